@@ -0,0 +1,124 @@
+package bond
+
+import (
+	"io"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// BackendReader reads the keys of the backend.
+type BackendReader interface {
+	// Get returns the value of the key, or ErrNotFound if it does not exist.
+	// The value is valid until the closer is closed.
+	Get(key []byte) (data []byte, closer io.Closer, err error)
+
+	// Iter returns the iterator over the keys within the bounds of the
+	// options, in the byte-wise order.
+	Iter(opt *IterOptions) Iterator
+}
+
+// BackendWriter writes the keys of the backend.
+type BackendWriter interface {
+	Set(key []byte, value []byte, opt WriteOptions) error
+	Delete(key []byte, opt WriteOptions) error
+	DeleteRange(start []byte, end []byte, opt WriteOptions) error
+}
+
+// Backend is the key-value store the database is built on. The pebble
+// database opened in the directory of the database is the default one, the
+// other stores, e.g. BadgerDB or the remote key-value store, are plugged in
+// with Options.Backend.
+//
+// The features that rely on the pebble internals, e.g. the checkpoints, the
+// disk usage estimates and the compaction metrics, fail with
+// ErrNotSupported on the other backends.
+type Backend interface {
+	BackendReader
+	BackendWriter
+
+	// Batch returns the batch that reads its own writes on top of the backend.
+	Batch() BackendBatch
+
+	// Snapshot returns the point-in-time view of the backend.
+	Snapshot() BackendSnapshot
+
+	Close() error
+}
+
+// BackendBatch is the set of the writes committed to the backend atomically.
+// The reads of the batch see its writes.
+type BackendBatch interface {
+	BackendReader
+	BackendWriter
+
+	// Merge merges the operand into the value of the key. The operands of the
+	// sums maintained with MaintainSum, the 8-byte big-endian integers, are
+	// added up, the operands of the other keys are appended to their values.
+	// The backends that do not support merges return ErrNotSupported, then
+	// the features relying on them, e.g. the sums and the delta updates, fail.
+	Merge(key []byte, value []byte, opt WriteOptions) error
+
+	// Apply adds the writes of the batch created by the same backend.
+	Apply(batch BackendBatch, opt WriteOptions) error
+
+	// Mutations returns the copies of the sets, the deletes and the merges of
+	// the batch.
+	Mutations() []WriteMutation
+
+	// Count returns the number of the writes and Len the size of the batch
+	// in bytes.
+	Count() uint32
+	Len() int
+	Empty() bool
+
+	Reset()
+	Commit(opt WriteOptions) error
+	Close() error
+
+	// SeqNum returns the sequence number of the committed batch, or zero if
+	// the backend does not number the commits.
+	SeqNum() uint64
+}
+
+// BackendSnapshot is the point-in-time view of the backend.
+type BackendSnapshot interface {
+	BackendReader
+
+	Close() error
+}
+
+// backend returns the backend the database reads from.
+func (db *_db) backend() Backend {
+	if db.secondary != nil {
+		return &_secondaryBackend{secondary: db.secondary}
+	}
+	return db.store
+}
+
+// pebbleDB returns the pebble instance the database reads from, or
+// ErrNotSupported if the database is not backed by pebble. The instance is
+// valid until release is called.
+func (db *_db) pebbleDB() (pdb *pebble.DB, release func(), err error) {
+	if db.secondary != nil {
+		instance, err := db.secondary.acquire()
+		if err != nil {
+			return nil, nil, err
+		}
+		return instance.pebble, func() { _ = instance.release() }, nil
+	}
+	if db.pebble == nil {
+		return nil, nil, ErrNotSupported
+	}
+	return db.pebble, func() {}, nil
+}
+
+// estimateDiskUsage returns the estimated disk space used by the keys in the
+// range, or ErrNotSupported if the database is not backed by pebble.
+func (db *_db) estimateDiskUsage(start []byte, end []byte) (uint64, error) {
+	pdb, release, err := db.pebbleDB()
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	return pdb.EstimateDiskUsage(start, end)
+}
@@ -0,0 +1,487 @@
+package bond
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// NewMemoryBackend returns the backend that keeps the keys in memory, e.g.
+// for the tests. It's not durable, the keys are lost once the database is
+// closed, so it's only used if it's set with Options.Backend.
+//
+// The committed keys are kept in the sorted slice that is replaced by every
+// commit, so the snapshots and the iterators are free and the commits cost
+// the size of the data. The features that rely on the pebble internals fail
+// with ErrNotSupported, as with the other backends.
+func NewMemoryBackend() Backend {
+	return &_memoryBackend{}
+}
+
+// errMemoryBackendClosed is returned by the writes to the closed backend.
+var errMemoryBackendClosed = fmt.Errorf("memory backend is closed")
+
+type _memoryEntry struct {
+	key   []byte
+	value []byte
+}
+
+// _memoryOp is the write of the batch. The value of the range deletion is
+// its end key.
+type _memoryOp struct {
+	kind  WriteChangeKind
+	key   []byte
+	value []byte
+}
+
+type _memoryBackend struct {
+	// entries are the committed keys sorted by the key, the slice is never
+	// modified, the commits replace it
+	entries []_memoryEntry
+	seq     uint64
+	closed  bool
+
+	mutex sync.RWMutex
+}
+
+func (b *_memoryBackend) current() []_memoryEntry {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.entries
+}
+
+func (b *_memoryBackend) Get(key []byte) (data []byte, closer io.Closer, err error) {
+	return memoryGet(b.current(), key)
+}
+
+func (b *_memoryBackend) Iter(opt *IterOptions) Iterator {
+	return newMemoryIterator(b.current(), opt)
+}
+
+func (b *_memoryBackend) Set(key []byte, value []byte, opt WriteOptions) error {
+	return b.write(opt, func(batch BackendBatch) error { return batch.Set(key, value, opt) })
+}
+
+func (b *_memoryBackend) Delete(key []byte, opt WriteOptions) error {
+	return b.write(opt, func(batch BackendBatch) error { return batch.Delete(key, opt) })
+}
+
+func (b *_memoryBackend) DeleteRange(start []byte, end []byte, opt WriteOptions) error {
+	return b.write(opt, func(batch BackendBatch) error { return batch.DeleteRange(start, end, opt) })
+}
+
+// write commits the write of the function in its own batch.
+func (b *_memoryBackend) write(opt WriteOptions, f func(batch BackendBatch) error) error {
+	batch := b.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	err := f(batch)
+	if err != nil {
+		return err
+	}
+	return batch.Commit(opt)
+}
+
+func (b *_memoryBackend) Batch() BackendBatch {
+	return &_memoryBatch{backend: b, latest: make(map[string]int)}
+}
+
+func (b *_memoryBackend) Snapshot() BackendSnapshot {
+	return &_memorySnapshot{entries: b.current()}
+}
+
+func (b *_memoryBackend) Close() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.entries, b.closed = nil, true
+	return nil
+}
+
+// commit applies the writes to the committed keys and returns the sequence
+// number of the commit.
+func (b *_memoryBackend) commit(ops []_memoryOp) (uint64, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.closed {
+		return 0, errMemoryBackendClosed
+	}
+
+	entries, err := applyMemoryOps(b.entries, ops)
+	if err != nil {
+		return 0, err
+	}
+
+	b.entries = entries
+	b.seq++
+	return b.seq, nil
+}
+
+type _memoryBatch struct {
+	backend *_memoryBackend
+
+	ops  []_memoryOp
+	size int
+	seq  uint64
+
+	// latest are the indexes of the last sets, deletes and merges of the
+	// keys, and rangeDeletes the indexes of the range deletions, so the
+	// batch reads the keys without applying its writes
+	latest       map[string]int
+	rangeDeletes []int
+}
+
+func (b *_memoryBatch) Get(key []byte) (data []byte, closer io.Closer, err error) {
+	i, ok := b.latest[string(key)]
+	if !ok {
+		i = -1
+	}
+
+	for _, rangeDelete := range b.rangeDeletes {
+		if rangeDelete > i && b.ops[rangeDelete].covers(key) {
+			i, ok = rangeDelete, true
+		}
+	}
+
+	switch {
+	case !ok:
+		return memoryGet(b.backend.current(), key)
+	case b.ops[i].kind == WriteChangeSet:
+		return b.ops[i].value, _memoryCloser{}, nil
+	case b.ops[i].kind == WriteChangeMerge:
+		entries, err := b.view()
+		if err != nil {
+			return nil, nil, err
+		}
+		return memoryGet(entries, key)
+	default:
+		return nil, nil, ErrNotFound
+	}
+}
+
+func (b *_memoryBatch) Iter(opt *IterOptions) Iterator {
+	entries, err := b.view()
+	if err != nil {
+		return &_memoryIterator{pos: -1, err: err}
+	}
+	return newMemoryIterator(entries, opt)
+}
+
+// view returns the committed keys with the writes of the batch applied.
+func (b *_memoryBatch) view() ([]_memoryEntry, error) {
+	return applyMemoryOps(b.backend.current(), b.ops)
+}
+
+func (b *_memoryBatch) Set(key []byte, value []byte, _ WriteOptions) error {
+	b.add(_memoryOp{kind: WriteChangeSet, key: key, value: value})
+	return nil
+}
+
+func (b *_memoryBatch) Delete(key []byte, _ WriteOptions) error {
+	b.add(_memoryOp{kind: WriteChangeDelete, key: key})
+	return nil
+}
+
+func (b *_memoryBatch) DeleteRange(start []byte, end []byte, _ WriteOptions) error {
+	b.add(_memoryOp{kind: WriteChangeDeleteRange, key: start, value: end})
+	return nil
+}
+
+func (b *_memoryBatch) Merge(key []byte, value []byte, _ WriteOptions) error {
+	b.add(_memoryOp{kind: WriteChangeMerge, key: key, value: value})
+	return nil
+}
+
+// add adds the copy of the write to the batch.
+func (b *_memoryBatch) add(op _memoryOp) {
+	op.key = append([]byte{}, op.key...)
+	if op.value != nil {
+		op.value = append([]byte{}, op.value...)
+	}
+
+	if op.kind == WriteChangeDeleteRange {
+		b.rangeDeletes = append(b.rangeDeletes, len(b.ops))
+	} else {
+		b.latest[string(op.key)] = len(b.ops)
+	}
+
+	b.ops = append(b.ops, op)
+	b.size += len(op.key) + len(op.value)
+}
+
+func (b *_memoryBatch) Apply(batch BackendBatch, _ WriteOptions) error {
+	memoryBatch, ok := batch.(*_memoryBatch)
+	if !ok {
+		return fmt.Errorf("batch of the other backend can not be applied")
+	}
+
+	for _, op := range memoryBatch.ops {
+		b.add(op)
+	}
+	return nil
+}
+
+func (b *_memoryBatch) Mutations() []WriteMutation {
+	mutations := make([]WriteMutation, 0, len(b.ops))
+	for _, op := range b.ops {
+		mutations = append(mutations, newWriteMutation(op.kind, op.key, op.value))
+	}
+	return mutations
+}
+
+func (b *_memoryBatch) Count() uint32 {
+	return uint32(len(b.ops))
+}
+
+func (b *_memoryBatch) Len() int {
+	return b.size
+}
+
+func (b *_memoryBatch) Empty() bool {
+	return len(b.ops) == 0
+}
+
+func (b *_memoryBatch) Reset() {
+	b.ops, b.size, b.seq = nil, 0, 0
+	b.latest, b.rangeDeletes = make(map[string]int), nil
+}
+
+func (b *_memoryBatch) Commit(_ WriteOptions) error {
+	seq, err := b.backend.commit(b.ops)
+	if err != nil {
+		return err
+	}
+
+	b.seq = seq
+	return nil
+}
+
+func (b *_memoryBatch) Close() error {
+	return nil
+}
+
+func (b *_memoryBatch) SeqNum() uint64 {
+	return b.seq
+}
+
+type _memorySnapshot struct {
+	entries []_memoryEntry
+}
+
+func (s *_memorySnapshot) Get(key []byte) (data []byte, closer io.Closer, err error) {
+	return memoryGet(s.entries, key)
+}
+
+func (s *_memorySnapshot) Iter(opt *IterOptions) Iterator {
+	return newMemoryIterator(s.entries, opt)
+}
+
+func (s *_memorySnapshot) Close() error {
+	return nil
+}
+
+// _memoryIterator iterates over the entries within the bounds. The prefix is
+// set by SeekPrefixGE, the iterator is then only valid on the keys of the
+// prefix, as the pebble iterator with the DefaultKeyComparer.
+type _memoryIterator struct {
+	entries []_memoryEntry
+	pos     int
+	prefix  []byte
+	err     error
+}
+
+func newMemoryIterator(entries []_memoryEntry, opt *IterOptions) *_memoryIterator {
+	if opt != nil && opt.UpperBound != nil {
+		entries = entries[:memorySeek(entries, opt.UpperBound)]
+	}
+	if opt != nil && opt.LowerBound != nil {
+		entries = entries[memorySeek(entries, opt.LowerBound):]
+	}
+	return &_memoryIterator{entries: entries, pos: -1}
+}
+
+func (it *_memoryIterator) First() bool {
+	it.pos, it.prefix = 0, nil
+	return it.Valid()
+}
+
+func (it *_memoryIterator) Last() bool {
+	it.pos, it.prefix = len(it.entries)-1, nil
+	return it.Valid()
+}
+
+func (it *_memoryIterator) Prev() bool {
+	if it.pos >= 0 {
+		it.pos--
+	}
+	return it.Valid()
+}
+
+func (it *_memoryIterator) Next() bool {
+	if it.pos < len(it.entries) {
+		it.pos++
+	}
+	return it.Valid()
+}
+
+func (it *_memoryIterator) Valid() bool {
+	if it.err != nil || it.pos < 0 || it.pos >= len(it.entries) {
+		return false
+	}
+
+	key := it.entries[it.pos].key
+	return it.prefix == nil || bytes.Equal(key[:_KeyPrefixSplitIndex(key)], it.prefix)
+}
+
+func (it *_memoryIterator) Error() error {
+	return it.err
+}
+
+func (it *_memoryIterator) SeekGE(key []byte) bool {
+	it.pos, it.prefix = memorySeek(it.entries, key), nil
+	return it.Valid()
+}
+
+func (it *_memoryIterator) SeekPrefixGE(key []byte) bool {
+	it.pos = memorySeek(it.entries, key)
+	it.prefix = append([]byte{}, key[:_KeyPrefixSplitIndex(key)]...)
+	return it.Valid()
+}
+
+func (it *_memoryIterator) SeekLT(key []byte) bool {
+	it.pos, it.prefix = memorySeek(it.entries, key)-1, nil
+	return it.Valid()
+}
+
+func (it *_memoryIterator) Key() []byte {
+	return it.entries[it.pos].key
+}
+
+func (it *_memoryIterator) Value() []byte {
+	return it.entries[it.pos].value
+}
+
+func (it *_memoryIterator) Close() error {
+	return it.err
+}
+
+type _memoryCloser struct{}
+
+func (_memoryCloser) Close() error {
+	return nil
+}
+
+// covers returns true if the range deletion covers the key.
+func (op _memoryOp) covers(key []byte) bool {
+	return bytes.Compare(key, op.key) >= 0 && bytes.Compare(key, op.value) < 0
+}
+
+// memorySeek returns the index of the first entry with the key greater than or
+// equal to the key.
+func memorySeek(entries []_memoryEntry, key []byte) int {
+	return sort.Search(len(entries), func(i int) bool {
+		return bytes.Compare(entries[i].key, key) >= 0
+	})
+}
+
+func memoryGet(entries []_memoryEntry, key []byte) ([]byte, io.Closer, error) {
+	i := memorySeek(entries, key)
+	if i == len(entries) || !bytes.Equal(entries[i].key, key) {
+		return nil, nil, ErrNotFound
+	}
+	return entries[i].value, _memoryCloser{}, nil
+}
+
+// _memoryChange is the value of the key changed by the writes.
+type _memoryChange struct {
+	value   []byte
+	deleted bool
+}
+
+// applyMemoryOps returns the new entries with the writes applied, the entries
+// are not modified.
+func applyMemoryOps(entries []_memoryEntry, ops []_memoryOp) ([]_memoryEntry, error) {
+	if len(ops) == 0 {
+		return entries, nil
+	}
+
+	changes := make(map[string]_memoryChange, len(ops))
+	for _, op := range ops {
+		switch op.kind {
+		case WriteChangeSet:
+			changes[string(op.key)] = _memoryChange{value: op.value}
+		case WriteChangeDelete:
+			changes[string(op.key)] = _memoryChange{deleted: true}
+		case WriteChangeDeleteRange:
+			for i := memorySeek(entries, op.key); i < len(entries) && op.covers(entries[i].key); i++ {
+				changes[string(entries[i].key)] = _memoryChange{deleted: true}
+			}
+			for key := range changes {
+				if op.covers([]byte(key)) {
+					changes[key] = _memoryChange{deleted: true}
+				}
+			}
+		case WriteChangeMerge:
+			old, exists := changes[string(op.key)]
+			if !exists {
+				value, _, err := memoryGet(entries, op.key)
+				old = _memoryChange{value: value, deleted: err != nil}
+			}
+
+			value, err := memoryMerge(op.key, old.value, !old.deleted, op.value)
+			if err != nil {
+				return nil, err
+			}
+			changes[string(op.key)] = _memoryChange{value: value}
+		}
+	}
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]_memoryEntry, 0, len(entries)+len(keys))
+	i := 0
+	for _, key := range keys {
+		for ; i < len(entries) && string(entries[i].key) < key; i++ {
+			result = append(result, entries[i])
+		}
+		if i < len(entries) && string(entries[i].key) == key {
+			i++
+		}
+
+		if change := changes[key]; !change.deleted {
+			result = append(result, _memoryEntry{key: []byte(key), value: change.value})
+		}
+	}
+	return append(result, entries[i:]...), nil
+}
+
+// memoryMerge merges the operand into the value as the DefaultMerger does: the
+// sums of the aggregates are added up, the other operands are appended.
+func memoryMerge(key []byte, value []byte, exists bool, operand []byte) ([]byte, error) {
+	if len(key) < 2 || key[0] != BOND_DB_DATA_TABLE_ID || key[1] != BOND_DB_DATA_AGGREGATE_INDEX_ID {
+		return append(append([]byte{}, value...), operand...), nil
+	}
+
+	sum, err := decodeSum(operand)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		base, err := decodeSum(value)
+		if err != nil {
+			return nil, err
+		}
+		sum += base
+	}
+	return encodeSum(sum), nil
+}
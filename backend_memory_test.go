@@ -0,0 +1,186 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_MemoryBackend(t *testing.T) {
+	db, err := Open(dbName, &Options{Backend: NewMemoryBackend()})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	accountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{accountAddressIndex})
+	require.NoError(t, err)
+
+	balanceByAccount := tokenBalanceTable.MaintainSum("balance_by_account",
+		func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		func(tb *TokenBalance) int64 {
+			return int64(tb.Balance)
+		})
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 7},
+		{ID: 3, AccountAddress: "0xtestAccount2", Balance: 3},
+	})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Execute(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	require.Len(t, tokenBalances, 2)
+	assert.Equal(t, uint64(1), tokenBalances[0].ID)
+	assert.Equal(t, uint64(2), tokenBalances[1].ID)
+
+	sum, err := balanceByAccount.Get(&TokenBalance{AccountAddress: "0xtestAccount"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), sum)
+
+	// the batch reads its own writes, the database does not until it's committed
+	batch := db.Batch()
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 2, AccountAddress: "0xtestAccount2", Balance: 8},
+	}, batch)
+	require.NoError(t, err)
+	err = tokenBalanceTable.Delete(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+	}, batch)
+	require.NoError(t, err)
+
+	tb, err := tokenBalanceTable.Get(&TokenBalance{ID: 2}, batch)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), tb.Balance)
+
+	_, err = tokenBalanceTable.Get(&TokenBalance{ID: 1}, batch)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	tb, err = tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), tb.Balance)
+
+	snapshot := db.RetainSnapshot()
+
+	require.NoError(t, batch.Commit(Sync))
+	_ = batch.Close()
+
+	tokenBalances = nil
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount2"}).
+		Execute(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	require.Len(t, tokenBalances, 2)
+	assert.Equal(t, uint64(2), tokenBalances[0].ID)
+	assert.Equal(t, uint64(3), tokenBalances[1].ID)
+
+	sum, err = balanceByAccount.Get(&TokenBalance{AccountAddress: "0xtestAccount2"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), sum)
+
+	sum, err = balanceByAccount.Get(&TokenBalance{AccountAddress: "0xtestAccount"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), sum)
+
+	// the snapshot keeps the rows from before the commit
+	tokenBalances = nil
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		AsOf(snapshot).
+		Execute(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	require.Len(t, tokenBalances, 2)
+}
+
+func TestBond_MemoryBackend_Iter(t *testing.T) {
+	backend := NewMemoryBackend()
+	defer func() {
+		_ = backend.Close()
+	}()
+
+	for _, key := range []string{"a1", "a2", "b1", "b2", "c1"} {
+		require.NoError(t, backend.Set([]byte(key), []byte(key), Sync))
+	}
+
+	require.NoError(t, backend.DeleteRange([]byte("a2"), []byte("b2"), Sync))
+
+	_, _, err := backend.Get([]byte("b1"))
+	require.ErrorIs(t, err, ErrNotFound)
+
+	collect := func(iter Iterator) []string {
+		defer func() {
+			_ = iter.Close()
+		}()
+
+		var keys []string
+		for ; iter.Valid(); iter.Next() {
+			keys = append(keys, string(iter.Key()))
+		}
+		return keys
+	}
+
+	iter := backend.Iter(&IterOptions{})
+	iter.First()
+	assert.Equal(t, []string{"a1", "b2", "c1"}, collect(iter))
+
+	iter = backend.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte("b"),
+			UpperBound: []byte("c"),
+		},
+	})
+	iter.First()
+	assert.Equal(t, []string{"b2"}, collect(iter))
+
+	// the batch iterates over the committed keys with its own writes
+	batch := backend.Batch()
+	require.NoError(t, batch.Set([]byte("b1"), []byte("b1"), Sync))
+	require.NoError(t, batch.Delete([]byte("c1"), Sync))
+
+	iter = batch.Iter(&IterOptions{})
+	iter.Last()
+	var keys []string
+	for ; iter.Valid(); iter.Prev() {
+		keys = append(keys, string(iter.Key()))
+	}
+	_ = iter.Close()
+	assert.Equal(t, []string{"b2", "b1", "a1"}, keys)
+
+	require.NoError(t, batch.Commit(Sync))
+	require.NoError(t, batch.Close())
+
+	iter = backend.Iter(&IterOptions{})
+	iter.SeekGE([]byte("b"))
+	assert.Equal(t, []string{"b1", "b2"}, collect(iter))
+}
@@ -0,0 +1,138 @@
+package bond
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// NewPebbleBackend returns the backend of the pebble database. The database
+// needs to be opened with the DefaultKeyComparer and the DefaultMerger, as Open
+// does.
+func NewPebbleBackend(pdb *pebble.DB) Backend {
+	return &_pebbleBackend{db: pdb}
+}
+
+type _pebbleBackend struct {
+	db *pebble.DB
+}
+
+func (b *_pebbleBackend) Get(key []byte) (data []byte, closer io.Closer, err error) {
+	data, closer, err = b.db.Get(key)
+	return data, closer, notFound(err)
+}
+
+func (b *_pebbleBackend) Iter(opt *IterOptions) Iterator {
+	return b.db.NewIter(pebbleIterOptions(opt))
+}
+
+func (b *_pebbleBackend) Set(key []byte, value []byte, opt WriteOptions) error {
+	return b.db.Set(key, value, pebbleWriteOptions(opt))
+}
+
+func (b *_pebbleBackend) Delete(key []byte, opt WriteOptions) error {
+	return b.db.Delete(key, pebbleWriteOptions(opt))
+}
+
+func (b *_pebbleBackend) DeleteRange(start []byte, end []byte, opt WriteOptions) error {
+	return b.db.DeleteRange(start, end, pebbleWriteOptions(opt))
+}
+
+func (b *_pebbleBackend) Batch() BackendBatch {
+	return &_pebbleBatch{batch: b.db.NewIndexedBatch()}
+}
+
+func (b *_pebbleBackend) Snapshot() BackendSnapshot {
+	return &_pebbleSnapshot{snapshot: b.db.NewSnapshot()}
+}
+
+func (b *_pebbleBackend) Close() error {
+	return b.db.Close()
+}
+
+type _pebbleBatch struct {
+	batch *pebble.Batch
+}
+
+func (b *_pebbleBatch) Get(key []byte) (data []byte, closer io.Closer, err error) {
+	data, closer, err = b.batch.Get(key)
+	return data, closer, notFound(err)
+}
+
+func (b *_pebbleBatch) Iter(opt *IterOptions) Iterator {
+	return b.batch.NewIter(pebbleIterOptions(opt))
+}
+
+func (b *_pebbleBatch) Set(key []byte, value []byte, opt WriteOptions) error {
+	return b.batch.Set(key, value, pebbleWriteOptions(opt))
+}
+
+func (b *_pebbleBatch) Delete(key []byte, opt WriteOptions) error {
+	return b.batch.Delete(key, pebbleWriteOptions(opt))
+}
+
+func (b *_pebbleBatch) DeleteRange(start []byte, end []byte, opt WriteOptions) error {
+	return b.batch.DeleteRange(start, end, pebbleWriteOptions(opt))
+}
+
+func (b *_pebbleBatch) Merge(key []byte, value []byte, opt WriteOptions) error {
+	return b.batch.Merge(key, value, pebbleWriteOptions(opt))
+}
+
+func (b *_pebbleBatch) Apply(batch BackendBatch, opt WriteOptions) error {
+	pebbleBatch, ok := batch.(*_pebbleBatch)
+	if !ok {
+		return fmt.Errorf("batch of the other backend can not be applied")
+	}
+	return b.batch.Apply(pebbleBatch.batch, pebbleWriteOptions(opt))
+}
+
+func (b *_pebbleBatch) Mutations() []WriteMutation {
+	return batchMutations(b.batch)
+}
+
+func (b *_pebbleBatch) Count() uint32 {
+	return b.batch.Count()
+}
+
+func (b *_pebbleBatch) Len() int {
+	return b.batch.Len()
+}
+
+func (b *_pebbleBatch) Empty() bool {
+	return b.batch.Empty()
+}
+
+func (b *_pebbleBatch) Reset() {
+	b.batch.Reset()
+}
+
+func (b *_pebbleBatch) Commit(opt WriteOptions) error {
+	return b.batch.Commit(pebbleWriteOptions(opt))
+}
+
+func (b *_pebbleBatch) Close() error {
+	return b.batch.Close()
+}
+
+func (b *_pebbleBatch) SeqNum() uint64 {
+	return b.batch.SeqNum()
+}
+
+type _pebbleSnapshot struct {
+	snapshot *pebble.Snapshot
+}
+
+func (s *_pebbleSnapshot) Get(key []byte) (data []byte, closer io.Closer, err error) {
+	data, closer, err = s.snapshot.Get(key)
+	return data, closer, notFound(err)
+}
+
+func (s *_pebbleSnapshot) Iter(opt *IterOptions) Iterator {
+	return s.snapshot.NewIter(pebbleIterOptions(opt))
+}
+
+func (s *_pebbleSnapshot) Close() error {
+	return s.snapshot.Close()
+}
@@ -0,0 +1,96 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type _countingBackend struct {
+	Backend
+
+	gets    int
+	batches int
+	closed  bool
+}
+
+func (b *_countingBackend) Get(key []byte) ([]byte, io.Closer, error) {
+	b.gets++
+	return b.Backend.Get(key)
+}
+
+func (b *_countingBackend) Batch() BackendBatch {
+	b.batches++
+	return b.Backend.Batch()
+}
+
+func (b *_countingBackend) Close() error {
+	b.closed = true
+	return b.Backend.Close()
+}
+
+func TestBond_Open_Backend(t *testing.T) {
+	pdb, err := pebble.Open(dbName, &pebble.Options{
+		Comparer: DefaultKeyComparer(),
+		Merger:   DefaultMerger(),
+	})
+	require.NoError(t, err)
+
+	backend := &_countingBackend{Backend: NewPebbleBackend(pdb)}
+
+	db, err := Open(dbName, &Options{Backend: backend})
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dbName)
+	}()
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	balanceByAccount := tokenBalanceTable.MaintainSum("balance_by_account",
+		func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		func(tb *TokenBalance) int64 {
+			return int64(tb.Balance)
+		})
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 7},
+	})
+	require.NoError(t, err)
+
+	tb, err := tokenBalanceTable.Get(&TokenBalance{ID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(7), tb.Balance)
+
+	sum, err := balanceByAccount.Get(&TokenBalance{AccountAddress: "0xtestAccount"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(12), sum)
+
+	assert.Greater(t, backend.gets, 0)
+	assert.Greater(t, backend.batches, 0)
+
+	err = db.SnapshotStream(context.Background(), &bytes.Buffer{})
+	require.ErrorIs(t, err, ErrNotSupported)
+
+	require.NoError(t, db.Close())
+	assert.True(t, backend.closed)
+}
@@ -0,0 +1,256 @@
+package bond
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultBackgroundConcurrency is the number of the background tasks run at
+// the same time if Options.BackgroundConcurrency is not set.
+const DefaultBackgroundConcurrency = 1
+
+// BackgroundTask is the single run of the periodic background task. The
+// context is done once the run exceeds its deadline, or the background
+// tasks are paused or stopped, so the task should check it between the
+// batches of its work.
+type BackgroundTask func(ctx context.Context) error
+
+// Background runs the periodic maintenance tasks of the database, such as
+// the table retention, with the global concurrency limit.
+type Background interface {
+	// Schedule runs the task every interval. The run that is due while the
+	// previous run of the task is not finished is skipped.
+	Schedule(name string, interval time.Duration, task BackgroundTask)
+
+	// Pause cancels the running tasks and waits for them to return, the
+	// tasks are not run until Resume is called. It must not be called from
+	// the task.
+	Pause()
+	Resume()
+	Paused() bool
+}
+
+// BackgroundScheduler gives access to the background tasks of the database.
+type BackgroundScheduler interface {
+	Background() Background
+}
+
+type _backgroundTask struct {
+	name     string
+	interval time.Duration
+	task     BackgroundTask
+
+	next    time.Time
+	running bool
+}
+
+// _background runs the due tasks from the single goroutine, the tasks run in
+// their own goroutines once they get the concurrency slot.
+type _background struct {
+	tasks []*_backgroundTask
+
+	slots   chan struct{}
+	timeout time.Duration
+	onError func(task string, err error)
+
+	// runCtx is cancelled when the tasks are paused or stopped
+	runCtx    context.Context
+	runCancel context.CancelFunc
+	running   sync.WaitGroup
+
+	paused bool
+	closed bool
+	wake   chan struct{}
+	done   chan struct{}
+
+	mutex sync.Mutex
+}
+
+func newBackground(opts *Options) *_background {
+	concurrency := opts.BackgroundConcurrency
+	if concurrency <= 0 {
+		concurrency = DefaultBackgroundConcurrency
+	}
+
+	b := &_background{
+		slots:   make(chan struct{}, concurrency),
+		timeout: opts.BackgroundTaskTimeout,
+		onError: opts.OnBackgroundError,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	b.runCtx, b.runCancel = context.WithCancel(context.Background())
+
+	go b.run()
+	return b
+}
+
+// Background returns the background tasks of the database.
+//
+// Example:
+//
+//	// pause the maintenance during the backup
+//	db.Background().Pause()
+//	defer db.Background().Resume()
+func (db *_db) Background() Background {
+	return db.background
+}
+
+func (b *_background) Schedule(name string, interval time.Duration, task BackgroundTask) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.tasks = append(b.tasks, &_backgroundTask{
+		name:     name,
+		interval: interval,
+		task:     task,
+		next:     time.Now().Add(interval),
+	})
+	b.notify()
+}
+
+func (b *_background) Pause() {
+	b.mutex.Lock()
+	if b.paused || b.closed {
+		b.mutex.Unlock()
+		return
+	}
+
+	b.paused = true
+	b.runCancel()
+	b.runCtx, b.runCancel = context.WithCancel(context.Background())
+	b.mutex.Unlock()
+
+	b.running.Wait()
+}
+
+func (b *_background) Resume() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.paused = false
+	b.notify()
+}
+
+func (b *_background) Paused() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.paused
+}
+
+// close stops the tasks, as the database is being closed.
+func (b *_background) close() {
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return
+	}
+
+	b.closed = true
+	b.runCancel()
+	b.notify()
+	b.mutex.Unlock()
+
+	<-b.done
+	b.running.Wait()
+}
+
+func (b *_background) notify() {
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (b *_background) run() {
+	defer close(b.done)
+
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		b.mutex.Lock()
+		if b.closed {
+			b.mutex.Unlock()
+			return
+		}
+
+		now := time.Now()
+		wait := time.Hour
+		if !b.paused {
+			for _, task := range b.tasks {
+				if task.running {
+					continue
+				}
+
+				if !task.next.After(now) {
+					task.next = now.Add(task.interval)
+					b.start(task)
+					continue
+				}
+
+				if d := task.next.Sub(now); d < wait {
+					wait = d
+				}
+			}
+		}
+		b.mutex.Unlock()
+
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-b.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// start runs the task once it gets the concurrency slot, it's called with the
+// mutex held.
+func (b *_background) start(task *_backgroundTask) {
+	task.running = true
+	b.running.Add(1)
+
+	ctx := b.runCtx
+	go func() {
+		defer func() {
+			b.mutex.Lock()
+			task.running = false
+			b.notify()
+			b.mutex.Unlock()
+
+			b.running.Done()
+		}()
+
+		select {
+		case b.slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		defer func() {
+			<-b.slots
+		}()
+
+		timeout := b.timeout
+		if timeout <= 0 {
+			timeout = task.interval
+		}
+
+		runCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		// the runs cancelled by the pause or the close are not failures
+		err := task.task(runCtx)
+		if err != nil && ctx.Err() == nil && b.onError != nil {
+			b.onError(task.name, err)
+		}
+	}()
+}
@@ -0,0 +1,92 @@
+package bond
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Background(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		errs   = map[string]error{}
+		errRun = errors.New("run failed")
+	)
+
+	db, err := Open(dbName, &Options{
+		BackgroundTaskTimeout: 50 * time.Millisecond,
+		OnBackgroundError: func(task string, err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			errs[task] = err
+		},
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	var (
+		runs       int32
+		running    int32
+		concurrent int32
+	)
+	task := func(ctx context.Context) error {
+		if atomic.AddInt32(&running, 1) > 1 {
+			atomic.StoreInt32(&concurrent, 1)
+		}
+		defer atomic.AddInt32(&running, -1)
+
+		atomic.AddInt32(&runs, 1)
+		time.Sleep(time.Millisecond)
+		return nil
+	}
+
+	db.Background().Schedule("first", 5*time.Millisecond, task)
+	db.Background().Schedule("second", 5*time.Millisecond, task)
+	db.Background().Schedule("failing", 5*time.Millisecond, func(ctx context.Context) error {
+		return errRun
+	})
+	db.Background().Schedule("slow", 5*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&runs) >= 10
+	}, time.Second, time.Millisecond)
+
+	// one task runs at a time
+	assert.Equal(t, int32(0), atomic.LoadInt32(&concurrent))
+
+	require.Eventually(t, func() bool {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return errs["failing"] != nil && errs["slow"] != nil
+	}, time.Second, time.Millisecond)
+
+	mutex.Lock()
+	assert.ErrorIs(t, errs["failing"], errRun)
+	assert.ErrorIs(t, errs["slow"], context.DeadlineExceeded)
+	mutex.Unlock()
+
+	db.Background().Pause()
+	assert.True(t, db.Background().Paused())
+
+	paused := atomic.LoadInt32(&runs)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, paused, atomic.LoadInt32(&runs))
+
+	db.Background().Resume()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&runs) > paused
+	}, time.Second, time.Millisecond)
+}
@@ -3,12 +3,29 @@ package bond
 import (
 	"fmt"
 	"io"
-
-	"github.com/cockroachdb/pebble"
+	"time"
 )
 
 var sequenceId = NumberSequence{}
 
+// CommittedBatchInfo describes the batch that was committed to the store.
+type CommittedBatchInfo struct {
+	ID    uint64
+	Count uint32
+	Size  int
+
+	// Sync is true if the batch was synced to the WAL before the commit
+	// returned, so it survives the crash.
+	Sync bool
+	Time time.Time
+
+	// Seq is the sequence number of the commit in the store, increasing with
+	// every commit, e.g. to correlate the write with the mutations passed to
+	// the write interceptor, or to wait for the replica fed by them to apply
+	// it. It's zero if the backend does not number the commits.
+	Seq uint64
+}
+
 type Committer interface {
 	Commit(opt WriteOptions) error
 
@@ -16,6 +33,11 @@ type Committer interface {
 	OnCommitted(func(b Batch))
 	OnError(func(b Batch, err error))
 	OnClose(func(b Batch))
+
+	// AfterCommit registers the listener called once the batch is committed
+	// to the store. Unlike OnCommitted, the listeners of the batch applied to
+	// another batch are called when that batch is committed, with its info.
+	AfterCommit(func(info CommittedBatchInfo))
 }
 
 type Batch interface {
@@ -36,21 +58,24 @@ type Batch interface {
 }
 
 type _batch struct {
-	*pebble.Batch
+	BackendBatch
 
 	id uint64
+	db *_db
 
 	onCommitCallbacks    []func(b Batch) error
 	onCommittedCallbacks []func(b Batch)
 	onErrorCallbacks     []func(b Batch, err error)
 	onClose              []func(b Batch)
+	afterCommit          []func(info CommittedBatchInfo)
 }
 
 func newBatch(db *_db) Batch {
 	id, _ := sequenceId.Next()
 	return &_batch{
-		Batch: db.pebble.NewIndexedBatch(),
-		id:    id,
+		BackendBatch: db.backend().Batch(),
+		id:           id,
+		db:           db,
 	}
 }
 
@@ -59,7 +84,7 @@ func (b *_batch) ID() uint64 {
 }
 
 func (b *_batch) Reset() {
-	b.Batch.Reset()
+	b.BackendBatch.Reset()
 
 	b.id, _ = sequenceId.Next()
 
@@ -67,26 +92,27 @@ func (b *_batch) Reset() {
 	b.onCommittedCallbacks = nil
 	b.onErrorCallbacks = nil
 	b.onClose = nil
+	b.afterCommit = nil
 }
 
 func (b *_batch) Get(key []byte, _ ...Batch) (data []byte, closer io.Closer, err error) {
-	return b.Batch.Get(key)
+	return b.BackendBatch.Get(key)
 }
 
 func (b *_batch) Set(key []byte, value []byte, opt WriteOptions, _ ...Batch) error {
-	return b.Batch.Set(key, value, pebbleWriteOptions(opt))
+	return b.BackendBatch.Set(key, value, opt)
 }
 
 func (b *_batch) Delete(key []byte, opts WriteOptions, _ ...Batch) error {
-	return b.Batch.Delete(key, pebbleWriteOptions(opts))
+	return b.BackendBatch.Delete(key, opts)
 }
 
 func (b *_batch) DeleteRange(start []byte, end []byte, opt WriteOptions, _ ...Batch) error {
-	return b.Batch.DeleteRange(start, end, pebbleWriteOptions(opt))
+	return b.BackendBatch.DeleteRange(start, end, opt)
 }
 
 func (b *_batch) Iter(opt *IterOptions, _ ...Batch) Iterator {
-	return b.NewIter(pebbleIterOptions(opt))
+	return b.BackendBatch.Iter(opt)
 }
 
 func (b *_batch) Apply(batch Batch, opt WriteOptions) error {
@@ -101,38 +127,84 @@ func (b *_batch) Apply(batch Batch, opt WriteOptions) error {
 	}
 	defer innerBatch.notifyOnCommitted()
 
-	err = b.Batch.Apply(innerBatch.Batch, pebbleWriteOptions(opt))
+	err = b.BackendBatch.Apply(innerBatch.BackendBatch, opt)
 	if err != nil {
 		innerBatch.notifyOnError(err)
 		return err
 	}
+
+	// the applied writes land with this batch
+	b.afterCommit = append(b.afterCommit, innerBatch.afterCommit...)
+	innerBatch.afterCommit = nil
 	return nil
 }
 
+// Commit commits the batch. The batch committed with DryRun is discarded
+// without running the commit callbacks.
 func (b *_batch) Commit(opt WriteOptions) error {
 	if b.Empty() {
 		return nil
 	}
 
+	if opt.DryRun {
+		opt.Report.addMutations(b.BackendBatch.Mutations())
+
+		// the callbacks of the discarded writes do not run once the batch
+		// is reused, the ones run on close, e.g. the quota refunds, run now
+		b.notifyOnClose()
+		b.Reset()
+		return nil
+	}
+
 	err := b.notifyOnCommit()
 	if err != nil {
 		return err
 	}
 
-	err = b.Batch.Commit(pebbleWriteOptions(opt))
+	if b.db.faultInjector != nil {
+		err = b.db.faultInjector(Fault{Point: FaultPointPreCommit})
+		if err != nil {
+			b.notifyOnError(err)
+			return err
+		}
+	}
+
+	info := CommittedBatchInfo{ID: b.id, Count: b.BackendBatch.Count(), Size: b.BackendBatch.Len(), Sync: opt.Sync}
+
+	var mutations []WriteMutation
+	if b.db.observesMutations() {
+		mutations = b.BackendBatch.Mutations()
+	}
+
+	err = b.BackendBatch.Commit(opt)
+	b.db.notifyWrite()
 	if err != nil {
 		b.notifyOnError(err)
 		return err
 	}
 
+	info.Seq = b.BackendBatch.SeqNum()
+	b.db.committed(info.Seq)
+	for i := range mutations {
+		mutations[i].Seq = info.Seq
+	}
+
+	b.db.notifyMutations(mutations)
+
 	b.notifyOnCommitted()
+
+	info.Time = b.db.clock.Now()
+	if opt.Committed != nil {
+		*opt.Committed = info
+	}
+	b.notifyAfterCommit(info)
 	return nil
 }
 
 func (b *_batch) Close() error {
 	b.notifyOnClose()
 
-	err := b.Batch.Close()
+	err := b.BackendBatch.Close()
 	if err != nil {
 		b.notifyOnError(err)
 		return err
@@ -156,6 +228,10 @@ func (b *_batch) OnClose(f func(b Batch)) {
 	b.onClose = append(b.onClose, f)
 }
 
+func (b *_batch) AfterCommit(f func(info CommittedBatchInfo)) {
+	b.afterCommit = append(b.afterCommit, f)
+}
+
 func (b *_batch) notifyOnCommit() error {
 	for _, f := range b.onCommitCallbacks {
 		err := f(b)
@@ -179,8 +255,14 @@ func (b *_batch) notifyOnError(err error) {
 	}
 }
 
+func (b *_batch) notifyAfterCommit(info CommittedBatchInfo) {
+	for _, f := range b.afterCommit {
+		f(info)
+	}
+}
+
 func (b *_batch) notifyOnClose() {
-	for _, f := range b.onCommittedCallbacks {
+	for _, f := range b.onClose {
 		f(b)
 	}
 }
@@ -2,6 +2,7 @@ package bond
 
 import (
 	"context"
+	"os"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -43,3 +44,137 @@ func Test_Batch_Callbacks(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 2, counter)
 }
+
+func Test_Batch_AfterCommit(t *testing.T) {
+	db, t1, _, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	var infos []CommittedBatchInfo
+
+	batch := db.Batch()
+
+	innerBatch := db.Batch()
+	defer func() { _ = innerBatch.Close() }()
+
+	innerBatch.AfterCommit(func(info CommittedBatchInfo) {
+		infos = append(infos, info)
+	})
+	batch.AfterCommit(func(info CommittedBatchInfo) {
+		infos = append(infos, info)
+	})
+
+	err := t1.Insert(context.Background(), []*TokenBalance{{ID: 1, AccountAddress: "0xtestAccount"}}, innerBatch)
+	require.NoError(t, err)
+
+	// the listeners of the applied batch wait for the batch it was applied to
+	err = batch.Apply(innerBatch, Sync)
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+
+	err = batch.Commit(NoSync)
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+
+	for _, info := range infos {
+		assert.Equal(t, batch.ID(), info.ID)
+		assert.NotZero(t, info.Count)
+		assert.False(t, info.Sync)
+		assert.False(t, info.Time.IsZero())
+		assert.NotZero(t, info.Seq)
+	}
+
+	closed := 0
+	batch.OnClose(func(b Batch) {
+		closed++
+	})
+	batch.OnCommitted(func(b Batch) {
+		t.Fatal("committed callback called on close")
+	})
+
+	err = batch.Close()
+	require.NoError(t, err)
+	assert.Equal(t, 1, closed)
+}
+
+func Test_Batch_CommittedSeq(t *testing.T) {
+	db, t1, _, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	var inserted CommittedBatchInfo
+	ctx := ContextWithWriteOptions(context.Background(), WriteOptions{Sync: true, Committed: &inserted})
+	err := t1.Insert(ctx, []*TokenBalance{{ID: 1, AccountAddress: "0xtestAccount"}})
+	require.NoError(t, err)
+	assert.NotZero(t, inserted.Seq)
+
+	var updated CommittedBatchInfo
+	ctx = ContextWithWriteOptions(context.Background(), WriteOptions{Sync: true, Committed: &updated})
+	err = t1.Update(ctx, []*TokenBalance{{ID: 1, AccountAddress: "0xtestAccount", Balance: 5}})
+	require.NoError(t, err)
+	assert.Greater(t, updated.Seq, inserted.Seq)
+
+	// the writes without the batch get the info of their commit too
+	var set CommittedBatchInfo
+	err = db.Set([]byte{0x01, 0xFF}, []byte{0x01}, WriteOptions{Sync: true, Committed: &set})
+	require.NoError(t, err)
+	assert.Greater(t, set.Seq, updated.Seq)
+	assert.Equal(t, uint32(1), set.Count)
+}
+
+func Test_TypedBatch(t *testing.T) {
+	const otherDBName = "test_db_typed_batch_other"
+
+	db, t1, _, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	otherDB, err := Open(otherDBName, &Options{})
+	require.NoError(t, err)
+	defer func() {
+		_ = otherDB.Close()
+		_ = os.RemoveAll(otherDBName)
+	}()
+
+	otherTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        otherDB,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	batch := NewTypedBatch(db)
+	defer func() { _ = batch.Close() }()
+
+	err = InsertInto(context.Background(), batch, t1, []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 7},
+	})
+	require.NoError(t, err)
+
+	err = UpdateIn(context.Background(), batch, t1, []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount2", Balance: 6},
+	})
+	require.NoError(t, err)
+
+	err = DeleteFrom(context.Background(), batch, t1, []*TokenBalance{
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 7},
+	})
+	require.NoError(t, err)
+
+	err = InsertInto(context.Background(), batch, otherTable, []*TokenBalance{{ID: 3}})
+	require.Error(t, err)
+
+	// the uncommitted rows are read with the underlying batch
+	tb, err := t1.Get(&TokenBalance{ID: 1}, batch.Batch())
+	require.NoError(t, err)
+	assert.Equal(t, "0xtestAccount2", tb.AccountAddress)
+
+	err = batch.Commit(Sync)
+	require.NoError(t, err)
+
+	var rows []*TokenBalance
+	err = t1.Scan(context.Background(), &rows)
+	require.NoError(t, err)
+	require.Len(t, rows, 1)
+	assert.Equal(t, uint64(6), rows[0].Balance)
+}
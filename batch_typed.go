@@ -0,0 +1,103 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+)
+
+// TypedBatch is the batch that is written only through the tables, so the
+// index entries, the write hooks and the caches of the tables are maintained
+// for every row it writes. The rows are written with InsertInto, UpdateIn,
+// UpsertInto and DeleteFrom.
+//
+// Example:
+//
+//	batch := bond.NewTypedBatch(db)
+//	defer batch.Close()
+//
+//	err := bond.InsertInto(ctx, batch, tokenBalanceTable, tokenBalances)
+//	...
+//	err = bond.DeleteFrom(ctx, batch, accountTable, accounts)
+//	...
+//	err = batch.Commit(bond.Sync)
+type TypedBatch struct {
+	db    DB
+	batch Batch
+}
+
+// NewTypedBatch creates the typed batch of the database.
+func NewTypedBatch(db DB) *TypedBatch {
+	return &TypedBatch{db: db, batch: db.Batch()}
+}
+
+// ID returns the ID of the underlying batch.
+func (b *TypedBatch) ID() uint64 {
+	return b.batch.ID()
+}
+
+// Empty returns true if nothing was written to the batch.
+func (b *TypedBatch) Empty() bool {
+	return b.batch.Empty()
+}
+
+// Commit commits the rows written to the batch.
+func (b *TypedBatch) Commit(opt WriteOptions) error {
+	return b.batch.Commit(opt)
+}
+
+// AfterCommit registers the listener called once the batch is committed.
+func (b *TypedBatch) AfterCommit(f func(info CommittedBatchInfo)) {
+	b.batch.AfterCommit(f)
+}
+
+// Close discards the batch.
+func (b *TypedBatch) Close() error {
+	return b.batch.Close()
+}
+
+// Batch returns the underlying batch to read the uncommitted rows with, e.g.
+// with Table.Get or Query.Execute. The rows must not be written with it.
+func (b *TypedBatch) Batch() Batch {
+	return b.batch
+}
+
+// InsertInto inserts the rows into the table with the batch.
+func InsertInto[T any](ctx context.Context, b *TypedBatch, table Table[T], rows []T) error {
+	if err := b.check(table); err != nil {
+		return err
+	}
+	return table.Insert(ctx, rows, b.batch)
+}
+
+// UpdateIn updates the rows of the table with the batch.
+func UpdateIn[T any](ctx context.Context, b *TypedBatch, table Table[T], rows []T) error {
+	if err := b.check(table); err != nil {
+		return err
+	}
+	return table.Update(ctx, rows, b.batch)
+}
+
+// UpsertInto upserts the rows into the table with the batch.
+func UpsertInto[T any](ctx context.Context, b *TypedBatch, table Table[T], rows []T, onConflict func(old, new T) T) error {
+	if err := b.check(table); err != nil {
+		return err
+	}
+	return table.Upsert(ctx, rows, onConflict, b.batch)
+}
+
+// DeleteFrom deletes the rows from the table with the batch.
+func DeleteFrom[T any](ctx context.Context, b *TypedBatch, table Table[T], rows []T) error {
+	if err := b.check(table); err != nil {
+		return err
+	}
+	return table.Delete(ctx, rows, b.batch)
+}
+
+// check returns the error if the table belongs to the other database, as its
+// rows would be written to the wrong store.
+func (b *TypedBatch) check(table TableInfo) error {
+	if t, ok := table.(interface{ database() DB }); ok && t.database() != b.db {
+		return fmt.Errorf("table %s does not belong to the database of the batch", table.Name())
+	}
+	return nil
+}
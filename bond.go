@@ -3,8 +3,12 @@ package bond
 import (
 	"fmt"
 	"io"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
 	"github.com/go-bond/bond/serializers"
 )
 
@@ -12,12 +16,57 @@ const (
 	// BOND_DB_DATA_TABLE_ID ..
 	BOND_DB_DATA_TABLE_ID = 0x0
 
+	// BOND_DB_DATA_CATALOG_INDEX_ID
+	BOND_DB_DATA_CATALOG_INDEX_ID = 0x1
+
+	// BOND_DB_DATA_INDEX_SKETCH_INDEX_ID
+	BOND_DB_DATA_INDEX_SKETCH_INDEX_ID = 0x2
+
+	// BOND_DB_DATA_INDEX_STATS_INDEX_ID
+	BOND_DB_DATA_INDEX_STATS_INDEX_ID = 0x3
+
+	// BOND_DB_DATA_INDEX_REFERENCE_INDEX_ID
+	BOND_DB_DATA_INDEX_REFERENCE_INDEX_ID = 0x4
+
+	// BOND_DB_DATA_COLUMN_GROUP_INDEX_ID
+	BOND_DB_DATA_COLUMN_GROUP_INDEX_ID = 0x5
+
+	// BOND_DB_DATA_DICTIONARY_INDEX_ID
+	BOND_DB_DATA_DICTIONARY_INDEX_ID = 0x6
+
+	// BOND_DB_DATA_INDEX_BUILD_INDEX_ID
+	BOND_DB_DATA_INDEX_BUILD_INDEX_ID = 0x7
+
+	// BOND_DB_DATA_ROW_VERSION_INDEX_ID
+	BOND_DB_DATA_ROW_VERSION_INDEX_ID = 0x8
+
+	// BOND_DB_DATA_ARCHIVE_STUB_INDEX_ID
+	BOND_DB_DATA_ARCHIVE_STUB_INDEX_ID = 0x9
+
+	// BOND_DB_DATA_AGGREGATE_INDEX_ID
+	BOND_DB_DATA_AGGREGATE_INDEX_ID = 0xA
+
+	// BOND_DB_DATA_COMMIT_INTENT_INDEX_ID
+	BOND_DB_DATA_COMMIT_INTENT_INDEX_ID = 0xB
+
+	// BOND_DB_DATA_OVERFLOW_INDEX_ID
+	BOND_DB_DATA_OVERFLOW_INDEX_ID = 0xC
+
 	// BOND_DB_DATA_USER_SPACE_INDEX_ID
 	BOND_DB_DATA_USER_SPACE_INDEX_ID = 0xFF
 )
 
 type WriteOptions struct {
 	Sync bool
+
+	// DryRun discards the write instead of applying it. The changes the write
+	// would make are added to the Report if it is set.
+	DryRun bool
+	Report *WriteReport
+
+	// Committed is set to the info of the commit once the write is committed,
+	// e.g. to read the sequence number of the write.
+	Committed *CommittedBatchInfo
 }
 
 var (
@@ -69,14 +118,76 @@ type DB interface {
 
 	Closer
 
+	Snapshotter
+	TableExtractor
+	Exporter
+	SchemaFingerprinter
+	SnapshotStreamer
+	BackgroundScheduler
+	HealthChecker
+	SlowQueryLogger
+	TableChangeNotifier
+	CommitWaiter
+	CommitPreparer
+
 	OnClose(func(db DB))
 }
 
 type _db struct {
+	// writeSeq is increased after each write, it needs to be first
+	// field for atomic operations to be aligned on 32-bit platforms
+	writeSeq uint64
+
+	// commitSeq is the sequence number of the last committed batch
+	commitSeq uint64
+
+	// store is the backend of the database, pebble is set if it's the
+	// pebble database opened in the directory
+	store  Backend
 	pebble *pebble.DB
 
+	// dirname and fs are the directory and the file system of the database
+	dirname string
+	fs      vfs.FS
+
+	iteratorPool *_iteratorPool
+
+	writeConcurrency int
+
+	catalog *_catalog
+
 	serializer Serializer[any]
 
+	snapshots _snapshots
+
+	health *_health
+
+	onIndexBuildProgress func(progress IndexBuildProgress)
+
+	slowQueryThreshold time.Duration
+	onSlowQuery        func(query SlowQuery)
+	slowQueryLog       *_table[*SlowQuery]
+
+	queryAdmission *_queryAdmission
+
+	writeInterceptor *_writeInterceptor
+	tableChanges     _tableChanges
+
+	commitIntents _commitIntents
+
+	faultInjector FaultInjector
+
+	clock Clock
+
+	// secondary is set if the database is the secondary of the primary
+	// opened in this process
+	secondary *_secondary
+
+	background *_background
+
+	systemTables      *_systemTables
+	systemTablesMutex sync.Mutex
+
 	onCloseCallbacks []func(db DB)
 }
 
@@ -91,19 +202,40 @@ func Open(dirname string, opts *Options) (DB, error) {
 
 	opts.PebbleOptions.Comparer = DefaultKeyComparer()
 
-	pdb, err := pebble.Open(dirname, opts.PebbleOptions)
-	if err != nil {
-		return nil, err
+	health := &_health{}
+
+	pebbleOptions := *opts.PebbleOptions
+	pebbleOptions.EventListener = pebble.TeeEventListener(
+		pebble.TeeEventListener(opts.PebbleOptions.EventListener, health.eventListener()),
+		eventListener(opts),
+	)
+	pebbleOptions.Merger = newMerger(opts.PebbleOptions.Merger)
+
+	var (
+		pdb     *pebble.DB
+		backend = opts.Backend
+		err     error
+	)
+	if backend == nil {
+		pdb, err = pebble.Open(dirname, &pebbleOptions)
+		if err != nil {
+			return nil, err
+		}
+		backend = NewPebbleBackend(pdb)
 	}
 
-	var serializer Serializer[any]
-	if opts.Serializer != nil {
-		serializer = opts.Serializer
-	} else {
-		serializer = &serializers.JsonSerializer{}
+	db := newDB(backend, pdb, dirname, opts, &pebbleOptions, health)
+	if pdb != nil {
+		if err := removeSecondaryCheckpoints(db); err != nil {
+			_ = backend.Close()
+			return nil, err
+		}
+	}
+	if opts.IteratorPoolSize > 0 && pdb != nil {
+		db.iteratorPool = newIteratorPool(pdb, &db.writeSeq, opts.IteratorPoolSize)
 	}
 
-	db := &_db{pebble: pdb, serializer: serializer}
+	db.catalog = newCatalog(db, opts.CatalogDriftFunc, opts.OnCatalogRename)
 
 	if db.Version() == 0 {
 		if err := db.initVersion(); err != nil {
@@ -113,9 +245,70 @@ func Open(dirname string, opts *Options) (DB, error) {
 		return nil, fmt.Errorf("bond db version is %d but expecting %d", db.Version(), BOND_DB_DATA_VERSION)
 	}
 
+	if err := db.catalog.load(); err != nil {
+		_ = backend.Close()
+		return nil, err
+	}
+
+	if err := db.loadCommitIntents(); err != nil {
+		_ = backend.Close()
+		return nil, err
+	}
+
+	if opts.SlowQueryLogTableID != BOND_DB_DATA_TABLE_ID {
+		db.slowQueryLog, err = newSlowQueryLog(db, opts.SlowQueryLogTableID)
+		if err != nil {
+			_ = backend.Close()
+			return nil, err
+		}
+	}
+
+	db.background = newBackground(opts)
+
+	registerOpenDB(db)
 	return db, nil
 }
 
+// newDB returns the database of the backend.
+func newDB(backend Backend, pdb *pebble.DB, dirname string, opts *Options, pebbleOptions *pebble.Options, health *_health) *_db {
+	var serializer Serializer[any]
+	if opts.Serializer != nil {
+		serializer = opts.Serializer
+	} else {
+		serializer = &serializers.JsonSerializer{}
+	}
+
+	db := &_db{
+		store:            backend,
+		pebble:           pdb,
+		dirname:          dirname,
+		fs:               vfs.Default,
+		writeConcurrency: opts.WriteConcurrency,
+		serializer:       serializer,
+		snapshots:        _snapshots{retention: opts.SnapshotRetention},
+		health:           health,
+
+		onIndexBuildProgress: opts.OnIndexBuildProgress,
+
+		slowQueryThreshold: opts.SlowQueryThreshold,
+		onSlowQuery:        opts.OnSlowQuery,
+
+		queryAdmission: newQueryAdmission(opts),
+
+		writeInterceptor: newWriteInterceptor(opts),
+
+		faultInjector: opts.FaultInjector,
+		clock:         opts.Clock,
+	}
+	if db.clock == nil {
+		db.clock = SystemClock
+	}
+	if pebbleOptions.FS != nil {
+		db.fs = pebbleOptions.FS
+	}
+	return db
+}
+
 func (db *_db) Serializer() Serializer[any] {
 	return db.serializer
 }
@@ -124,40 +317,84 @@ func (db *_db) Get(key []byte, batch ...Batch) (data []byte, closer io.Closer, e
 	if batch != nil && len(batch) > 0 && batch[0] != nil {
 		data, closer, err = batch[0].Get(key)
 	} else {
-		data, closer, err = db.pebble.Get(key)
+		data, closer, err = db.backend().Get(key)
 	}
-	return
+	if err == nil {
+		db.health.read()
+	}
+	return data, closer, notFound(err)
 }
 
 func (db *_db) Set(key []byte, value []byte, opt WriteOptions, batch ...Batch) error {
 	if batch != nil && len(batch) > 0 && batch[0] != nil {
 		return batch[0].Set(key, value, opt)
+	} else if opt.DryRun {
+		opt.Report.add(WriteChangeSet, key, value)
+		return nil
+	} else if opt.Committed != nil || db.observesMutations() {
+		return db.commitWrite(opt, func(batch Batch) error {
+			return batch.Set(key, value, opt)
+		})
 	} else {
-		return db.pebble.Set(key, value, pebbleWriteOptions(opt))
+		defer db.notifyWrite()
+		return db.backend().Set(key, value, opt)
 	}
 }
 
 func (db *_db) Delete(key []byte, opts WriteOptions, batch ...Batch) error {
 	if batch != nil && len(batch) > 0 && batch[0] != nil {
 		return batch[0].Delete(key, opts)
+	} else if opts.DryRun {
+		opts.Report.add(WriteChangeDelete, key, nil)
+		return nil
+	} else if opts.Committed != nil || db.observesMutations() {
+		return db.commitWrite(opts, func(batch Batch) error {
+			return batch.Delete(key, opts)
+		})
 	} else {
-		return db.pebble.Delete(key, pebbleWriteOptions(opts))
+		defer db.notifyWrite()
+		return db.backend().Delete(key, opts)
 	}
 }
 
 func (db *_db) DeleteRange(start []byte, end []byte, opt WriteOptions, batch ...Batch) error {
 	if batch != nil && len(batch) > 0 && batch[0] != nil {
 		return batch[0].DeleteRange(start, end, opt)
+	} else if opt.DryRun {
+		opt.Report.add(WriteChangeDeleteRange, start, end)
+		return nil
+	} else if opt.Committed != nil || db.observesMutations() {
+		return db.commitWrite(opt, func(batch Batch) error {
+			return batch.DeleteRange(start, end, opt)
+		})
 	} else {
-		return db.pebble.DeleteRange(start, end, pebbleWriteOptions(opt))
+		defer db.notifyWrite()
+		return db.backend().DeleteRange(start, end, opt)
+	}
+}
+
+// commitWrite commits the single write with its own batch, so the write gets
+// the info of its commit and its mutations are observed.
+func (db *_db) commitWrite(opt WriteOptions, write func(batch Batch) error) error {
+	batch := db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	err := write(batch)
+	if err != nil {
+		return err
 	}
+	return batch.Commit(opt)
 }
 
 func (db *_db) Iter(opt *IterOptions, batch ...Batch) Iterator {
 	if batch != nil && len(batch) > 0 && batch[0] != nil {
 		return batch[0].Iter(opt)
+	} else if db.iteratorPool != nil {
+		return db.iteratorPool.Get(opt)
 	} else {
-		return db.pebble.NewIter(pebbleIterOptions(opt))
+		return db.backend().Iter(opt)
 	}
 }
 
@@ -170,14 +407,30 @@ func (db *_db) Apply(b Batch, opt WriteOptions) error {
 }
 
 func (db *_db) Close() error {
+	atomic.StoreInt32(&db.health.closed, 1)
+	db.background.close()
 	db.notifyOnClose()
-	return db.pebble.Close()
+	if db.iteratorPool != nil {
+		db.iteratorPool.Close()
+	}
+	db.closeSnapshots()
+	db.writeInterceptor.close()
+	if db.secondary != nil {
+		return db.secondary.close()
+	}
+	unregisterOpenDB(db)
+	return db.store.Close()
 }
 
 func (db *_db) OnClose(f func(db DB)) {
 	db.onCloseCallbacks = append(db.onCloseCallbacks, f)
 }
 
+func (db *_db) notifyWrite() {
+	atomic.AddUint64(&db.writeSeq, 1)
+	db.health.written()
+}
+
 func (db *_db) notifyOnClose() {
 	for _, onClose := range db.onCloseCallbacks {
 		onClose(db)
@@ -185,7 +438,7 @@ func (db *_db) notifyOnClose() {
 }
 
 func pebbleWriteOptions(opt WriteOptions) *pebble.WriteOptions {
-	if opt == NoSync {
+	if !opt.Sync {
 		return pebble.NoSync
 	}
 	return pebble.Sync
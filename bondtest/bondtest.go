@@ -0,0 +1,250 @@
+// Package bondtest helps to test the tables built with bond. Generate creates
+// the rows of the property tests and CheckTable round-trips them through the
+// table, checking that every index agrees with the rows, which catches the
+// index key functions that are not deterministic or miss the fields early.
+package bondtest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/go-bond/bond"
+)
+
+// DefaultSeed is the seed of the random source used by Generate, so the
+// generated rows are the same in every run.
+const DefaultSeed = 1
+
+// Generate returns n rows created by the genFunc with the random source seeded
+// with the DefaultSeed.
+//
+// Example:
+//
+//	rows := bondtest.Generate(1000, func(rnd *rand.Rand, i int) *TokenBalance {
+//		return &TokenBalance{
+//			ID:             uint64(i + 1),
+//			AccountAddress: fmt.Sprintf("0x%x", rnd.Intn(50)),
+//			Balance:        rnd.Uint64(),
+//		}
+//	})
+func Generate[T any](n int, genFunc func(rnd *rand.Rand, i int) T) []T {
+	return GenerateSeed(DefaultSeed, n, genFunc)
+}
+
+// GenerateSeed returns n rows created by the genFunc with the random source
+// seeded with the seed.
+func GenerateSeed[T any](seed int64, n int, genFunc func(rnd *rand.Rand, i int) T) []T {
+	rnd := rand.New(rand.NewSource(seed))
+
+	rows := make([]T, 0, n)
+	for i := 0; i < n; i++ {
+		rows = append(rows, genFunc(rnd, i))
+	}
+	return rows
+}
+
+// CheckOptions are the options of CheckTable.
+type CheckOptions[T any] struct {
+	// Update changes the row before it's updated, it must not change the
+	// primary key. The rows are not updated if it's not set.
+	Update func(rnd *rand.Rand, tr T) T
+
+	// Seed is the seed of the random source passed to Update, the
+	// DefaultSeed if not set.
+	Seed int64
+}
+
+// CheckTable inserts the rows to the empty table, updates them if the Update
+// is set and deletes them, checking after every step that:
+//
+//   - every row is read by Get as it was written,
+//   - every index has one entry per row, or per row that passes its filter,
+//   - every row is returned by the query of every index with the row as the
+//     selector,
+//   - no row and no index entry is left after the delete.
+//
+// It returns the error that describes the first broken invariant.
+//
+// Example:
+//
+//	func TestTokenBalanceTable(t *testing.T) {
+//		err := bondtest.CheckTable(ctx, tokenBalanceTable, rows, bondtest.CheckOptions[*TokenBalance]{
+//			Update: func(rnd *rand.Rand, tb *TokenBalance) *TokenBalance {
+//				tb.AccountAddress = fmt.Sprintf("0x%x", rnd.Intn(50))
+//				return tb
+//			},
+//		})
+//		require.NoError(t, err)
+//	}
+func CheckTable[T any](ctx context.Context, table bond.Table[T], rows []T, opts ...CheckOptions[T]) error {
+	var opt CheckOptions[T]
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+	if opt.Seed == 0 {
+		opt.Seed = DefaultSeed
+	}
+
+	indexes := append([]*bond.Index[T]{table.PrimaryIndex()}, table.SecondaryIndexes()...)
+
+	for _, idx := range indexes {
+		entries, err := indexEntries(table, idx)
+		if err != nil {
+			return err
+		}
+		if entries != 0 {
+			return fmt.Errorf("table %s is not empty: index %s has %d entries", table.Name(), idx.IndexName, entries)
+		}
+	}
+
+	err := table.Insert(ctx, rows)
+	if err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+
+	err = checkRows(ctx, table, indexes, rows)
+	if err != nil {
+		return fmt.Errorf("after insert: %w", err)
+	}
+
+	if opt.Update != nil {
+		rnd := rand.New(rand.NewSource(opt.Seed))
+		for i, row := range rows {
+			rows[i] = opt.Update(rnd, row)
+		}
+
+		err = table.Update(ctx, rows)
+		if err != nil {
+			return fmt.Errorf("update: %w", err)
+		}
+
+		err = checkRows(ctx, table, indexes, rows)
+		if err != nil {
+			return fmt.Errorf("after update: %w", err)
+		}
+	}
+
+	err = table.Delete(ctx, rows)
+	if err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	for _, idx := range indexes {
+		entries, err := indexEntries(table, idx)
+		if err != nil {
+			return err
+		}
+		if entries != 0 {
+			return fmt.Errorf("after delete: index %s has %d entries left", idx.IndexName, entries)
+		}
+	}
+
+	for i, row := range rows {
+		_, err = table.Get(row)
+		if !errors.Is(err, bond.ErrNotFound) {
+			return fmt.Errorf("after delete: row %d is still read: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// checkRows checks that the rows and the index entries of the table match the
+// rows.
+func checkRows[T any](ctx context.Context, table bond.Table[T], indexes []*bond.Index[T], rows []T) error {
+	serializer := table.Serializer()
+
+	for i, row := range rows {
+		data, err := serializer.Serialize(&row)
+		if err != nil {
+			return fmt.Errorf("row %d: failed to serialize: %w", i, err)
+		}
+
+		read, err := table.Get(row)
+		if err != nil {
+			return fmt.Errorf("row %d: get: %w", i, err)
+		}
+
+		readData, err := serializer.Serialize(&read)
+		if err != nil {
+			return fmt.Errorf("row %d: failed to serialize: %w", i, err)
+		}
+
+		if !bytes.Equal(data, readData) {
+			return fmt.Errorf("row %d: read row differs from the written one", i)
+		}
+	}
+
+	for _, idx := range indexes {
+		expected := 0
+		for i, row := range rows {
+			if idx.IndexFilterFunction != nil && !idx.IndexFilterFunction(row) {
+				continue
+			}
+			expected++
+
+			found, err := queryFinds(ctx, table, idx, row)
+			if err != nil {
+				return fmt.Errorf("index %s: row %d: %w", idx.IndexName, i, err)
+			}
+			if !found {
+				return fmt.Errorf("index %s: row %d is not returned by the query with the row as the selector", idx.IndexName, i)
+			}
+		}
+
+		entries, err := indexEntries(table, idx)
+		if err != nil {
+			return err
+		}
+		if entries != expected {
+			return fmt.Errorf("index %s: has %d entries, expected %d", idx.IndexName, entries, expected)
+		}
+	}
+	return nil
+}
+
+// queryFinds returns true if the query of the index with the row as the
+// selector returns the row.
+func queryFinds[T any](ctx context.Context, table bond.Table[T], idx *bond.Index[T], row T) (bool, error) {
+	serializer := table.Serializer()
+
+	data, err := serializer.Serialize(&row)
+	if err != nil {
+		return false, err
+	}
+
+	var found []T
+	err = table.Query().With(idx, row).Execute(ctx, &found)
+	if err != nil {
+		return false, err
+	}
+
+	for _, tr := range found {
+		foundData, err := serializer.Serialize(&tr)
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(data, foundData) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// indexEntries counts the entries of the index.
+func indexEntries[T any](table bond.Table[T], idx *bond.Index[T]) (int, error) {
+	iter := table.Iter(nil)
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	prefix := []byte{byte(table.ID()), byte(idx.IndexID)}
+
+	entries := 0
+	for iter.SeekGE(prefix); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); iter.Next() {
+		entries++
+	}
+	return entries, iter.Error()
+}
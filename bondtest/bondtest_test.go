@@ -0,0 +1,118 @@
+package bondtest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/go-bond/bond"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type TokenBalance struct {
+	ID              uint64 `json:"id"`
+	ContractAddress string `json:"contractAddress"`
+	AccountAddress  string `json:"accountAddress"`
+	Balance         uint64 `json:"balance"`
+}
+
+const dbName = "test_db"
+
+func setupTable(t *testing.T, accountKeyFunc bond.IndexKeyFunction[*TokenBalance]) (bond.DB, bond.Table[*TokenBalance]) {
+	db, err := bond.Open(dbName, &bond.Options{})
+	require.NoError(t, err)
+
+	table := bond.NewTable[*TokenBalance](bond.TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   bond.TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder bond.KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	err = table.AddIndex([]*bond.Index[*TokenBalance]{
+		bond.NewIndex[*TokenBalance](bond.IndexOptions[*TokenBalance]{
+			IndexID:        bond.PrimaryIndexID + 1,
+			IndexName:      "account_address_idx",
+			IndexKeyFunc:   accountKeyFunc,
+			IndexOrderFunc: bond.IndexOrderDefault[*TokenBalance],
+		}),
+		bond.NewIndex[*TokenBalance](bond.IndexOptions[*TokenBalance]{
+			IndexID:   bond.PrimaryIndexID + 2,
+			IndexName: "rich_idx",
+			IndexKeyFunc: func(builder bond.KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.ContractAddress).Bytes()
+			},
+			IndexFilterFunc: func(tb *TokenBalance) bool {
+				return tb.Balance > 500
+			},
+			IndexOrderFunc: bond.IndexOrderDefault[*TokenBalance],
+		}),
+	})
+	require.NoError(t, err)
+
+	return db, table
+}
+
+func generateTokenBalances(n int) []*TokenBalance {
+	return Generate(n, func(rnd *rand.Rand, i int) *TokenBalance {
+		return &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: fmt.Sprintf("0xc%d", rnd.Intn(5)),
+			AccountAddress:  fmt.Sprintf("0xa%d", rnd.Intn(20)),
+			Balance:         uint64(rnd.Intn(1000)),
+		}
+	})
+}
+
+func TestGenerate(t *testing.T) {
+	assert.Equal(t, generateTokenBalances(10), generateTokenBalances(10))
+	assert.NotEqual(t,
+		GenerateSeed(1, 10, func(rnd *rand.Rand, _ int) int { return rnd.Int() }),
+		GenerateSeed(2, 10, func(rnd *rand.Rand, _ int) int { return rnd.Int() }))
+}
+
+func TestCheckTable(t *testing.T) {
+	db, table := setupTable(t, func(builder bond.KeyBuilder, tb *TokenBalance) []byte {
+		return builder.AddStringField(tb.AccountAddress).Bytes()
+	})
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	err := CheckTable(context.Background(), table, generateTokenBalances(200), CheckOptions[*TokenBalance]{
+		Update: func(rnd *rand.Rand, tb *TokenBalance) *TokenBalance {
+			tb.AccountAddress = fmt.Sprintf("0xa%d", rnd.Intn(20))
+			tb.Balance = uint64(rnd.Intn(1000))
+			return tb
+		},
+	})
+	require.NoError(t, err)
+}
+
+func TestCheckTable_BrokenIndexKey(t *testing.T) {
+	calls := 0
+	db, table := setupTable(t, func(builder bond.KeyBuilder, tb *TokenBalance) []byte {
+		// the key is not deterministic, the update can not find the old entry
+		calls++
+		return builder.AddStringField(tb.AccountAddress).AddUint64Field(uint64(calls)).Bytes()
+	})
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	err := CheckTable(context.Background(), table, generateTokenBalances(20), CheckOptions[*TokenBalance]{
+		Update: func(_ *rand.Rand, tb *TokenBalance) *TokenBalance {
+			tb.Balance++
+			return tb
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "account_address_idx")
+}
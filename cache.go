@@ -0,0 +1,153 @@
+package bond
+
+import (
+	"container/list"
+	"sync"
+)
+
+// _rowCache is the LRU cache of deserialized rows keyed by the row key.
+//
+// The generation is increased on every invalidation. The readers take the
+// generation before reading the row from the database and the row is cached
+// only if the generation did not change in the meantime, so the row that was
+// read before concurrent write committed does not end up in the cache.
+type _rowCache[T any] struct {
+	size int
+
+	generation uint64
+	entries    map[string]*list.Element
+	lru        *list.List
+
+	mutex sync.Mutex
+}
+
+type _rowCacheEntry[T any] struct {
+	key string
+	row T
+}
+
+func newRowCache[T any](size int) *_rowCache[T] {
+	return &_rowCache[T]{
+		size:    size,
+		entries: make(map[string]*list.Element, size),
+		lru:     list.New(),
+	}
+}
+
+func (c *_rowCache[T]) Generation() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.generation
+}
+
+func (c *_rowCache[T]) Get(key []byte) (T, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.entries[string(key)]; ok {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*_rowCacheEntry[T]).row, true
+	}
+
+	var zero T
+	return zero, false
+}
+
+func (c *_rowCache[T]) Put(key []byte, row T, generation uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if elem, ok := c.entries[string(key)]; ok {
+		elem.Value.(*_rowCacheEntry[T]).row = row
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.entries[string(key)] = c.lru.PushFront(&_rowCacheEntry[T]{key: string(key), row: row})
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*_rowCacheEntry[T]).key)
+	}
+}
+
+func (c *_rowCache[T]) Invalidate(keys [][]byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	for _, key := range keys {
+		if elem, ok := c.entries[string(key)]; ok {
+			c.lru.Remove(elem)
+			delete(c.entries, string(key))
+		}
+	}
+}
+
+func (c *_rowCache[T]) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	c.entries = make(map[string]*list.Element, c.size)
+	c.lru.Init()
+}
+
+func (c *_rowCache[T]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
+
+// _cacheInvalidation holds the row keys and index prefixes touched by a write.
+type _cacheInvalidation struct {
+	keys     [][]byte
+	prefixes [][]byte
+}
+
+// collectInvalidation records the row key and, for the query cache, the index
+// prefixes of given versions of the row.
+func (t *_table[T]) collectInvalidation(inv *_cacheInvalidation, key []byte, indexes map[IndexID]*Index[T], trs ...T) {
+	if t.cache != nil {
+		inv.keys = append(inv.keys, append([]byte{}, key...))
+	}
+
+	if t.queryCache != nil {
+		for _, tr := range trs {
+			inv.prefixes = append(inv.prefixes, t.keyPrefix(t.primaryIndex, tr, make([]byte, 0, DataKeyBufferSize)))
+			for _, idx := range indexes {
+				inv.prefixes = append(inv.prefixes, t.keyPrefix(idx, tr, make([]byte, 0, DataKeyBufferSize)))
+			}
+		}
+	}
+}
+
+// invalidateCache removes the written rows and the query results that depend
+// on them from the caches. The writes to the external batch are invalidated
+// again once the batch is committed, so the results read by the concurrent
+// readers in the meantime are not kept.
+func (t *_table[T]) invalidateCache(inv _cacheInvalidation, batch Batch, externalBatch bool) {
+	invalidate := func() {
+		if t.cache != nil && len(inv.keys) > 0 {
+			t.cache.Invalidate(inv.keys)
+		}
+		if t.queryCache != nil && len(inv.prefixes) > 0 {
+			t.queryCache.Invalidate(inv.prefixes)
+		}
+	}
+
+	if len(inv.keys) == 0 && len(inv.prefixes) == 0 {
+		return
+	}
+
+	invalidate()
+	if externalBatch {
+		batch.AfterCommit(func(CommittedBatchInfo) {
+			invalidate()
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_Cache(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		CacheSize: 2,
+	})
+
+	cache := tokenBalanceTable.(*_table[*TokenBalance]).cache
+	require.NotNil(t, cache)
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 7},
+		{ID: 3, AccountAddress: "0xtestAccount", Balance: 9},
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	tb, err := tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[0], tb)
+	assert.Equal(t, 1, cache.Len())
+
+	// served from the cache
+	tbCached, err := tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Same(t, tb, tbCached)
+
+	// the least recently used row is evicted
+	trs, err := tokenBalanceTable.GetByKeys(context.Background(), []PrimaryKey{
+		NewKeyBuilder([]byte{}).AddUint64Field(2).Bytes(),
+		NewKeyBuilder([]byte{}).AddUint64Field(3).Bytes(),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[1:], trs)
+	assert.Equal(t, 2, cache.Len())
+
+	_, ok := cache.Get(tokenBalanceTable.(*_table[*TokenBalance]).key(tokenBalances[0], make([]byte, 0, DataKeyBufferSize)))
+	assert.False(t, ok)
+
+	// the write invalidates the cached row
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 70},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, cache.Len())
+
+	tb, err = tokenBalanceTable.Get(&TokenBalance{ID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(70), tb.Balance)
+
+	// the write to the batch invalidates the cached row once committed
+	batch := db.Batch()
+	defer func() { _ = batch.Close() }()
+
+	err = tokenBalanceTable.Delete(context.Background(), []*TokenBalance{{ID: 2}}, batch)
+	require.NoError(t, err)
+
+	tb, err = tokenBalanceTable.Get(&TokenBalance{ID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(70), tb.Balance)
+	assert.Equal(t, 2, cache.Len())
+
+	err = batch.Commit(Sync)
+	require.NoError(t, err)
+	assert.Equal(t, 1, cache.Len())
+
+	_, err = tokenBalanceTable.Get(&TokenBalance{ID: 2})
+	require.Error(t, err)
+}
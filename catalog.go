@@ -0,0 +1,676 @@
+package bond
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/go-bond/bond/utils"
+)
+
+// CatalogDriftKind is the kind of difference between the persisted catalog
+// and the tables and indexes registered by the running binary.
+type CatalogDriftKind int
+
+const (
+	// CatalogDriftTableName the table ID was persisted with a different table name.
+	CatalogDriftTableName CatalogDriftKind = iota
+	// CatalogDriftTableID the table name was persisted with a different table ID.
+	CatalogDriftTableID
+	// CatalogDriftTableKey the primary key function produces different fields.
+	CatalogDriftTableKey
+	// CatalogDriftTableRemoved the persisted table is not registered.
+	CatalogDriftTableRemoved
+	// CatalogDriftIndexName the index ID was persisted with a different index name.
+	CatalogDriftIndexName
+	// CatalogDriftIndexID the index name was persisted with a different index ID.
+	CatalogDriftIndexID
+	// CatalogDriftIndexKey the index key or order function produces different fields.
+	CatalogDriftIndexKey
+	// CatalogDriftIndexRemoved the persisted index is not registered.
+	CatalogDriftIndexRemoved
+)
+
+func (k CatalogDriftKind) String() string {
+	switch k {
+	case CatalogDriftTableName:
+		return "table name changed"
+	case CatalogDriftTableID:
+		return "table id changed"
+	case CatalogDriftTableKey:
+		return "table key changed"
+	case CatalogDriftTableRemoved:
+		return "table removed"
+	case CatalogDriftIndexName:
+		return "index name changed"
+	case CatalogDriftIndexID:
+		return "index id changed"
+	case CatalogDriftIndexKey:
+		return "index key changed"
+	case CatalogDriftIndexRemoved:
+		return "index removed"
+	default:
+		return "unknown"
+	}
+}
+
+// CatalogDrift describes the difference between the persisted catalog and
+// the registered table or index.
+type CatalogDrift struct {
+	Kind CatalogDriftKind
+
+	TableID   TableID
+	TableName string
+	IndexID   IndexID
+	IndexName string
+
+	Persisted  string
+	Registered string
+}
+
+func (d CatalogDrift) Error() string {
+	subject := fmt.Sprintf("table %q (0x%02x)", d.TableName, d.TableID)
+	if d.Kind >= CatalogDriftIndexName {
+		subject = fmt.Sprintf("index %q (0x%02x) of %s", d.IndexName, d.IndexID, subject)
+	}
+	return fmt.Sprintf("catalog drift: %s: %s: persisted %q, registered %q",
+		subject, d.Kind, d.Persisted, d.Registered)
+}
+
+// CatalogDriftFunc is called for every difference found between the persisted
+// catalog and the registered tables and indexes. Returning nil accepts the
+// change and updates the persisted catalog, returning an error fails the
+// registration.
+type CatalogDriftFunc func(drift CatalogDrift) error
+
+type _catalogTable struct {
+	ID          TableID         `json:"id"`
+	Name        string          `json:"name"`
+	Fingerprint string          `json:"fingerprint"`
+	Indexes     []_catalogIndex `json:"indexes"`
+
+	// StorageID is the ID the keys of the table are stored under, if the
+	// table was replaced with ReplaceFrom, see _tableStorage.
+	StorageID TableID `json:"storage_id,omitempty"`
+}
+
+// storage returns the ID the keys of the table are stored under.
+func (t *_catalogTable) storage() TableID {
+	if t.StorageID != 0 {
+		return t.StorageID
+	}
+	return t.ID
+}
+
+type _catalogIndex struct {
+	ID          IndexID `json:"id"`
+	Name        string  `json:"name"`
+	Fingerprint string  `json:"fingerprint"`
+}
+
+// _catalog keeps track of the tables registered on the database, so the
+// tables do not silently share the key space. The tables and indexes are
+// persisted, so the changes made between the runs are detected.
+type _catalog struct {
+	db        *_db
+	driftFunc CatalogDriftFunc
+	onRename  func(drift CatalogDrift)
+
+	tables    map[TableID]string
+	indexes   map[TableID]map[IndexID]string
+	persisted map[TableID]*_catalogTable
+
+	// serializers are the serializers of the registered tables, they are
+	// not persisted
+	serializers map[TableID]string
+
+	storages struct {
+		// tables are the storage IDs shared by the tables registered with
+		// the same ID
+		tables map[TableID]*_tableStorage
+
+		// mapping is the _catalogStorageMap of the persisted catalog
+		mapping atomic.Value
+	}
+
+	mutex sync.Mutex
+}
+
+func newCatalog(db *_db, driftFunc CatalogDriftFunc, onRename func(drift CatalogDrift)) *_catalog {
+	c := &_catalog{
+		db:        db,
+		driftFunc: driftFunc,
+		onRename:  onRename,
+		tables:    make(map[TableID]string),
+		indexes:   make(map[TableID]map[IndexID]string),
+		persisted: make(map[TableID]*_catalogTable),
+
+		serializers: make(map[TableID]string),
+	}
+	c.storages.tables = make(map[TableID]*_tableStorage)
+	return c
+}
+
+// drift reports the drift to the CatalogDriftFunc. If it's not set, the tables
+// and the indexes renamed without changing their keys are accepted and passed
+// to OnCatalogRename, the other drifts fail the registration.
+func (c *_catalog) drift(drift CatalogDrift, keyChanged bool) error {
+	if c.driftFunc != nil {
+		return c.driftFunc(drift)
+	}
+
+	switch drift.Kind {
+	case CatalogDriftTableName, CatalogDriftIndexName:
+		if !keyChanged {
+			if c.onRename != nil {
+				c.onRename(drift)
+			}
+			return nil
+		}
+	}
+	return drift
+}
+
+// load reads the persisted catalog.
+func (c *_catalog) load() error {
+	iter := c.db.backend().Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_CATALOG_INDEX_ID},
+			UpperBound: []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_CATALOG_INDEX_ID + 1},
+		},
+	})
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		var table _catalogTable
+		if err := json.Unmarshal(iter.Value(), &table); err != nil {
+			_ = iter.Close()
+			return fmt.Errorf("failed to load catalog entry %s: %w", FormatKey(iter.Key()), err)
+		}
+		c.persisted[table.ID] = &table
+	}
+
+	c.updateStorageMapLocked()
+	return iter.Close()
+}
+
+// refreshStorages reads the storage IDs of the tables from the persisted
+// catalog, e.g. on the secondary that caught up with the primary, which may
+// have replaced the tables since.
+func (c *_catalog) refreshStorages() error {
+	iter := c.db.backend().Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_CATALOG_INDEX_ID},
+			UpperBound: []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_CATALOG_INDEX_ID + 1},
+		},
+	})
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		var table _catalogTable
+		if err := json.Unmarshal(iter.Value(), &table); err != nil {
+			_ = iter.Close()
+			return fmt.Errorf("failed to load catalog entry %s: %w", FormatKey(iter.Key()), err)
+		}
+
+		if persisted, ok := c.persisted[table.ID]; ok {
+			persisted.StorageID = table.StorageID
+		}
+		if storage, ok := c.storages.tables[table.ID]; ok {
+			storage.set(table.storage())
+		}
+	}
+
+	c.updateStorageMapLocked()
+	return iter.Close()
+}
+
+// storage returns the storage ID of the table, shared by all the tables
+// registered with the ID.
+func (c *_catalog) storage(id TableID) *_tableStorage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if storage, ok := c.storages.tables[id]; ok {
+		return storage
+	}
+
+	storage := newTableStorage(id)
+	if persisted, ok := c.persisted[id]; ok {
+		storage.set(persisted.storage())
+	}
+	c.storages.tables[id] = storage
+	return storage
+}
+
+// storageID returns the ID the keys of the table are stored under.
+func (c *_catalog) storageID(id TableID) TableID {
+	if storageID, ok := c.storageMap().storages[id]; ok {
+		return storageID
+	}
+	return id
+}
+
+// tableOfStorage returns the ID of the table whose keys are stored under the
+// storage ID.
+func (c *_catalog) tableOfStorage(storageID TableID) TableID {
+	if id, ok := c.storageMap().tables[storageID]; ok {
+		return id
+	}
+	return storageID
+}
+
+// replaced returns true if any table is stored under the ID of the other one.
+func (c *_catalog) replaced() bool {
+	return len(c.storageMap().storages) > 0
+}
+
+// _catalogStorageMap maps the tables stored under the IDs of the other tables
+// to their storage IDs and back. It's read without the catalog mutex, so the
+// writes made while the mutex is held can look it up.
+type _catalogStorageMap struct {
+	storages map[TableID]TableID
+	tables   map[TableID]TableID
+}
+
+func (c *_catalog) storageMap() *_catalogStorageMap {
+	storageMap, _ := c.storages.mapping.Load().(*_catalogStorageMap)
+	if storageMap == nil {
+		return &_catalogStorageMap{}
+	}
+	return storageMap
+}
+
+// updateStorageMapLocked updates the storage map from the persisted catalog.
+func (c *_catalog) updateStorageMapLocked() {
+	storageMap := &_catalogStorageMap{
+		storages: make(map[TableID]TableID),
+		tables:   make(map[TableID]TableID),
+	}
+	for id, persisted := range c.persisted {
+		if storageID := persisted.storage(); storageID != id {
+			storageMap.storages[id] = storageID
+			storageMap.tables[storageID] = id
+		}
+	}
+	c.storages.mapping.Store(storageMap)
+}
+
+// swapStorages writes the catalog entries of the tables with their storage
+// IDs swapped to the batch. The returned function swaps the storage IDs of the
+// registered tables once the batch is committed.
+func (c *_catalog) swapStorages(batch Batch, a TableID, b TableID) (func(), error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entryA, okA := c.persisted[a]
+	entryB, okB := c.persisted[b]
+	if !okA || !okB {
+		return nil, fmt.Errorf("tables 0x%02x and 0x%02x are not registered in catalog", a, b)
+	}
+
+	swappedA, swappedB := *entryA, *entryB
+	swappedA.StorageID, swappedB.StorageID = entryB.storage(), entryA.storage()
+	for _, entry := range []*_catalogTable{&swappedA, &swappedB} {
+		if entry.StorageID == entry.ID {
+			entry.StorageID = 0
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		err = batch.Set(catalogKey(entry.ID), data, Sync)
+		if err != nil {
+			return nil, fmt.Errorf("failed to persist catalog entry of table %q: %w", entry.Name, err)
+		}
+	}
+
+	return func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		c.persisted[a], c.persisted[b] = &swappedA, &swappedB
+		if storage, ok := c.storages.tables[a]; ok {
+			storage.set(swappedA.storage())
+		}
+		if storage, ok := c.storages.tables[b]; ok {
+			storage.set(swappedB.storage())
+		}
+		c.updateStorageMapLocked()
+	}, nil
+}
+
+// registerTable registers the table. The table with the same ID and name can
+// be registered multiple times, as it describes the same rows.
+func (c *_catalog) registerTable(id TableID, name string, fingerprint string, serializer string) error {
+	if id == BOND_DB_DATA_TABLE_ID {
+		return fmt.Errorf("table id 0x%02x of %q is reserved for bond: %w", id, name, ErrTableIDCollision)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if registeredName, ok := c.tables[id]; ok && registeredName != name {
+		return fmt.Errorf("table id 0x%02x of %q is already used by table %q: %w",
+			id, name, registeredName, ErrTableIDCollision)
+	}
+
+	entry := &_catalogTable{ID: id, Name: name, Fingerprint: fingerprint}
+	drift := CatalogDrift{TableID: id, TableName: name}
+
+	if persisted, ok := c.persisted[id]; ok {
+		// the replaced table keeps reading the keys it was replaced with
+		entry.StorageID = persisted.StorageID
+
+		if persisted.Name != name {
+			keyChanged := fingerprintsDiffer(persisted.Fingerprint, fingerprint)
+
+			drift.Kind, drift.Persisted, drift.Registered = CatalogDriftTableName, persisted.Name, name
+			if err := c.drift(drift, keyChanged); err != nil {
+				return err
+			}
+
+			// the renamed table keeps its indexes
+			if !keyChanged {
+				entry.Indexes = persisted.Indexes
+			}
+		} else {
+			entry.Indexes = persisted.Indexes
+
+			if fingerprintsDiffer(persisted.Fingerprint, fingerprint) {
+				drift.Kind, drift.Persisted, drift.Registered = CatalogDriftTableKey, persisted.Fingerprint, fingerprint
+				if err := c.drift(drift, true); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, persisted := range c.sortedPersisted() {
+		if _, ok := c.persisted[id]; !ok && persisted.storage() == id {
+			return fmt.Errorf("table id 0x%02x of %q is used to store table %q: %w",
+				id, name, persisted.Name, ErrTableIDCollision)
+		}
+
+		if persisted.ID != id && persisted.Name == name {
+			drift.Kind = CatalogDriftTableID
+			drift.Persisted, drift.Registered = fmt.Sprintf("0x%02x", persisted.ID), fmt.Sprintf("0x%02x", id)
+			if err := c.drift(drift, false); err != nil {
+				return err
+			}
+
+			if err := c.unpersist(persisted); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := c.persist(entry); err != nil {
+		return err
+	}
+
+	c.tables[id] = name
+	c.serializers[id] = serializer
+	if _, ok := c.indexes[id]; !ok {
+		c.indexes[id] = make(map[IndexID]string)
+	}
+	return nil
+}
+
+// registerIndexes registers the indexes of the table.
+func (c *_catalog) registerIndexes(tableID TableID, indexes []_catalogIndex) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	persisted, ok := c.persisted[tableID]
+	if !ok {
+		return fmt.Errorf("table 0x%02x is not registered in catalog", tableID)
+	}
+
+	entry := *persisted
+	entry.Indexes = append([]_catalogIndex{}, persisted.Indexes...)
+
+	for _, index := range indexes {
+		drift := CatalogDrift{TableID: tableID, TableName: entry.Name, IndexID: index.ID, IndexName: index.Name}
+
+		replaced := false
+		for i, persistedIndex := range entry.Indexes {
+			if persistedIndex.ID == index.ID {
+				if persistedIndex.Name != index.Name {
+					drift.Kind, drift.Persisted, drift.Registered = CatalogDriftIndexName, persistedIndex.Name, index.Name
+					if err := c.drift(drift, fingerprintsDiffer(persistedIndex.Fingerprint, index.Fingerprint)); err != nil {
+						return err
+					}
+				} else if fingerprintsDiffer(persistedIndex.Fingerprint, index.Fingerprint) {
+					drift.Kind, drift.Persisted, drift.Registered = CatalogDriftIndexKey, persistedIndex.Fingerprint, index.Fingerprint
+					if err := c.drift(drift, true); err != nil {
+						return err
+					}
+				}
+
+				entry.Indexes[i] = index
+				replaced = true
+			} else if persistedIndex.Name == index.Name {
+				drift.Kind = CatalogDriftIndexID
+				drift.Persisted, drift.Registered = fmt.Sprintf("0x%02x", persistedIndex.ID), fmt.Sprintf("0x%02x", index.ID)
+				if err := c.drift(drift, false); err != nil {
+					return err
+				}
+			}
+		}
+
+		if !replaced {
+			entry.Indexes = append(entry.Indexes, index)
+		}
+	}
+
+	sort.Slice(entry.Indexes, func(i, j int) bool {
+		return entry.Indexes[i].ID < entry.Indexes[j].ID
+	})
+
+	if err := c.persist(&entry); err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		c.indexes[tableID][index.ID] = index.Name
+	}
+	return nil
+}
+
+// removed returns the persisted tables and indexes that are not registered.
+func (c *_catalog) removed() []CatalogDrift {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var drifts []CatalogDrift
+	for _, persisted := range c.sortedPersisted() {
+		if _, ok := c.tables[persisted.ID]; !ok {
+			drifts = append(drifts, CatalogDrift{
+				Kind:      CatalogDriftTableRemoved,
+				TableID:   persisted.ID,
+				TableName: persisted.Name,
+				Persisted: persisted.Name,
+			})
+			continue
+		}
+
+		for _, index := range persisted.Indexes {
+			if _, ok := c.indexes[persisted.ID][index.ID]; !ok {
+				drifts = append(drifts, CatalogDrift{
+					Kind:      CatalogDriftIndexRemoved,
+					TableID:   persisted.ID,
+					TableName: persisted.Name,
+					IndexID:   index.ID,
+					IndexName: index.Name,
+					Persisted: index.Name,
+				})
+			}
+		}
+	}
+	return drifts
+}
+
+func (c *_catalog) persist(entry *_catalogTable) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// the secondary reads the catalog of the primary
+	if c.db.secondary != nil {
+		c.persisted[entry.ID] = entry
+		c.updateStorageMapLocked()
+		return nil
+	}
+
+	err = c.db.Set(catalogKey(entry.ID), data, Sync)
+	if err != nil {
+		return fmt.Errorf("failed to persist catalog entry of table %q: %w", entry.Name, err)
+	}
+
+	c.persisted[entry.ID] = entry
+	c.updateStorageMapLocked()
+	return nil
+}
+
+func (c *_catalog) unpersist(entry *_catalogTable) error {
+	if c.db.secondary != nil {
+		delete(c.persisted, entry.ID)
+		c.updateStorageMapLocked()
+		return nil
+	}
+
+	err := c.db.Delete(catalogKey(entry.ID), Sync)
+	if err != nil {
+		return fmt.Errorf("failed to remove catalog entry of table %q: %w", entry.Name, err)
+	}
+
+	delete(c.persisted, entry.ID)
+	c.updateStorageMapLocked()
+	return nil
+}
+
+func (c *_catalog) sortedPersisted() []*_catalogTable {
+	tables := make([]*_catalogTable, 0, len(c.persisted))
+	for _, table := range c.persisted {
+		tables = append(tables, table)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].ID < tables[j].ID
+	})
+	return tables
+}
+
+// CheckCatalog returns the tables and indexes that are persisted in the catalog
+// of the database, but were not registered by the running binary. It should be
+// called once all the tables and indexes are registered.
+func CheckCatalog(db DB) []CatalogDrift {
+	bdb, ok := db.(*_db)
+	if !ok {
+		return nil
+	}
+	return bdb.catalog.removed()
+}
+
+// checkIndexes checks that the indexes do not share IDs with each other,
+// the primary index and the already added or built indexes.
+func (t *_table[T]) checkIndexes(idxs []*Index[T]) error {
+	added := make(map[IndexID]string, len(t.secondaryIndexes)+len(idxs))
+	for id, idx := range t.secondaryIndexes {
+		added[id] = idx.IndexName
+	}
+
+	for id, build := range t.indexBuilds {
+		if _, ok := added[id]; !ok {
+			added[id] = build.index.IndexName
+		}
+	}
+
+	for _, idx := range idxs {
+		if idx.IndexID == PrimaryIndexID {
+			return t.newError(idx, nil, fmt.Errorf("index id 0x%02x is reserved for the primary index: %w",
+				idx.IndexID, ErrIndexIDCollision))
+		}
+
+		if name, ok := added[idx.IndexID]; ok && name != idx.IndexName {
+			return t.newError(idx, nil, fmt.Errorf("index id 0x%02x is already used by index %s: %w",
+				idx.IndexID, name, ErrIndexIDCollision))
+		}
+
+		added[idx.IndexID] = idx.IndexName
+	}
+
+	return nil
+}
+
+// registerIndexes registers the indexes in the catalog of the database.
+func (t *_table[T]) registerIndexes(idxs []*Index[T]) error {
+	if t.catalog == nil {
+		return nil
+	}
+
+	indexes := make([]_catalogIndex, 0, len(idxs))
+	for _, idx := range idxs {
+		indexes = append(indexes, _catalogIndex{ID: idx.IndexID, Name: idx.IndexName, Fingerprint: indexFingerprint(idx)})
+	}
+
+	return t.catalog.registerIndexes(t.id, indexes)
+}
+
+// indexFingerprint describes the fields of the index keys and the index order,
+// it's empty if the key functions can not be run on the empty row.
+func indexFingerprint[T any](idx *Index[T]) string {
+	empty := utils.MakeNew[T]()
+
+	keyFields, keyOk := keyFingerprint(func(builder KeyBuilder) []byte {
+		return idx.IndexKeyFunction(builder, empty)
+	})
+	orderFields, orderOk := keyFingerprint(func(builder KeyBuilder) []byte {
+		return idx.IndexOrderFunction(IndexOrder{keyBuilder: builder}, empty).Bytes()
+	})
+
+	if !keyOk || !orderOk {
+		return ""
+	}
+	return keyFields + "/" + orderFields
+}
+
+// keyFingerprint describes the fields produced by the key function. It returns
+// false if the key function can not be run on the empty row.
+func keyFingerprint(keyFunc func(builder KeyBuilder) []byte) (fingerprint string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			fingerprint, ok = "", false
+		}
+	}()
+
+	schema := keySchema(keyFunc)
+
+	fields := make([]string, 0, len(schema))
+	for _, field := range schema {
+		fields = append(fields, field.Type.String())
+	}
+	return strings.Join(fields, ","), true
+}
+
+func fingerprintsDiffer(persisted, registered string) bool {
+	return persisted != "" && registered != "" && persisted != registered
+}
+
+func catalogKey(id TableID) []byte {
+	return KeyEncode(Key{
+		TableID:    BOND_DB_DATA_TABLE_ID,
+		IndexID:    BOND_DB_DATA_CATALOG_INDEX_ID,
+		IndexKey:   []byte{},
+		IndexOrder: []byte{},
+		PrimaryKey: []byte{byte(id)},
+	})
+}
@@ -0,0 +1,161 @@
+package bond
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Catalog_Drift(t *testing.T) {
+	defer func() { _ = os.RemoveAll(dbName) }()
+
+	const (
+		TokenBalanceTableID TableID = 0xC0
+	)
+
+	tokenBalanceTableOptions := func(db DB, name string) TableOptions[*TokenBalance] {
+		return TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   TokenBalanceTableID,
+			TableName: name,
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+		}
+	}
+
+	accountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	// first run persists the catalog
+	db, err := Open(dbName, &Options{})
+	require.NoError(t, err)
+
+	tokenBalanceTable, err := RegisterTable(tokenBalanceTableOptions(db, "token_balance"))
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{accountAddressIndex})
+	require.NoError(t, err)
+	assert.Empty(t, CheckCatalog(db))
+
+	require.NoError(t, db.Close())
+
+	// the table id reused by a different table
+	db, err = Open(dbName, &Options{})
+	require.NoError(t, err)
+
+	tokenBalanceCopyTableOptions := tokenBalanceTableOptions(db, "token_balance_copy")
+	tokenBalanceCopyTableOptions.TablePrimaryKeyFunc = func(builder KeyBuilder, tb *TokenBalance) []byte {
+		return builder.AddStringField(tb.AccountAddress).Bytes()
+	}
+
+	_, err = RegisterTable(tokenBalanceCopyTableOptions)
+	require.Error(t, err)
+
+	var drift CatalogDrift
+	require.True(t, errors.As(err, &drift))
+	assert.Equal(t, CatalogDriftTableName, drift.Kind)
+	assert.Equal(t, "token_balance", drift.Persisted)
+	assert.Equal(t, "token_balance_copy", drift.Registered)
+
+	// the index changed its key
+	tokenBalanceTable, err = RegisterTable(tokenBalanceTableOptions(db, "token_balance"))
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{
+		NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 1,
+			IndexName: "account_address_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint32Field(tb.AccountID).Bytes()
+			},
+			IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+		}),
+	})
+	require.True(t, errors.As(err, &drift))
+	assert.Equal(t, CatalogDriftIndexKey, drift.Kind)
+	assert.Equal(t, "string/", drift.Persisted)
+	assert.Equal(t, "uint32/", drift.Registered)
+
+	// the index is not registered
+	assert.Equal(t, []CatalogDrift{{
+		Kind:      CatalogDriftIndexRemoved,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		Persisted: "account_address_idx",
+	}}, CheckCatalog(db))
+
+	require.NoError(t, db.Close())
+
+	// the accepted drift updates the catalog
+	var drifts []CatalogDrift
+	db, err = Open(dbName, &Options{
+		CatalogDriftFunc: func(drift CatalogDrift) error {
+			drifts = append(drifts, drift)
+			return nil
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = RegisterTable(tokenBalanceTableOptions(db, "token_balance_renamed"))
+	require.NoError(t, err)
+	require.Equal(t, 1, len(drifts))
+	assert.Equal(t, CatalogDriftTableName, drifts[0].Kind)
+
+	require.NoError(t, db.Close())
+
+	db, err = Open(dbName, &Options{})
+	require.NoError(t, err)
+
+	// the renamed table keeps its indexes
+	_, err = RegisterTable(tokenBalanceTableOptions(db, "token_balance_renamed"))
+	require.NoError(t, err)
+
+	drifts = CheckCatalog(db)
+	require.Equal(t, 1, len(drifts))
+	assert.Equal(t, CatalogDriftIndexRemoved, drifts[0].Kind)
+	assert.Equal(t, "account_address_idx", drifts[0].IndexName)
+
+	require.NoError(t, db.Close())
+
+	// the table and the index renamed without changing their keys are accepted
+	var renames []CatalogDrift
+	db, err = Open(dbName, &Options{
+		OnCatalogRename: func(drift CatalogDrift) {
+			renames = append(renames, drift)
+		},
+	})
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	tokenBalanceTable, err = RegisterTable(tokenBalanceTableOptions(db, "token_balance"))
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{
+		NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 1,
+			IndexName: "account_address_renamed_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.AccountAddress).Bytes()
+			},
+			IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+		}),
+	})
+	require.NoError(t, err)
+	assert.Empty(t, CheckCatalog(db))
+
+	require.Equal(t, 2, len(renames))
+	assert.Equal(t, CatalogDriftTableName, renames[0].Kind)
+	assert.Equal(t, CatalogDriftIndexName, renames[1].Kind)
+}
@@ -0,0 +1,38 @@
+package bond
+
+import "time"
+
+// Clock is the time source of the database. It decides the expiry of the
+// rows by the retention and the archival, of the retained snapshots and of
+// the queue visibility timeouts, and stamps the committed batches, so the
+// tests can control the time and the replicas can agree on the expiry.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc is the function that implements Clock.
+//
+// Example:
+//
+//	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+//	db, err := bond.Open(dir, &bond.Options{
+//		Clock: bond.ClockFunc(func() time.Time {
+//			return now
+//		}),
+//	})
+type ClockFunc func() time.Time
+
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SystemClock is the Clock that returns the system time.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// clockOf returns the clock of the database or the SystemClock.
+func clockOf(db DB) Clock {
+	if db, ok := db.(*_db); ok && db.clock != nil {
+		return db.clock
+	}
+	return SystemClock
+}
@@ -0,0 +1,69 @@
+package bond
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Clock(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	db, err := Open(dbName, &Options{
+		Clock: ClockFunc(func() time.Time {
+			return now
+		}),
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		Retention: &Retention[*TokenBalance]{
+			MaxAge: time.Hour,
+			// the balance is the hour the row was written at
+			TimeFunc: func(tb *TokenBalance) time.Time {
+				return time.Date(2022, 1, 1, int(tb.Balance), 0, 0, 0, time.UTC)
+			},
+			Interval: time.Hour,
+		},
+	})
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 5; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{ID: uint64(i), AccountAddress: "0xtestAccount", Balance: uint64(i)})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	// the rows are not expired yet
+	deleted, err := tokenBalanceTable.EnforceRetention(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+
+	now = time.Date(2022, 1, 1, 3, 30, 0, 0, time.UTC)
+
+	deleted, err = tokenBalanceTable.EnforceRetention(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	var tokenBalancesRead []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[2:], tokenBalancesRead)
+
+	assert.Equal(t, now, db.RetainSnapshot())
+}
@@ -0,0 +1,418 @@
+package bond
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// CommitPreparer prepares the batches to be committed in two phases.
+type CommitPreparer interface {
+	PrepareCommit(batch Batch) (*CommitIntent, error)
+	PendingCommitIntents() []*CommitIntent
+}
+
+// CommitIntent is the batch prepared to be committed. The writes of the batch
+// are persisted, but not applied, until the intent is confirmed, so the commit
+// can be coordinated with the external system, e.g. the message broker of the
+// saga, and survives the crash in between.
+type CommitIntent struct {
+	// ID is unique for the database, the IDs of the resolved intents are not
+	// reused after the database is reopened.
+	ID        uint64
+	CreatedAt time.Time
+
+	// Mutations are the writes of the prepared batch.
+	Mutations []WriteMutation
+
+	db *_db
+
+	// batch is the prepared batch, nil if the intent was recovered on Open,
+	// and count is the number of its writes when it was prepared
+	batch Batch
+	count uint32
+
+	// resolving is set while the intent is confirmed or aborted, so it's
+	// resolved once
+	resolving bool
+}
+
+// _commitIntents are the intents that are neither confirmed nor aborted.
+type _commitIntents struct {
+	intents map[uint64]*CommitIntent
+	lastID  uint64
+
+	// reloads drop the state the tables hold in memory, e.g. the cached rows
+	// and the index statistics, by the table. They're called once the writes
+	// of the recovered intent are committed.
+	reloads map[TableID][]func() error
+
+	mutex sync.Mutex
+}
+
+// _commitIntentRecord is the persisted commit intent.
+type _commitIntentRecord struct {
+	CreatedAt time.Time       `json:"createdAt"`
+	Mutations []WriteMutation `json:"mutations"`
+}
+
+// PrepareCommit persists the writes of the batch as the commit intent, which
+// is committed with Confirm or discarded with Abort. The batch must not be
+// written to or committed after it's prepared, Confirm fails if it was. The intent does not lock the
+// rows, the writes made in the meantime are overwritten by the confirmed ones.
+//
+// The intents that were pending when the database was closed, or crashed, are
+// recovered on Open and listed by PendingCommitIntents, so they can be resolved
+// once the outcome of the external system is known.
+//
+// Example:
+//
+//	batch := db.Batch()
+//	err := ordersTable.Insert(ctx, orders, batch)
+//	...
+//	intent, err := db.PrepareCommit(batch)
+//	...
+//	if err := broker.Publish(ctx, orderCreatedEvents); err != nil {
+//		return intent.Abort()
+//	}
+//	return intent.Confirm(bond.Sync)
+func (db *_db) PrepareCommit(batch Batch) (*CommitIntent, error) {
+	b, ok := batch.(*_batch)
+	if !ok {
+		return nil, fmt.Errorf("prepare commit: incorrect batch param")
+	}
+
+	if b.Empty() {
+		return nil, fmt.Errorf("prepare commit: empty batch")
+	}
+
+	db.commitIntents.mutex.Lock()
+	defer db.commitIntents.mutex.Unlock()
+
+	intent := &CommitIntent{
+		ID:        db.commitIntents.lastID + 1,
+		CreatedAt: db.clock.Now(),
+		Mutations: b.BackendBatch.Mutations(),
+		db:        db,
+		batch:     batch,
+		count:     b.BackendBatch.Count(),
+	}
+
+	data, err := json.Marshal(_commitIntentRecord{CreatedAt: intent.CreatedAt, Mutations: intent.Mutations})
+	if err != nil {
+		return nil, fmt.Errorf("prepare commit: %w", err)
+	}
+
+	// the last ID is persisted with the intent, so the IDs are not reused
+	// once the intents are resolved and the database is reopened
+	var lastID [8]byte
+	binary.BigEndian.PutUint64(lastID[:], intent.ID)
+
+	intentBatch := db.Batch()
+	defer func() {
+		_ = intentBatch.Close()
+	}()
+
+	err = intentBatch.Set(commitIntentKey(intent.ID), data, Sync)
+	if err == nil {
+		err = intentBatch.Set(commitIntentLastIDKey(), lastID[:], Sync)
+	}
+	if err == nil {
+		err = intentBatch.Commit(Sync)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("prepare commit: failed to persist intent: %w", err)
+	}
+
+	if db.commitIntents.intents == nil {
+		db.commitIntents.intents = make(map[uint64]*CommitIntent)
+	}
+	db.commitIntents.intents[intent.ID] = intent
+	db.commitIntents.lastID = intent.ID
+	return intent, nil
+}
+
+// PendingCommitIntents returns the commit intents that are neither confirmed
+// nor aborted, including the ones recovered on Open, in the order they were
+// prepared.
+func (db *_db) PendingCommitIntents() []*CommitIntent {
+	db.commitIntents.mutex.Lock()
+	defer db.commitIntents.mutex.Unlock()
+
+	intents := make([]*CommitIntent, 0, len(db.commitIntents.intents))
+	for _, intent := range db.commitIntents.intents {
+		intents = append(intents, intent)
+	}
+
+	sort.Slice(intents, func(i, j int) bool {
+		return intents[i].ID < intents[j].ID
+	})
+	return intents
+}
+
+// Confirm commits the writes of the intent and removes the intent with the
+// same commit. The intent recovered on Open commits its persisted writes, and
+// the tables it wrote to drop their cached rows and reload their index
+// statistics.
+func (i *CommitIntent) Confirm(opt WriteOptions) error {
+	if err := i.begin(); err != nil {
+		return err
+	}
+
+	err := i.confirm(opt)
+	if err != nil {
+		i.release()
+		return fmt.Errorf("commit intent %d: %w", i.ID, err)
+	}
+
+	i.resolve()
+
+	if i.batch == nil {
+		err = i.db.reloadReplayedTables(i.Mutations)
+		if err != nil {
+			return fmt.Errorf("commit intent %d: %w", i.ID, err)
+		}
+	}
+	return nil
+}
+
+func (i *CommitIntent) confirm(opt WriteOptions) error {
+	batch := i.batch
+	if batch == nil {
+		batch = i.db.Batch()
+		defer func() {
+			_ = batch.Close()
+		}()
+
+		err := replayMutations(batch.(*_batch).BackendBatch, i.Mutations)
+		if err != nil {
+			return err
+		}
+	} else {
+		b := batch.(*_batch)
+		if b.BackendBatch.Count() != i.count {
+			return fmt.Errorf("batch was written to after it was prepared")
+		}
+
+		// the intent key is deleted once, so the commit can be retried
+		defer func() {
+			i.count = b.BackendBatch.Count()
+		}()
+	}
+
+	err := batch.Delete(commitIntentKey(i.ID), Sync)
+	if err != nil {
+		return err
+	}
+	return batch.Commit(opt)
+}
+
+// Abort discards the writes of the intent. The prepared batch is not closed.
+func (i *CommitIntent) Abort() error {
+	if err := i.begin(); err != nil {
+		return err
+	}
+
+	err := i.db.Delete(commitIntentKey(i.ID), Sync)
+	if err != nil {
+		i.release()
+		return fmt.Errorf("commit intent %d: %w", i.ID, err)
+	}
+
+	i.resolve()
+	return nil
+}
+
+// begin marks the pending intent as being resolved.
+func (i *CommitIntent) begin() error {
+	i.db.commitIntents.mutex.Lock()
+	defer i.db.commitIntents.mutex.Unlock()
+
+	if i.db.commitIntents.intents[i.ID] != i {
+		return fmt.Errorf("commit intent %d is not pending", i.ID)
+	}
+	if i.resolving {
+		return fmt.Errorf("commit intent %d is being resolved", i.ID)
+	}
+	i.resolving = true
+	return nil
+}
+
+// release leaves the intent pending, once it failed to be resolved.
+func (i *CommitIntent) release() {
+	i.db.commitIntents.mutex.Lock()
+	defer i.db.commitIntents.mutex.Unlock()
+	i.resolving = false
+}
+
+func (i *CommitIntent) resolve() {
+	i.db.commitIntents.mutex.Lock()
+	defer i.db.commitIntents.mutex.Unlock()
+	delete(i.db.commitIntents.intents, i.ID)
+}
+
+// onCommitIntentReplayed registers the function that drops the state the
+// table holds in memory once the recovered intent writes its keys.
+func (db *_db) onCommitIntentReplayed(table TableID, f func() error) {
+	db.commitIntents.mutex.Lock()
+	defer db.commitIntents.mutex.Unlock()
+
+	if db.commitIntents.reloads == nil {
+		db.commitIntents.reloads = make(map[TableID][]func() error)
+	}
+	db.commitIntents.reloads[table] = append(db.commitIntents.reloads[table], f)
+}
+
+// reloadReplayedTables calls the reloads of the tables written to by the
+// replayed mutations.
+func (db *_db) reloadReplayedTables(mutations []WriteMutation) error {
+	storageIDs := replayedStorageIDs(mutations)
+
+	db.commitIntents.mutex.Lock()
+	var reloads []func() error
+	for table, tableReloads := range db.commitIntents.reloads {
+		if storageIDs[db.catalog.storageID(table)] {
+			reloads = append(reloads, tableReloads...)
+		}
+	}
+	db.commitIntents.mutex.Unlock()
+
+	for _, reload := range reloads {
+		err := reload()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayedStorageIDs returns the storage IDs of the tables whose keys are
+// written by the mutations. The bond data of the table, e.g. the index
+// statistics, is keyed by its storage ID after the bond index ID.
+func replayedStorageIDs(mutations []WriteMutation) map[TableID]bool {
+	storageIDs := make(map[TableID]bool)
+	for _, mutation := range mutations {
+		switch {
+		case mutation.Kind == WriteChangeDeleteRange && len(mutation.Key) > 0 && len(mutation.Value) > 0:
+			for id := int(mutation.Key[0]); id <= int(mutation.Value[0]); id++ {
+				storageIDs[TableID(id)] = true
+			}
+		case mutation.TableID == BOND_DB_DATA_TABLE_ID && len(mutation.Key) > 2:
+			storageIDs[TableID(mutation.Key[2])] = true
+		default:
+			storageIDs[mutation.TableID] = true
+		}
+	}
+	return storageIDs
+}
+
+// loadCommitIntents recovers the pending commit intents.
+func (db *_db) loadCommitIntents() error {
+	iter := db.backend().Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_COMMIT_INTENT_INDEX_ID},
+			UpperBound: []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_COMMIT_INTENT_INDEX_ID + 1},
+		},
+	})
+
+	db.commitIntents.mutex.Lock()
+	defer db.commitIntents.mutex.Unlock()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		key := iter.Key()
+		if bytes.Equal(key, commitIntentLastIDKey()) && len(iter.Value()) == 8 {
+			if lastID := binary.BigEndian.Uint64(iter.Value()); lastID > db.commitIntents.lastID {
+				db.commitIntents.lastID = lastID
+			}
+			continue
+		} else if len(key) != 10 {
+			continue
+		}
+
+		var record _commitIntentRecord
+		if err := json.Unmarshal(iter.Value(), &record); err != nil {
+			_ = iter.Close()
+			return fmt.Errorf("failed to load commit intent %s: %w", FormatKey(key), err)
+		}
+
+		intent := &CommitIntent{
+			ID:        binary.BigEndian.Uint64(key[2:]),
+			CreatedAt: record.CreatedAt,
+			Mutations: record.Mutations,
+			db:        db,
+		}
+
+		if db.commitIntents.intents == nil {
+			db.commitIntents.intents = make(map[uint64]*CommitIntent)
+		}
+		db.commitIntents.intents[intent.ID] = intent
+		if intent.ID > db.commitIntents.lastID {
+			db.commitIntents.lastID = intent.ID
+		}
+	}
+
+	return iter.Close()
+}
+
+// reloadReplayed drops the cached rows and the query results, and reloads the
+// index statistics, once the recovered commit intent wrote to the table.
+func (t *_table[T]) reloadReplayed() error {
+	t.clearCaches()
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	for _, idx := range t.secondaryIndexes {
+		if idx.stats == nil {
+			continue
+		}
+
+		err := idx.loadStats()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayMutations writes the mutations to the batch.
+func replayMutations(batch BackendBatch, mutations []WriteMutation) error {
+	for _, mutation := range mutations {
+		var err error
+		switch mutation.Kind {
+		case WriteChangeSet:
+			err = batch.Set(mutation.Key, mutation.Value, Sync)
+		case WriteChangeDelete:
+			err = batch.Delete(mutation.Key, Sync)
+		case WriteChangeDeleteRange:
+			err = batch.DeleteRange(mutation.Key, mutation.Value, Sync)
+		case WriteChangeMerge:
+			err = batch.Merge(mutation.Key, mutation.Value, Sync)
+		default:
+			err = fmt.Errorf("unknown mutation kind %s", mutation.Kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitIntentLastIDKey returns the key of the last ID given to the intent. It
+// sorts before the keys of the intents.
+func commitIntentLastIDKey() []byte {
+	return []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_COMMIT_INTENT_INDEX_ID}
+}
+
+func commitIntentKey(id uint64) []byte {
+	key := make([]byte, 10)
+	key[0], key[1] = BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_COMMIT_INTENT_INDEX_ID
+	binary.BigEndian.PutUint64(key[2:], id)
+	return key
+}
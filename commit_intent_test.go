@@ -0,0 +1,194 @@
+package bond
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_PrepareCommit(t *testing.T) {
+	db := setupDatabase()
+	defer func() { tearDownDatabase(db) }()
+
+	newTokenBalanceTable := func(db DB) Table[*TokenBalance] {
+		return NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   TableID(1),
+			TableName: "token_balance",
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+			CacheSize: 10,
+		})
+	}
+
+	tokenBalanceTable := newTokenBalanceTable(db)
+	ctx := context.Background()
+
+	tb1 := &TokenBalance{ID: 1, AccountAddress: "0xa1", Balance: 5}
+	tb2 := &TokenBalance{ID: 2, AccountAddress: "0xa2", Balance: 7}
+	tb3 := &TokenBalance{ID: 3, AccountAddress: "0xa3", Balance: 9}
+
+	// the confirmed intent applies the writes
+	batch := db.Batch()
+	err := tokenBalanceTable.Insert(ctx, []*TokenBalance{tb1}, batch)
+	require.NoError(t, err)
+
+	intent, err := db.PrepareCommit(batch)
+	require.NoError(t, err)
+	require.Equal(t, []*CommitIntent{intent}, db.PendingCommitIntents())
+
+	exist := tokenBalanceTable.Exist(tb1)
+	assert.False(t, exist)
+
+	err = intent.Confirm(Sync)
+	require.NoError(t, err)
+	_ = batch.Close()
+
+	exist = tokenBalanceTable.Exist(tb1)
+	assert.True(t, exist)
+	assert.Empty(t, db.PendingCommitIntents())
+
+	// the resolved intent can not be resolved again
+	err = intent.Abort()
+	require.Error(t, err)
+
+	// the aborted intent discards the writes
+	batch = db.Batch()
+	err = tokenBalanceTable.Insert(ctx, []*TokenBalance{tb2}, batch)
+	require.NoError(t, err)
+
+	intent, err = db.PrepareCommit(batch)
+	require.NoError(t, err)
+
+	err = intent.Abort()
+	require.NoError(t, err)
+	_ = batch.Close()
+
+	exist = tokenBalanceTable.Exist(tb2)
+	assert.False(t, exist)
+	assert.Empty(t, db.PendingCommitIntents())
+
+	// the empty batch can not be prepared
+	_, err = db.PrepareCommit(db.Batch())
+	require.Error(t, err)
+
+	// the batch written to after it was prepared is not committed
+	batch = db.Batch()
+	err = tokenBalanceTable.Insert(ctx, []*TokenBalance{tb2}, batch)
+	require.NoError(t, err)
+
+	intent, err = db.PrepareCommit(batch)
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Insert(ctx, []*TokenBalance{tb3}, batch)
+	require.NoError(t, err)
+
+	err = intent.Confirm(Sync)
+	require.Error(t, err)
+
+	err = intent.Abort()
+	require.NoError(t, err)
+	_ = batch.Close()
+
+	exist = tokenBalanceTable.Exist(tb2)
+	assert.False(t, exist)
+
+	// the pending intent is recovered after reopen
+	batch = db.Batch()
+	err = tokenBalanceTable.Insert(ctx, []*TokenBalance{tb2, tb3}, batch)
+	require.NoError(t, err)
+	err = tokenBalanceTable.Delete(ctx, []*TokenBalance{tb1}, batch)
+	require.NoError(t, err)
+
+	intent, err = db.PrepareCommit(batch)
+	require.NoError(t, err)
+	_ = batch.Close()
+
+	err = db.Close()
+	require.NoError(t, err)
+
+	db, err = Open(dbName, &Options{})
+	require.NoError(t, err)
+
+	tokenBalanceTable = newTokenBalanceTable(db)
+
+	intents := db.PendingCommitIntents()
+	require.Len(t, intents, 1)
+	assert.Equal(t, intent.ID, intents[0].ID)
+	assert.Equal(t, intent.Mutations, intents[0].Mutations)
+
+	exist = tokenBalanceTable.Exist(tb2)
+	assert.False(t, exist)
+
+	// the row is cached before the intent is confirmed
+	_, err = tokenBalanceTable.Get(tb1)
+	require.NoError(t, err)
+
+	// the intent confirmed at the same time is committed once
+	var (
+		wg        sync.WaitGroup
+		confirmed int32
+	)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if intents[0].Confirm(Sync) == nil {
+				atomic.AddInt32(&confirmed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), confirmed)
+
+	_, err = tokenBalanceTable.Get(tb1)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	var tokenBalances []*TokenBalance
+	err = tokenBalanceTable.Scan(ctx, &tokenBalances)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tb2, tb3}, tokenBalances)
+	assert.Empty(t, db.PendingCommitIntents())
+
+	// the ids of the new intents follow the recovered ones
+	batch = db.Batch()
+	err = tokenBalanceTable.Insert(ctx, []*TokenBalance{tb1}, batch)
+	require.NoError(t, err)
+
+	next, err := db.PrepareCommit(batch)
+	require.NoError(t, err)
+	assert.Greater(t, next.ID, intent.ID)
+
+	err = next.Abort()
+	require.NoError(t, err)
+	_ = batch.Close()
+
+	// the IDs are not reused once all the intents are resolved and the
+	// database is reopened
+	require.Empty(t, db.PendingCommitIntents())
+
+	err = db.Close()
+	require.NoError(t, err)
+
+	db, err = Open(dbName, &Options{})
+	require.NoError(t, err)
+
+	tokenBalanceTable = newTokenBalanceTable(db)
+
+	batch = db.Batch()
+	err = tokenBalanceTable.Insert(ctx, []*TokenBalance{tb1}, batch)
+	require.NoError(t, err)
+
+	reopened, err := db.PrepareCommit(batch)
+	require.NoError(t, err)
+	assert.Greater(t, reopened.ID, next.ID)
+
+	err = reopened.Confirm(Sync)
+	require.NoError(t, err)
+	_ = batch.Close()
+}
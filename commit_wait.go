@@ -0,0 +1,57 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+// CommitWaiter waits for the commits to be readable from the database.
+type CommitWaiter interface {
+	WaitForCommit(ctx context.Context, seq uint64) error
+}
+
+// WaitForCommit waits until the batch committed to the primary with the
+// sequence number, see CommittedBatchInfo.Seq, is readable from the database.
+// The secondary opened with OpenSecondary waits for the catch-up that reads
+// the commit, up to Options.SecondaryCatchUpInterval, the primary returns
+// immediately. It lets the session that wrote to the primary read its own
+// writes from the secondary.
+//
+// Example:
+//
+//	var info bond.CommittedBatchInfo
+//	err := tokenBalanceTable.Insert(bond.ContextWithWriteOptions(ctx, bond.WriteOptions{Sync: true, Committed: &info}), trs)
+//	...
+//	err = analyticsDB.WaitForCommit(ctx, info.Seq)
+func (db *_db) WaitForCommit(ctx context.Context, seq uint64) error {
+	if db.secondary == nil {
+		return nil
+	}
+
+	for {
+		instance, caughtUp := db.secondary.waitFor()
+		if instance == nil {
+			return fmt.Errorf("wait for commit: database closed")
+		}
+		if instance.seq >= seq {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		case <-caughtUp:
+		}
+	}
+}
+
+// committed records the sequence number of the committed batch.
+func (db *_db) committed(seq uint64) {
+	for {
+		last := atomic.LoadUint64(&db.commitSeq)
+		if seq <= last || atomic.CompareAndSwapUint64(&db.commitSeq, last, seq) {
+			return
+		}
+	}
+}
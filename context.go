@@ -6,6 +6,8 @@ import (
 
 const contextKeyName = "go-bond-batch"
 const contextSyncKeyName = "go-bond-sync-batch"
+const contextWriteOptionsKeyName = "go-bond-write-options"
+const contextFieldCodecKeyName = "go-bond-field-codec-key"
 
 func ContextWithBatch(ctx context.Context, batch Batch) context.Context {
 	return context.WithValue(ctx, contextKeyName, batch)
@@ -28,3 +30,30 @@ func ContextRetrieveSyncBatch(ctx context.Context) *SyncBatch {
 	}
 	return nil
 }
+
+// ContextWithWriteOptions sets the options of the commits made by the table
+// writes, e.g. WriteOptions{DryRun: true}. The writes to the external batch
+// are committed with the options the batch is committed with.
+func ContextWithWriteOptions(ctx context.Context, opt WriteOptions) context.Context {
+	return context.WithValue(ctx, contextWriteOptionsKeyName, opt)
+}
+
+func ContextRetrieveWriteOptions(ctx context.Context) WriteOptions {
+	if opt := ctx.Value(contextWriteOptionsKeyName); opt != nil {
+		return opt.(WriteOptions)
+	}
+	return Sync
+}
+
+// ContextWithFieldCodecKey sets the key the rows of the tables with
+// TableOptions.FieldCodec are decoded with on read.
+func ContextWithFieldCodecKey(ctx context.Context, key []byte) context.Context {
+	return context.WithValue(ctx, contextFieldCodecKeyName, key)
+}
+
+func ContextRetrieveFieldCodecKey(ctx context.Context) []byte {
+	if key := ctx.Value(contextFieldCodecKeyName); key != nil {
+		return key.([]byte)
+	}
+	return nil
+}
@@ -93,6 +93,11 @@ func (m *MockBatch) OnClose(f func(b Batch)) {
 	panic("implement me")
 }
 
+func (m *MockBatch) AfterCommit(f func(info CommittedBatchInfo)) {
+	//TODO implement me
+	panic("implement me")
+}
+
 func TestContextWithBatch(t *testing.T) {
 	mBatch := &MockBatch{}
 
@@ -0,0 +1,73 @@
+package bond
+
+// WriteChangeKind is the kind of the change made by the write.
+type WriteChangeKind uint8
+
+const (
+	WriteChangeSet WriteChangeKind = iota
+	WriteChangeDelete
+	WriteChangeDeleteRange
+	WriteChangeMerge
+)
+
+func (k WriteChangeKind) String() string {
+	switch k {
+	case WriteChangeSet:
+		return "set"
+	case WriteChangeDelete:
+		return "delete"
+	case WriteChangeDeleteRange:
+		return "delete range"
+	case WriteChangeMerge:
+		return "merge"
+	default:
+		return "unknown"
+	}
+}
+
+// WriteChange is the single change of the write. The Value is the value that
+// is set, the end key of the deleted range, or the operand that is merged.
+type WriteChange struct {
+	Kind  WriteChangeKind
+	Key   []byte
+	Value []byte
+}
+
+// WriteReport describes the changes the dry run write would make.
+type WriteReport struct {
+	Changes []WriteChange
+}
+
+// Count returns the number of the changes of given kind.
+func (r *WriteReport) Count(kind WriteChangeKind) int {
+	count := 0
+	for _, change := range r.Changes {
+		if change.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+func (r *WriteReport) add(kind WriteChangeKind, key []byte, value []byte) {
+	if r == nil {
+		return
+	}
+
+	r.Changes = append(r.Changes, WriteChange{
+		Kind:  kind,
+		Key:   append([]byte{}, key...),
+		Value: append([]byte{}, value...),
+	})
+}
+
+// addMutations adds the mutations of the batch to the report.
+func (r *WriteReport) addMutations(mutations []WriteMutation) {
+	if r == nil {
+		return
+	}
+
+	for _, mutation := range mutations {
+		r.add(mutation.Kind, mutation.Key, mutation.Value)
+	}
+}
@@ -0,0 +1,104 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_DryRun(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	tokenBalance := &TokenBalance{ID: 1, AccountAddress: "0xtestAccount", Balance: 5}
+
+	var report WriteReport
+	dryRunCtx := ContextWithWriteOptions(context.Background(), WriteOptions{DryRun: true, Report: &report})
+
+	err = tokenBalanceTable.Insert(dryRunCtx, []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Count(WriteChangeSet))
+	assert.False(t, tokenBalanceTable.Exist(tokenBalance))
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	// constraint checks are run
+	err = tokenBalanceTable.Insert(dryRunCtx, []*TokenBalance{tokenBalance})
+	require.ErrorIs(t, err, ErrKeyExists)
+
+	report = WriteReport{}
+	err = tokenBalanceTable.Delete(dryRunCtx, []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Count(WriteChangeDelete))
+	assert.True(t, tokenBalanceTable.Exist(tokenBalance))
+
+	// batch committed with dry run is discarded
+	report = WriteReport{}
+	batch := db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	tokenBalance2 := &TokenBalance{ID: 2, AccountAddress: "0xtestAccount", Balance: 7}
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance2}, batch)
+	require.NoError(t, err)
+
+	var discardedCommitted bool
+	batch.OnCommitted(func(Batch) {
+		discardedCommitted = true
+	})
+
+	err = batch.Commit(WriteOptions{DryRun: true, Report: &report})
+	require.NoError(t, err)
+	assert.Equal(t, 2, report.Count(WriteChangeSet))
+	assert.True(t, batch.Empty())
+	assert.False(t, tokenBalanceTable.Exist(tokenBalance2))
+
+	// the callbacks of the discarded writes do not run once the batch is reused
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance2}, batch)
+	require.NoError(t, err)
+
+	err = batch.Commit(Sync)
+	require.NoError(t, err)
+	assert.True(t, tokenBalanceTable.Exist(tokenBalance2))
+	assert.False(t, discardedCommitted)
+
+	// database writes
+	report = WriteReport{}
+	err = db.Set([]byte("key"), []byte("value"), WriteOptions{DryRun: true, Report: &report})
+	require.NoError(t, err)
+	require.Len(t, report.Changes, 1)
+	assert.Equal(t, WriteChange{Kind: WriteChangeSet, Key: []byte("key"), Value: []byte("value")}, report.Changes[0])
+
+	_, _, err = db.Get([]byte("key"))
+	require.Error(t, err)
+}
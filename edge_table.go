@@ -0,0 +1,232 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Edge is the directed edge between the From and the To node.
+type Edge[From any, To any] struct {
+	From From
+	To   To
+}
+
+// EdgeDirection is the direction in which the edges are followed.
+type EdgeDirection uint8
+
+const (
+	// EdgeDirectionOutgoing follows the edges from the From to the To node.
+	EdgeDirectionOutgoing EdgeDirection = iota
+	// EdgeDirectionIncoming follows the edges from the To to the From node.
+	EdgeDirectionIncoming
+)
+
+const (
+	EdgeTableForwardIndexID = PrimaryIndexID + 1
+	EdgeTableReverseIndexID = PrimaryIndexID + 2
+)
+
+type EdgeTableOptions[From any, To any] struct {
+	DB DB
+
+	TableID   TableID
+	TableName string
+
+	FromKeyFunc func(builder KeyBuilder, from From) []byte
+	ToKeyFunc   func(builder KeyBuilder, to To) []byte
+
+	Serializer Serializer[**Edge[From, To]]
+}
+
+// EdgeTable is the table of the directed edges. The edges are indexed by the
+// From node in the forward index and by the To node in the reverse index, so
+// they can be followed in both directions.
+type EdgeTable[From any, To any] struct {
+	Table[*Edge[From, To]]
+
+	table *_table[*Edge[From, To]]
+
+	forwardIndex *Index[*Edge[From, To]]
+	reverseIndex *Index[*Edge[From, To]]
+
+	fromKeyFunc func(builder KeyBuilder, from From) []byte
+	toKeyFunc   func(builder KeyBuilder, to To) []byte
+}
+
+func NewEdgeTable[From any, To any](opt EdgeTableOptions[From, To]) *EdgeTable[From, To] {
+	table := NewTable[*Edge[From, To]](TableOptions[*Edge[From, To]]{
+		DB:        opt.DB,
+		TableID:   opt.TableID,
+		TableName: opt.TableName,
+		TablePrimaryKeyFunc: func(builder KeyBuilder, e *Edge[From, To]) []byte {
+			fromKey := opt.FromKeyFunc(builder, e.From)
+			return opt.ToKeyFunc(NewKeyBuilder(fromKey), e.To)
+		},
+		Serializer: opt.Serializer,
+	})
+
+	forwardIndex := NewIndex[*Edge[From, To]](IndexOptions[*Edge[From, To]]{
+		IndexID:   EdgeTableForwardIndexID,
+		IndexName: "forward_idx",
+		IndexKeyFunc: func(builder KeyBuilder, e *Edge[From, To]) []byte {
+			return opt.FromKeyFunc(builder, e.From)
+		},
+		IndexOrderFunc: IndexOrderDefault[*Edge[From, To]],
+	})
+
+	reverseIndex := NewIndex[*Edge[From, To]](IndexOptions[*Edge[From, To]]{
+		IndexID:   EdgeTableReverseIndexID,
+		IndexName: "reverse_idx",
+		IndexKeyFunc: func(builder KeyBuilder, e *Edge[From, To]) []byte {
+			return opt.ToKeyFunc(builder, e.To)
+		},
+		IndexOrderFunc: IndexOrderDefault[*Edge[From, To]],
+	})
+
+	err := table.AddIndex([]*Index[*Edge[From, To]]{forwardIndex, reverseIndex})
+	if err != nil {
+		panic(err)
+	}
+
+	return &EdgeTable[From, To]{
+		Table:        table,
+		table:        table.(*_table[*Edge[From, To]]),
+		forwardIndex: forwardIndex,
+		reverseIndex: reverseIndex,
+		fromKeyFunc:  opt.FromKeyFunc,
+		toKeyFunc:    opt.ToKeyFunc,
+	}
+}
+
+// AddEdges adds the edges, the edges that already exist are left as they are.
+func (e *EdgeTable[From, To]) AddEdges(ctx context.Context, edges []*Edge[From, To], optBatch ...Batch) error {
+	return e.Upsert(ctx, edges, TableUpsertOnConflictReplace[*Edge[From, To]], optBatch...)
+}
+
+// RemoveEdges removes the edges.
+func (e *EdgeTable[From, To]) RemoveEdges(ctx context.Context, edges []*Edge[From, To], optBatch ...Batch) error {
+	return e.Delete(ctx, edges, optBatch...)
+}
+
+// Outgoing returns up to the limit of the edges that start in the from node.
+// The limit of 0 returns all the edges.
+func (e *EdgeTable[From, To]) Outgoing(ctx context.Context, from From, limit uint64, optBatch ...Batch) ([]*Edge[From, To], error) {
+	return e.edges(ctx, e.forwardIndex, &Edge[From, To]{From: from}, limit, optBatch...)
+}
+
+// Incoming returns up to the limit of the edges that end in the to node. The
+// limit of 0 returns all the edges.
+func (e *EdgeTable[From, To]) Incoming(ctx context.Context, to To, limit uint64, optBatch ...Batch) ([]*Edge[From, To], error) {
+	return e.edges(ctx, e.reverseIndex, &Edge[From, To]{To: to}, limit, optBatch...)
+}
+
+func (e *EdgeTable[From, To]) edges(ctx context.Context, idx *Index[*Edge[From, To]], selector *Edge[From, To], limit uint64, optBatch ...Batch) ([]*Edge[From, To], error) {
+	var batch Batch
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		batch = optBatch[0]
+	}
+
+	// the edges are selected by the index key only, the selector keys of the
+	// other node would skip the edges with the lower keys
+	prefix := e.table.keyPrefix(idx, selector, make([]byte, 0, DataKeyBufferSize))
+
+	iter := e.table.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: prefix,
+		},
+	}, batch)
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	var (
+		edges     []*Edge[From, To]
+		keyBuffer [DataKeyBufferSize]byte
+	)
+	for iter.SeekPrefixGE(prefix); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		edge, err := e.table.get(KeyBytes(iter.Key()).ToDataKeyBytes(keyBuffer[:0]), batch)
+		if err != nil {
+			return nil, err
+		}
+
+		edges = append(edges, edge)
+		if limit > 0 && uint64(len(edges)) >= limit {
+			break
+		}
+	}
+
+	return edges, nil
+}
+
+// Neighbors returns up to the limit of the nodes connected to the node by the
+// edges in given direction. The limit of 0 returns all the neighbors.
+func Neighbors[N any](ctx context.Context, edges *EdgeTable[N, N], node N, dir EdgeDirection, limit uint64, optBatch ...Batch) ([]N, error) {
+	var (
+		neighborEdges []*Edge[N, N]
+		err           error
+	)
+	if dir == EdgeDirectionOutgoing {
+		neighborEdges, err = edges.Outgoing(ctx, node, limit, optBatch...)
+	} else {
+		neighborEdges, err = edges.Incoming(ctx, node, limit, optBatch...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	neighbors := make([]N, 0, len(neighborEdges))
+	for _, edge := range neighborEdges {
+		if dir == EdgeDirectionOutgoing {
+			neighbors = append(neighbors, edge.To)
+		} else {
+			neighbors = append(neighbors, edge.From)
+		}
+	}
+	return neighbors, nil
+}
+
+// Traverse visits the nodes reachable from the start node in the breadth-first
+// order, up to maxDepth edges away. Every node is visited once, the start node
+// is not visited. The traversal stops when the visit returns false.
+func Traverse[N any](ctx context.Context, edges *EdgeTable[N, N], start N, dir EdgeDirection, maxDepth int, visit func(node N, depth int) (bool, error), optBatch ...Batch) error {
+	nodeKey := func(node N) string {
+		return string(edges.fromKeyFunc(NewKeyBuilder([]byte{}), node))
+	}
+
+	visited := map[string]struct{}{nodeKey(start): {}}
+	frontier := []N{start}
+	for depth := 1; depth <= maxDepth && len(frontier) > 0; depth++ {
+		var next []N
+		for _, node := range frontier {
+			neighbors, err := Neighbors(ctx, edges, node, dir, 0, optBatch...)
+			if err != nil {
+				return err
+			}
+
+			for _, neighbor := range neighbors {
+				key := nodeKey(neighbor)
+				if _, ok := visited[key]; ok {
+					continue
+				}
+				visited[key] = struct{}{}
+
+				cont, err := visit(neighbor, depth)
+				if err != nil || !cont {
+					return err
+				}
+
+				next = append(next, neighbor)
+			}
+		}
+		frontier = next
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_EdgeTable(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TransferEdgeTableID = TableID(1)
+	)
+
+	addressKeyFunc := func(builder KeyBuilder, address string) []byte {
+		return builder.AddEscapedStringField(address).Bytes()
+	}
+
+	transfers := NewEdgeTable[string, string](EdgeTableOptions[string, string]{
+		DB:          db,
+		TableID:     TransferEdgeTableID,
+		TableName:   "transfer_edge",
+		FromKeyFunc: addressKeyFunc,
+		ToKeyFunc:   addressKeyFunc,
+	})
+
+	err := transfers.AddEdges(context.Background(), []*Edge[string, string]{
+		{From: "0xa", To: "0xb"},
+		{From: "0xa", To: "0xc"},
+		{From: "0xb", To: "0xc"},
+		{From: "0xc", To: "0xd"},
+		{From: "0xd", To: "0xa"},
+	})
+	require.NoError(t, err)
+
+	edges, err := transfers.Outgoing(context.Background(), "0xa", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []*Edge[string, string]{{From: "0xa", To: "0xb"}, {From: "0xa", To: "0xc"}}, edges)
+
+	edges, err = transfers.Incoming(context.Background(), "0xc", 1)
+	require.NoError(t, err)
+	assert.Equal(t, []*Edge[string, string]{{From: "0xa", To: "0xc"}}, edges)
+
+	neighbors, err := Neighbors(context.Background(), transfers, "0xc", EdgeDirectionIncoming, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0xa", "0xb"}, neighbors)
+
+	type visit struct {
+		node  string
+		depth int
+	}
+
+	var visits []visit
+	err = Traverse(context.Background(), transfers, "0xa", EdgeDirectionOutgoing, 2, func(node string, depth int) (bool, error) {
+		visits = append(visits, visit{node, depth})
+		return true, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []visit{{"0xb", 1}, {"0xc", 1}, {"0xd", 2}}, visits)
+
+	err = transfers.RemoveEdges(context.Background(), []*Edge[string, string]{{From: "0xa", To: "0xc"}})
+	require.NoError(t, err)
+
+	neighbors, err = Neighbors(context.Background(), transfers, "0xa", EdgeDirectionOutgoing, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0xb"}, neighbors)
+
+	visits = nil
+	err = Traverse(context.Background(), transfers, "0xa", EdgeDirectionIncoming, 10, func(node string, depth int) (bool, error) {
+		visits = append(visits, visit{node, depth})
+		return true, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []visit{{"0xd", 1}, {"0xc", 2}, {"0xb", 3}}, visits)
+}
@@ -0,0 +1,158 @@
+package bond
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+var (
+	// ErrNotFound is returned when the row does not exist. It's also returned
+	// in place of pebble.ErrNotFound by the key reads of DB and Batch.
+	ErrNotFound = errors.New("not found")
+
+	// ErrKeyExists is returned when the inserted row has the primary key that
+	// already exists in the table or repeats within the inserted rows.
+	ErrKeyExists = errors.New("key already exists")
+
+	// ErrUniqueConstraint is returned when the write violates the uniqueness of
+	// the primary key. It is the same error as ErrKeyExists.
+	ErrUniqueConstraint = ErrKeyExists
+
+	// ErrIndexNotRegistered is returned when the index is used with the table
+	// it was not added to.
+	ErrIndexNotRegistered = errors.New("index not registered")
+
+	// ErrIndexBuilding is returned when the index is used before its build
+	// finishes. The error is IndexBuildingError.
+	ErrIndexBuilding = errors.New("index building")
+
+	// ErrTableIDCollision is returned when the table ID is reserved or already
+	// used by another table.
+	ErrTableIDCollision = errors.New("table id collision")
+
+	// ErrIndexIDCollision is returned when the index ID is reserved or already
+	// used by another index of the table.
+	ErrIndexIDCollision = errors.New("index id collision")
+
+	// ErrChecksumMismatch is returned when the row read from the table with
+	// checksums enabled does not match its checksum.
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+
+	// ErrQueryMemoryLimitExceeded is returned when the rows held by the query
+	// exceed the memory limit set with Query.MemoryLimit.
+	ErrQueryMemoryLimitExceeded = errors.New("query memory limit exceeded")
+
+	// ErrQueryScanLimitExceeded is returned when the query scans more rows
+	// than allowed by Query.MaxScanRows.
+	ErrQueryScanLimitExceeded = errors.New("query scan limit exceeded")
+
+	// ErrQueryDeadlineExceeded is returned when the query runs longer than
+	// allowed by Query.Deadline.
+	ErrQueryDeadlineExceeded = errors.New("query deadline exceeded")
+
+	// ErrQueryOverloaded is returned when the query is not admitted, as too
+	// many queries are running or waiting. The error is QueryOverloadError.
+	ErrQueryOverloaded = errors.New("query overloaded")
+
+	// ErrCallbackPanic is returned when the user provided function, such as
+	// the filter, the order or the index key function, panics.
+	ErrCallbackPanic = errors.New("callback panicked")
+
+	// ErrQueueEmpty is returned when the queue has no message to dequeue.
+	ErrQueueEmpty = errors.New("queue empty")
+
+	// ErrSnapshotNotFound is returned when no retained snapshot was taken at
+	// or before the time the query is executed as of.
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+
+	// ErrSnapshotReadOnly is returned when writing to the snapshot.
+	ErrSnapshotReadOnly = errors.New("snapshot is read only")
+
+	// ErrRateLimited is returned when the write exceeds TableOptions.RateLimit.
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrTableSizeExceeded is returned when the write would make the table
+	// larger than TableOptions.MaxSizeBytes.
+	ErrTableSizeExceeded = errors.New("table size exceeded")
+
+	// ErrInvalidPageToken is returned when the page token passed to Paginate
+	// is malformed or was returned for the different query.
+	ErrInvalidPageToken = errors.New("invalid page token")
+
+	// ErrInvalidExportStream is returned when the stream read by ImportStream
+	// is not the export stream or it's corrupted.
+	ErrInvalidExportStream = errors.New("invalid export stream")
+
+	// ErrVersionMismatch is returned by UpdateIfVersion when the row was
+	// changed since the version was read.
+	ErrVersionMismatch = errors.New("version mismatch")
+
+	// ErrNotSupported is returned by the features the Backend of the database
+	// does not support.
+	ErrNotSupported = errors.New("not supported by backend")
+
+	// ErrIndexOrderNotPreserved is returned when the query with
+	// Query.OrderPreserveIndex can not return the rows in the index order.
+	// The error is IndexOrderError.
+	ErrIndexOrderNotPreserved = errors.New("index order not preserved")
+)
+
+// TableError is the error returned by the table operations. It describes the
+// table, the index and the key the error relates to. The underlying error can
+// be checked with errors.Is, e.g. errors.Is(err, ErrNotFound).
+type TableError struct {
+	Table string
+	Index string
+	Key   []byte
+	Err   error
+}
+
+func (e *TableError) Error() string {
+	msg := fmt.Sprintf("table %s", e.Table)
+	if e.Index != "" {
+		msg += fmt.Sprintf(" index %s", e.Index)
+	}
+	if e.Key != nil {
+		msg += fmt.Sprintf(" key %s", FormatKey(e.Key))
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *TableError) Unwrap() error {
+	return e.Err
+}
+
+// newError wraps the error with the table, the index and the key. The index
+// may be nil for the errors that relate to the row itself.
+func (t *_table[T]) newError(idx *Index[T], key []byte, err error) error {
+	var indexName string
+	if idx != nil {
+		indexName = idx.IndexName
+	}
+
+	var keyCopy []byte
+	if key != nil {
+		keyCopy = append([]byte{}, key...)
+	}
+
+	return &TableError{Table: t.name, Index: indexName, Key: keyCopy, Err: err}
+}
+
+// recoverPanic converts the panic of the user provided callback into the
+// error with the index and the key the callback was called for. It has to be
+// deferred directly by the function calling the callback.
+func (t *_table[T]) recoverPanic(err *error, idx *Index[T], key []byte, callback string) {
+	if r := recover(); r != nil {
+		*err = t.newError(idx, key, fmt.Errorf("%w: %s: %v", ErrCallbackPanic, callback, r))
+	}
+}
+
+// notFound translates pebble.ErrNotFound to ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, pebble.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,190 @@
+package bond
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_TableErrors(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	tokenBalance := &TokenBalance{ID: 1, AccountAddress: "0xtestAccount", Balance: 5}
+
+	_, err := tokenBalanceTable.Get(tokenBalance)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	var tableErr *TableError
+	require.True(t, errors.As(err, &tableErr))
+	assert.Equal(t, "token_balance", tableErr.Table)
+	assert.Equal(t, tokenBalanceTable.(*_table[*TokenBalance]).key(tokenBalance, make([]byte, 0, DataKeyBufferSize)), tableErr.Key)
+
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{tokenBalance})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.ErrorIs(t, err, ErrKeyExists)
+	require.ErrorIs(t, err, ErrUniqueConstraint)
+
+	var tokenBalances []*TokenBalance
+	err = tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, tokenBalance).
+		Execute(context.Background(), &tokenBalances)
+	require.ErrorIs(t, err, ErrIndexNotRegistered)
+	require.True(t, errors.As(err, &tableErr))
+	assert.Equal(t, "account_address_idx", tableErr.Index)
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{
+		NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 1,
+			IndexName: "contract_address_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.ContractAddress).Bytes()
+			},
+			IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+		}),
+	})
+	require.ErrorIs(t, err, ErrIndexIDCollision)
+
+	_, err = RegisterTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance_copy",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+	require.ErrorIs(t, err, ErrTableIDCollision)
+}
+
+func TestBond_CallbackPanic(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountAddress: "", Balance: 7},
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	var tableErr *TableError
+	var result []*TokenBalance
+
+	err = tokenBalanceTable.Query().
+		Filter(func(tb *TokenBalance) bool {
+			return tb.AccountAddress[0] == '0'
+		}).
+		Execute(context.Background(), &result)
+	require.ErrorIs(t, err, ErrCallbackPanic)
+	require.True(t, errors.As(err, &tableErr))
+	assert.Equal(t, tokenBalanceTable.(*_table[*TokenBalance]).key(tokenBalances[1], make([]byte, 0, DataKeyBufferSize)), tableErr.Key)
+
+	err = tokenBalanceTable.Query().
+		Order(func(tb, tb2 *TokenBalance) bool {
+			return tb.AccountAddress[0] < tb2.AccountAddress[0]
+		}).
+		Execute(context.Background(), &result)
+	require.ErrorIs(t, err, ErrCallbackPanic)
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{
+		NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 1,
+			IndexName: "account_address_first_letter_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddByteField(tb.AccountAddress[0]).Bytes()
+			},
+			IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+		}),
+	}, true)
+	require.ErrorIs(t, err, ErrCallbackPanic)
+	require.True(t, errors.As(err, &tableErr))
+	assert.Equal(t, tokenBalanceTable.(*_table[*TokenBalance]).key(tokenBalances[1], make([]byte, 0, DataKeyBufferSize)), tableErr.Key)
+}
+
+func TestBond_NotFound(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	tokenBalance := &TokenBalance{ID: 1, AccountAddress: "0xtestAccount", Balance: 5}
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	tb, err := tokenBalanceTable.GetOr(context.Background(), &TokenBalance{ID: 1}, &TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalance, tb)
+
+	tb, err = tokenBalanceTable.GetOr(context.Background(), &TokenBalance{ID: 2}, &TokenBalance{ID: 2, Balance: 7})
+	require.NoError(t, err)
+	assert.Equal(t, &TokenBalance{ID: 2, Balance: 7}, tb)
+
+	// the key reads do not return the pebble errors
+	_, _, err = db.Get([]byte{0xF0, 0x01})
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	batch := db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	_, _, err = db.Get([]byte{0xF0, 0x01}, batch)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	_, err = tokenBalanceTable.Get(&TokenBalance{ID: 2}, batch)
+	assert.True(t, errors.Is(err, ErrNotFound))
+}
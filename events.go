@@ -0,0 +1,102 @@
+package bond
+
+import (
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// WriteStallInfo describes the write stall that began.
+type WriteStallInfo struct {
+	Reason string
+}
+
+// CompactionInfo describes the finished compaction.
+type CompactionInfo struct {
+	JobID       int
+	Reason      string
+	InputLevels []int
+	OutputLevel int
+	OutputBytes uint64
+	Duration    time.Duration
+	Err         error
+}
+
+// FlushInfo describes the finished flush of the memtables.
+type FlushInfo struct {
+	JobID       int
+	Reason      string
+	Memtables   int
+	OutputBytes uint64
+	Duration    time.Duration
+	Err         error
+}
+
+// IndexBuildProgress describes the progress of the index build started with
+// Table.AddIndex. It's reported after every committed reindex batch and once
+// the build is done.
+type IndexBuildProgress struct {
+	TableID   TableID
+	TableName string
+	IndexIDs  []IndexID
+	Rows      uint64
+	Done      bool
+}
+
+// eventListener returns the pebble listener that calls the event callbacks
+// of the options.
+func eventListener(opts *Options) pebble.EventListener {
+	listener := pebble.EventListener{}
+	if opts.OnWriteStallBegin != nil {
+		listener.WriteStallBegin = func(info pebble.WriteStallBeginInfo) {
+			opts.OnWriteStallBegin(WriteStallInfo{Reason: info.Reason})
+		}
+	}
+
+	if opts.OnWriteStallEnd != nil {
+		listener.WriteStallEnd = opts.OnWriteStallEnd
+	}
+
+	if opts.OnCompaction != nil {
+		listener.CompactionEnd = func(info pebble.CompactionInfo) {
+			compactionInfo := CompactionInfo{
+				JobID:       info.JobID,
+				Reason:      info.Reason,
+				OutputLevel: info.Output.Level,
+				Duration:    info.TotalDuration,
+				Err:         info.Err,
+			}
+			for _, input := range info.Input {
+				compactionInfo.InputLevels = append(compactionInfo.InputLevels, input.Level)
+			}
+			for _, table := range info.Output.Tables {
+				compactionInfo.OutputBytes += table.Size
+			}
+			opts.OnCompaction(compactionInfo)
+		}
+	}
+
+	if opts.OnFlush != nil {
+		listener.FlushEnd = func(info pebble.FlushInfo) {
+			flushInfo := FlushInfo{
+				JobID:     info.JobID,
+				Reason:    info.Reason,
+				Memtables: info.Input,
+				Duration:  info.TotalDuration,
+				Err:       info.Err,
+			}
+			for _, table := range info.Output {
+				flushInfo.OutputBytes += table.Size
+			}
+			opts.OnFlush(flushInfo)
+		}
+	}
+	return listener
+}
+
+// notifyIndexBuildProgress calls Options.OnIndexBuildProgress.
+func (t *_table[T]) notifyIndexBuildProgress(progress IndexBuildProgress) {
+	if db, ok := t.db.(*_db); ok && db.onIndexBuildProgress != nil {
+		db.onIndexBuildProgress(progress)
+	}
+}
@@ -0,0 +1,95 @@
+package bond
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_EventCallbacks(t *testing.T) {
+	var (
+		mutex       sync.Mutex
+		flushes     []FlushInfo
+		compactions []CompactionInfo
+		progresses  []IndexBuildProgress
+	)
+
+	db, err := Open(dbName, &Options{
+		OnFlush: func(info FlushInfo) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			flushes = append(flushes, info)
+		},
+		OnCompaction: func(info CompactionInfo) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			compactions = append(compactions, info)
+		},
+		OnIndexBuildProgress: func(progress IndexBuildProgress) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			progresses = append(progresses, progress)
+		},
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{ID: uint64(i), AccountAddress: "0xtestAccount"})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	AccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{AccountAddressIndex}, true)
+	require.NoError(t, err)
+
+	pdb := db.(*_db).pebble
+	require.NoError(t, pdb.Flush())
+	require.NoError(t, pdb.Compact([]byte{0x01}, []byte{0x02}, false))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	require.Len(t, progresses, 1)
+	assert.Equal(t, IndexBuildProgress{
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		IndexIDs:  []IndexID{PrimaryIndexID + 1},
+		Rows:      10,
+		Done:      true,
+	}, progresses[0])
+
+	require.NotEmpty(t, flushes)
+	assert.NoError(t, flushes[0].Err)
+	assert.Greater(t, flushes[0].OutputBytes, uint64(0))
+
+	require.NotEmpty(t, compactions)
+	assert.NoError(t, compactions[0].Err)
+}
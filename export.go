@@ -0,0 +1,476 @@
+package bond
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sort"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// DefaultImportBatchSize is the number of keys written with one batch by
+// ImportStream.
+const DefaultImportBatchSize = 1000
+
+// exportMagic starts the export stream, the last byte is the format version.
+var exportMagic = []byte{'B', 'O', 'N', 'D', 'E', 'X', 'P', 1}
+
+// Exporter writes the tables to the stream.
+type Exporter interface {
+	Export(ctx context.Context, w io.Writer, opt ExportOptions) error
+}
+
+// ExportOptions are the options of Export.
+type ExportOptions struct {
+	// Tables are the IDs of the exported tables, all the tables of the
+	// catalog if empty.
+	Tables []TableID
+	// Snapshot exports the tables from the snapshot of the database, so the
+	// rows of all the tables are consistent with each other.
+	Snapshot bool
+}
+
+// ImportOptions are the options of ImportStream.
+type ImportOptions struct {
+	// BatchSize is the number of keys written with one batch, the
+	// DefaultImportBatchSize if not set.
+	BatchSize int
+}
+
+// _exportSchema is the schema of the exported tables.
+type _exportSchema struct {
+	Tables []_catalogTable `json:"tables"`
+}
+
+// Export writes the tables to the stream that is imported with ImportStream.
+// The stream is self-describing: it starts with the schema of the tables, as
+// persisted in the catalog, followed by the keys and the values of the rows,
+// the index entries and the bond data entries of the tables, and it ends with
+// the number of the keys and their checksum.
+//
+// Example:
+//
+//	f, err := os.Create("production.bond")
+//	...
+//	err = db.Export(ctx, f, bond.ExportOptions{Tables: []bond.TableID{TokenBalanceTableID}, Snapshot: true})
+func (db *_db) Export(ctx context.Context, w io.Writer, opt ExportOptions) error {
+	schema, err := db.exportSchema(opt.Tables)
+	if err != nil {
+		return err
+	}
+
+	var reader BackendReader = db.backend()
+	if opt.Snapshot {
+		snapshot := db.backend().Snapshot()
+		defer func() {
+			_ = snapshot.Close()
+		}()
+		reader = snapshot
+	}
+
+	bw := bufio.NewWriter(w)
+	writer := &_exportWriter{w: bw, hash: crc32.NewIEEE()}
+
+	_, err = bw.Write(exportMagic)
+	if err != nil {
+		return err
+	}
+
+	// the stream has the keys of the tables under their IDs
+	storageIDs := make(map[TableID]TableID, len(schema.Tables))
+	for i := range schema.Tables {
+		storageIDs[schema.Tables[i].ID] = schema.Tables[i].storage()
+		schema.Tables[i].StorageID = 0
+	}
+
+	schemaData, err := json.Marshal(schema)
+	if err != nil {
+		return err
+	}
+
+	err = writer.writeBytes(schemaData)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range schema.Tables {
+		for _, prefix := range tableDataPrefixes(storageIDs[table.ID]) {
+			iter := reader.Iter(&IterOptions{
+				IterOptions: pebble.IterOptions{
+					LowerBound: prefix,
+					UpperBound: prefixUpperBound(prefix),
+				},
+			})
+
+			for iter.First(); iter.Valid(); iter.Next() {
+				select {
+				case <-ctx.Done():
+					_ = iter.Close()
+					return fmt.Errorf("context done: %w", ctx.Err())
+				default:
+				}
+
+				err = writer.writeRecord(tableKeyWithID(iter.Key(), table.ID), iter.Value())
+				if err != nil {
+					_ = iter.Close()
+					return err
+				}
+			}
+
+			err = iter.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err = writer.writeTrailer()
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// exportSchema returns the catalog entries of the tables.
+func (db *_db) exportSchema(tableIDs []TableID) (*_exportSchema, error) {
+	db.catalog.mutex.Lock()
+	defer db.catalog.mutex.Unlock()
+
+	if len(tableIDs) == 0 {
+		for _, table := range db.catalog.sortedPersisted() {
+			tableIDs = append(tableIDs, table.ID)
+		}
+	}
+
+	schema := &_exportSchema{}
+	for _, tableID := range tableIDs {
+		table, ok := db.catalog.persisted[tableID]
+		if !ok {
+			return nil, fmt.Errorf("export: table 0x%02x is not in catalog", tableID)
+		}
+		schema.Tables = append(schema.Tables, *table)
+	}
+
+	sort.Slice(schema.Tables, func(i, j int) bool {
+		return schema.Tables[i].ID < schema.Tables[j].ID
+	})
+	return schema, nil
+}
+
+// ImportStream writes the tables of the stream written by Export to the
+// database. The tables must not exist in the database, or they must have the
+// same name and keys and no rows. The stream is verified as it's read, the
+// keys imported before the verification fails are not removed.
+func ImportStream(ctx context.Context, db DB, r io.Reader, opt ImportOptions) error {
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = DefaultImportBatchSize
+	}
+
+	d, ok := db.(*_db)
+	if !ok {
+		return fmt.Errorf("import: unsupported database")
+	}
+
+	reader := &_exportReader{r: bufio.NewReader(r), hash: crc32.NewIEEE()}
+
+	magic := make([]byte, len(exportMagic))
+	_, err := io.ReadFull(reader.r, magic)
+	if err != nil || !bytes.Equal(magic, exportMagic) {
+		return fmt.Errorf("%w: unknown format", ErrInvalidExportStream)
+	}
+
+	schemaData, err := reader.readBytes()
+	if err != nil {
+		return err
+	}
+
+	var schema _exportSchema
+	err = json.Unmarshal(schemaData, &schema)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidExportStream, err)
+	}
+
+	tables := make(map[TableID]bool)
+	for _, table := range schema.Tables {
+		err = d.importCheckTable(table)
+		if err != nil {
+			return err
+		}
+		tables[table.ID] = true
+	}
+
+	// the tables replaced with ReplaceFrom store their keys under the IDs of
+	// the staging tables
+	storageIDs := make(map[TableID]TableID)
+	for _, table := range schema.Tables {
+		storageIDs[table.ID] = d.catalog.storageID(table.ID)
+	}
+
+	batch := db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	var keys int
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		key, value, ok, err := reader.readRecord()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		if !importKeyOfTables(key, tables) {
+			return fmt.Errorf("%w: key %s does not belong to the exported tables", ErrInvalidExportStream, FormatKey(key))
+		}
+
+		err = batch.Set(tableKeyWithID(key, storageIDs[tableOfKey(key)]), value, Sync)
+		if err != nil {
+			return err
+		}
+
+		keys++
+		if keys%opt.BatchSize == 0 {
+			err = batch.Commit(Sync)
+			if err != nil {
+				return err
+			}
+			_ = batch.Close()
+			batch = db.Batch()
+		}
+	}
+
+	err = reader.readTrailer()
+	if err != nil {
+		return err
+	}
+
+	err = batch.Commit(Sync)
+	if err != nil {
+		return err
+	}
+
+	d.catalog.mutex.Lock()
+	defer d.catalog.mutex.Unlock()
+
+	for i := range schema.Tables {
+		table := schema.Tables[i]
+		if _, ok := d.catalog.persisted[table.ID]; ok {
+			continue
+		}
+
+		err = d.catalog.persist(&table)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importCheckTable returns the error if the table can not be imported to the
+// database.
+func (db *_db) importCheckTable(table _catalogTable) error {
+	if table.ID == BOND_DB_DATA_TABLE_ID {
+		return fmt.Errorf("%w: table %d is reserved", ErrInvalidExportStream, table.ID)
+	}
+
+	db.catalog.mutex.Lock()
+	persisted, ok := db.catalog.persisted[table.ID]
+	db.catalog.mutex.Unlock()
+
+	if ok && (persisted.Name != table.Name || fingerprintsDiffer(persisted.Fingerprint, table.Fingerprint)) {
+		return fmt.Errorf("import: table 0x%02x %q does not match table %q of the database",
+			table.ID, table.Name, persisted.Name)
+	}
+
+	if storedID := db.catalog.tableOfStorage(table.ID); !ok && storedID != table.ID {
+		return fmt.Errorf("import: table id 0x%02x of %q is used to store table 0x%02x: %w",
+			table.ID, table.Name, storedID, ErrTableIDCollision)
+	}
+
+	storageID := db.catalog.storageID(table.ID)
+	iter := db.backend().Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{byte(storageID)},
+			UpperBound: prefixUpperBound([]byte{byte(storageID)}),
+		},
+	})
+	hasRows := iter.First()
+	err := iter.Close()
+	if err != nil {
+		return err
+	}
+
+	if hasRows {
+		return fmt.Errorf("import: table 0x%02x %q already has rows", table.ID, table.Name)
+	}
+	return nil
+}
+
+// tableOfKey returns the ID of the table the key of its rows, index entries or
+// bond data belongs to.
+func tableOfKey(key []byte) TableID {
+	if key[0] == BOND_DB_DATA_TABLE_ID {
+		return TableID(key[2])
+	}
+	return TableID(key[0])
+}
+
+// tableKeyWithID returns the key of the table rows, index entries or bond data
+// with the table ID replaced.
+func tableKeyWithID(key []byte, id TableID) []byte {
+	pos := 0
+	if key[0] == BOND_DB_DATA_TABLE_ID {
+		pos = 2
+	}
+	if TableID(key[pos]) == id {
+		return key
+	}
+
+	key = append([]byte{}, key...)
+	key[pos] = byte(id)
+	return key
+}
+
+// importKeyOfTables returns true if the key belongs to the imported tables.
+func importKeyOfTables(key []byte, tables map[TableID]bool) bool {
+	if len(key) == 0 {
+		return false
+	}
+
+	if key[0] != BOND_DB_DATA_TABLE_ID {
+		return tables[TableID(key[0])]
+	}
+
+	if len(key) < 3 || !tables[TableID(key[2])] {
+		return false
+	}
+	return bytes.IndexByte(tableBondDataIndexIDs, key[1]) >= 0
+}
+
+// _exportWriter writes the records of the export stream. Every record is the
+// key and the value prefixed with their lengths, the empty key ends them.
+type _exportWriter struct {
+	w       io.Writer
+	hash    hashWriter
+	records uint64
+	buffer  [binary.MaxVarintLen64]byte
+}
+
+type hashWriter interface {
+	io.Writer
+	Sum32() uint32
+}
+
+func (e *_exportWriter) writeBytes(data []byte) error {
+	n := binary.PutUvarint(e.buffer[:], uint64(len(data)))
+	_, err := e.w.Write(e.buffer[:n])
+	if err != nil {
+		return err
+	}
+	_, _ = e.hash.Write(e.buffer[:n])
+
+	_, err = e.w.Write(data)
+	if err != nil {
+		return err
+	}
+	_, _ = e.hash.Write(data)
+	return nil
+}
+
+func (e *_exportWriter) writeRecord(key, value []byte) error {
+	err := e.writeBytes(key)
+	if err != nil {
+		return err
+	}
+
+	e.records++
+	return e.writeBytes(value)
+}
+
+// writeTrailer ends the records and writes the number of the records and
+// the checksum of the stream.
+func (e *_exportWriter) writeTrailer() error {
+	err := e.writeBytes(nil)
+	if err != nil {
+		return err
+	}
+
+	var trailer [12]byte
+	binary.BigEndian.PutUint64(trailer[:8], e.records)
+	binary.BigEndian.PutUint32(trailer[8:], e.hash.Sum32())
+	_, err = e.w.Write(trailer[:])
+	return err
+}
+
+type _exportReader struct {
+	r       *bufio.Reader
+	hash    hashWriter
+	records uint64
+	buffer  [binary.MaxVarintLen64]byte
+}
+
+func (e *_exportReader) readBytes() ([]byte, error) {
+	length, err := binary.ReadUvarint(e.r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidExportStream, err)
+	}
+
+	n := binary.PutUvarint(e.buffer[:], length)
+	_, _ = e.hash.Write(e.buffer[:n])
+
+	if length > uint64(e.r.Size())<<16 {
+		return nil, fmt.Errorf("%w: record of %d bytes", ErrInvalidExportStream, length)
+	}
+
+	data := make([]byte, length)
+	_, err = io.ReadFull(e.r, data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidExportStream, err)
+	}
+	_, _ = e.hash.Write(data)
+	return data, nil
+}
+
+// readRecord returns false once the records end.
+func (e *_exportReader) readRecord() ([]byte, []byte, bool, error) {
+	key, err := e.readBytes()
+	if err != nil || len(key) == 0 {
+		return nil, nil, false, err
+	}
+
+	value, err := e.readBytes()
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	e.records++
+	return key, value, true, nil
+}
+
+// readTrailer verifies the number of the records and the checksum.
+func (e *_exportReader) readTrailer() error {
+	var trailer [12]byte
+	_, err := io.ReadFull(e.r, trailer[:])
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidExportStream, err)
+	}
+
+	if binary.BigEndian.Uint64(trailer[:8]) != e.records || binary.BigEndian.Uint32(trailer[8:]) != e.hash.Sum32() {
+		return fmt.Errorf("%w: checksum mismatch", ErrInvalidExportStream)
+	}
+	return nil
+}
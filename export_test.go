@@ -0,0 +1,96 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Export_ImportStream(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	newTokenBalanceTable := func(db DB) Table[*TokenBalance] {
+		return NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   TableID(1),
+			TableName: "token_balance",
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+		})
+	}
+
+	accountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	tokenBalanceTable := newTokenBalanceTable(db)
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{accountAddressIndex})
+	require.NoError(t, err)
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountID: 2, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount2", Balance: 7},
+		{ID: 3, AccountID: 1, ContractAddress: "0xtestContract2", AccountAddress: "0xtestAccount", Balance: 15},
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	var stream bytes.Buffer
+	err = db.Export(context.Background(), &stream, ExportOptions{Tables: []TableID{TableID(1)}, Snapshot: true})
+	require.NoError(t, err)
+
+	const importDBName = "test_db_import"
+
+	importDB, err := Open(importDBName, &Options{})
+	require.NoError(t, err)
+	defer func() {
+		_ = importDB.Close()
+		_ = os.RemoveAll(importDBName)
+	}()
+
+	// the corrupted stream is rejected
+	corrupted := append([]byte{}, stream.Bytes()...)
+	corrupted[len(corrupted)-20] ^= 0xFF
+	err = ImportStream(context.Background(), importDB, bytes.NewReader(corrupted), ImportOptions{BatchSize: 2})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalidExportStream)
+
+	// the keys imported before the corruption was detected are removed
+	err = importDB.DeleteRange([]byte{0x01}, []byte{0x02}, Sync)
+	require.NoError(t, err)
+
+	err = ImportStream(context.Background(), importDB, bytes.NewReader(stream.Bytes()), ImportOptions{BatchSize: 2})
+	require.NoError(t, err)
+
+	importedTable := newTokenBalanceTable(importDB)
+	err = importedTable.AddIndex([]*Index[*TokenBalance]{accountAddressIndex})
+	require.NoError(t, err)
+
+	var imported []*TokenBalance
+	err = importedTable.Query().Execute(context.Background(), &imported)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances, imported)
+
+	// the index entries are imported with the rows
+	err = importedTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Execute(context.Background(), &imported)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalances[0], tokenBalances[2]}, imported)
+
+	// the table with rows is not overwritten
+	err = ImportStream(context.Background(), importDB, bytes.NewReader(stream.Bytes()), ImportOptions{})
+	require.Error(t, err)
+}
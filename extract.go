@@ -0,0 +1,158 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"path/filepath"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// TableExtractor creates the new stores with the subset of the tables.
+type TableExtractor interface {
+	// ExtractTables creates the store in destDir that contains only the rows,
+	// the index entries and the catalog entries of the given tables.
+	ExtractTables(ctx context.Context, destDir string, tableIDs ...TableID) error
+}
+
+// ExtractTables creates the checkpoint of the store in destDir, which shares the
+// sstables with the store if the file system supports hard links. The other
+// tables and the bond data entries kept for them are then removed from the checkpoint
+// with the range deletions and compacted away.
+func (db *_db) ExtractTables(ctx context.Context, destDir string, tableIDs ...TableID) error {
+	if len(tableIDs) == 0 {
+		return fmt.Errorf("extract: no tables")
+	}
+
+	selected := map[TableID]bool{}
+	for _, tableID := range tableIDs {
+		if tableID == TableID(BOND_DB_DATA_TABLE_ID) {
+			return fmt.Errorf("extract: table %d is reserved", tableID)
+		}
+		selected[tableID] = true
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	// the checkpoint syncs the parent directory, which needs to be explicit
+	destDir, err := filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+
+	source, release, err := db.pebbleDB()
+	if err != nil {
+		return fmt.Errorf("extract: %w", err)
+	}
+
+	err = source.Checkpoint(destDir, pebble.WithFlushedWAL())
+	release()
+	if err != nil {
+		return fmt.Errorf("extract: failed to create checkpoint: %w", err)
+	}
+
+	pdb, err := pebble.Open(destDir, &pebble.Options{Comparer: DefaultKeyComparer(), Merger: newMerger(nil)})
+	if err != nil {
+		return fmt.Errorf("extract: failed to open checkpoint: %w", err)
+	}
+
+	err = extractRemoveTables(ctx, pdb, selected, db.catalog.tableOfStorage)
+	if err != nil {
+		_ = pdb.Close()
+		return err
+	}
+	return pdb.Close()
+}
+
+// tableBondDataIndexIDs are the bond data indexes that keep the entries of the
+// tables under the table ID.
+var tableBondDataIndexIDs = []byte{
+	BOND_DB_DATA_INDEX_SKETCH_INDEX_ID,
+	BOND_DB_DATA_INDEX_STATS_INDEX_ID,
+	BOND_DB_DATA_INDEX_REFERENCE_INDEX_ID,
+	BOND_DB_DATA_COLUMN_GROUP_INDEX_ID,
+	BOND_DB_DATA_DICTIONARY_INDEX_ID,
+	BOND_DB_DATA_INDEX_BUILD_INDEX_ID,
+	BOND_DB_DATA_ROW_VERSION_INDEX_ID,
+	BOND_DB_DATA_ARCHIVE_STUB_INDEX_ID,
+	BOND_DB_DATA_AGGREGATE_INDEX_ID,
+	BOND_DB_DATA_OVERFLOW_INDEX_ID,
+}
+
+// tableDataPrefixes returns the prefixes of the keys of the table: its rows and
+// index entries and the bond data entries kept for the table.
+func tableDataPrefixes(tableID TableID) [][]byte {
+	prefixes := [][]byte{{byte(tableID)}}
+	for _, indexID := range tableBondDataIndexIDs {
+		prefixes = append(prefixes, []byte{BOND_DB_DATA_TABLE_ID, indexID, byte(tableID)})
+	}
+	return prefixes
+}
+
+// extractRemoveTables removes the tables that are not selected. The keys
+// stored under the table ID belong to the table returned by tableOfStorage.
+func extractRemoveTables(ctx context.Context, pdb *pebble.DB, selected map[TableID]bool, tableOfStorage func(storageID TableID) TableID) error {
+	batch := pdb.NewBatch()
+	defer func() { _ = batch.Close() }()
+
+	for tableID := TableID(BOND_DB_DATA_TABLE_ID + 1); ; tableID++ {
+		if !selected[tableOfStorage(tableID)] {
+			for _, prefix := range tableDataPrefixes(tableID) {
+				err := extractDeleteRange(pdb, batch, prefix)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if !selected[tableID] {
+			err := batch.Delete(catalogKey(tableID), nil)
+			if err != nil {
+				return err
+			}
+		}
+
+		if tableID == math.MaxUint8 {
+			break
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	err := batch.Commit(pebble.Sync)
+	if err != nil {
+		return err
+	}
+
+	return pdb.Compact([]byte{BOND_DB_DATA_TABLE_ID}, []byte{math.MaxUint8, math.MaxUint8}, true)
+}
+
+// extractDeleteRange deletes the keys with the prefix. The prefixes that end
+// with 0xFF have no successor, so their keys are deleted one by one.
+func extractDeleteRange(pdb *pebble.DB, batch *pebble.Batch, prefix []byte) error {
+	if prefix[len(prefix)-1] != math.MaxUint8 {
+		end := append([]byte{}, prefix...)
+		end[len(end)-1]++
+		return batch.DeleteRange(prefix, end, nil)
+	}
+
+	iter := pdb.NewIter(&pebble.IterOptions{LowerBound: prefix})
+	for iter.First(); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); iter.Next() {
+		err := batch.Delete(iter.Key(), nil)
+		if err != nil {
+			_ = iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
+}
@@ -0,0 +1,70 @@
+package bond
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_ExtractTables(t *testing.T) {
+	const extractDBName = "test_db_extract"
+
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+	defer func() { _ = os.RemoveAll(extractDBName) }()
+
+	newTables := func(db DB) (Table[*TokenBalance], Table[*TokenBalance]) {
+		var tables []Table[*TokenBalance]
+		for _, tableID := range []TableID{1, 2} {
+			tables = append(tables, NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+				DB:        db,
+				TableID:   tableID,
+				TableName: "token_balance_" + string(rune('0'+tableID)),
+				TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+					return builder.AddUint64Field(tb.ID).Bytes()
+				},
+			}))
+		}
+		return tables[0], tables[1]
+	}
+
+	table1, table2 := newTables(db)
+
+	err := table1.Insert(context.Background(), []*TokenBalance{{ID: 1, Balance: 1}, {ID: 2, Balance: 2}})
+	require.NoError(t, err)
+
+	err = table2.Insert(context.Background(), []*TokenBalance{{ID: 3, Balance: 3}})
+	require.NoError(t, err)
+
+	err = db.ExtractTables(context.Background(), extractDBName, 1)
+	require.NoError(t, err)
+
+	// the checkpoint directory must not exist
+	err = db.ExtractTables(context.Background(), extractDBName, 1)
+	require.Error(t, err)
+
+	extracted, err := Open(extractDBName, &Options{})
+	require.NoError(t, err)
+	defer func() { _ = extracted.Close() }()
+
+	extractedTable1, extractedTable2 := newTables(extracted)
+
+	var trs []*TokenBalance
+	err = extractedTable1.Scan(context.Background(), &trs)
+	require.NoError(t, err)
+	assert.Len(t, trs, 2)
+
+	trs = nil
+	err = extractedTable2.Scan(context.Background(), &trs)
+	require.NoError(t, err)
+	assert.Len(t, trs, 0)
+
+	// the source store is intact
+	trs = nil
+	err = table2.Scan(context.Background(), &trs)
+	require.NoError(t, err)
+	assert.Len(t, trs, 1)
+}
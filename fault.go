@@ -0,0 +1,58 @@
+package bond
+
+// FaultPoint is the point of the write the fault is injected at.
+type FaultPoint string
+
+const (
+	// FaultPointPostSerialize is after the row is serialized and written to
+	// the batch, before its index entries are.
+	FaultPointPostSerialize FaultPoint = "post_serialize"
+
+	// FaultPointIndexUpdate is before the index entry of the row is written
+	// to the batch, so the fault leaves the row with some of its index
+	// entries written to the batch provided to the write.
+	FaultPointIndexUpdate FaultPoint = "index_update"
+
+	// FaultPointPreCommit is before the batch is committed.
+	FaultPointPreCommit FaultPoint = "pre_commit"
+)
+
+// Fault describes the point the fault may be injected at. The Table is empty
+// and the Key is nil at the FaultPointPreCommit. The Key is the key of the row,
+// or of the index entry at the FaultPointIndexUpdate.
+type Fault struct {
+	Point FaultPoint
+	Table string
+	Key   []byte
+}
+
+// FaultInjector is called at every fault point of the writes. The error it
+// returns fails the write at that point, the latency is injected by sleeping
+// before it returns. It's meant for the tests of the recovery logic of the
+// applications, e.g. of the batches that are partially written when the
+// write fails.
+//
+// Example:
+//
+//	db, err := bond.Open(dir, &bond.Options{
+//		FaultInjector: func(fault bond.Fault) error {
+//			if fault.Point == bond.FaultPointIndexUpdate && rand.Intn(100) == 0 {
+//				return errInjected
+//			}
+//			return nil
+//		},
+//	})
+type FaultInjector func(fault Fault) error
+
+// injectFault calls the fault injector of the database if it's set.
+func (t *_table[T]) injectFault(point FaultPoint, key []byte) error {
+	if t.faultInjector == nil {
+		return nil
+	}
+
+	err := t.faultInjector(Fault{Point: point, Table: t.name, Key: key})
+	if err != nil {
+		return t.newError(nil, key, err)
+	}
+	return nil
+}
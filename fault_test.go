@@ -0,0 +1,107 @@
+package bond
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_FaultInjector(t *testing.T) {
+	var (
+		mutex  sync.Mutex
+		faults = map[FaultPoint]error{}
+		delay  time.Duration
+		seen   []Fault
+	)
+	errInjected := errors.New("injected")
+
+	db, err := Open(dbName, &Options{
+		FaultInjector: func(fault Fault) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			seen = append(seen, fault)
+			if fault.Point == FaultPointPostSerialize {
+				time.Sleep(delay)
+			}
+			return faults[fault.Point]
+		},
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	setFault := func(point FaultPoint, err error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		faults = map[FaultPoint]error{point: err}
+		seen = nil
+	}
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	accountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+	require.NoError(t, tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{accountAddressIndex}))
+
+	tb := &TokenBalance{ID: 1, AccountAddress: "0xtestAccount", Balance: 5}
+
+	// the batch is left with the row, but without its index entries
+	setFault(FaultPointIndexUpdate, errInjected)
+	batch := db.Batch()
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tb}, batch)
+	require.ErrorIs(t, err, errInjected)
+	assert.True(t, tokenBalanceTable.Exist(tb, batch))
+
+	var tokenBalancesRead []*TokenBalance
+	err = tokenBalanceTable.Query().With(accountAddressIndex, tb).Execute(context.Background(), &tokenBalancesRead, batch)
+	require.NoError(t, err)
+	assert.Len(t, tokenBalancesRead, 0)
+	require.NoError(t, batch.Close())
+
+	// nothing is committed
+	setFault(FaultPointPreCommit, errInjected)
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tb})
+	require.ErrorIs(t, err, errInjected)
+	assert.False(t, tokenBalanceTable.Exist(tb))
+
+	// the latency
+	setFault(FaultPointPostSerialize, nil)
+	mutex.Lock()
+	delay = 20 * time.Millisecond
+	mutex.Unlock()
+
+	start := time.Now()
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tb})
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	mutex.Lock()
+	var points []FaultPoint
+	for _, fault := range seen {
+		points = append(points, fault.Point)
+	}
+	mutex.Unlock()
+	assert.Equal(t, []FaultPoint{FaultPointPostSerialize, FaultPointIndexUpdate, FaultPointPreCommit}, points)
+}
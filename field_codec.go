@@ -0,0 +1,94 @@
+package bond
+
+import (
+	"context"
+)
+
+// FieldCodec encodes the sensitive fields of the rows, e.g. encrypts or masks
+// the PII columns in otherwise shared tables. The rows are encoded before they
+// are serialized and decoded on read only if the caller supplied the key with
+// ContextWithFieldCodecKey.
+//
+// The methods must return the modified copy instead of changing the row in
+// place, as the rows are shared with the callers and the row cache. The encoded
+// fields must not be a part of the primary key or the index keys, which are
+// built from the plain rows.
+type FieldCodec[T any] interface {
+	Encode(tr T) (T, error)
+	Decode(key []byte, tr T) (T, error)
+}
+
+// FieldCodecSerializer encodes the rows with the codec before serializing
+// them. The rows are deserialized with the encoded fields.
+type FieldCodecSerializer[T any] struct {
+	Serializer Serializer[*T]
+	Codec      FieldCodec[T]
+}
+
+func (s *FieldCodecSerializer[T]) Serialize(tr *T) ([]byte, error) {
+	encoded, err := s.Codec.Encode(*tr)
+	if err != nil {
+		return nil, err
+	}
+	return s.Serializer.Serialize(&encoded)
+}
+
+func (s *FieldCodecSerializer[T]) serializeFor(tr *T) ([]byte, func(batch Batch) error, error) {
+	encoded, err := s.Codec.Encode(*tr)
+	if err != nil {
+		return nil, nil, err
+	}
+	return serializeFor(s.Serializer, &encoded)
+}
+
+func (s *FieldCodecSerializer[T]) Deserialize(b []byte, tr *T) error {
+	return s.Serializer.Deserialize(b, tr)
+}
+
+// decodeFields decodes the row with the key from the context. The row is
+// returned as it's stored if the table has no codec or the key is not set.
+func (t *_table[T]) decodeFields(ctx context.Context, tr T) (T, error) {
+	if t.fieldCodec == nil {
+		return tr, nil
+	}
+
+	key := ContextRetrieveFieldCodecKey(ctx)
+	if key == nil {
+		return tr, nil
+	}
+	return t.fieldCodec.Decode(key, tr)
+}
+
+// decodeLazy returns the lazy row that is decoded with the key from the context.
+func (t *_table[T]) decodeLazy(ctx context.Context, lazy Lazy[T]) Lazy[T] {
+	if t.fieldCodec == nil || ContextRetrieveFieldCodecKey(ctx) == nil {
+		return lazy
+	}
+
+	decoded := Lazy[T]{
+		indexValue: lazy.indexValue,
+		GetFunc: func() (T, error) {
+			tr, err := lazy.Get()
+			if err != nil {
+				return tr, err
+			}
+			return t.decodeFields(ctx, tr)
+		},
+	}
+
+	if lazy.GetIntoFunc != nil {
+		decoded.GetIntoFunc = func(tr *T) error {
+			if err := lazy.GetIntoFunc(tr); err != nil {
+				return err
+			}
+
+			decodedTr, err := t.decodeFields(ctx, *tr)
+			if err != nil {
+				return err
+			}
+			*tr = decodedTr
+			return nil
+		}
+	}
+	return decoded
+}
@@ -0,0 +1,88 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAccountAddressCodec struct {
+	key []byte
+}
+
+func (c *testAccountAddressCodec) Encode(tb *TokenBalance) (*TokenBalance, error) {
+	encoded := *tb
+	encoded.AccountAddress = "masked:" + reverse(tb.AccountAddress)
+	return &encoded, nil
+}
+
+func (c *testAccountAddressCodec) Decode(key []byte, tb *TokenBalance) (*TokenBalance, error) {
+	if !bytes.Equal(key, c.key) {
+		return nil, fmt.Errorf("invalid key")
+	}
+
+	decoded := *tb
+	decoded.AccountAddress = reverse(tb.AccountAddress[len("masked:"):])
+	return &decoded, nil
+}
+
+func reverse(s string) string {
+	r := []byte(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+func TestBond_FieldCodec(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		FieldCodec: &testAccountAddressCodec{key: []byte("secret")},
+		CacheSize:  16,
+	})
+
+	tokenBalance := &TokenBalance{ID: 1, AccountAddress: "0xabc", ContractAddress: "0xc1", Balance: 5}
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	// the inserted row is not modified
+	assert.Equal(t, "0xabc", tokenBalance.AccountAddress)
+
+	var result []*TokenBalance
+	err = tokenBalanceTable.Query().Execute(context.Background(), &result)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, "masked:cbax0", result[0].AccountAddress)
+	assert.Equal(t, "0xc1", result[0].ContractAddress)
+
+	ctx := ContextWithFieldCodecKey(context.Background(), []byte("secret"))
+
+	err = tokenBalanceTable.Query().Execute(ctx, &result)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	assert.Equal(t, tokenBalance, result[0])
+
+	trs, err := tokenBalanceTable.GetByKeys(ctx, []PrimaryKey{NewKeyBuilder([]byte{}).AddUint64Field(1).Bytes()})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalance, trs[0])
+
+	// the cached row stays encoded
+	tr, err := tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "masked:cbax0", tr.AccountAddress)
+
+	err = tokenBalanceTable.Query().Execute(ContextWithFieldCodecKey(context.Background(), []byte("wrong")), &result)
+	require.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// HealthReport is the state of the database for the liveness and the
+// readiness probes.
+type HealthReport struct {
+	Open bool
+
+	WriteStalled     bool
+	WriteStallReason string
+
+	CompactionDebt        uint64
+	CompactionsInProgress int64
+
+	WALSize uint64
+
+	LastWrite time.Time
+	LastRead  time.Time
+}
+
+// Healthy returns true if the database is open and the writes are not stalled.
+func (r HealthReport) Healthy() bool {
+	return r.Open && !r.WriteStalled
+}
+
+// HealthChecker reports the health of the database.
+type HealthChecker interface {
+	// Health reads from the database and returns the report of its state. The
+	// error is returned if the database can not be read.
+	Health(ctx context.Context) (HealthReport, error)
+}
+
+// _health tracks the state reported by Health. The fields are accessed
+// atomically.
+type _health struct {
+	lastWrite int64
+	lastRead  int64
+
+	closed           int32
+	writeStalled     int32
+	writeStallReason atomic.Value
+}
+
+// eventListener returns the listener that tracks the write stalls.
+func (h *_health) eventListener() pebble.EventListener {
+	return pebble.EventListener{
+		WriteStallBegin: func(info pebble.WriteStallBeginInfo) {
+			h.writeStallReason.Store(info.Reason)
+			atomic.StoreInt32(&h.writeStalled, 1)
+		},
+		WriteStallEnd: func() {
+			atomic.StoreInt32(&h.writeStalled, 0)
+		},
+	}
+}
+
+func (h *_health) written() {
+	atomic.StoreInt64(&h.lastWrite, time.Now().UnixNano())
+}
+
+func (h *_health) read() {
+	atomic.StoreInt64(&h.lastRead, time.Now().UnixNano())
+}
+
+func (db *_db) Health(ctx context.Context) (HealthReport, error) {
+	h := db.health
+	if atomic.LoadInt32(&h.closed) == 1 {
+		return HealthReport{}, fmt.Errorf("database closed")
+	}
+
+	select {
+	case <-ctx.Done():
+		return HealthReport{}, fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	// the probe read
+	_, closer, err := db.Get(bondDataVersionKey())
+	if err != nil {
+		return HealthReport{}, fmt.Errorf("failed to read: %w", err)
+	}
+	_ = closer.Close()
+
+	report := HealthReport{
+		Open:         true,
+		WriteStalled: atomic.LoadInt32(&h.writeStalled) == 1,
+		LastRead:     time.Unix(0, atomic.LoadInt64(&h.lastRead)),
+	}
+
+	// the compaction metrics are only reported by pebble
+	if pdb, release, err := db.pebbleDB(); err == nil {
+		metrics := pdb.Metrics()
+		release()
+		report.CompactionDebt = metrics.Compact.EstimatedDebt
+		report.CompactionsInProgress = metrics.Compact.NumInProgress
+		report.WALSize = metrics.WAL.Size
+	}
+	if report.WriteStalled {
+		report.WriteStallReason, _ = h.writeStallReason.Load().(string)
+	}
+	if lastWrite := atomic.LoadInt64(&h.lastWrite); lastWrite > 0 {
+		report.LastWrite = time.Unix(0, lastWrite)
+	}
+	return report, nil
+}
@@ -0,0 +1,46 @@
+package bond
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Health(t *testing.T) {
+	db, err := Open(dbName, &Options{})
+	require.NoError(t, err)
+	defer func() { _ = os.RemoveAll(dbName) }()
+
+	start := time.Now()
+
+	report, err := db.Health(context.Background())
+	require.NoError(t, err)
+	assert.True(t, report.Healthy())
+	assert.True(t, report.Open)
+	assert.False(t, report.WriteStalled)
+	assert.False(t, report.LastRead.Before(start))
+
+	err = db.Set([]byte{0x01, 0x00}, []byte("value"), Sync)
+	require.NoError(t, err)
+
+	report, err = db.Health(context.Background())
+	require.NoError(t, err)
+	assert.False(t, report.LastWrite.Before(start))
+	assert.Greater(t, report.WALSize, uint64(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = db.Health(ctx)
+	require.Error(t, err)
+
+	require.NoError(t, db.Close())
+
+	report, err = db.Health(context.Background())
+	require.Error(t, err)
+	assert.False(t, report.Healthy())
+}
@@ -122,6 +122,29 @@ type IndexOptions[T any] struct {
 	IndexKeyFunc    IndexKeyFunction[T]
 	IndexOrderFunc  IndexOrderFunction[T]
 	IndexFilterFunc IndexFilterFunction[T]
+
+	// IndexApproxDistinctFunc builds the value whose distinct count per index
+	// key is estimated by ApproxDistinct. The sketches are not maintained if
+	// it is not set.
+	IndexApproxDistinctFunc IndexKeyFunction[T]
+
+	// IndexStatistics maintains the histogram of the first index key fields,
+	// which is used by EstimateRows and Query.WithBestIndex.
+	IndexStatistics bool
+
+	// IndexReferenceFunc builds the key of the external reference data the
+	// index key function resolves, e.g. the contract address of the token
+	// decimals the normalized balance is computed with. The index entries are
+	// recorded with their reference keys, so they can be rebuilt with
+	// ReindexReferences when the reference data changes.
+	IndexReferenceFunc IndexKeyFunction[T]
+
+	// IndexPayloadFunc builds the small payload stored as the value of the
+	// index entry, e.g. the balance of the token balance. The queries reject
+	// the rows by their payloads with Query.FilterIndexed before the rows are
+	// read. The payload is built with the KeyBuilder, so its fields are
+	// decoded by IndexPayload.
+	IndexPayloadFunc IndexKeyFunction[T]
 }
 
 type Index[T any] struct {
@@ -131,6 +154,28 @@ type Index[T any] struct {
 	IndexKeyFunction    IndexKeyFunction[T]
 	IndexFilterFunction IndexFilterFunction[T]
 	IndexOrderFunction  IndexOrderFunction[T]
+
+	IndexApproxDistinctFunction IndexKeyFunction[T]
+
+	IndexStatistics bool
+
+	IndexReferenceFunction IndexKeyFunction[T]
+
+	IndexPayloadFunction IndexKeyFunction[T]
+
+	// payloadSchema is the layout of the payload fields
+	payloadSchema []KeyFieldSchema
+
+	// db, tableID and storage are set when the index is added to the table
+	db      DB
+	tableID TableID
+	storage *_tableStorage
+
+	// stats are set when the index is added to the table
+	stats     *_indexStats
+	keyFields *_indexKeyFields
+
+	buildStatus *_indexBuildStatus
 }
 
 func NewIndex[T any](opt IndexOptions[T]) *Index[T] {
@@ -140,6 +185,18 @@ func NewIndex[T any](opt IndexOptions[T]) *Index[T] {
 		IndexKeyFunction:    opt.IndexKeyFunc,
 		IndexOrderFunction:  opt.IndexOrderFunc,
 		IndexFilterFunction: opt.IndexFilterFunc,
+
+		IndexApproxDistinctFunction: opt.IndexApproxDistinctFunc,
+		IndexStatistics:             opt.IndexStatistics,
+		IndexReferenceFunction:      opt.IndexReferenceFunc,
+		IndexPayloadFunction:        opt.IndexPayloadFunc,
+
+		keyFields:   &_indexKeyFields{},
+		buildStatus: &_indexBuildStatus{},
+	}
+
+	if idx.IndexPayloadFunction != nil {
+		idx.payloadSchema = payloadSchema(idx.IndexPayloadFunction)
 	}
 
 	if idx.IndexOrderFunction == nil {
@@ -0,0 +1,113 @@
+package bond
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// IndexBuildStatus describes the build of the index added to the table with
+// reIndex, which backfills the entries of the existing rows.
+type IndexBuildStatus struct {
+	// Building is true while the rows are backfilled. The queries on the index
+	// fail with IndexBuildingError meanwhile, rather than miss the rows that
+	// are not backfilled yet.
+	Building bool
+
+	// Rows is the number of the backfilled rows.
+	Rows uint64
+
+	// StartedAt is the time the build started, UpdatedAt the time the last
+	// batch of the rows was backfilled, so the build that does not progress
+	// is the one with UpdatedAt far behind, and FinishedAt the time the build
+	// finished.
+	StartedAt  time.Time
+	UpdatedAt  time.Time
+	FinishedAt time.Time
+
+	// Err is the error the build failed with.
+	Err error
+}
+
+// IndexBuildingError is returned when the index is used before its build
+// finishes. It matches ErrIndexBuilding and ErrIndexNotRegistered with
+// errors.Is, as the index is not added to the table until it's built.
+type IndexBuildingError struct {
+	Status IndexBuildStatus
+}
+
+func (e *IndexBuildingError) Error() string {
+	return fmt.Sprintf("%s: %d rows backfilled since %s", ErrIndexBuilding, e.Status.Rows, e.Status.StartedAt.Format(time.RFC3339))
+}
+
+func (e *IndexBuildingError) Is(target error) bool {
+	return target == ErrIndexBuilding || target == ErrIndexNotRegistered
+}
+
+// _indexBuildStatus is the status of the last build of the index.
+type _indexBuildStatus struct {
+	status IndexBuildStatus
+	mutex  sync.Mutex
+}
+
+// BuildStatus returns the status of the last build of the index, the zero
+// status if the index was never built.
+//
+// Example:
+//
+//	go func() {
+//		err := tokenBalanceTable.AddIndex([]*bond.Index[*TokenBalance]{AccountAddressIndex}, true)
+//		...
+//	}()
+//	...
+//	status := AccountAddressIndex.BuildStatus()
+//	if status.Building && time.Since(status.UpdatedAt) > time.Minute {
+//		log.Printf("index build stalled after %d rows", status.Rows)
+//	}
+func (i *Index[T]) BuildStatus() IndexBuildStatus {
+	if i.buildStatus == nil {
+		return IndexBuildStatus{}
+	}
+
+	i.buildStatus.mutex.Lock()
+	defer i.buildStatus.mutex.Unlock()
+	return i.buildStatus.status
+}
+
+// checkBuilt returns IndexBuildingError if the index is being built.
+func (i *Index[T]) checkBuilt() error {
+	if status := i.BuildStatus(); status.Building {
+		return fmt.Errorf("index %s: %w", i.IndexName, &IndexBuildingError{Status: status})
+	}
+	return nil
+}
+
+func (s *_indexBuildStatus) update(f func(status *IndexBuildStatus)) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	f(&s.status)
+}
+
+// checkIndexRegistered returns ErrIndexNotRegistered if the secondary index was
+// not added to the table, or IndexBuildingError if it's being built.
+func (t *_table[T]) checkIndexRegistered(idx *Index[T]) error {
+	if idx.IndexID == PrimaryIndexID {
+		return nil
+	}
+
+	t.mutex.RLock()
+	_, registered := t.secondaryIndexes[idx.IndexID]
+	build, building := t.indexBuilds[idx.IndexID]
+	t.mutex.RUnlock()
+
+	if registered {
+		return nil
+	} else if building && build.index == idx {
+		return t.newError(idx, nil, &IndexBuildingError{Status: idx.BuildStatus()})
+	}
+	return t.newError(idx, nil, ErrIndexNotRegistered)
+}
@@ -0,0 +1,196 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/go-bond/bond/utils"
+)
+
+// IndexPrefix is the distinct value of the leading index key fields.
+type IndexPrefix struct {
+	// Key is the encoded prefix of the index key, which is passed as after
+	// to continue the listing.
+	Key []byte
+
+	// Fields are the decoded leading fields of the index key.
+	Fields []KeyField
+}
+
+// _indexPrefixHead is the next distinct prefix of the index entries with the
+// index key of the same length.
+type _indexPrefixHead struct {
+	length  int
+	prefix  IndexPrefix
+	sortKey []byte
+}
+
+// DistinctPrefixes lists the distinct values of the first depth index key
+// fields, e.g. all the account addresses of the index on the account address
+// and the contract address, in the order of the index. The listing starts
+// after the prefix key returned by the previous call, or from the beginning if
+// after is nil, and returns up to limit prefixes, all if the limit is zero.
+//
+// The index entries are not scanned one by one. The entries of every prefix
+// are skipped with a single seek for every index key length they are stored
+// with, so the cost depends on the number of distinct prefixes rather than
+// on the number of entries.
+//
+// Example:
+//
+//	prefixes, err := TokenBalanceAccountAndContractAddressIndex.DistinctPrefixes(ctx, 1, 100, nil)
+//	...
+//	for _, prefix := range prefixes {
+//		fmt.Println(prefix.Fields[0].Value())
+//	}
+//	...
+//	prefixes, err = TokenBalanceAccountAndContractAddressIndex.DistinctPrefixes(ctx, 1, 100, prefixes[len(prefixes)-1].Key)
+func (i *Index[T]) DistinctPrefixes(ctx context.Context, depth int, limit int, after []byte) ([]IndexPrefix, error) {
+	if i.db == nil {
+		return nil, fmt.Errorf("index %s: %w", i.IndexName, ErrIndexNotRegistered)
+	}
+
+	if err := i.checkBuilt(); err != nil {
+		return nil, err
+	}
+
+	if i.IndexID == PrimaryIndexID {
+		return nil, fmt.Errorf("index %s: distinct prefixes can not be listed for primary index", i.IndexName)
+	}
+
+	schema := keySchema(func(builder KeyBuilder) []byte {
+		return i.IndexKeyFunction(builder, utils.MakeNew[T]())
+	})
+	if depth <= 0 || depth > len(schema) {
+		return nil, fmt.Errorf("index %s: depth %d out of range 1..%d", i.IndexName, depth, len(schema))
+	}
+
+	// the entries with the same prefix are followed by the id of the next
+	// field, so the prefixes are ordered and skipped with it
+	var terminator []byte
+	if depth < len(schema) {
+		terminator = []byte{schema[depth].ID}
+	}
+
+	iter := i.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{byte(i.storage.ID()), byte(i.IndexID)},
+			UpperBound: []byte{byte(i.storage.ID()), byte(i.IndexID + 1)},
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	lengthKey := func(length int) []byte {
+		key := make([]byte, 6)
+		key[0], key[1] = byte(i.storage.ID()), byte(i.IndexID)
+		binary.BigEndian.PutUint32(key[2:6], uint32(length))
+		return key
+	}
+
+	seekKey := func(length int, prefix []byte) []byte {
+		key := append(lengthKey(length), prefix...)
+		return append(key, terminator...)
+	}
+
+	// head returns the first prefix after the given one in the entries with
+	// the index key of the length
+	head := func(length int, after []byte) (*_indexPrefixHead, error) {
+		var valid bool
+		if after == nil {
+			valid = iter.SeekGE(lengthKey(length))
+		} else if terminator == nil && len(after) != length {
+			// the index keys of the other lengths that start with the
+			// prefix are the other values
+			valid = iter.SeekGE(seekKey(length, after))
+		} else if upperBound := prefixUpperBound(seekKey(length, after)); upperBound != nil {
+			valid = iter.SeekGE(upperBound)
+		}
+
+		if !valid {
+			return nil, nil
+		}
+
+		indexKey := KeyBytes(iter.Key()).IndexKey()
+		if len(indexKey) != length {
+			return nil, nil
+		}
+
+		indexKey = append([]byte{}, indexKey...)
+		fields, err := decodeKeyFields(indexKey, schema)
+		if err != nil {
+			return nil, fmt.Errorf("index %s: failed to decode index key: %w", i.IndexName, err)
+		}
+
+		size := 0
+		for _, field := range fields[:depth] {
+			size += 1 + len(field.Data)
+		}
+
+		return &_indexPrefixHead{
+			length:  length,
+			prefix:  IndexPrefix{Key: indexKey[:size], Fields: fields[:depth]},
+			sortKey: append(indexKey[:size:size], terminator...),
+		}, nil
+	}
+
+	var heads []*_indexPrefixHead
+	for valid := iter.First(); valid; {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		length := len(KeyBytes(iter.Key()).IndexKey())
+
+		h, err := head(length, after)
+		if err != nil {
+			return nil, err
+		}
+		if h != nil {
+			heads = append(heads, h)
+		}
+
+		valid = iter.SeekGE(lengthKey(length + 1))
+	}
+
+	var prefixes []IndexPrefix
+	for len(heads) > 0 && (limit <= 0 || len(prefixes) < limit) {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		next := heads[0]
+		for _, h := range heads[1:] {
+			if bytes.Compare(h.sortKey, next.sortKey) < 0 {
+				next = h
+			}
+		}
+		prefixes = append(prefixes, next.prefix)
+
+		emitted := next.prefix.Key
+		remaining := heads[:0]
+		for _, h := range heads {
+			if bytes.Equal(h.sortKey, next.sortKey) {
+				var err error
+				h, err = head(h.length, emitted)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if h != nil {
+				remaining = append(remaining, h)
+			}
+		}
+		heads = remaining
+	}
+
+	return prefixes, nil
+}
@@ -0,0 +1,91 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex_DistinctPrefixes(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceAccountAndContractAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_and_contract_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.
+				AddStringField(tb.AccountAddress).
+				AddStringField(tb.ContractAddress).
+				Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAndContractAddressIndex})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < 60; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			AccountAddress:  []string{"0xb", "0xab", "0xa"}[i%3],
+			ContractAddress: fmt.Sprintf("0xc%d", i%4*50),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	values := func(prefixes []IndexPrefix) []string {
+		var values []string
+		for _, prefix := range prefixes {
+			var fields []string
+			for _, field := range prefix.Fields {
+				fields = append(fields, field.Value().(string))
+			}
+			values = append(values, fmt.Sprint(fields))
+		}
+		return values
+	}
+
+	prefixes, err := TokenBalanceAccountAndContractAddressIndex.DistinctPrefixes(context.Background(), 1, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[0xa]", "[0xab]", "[0xb]"}, values(prefixes))
+
+	prefixes, err = TokenBalanceAccountAndContractAddressIndex.DistinctPrefixes(context.Background(), 1, 2, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[0xa]", "[0xab]"}, values(prefixes))
+
+	prefixes, err = TokenBalanceAccountAndContractAddressIndex.DistinctPrefixes(context.Background(), 1, 2, prefixes[1].Key)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[0xb]"}, values(prefixes))
+
+	prefixes, err = TokenBalanceAccountAndContractAddressIndex.DistinctPrefixes(context.Background(), 2, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 12, len(prefixes))
+	assert.Equal(t, "[0xa 0xc0]", values(prefixes)[0])
+	assert.Equal(t, "[0xb 0xc50]", values(prefixes)[11])
+
+	_, err = TokenBalanceAccountAndContractAddressIndex.DistinctPrefixes(context.Background(), 3, 0, nil)
+	require.Error(t, err)
+
+	_, err = tokenBalanceTable.PrimaryIndex().DistinctPrefixes(context.Background(), 1, 0, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package bond
+
+import (
+	"fmt"
+
+	"github.com/go-bond/bond/utils"
+)
+
+// IndexPayload is the payload of the index entry built with the
+// IndexOptions.IndexPayloadFunc.
+type IndexPayload struct {
+	data   []byte
+	schema []KeyFieldSchema
+}
+
+// Bytes returns the payload as it was built. The bytes are valid only during
+// the call of the filter.
+func (p IndexPayload) Bytes() []byte {
+	return p.data
+}
+
+// Fields returns the decoded fields of the payload.
+func (p IndexPayload) Fields() ([]KeyField, error) {
+	if p.schema == nil {
+		return nil, fmt.Errorf("index payload layout is unknown")
+	}
+	return decodeKeyFields(p.data, p.schema)
+}
+
+// Value returns the value of the i-th field of the payload, or nil if the
+// payload does not decode.
+//
+// Example:
+//
+//	query.FilterIndexed(func(payload bond.IndexPayload) bool {
+//		balance, _ := payload.Value(0).(uint64)
+//		return balance > 100
+//	})
+func (p IndexPayload) Value(i int) any {
+	fields, err := p.Fields()
+	if err != nil || i < 0 || i >= len(fields) {
+		return nil
+	}
+	return fields[i].Value()
+}
+
+// payloadSchema returns the layout of the payload fields, or nil if the
+// payload function can not be run on the empty row.
+func payloadSchema[T any](payloadFunc IndexKeyFunction[T]) (schema []KeyFieldSchema) {
+	defer func() {
+		if recover() != nil {
+			schema = nil
+		}
+	}()
+
+	empty := utils.MakeNew[T]()
+	return keySchema(func(builder KeyBuilder) []byte {
+		return payloadFunc(builder, empty)
+	})
+}
+
+// entryValue returns the value of the index entry of the row, the payload if
+// the index has one.
+func (i *Index[T]) entryValue(tr T) []byte {
+	if i.IndexPayloadFunction == nil {
+		return []byte{}
+	}
+	return i.IndexPayloadFunction(NewKeyBuilder([]byte{}), tr)
+}
+
+// indexEntryValue returns the value of the index entry with the key.
+func (t *_table[T]) indexEntryValue(indexKey []byte, idxs map[IndexID]*Index[T], tr T) []byte {
+	if idx, ok := idxs[IndexID(indexKey[1])]; ok {
+		return idx.entryValue(tr)
+	}
+	return []byte{}
+}
+
+// FilterIndexed rejects the rows by the payloads of their index entries
+// before the rows are read, so the queries that reject many rows read only
+// the index. The indexes the query scans must have the payloads.
+//
+// Example:
+//
+//	t.Query().
+//		With(AccountBalanceIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+//		FilterIndexed(func(payload bond.IndexPayload) bool {
+//			balance, _ := payload.Value(0).(uint64)
+//			return balance > 100
+//		})
+func (q Query[R]) FilterIndexed(filter func(payload IndexPayload) bool) Query[R] {
+	if q.payloadFilter == nil {
+		q.payloadFilter = filter
+		return q
+	}
+
+	previous := q.payloadFilter
+	q.payloadFilter = func(payload IndexPayload) bool {
+		return previous(payload) && filter(payload)
+	}
+	return q
+}
+
+// filterPayload applies the payload filter to the index entry. The panic of
+// the filter is returned as the error with the key of the entry.
+func (q Query[R]) filterPayload(query FilterAndIndex[R], keyBytes KeyBytes, lazy Lazy[R]) (ok bool, err error) {
+	defer q.table.recoverPanic(&err, query.Index, keyBytes, "index payload filter")
+
+	return q.payloadFilter(IndexPayload{data: lazy.indexValue, schema: query.Index.payloadSchema}), nil
+}
+
+// hasIndexPayload returns true if the index of the index entry key has the
+// payload.
+func hasIndexPayload[T any](indexKey []byte, idxs map[IndexID]*Index[T]) bool {
+	idx, ok := idxs[IndexID(indexKey[1])]
+	return ok && idx.IndexPayloadFunction != nil
+}
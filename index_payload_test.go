@@ -0,0 +1,86 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Query_FilterIndexed(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	accountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+		IndexPayloadFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.Balance).Bytes()
+		},
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{accountAddressIndex})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < 10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: "0xtestContract",
+			AccountAddress:  "0xtestAccount",
+			Balance:         uint64(i * 10),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	richFilter := func(payload IndexPayload) bool {
+		balance, _ := payload.Value(0).(uint64)
+		return balance >= 50
+	}
+
+	var tokenBalancesRead []*TokenBalance
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		FilterIndexed(richFilter).
+		Execute(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[5:], tokenBalancesRead)
+
+	// the payload follows the update of the row with the same index key
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount", Balance: 100},
+	})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		FilterIndexed(richFilter).
+		Limit(2).
+		Execute(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	require.Len(t, tokenBalancesRead, 2)
+	assert.Equal(t, uint64(1), tokenBalancesRead[0].ID)
+	assert.Equal(t, uint64(6), tokenBalancesRead[1].ID)
+
+	// the index without the payload can not be filtered by it
+	err = tokenBalanceTable.Query().
+		FilterIndexed(richFilter).
+		Execute(context.Background(), &tokenBalancesRead)
+	require.Error(t, err)
+}
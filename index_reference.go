@@ -0,0 +1,216 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// TableReferenceReindexer rebuilds the index entries that depend on the
+// external reference data.
+type TableReferenceReindexer[T any] interface {
+	// ReindexReferences rebuilds the entries of the index that were written
+	// for the rows with the given reference keys. It needs to be called when
+	// the reference data resolved by the index key function changes, e.g.
+	// with the batch that updates it.
+	ReindexReferences(ctx context.Context, idx *Index[T], references [][]byte, optBatch ...Batch) error
+}
+
+// _referencedEntry is the index entry written for the row with the reference.
+type _referencedEntry struct {
+	reference []byte
+	indexKey  []byte
+}
+
+// ReindexReferences rebuilds the index entries of the rows that reference the
+// changed data. The entries are found with the reference entries, which record
+// the index keys written for every reference key, so the entries built from the
+// outdated reference data are removed although the index key function no
+// longer returns them.
+//
+// Example:
+//
+//	// the decimals of the token changed, so its normalized balances too
+//	err := tokenBalanceTable.ReindexReferences(ctx, NormalizedBalanceIndex,
+//		[][]byte{bond.NewKeyBuilder([]byte{}).AddStringField(contractAddress).Bytes()})
+func (t *_table[T]) ReindexReferences(ctx context.Context, idx *Index[T], references [][]byte, optBatch ...Batch) error {
+	if idx.IndexReferenceFunction == nil {
+		return fmt.Errorf("index %s does not maintain reference entries", idx.IndexName)
+	}
+
+	if idx.db == nil || idx.tableID != t.id {
+		return fmt.Errorf("index %s: %w", idx.IndexName, ErrIndexNotRegistered)
+	}
+
+	var (
+		batch         Batch
+		externalBatch = len(optBatch) > 0 && optBatch[0] != nil
+	)
+	if externalBatch {
+		batch = optBatch[0]
+	} else {
+		batch = t.db.Batch()
+		defer func() {
+			_ = batch.Close()
+		}()
+	}
+
+	var (
+		entries     []_referencedEntry
+		primaryKeys []PrimaryKey
+	)
+	for _, reference := range references {
+		prefix := idx.referenceKey(reference, nil)
+
+		iter := t.db.Iter(&IterOptions{
+			IterOptions: pebble.IterOptions{
+				LowerBound: prefix,
+			},
+		}, batch)
+
+		for iter.First(); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); iter.Next() {
+			indexKey := append([]byte{}, iter.Key()[len(prefix):]...)
+			entries = append(entries, _referencedEntry{reference: reference, indexKey: indexKey})
+			primaryKeys = append(primaryKeys, KeyBytes(indexKey).PrimaryKey())
+		}
+
+		err := iter.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	trs, found, err := t.getByKeys(ctx, primaryKeys, true, batch)
+	if err != nil {
+		return err
+	}
+
+	var invalidation _cacheInvalidation
+	for i, entry := range entries {
+		err = batch.Delete(entry.indexKey, Sync)
+		if err != nil {
+			return err
+		}
+
+		err = batch.Delete(idx.referenceKey(entry.reference, entry.indexKey), Sync)
+		if err != nil {
+			return err
+		}
+
+		if t.queryCache != nil {
+			key := KeyBytes(entry.indexKey).ToKey()
+			invalidation.prefixes = append(invalidation.prefixes, KeyEncode(Key{
+				TableID:    key.TableID,
+				IndexID:    key.IndexID,
+				IndexKey:   key.IndexKey,
+				IndexOrder: []byte{},
+				PrimaryKey: []byte{},
+			}))
+		}
+
+		if !found[i] {
+			continue
+		}
+
+		err = t.setIndexReference(batch, idx, trs[i], true)
+		if err != nil {
+			return err
+		}
+
+		if t.queryCache != nil {
+			t.collectInvalidation(&invalidation, nil, map[IndexID]*Index[T]{idx.IndexID: idx}, trs[i])
+		}
+	}
+
+	if !externalBatch {
+		err = batch.Commit(ContextRetrieveWriteOptions(ctx))
+		if err != nil {
+			return err
+		}
+	}
+
+	t.invalidateCache(invalidation, batch, externalBatch)
+	return nil
+}
+
+// updateIndexReferences is the write hook that records the index entries of
+// the written rows with their reference keys.
+func (t *_table[T]) updateIndexReferences(idx *Index[T]) _writeHook[T] {
+	return func(_ context.Context, batch Batch, changes []_rowChange[T]) error {
+		for _, change := range changes {
+			if change.hasOld {
+				err := t.deleteIndexReference(batch, idx, change.old)
+				if err != nil {
+					return err
+				}
+			}
+			if change.hasNew {
+				err := t.setIndexReference(batch, idx, change.new, false)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// setIndexReference records the index entry of the row with its reference key.
+// If withEntry is true, the index entry itself is written too.
+func (t *_table[T]) setIndexReference(batch Batch, idx *Index[T], tr T, withEntry bool) error {
+	if !idx.IndexFilterFunction(tr) {
+		return nil
+	}
+
+	indexKey, err := t.safeIndexKey(tr, idx, make([]byte, 0, DataKeyBufferSize))
+	if err != nil {
+		return err
+	}
+
+	if withEntry {
+		err = batch.Set(indexKey, idx.entryValue(tr), Sync)
+		if err != nil {
+			return err
+		}
+	}
+
+	reference := idx.IndexReferenceFunction(NewKeyBuilder([]byte{}), tr)
+	return batch.Set(idx.referenceKey(reference, indexKey), []byte{}, Sync)
+}
+
+func (t *_table[T]) deleteIndexReference(batch Batch, idx *Index[T], tr T) error {
+	if !idx.IndexFilterFunction(tr) {
+		return nil
+	}
+
+	indexKey, err := t.safeIndexKey(tr, idx, make([]byte, 0, DataKeyBufferSize))
+	if err != nil {
+		return err
+	}
+
+	reference := idx.IndexReferenceFunction(NewKeyBuilder([]byte{}), tr)
+	return batch.Delete(idx.referenceKey(reference, indexKey), Sync)
+}
+
+// referenceKey returns the key of the reference entry. The length of the
+// reference key is encoded before it, so the references do not share their
+// entries with the references they are the prefix of.
+func (i *Index[T]) referenceKey(reference []byte, indexKey []byte) []byte {
+	key := make([]byte, 0, 8+len(reference)+len(indexKey))
+	key = append(key, BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_INDEX_REFERENCE_INDEX_ID, byte(i.storage.ID()), byte(i.IndexID))
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(reference)))
+	key = append(key, length[:]...)
+	key = append(key, reference...)
+	return append(key, indexKey...)
+}
@@ -0,0 +1,124 @@
+package bond
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_ReindexReferences(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	var (
+		decimalsMutex sync.Mutex
+		decimals      = map[string]uint64{"0xtestContract": 1, "0xtestContract2": 10}
+	)
+
+	normalize := func(tb *TokenBalance) uint64 {
+		decimalsMutex.Lock()
+		defer decimalsMutex.Unlock()
+		// the selectors without the contract are normalized already
+		if d, ok := decimals[tb.ContractAddress]; ok {
+			return tb.Balance / d
+		}
+		return tb.Balance
+	}
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceNormalizedIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "normalized_balance_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(normalize(tb)).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+		IndexReferenceFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.ContractAddress).Bytes()
+		},
+	})
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount1", Balance: 100},
+		{ID: 2, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount2", Balance: 200},
+		{ID: 3, ContractAddress: "0xtestContract2", AccountAddress: "0xtestAccount1", Balance: 100},
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances[:1])
+	require.NoError(t, err)
+
+	// the rows that exist before the index is added are recorded on reindex
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceNormalizedIndex}, true)
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances[1:])
+	require.NoError(t, err)
+
+	normalized := func(balance uint64) []*TokenBalance {
+		var result []*TokenBalance
+		err := tokenBalanceTable.Query().
+			With(TokenBalanceNormalizedIndex, &TokenBalance{Balance: balance}).
+			Execute(context.Background(), &result)
+		require.NoError(t, err)
+		return result
+	}
+
+	countEntries := func() int {
+		iter := db.Iter(&IterOptions{
+			IterOptions: pebble.IterOptions{
+				LowerBound: []byte{1, byte(TokenBalanceNormalizedIndex.IndexID)},
+				UpperBound: []byte{1, byte(TokenBalanceNormalizedIndex.IndexID + 1)},
+			},
+		})
+		defer func() { _ = iter.Close() }()
+
+		var count int
+		for iter.First(); iter.Valid(); iter.Next() {
+			count++
+		}
+		return count
+	}
+
+	assert.Len(t, normalized(100), 1)
+	assert.Len(t, normalized(10), 1)
+
+	decimalsMutex.Lock()
+	decimals["0xtestContract"] = 100
+	decimalsMutex.Unlock()
+
+	err = tokenBalanceTable.ReindexReferences(context.Background(), TokenBalanceNormalizedIndex, [][]byte{
+		NewKeyBuilder([]byte{}).AddStringField("0xtestContract").Bytes(),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, countEntries())
+	assert.Equal(t, []*TokenBalance{tokenBalances[0]}, normalized(1))
+	assert.Equal(t, []*TokenBalance{tokenBalances[1]}, normalized(2))
+	assert.Equal(t, []*TokenBalance{tokenBalances[2]}, normalized(10))
+
+	// the reference entries of the deleted rows are removed
+	err = tokenBalanceTable.Delete(context.Background(), tokenBalances[:1])
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.ReindexReferences(context.Background(), TokenBalanceNormalizedIndex, [][]byte{
+		NewKeyBuilder([]byte{}).AddStringField("0xtestContract").Bytes(),
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, countEntries())
+
+	err = tokenBalanceTable.ReindexReferences(context.Background(), tokenBalanceTable.PrimaryIndex(), nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,162 @@
+package bond
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// IndexSketchPrecision is the number of the hash bits that select the register
+// of the HyperLogLog sketch. The sketch has 2^precision registers and the
+// standard error of about 1.04/sqrt(2^precision).
+const IndexSketchPrecision = 10
+
+const indexSketchRegisters = 1 << IndexSketchPrecision
+
+// ApproxDistinct estimates the number of the distinct values built by the
+// IndexApproxDistinctFunc in the rows whose index keys start with the index key
+// of the selector. The sketches only grow, the deleted rows and the replaced
+// values are still counted.
+func (i *Index[T]) ApproxDistinct(ctx context.Context, selector T) (uint64, error) {
+	if i.IndexApproxDistinctFunction == nil {
+		return 0, fmt.Errorf("index %s does not maintain distinct count sketches", i.IndexName)
+	}
+
+	if i.db == nil {
+		return 0, fmt.Errorf("index %s: %w", i.IndexName, ErrIndexNotRegistered)
+	}
+
+	if err := i.checkBuilt(); err != nil {
+		return 0, err
+	}
+
+	prefix := i.sketchKey(i.IndexKeyFunction(NewKeyBuilder([]byte{}), selector), nil)
+	upperBound := append([]byte{}, prefix...)
+	for j := len(upperBound) - 1; j >= 0; j-- {
+		upperBound[j]++
+		if upperBound[j] != 0 {
+			upperBound = upperBound[:j+1]
+			break
+		}
+	}
+
+	iter := i.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: upperBound,
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	var registers [indexSketchRegisters]uint8
+	for iter.First(); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		key, value := iter.Key(), iter.Value()
+		register := binary.BigEndian.Uint16(key[len(key)-2:])
+		if value[0] > registers[register] {
+			registers[register] = value[0]
+		}
+	}
+
+	return hllEstimate(registers[:]), nil
+}
+
+// updateSketches is the write hook that adds the written rows to the sketches.
+func (i *Index[T]) updateSketches(_ context.Context, batch Batch, changes []_rowChange[T]) error {
+	for _, change := range changes {
+		if !change.hasNew {
+			continue
+		}
+
+		err := i.updateSketch(batch, change.new)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateSketch adds the row to the sketch of its index key. Only the register
+// the value hashes to is written, and only if its rank increases.
+func (i *Index[T]) updateSketch(batch Batch, tr T) error {
+	if !i.IndexFilterFunction(tr) {
+		return nil
+	}
+
+	indexKey := i.IndexKeyFunction(NewKeyBuilder([]byte{}), tr)
+	value := i.IndexApproxDistinctFunction(NewKeyBuilder([]byte{}), tr)
+
+	hash := fnv.New64a()
+	_, _ = hash.Write(value)
+	h := mix64(hash.Sum64())
+
+	register := uint16(h >> (64 - IndexSketchPrecision))
+	rank := uint8(bits.LeadingZeros64(h<<IndexSketchPrecision|1<<(IndexSketchPrecision-1))) + 1
+
+	var registerBytes [2]byte
+	binary.BigEndian.PutUint16(registerBytes[:], register)
+	key := i.sketchKey(indexKey, registerBytes[:])
+
+	data, closer, err := i.db.Get(key, batch)
+	if err == nil {
+		current := data[0]
+		_ = closer.Close()
+		if current >= rank {
+			return nil
+		}
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	return batch.Set(key, []byte{rank}, Sync)
+}
+
+func (i *Index[T]) sketchKey(indexKey []byte, register []byte) []byte {
+	key := make([]byte, 0, 4+len(indexKey)+len(register))
+	key = append(key, BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_INDEX_SKETCH_INDEX_ID, byte(i.storage.ID()), byte(i.IndexID))
+	key = append(key, indexKey...)
+	return append(key, register...)
+}
+
+// mix64 spreads the bits of the FNV hash, so the register and the rank bits
+// are independent.
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+func hllEstimate(registers []uint8) uint64 {
+	m := float64(len(registers))
+
+	sum, zeros := 0.0, 0
+	for _, rank := range registers {
+		sum += math.Pow(2, -float64(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// linear counting for the small cardinalities
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(estimate))
+}
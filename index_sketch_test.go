@@ -0,0 +1,78 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Index_ApproxDistinct(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceContractAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "contract_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.ContractAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+		IndexApproxDistinctFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+	})
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < 5000; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: "0xtestContract",
+			// every account holds two tokens
+			AccountAddress: fmt.Sprintf("0xtestAccount%d", i/2),
+			Balance:        uint64(i),
+		})
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances[:3000])
+	require.NoError(t, err)
+
+	// the rows that exist before the index is added are counted on reindex
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceContractAddressIndex}, true)
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances[3000:])
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 5001, ContractAddress: "0xtestContract2", AccountAddress: "0xtestAccount0", Balance: 1},
+	})
+	require.NoError(t, err)
+
+	count, err := TokenBalanceContractAddressIndex.ApproxDistinct(context.Background(), &TokenBalance{ContractAddress: "0xtestContract"})
+	require.NoError(t, err)
+	assert.InEpsilon(t, 2500, count, 0.1)
+
+	count, err = TokenBalanceContractAddressIndex.ApproxDistinct(context.Background(), &TokenBalance{ContractAddress: "0xtestContract2"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+
+	count, err = TokenBalanceContractAddressIndex.ApproxDistinct(context.Background(), &TokenBalance{ContractAddress: "0xunknownContract"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+}
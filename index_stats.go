@@ -0,0 +1,389 @@
+package bond
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-bond/bond/utils"
+)
+
+// IndexStatsPersistInterval is how often the index statistics are written
+// along with the table writes.
+var IndexStatsPersistInterval = 10 * time.Second
+
+// IndexScanCostFactor is the cost of reading the row through the index, which
+// is the index entry read followed by the row point read, relative to reading
+// the row with the table scan. Query.WithBestIndex scans the table when the
+// estimated index entries cost more than the rows of the table.
+var IndexScanCostFactor = 3.0
+
+const (
+	indexStatsDepth = 4
+	indexStatsWidth = 1024
+)
+
+// _indexStats is the count-min sketch of the first index key fields, which is
+// the histogram of the index key prefixes with the bounded size. The counts
+// are decreased for the removed entries.
+type _indexStats struct {
+	mutex sync.Mutex
+
+	total     int64
+	counts    [indexStatsDepth][indexStatsWidth]int32
+	persisted time.Time
+}
+
+// IndexStats is the summary of the index statistics.
+type IndexStats struct {
+	Entries uint64
+}
+
+// Statistics returns the summary of the index statistics. The error is
+// returned if the statistics are not maintained.
+func (i *Index[T]) Statistics() (IndexStats, error) {
+	if i.stats == nil {
+		return IndexStats{}, fmt.Errorf("index %s does not maintain statistics", i.IndexName)
+	}
+
+	i.stats.mutex.Lock()
+	defer i.stats.mutex.Unlock()
+
+	return IndexStats{Entries: uint64(maxInt64(i.stats.total, 0))}, nil
+}
+
+// EstimateRows estimates the number of the index entries that start with the
+// index key prefix of the selector. The prefix is the leading fields of the
+// selector that are set, only the first of them is taken into account, so the
+// estimate is the upper bound. It returns false if the index does not maintain
+// statistics.
+func (i *Index[T]) EstimateRows(selector T) (uint64, bool) {
+	if i.stats == nil {
+		return 0, false
+	}
+
+	first, ok := i.firstField(selector, true)
+
+	i.stats.mutex.Lock()
+	defer i.stats.mutex.Unlock()
+
+	if !ok {
+		return uint64(maxInt64(i.stats.total, 0)), true
+	}
+
+	estimate := int64(math.MaxInt32)
+	for row, column := range indexStatsColumns(first) {
+		estimate = minInt64(estimate, int64(i.stats.counts[row][column]))
+	}
+	return uint64(maxInt64(estimate, 0)), true
+}
+
+// chooseIndex replaces the index selected by WithBestIndex with the candidate
+// that has the fewest estimated entries, or with the primary index if there is
+// no such candidate or the table scan is cheaper.
+func (q Query[R]) chooseIndex() Query[R] {
+	var (
+		best         *Index[R]
+		bestEstimate uint64
+		rows         uint64
+	)
+	for _, idx := range q.indexCandidates {
+		// the index that is being built can not be queried yet
+		if idx.BuildStatus().Building {
+			continue
+		}
+
+		if stats, err := idx.Statistics(); err == nil && stats.Entries > rows {
+			rows = stats.Entries
+		}
+
+		if _, ok := idx.firstField(q.indexSelector, true); !ok {
+			continue
+		}
+
+		estimate, ok := idx.EstimateRows(q.indexSelector)
+		if ok && (best == nil || estimate < bestEstimate) {
+			best, bestEstimate = idx, estimate
+		}
+	}
+
+	selector, prefix := q.indexSelector, true
+	switch {
+	case best == nil:
+		q.indexChoice = "no candidate index applies"
+	case float64(bestEstimate)*IndexScanCostFactor >= float64(rows) && rows > 0:
+		q.indexChoice = fmt.Sprintf("table scan is cheaper than %s with estimated %d of %d rows",
+			best.IndexName, bestEstimate, rows)
+		best = nil
+	default:
+		q.indexChoice = fmt.Sprintf("%s with estimated %d of %d rows", best.IndexName, bestEstimate, rows)
+	}
+
+	if best == nil {
+		best, selector, prefix = q.table.primaryIndex, utils.MakeNew[R](), false
+	}
+
+	queries := make([]FilterAndIndex[R], 0, len(q.queries))
+	for _, query := range q.queries {
+		if query.Index == nil {
+			query.Index, query.IndexSelector, query.IndexPrefix = best, selector, prefix
+		}
+		queries = append(queries, query)
+	}
+	q.queries = queries
+
+	if q.index == nil {
+		q.index, q.indexSelector, q.indexPrefix = best, selector, prefix
+	}
+	q.indexCandidates = nil
+	return q
+}
+
+// _indexKeyFields is the key schema of the index and the first key field of
+// the zero row, which are computed once per index.
+type _indexKeyFields struct {
+	once sync.Once
+
+	schema    []KeyFieldSchema
+	zeroFirst []byte
+	zeroOK    bool
+}
+
+// firstField returns the data of the first index key field. If setOnly is
+// true, it's not returned if it's equal to the field of the zero row.
+func (i *Index[T]) firstField(tr T, setOnly bool) ([]byte, bool) {
+	keyFields := i.keyFields
+	keyFields.once.Do(func() {
+		zero := utils.MakeNew[T]()
+		keyFields.schema = keySchema(func(builder KeyBuilder) []byte {
+			return i.IndexKeyFunction(builder, zero)
+		})
+
+		fields, err := decodeKeyFields(i.IndexKeyFunction(NewKeyBuilder([]byte{}), zero), keyFields.schema)
+		if err == nil && len(fields) > 0 {
+			keyFields.zeroFirst, keyFields.zeroOK = fields[0].Data, true
+		}
+	})
+
+	fields, err := decodeKeyFields(i.IndexKeyFunction(NewKeyBuilder([]byte{}), tr), keyFields.schema)
+	if err != nil || len(fields) == 0 {
+		return nil, false
+	}
+
+	if setOnly && keyFields.zeroOK && string(keyFields.zeroFirst) == string(fields[0].Data) {
+		return nil, false
+	}
+	return fields[0].Data, true
+}
+
+// _indexStatsDelta is the change of the statistics made by the write. It's
+// applied once the write is committed, so the failed, the canceled and the
+// dry run writes leave the statistics intact.
+type _indexStatsDelta struct {
+	total   int64
+	entries []_indexStatsEntry
+}
+
+type _indexStatsEntry struct {
+	columns [indexStatsDepth]int
+	delta   int32
+}
+
+// updateStats is the write hook that adds the written entries to the index
+// statistics and removes the replaced and the deleted ones once the batch is
+// committed. The statistics are written to the batch if they were not
+// persisted recently.
+func (i *Index[T]) updateStats(_ context.Context, batch Batch, changes []_rowChange[T]) error {
+	delta := &_indexStatsDelta{}
+	for _, change := range changes {
+		if change.hasOld {
+			i.addStats(delta, change.old, -1)
+		}
+		if change.hasNew {
+			i.addStats(delta, change.new, 1)
+		}
+	}
+
+	if len(delta.entries) == 0 {
+		return nil
+	}
+
+	batch.AfterCommit(func(CommittedBatchInfo) {
+		i.applyStats(delta)
+	})
+
+	i.stats.mutex.Lock()
+	defer i.stats.mutex.Unlock()
+
+	if time.Since(i.stats.persisted) < IndexStatsPersistInterval {
+		return nil
+	}
+	return i.persistStats(batch, delta)
+}
+
+func (i *Index[T]) addStats(delta *_indexStatsDelta, tr T, change int32) {
+	if !i.IndexFilterFunction(tr) {
+		return
+	}
+
+	first, ok := i.firstField(tr, false)
+	if !ok {
+		return
+	}
+
+	delta.total += int64(change)
+	delta.entries = append(delta.entries, _indexStatsEntry{columns: indexStatsColumns(first), delta: change})
+}
+
+func (i *Index[T]) applyStats(delta *_indexStatsDelta) {
+	i.stats.mutex.Lock()
+	defer i.stats.mutex.Unlock()
+
+	i.stats.total += delta.total
+	for _, entry := range delta.entries {
+		for row, column := range entry.columns {
+			i.stats.counts[row][column] += entry.delta
+		}
+	}
+}
+
+// persistStats writes the statistics with the delta of the batch to the batch.
+// The stats mutex must be held.
+func (i *Index[T]) persistStats(batch Batch, delta *_indexStatsDelta) error {
+	total, counts := i.stats.total+delta.total, i.stats.counts
+	for _, entry := range delta.entries {
+		for row, column := range entry.columns {
+			counts[row][column] += entry.delta
+		}
+	}
+
+	err := batch.Set(i.statsKey(), encodeIndexStats(total, &counts), Sync)
+	if err != nil {
+		return err
+	}
+
+	i.stats.persisted = time.Now()
+	return nil
+}
+
+// encodeIndexStats returns the persisted statistics: the total and the counts
+// of the sketch.
+func encodeIndexStats(total int64, counts *[indexStatsDepth][indexStatsWidth]int32) []byte {
+	data := make([]byte, 8+indexStatsDepth*indexStatsWidth*4)
+	binary.BigEndian.PutUint64(data, uint64(total))
+
+	offset := 8
+	for row := range counts {
+		for _, count := range counts[row] {
+			binary.BigEndian.PutUint32(data[offset:], uint32(count))
+			offset += 4
+		}
+	}
+	return data
+}
+
+// loadStats reads the persisted statistics. The statistics of the index that
+// was never persisted are empty.
+func (i *Index[T]) loadStats() error {
+	data, closer, err := i.db.Get(i.statsKey())
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer func() { _ = closer.Close() }()
+
+	if len(data) != 8+indexStatsDepth*indexStatsWidth*4 {
+		return fmt.Errorf("index %s: invalid statistics", i.IndexName)
+	}
+
+	i.stats.mutex.Lock()
+	defer i.stats.mutex.Unlock()
+
+	i.stats.total = int64(binary.BigEndian.Uint64(data))
+
+	offset := 8
+	for row := range i.stats.counts {
+		for column := range i.stats.counts[row] {
+			i.stats.counts[row][column] = int32(binary.BigEndian.Uint32(data[offset:]))
+			offset += 4
+		}
+	}
+	return nil
+}
+
+// replaceStats takes over the statistics of the index of the staging table,
+// whose entries are read by the index once the storage IDs of the tables are
+// swapped. The persisted statistics of the staging index may lag behind, so
+// its current ones are written to the batch and the returned function takes
+// them over once it's committed.
+func (i *Index[T]) replaceStats(batch Batch, staging *Index[T]) (func(), error) {
+	var total int64
+	var counts [indexStatsDepth][indexStatsWidth]int32
+	if staging.stats != nil {
+		staging.stats.mutex.Lock()
+		total, counts = staging.stats.total, staging.stats.counts
+		staging.stats.mutex.Unlock()
+	}
+
+	err := batch.Set(staging.statsKey(), encodeIndexStats(total, &counts), Sync)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		i.stats.mutex.Lock()
+		i.stats.total, i.stats.counts = total, counts
+		i.stats.persisted = time.Now()
+		i.stats.mutex.Unlock()
+
+		if staging.stats != nil {
+			staging.resetStats()
+		}
+	}, nil
+}
+
+// resetStats clears the statistics before the index is rebuilt.
+func (i *Index[T]) resetStats() {
+	i.stats.mutex.Lock()
+	defer i.stats.mutex.Unlock()
+
+	i.stats.total = 0
+	i.stats.counts = [indexStatsDepth][indexStatsWidth]int32{}
+}
+
+func (i *Index[T]) statsKey() []byte {
+	return []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_INDEX_STATS_INDEX_ID, byte(i.storage.ID()), byte(i.IndexID)}
+}
+
+// indexStatsColumns returns the sketch column of the value for every row.
+func indexStatsColumns(value []byte) [indexStatsDepth]int {
+	hash := fnv.New64a()
+	_, _ = hash.Write(value)
+	h := mix64(hash.Sum64())
+
+	var columns [indexStatsDepth]int
+	for row := range columns {
+		columns[row] = int((h >> (row * 16)) % indexStatsWidth)
+	}
+	return columns
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
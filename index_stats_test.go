@@ -0,0 +1,303 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Index_EstimateRows(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceContractAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "contract_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.ContractAddress).Bytes()
+		},
+		IndexOrderFunc:  IndexOrderDefault[*TokenBalance],
+		IndexStatistics: true,
+	})
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < 1000; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: fmt.Sprintf("0xtestContract%d", i%10),
+			AccountAddress:  fmt.Sprintf("0xtestAccount%d", i),
+			Balance:         uint64(i),
+		})
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances[:600])
+	require.NoError(t, err)
+
+	// the rows that exist before the index is added are counted on reindex
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceContractAddressIndex}, true)
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances[600:])
+	require.NoError(t, err)
+
+	estimate, ok := TokenBalanceContractAddressIndex.EstimateRows(&TokenBalance{ContractAddress: "0xtestContract3"})
+	require.True(t, ok)
+	assert.GreaterOrEqual(t, estimate, uint64(100))
+	assert.Less(t, estimate, uint64(150))
+
+	estimate, ok = TokenBalanceContractAddressIndex.EstimateRows(&TokenBalance{})
+	require.True(t, ok)
+	assert.Equal(t, uint64(1000), estimate)
+
+	// the moved and the deleted rows are removed from the statistics
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 4, ContractAddress: "0xtestContract9", AccountAddress: "0xtestAccount3", Balance: 3},
+	})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Delete(context.Background(), tokenBalances[100:200])
+	require.NoError(t, err)
+
+	estimate, ok = TokenBalanceContractAddressIndex.EstimateRows(&TokenBalance{ContractAddress: "0xtestContract3"})
+	require.True(t, ok)
+	assert.GreaterOrEqual(t, estimate, uint64(89))
+	assert.Less(t, estimate, uint64(150))
+
+	stats, err := TokenBalanceContractAddressIndex.Statistics()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(900), stats.Entries)
+
+	_, ok = tokenBalanceTable.PrimaryIndex().EstimateRows(&TokenBalance{})
+	assert.False(t, ok)
+}
+
+func TestBond_Index_Statistics_Persisted(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	defer func(interval time.Duration) {
+		IndexStatsPersistInterval = interval
+	}(IndexStatsPersistInterval)
+	IndexStatsPersistInterval = 0
+
+	newIndex := func() *Index[*TokenBalance] {
+		return NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 1,
+			IndexName: "contract_address_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.ContractAddress).Bytes()
+			},
+			IndexOrderFunc:  IndexOrderDefault[*TokenBalance],
+			IndexStatistics: true,
+		})
+	}
+
+	newTable := func() Table[*TokenBalance] {
+		return NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   TableID(1),
+			TableName: "token_balance",
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+		})
+	}
+
+	tokenBalanceTable := newTable()
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{newIndex()})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount1"},
+		{ID: 2, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount2"},
+	})
+	require.NoError(t, err)
+
+	// the statistics are loaded when the index is added to the reopened table
+	index := newIndex()
+	err = newTable().AddIndex([]*Index[*TokenBalance]{index})
+	require.NoError(t, err)
+
+	estimate, ok := index.EstimateRows(&TokenBalance{ContractAddress: "0xtestContract"})
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), estimate)
+}
+
+func TestBond_Index_Statistics_NotCommitted(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceContractAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "contract_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.ContractAddress).Bytes()
+		},
+		IndexOrderFunc:  IndexOrderDefault[*TokenBalance],
+		IndexStatistics: true,
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceContractAddressIndex})
+	require.NoError(t, err)
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount1"},
+		{ID: 2, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount2"},
+	}
+
+	// the dry run is not counted
+	dryRunCtx := ContextWithWriteOptions(context.Background(), WriteOptions{DryRun: true, Report: &WriteReport{}})
+	err = tokenBalanceTable.Insert(dryRunCtx, tokenBalances)
+	require.NoError(t, err)
+
+	// the external batch closed without the commit is not counted
+	batch := db.Batch()
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances, batch)
+	require.NoError(t, err)
+	require.NoError(t, batch.Close())
+
+	stats, err := TokenBalanceContractAddressIndex.Statistics()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), stats.Entries)
+
+	// the external batch is counted once it's committed
+	batch = db.Batch()
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances, batch)
+	require.NoError(t, err)
+
+	stats, err = TokenBalanceContractAddressIndex.Statistics()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), stats.Entries)
+
+	require.NoError(t, batch.Commit(Sync))
+	require.NoError(t, batch.Close())
+
+	estimate, ok := TokenBalanceContractAddressIndex.EstimateRows(&TokenBalance{ContractAddress: "0xtestContract"})
+	require.True(t, ok)
+	assert.Equal(t, uint64(2), estimate)
+}
+
+func TestBond_Query_WithBestIndex(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceContractAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "contract_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.ContractAddress).Bytes()
+		},
+		IndexOrderFunc:  IndexOrderDefault[*TokenBalance],
+		IndexStatistics: true,
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 2,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc:  IndexOrderDefault[*TokenBalance],
+		IndexStatistics: true,
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{
+		TokenBalanceContractAddressIndex,
+		TokenBalanceAccountAddressIndex,
+	})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < 1000; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: fmt.Sprintf("0xtestContract%d", i%2),
+			AccountAddress:  fmt.Sprintf("0xtestAccount%d", i%100),
+			Balance:         uint64(i),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	filter := func(tb *TokenBalance) bool {
+		return tb.ContractAddress == "0xtestContract1" && tb.AccountAddress == "0xtestAccount7"
+	}
+
+	query := tokenBalanceTable.Query().
+		WithBestIndex(&TokenBalance{ContractAddress: "0xtestContract1", AccountAddress: "0xtestAccount7"},
+			TokenBalanceContractAddressIndex, TokenBalanceAccountAddressIndex).
+		Filter(filter)
+
+	assert.Equal(t, TokenBalanceAccountAddressIndex, query.chooseIndex().queries[0].Index)
+
+	var result []*TokenBalance
+	err = query.Execute(context.Background(), &result)
+	require.NoError(t, err)
+	require.Len(t, result, 10)
+	for _, tb := range result {
+		assert.True(t, filter(tb))
+	}
+
+	assert.Equal(t, "prefix scan account_address_idx with filter, index choice: account_address_idx with estimated 10 of 1000 rows", query.Explain())
+
+	// the unselective prefix is cheaper to read with the table scan
+	query = tokenBalanceTable.Query().
+		WithBestIndex(&TokenBalance{ContractAddress: "0xtestContract1"}, TokenBalanceContractAddressIndex).
+		Filter(func(tb *TokenBalance) bool {
+			return tb.ContractAddress == "0xtestContract1"
+		})
+
+	assert.Equal(t, "scan primary with filter, index choice: table scan is cheaper than contract_address_idx with estimated 500 of 1000 rows", query.Explain())
+
+	err = query.Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Len(t, result, 500)
+
+	// the primary index is scanned if no candidate applies
+	query = tokenBalanceTable.Query().
+		WithBestIndex(&TokenBalance{Balance: 5}, TokenBalanceContractAddressIndex, TokenBalanceAccountAddressIndex).
+		Limit(5)
+
+	assert.Equal(t, tokenBalanceTable.PrimaryIndex(), query.chooseIndex().index)
+	assert.Equal(t, "scan primary, index choice: no candidate index applies, limit 5", query.Explain())
+
+	keys, err := query.Keys(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, keys, 5)
+}
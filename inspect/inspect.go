@@ -16,6 +16,7 @@ type Inspect interface {
 	EntryFields(table string) (map[string]string, error)
 
 	Query(ctx context.Context, table string, index string, indexSelector map[string]interface{}, filter map[string]interface{}, limit uint64, after map[string]interface{}) ([]map[string]interface{}, error)
+	DumpKey(ctx context.Context, table string, key []byte) (string, error)
 }
 
 type inspect struct {
@@ -164,6 +165,25 @@ func (in *inspect) Query(ctx context.Context, table string, index string, indexS
 	return resultMapArray, nil
 }
 
+func (in *inspect) DumpKey(ctx context.Context, table string, key []byte) (string, error) {
+	tableInfo, _, err := in.findTableAndIndexInfo(table, "")
+	if err != nil {
+		return "", err
+	}
+
+	debugRowValues := reflect.ValueOf(tableInfo).MethodByName("DebugRow").Call(
+		[]reflect.Value{
+			reflect.ValueOf(ctx),
+			reflect.ValueOf(key),
+		},
+	)
+	if debugRowValues[1].Interface() != nil {
+		return "", debugRowValues[1].Interface().(error)
+	}
+
+	return debugRowValues[0].String(), nil
+}
+
 func (in *inspect) findTableAndIndexInfo(table string, index string) (bond.TableInfo, bond.IndexInfo, error) {
 	if index == "" {
 		index = bond.PrimaryIndexName
@@ -2,6 +2,7 @@ package inspect
 
 import (
 	"context"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -62,6 +63,12 @@ var _FlagAfter = &cli.StringFlag{
 	Required: false,
 }
 
+var _FlagKey = &cli.StringFlag{
+	Name:     "key",
+	Usage:    "sets hex encoded raw key",
+	Required: true,
+}
+
 var _FlagDeadline = &cli.DurationFlag{
 	Name:     "deadline",
 	Usage:    "sets query deadline",
@@ -81,7 +88,8 @@ func NewInspectCLI(init func(path string) (Inspect, error)) *cli.App {
 			"bond-cli --url .bond tables\n" +
 			"bond-cli --url http://localhost:7777/bond tables\n" +
 			"bond-cli --url http://localhost:7777/bond indexes --table token_balances\n" +
-			"bond-cli --url http://localhost:7777/bond entry-fields --table token_balances",
+			"bond-cli --url http://localhost:7777/bond entry-fields --table token_balances\n" +
+			"bond-cli --url http://localhost:7777/bond dump-key --table token_balances --key 0x0100...",
 		Flags: []cli.Flag{
 			_FlagBondURL,
 			_FlagHeaders,
@@ -238,6 +246,33 @@ func NewInspectCLI(init func(path string) (Inspect, error)) *cli.App {
 					return nil
 				},
 			},
+			{
+				Name:  "dump-key",
+				Usage: "decodes raw key and prints the row it points to",
+				Flags: []cli.Flag{
+					_FlagTable,
+					_FlagKey,
+					_FlagDeadline,
+				},
+				Action: func(ctx *cli.Context) error {
+					dumpCtx, cancel := context.WithDeadline(
+						context.Background(), time.Now().Add(ctx.Duration(_FlagDeadline.Name)))
+					defer cancel()
+
+					key, err := hex.DecodeString(strings.TrimPrefix(ctx.String(_FlagKey.Name), "0x"))
+					if err != nil {
+						return fmt.Errorf("invalid key: %w", err)
+					}
+
+					result, err := inspect.DumpKey(dumpCtx, ctx.String(_FlagTable.Name), key)
+					if err != nil {
+						return err
+					}
+
+					fmt.Print(result)
+					return nil
+				},
+			},
 		},
 		HideHelp:        true,
 		HideHelpCommand: true,
@@ -12,6 +12,7 @@ const (
 	IndexesPath     = "/indexes"
 	EntryFieldsPath = "/entryFields"
 	QueryPath       = "/query"
+	DumpKeyPath     = "/dumpKey"
 )
 
 func NewInspectHandler(inspect Inspect) http.HandlerFunc {
@@ -21,12 +22,14 @@ func NewInspectHandler(inspect Inspect) http.HandlerFunc {
 		endsInIndexes     = regexp.MustCompile(IndexesPath + "$")
 		endsInEntryFields = regexp.MustCompile(EntryFieldsPath + "$")
 		endsInQuery       = regexp.MustCompile(QueryPath + "$")
+		endsInDumpKey     = regexp.MustCompile(DumpKeyPath + "$")
 
 		// handlers
 		tablesHandler      = buildTablesHandler(inspect)
 		indexesHandler     = buildIndexesHandler(inspect)
 		entryFieldsHandler = buildEntryFieldsHandler(inspect)
 		queryHandler       = buildQueryHandler(inspect)
+		dumpKeyHandler     = buildDumpKeyHandler(inspect)
 	)
 
 	return func(writer http.ResponseWriter, request *http.Request) {
@@ -39,6 +42,8 @@ func NewInspectHandler(inspect Inspect) http.HandlerFunc {
 			entryFieldsHandler.ServeHTTP(writer, request)
 		case endsInQuery.Match([]byte(request.URL.Path)):
 			queryHandler.ServeHTTP(writer, request)
+		case endsInDumpKey.Match([]byte(request.URL.Path)):
+			dumpKeyHandler.ServeHTTP(writer, request)
 		default:
 			http.NotFound(writer, request)
 		}
@@ -227,6 +232,46 @@ func buildQueryHandler(inspect Inspect) http.HandlerFunc {
 	}
 }
 
+type requestDumpKey struct {
+	Table string `json:"table"`
+	Key   []byte `json:"key"`
+}
+
+func buildDumpKeyHandler(inspect Inspect) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		accept := request.Header.Get("Accept")
+		if accept == "" {
+			accept = "application/json"
+		}
+
+		data, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			writeErrorResponse(response, http.StatusInternalServerError, err)
+			return
+		}
+
+		var req requestDumpKey
+		err = json.Unmarshal(data, &req)
+		if err != nil {
+			writeErrorResponse(response, http.StatusInternalServerError, err)
+			return
+		}
+
+		switch accept {
+		case "application/json":
+			result, err := inspect.DumpKey(request.Context(), req.Table, req.Key)
+			if err != nil {
+				writeErrorResponse(response, http.StatusInternalServerError, err)
+				return
+			}
+
+			writeResponse(response, http.StatusOK, []byte(result))
+		default:
+			writeEmptyResponse(response, http.StatusNotAcceptable)
+		}
+	}
+}
+
 func writeResponse(response http.ResponseWriter, status int, data []byte) {
 	response.WriteHeader(status)
 	_, _ = response.Write(data)
@@ -19,6 +19,7 @@ type inspectClient struct {
 	indexesURL     string
 	entryFieldsURL string
 	queryURL       string
+	dumpKeyURL     string
 }
 
 func NewInspectRemote(url string, headers map[string]string) Inspect {
@@ -34,6 +35,7 @@ func NewInspectRemote(url string, headers map[string]string) Inspect {
 		indexesURL:     fmt.Sprintf("%s%s", url, IndexesPath),
 		entryFieldsURL: fmt.Sprintf("%s%s", url, EntryFieldsPath),
 		queryURL:       fmt.Sprintf("%s%s", url, QueryPath),
+		dumpKeyURL:     fmt.Sprintf("%s%s", url, DumpKeyPath),
 	}
 }
 
@@ -175,3 +177,36 @@ func (i *inspectClient) Query(ctx context.Context, table string, index string, i
 
 	return result, nil
 }
+
+func (i *inspectClient) DumpKey(ctx context.Context, table string, key []byte) (string, error) {
+	rqStruct := requestDumpKey{
+		Table: table,
+		Key:   key,
+	}
+
+	rqData, err := json.Marshal(rqStruct)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := i.client.R().
+		SetContext(ctx).
+		SetHeaders(i.headers).
+		SetBody(rqData).
+		Post(i.dumpKeyURL)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.IsError() {
+		respErr := responseError{}
+		err = json.Unmarshal(resp.Body(), &respErr)
+		if err != nil {
+			return "", fmt.Errorf("request failed with status(%s)", resp.Status())
+		}
+
+		return "", fmt.Errorf("request failed with status(%s), reason: %s", resp.Status(), respErr.Error)
+	}
+
+	return string(resp.Body()), nil
+}
@@ -407,4 +407,33 @@ func TestNewInspectHandler(t *testing.T) {
 		})
 	})
 
+	t.Run("DumpKey", func(t *testing.T) {
+		requestBody := requestDumpKey{
+			Table: "token_balance",
+			Key: bond.KeyEncode(bond.Key{
+				TableID:    table.ID(),
+				IndexID:    bond.PrimaryIndexID,
+				PrimaryKey: bond.NewKeyBuilder([]byte{}).AddUint64Field(1).Bytes(),
+			}),
+		}
+
+		requestBodyData, err := json.Marshal(requestBody)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(
+			"POST",
+			"/bond/dumpKey",
+			bytes.NewBuffer(requestBodyData))
+
+		mux.ServeHTTP(w, req)
+		require.Equal(t, 200, w.Code)
+
+		var result map[string]interface{}
+		err = json.Unmarshal(w.Body.Bytes(), &result)
+		require.NoError(t, err)
+
+		assert.Equal(t, "token_balance", result["table"])
+		assert.Equal(t, bond.PrimaryIndexName, result["index"])
+	})
 }
@@ -2,6 +2,7 @@ package inspect
 
 import (
 	"context"
+	"encoding/json"
 	"os"
 	"testing"
 
@@ -409,3 +410,46 @@ func TestInspect_Query(t *testing.T) {
 		assert.Equal(t, resp, expectedTokenBalance2)
 	})
 }
+
+func TestInspect_DumpKey(t *testing.T) {
+	db, table, _, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	err := table.Insert(context.Background(), []*TokenBalance{
+		{
+			ID:              1,
+			AccountID:       1,
+			ContractAddress: "0xc",
+			AccountAddress:  "0xa",
+			TokenID:         10,
+			Balance:         501,
+		},
+	})
+	require.NoError(t, err)
+
+	insp, err := NewInspect([]bond.TableInfo{table})
+	require.NoError(t, err)
+
+	key := bond.NewKeyBuilder([]byte{}).AddUint64Field(1).Bytes()
+	rawKey := bond.KeyEncode(bond.Key{
+		TableID:    table.ID(),
+		IndexID:    bond.PrimaryIndexID,
+		PrimaryKey: key,
+	})
+
+	resp, err := insp.DumpKey(context.Background(), "token_balance", rawKey)
+	require.NoError(t, err)
+
+	var row map[string]interface{}
+	err = json.Unmarshal([]byte(resp), &row)
+	require.NoError(t, err)
+
+	assert.Equal(t, "token_balance", row["table"])
+	assert.Equal(t, float64(501), row["value"].(map[string]interface{})["balance"])
+
+	_, err = insp.DumpKey(context.Background(), "token_balance", []byte{0x02})
+	require.Error(t, err)
+
+	_, err = insp.DumpKey(context.Background(), "unknown", rawKey)
+	require.Error(t, err)
+}
@@ -0,0 +1,113 @@
+package bond
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// _iteratorPool keeps closed iterators per table, so they can be reused by
+// the following queries instead of being constructed from scratch.
+//
+// The iterator reads from the state of the database at its creation, hence
+// the pooled iterator is only reused if there were no writes since then.
+type _iteratorPool struct {
+	pebble *pebble.DB
+
+	writeSeq *uint64
+	tables   [256]chan *_pooledIterator
+
+	closed bool
+	mutex  sync.RWMutex
+}
+
+type _pooledIterator struct {
+	*pebble.Iterator
+
+	pool     *_iteratorPool
+	tableID  TableID
+	writeSeq uint64
+}
+
+func newIteratorPool(pdb *pebble.DB, writeSeq *uint64, size int) *_iteratorPool {
+	pool := &_iteratorPool{
+		pebble:   pdb,
+		writeSeq: writeSeq,
+	}
+
+	for i := range pool.tables {
+		pool.tables[i] = make(chan *_pooledIterator, size)
+	}
+
+	return pool
+}
+
+func (p *_iteratorPool) Get(opt *IterOptions) Iterator {
+	pebbleOpt := pebbleIterOptions(opt)
+
+	var tableID TableID
+	if len(pebbleOpt.LowerBound) > 0 {
+		tableID = TableID(pebbleOpt.LowerBound[0])
+	}
+
+	writeSeq := atomic.LoadUint64(p.writeSeq)
+	for {
+		select {
+		case iter := <-p.tables[tableID]:
+			if iter.writeSeq != writeSeq {
+				_ = iter.Iterator.Close()
+				continue
+			}
+
+			iter.SetOptions(pebbleOpt)
+			return iter
+		default:
+			return &_pooledIterator{
+				Iterator: p.pebble.NewIter(pebbleOpt),
+				pool:     p,
+				tableID:  tableID,
+				writeSeq: writeSeq,
+			}
+		}
+	}
+}
+
+func (p *_iteratorPool) put(iter *_pooledIterator) error {
+	if err := iter.Error(); err != nil {
+		_ = iter.Iterator.Close()
+		return err
+	}
+
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.closed || iter.writeSeq != atomic.LoadUint64(p.writeSeq) {
+		return iter.Iterator.Close()
+	}
+
+	select {
+	case p.tables[iter.tableID] <- iter:
+		return nil
+	default:
+		return iter.Iterator.Close()
+	}
+}
+
+// Close closes all the pooled iterators. It needs to be called before
+// closing the database.
+func (p *_iteratorPool) Close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.closed = true
+	for _, table := range p.tables {
+		for len(table) > 0 {
+			_ = (<-table).Iterator.Close()
+		}
+	}
+}
+
+func (it *_pooledIterator) Close() error {
+	return it.pool.put(it)
+}
@@ -0,0 +1,63 @@
+package bond
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_IteratorPool(t *testing.T) {
+	db, err := Open(dbName, &Options{IteratorPoolSize: 2})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	pool := db.(*_db).iteratorPool
+	require.NotNil(t, pool)
+
+	tokenBalance := &TokenBalance{ID: 1, AccountAddress: "0xtestAccount", Balance: 5}
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	err = tokenBalanceTable.Query().Execute(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalance}, tokenBalances)
+	assert.Equal(t, 1, len(pool.tables[TokenBalanceTableID]))
+
+	// reuses the pooled iterator
+	err = tokenBalanceTable.Query().Execute(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalance}, tokenBalances)
+	assert.Equal(t, 1, len(pool.tables[TokenBalanceTableID]))
+
+	// the write makes pooled iterator stale
+	tokenBalance2 := &TokenBalance{ID: 2, AccountAddress: "0xtestAccount", Balance: 7}
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance2})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Query().Execute(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalance, tokenBalance2}, tokenBalances)
+	assert.Equal(t, 1, len(pool.tables[TokenBalanceTableID]))
+}
@@ -0,0 +1,354 @@
+package bond
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/go-bond/bond/utils"
+)
+
+// KeyFieldType is the type of the field written by KeyBuilder.
+type KeyFieldType uint8
+
+const (
+	KeyFieldTypeUnknown KeyFieldType = iota
+	KeyFieldTypeInt64
+	KeyFieldTypeInt32
+	KeyFieldTypeInt16
+	KeyFieldTypeUint64
+	KeyFieldTypeUint32
+	KeyFieldTypeUint16
+	KeyFieldTypeByte
+	KeyFieldTypeString
+	KeyFieldTypeBytes
+	KeyFieldTypeBigInt
+	KeyFieldTypeEscapedString
+	KeyFieldTypeEscapedBytes
+	KeyFieldTypePath
+	KeyFieldTypeTime
+)
+
+func (t KeyFieldType) String() string {
+	switch t {
+	case KeyFieldTypeInt64:
+		return "int64"
+	case KeyFieldTypeInt32:
+		return "int32"
+	case KeyFieldTypeInt16:
+		return "int16"
+	case KeyFieldTypeUint64:
+		return "uint64"
+	case KeyFieldTypeUint32:
+		return "uint32"
+	case KeyFieldTypeUint16:
+		return "uint16"
+	case KeyFieldTypeByte:
+		return "byte"
+	case KeyFieldTypeString:
+		return "string"
+	case KeyFieldTypeBytes:
+		return "bytes"
+	case KeyFieldTypeBigInt:
+		return "bigint"
+	case KeyFieldTypeEscapedString:
+		return "escaped_string"
+	case KeyFieldTypeEscapedBytes:
+		return "escaped_bytes"
+	case KeyFieldTypePath:
+		return "path"
+	case KeyFieldTypeTime:
+		return "time"
+	default:
+		return "unknown"
+	}
+}
+
+// KeyFieldVariableSize marks fields which encoded size depends on the value.
+const KeyFieldVariableSize = -1
+
+// KeyFieldSchema describes a single field written by KeyBuilder. The Size
+// is the number of bytes that follow the field id or KeyFieldVariableSize.
+type KeyFieldSchema struct {
+	ID   byte
+	Type KeyFieldType
+	Size int
+}
+
+// KeyField is the decoded key field.
+type KeyField struct {
+	KeyFieldSchema
+
+	Data []byte
+}
+
+// Value returns the field value converted back to its Go type.
+//
+// Note: The fields of IndexOrder are returned as they were stored, so
+// the DESC ordered fields carry the inverted value.
+func (f KeyField) Value() any {
+	switch f.Type {
+	case KeyFieldTypeInt64:
+		i := int64(binary.BigEndian.Uint64(f.Data[1:]))
+		if f.Data[0] == 0x00 {
+			return -(^i)
+		}
+		return i
+	case KeyFieldTypeInt32:
+		i := int32(binary.BigEndian.Uint32(f.Data[1:]))
+		if f.Data[0] == 0x00 {
+			return -(^i)
+		}
+		return i
+	case KeyFieldTypeInt16:
+		i := int16(binary.BigEndian.Uint16(f.Data[1:]))
+		if f.Data[0] == 0x00 {
+			return -(^i)
+		}
+		return i
+	case KeyFieldTypeUint64:
+		return binary.BigEndian.Uint64(f.Data)
+	case KeyFieldTypeUint32:
+		return binary.BigEndian.Uint32(f.Data)
+	case KeyFieldTypeUint16:
+		return binary.BigEndian.Uint16(f.Data)
+	case KeyFieldTypeByte:
+		return f.Data[0]
+	case KeyFieldTypeString:
+		return string(f.Data)
+	case KeyFieldTypeEscapedString:
+		unescaped, _, _ := unescape(f.Data)
+		return string(unescaped)
+	case KeyFieldTypeEscapedBytes:
+		unescaped, _, _ := unescape(f.Data)
+		return unescaped
+	case KeyFieldTypePath:
+		segments, _, _ := unescapePath(f.Data)
+		return strings.Join(segments, keyPathSeparator)
+	case KeyFieldTypeTime:
+		i := int64(binary.BigEndian.Uint64(f.Data[1:]))
+		if f.Data[0] == 0x00 {
+			i = -(^i)
+		}
+		return time.Unix(0, i).UTC()
+	case KeyFieldTypeBigInt:
+		magnitude := make([]byte, len(f.Data)-1)
+		copy(magnitude, f.Data[1:])
+		if f.Data[0] == 0x00 {
+			for i := range magnitude {
+				magnitude[i] = 0xFF - magnitude[i]
+			}
+			return big.NewInt(0).Neg(big.NewInt(0).SetBytes(magnitude))
+		}
+		return big.NewInt(0).SetBytes(magnitude)
+	default:
+		return append([]byte{}, f.Data...)
+	}
+}
+
+// DecodedKey is the key broken down into the fields defined by the
+// table primary key function and index key and order functions.
+type DecodedKey struct {
+	TableID    TableID
+	IndexID    IndexID
+	IndexName  string
+	IndexKey   []KeyField
+	IndexOrder []KeyField
+	PrimaryKey []KeyField
+}
+
+// KeyDecoder decodes raw keys of the table back into field values. The key
+// layout is learned by running the key functions against an empty row,
+// therefore the key functions that add fields conditionally can not be decoded.
+type KeyDecoder[T any] struct {
+	table *_table[T]
+}
+
+func NewKeyDecoder[T any](table Table[T]) (*KeyDecoder[T], error) {
+	t, ok := table.(*_table[T])
+	if !ok {
+		return nil, fmt.Errorf("key decoder requires table created with NewTable")
+	}
+	return &KeyDecoder[T]{table: t}, nil
+}
+
+// Decode decodes the raw key that belongs to the table.
+func (d *KeyDecoder[T]) Decode(key []byte) (DecodedKey, error) {
+	if err := keyValidate(key); err != nil {
+		return DecodedKey{}, err
+	}
+
+	var rawKey Key
+	if keyIsPrefix(key) {
+		rawKey = Key{
+			TableID:  KeyBytes(key).TableID(),
+			IndexID:  KeyBytes(key).IndexID(),
+			IndexKey: KeyBytes(key).IndexKey(),
+		}
+	} else {
+		rawKey = KeyDecode(key)
+	}
+
+	if rawKey.TableID != d.table.storageID() {
+		return DecodedKey{}, fmt.Errorf("key table id %d does not match table %s id %d",
+			rawKey.TableID, d.table.name, d.table.storageID())
+	}
+
+	idx, err := d.index(rawKey.IndexID)
+	if err != nil {
+		return DecodedKey{}, err
+	}
+
+	var (
+		empty  = utils.MakeNew[T]()
+		result = DecodedKey{
+			TableID:   d.table.id,
+			IndexID:   rawKey.IndexID,
+			IndexName: idx.IndexName,
+		}
+	)
+
+	result.IndexKey, err = decodeKeyFields(rawKey.IndexKey, keySchema(func(builder KeyBuilder) []byte {
+		return idx.IndexKeyFunction(builder, empty)
+	}))
+	if err != nil {
+		return DecodedKey{}, fmt.Errorf("failed to decode index key: %w", err)
+	}
+
+	if keyIsPrefix(key) {
+		return result, nil
+	}
+
+	result.IndexOrder, err = decodeKeyFields(rawKey.IndexOrder, keySchema(func(builder KeyBuilder) []byte {
+		return idx.IndexOrderFunction(IndexOrder{keyBuilder: builder}, empty).Bytes()
+	}))
+	if err != nil {
+		return DecodedKey{}, fmt.Errorf("failed to decode index order: %w", err)
+	}
+
+	result.PrimaryKey, err = decodeKeyFields(rawKey.PrimaryKey, keySchema(func(builder KeyBuilder) []byte {
+		return d.table.primaryKeyFunc(builder, empty)
+	}))
+	if err != nil {
+		return DecodedKey{}, fmt.Errorf("failed to decode primary key: %w", err)
+	}
+
+	return result, nil
+}
+
+func (d *KeyDecoder[T]) index(id IndexID) (*Index[T], error) {
+	if id == PrimaryIndexID {
+		return d.table.primaryIndex, nil
+	}
+
+	d.table.mutex.RLock()
+	defer d.table.mutex.RUnlock()
+
+	idx, ok := d.table.secondaryIndexes[id]
+	if !ok {
+		return nil, d.table.newError(nil, nil, fmt.Errorf("index 0x%02x: %w", id, ErrIndexNotRegistered))
+	}
+	return idx, nil
+}
+
+func keySchema(keyFunc func(builder KeyBuilder) []byte) []KeyFieldSchema {
+	var (
+		buffer [DataKeyBufferSize]byte
+		schema = make([]KeyFieldSchema, 0)
+	)
+
+	keyFunc(KeyBuilder{buff: buffer[:0], schema: &schema})
+	return schema
+}
+
+func keyValidate(key []byte) error {
+	if len(key) < 6 {
+		return fmt.Errorf("key too short: %d bytes", len(key))
+	}
+
+	indexKeyLen := int(binary.BigEndian.Uint32(key[2:6]))
+	if len(key) < 6+indexKeyLen {
+		return fmt.Errorf("key too short for index key length %d", indexKeyLen)
+	} else if len(key) == 6+indexKeyLen {
+		return nil
+	}
+
+	if len(key) < 10+indexKeyLen {
+		return fmt.Errorf("key too short for index key length %d", indexKeyLen)
+	}
+
+	indexOrderLen := int(binary.BigEndian.Uint32(key[6+indexKeyLen : 10+indexKeyLen]))
+	if len(key) < 10+indexKeyLen+indexOrderLen {
+		return fmt.Errorf("key too short for index order length %d", indexOrderLen)
+	}
+	return nil
+}
+
+func keyIsPrefix(key []byte) bool {
+	return len(key) == _KeyPrefixSplitIndex(key)
+}
+
+func decodeKeyFields(data []byte, schema []KeyFieldSchema) ([]KeyField, error) {
+	fields := make([]KeyField, 0, len(schema))
+
+	pos := 0
+	for i, fieldSchema := range schema {
+		if pos >= len(data) || data[pos] != fieldSchema.ID {
+			return nil, fmt.Errorf("field %d (%s) not found at offset %d", fieldSchema.ID, fieldSchema.Type, pos)
+		}
+		pos++
+
+		size := fieldSchema.Size
+		if fieldSchema.Type == KeyFieldTypeEscapedString || fieldSchema.Type == KeyFieldTypeEscapedBytes {
+			_, escapedSize, err := unescape(data[pos:])
+			if err != nil {
+				return nil, fmt.Errorf("field %d (%s): %w", fieldSchema.ID, fieldSchema.Type, err)
+			}
+			size = escapedSize
+		} else if fieldSchema.Type == KeyFieldTypePath {
+			_, pathSize, err := unescapePath(data[pos:])
+			if err != nil {
+				return nil, fmt.Errorf("field %d (%s): %w", fieldSchema.ID, fieldSchema.Type, err)
+			}
+			size = pathSize
+		} else if size == KeyFieldVariableSize {
+			size = variableKeyFieldSize(data[pos:], schema[i+1:])
+		}
+
+		if size < 0 || pos+size > len(data) {
+			return nil, fmt.Errorf("field %d (%s) exceeds key length", fieldSchema.ID, fieldSchema.Type)
+		}
+
+		fields = append(fields, KeyField{KeyFieldSchema: fieldSchema, Data: data[pos : pos+size]})
+		pos += size
+	}
+
+	if pos != len(data) {
+		return nil, fmt.Errorf("unexpected %d trailing bytes", len(data)-pos)
+	}
+
+	return fields, nil
+}
+
+func variableKeyFieldSize(data []byte, nextFields []KeyFieldSchema) int {
+	fixedSize := 0
+	for _, next := range nextFields {
+		if next.Size == KeyFieldVariableSize {
+			// find the first split at which the rest of the fields decode
+			for i, b := range data {
+				if b != nextFields[0].ID {
+					continue
+				}
+
+				if _, err := decodeKeyFields(data[i:], nextFields); err == nil {
+					return i
+				}
+			}
+			return -1
+		}
+		fixedSize += 1 + next.Size
+	}
+	return len(data) - fixedSize
+}
@@ -0,0 +1,187 @@
+package bond
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyField_Value(t *testing.T) {
+	var buffer [1024]byte
+
+	kb := NewKeyBuilder(buffer[:0]).
+		AddInt64Field(-10).
+		AddInt32Field(10).
+		AddInt16Field(0).
+		AddUint64Field(5).
+		AddStringField("abc").
+		AddBigIntField(big.NewInt(-1000), 256)
+
+	fields, err := decodeKeyFields(kb.Bytes(), keySchema(func(builder KeyBuilder) []byte {
+		return builder.
+			AddInt64Field(0).
+			AddInt32Field(0).
+			AddInt16Field(0).
+			AddUint64Field(0).
+			AddStringField("").
+			AddBigIntField(big.NewInt(0), 256).
+			Bytes()
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 6, len(fields))
+
+	assert.Equal(t, int64(-10), fields[0].Value())
+	assert.Equal(t, int32(10), fields[1].Value())
+	assert.Equal(t, int16(0), fields[2].Value())
+	assert.Equal(t, uint64(5), fields[3].Value())
+	assert.Equal(t, "abc", fields[4].Value())
+	assert.Equal(t, big.NewInt(-1000), fields[5].Value())
+	assert.Equal(t, KeyFieldTypeString, fields[4].Type)
+}
+
+func TestKeyField_Value_Escaped(t *testing.T) {
+	var buffer [1024]byte
+
+	kb := NewKeyBuilder(buffer[:0]).
+		AddEscapedStringField("a\x00b").
+		AddStringField("c").
+		AddEscapedBytesField([]byte{0x02, 0x01}).
+		AddUint16Field(3)
+
+	fields, err := decodeKeyFields(kb.Bytes(), keySchema(func(builder KeyBuilder) []byte {
+		return builder.
+			AddEscapedStringField("").
+			AddStringField("").
+			AddEscapedBytesField(nil).
+			AddUint16Field(0).
+			Bytes()
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 4, len(fields))
+
+	assert.Equal(t, "a\x00b", fields[0].Value())
+	assert.Equal(t, "c", fields[1].Value())
+	assert.Equal(t, []byte{0x02, 0x01}, fields[2].Value())
+	assert.Equal(t, uint16(3), fields[3].Value())
+}
+
+func TestKeyField_Value_Path(t *testing.T) {
+	var buffer [1024]byte
+
+	kb := NewKeyBuilder(buffer[:0]).
+		AddPathField("/org/team\x00/member").
+		AddPathField("").
+		AddUint16Field(3)
+
+	fields, err := decodeKeyFields(kb.Bytes(), keySchema(func(builder KeyBuilder) []byte {
+		return builder.
+			AddPathField("").
+			AddPathField("").
+			AddUint16Field(0).
+			Bytes()
+	}))
+	require.NoError(t, err)
+	require.Equal(t, 3, len(fields))
+
+	assert.Equal(t, KeyFieldTypePath, fields[0].Type)
+	assert.Equal(t, "org/team\x00/member", fields[0].Value())
+	assert.Equal(t, "", fields[1].Value())
+	assert.Equal(t, uint16(3), fields[2].Value())
+}
+
+func TestKeyDecoder_Decode(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID TableID = 0xC0
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	const (
+		_                                 = PrimaryIndexID
+		TokenBalanceAccountAddressIndexID = IndexID(iota)
+	)
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   TokenBalanceAccountAddressIndexID,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).AddUint32Field(tb.TokenID).Bytes()
+		},
+		IndexOrderFunc: func(o IndexOrder, tb *TokenBalance) IndexOrder {
+			return o.OrderUint64(tb.Balance, IndexOrderTypeASC)
+		},
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{
+			ID:             1,
+			AccountAddress: "0xtestAccount",
+			TokenID:        7,
+			Balance:        15,
+		},
+	})
+	require.NoError(t, err)
+
+	decoder, err := NewKeyDecoder(tokenBalanceTable)
+	require.NoError(t, err)
+
+	iter := tokenBalanceTable.Iter(nil)
+	defer func() { _ = iter.Close() }()
+
+	var decodedKeys []DecodedKey
+	for iter.First(); iter.Valid(); iter.Next() {
+		decodedKey, err := decoder.Decode(iter.Key())
+		require.NoError(t, err)
+
+		decodedKeys = append(decodedKeys, decodedKey)
+	}
+	require.Equal(t, 2, len(decodedKeys))
+
+	dataKey := decodedKeys[0]
+	assert.Equal(t, TokenBalanceTableID, dataKey.TableID)
+	assert.Equal(t, PrimaryIndexID, dataKey.IndexID)
+	assert.Equal(t, PrimaryIndexName, dataKey.IndexName)
+	assert.Equal(t, 0, len(dataKey.IndexKey))
+	require.Equal(t, 1, len(dataKey.PrimaryKey))
+	assert.Equal(t, uint64(1), dataKey.PrimaryKey[0].Value())
+
+	indexKey := decodedKeys[1]
+	assert.Equal(t, TokenBalanceAccountAddressIndexID, indexKey.IndexID)
+	assert.Equal(t, "account_address_idx", indexKey.IndexName)
+	require.Equal(t, 2, len(indexKey.IndexKey))
+	assert.Equal(t, "0xtestAccount", indexKey.IndexKey[0].Value())
+	assert.Equal(t, uint32(7), indexKey.IndexKey[1].Value())
+	require.Equal(t, 1, len(indexKey.IndexOrder))
+	assert.Equal(t, uint64(15), indexKey.IndexOrder[0].Value())
+	require.Equal(t, 1, len(indexKey.PrimaryKey))
+	assert.Equal(t, uint64(1), indexKey.PrimaryKey[0].Value())
+
+	prefixKey, err := decoder.Decode(tokenBalanceTable.(*_table[*TokenBalance]).keyPrefix(
+		TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount", TokenID: 7}, nil))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(prefixKey.IndexKey))
+	assert.Equal(t, "0xtestAccount", prefixKey.IndexKey[0].Value())
+	assert.Equal(t, 0, len(prefixKey.PrimaryKey))
+
+	_, err = decoder.Decode([]byte{byte(TokenBalanceTableID), 0x05, 0x00, 0x00, 0x00, 0x00})
+	require.Error(t, err)
+
+	_, err = decoder.Decode([]byte{0x01})
+	require.Error(t, err)
+}
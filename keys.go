@@ -3,7 +3,10 @@ package bond
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"math/big"
+	"strings"
+	"time"
 
 	"github.com/cockroachdb/pebble"
 )
@@ -11,6 +14,8 @@ import (
 type KeyBuilder struct {
 	buff []byte
 	fid  byte
+
+	schema *[]KeyFieldSchema
 }
 
 func NewKeyBuilder(buff []byte) KeyBuilder {
@@ -19,23 +24,40 @@ func NewKeyBuilder(buff []byte) KeyBuilder {
 
 func (b KeyBuilder) AddInt64Field(i int64) KeyBuilder {
 	bt := b.putFieldID()
+	bt.record(KeyFieldTypeInt64, 9)
+	bt.buff = appendInt64(bt.buff, i)
+	return bt
+}
+
+// AddTimeField adds the time field stored as the nanoseconds since the Unix
+// epoch, so the times sort chronologically, see Query.WithTimeRange. The times
+// before the year 1678 and after the year 2262 can not be stored.
+func (b KeyBuilder) AddTimeField(t time.Time) KeyBuilder {
+	bt := b.putFieldID()
+	bt.record(KeyFieldTypeTime, 9)
+	bt.buff = appendInt64(bt.buff, t.UnixNano())
+	return bt
+}
 
+// appendInt64 appends the sign byte and the big-endian integer.
+func appendInt64(buff []byte, i int64) []byte {
 	if i > 0 {
-		bt.buff = append(bt.buff, 0x02)
+		buff = append(buff, 0x02)
 	} else if i == 0 {
-		bt.buff = append(bt.buff, 0x01)
+		buff = append(buff, 0x01)
 	} else {
-		bt.buff = append(bt.buff, 0x00)
+		buff = append(buff, 0x00)
 		i = ^-i
 	}
 
-	bt.buff = append(bt.buff, []byte{0, 0, 0, 0, 0, 0, 0, 0}...)
-	binary.BigEndian.PutUint64(bt.buff[len(bt.buff)-8:], uint64(i))
-	return bt
+	buff = append(buff, []byte{0, 0, 0, 0, 0, 0, 0, 0}...)
+	binary.BigEndian.PutUint64(buff[len(buff)-8:], uint64(i))
+	return buff
 }
 
 func (b KeyBuilder) AddInt32Field(i int32) KeyBuilder {
 	bt := b.putFieldID()
+	bt.record(KeyFieldTypeInt32, 5)
 
 	if i > 0 {
 		bt.buff = append(bt.buff, 0x02)
@@ -53,6 +75,7 @@ func (b KeyBuilder) AddInt32Field(i int32) KeyBuilder {
 
 func (b KeyBuilder) AddInt16Field(i int16) KeyBuilder {
 	bt := b.putFieldID()
+	bt.record(KeyFieldTypeInt16, 3)
 
 	if i > 0 {
 		bt.buff = append(bt.buff, 0x02)
@@ -70,6 +93,7 @@ func (b KeyBuilder) AddInt16Field(i int16) KeyBuilder {
 
 func (b KeyBuilder) AddUint64Field(i uint64) KeyBuilder {
 	bt := b.putFieldID()
+	bt.record(KeyFieldTypeUint64, 8)
 	bt.buff = append(bt.buff, []byte{0, 0, 0, 0, 0, 0, 0, 0}...)
 	binary.BigEndian.PutUint64(bt.buff[len(bt.buff)-8:], i)
 	return bt
@@ -77,6 +101,7 @@ func (b KeyBuilder) AddUint64Field(i uint64) KeyBuilder {
 
 func (b KeyBuilder) AddUint32Field(i uint32) KeyBuilder {
 	bt := b.putFieldID()
+	bt.record(KeyFieldTypeUint32, 4)
 	bt.buff = append(bt.buff, []byte{0, 0, 0, 0}...)
 	binary.BigEndian.PutUint32(bt.buff[len(bt.buff)-4:], i)
 	return bt
@@ -84,6 +109,7 @@ func (b KeyBuilder) AddUint32Field(i uint32) KeyBuilder {
 
 func (b KeyBuilder) AddUint16Field(i uint16) KeyBuilder {
 	bt := b.putFieldID()
+	bt.record(KeyFieldTypeUint16, 2)
 	bt.buff = append(bt.buff, []byte{0, 0}...)
 	binary.BigEndian.PutUint16(bt.buff[len(bt.buff)-2:], i)
 	return bt
@@ -91,24 +117,66 @@ func (b KeyBuilder) AddUint16Field(i uint16) KeyBuilder {
 
 func (b KeyBuilder) AddByteField(btt byte) KeyBuilder {
 	bt := b.putFieldID()
+	bt.record(KeyFieldTypeByte, 1)
 	bt.buff = append(bt.buff, btt)
 	return bt
 }
 
 func (b KeyBuilder) AddStringField(s string) KeyBuilder {
 	bt := b.putFieldID()
+	bt.record(KeyFieldTypeString, KeyFieldVariableSize)
 	bt.buff = append(bt.buff, []byte(s)...)
 	return bt
 }
 
 func (b KeyBuilder) AddBytesField(bs []byte) KeyBuilder {
 	bt := b.putFieldID()
+	bt.record(KeyFieldTypeBytes, KeyFieldVariableSize)
 	bt.buff = append(bt.buff, bs...)
 	return bt
 }
 
+// AddEscapedStringField adds string field that is escaped and terminated so
+// that it can be followed by other variable length fields without making the
+// composite key ambiguous ("ab"+"c" vs "a"+"bc"). The byte order of escaped
+// fields is the same as the order of the original strings.
+func (b KeyBuilder) AddEscapedStringField(s string) KeyBuilder {
+	bt := b.putFieldID()
+	bt.record(KeyFieldTypeEscapedString, KeyFieldVariableSize)
+	bt.buff = appendEscaped(bt.buff, []byte(s))
+	return bt
+}
+
+// AddEscapedBytesField adds bytes field that is escaped and terminated. See
+// AddEscapedStringField.
+func (b KeyBuilder) AddEscapedBytesField(bs []byte) KeyBuilder {
+	bt := b.putFieldID()
+	bt.record(KeyFieldTypeEscapedBytes, KeyFieldVariableSize)
+	bt.buff = appendEscaped(bt.buff, bs)
+	return bt
+}
+
+// AddPathField adds the hierarchical path field, e.g. "org/team/member". The
+// path is split into the segments by "/", the empty segments are ignored.
+// The segments are escaped and terminated one by one and the path ends with
+// its own terminator, so the keys of the subtree of the path start with the
+// path without the terminator, see Query.WithSubtree, and the path sorts
+// before its descendants.
+func (b KeyBuilder) AddPathField(path string) KeyBuilder {
+	bt := b.putFieldID()
+	bt.record(KeyFieldTypePath, KeyFieldVariableSize)
+	for _, segment := range strings.Split(path, keyPathSeparator) {
+		if segment != "" {
+			bt.buff = appendEscaped(bt.buff, []byte(segment))
+		}
+	}
+	bt.buff = append(bt.buff, keyEscapeByte, keyPathTerminator)
+	return bt
+}
+
 func (b KeyBuilder) AddBigIntField(bi *big.Int, bits int) KeyBuilder {
 	bt := b.putFieldID()
+	bt.record(KeyFieldTypeBigInt, 1+bits/8)
 
 	sign := bi.Sign() + 1
 	bt.buff = append(bt.buff, byte(sign)) // 0 - negative, 1 - zero, 2 - positive
@@ -133,10 +201,87 @@ func (b KeyBuilder) AddBigIntField(bi *big.Int, bits int) KeyBuilder {
 	return bt
 }
 
+const (
+	keyEscapeByte       = 0x00
+	keyEscapedZeroByte  = 0xFF
+	keyEscapeTerminator = 0x01
+
+	keyPathTerminator = 0x00
+	keyPathSeparator  = "/"
+)
+
+// appendEscaped appends bs with every 0x00 replaced by 0x00 0xFF and the
+// 0x00 0x01 terminator at the end.
+func appendEscaped(buff []byte, bs []byte) []byte {
+	for _, b := range bs {
+		if b == keyEscapeByte {
+			buff = append(buff, keyEscapeByte, keyEscapedZeroByte)
+		} else {
+			buff = append(buff, b)
+		}
+	}
+	return append(buff, keyEscapeByte, keyEscapeTerminator)
+}
+
+// unescape returns the original bytes of escaped field and the number of
+// bytes the escaped field occupies including terminator.
+func unescape(data []byte) ([]byte, int, error) {
+	var unescaped []byte
+	for i := 0; i < len(data); i++ {
+		if data[i] != keyEscapeByte {
+			unescaped = append(unescaped, data[i])
+			continue
+		}
+
+		if i+1 >= len(data) {
+			return nil, 0, fmt.Errorf("escaped field is not terminated")
+		}
+
+		switch data[i+1] {
+		case keyEscapedZeroByte:
+			unescaped = append(unescaped, keyEscapeByte)
+			i++
+		case keyEscapeTerminator:
+			return unescaped, i + 2, nil
+		default:
+			return nil, 0, fmt.Errorf("invalid escape sequence 0x%02x", data[i+1])
+		}
+	}
+	return nil, 0, fmt.Errorf("escaped field is not terminated")
+}
+
+// unescapePath returns the segments of the path field and the number of bytes
+// the path field occupies including terminator.
+func unescapePath(data []byte) ([]string, int, error) {
+	var (
+		segments []string
+		pos      int
+	)
+	for {
+		if pos+1 < len(data) && data[pos] == keyEscapeByte && data[pos+1] == keyPathTerminator {
+			return segments, pos + 2, nil
+		}
+
+		segment, size, err := unescape(data[pos:])
+		if err != nil {
+			return nil, 0, fmt.Errorf("path segment %d: %w", len(segments), err)
+		}
+		segments = append(segments, string(segment))
+		pos += size
+	}
+}
+
 func (b KeyBuilder) putFieldID() KeyBuilder {
 	return KeyBuilder{
-		buff: append(b.buff, b.fid+1),
-		fid:  b.fid + 1,
+		buff:   append(b.buff, b.fid+1),
+		fid:    b.fid + 1,
+		schema: b.schema,
+	}
+}
+
+func (b KeyBuilder) record(fieldType KeyFieldType, size int) {
+	if b.schema != nil {
+		*b.schema = append(*b.schema, KeyFieldSchema{ID: b.fid, Type: fieldType, Size: size})
 	}
 }
 
@@ -255,6 +400,10 @@ func KeyDecode(keyBytes []byte) Key {
 
 type KeyBytes []byte
 
+// PrimaryKey is the encoded primary key of the row as built by
+// TablePrimaryKeyFunc.
+type PrimaryKey []byte
+
 func (key KeyBytes) ToDataKeyBytes(rawBuffs ...[]byte) KeyBytes {
 	var rawBuff []byte
 	if len(rawBuffs) > 0 && rawBuffs[0] != nil {
@@ -290,6 +439,12 @@ func (key KeyBytes) IndexKey() []byte {
 	return key[6 : 6+keyLen]
 }
 
+func (key KeyBytes) PrimaryKey() PrimaryKey {
+	indexKeyLen := int(binary.BigEndian.Uint32(key[2:6]))
+	indexOrderLen := int(binary.BigEndian.Uint32(key[6+indexKeyLen : 10+indexKeyLen]))
+	return PrimaryKey(key[10+indexKeyLen+indexOrderLen:])
+}
+
 func (key KeyBytes) ToKey() Key {
 	return KeyDecode(key)
 }
@@ -301,5 +456,68 @@ func DefaultKeyComparer() *pebble.Comparer {
 }
 
 func _KeyPrefixSplitIndex(rawKey []byte) int {
-	return 6 + int(binary.BigEndian.Uint32(rawKey[2:6]))
+	// the bond data keys of the sketches, the statistics and the references
+	// do not follow the key layout, so the whole key is their prefix
+	if len(rawKey) < 6 {
+		return len(rawKey)
+	}
+
+	split := 6 + int(binary.BigEndian.Uint32(rawKey[2:6]))
+	if split > len(rawKey) {
+		return len(rawKey)
+	}
+	return split
+}
+
+// FormatKey renders the key in human-readable form for logs and error
+// messages, e.g.:
+//
+//	table=0xc0 index=0x01 key=01"0xtestAccount"0200000007 order= pk=010000000000000001
+//
+// The printable runs of bytes are shown as quoted text and the rest as hex.
+func FormatKey(key []byte) string {
+	if err := keyValidate(key); err != nil {
+		return fmt.Sprintf("invalid=%x", key)
+	}
+
+	keyBytes := KeyBytes(key)
+
+	buff := bytes.NewBuffer(make([]byte, 0, len(key)*3))
+	_, _ = fmt.Fprintf(buff, "table=0x%02x index=0x%02x key=", byte(keyBytes.TableID()), byte(keyBytes.IndexID()))
+	formatKeySegment(buff, keyBytes.IndexKey())
+
+	if keyIsPrefix(key) {
+		return buff.String()
+	}
+
+	rawKey := KeyDecode(key)
+	buff.WriteString(" order=")
+	formatKeySegment(buff, rawKey.IndexOrder)
+	buff.WriteString(" pk=")
+	formatKeySegment(buff, rawKey.PrimaryKey)
+
+	return buff.String()
+}
+
+const formatKeyMinTextLen = 3
+
+func formatKeySegment(buff *bytes.Buffer, segment []byte) {
+	isPrintable := func(b byte) bool {
+		return b >= 0x20 && b < 0x7F && b != '"'
+	}
+
+	for i := 0; i < len(segment); {
+		end := i
+		for end < len(segment) && isPrintable(segment[end]) {
+			end++
+		}
+
+		if end-i >= formatKeyMinTextLen {
+			_, _ = fmt.Fprintf(buff, "%q", segment[i:end])
+			i = end
+		} else {
+			_, _ = fmt.Fprintf(buff, "%02x", segment[i])
+			i++
+		}
+	}
 }
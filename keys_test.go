@@ -1,8 +1,10 @@
 package bond
 
 import (
+	"bytes"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -64,6 +66,22 @@ func TestKeyBuilder_AddInt64Field(t *testing.T) {
 	assert.Equal(t, []byte{0x01, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF - 0x0a}, kb.Bytes())
 }
 
+func TestKeyBuilder_AddTimeField(t *testing.T) {
+	var buffer [1024]byte
+
+	kb := NewKeyBuilder(buffer[:0])
+	kb = kb.AddTimeField(time.Unix(0, 10))
+
+	assert.Equal(t, []byte{0x01, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x0a}, kb.Bytes())
+
+	field := KeyField{KeyFieldSchema: KeyFieldSchema{ID: 1, Type: KeyFieldTypeTime, Size: 9}, Data: kb.Bytes()[1:]}
+	assert.Equal(t, time.Unix(0, 10).UTC(), field.Value())
+
+	before := NewKeyBuilder([]byte{}).AddTimeField(time.Unix(-10, 0)).Bytes()
+	after := NewKeyBuilder([]byte{}).AddTimeField(time.Unix(10, 0)).Bytes()
+	assert.Equal(t, -1, bytes.Compare(before, after))
+}
+
 func TestKeyBuilder_AddUint16Field(t *testing.T) {
 	var buffer [1024]byte
 
@@ -118,6 +136,67 @@ func TestKeyBuilder_AddBytesField(t *testing.T) {
 	assert.Equal(t, []byte{0x01, 0xF1, 0x1F}, kb.Bytes())
 }
 
+func TestKeyBuilder_AddEscapedStringField(t *testing.T) {
+	var buffer [1024]byte
+
+	kb := NewKeyBuilder(buffer[:0])
+	kb = kb.AddEscapedStringField("a\x00c")
+
+	assert.Equal(t, []byte{0x01, 'a', 0x00, 0xFF, 'c', 0x00, 0x01}, kb.Bytes())
+}
+
+func TestKeyBuilder_AddEscapedBytesField(t *testing.T) {
+	var buffer [1024]byte
+
+	kb := NewKeyBuilder(buffer[:0])
+	kb = kb.AddEscapedBytesField([]byte{0xF1, 0x1F})
+
+	assert.Equal(t, []byte{0x01, 0xF1, 0x1F, 0x00, 0x01}, kb.Bytes())
+}
+
+func TestKeyBuilder_AddEscapedStringField_Unambiguous(t *testing.T) {
+	key := func(s1, s2 string) []byte {
+		return NewKeyBuilder([]byte{}).AddEscapedStringField(s1).AddEscapedStringField(s2).Bytes()
+	}
+
+	assert.NotEqual(t, key("ab", "c"), key("a", "bc"))
+
+	ordered := [][]string{
+		{"a", "z"},
+		{"a\x00", "a"},
+		{"a\x00\x00", ""},
+		{"ab", ""},
+		{"ab", "c"},
+		{"b", ""},
+	}
+	for i := 1; i < len(ordered); i++ {
+		assert.Equal(t, -1, bytes.Compare(
+			key(ordered[i-1][0], ordered[i-1][1]),
+			key(ordered[i][0], ordered[i][1]),
+		), "%v < %v", ordered[i-1], ordered[i])
+	}
+}
+
+func TestKeyBuilder_AddPathField(t *testing.T) {
+	var buffer [1024]byte
+
+	kb := NewKeyBuilder(buffer[:0])
+	kb = kb.AddPathField("/a//b\x00/")
+
+	assert.Equal(t, []byte{0x01, 'a', 0x00, 0x01, 'b', 0x00, 0xFF, 0x00, 0x01, 0x00, 0x00}, kb.Bytes())
+
+	key := func(path string) []byte {
+		return NewKeyBuilder([]byte{}).AddPathField(path).AddUint16Field(1).Bytes()
+	}
+
+	// the path sorts before its descendants
+	ordered := []string{"", "a", "a/b", "a/b/c", "a/b\x00", "a/bc", "b"}
+	for i := 1; i < len(ordered); i++ {
+		assert.Equal(t, -1, bytes.Compare(key(ordered[i-1]), key(ordered[i])),
+			"%q < %q", ordered[i-1], ordered[i])
+	}
+}
+
 func TestKeyBuilder_AddBigIntField(t *testing.T) {
 	var buffer [1024]byte
 
@@ -263,3 +342,42 @@ func Benchmark_KeyBuilder(b *testing.B) {
 			Bytes()
 	}
 }
+
+func TestFormatKey(t *testing.T) {
+	key := KeyEncode(Key{
+		TableID:    0xC0,
+		IndexID:    0x01,
+		IndexKey:   NewKeyBuilder([]byte{}).AddStringField("0xtestAccount").AddUint32Field(7).Bytes(),
+		IndexOrder: []byte{},
+		PrimaryKey: NewKeyBuilder([]byte{}).AddUint64Field(1).Bytes(),
+	})
+
+	assert.Equal(t, `table=0xc0 index=0x01 key=01"0xtestAccount"0200000007 order= pk=010000000000000001`, FormatKey(key))
+
+	keyPrefix := KeyEncode(Key{
+		TableID:    0xC0,
+		IndexID:    0x01,
+		IndexKey:   NewKeyBuilder([]byte{}).AddStringField("ab").Bytes(),
+		IndexOrder: []byte{},
+		PrimaryKey: []byte{},
+	})
+
+	assert.Equal(t, `table=0xc0 index=0x01 key=016162`, FormatKey(keyPrefix))
+	assert.Equal(t, `invalid=c001`, FormatKey([]byte{0xC0, 0x01}))
+}
+
+func TestKeyPrefixSplitIndex(t *testing.T) {
+	key := KeyEncode(Key{
+		TableID:    0xC0,
+		IndexID:    0x01,
+		IndexKey:   NewKeyBuilder([]byte{}).AddStringField("ab").Bytes(),
+		IndexOrder: []byte{},
+		PrimaryKey: NewKeyBuilder([]byte{}).AddUint64Field(1).Bytes(),
+	})
+
+	assert.Equal(t, 6+3, _KeyPrefixSplitIndex(key))
+
+	// the bond data keys outside of the key layout are split at their end
+	assert.Equal(t, 4, _KeyPrefixSplitIndex([]byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_INDEX_STATS_INDEX_ID, 0x01, 0x01}))
+	assert.Equal(t, 9, _KeyPrefixSplitIndex([]byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_INDEX_SKETCH_INDEX_ID, 0x01, 0x01, 0x09, 0x09, 0x09, 0x09, 0x09}))
+}
@@ -1,9 +1,32 @@
 package bond
 
+import "github.com/go-bond/bond/utils"
+
 type Lazy[T any] struct {
-	GetFunc func() (T, error)
+	GetFunc     func() (T, error)
+	GetIntoFunc func(t *T) error
+
+	// indexValue is the value of the secondary index entry
+	indexValue []byte
 }
 
 func (l Lazy[T]) Get() (T, error) {
 	return l.GetFunc()
 }
+
+// GetInto retrieves the value into provided destination. If the destination
+// holds pointer to an existing object, the object is reset and reused.
+func (l Lazy[T]) GetInto(t *T) error {
+	if l.GetIntoFunc == nil {
+		value, err := l.GetFunc()
+		if err != nil {
+			return err
+		}
+
+		*t = value
+		return nil
+	}
+
+	utils.ResetPointer(*t)
+	return l.GetIntoFunc(t)
+}
@@ -0,0 +1,177 @@
+package bond
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+)
+
+// LogTailPageSize is the number of the log entries Tail reads at once.
+const LogTailPageSize = 1000
+
+type LogOptions struct {
+	DB DB
+
+	TableID   TableID
+	TableName string
+}
+
+// LogEntry is the entry of the log. The offsets of the entries start at 1 and
+// increase in the order the entries are appended.
+type LogEntry[T any] struct {
+	Offset uint64
+	Entry  T
+}
+
+// Log is the append-only log of the entries stored in the table. The entries
+// are never updated, they are read in the order of their offsets with Tail,
+// which makes the log the primitive for the event sourcing next to the state
+// tables of the same database, e.g. the events and the state they produce can
+// be written with the same batch.
+type Log[T any] struct {
+	db DB
+
+	entries Table[*LogEntry[T]]
+
+	nextOffset uint64
+
+	// appended is closed and replaced when the entries are appended
+	appended chan struct{}
+
+	mutex sync.Mutex
+}
+
+func NewLog[T any](opt LogOptions) (*Log[T], error) {
+	entries, err := RegisterTable[*LogEntry[T]](TableOptions[*LogEntry[T]]{
+		DB:        opt.DB,
+		TableID:   opt.TableID,
+		TableName: opt.TableName,
+		TablePrimaryKeyFunc: func(builder KeyBuilder, e *LogEntry[T]) []byte {
+			return builder.AddUint64Field(e.Offset).Bytes()
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log[T]{
+		db:         opt.DB,
+		entries:    entries,
+		nextOffset: 1,
+		appended:   make(chan struct{}),
+	}
+
+	iter := entries.Iter(nil)
+	if iter.Last() {
+		primaryKey := KeyBytes(iter.Key()).PrimaryKey()
+		l.nextOffset = binary.BigEndian.Uint64(primaryKey[len(primaryKey)-8:]) + 1
+	}
+
+	err = iter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Append appends the entries to the log and returns their offsets. The
+// entries are written with the batch if it's provided, they are visible to
+// Tail once the batch is committed. The batches need to be committed in the
+// order of the appends, as Tail does not return to the skipped offsets.
+func (l *Log[T]) Append(ctx context.Context, entries []T, optBatch ...Batch) ([]uint64, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	offsets := make([]uint64, 0, len(entries))
+	logEntries := make([]*LogEntry[T], 0, len(entries))
+	for i, entry := range entries {
+		offset := l.nextOffset + uint64(i)
+		offsets = append(offsets, offset)
+		logEntries = append(logEntries, &LogEntry[T]{Offset: offset, Entry: entry})
+	}
+
+	err := l.entries.Insert(ctx, logEntries, optBatch...)
+	if err != nil {
+		return nil, err
+	}
+
+	l.nextOffset += uint64(len(entries))
+
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		optBatch[0].OnCommitted(func(_ Batch) {
+			l.notifyAppended()
+		})
+	} else {
+		l.notifyAppendedLocked()
+	}
+
+	return offsets, nil
+}
+
+// notifyAppended wakes up the tailing readers.
+func (l *Log[T]) notifyAppended() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.notifyAppendedLocked()
+}
+
+func (l *Log[T]) notifyAppendedLocked() {
+	close(l.appended)
+	l.appended = make(chan struct{})
+}
+
+// Tail calls f with the entries starting at the offset, in the order of their
+// offsets. Once all the entries are read it waits for the new entries to be
+// appended. It returns when the context is done or f returns an error.
+//
+// Example:
+//
+//	err := events.Tail(ctx, projection.Offset+1, func(e *bond.LogEntry[*Event]) error {
+//		return projection.Apply(e)
+//	})
+func (l *Log[T]) Tail(ctx context.Context, fromOffset uint64, f func(entry *LogEntry[T]) error) error {
+	next := fromOffset
+	for {
+		l.mutex.Lock()
+		appended := l.appended
+		l.mutex.Unlock()
+
+		var entries []*LogEntry[T]
+		err := l.entries.Query().
+			With(l.entries.PrimaryIndex(), &LogEntry[T]{Offset: next}).
+			Limit(LogTailPageSize).
+			Execute(ctx, &entries)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			err = f(entry)
+			if err != nil {
+				return err
+			}
+			next = entry.Offset + 1
+		}
+
+		if len(entries) == LogTailPageSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		case <-appended:
+		}
+	}
+}
+
+// NextOffset returns the offset of the next appended entry.
+func (l *Log[T]) NextOffset() uint64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.nextOffset
+}
@@ -0,0 +1,64 @@
+package bond
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Log(t *testing.T) {
+	db := setupDatabase()
+	defer func() {
+		tearDownDatabase(db)
+	}()
+
+	log, err := NewLog[string](LogOptions{DB: db, TableID: 1, TableName: "events"})
+	require.NoError(t, err)
+
+	offsets, err := log.Append(context.Background(), []string{"created", "updated"})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, offsets)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	received := make(chan *LogEntry[string])
+	tailErr := make(chan error, 1)
+	go func() {
+		tailErr <- log.Tail(ctx, 2, func(entry *LogEntry[string]) error {
+			received <- entry
+			return nil
+		})
+	}()
+
+	assert.Equal(t, &LogEntry[string]{Offset: 2, Entry: "updated"}, <-received)
+
+	offsets, err = log.Append(context.Background(), []string{"deleted"})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3}, offsets)
+	assert.Equal(t, &LogEntry[string]{Offset: 3, Entry: "deleted"}, <-received)
+
+	// the entries appended with the batch are tailed once it's committed
+	batch := db.Batch()
+	offsets, err = log.Append(context.Background(), []string{"restored"}, batch)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{4}, offsets)
+	require.NoError(t, batch.Commit(Sync))
+	_ = batch.Close()
+	assert.Equal(t, &LogEntry[string]{Offset: 4, Entry: "restored"}, <-received)
+
+	cancel()
+	require.True(t, errors.Is(<-tailErr, context.Canceled))
+
+	// the next offset is recovered by the reopened log
+	require.NoError(t, db.Close())
+	db = setupDatabase()
+
+	log, err = NewLog[string](LogOptions{DB: db, TableID: 1, TableName: "events"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), log.NextOffset())
+}
@@ -0,0 +1,334 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// DefaultMergeBatchSize is the number of keys written to the destination with
+// a single batch.
+const DefaultMergeBatchSize = 1000
+
+// MergeConflictPolicy decides what happens to the source row whose primary key
+// already exists in the destination.
+type MergeConflictPolicy int
+
+const (
+	// MergeConflictFail fails the merge before anything is written.
+	MergeConflictFail MergeConflictPolicy = iota
+	// MergeConflictSkip keeps the destination row.
+	MergeConflictSkip
+	// MergeConflictOverwrite replaces the destination row and its index
+	// entries with the source row.
+	MergeConflictOverwrite
+)
+
+// MergeOptions are the options of Merge.
+type MergeOptions struct {
+	ConflictPolicy MergeConflictPolicy
+
+	// TableIDs are the source tables to be merged. All tables are merged if
+	// not set.
+	TableIDs []TableID
+
+	// TableIDMapping maps the source table IDs to the destination table IDs.
+	// The unmapped tables keep their IDs.
+	TableIDMapping map[TableID]TableID
+
+	// IndexIDMapping maps the source index IDs of the source tables to the
+	// destination index IDs. The unmapped indexes keep their IDs.
+	IndexIDMapping map[TableID]map[IndexID]IndexID
+
+	// BatchSize is the number of keys written with a single batch. Zero uses
+	// DefaultMergeBatchSize.
+	BatchSize int
+}
+
+// Merge streams the rows and the index entries of the source tables into the
+// destination. The conflicts of the primary keys are resolved with the conflict
+// policy. The overflow chunks the rows refer to are copied with them. The
+// other reserved data, e.g. the catalog and the index sketches, is not merged,
+// and the caches of the destination tables opened during the merge are not
+// invalidated. The tables with the dictionary fields can not be merged, as the
+// dictionary IDs of the rows are not valid in the destination.
+//
+// Example:
+//
+//	err := bond.Merge(ctx, dstDB, shardDB, bond.MergeOptions{
+//		ConflictPolicy: bond.MergeConflictSkip,
+//	})
+func Merge(ctx context.Context, dst DB, src DB, opt MergeOptions) error {
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = DefaultMergeBatchSize
+	}
+
+	tableIDs := opt.TableIDs
+	if len(tableIDs) == 0 {
+		for tableID := TableID(BOND_DB_DATA_TABLE_ID + 1); ; tableID++ {
+			tableIDs = append(tableIDs, tableID)
+			if tableID == math.MaxUint8 {
+				break
+			}
+		}
+	}
+
+	for _, tableID := range tableIDs {
+		if tableID == TableID(BOND_DB_DATA_TABLE_ID) {
+			return fmt.Errorf("merge: table %d is reserved", tableID)
+		}
+
+		dictionaryPrefix := []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_DICTIONARY_INDEX_ID, byte(storageIDOf(src, tableID))}
+		if mergeHasPrefix(src, dictionaryPrefix) {
+			return fmt.Errorf("merge: table %d: dictionary fields can not be merged", tableID)
+		}
+	}
+
+	// the conflicts are checked before the writes, so that the failed merge
+	// leaves the destination intact
+	if opt.ConflictPolicy == MergeConflictFail {
+		for _, tableID := range tableIDs {
+			if err := mergeCheckConflicts(ctx, dst, src, tableID, opt); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, tableID := range tableIDs {
+		if err := mergeTable(ctx, dst, src, tableID, opt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func mergeCheckConflicts(ctx context.Context, dst DB, src DB, tableID TableID, opt MergeOptions) error {
+	dstStorageID := mergeDstStorageID(dst, tableID, opt)
+
+	iter := src.Iter(mergeIterOptions(storageIDOf(src, tableID), true))
+	defer func() { _ = iter.Close() }()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		if dstKey := mergeKey(iter.Key(), tableID, dstStorageID, opt); mergeExists(dst, dstKey) {
+			return fmt.Errorf("merge: row %s: %w", FormatKey(dstKey), ErrKeyExists)
+		}
+	}
+	return nil
+}
+
+func mergeTable(ctx context.Context, dst DB, src DB, tableID TableID, opt MergeOptions) error {
+	writer := &_mergeWriter{ctx: ctx, batch: dst.Batch(), size: opt.BatchSize}
+	defer func() { _ = writer.batch.Close() }()
+
+	// the primary keys of the skipped and the replaced rows decide which
+	// index entries are written and removed
+	var (
+		skipped  = map[string]struct{}{}
+		replaced = map[string]struct{}{}
+	)
+
+	var (
+		srcStorageID = storageIDOf(src, tableID)
+		dstStorageID = mergeDstStorageID(dst, tableID, opt)
+	)
+
+	// the overflow chunks are stored under the hashes of the values, so they
+	// are written before the rows that refer to them without conflicts
+	overflowPrefix := []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_OVERFLOW_INDEX_ID, byte(srcStorageID)}
+	iter := src.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: overflowPrefix,
+			UpperBound: prefixUpperBound(overflowPrefix),
+		},
+	})
+	for iter.First(); iter.Valid(); iter.Next() {
+		dstKey := append([]byte{}, iter.Key()...)
+		dstKey[2] = byte(dstStorageID)
+
+		if err := writer.set(dstKey, iter.Value()); err != nil {
+			_ = iter.Close()
+			return err
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	iter = src.Iter(mergeIterOptions(srcStorageID, true))
+	for iter.First(); iter.Valid(); iter.Next() {
+		dstKey := mergeKey(iter.Key(), tableID, dstStorageID, opt)
+		if mergeExists(dst, dstKey) {
+			switch opt.ConflictPolicy {
+			case MergeConflictSkip:
+				skipped[string(KeyBytes(dstKey).PrimaryKey())] = struct{}{}
+				continue
+			case MergeConflictOverwrite:
+				replaced[string(KeyBytes(dstKey).PrimaryKey())] = struct{}{}
+			default:
+				_ = iter.Close()
+				return fmt.Errorf("merge: row %s: %w", FormatKey(dstKey), ErrKeyExists)
+			}
+		}
+
+		if err := writer.set(dstKey, iter.Value()); err != nil {
+			_ = iter.Close()
+			return err
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	// the index entries of the replaced rows are removed from the destination
+	if len(replaced) > 0 {
+		iter = dst.Iter(mergeIterOptions(dstStorageID, false))
+		for iter.First(); iter.Valid(); iter.Next() {
+			if _, ok := replaced[string(KeyBytes(iter.Key()).PrimaryKey())]; !ok {
+				continue
+			}
+
+			if err := writer.delete(iter.Key()); err != nil {
+				_ = iter.Close()
+				return err
+			}
+		}
+		if err := iter.Close(); err != nil {
+			return err
+		}
+
+		// the deletes are committed before the new entries are written
+		if err := writer.commit(); err != nil {
+			return err
+		}
+	}
+
+	iter = src.Iter(mergeIterOptions(srcStorageID, false))
+	for iter.First(); iter.Valid(); iter.Next() {
+		if _, ok := skipped[string(KeyBytes(iter.Key()).PrimaryKey())]; ok {
+			continue
+		}
+
+		if err := writer.set(mergeKey(iter.Key(), tableID, dstStorageID, opt), iter.Value()); err != nil {
+			_ = iter.Close()
+			return err
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	return writer.commit()
+}
+
+// _mergeWriter writes the keys to the destination with the batches of the
+// given size.
+type _mergeWriter struct {
+	ctx   context.Context
+	batch Batch
+	size  int
+	count int
+}
+
+func (w *_mergeWriter) set(key []byte, value []byte) error {
+	if err := w.batch.Set(key, value, Sync); err != nil {
+		return err
+	}
+	return w.commitFull()
+}
+
+func (w *_mergeWriter) delete(key []byte) error {
+	if err := w.batch.Delete(key, Sync); err != nil {
+		return err
+	}
+	return w.commitFull()
+}
+
+func (w *_mergeWriter) commitFull() error {
+	if w.count++; w.count < w.size {
+		return nil
+	}
+	return w.commit()
+}
+
+func (w *_mergeWriter) commit() error {
+	select {
+	case <-w.ctx.Done():
+		return fmt.Errorf("context done: %w", w.ctx.Err())
+	default:
+	}
+
+	if err := w.batch.Commit(ContextRetrieveWriteOptions(w.ctx)); err != nil {
+		return err
+	}
+	w.batch.Reset()
+	w.count = 0
+	return nil
+}
+
+// mergeDstStorageID returns the ID the keys of the source table are stored
+// under in the destination.
+func mergeDstStorageID(dst DB, tableID TableID, opt MergeOptions) TableID {
+	if mapped, ok := opt.TableIDMapping[tableID]; ok {
+		tableID = mapped
+	}
+	return storageIDOf(dst, tableID)
+}
+
+// mergeKey returns the key of the source table with the destination storage
+// and index IDs.
+func mergeKey(key []byte, tableID TableID, dstStorageID TableID, opt MergeOptions) []byte {
+	indexID := IndexID(key[1])
+
+	dstKey := append([]byte{}, key...)
+	dstKey[0] = byte(dstStorageID)
+	if mapped, ok := opt.IndexIDMapping[tableID][indexID]; ok && indexID != PrimaryIndexID {
+		dstKey[1] = byte(mapped)
+	}
+	return dstKey
+}
+
+// mergeHasPrefix returns true if the database has a key with the prefix.
+func mergeHasPrefix(db DB, prefix []byte) bool {
+	iter := db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: prefixUpperBound(prefix),
+		},
+	})
+	defer func() { _ = iter.Close() }()
+	return iter.First()
+}
+
+func mergeExists(db DB, key []byte) bool {
+	_, closer, err := db.Get(key)
+	if err != nil {
+		return false
+	}
+	_ = closer.Close()
+	return true
+}
+
+// mergeIterOptions returns the bounds of the table rows or the table index
+// entries.
+func mergeIterOptions(tableID TableID, rows bool) *IterOptions {
+	opt := &IterOptions{}
+	if rows {
+		opt.LowerBound = []byte{byte(tableID), byte(PrimaryIndexID)}
+		opt.UpperBound = []byte{byte(tableID), byte(PrimaryIndexID + 1)}
+		return opt
+	}
+
+	opt.LowerBound = []byte{byte(tableID), byte(PrimaryIndexID + 1)}
+	if tableID < math.MaxUint8 {
+		opt.UpperBound = []byte{byte(tableID + 1)}
+	}
+	return opt
+}
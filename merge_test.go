@@ -0,0 +1,222 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Merge(t *testing.T) {
+	const srcDBName = "test_db_merge_src"
+
+	setup := func() (DB, DB, Table[*TokenBalance], Table[*TokenBalance], *Index[*TokenBalance]) {
+		dst, err := Open(dbName, &Options{})
+		require.NoError(t, err)
+
+		src, err := Open(srcDBName, &Options{})
+		require.NoError(t, err)
+
+		AccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 1,
+			IndexName: "account_address_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.AccountAddress).Bytes()
+			},
+			IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+		})
+
+		tables := make([]Table[*TokenBalance], 0, 2)
+		for _, db := range []DB{dst, src} {
+			table := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+				DB:        db,
+				TableID:   TableID(1),
+				TableName: "token_balance",
+				TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+					return builder.AddUint64Field(tb.ID).Bytes()
+				},
+			})
+			require.NoError(t, table.AddIndex([]*Index[*TokenBalance]{AccountAddressIndex}))
+			tables = append(tables, table)
+		}
+
+		err = tables[0].Insert(context.Background(), []*TokenBalance{
+			{ID: 1, AccountAddress: "0xa1", Balance: 1},
+			{ID: 2, AccountAddress: "0xa2", Balance: 2},
+		})
+		require.NoError(t, err)
+
+		err = tables[1].Insert(context.Background(), []*TokenBalance{
+			{ID: 2, AccountAddress: "0xa3", Balance: 20},
+			{ID: 3, AccountAddress: "0xa3", Balance: 30},
+		})
+		require.NoError(t, err)
+
+		return dst, src, tables[0], tables[1], AccountAddressIndex
+	}
+
+	tearDown := func(dst DB, src DB) {
+		_ = dst.Close()
+		_ = src.Close()
+		_ = os.RemoveAll(dbName)
+		_ = os.RemoveAll(srcDBName)
+	}
+
+	balances := func(table Table[*TokenBalance], idx *Index[*TokenBalance], accountAddress string) []uint64 {
+		var trs []*TokenBalance
+		err := table.Query().
+			With(idx, &TokenBalance{AccountAddress: accountAddress}).
+			Execute(context.Background(), &trs)
+		require.NoError(t, err)
+
+		var balances []uint64
+		for _, tr := range trs {
+			balances = append(balances, tr.Balance)
+		}
+		return balances
+	}
+
+	t.Run("Fail", func(t *testing.T) {
+		dst, src, dstTable, _, idx := setup()
+		defer tearDown(dst, src)
+
+		err := Merge(context.Background(), dst, src, MergeOptions{})
+		require.ErrorIs(t, err, ErrKeyExists)
+
+		// nothing was written
+		assert.Nil(t, balances(dstTable, idx, "0xa3"))
+	})
+
+	t.Run("Skip", func(t *testing.T) {
+		dst, src, dstTable, _, idx := setup()
+		defer tearDown(dst, src)
+
+		err := Merge(context.Background(), dst, src, MergeOptions{ConflictPolicy: MergeConflictSkip, BatchSize: 1})
+		require.NoError(t, err)
+
+		assert.Equal(t, []uint64{2}, balances(dstTable, idx, "0xa2"))
+		assert.Equal(t, []uint64{30}, balances(dstTable, idx, "0xa3"))
+	})
+
+	t.Run("Overwrite", func(t *testing.T) {
+		dst, src, dstTable, _, idx := setup()
+		defer tearDown(dst, src)
+
+		err := Merge(context.Background(), dst, src, MergeOptions{ConflictPolicy: MergeConflictOverwrite})
+		require.NoError(t, err)
+
+		assert.Nil(t, balances(dstTable, idx, "0xa2"))
+		assert.Equal(t, []uint64{20, 30}, balances(dstTable, idx, "0xa3"))
+		assert.Equal(t, []uint64{1}, balances(dstTable, idx, "0xa1"))
+	})
+
+	t.Run("Remapping", func(t *testing.T) {
+		dst, src, _, _, _ := setup()
+		defer tearDown(dst, src)
+
+		err := Merge(context.Background(), dst, src, MergeOptions{
+			TableIDs:       []TableID{1},
+			TableIDMapping: map[TableID]TableID{1: 2},
+			IndexIDMapping: map[TableID]map[IndexID]IndexID{1: {PrimaryIndexID + 1: PrimaryIndexID + 2}},
+		})
+		require.NoError(t, err)
+
+		remappedTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        dst,
+			TableID:   TableID(2),
+			TableName: "token_balance_shard",
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+		})
+
+		remappedIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 2,
+			IndexName: "account_address_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.AccountAddress).Bytes()
+			},
+			IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+		})
+		require.NoError(t, remappedTable.AddIndex([]*Index[*TokenBalance]{remappedIndex}, false))
+
+		assert.Equal(t, []uint64{20, 30}, balances(remappedTable, remappedIndex, "0xa3"))
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		dst, src, _, _, _ := setup()
+		defer tearDown(dst, src)
+
+		newOverflowTable := func(db DB, id TableID) Table[*TokenBalance] {
+			return NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+				DB:        db,
+				TableID:   id,
+				TableName: fmt.Sprintf("token_balance_overflow_%d", id),
+				TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+					return builder.AddUint64Field(tb.ID).Bytes()
+				},
+				OverflowThreshold: 1024,
+			})
+		}
+
+		huge := strings.Repeat("0xa4", OverflowChunkSize/2)
+		err := newOverflowTable(src, TableID(3)).Insert(context.Background(), []*TokenBalance{
+			{ID: 4, AccountAddress: huge, Balance: 40},
+		})
+		require.NoError(t, err)
+
+		// the overflow chunks are copied with the rows
+		err = Merge(context.Background(), dst, src, MergeOptions{
+			TableIDs:       []TableID{3},
+			TableIDMapping: map[TableID]TableID{3: 4},
+		})
+		require.NoError(t, err)
+
+		tb, err := newOverflowTable(dst, TableID(4)).Get(&TokenBalance{ID: 4})
+		require.NoError(t, err)
+		assert.Equal(t, huge, tb.AccountAddress)
+	})
+
+	t.Run("Dictionary", func(t *testing.T) {
+		dst, src, dstTable, _, idx := setup()
+		defer tearDown(dst, src)
+
+		dictionaryTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        src,
+			TableID:   TableID(3),
+			TableName: "token_balance_dictionary",
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+			DictionaryFields: []DictionaryField[*TokenBalance]{
+				{
+					Name: "account_address",
+					Get: func(tb *TokenBalance) string {
+						return tb.AccountAddress
+					},
+					Set: func(tb *TokenBalance, value string) *TokenBalance {
+						c := *tb
+						c.AccountAddress = value
+						return &c
+					},
+				},
+			},
+		})
+
+		err := dictionaryTable.Insert(context.Background(), []*TokenBalance{
+			{ID: 4, AccountAddress: "0xa4", Balance: 40},
+		})
+		require.NoError(t, err)
+
+		// the dictionary IDs are not valid in the destination
+		err = Merge(context.Background(), dst, src, MergeOptions{ConflictPolicy: MergeConflictSkip})
+		require.Error(t, err)
+
+		// nothing was written
+		assert.Nil(t, balances(dstTable, idx, "0xa3"))
+	})
+}
@@ -16,7 +16,110 @@ const DefaultMaxWriterConcurrency = 8
 type Options struct {
 	PebbleOptions *pebble.Options
 
+	// Backend is the key-value store the database is built on instead of the
+	// pebble database opened in its directory. The PebbleOptions are not used
+	// with it, and the features that rely on pebble fail with ErrNotSupported.
+	Backend Backend
+
 	Serializer Serializer[any]
+
+	// IteratorPoolSize is the number of iterators kept per table for reuse
+	// by the following scans. The pooled iterator is only reused if there were
+	// no writes since it was created, so the pool benefits read mostly
+	// workloads. Zero disables pooling.
+	IteratorPoolSize int
+
+	// WriteConcurrency is the number of workers used to serialize rows and
+	// compute their index keys during inserts, and to compute the keys and
+	// index keys of the rows during deletes. The rows are still written to the
+	// batch in the order they were provided. Zero or one makes inserts and
+	// deletes fully sequential.
+	WriteConcurrency int
+
+	// CatalogDriftFunc is called when the registered table or index does not
+	// match the catalog persisted by the previous runs, e.g. the table ID is
+	// reused by a table with a different name. If not set, the tables and the
+	// indexes renamed without changing their keys are accepted, and the other
+	// drifts fail the registration with the CatalogDrift error.
+	CatalogDriftFunc CatalogDriftFunc
+
+	// OnCatalogRename is called when the table or the index renamed without
+	// changing its keys is accepted, if CatalogDriftFunc is not set.
+	OnCatalogRename func(drift CatalogDrift)
+
+	// SnapshotRetention is the time the snapshots taken with RetainSnapshot
+	// are kept for. The expired snapshots are released when the next one is
+	// taken. Zero keeps the snapshots until the database is closed.
+	SnapshotRetention time.Duration
+
+	// OnWriteStallBegin and OnWriteStallEnd are called when the writes start
+	// and stop being delayed because the flushes or the compactions fall
+	// behind. The event callbacks are called synchronously by the background
+	// jobs and the index builds, so they must not block.
+	OnWriteStallBegin func(info WriteStallInfo)
+	OnWriteStallEnd   func()
+
+	// OnCompaction is called when the compaction is finished.
+	OnCompaction func(info CompactionInfo)
+
+	// OnFlush is called when the flush of the memtables is finished.
+	OnFlush func(info FlushInfo)
+
+	// OnIndexBuildProgress is called during the index builds.
+	OnIndexBuildProgress func(progress IndexBuildProgress)
+
+	// SlowQueryThreshold enables the slow query log. The queries that take
+	// longer are passed to OnSlowQuery and written to the table with
+	// SlowQueryLogTableID if it's set. Zero disables the log.
+	SlowQueryThreshold  time.Duration
+	OnSlowQuery         func(query SlowQuery)
+	SlowQueryLogTableID TableID
+
+	// MaxConcurrentQueries is the number of the queries executed at the same
+	// time, the other queries wait for their turn in the order they arrived,
+	// so a burst of heavy scans does not starve the point reads, which are
+	// not limited. MaxQueuedQueries is the number of the queries that can
+	// wait, the queries beyond it and the queries waiting longer than
+	// QueryQueueTimeout fail with QueryOverloadError. Zero disables the limits.
+	// The queries executed from the callbacks of the other queries need
+	// their own turn, so the limit must leave the room for them.
+	MaxConcurrentQueries int
+	MaxQueuedQueries     int
+	QueryQueueTimeout    time.Duration
+
+	// WriteInterceptor is called with the mutations of every write committed
+	// to the database, e.g. to dual-write them to the new store or to the
+	// external system during the live migration. It's called after the
+	// commit, before the write returns, so the concurrent writes call it
+	// concurrently. If WriteInterceptorAsync is set, the mutations are queued
+	// and passed to it by the background goroutine one write at a time, the
+	// writes wait once DefaultWriteInterceptorQueueSize writes are queued.
+	// The queued writes are passed before Close returns.
+	WriteInterceptor      WriteInterceptor
+	WriteInterceptorAsync bool
+
+	// BackgroundConcurrency is the number of the background tasks, such as
+	// the table retention, run at the same time, the
+	// DefaultBackgroundConcurrency if not set. BackgroundTaskTimeout is the
+	// deadline of the single run of the task, its interval if not set.
+	// OnBackgroundError is called with the errors of the tasks.
+	BackgroundConcurrency int
+	BackgroundTaskTimeout time.Duration
+	OnBackgroundError     func(task string, err error)
+
+	// SecondaryCatchUpInterval is the interval the secondary opened with
+	// OpenSecondary catches up with the primary at, the
+	// DefaultSecondaryCatchUpInterval if not set.
+	SecondaryCatchUpInterval time.Duration
+
+	// Clock is the time source of the retention, the archival, the snapshots,
+	// the queues and the commit times of the batches, the SystemClock if not
+	// set.
+	Clock Clock
+
+	// FaultInjector injects the errors and the latency at the fault points
+	// of the writes. It must only be set by the tests.
+	FaultInjector FaultInjector
 }
 
 func DefaultOptions() *Options {
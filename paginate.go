@@ -0,0 +1,225 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// Page is the page of the rows returned by Paginate. The tokens are empty if
+// there is no next or previous page.
+type Page[T any] struct {
+	Rows      []T
+	NextToken string
+	PrevToken string
+}
+
+// TablePaginator reads the query results page by page.
+type TablePaginator[T any] interface {
+	Paginate(ctx context.Context, query Query[T], pageSize int, token string) (Page[T], error)
+}
+
+const (
+	pageTokenNext byte = iota + 1
+	pageTokenPrev
+)
+
+// Paginate returns the page of the query results that follows or precedes the
+// page the token was returned with, the first page if the token is empty. The
+// tokens hold the index key of the row the page starts after or ends before,
+// so every page is read with the index seek instead of skipping the rows of
+// the previous pages as with Offset. The pages stay consistent when rows are
+// inserted or deleted between the calls, the rows are neither repeated nor
+// skipped.
+//
+// The query can not be ordered, limited, offset, windowed, sampled or scan
+// the index prefix.
+//
+// Example:
+//
+//	page, err := t.Paginate(ctx, t.Query().With(AccountAddressIndex, selector), 50, r.URL.Query().Get("page"))
+func (t *_table[T]) Paginate(ctx context.Context, query Query[T], pageSize int, token string) (Page[T], error) {
+	if pageSize <= 0 {
+		return Page[T]{}, fmt.Errorf("paginate: invalid page size %d", pageSize)
+	}
+
+	if len(query.indexCandidates) > 0 {
+		query = query.chooseIndex()
+	}
+
+	if query.orderLessFunc != nil || query.offset > 0 || query.limit > 0 || query.isAfter ||
+		query.windowFunc != nil || query.sampleSize > 0 || query.usesPrefix() || query.usesTimeRange() || !query.asOf.IsZero() {
+		return Page[T]{}, fmt.Errorf("paginate can not be used with order, offset, limit, after, window, sample, prefix, time range or as of")
+	}
+
+	queries := query.queries
+	if len(queries) == 0 {
+		queries = []FilterAndIndex[T]{{Index: query.index, IndexSelector: query.indexSelector}}
+	}
+
+	idx := queries[0].Index
+	startKey, err := t.safeIndexKey(queries[0].IndexSelector, idx, make([]byte, 0, DataKeyBufferSize))
+	if err != nil {
+		return Page[T]{}, err
+	}
+
+	for _, q := range queries[1:] {
+		key, err := t.safeIndexKey(q.IndexSelector, q.Index, make([]byte, 0, DataKeyBufferSize))
+		if err != nil {
+			return Page[T]{}, err
+		}
+		if q.Index.IndexID != idx.IndexID || !bytes.Equal(key, startKey) {
+			return Page[T]{}, fmt.Errorf("paginate can not be used with multiple indexes or selectors")
+		}
+	}
+
+	prefix := startKey[:_KeyPrefixSplitIndex(startKey)]
+
+	direction, cursor, err := decodePageToken(token)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	if cursor != nil && (!bytes.HasPrefix(cursor, prefix) || bytes.Compare(cursor, startKey) < 0) {
+		return Page[T]{}, fmt.Errorf("%w: token does not belong to the query", ErrInvalidPageToken)
+	}
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: startKey,
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	notInMatchers := query.newNotInMatchers()
+	defer func() {
+		for _, matcher := range notInMatchers {
+			matcher.close()
+		}
+	}()
+
+	var (
+		rows     []T
+		keys     [][]byte
+		hasMore  bool
+		moveNext = iter.Next
+	)
+
+	switch {
+	case direction == pageTokenPrev:
+		iter.SeekLT(cursor)
+		moveNext = iter.Prev
+	case cursor != nil:
+		if iter.SeekGE(cursor) && bytes.Equal(iter.Key(), cursor) {
+			iter.Next()
+		}
+	default:
+		iter.First()
+	}
+
+	var keyBuffer [DataKeyBufferSize]byte
+	for ; iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); moveNext() {
+		select {
+		case <-ctx.Done():
+			return Page[T]{}, fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		dataKey := iter.Key()
+		if idx.IndexID != PrimaryIndexID {
+			dataKey = KeyBytes(iter.Key()).ToDataKeyBytes(keyBuffer[:0])
+		}
+
+		tr, err := t.get(dataKey, nil)
+		if err != nil {
+			return Page[T]{}, err
+		}
+
+		tr, err = t.decodeFields(ctx, tr)
+		if err != nil {
+			return Page[T]{}, err
+		}
+
+		// the rows of every query are returned, as when the query is executed
+		matches := false
+		for _, q := range queries {
+			if !query.shouldFilter(q) {
+				matches = true
+				break
+			}
+
+			matches, err = query.filter(q, notInMatchers, iter.Key(), tr)
+			if err != nil {
+				return Page[T]{}, err
+			}
+			if matches {
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+
+		if len(rows) == pageSize {
+			hasMore = true
+			break
+		}
+
+		rows = append(rows, tr)
+		keys = append(keys, append([]byte{}, iter.Key()...))
+	}
+
+	page := Page[T]{Rows: rows}
+	if direction == pageTokenPrev {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+			keys[i], keys[j] = keys[j], keys[i]
+		}
+	}
+
+	if len(rows) == 0 {
+		// the rows before the cursor were deleted, so the first page follows
+		if direction == pageTokenPrev {
+			return t.Paginate(ctx, query, pageSize, "")
+		}
+
+		// the rows after the cursor were deleted, the previous page ends with
+		// the cursor, so it ends before its immediate successor
+		if cursor != nil {
+			page.PrevToken = encodePageToken(pageTokenPrev, append(append([]byte{}, cursor...), 0))
+		}
+		return page, nil
+	}
+
+	if hasMore || direction == pageTokenPrev {
+		page.NextToken = encodePageToken(pageTokenNext, keys[len(keys)-1])
+	}
+	if (hasMore && direction == pageTokenPrev) || (cursor != nil && direction == pageTokenNext) {
+		page.PrevToken = encodePageToken(pageTokenPrev, keys[0])
+	}
+	return page, nil
+}
+
+func encodePageToken(direction byte, key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(append([]byte{direction}, key...))
+}
+
+func decodePageToken(token string) (byte, []byte, error) {
+	if token == "" {
+		return pageTokenNext, nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: %s", ErrInvalidPageToken, err)
+	}
+
+	if len(data) < 2 || (data[0] != pageTokenNext && data[0] != pageTokenPrev) || keyValidate(data[1:]) != nil {
+		return 0, nil, ErrInvalidPageToken
+	}
+	return data[0], data[1:], nil
+}
@@ -0,0 +1,115 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_Paginate(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < 25; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: "0xtestContract",
+			AccountAddress:  fmt.Sprintf("0xtestAccount%d", i%2),
+			Balance:         uint64(i),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	query := tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount0"}).
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance != 10
+		})
+
+	ids := func(page Page[*TokenBalance]) []uint64 {
+		var result []uint64
+		for _, tb := range page.Rows {
+			result = append(result, tb.ID)
+		}
+		return result
+	}
+
+	page, err := tokenBalanceTable.Paginate(context.Background(), query, 5, "")
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 3, 5, 7, 9}, ids(page))
+	assert.Empty(t, page.PrevToken)
+	require.NotEmpty(t, page.NextToken)
+
+	// the rows deleted before the cursor do not move the pages
+	err = tokenBalanceTable.Delete(context.Background(), tokenBalances[2:3])
+	require.NoError(t, err)
+
+	page, err = tokenBalanceTable.Paginate(context.Background(), query, 5, page.NextToken)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{13, 15, 17, 19, 21}, ids(page))
+	require.NotEmpty(t, page.PrevToken)
+	require.NotEmpty(t, page.NextToken)
+
+	lastPage, err := tokenBalanceTable.Paginate(context.Background(), query, 5, page.NextToken)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{23, 25}, ids(lastPage))
+	assert.Empty(t, lastPage.NextToken)
+	require.NotEmpty(t, lastPage.PrevToken)
+
+	page, err = tokenBalanceTable.Paginate(context.Background(), query, 5, lastPage.PrevToken)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{13, 15, 17, 19, 21}, ids(page))
+
+	page, err = tokenBalanceTable.Paginate(context.Background(), query, 5, page.PrevToken)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 5, 7, 9}, ids(page))
+	assert.Empty(t, page.PrevToken)
+	require.NotEmpty(t, page.NextToken)
+
+	// the page after the deleted rows is empty, but leads back
+	err = tokenBalanceTable.Delete(context.Background(), tokenBalances[22:])
+	require.NoError(t, err)
+
+	page, err = tokenBalanceTable.Paginate(context.Background(), query, 5, lastPage.PrevToken)
+	require.NoError(t, err)
+	page, err = tokenBalanceTable.Paginate(context.Background(), query, 5, page.NextToken)
+	require.NoError(t, err)
+	assert.Empty(t, page.Rows)
+
+	page, err = tokenBalanceTable.Paginate(context.Background(), query, 5, page.PrevToken)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{13, 15, 17, 19, 21}, ids(page))
+
+	_, err = tokenBalanceTable.Paginate(context.Background(), query, 5, "invalid")
+	assert.ErrorIs(t, err, ErrInvalidPageToken)
+
+	_, err = tokenBalanceTable.Paginate(context.Background(), query.Limit(5), 5, "")
+	assert.Error(t, err)
+}
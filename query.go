@@ -3,7 +3,11 @@ package bond
 import (
 	"context"
 	"fmt"
+	"io"
+	"math/rand"
+	"reflect"
 	"sort"
+	"time"
 
 	"github.com/go-bond/bond/utils"
 )
@@ -16,8 +20,21 @@ type FilterAndIndex[R any] struct {
 	FilterFunc    FilterFunc[R]
 	Index         *Index[R]
 	IndexSelector R
+	IndexPrefix   bool
+
+	// IndexTimeRange selects the index entries by the time field leading
+	// their index keys instead of the selector, see Query.WithTimeRange.
+	IndexTimeRange *TimeRange
 }
 
+// WindowFunc is the function template that returns the window of the record.
+type WindowFunc[R any] func(r R) uint64
+
+// WindowAggregateFunc is the function template that merges the record into the
+// aggregate of the window. The aggregate of the new window is created with
+// utils.MakeNew.
+type WindowAggregateFunc[R any] func(window uint64, acc R, r R) R
+
 // OrderLessFunc is the function template to be used for record sorting.
 type OrderLessFunc[R any] func(r, r2 R) bool
 
@@ -35,12 +52,40 @@ type Query[R any] struct {
 	table         *_table[R]
 	index         *Index[R]
 	indexSelector R
+	indexPrefix   bool
+
+	indexTimeRange *TimeRange
+
+	// indexCandidates are the indexes WithBestIndex chooses from
+	indexCandidates []*Index[R]
+	indexChoice     string
 
 	queries       []FilterAndIndex[R]
 	orderLessFunc OrderLessFunc[R]
 	offset        uint64
 	limit         uint64
 	isAfter       bool
+	memoryLimit   uint64
+	maxScanRows   uint64
+	deadline      time.Duration
+
+	windowFunc          WindowFunc[R]
+	windowAggregateFunc WindowAggregateFunc[R]
+
+	notIns []_notIn[R]
+
+	payloadFilter func(payload IndexPayload) bool
+
+	sampleSize uint64
+
+	asOf time.Time
+
+	cached           bool
+	cacheFingerprint string
+
+	trace io.Writer
+
+	preserveIndexOrder bool
 }
 
 func newQuery[R any](t *_table[R], i *Index[R]) Query[R] {
@@ -53,6 +98,13 @@ func newQuery[R any](t *_table[R], i *Index[R]) Query[R] {
 		offset:        0,
 		limit:         0,
 		isAfter:       false,
+		memoryLimit:   0,
+
+		windowFunc:          nil,
+		windowAggregateFunc: nil,
+
+		cached:           false,
+		cacheFingerprint: "",
 	}
 }
 
@@ -71,6 +123,71 @@ func (q Query[R]) Table() Table[R] {
 func (q Query[R]) With(idx *Index[R], selector R) Query[R] {
 	q.index = idx
 	q.indexSelector = selector
+	q.indexPrefix = false
+	q.indexTimeRange = nil
+	return q
+}
+
+// WithPrefix selects the index entries whose index keys start with the index
+// key of the partial selector. The trailing fields of the selector that are
+// left unset are not the part of the prefix, and the last set string field
+// matches the strings it is the prefix of, e.g. the address autocomplete:
+//
+//	t.Query().
+//		WithPrefix(AccountAddressIndex, &TokenBalance{AccountAddress: "0xab"})
+//
+// The entries are ordered by the length of their index keys first.
+func (q Query[R]) WithPrefix(idx *Index[R], partialSelector R) Query[R] {
+	q.index = idx
+	q.indexSelector = partialSelector
+	q.indexPrefix = true
+	q.indexTimeRange = nil
+	return q
+}
+
+// WithSubtree selects the index entries whose path field, added with
+// KeyBuilder.AddPathField, is the path of the selector or its descendant.
+// The path field needs to be the last field of the index key set in the
+// selector, the fields before it need to match, as with WithPrefix. The
+// empty path is not set, so it's not the part of the prefix.
+//
+//	t.Query().
+//		WithSubtree(EmployeeTeamPathIndex, &Employee{TeamPath: "acme/engineering"})
+//
+// It selects the employees of "acme/engineering" and of "acme/engineering/storage",
+// but not of "acme/engineering-ops". The entries are ordered as with WithPrefix.
+func (q Query[R]) WithSubtree(idx *Index[R], pathSelector R) Query[R] {
+	return q.WithPrefix(idx, pathSelector)
+}
+
+// WithBestIndex selects the index for query execution from the candidates
+// using their statistics. The candidate with the fewest estimated entries that
+// start with the index key prefix of the partial selector is scanned with
+// the prefix, as with WithPrefix. The candidates whose first index key field is
+// not set in the selector, the ones that do not maintain statistics, and the
+// ones that are being built, are not considered. The primary index is scanned if no candidate applies.
+//
+//	t.Query().
+//		WithBestIndex(&TokenBalance{AccountAddress: "0xab", ContractAddress: "0xcd"},
+//			AccountAddressIndex, ContractAddressIndex).
+//		Filter(func(tb *TokenBalance) bool {
+//			return tb.AccountAddress == "0xab" && tb.ContractAddress == "0xcd"
+//		})
+//
+// The selector only narrows the scanned entries, so the filter still needs to
+// check the conditions. If the selected entries are a large part of the table,
+// the table is scanned instead, as the index scan would read every row with the
+// point read. The choice is shown by Explain.
+//
+// The choice is opt-in, the queries without With or WithBestIndex scan the
+// primary index, as the filters are functions whose referenced fields are not
+// known to the query.
+func (q Query[R]) WithBestIndex(partialSelector R, candidates ...*Index[R]) Query[R] {
+	q.index = nil
+	q.indexSelector = partialSelector
+	q.indexPrefix = true
+	q.indexTimeRange = nil
+	q.indexCandidates = candidates
 	return q
 }
 
@@ -79,9 +196,11 @@ func (q Query[R]) With(idx *Index[R], selector R) Query[R] {
 func (q Query[R]) Filter(filter FilterFunc[R]) Query[R] {
 	newWhere := make([]FilterAndIndex[R], 0, len(q.queries)+1)
 	q.queries = append(append(newWhere, q.queries...), FilterAndIndex[R]{
-		FilterFunc:    filter,
-		Index:         q.index,
-		IndexSelector: q.indexSelector,
+		FilterFunc:     filter,
+		Index:          q.index,
+		IndexSelector:  q.indexSelector,
+		IndexPrefix:    q.indexPrefix,
+		IndexTimeRange: q.indexTimeRange,
 	})
 	return q
 }
@@ -92,6 +211,48 @@ func (q Query[R]) Order(less OrderLessFunc[R]) Query[R] {
 	return q
 }
 
+// Window aggregates the records of the same window into one record while
+// streaming. The window ends when the record of the other window is read, so
+// the records should be read in the window order, e.g. with the index ordered
+// by time and the window being the time bucket. The Offset and the Limit apply
+// to the aggregated records.
+//
+// Example:
+//
+//	t.Query().
+//		With(BalanceTimeIndex, &Balance{}).
+//		Window(func(b *Balance) uint64 {
+//			return b.Timestamp / 3600
+//		}, func(hour uint64, acc *Balance, b *Balance) *Balance {
+//			acc.Timestamp = hour * 3600
+//			acc.Amount += b.Amount
+//			return acc
+//		})
+func (q Query[R]) Window(windowFunc WindowFunc[R], aggregateFunc WindowAggregateFunc[R]) Query[R] {
+	q.windowFunc = windowFunc
+	q.windowAggregateFunc = aggregateFunc
+	return q
+}
+
+// Sample returns the approximately uniform random sample of n records that
+// match the query. The sample is taken with the reservoir sampling over the
+// scan, so the rows that are not sampled and not filtered are not fetched.
+// The order is applied to the sample. It can not be used with offset, limit,
+// after or window.
+func (q Query[R]) Sample(n uint64) Query[R] {
+	q.sampleSize = n
+	return q
+}
+
+// AsOf executes the query on the latest snapshot retained with
+// DB.RetainSnapshot at or before the time, so that the results are as they
+// existed at that time. The query fails with ErrSnapshotNotFound if there is
+// no such snapshot. It can not be used with the batch.
+func (q Query[R]) AsOf(ts time.Time) Query[R] {
+	q.asOf = ts
+	return q
+}
+
 // Offset sets offset of the records.
 //
 // WARNING: Using Offset requires traversing through all the rows
@@ -120,79 +281,481 @@ func (q Query[R]) After(sel R) Query[R] {
 	return q
 }
 
+// MemoryLimit sets the maximal estimated number of bytes of rows that the query
+// may hold in memory during execution. The rows are held in memory when the query
+// uses Order, or Offset together with Filter, as the whole selection needs to be
+// read before the order and offset are applied. The query fails with
+// ErrQueryMemoryLimitExceeded as soon as the limit is exceeded instead of
+// consuming unbounded memory. Zero means no limit.
+func (q Query[R]) MemoryLimit(bytes uint64) Query[R] {
+	q.memoryLimit = bytes
+	return q
+}
+
+// MaxScanRows sets the maximal number of the index entries the query may scan,
+// including the ones that are filtered out. The query fails with
+// ErrQueryScanLimitExceeded as soon as the limit is exceeded, which protects
+// from the selectors that match a large part of the table. Zero means no limit.
+func (q Query[R]) MaxScanRows(n uint64) Query[R] {
+	q.maxScanRows = n
+	return q
+}
+
+// Deadline sets the maximal duration of the query execution. The query fails
+// with ErrQueryDeadlineExceeded once it runs longer, whether or not the
+// context has the deadline. Zero means no deadline.
+func (q Query[R]) Deadline(d time.Duration) Query[R] {
+	q.deadline = d
+	return q
+}
+
+// Cached makes the query use the query result cache of the table, enabled
+// with TableOptions.QueryCacheSize. As the filter and order functions can not
+// be compared, the fingerprint needs to identify them. The queries with equal
+// fingerprints, indexes, selectors, offsets and limits share the cached result.
+// The query is not cached when executed with the batch, with the prefix
+// selector or with NotIn.
+//
+// WARNING: The cached rows are shared between the queries and must not be modified.
+func (q Query[R]) Cached(fingerprint string) Query[R] {
+	q.cached = true
+	q.cacheFingerprint = fingerprint
+	return q
+}
+
 // Execute the built query.
 func (q Query[R]) Execute(ctx context.Context, r *[]R, optBatch ...Batch) error {
+	if q.cached && q.table.queryCache != nil && !q.usesPrefix() && !q.usesTimeRange() && len(q.notIns) == 0 && q.sampleSize == 0 && q.asOf.IsZero() && (len(optBatch) == 0 || optBatch[0] == nil) {
+		return q.executeCached(ctx, r)
+	}
+	return q.execute(ctx, r, nil, optBatch...)
+}
+
+// ExecuteReuse executes the built query reusing the capacity of the destination
+// slice and the row objects it already holds. The row objects are reset before
+// being filled with the new data. The allocator is used to create row objects
+// when the destination does not have one to reuse, so it can be backed by a pool.
+//
+// WARNING: The rows returned by the previous execution are overwritten, so they
+// must not be referenced anymore when calling ExecuteReuse.
+func (q Query[R]) ExecuteReuse(ctx context.Context, r *[]R, allocator func() R, optBatch ...Batch) error {
+	if allocator == nil {
+		allocator = utils.MakeNew[R]
+	}
+	return q.execute(ctx, r, allocator, optBatch...)
+}
+
+// Keys executes the built query and returns the primary keys of the matching
+// rows instead of the rows. If the query has no filters and order, the rows are
+// not fetched at all which allows to cheaply scan the index, apply custom
+// pagination and fetch only needed rows with Table.GetByKeys.
+func (q Query[R]) Keys(ctx context.Context, optBatch ...Batch) ([]PrimaryKey, error) {
+	if !q.isPlainIndexScan() {
+		var records []R
+		err := q.Execute(ctx, &records, optBatch...)
+		if err != nil {
+			return nil, err
+		}
+
+		keys := make([]PrimaryKey, 0, len(records))
+		for _, record := range records {
+			keys = append(keys, q.table.primaryKeyFunc(NewKeyBuilder([]byte{}), record))
+		}
+		return keys, nil
+	}
+
+	release, err := q.admit(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	var (
+		keys            []PrimaryKey
+		count           = uint64(0)
+		skippedFirstRow = false
+	)
+
+	err = q.table.scanIndexForEach(ctx, q.index, q.indexSelector, func(keyBytes KeyBytes, _ Lazy[R]) (bool, error) {
+		q.traceKey("visit", keyBytes)
+
+		if q.isAfter && !skippedFirstRow {
+			skippedFirstRow = true
+			return true, nil
+		}
+
+		count++
+		if count <= q.offset {
+			return true, nil
+		}
+
+		keys = append(keys, append(PrimaryKey{}, keyBytes.PrimaryKey()...))
+		return !q.shouldLimit() || uint64(len(keys)) < q.limit, nil
+	}, true, optBatch...)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// isPlainIndexScan returns true if the query only reads the index entries from
+// the selector, so the keys can be read without fetching the rows. The query
+// with any other option is executed in full, so the options added to the query
+// need to be added here as well to not be ignored by Keys.
+func (q Query[R]) isPlainIndexScan() bool {
+	return len(q.queries) == 0 &&
+		q.orderLessFunc == nil &&
+		!q.indexPrefix &&
+		q.indexTimeRange == nil &&
+		len(q.indexCandidates) == 0 &&
+		q.maxScanRows == 0 &&
+		q.deadline == 0 &&
+		q.windowFunc == nil &&
+		q.windowAggregateFunc == nil &&
+		len(q.notIns) == 0 &&
+		q.payloadFilter == nil &&
+		q.sampleSize == 0 &&
+		q.asOf.IsZero()
+}
+
+func (q Query[R]) execute(ctx context.Context, r *[]R, allocator func() R, optBatch ...Batch) (err error) {
+	if q.preserveIndexOrder {
+		q, err = q.indexOrderQuery()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(q.indexCandidates) > 0 {
+		q = q.chooseIndex()
+	}
+
 	if q.isAfter && q.orderLessFunc != nil {
 		return fmt.Errorf("after can not be used with order")
 	}
 
+	if q.isAfter && q.indexPrefix {
+		return fmt.Errorf("after can not be used with prefix")
+	}
+
+	if q.isAfter && q.indexTimeRange != nil {
+		return fmt.Errorf("after can not be used with time range")
+	}
+
+	if q.windowFunc != nil && (q.orderLessFunc != nil || q.isAfter) {
+		return fmt.Errorf("window can not be used with order or after")
+	}
+
+	if q.sampleSize > 0 && (q.offset > 0 || q.limit > 0 || q.isAfter || q.windowFunc != nil) {
+		return fmt.Errorf("sample can not be used with offset, limit, after or window")
+	}
+
+	if q.payloadFilter != nil {
+		for _, query := range q.queries {
+			if query.Index.IndexPayloadFunction == nil {
+				return q.table.newError(query.Index, nil, fmt.Errorf("filter indexed can not be used with index without payload"))
+			}
+		}
+		if len(q.queries) == 0 && q.index.IndexPayloadFunction == nil {
+			return q.table.newError(q.index, nil, fmt.Errorf("filter indexed can not be used with index without payload"))
+		}
+	}
+
+	release, err := q.admit(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if !q.asOf.IsZero() {
+		if len(optBatch) > 0 && optBatch[0] != nil {
+			return fmt.Errorf("as of can not be used with batch")
+		}
+
+		db, ok := q.table.db.(*_db)
+		if !ok {
+			return ErrSnapshotNotFound
+		}
+
+		snapshot := db.acquireSnapshot(q.asOf)
+		if snapshot == nil {
+			return fmt.Errorf("%w: as of %s", ErrSnapshotNotFound, q.asOf)
+		}
+		defer db.releaseSnapshot(snapshot)
+
+		optBatch = []Batch{&_snapshotBatch{snapshot: snapshot.snapshot}}
+	}
+
 	if len(q.queries) == 0 {
-		q.queries = append([]FilterAndIndex[R]{
+		q.queries = []FilterAndIndex[R]{
 			{
-				FilterFunc:    nil,
-				Index:         q.index,
-				IndexSelector: q.indexSelector,
+				FilterFunc:     nil,
+				Index:          q.index,
+				IndexSelector:  q.indexSelector,
+				IndexPrefix:    q.indexPrefix,
+				IndexTimeRange: q.indexTimeRange,
 			},
-		})
+		}
+	}
+
+	if q.trace != nil {
+		q.tracef("plan %s", q.plan())
 	}
 
 	var records []R
+	if allocator != nil {
+		records = (*r)[:0]
+	}
+
+	var rowsScanned uint64
+	start := time.Now()
+	defer func() {
+		q.logSlowQuery(start, rowsScanned, len(records), err)
+	}()
+
+	var memory uint64
+	hold := func(record R) error {
+		records = append(records, record)
+		if q.memoryLimit == 0 {
+			return nil
+		}
+
+		memory += uint64(utils.SizeOf(record))
+		if memory > q.memoryLimit {
+			return fmt.Errorf("%w: %d rows use more than %d bytes",
+				ErrQueryMemoryLimitExceeded, len(records), q.memoryLimit)
+		}
+		return nil
+	}
+
+	// the records are added to the window aggregate instead of being held
+	var (
+		window     uint64
+		windowAcc  R
+		windowOpen bool
+	)
+	add := hold
+	if q.windowFunc != nil {
+		add = func(record R) (err error) {
+			defer q.table.recoverPanic(&err, nil, nil, "window")
+
+			recordWindow := q.windowFunc(record)
+			if windowOpen && recordWindow != window {
+				if err = hold(windowAcc); err != nil {
+					return err
+				}
+				windowOpen = false
+			}
+
+			if !windowOpen {
+				window, windowAcc, windowOpen = recordWindow, utils.MakeNew[R](), true
+			}
+
+			windowAcc = q.windowAggregateFunc(window, windowAcc, record)
+			return nil
+		}
+	}
+
+	// the records replace the random records of the reservoir when it's full
+	var (
+		sampleSeen uint64
+		sampleSlot = -1
+		sampleRand *rand.Rand
+	)
+	sample := func() int {
+		sampleSeen++
+		if sampleSeen <= q.sampleSize {
+			return len(records)
+		}
+		return int(sampleRand.Int63n(int64(sampleSeen)))
+	}
+	if q.sampleSize > 0 {
+		sampleRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+		add = func(record R) error {
+			slot := sampleSlot
+			if slot < 0 {
+				slot = sample()
+			}
+			sampleSlot = -1
+
+			if slot >= int(q.sampleSize) {
+				return nil
+			} else if slot < len(records) {
+				records[slot] = record
+				return nil
+			}
+			return hold(record)
+		}
+	}
+
+	notInMatchers := q.newNotInMatchers(optBatch...)
+	defer func() {
+		for _, matcher := range notInMatchers {
+			matcher.close()
+		}
+	}()
+
+	// the full pages of the sequential pagination are followed by the prefetch
+	var pageEnd []byte
+	prefetch := q.table.sequentialPrefetch != nil && q.limit > 0 && q.orderLessFunc == nil &&
+		q.windowFunc == nil && q.sampleSize == 0 && (len(optBatch) == 0 || optBatch[0] == nil)
+
 	for _, query := range q.queries {
 		count := uint64(0)
 		skippedFirstRow := false
-		err := q.table.ScanIndexForEach(ctx, query.Index, query.IndexSelector, func(_ KeyBytes, lazy Lazy[R]) (bool, error) {
+		scan := q.table.ScanIndexForEach
+		if query.IndexPrefix {
+			scan = q.table.scanIndexPrefixForEach
+		}
+		if query.IndexTimeRange != nil {
+			timeRange := *query.IndexTimeRange
+			scan = func(ctx context.Context, idx *Index[R], _ R, f func(keyBytes KeyBytes, t Lazy[R]) (bool, error), optBatch ...Batch) error {
+				return q.table.scanIndexTimeRangeForEach(ctx, idx, timeRange, f, optBatch...)
+			}
+		}
+
+		err := scan(ctx, query.Index, query.IndexSelector, func(keyBytes KeyBytes, lazy Lazy[R]) (bool, error) {
+			rowsScanned++
+			q.traceKey("visit", keyBytes)
+
+			if err := q.checkBudget(start, rowsScanned); err != nil {
+				return false, err
+			}
+
 			if q.isAfter && !skippedFirstRow {
 				skippedFirstRow = true
+				q.traceKey("skip after", keyBytes)
 				return true, nil
 			}
 
 			// check if can apply offset in here
 			if q.shouldApplyOffsetEarly() && q.offset > count {
 				count++
+				q.traceKey("skip offset", keyBytes)
 				return true, nil
 			}
 
+			// reject the row by the index payload before fetching it
+			if q.payloadFilter != nil {
+				ok, err := q.filterPayload(query, keyBytes, lazy)
+				if err != nil {
+					return false, err
+				}
+				if !ok {
+					q.traceKey("reject payload", keyBytes)
+					return true, nil
+				}
+			}
+
+			// skip the row before fetching if it's not sampled
+			if q.sampleSize > 0 && !q.shouldFilter(query) {
+				if sampleSlot = sample(); sampleSlot >= int(q.sampleSize) {
+					sampleSlot = -1
+					q.traceKey("skip sample", keyBytes)
+					return true, nil
+				}
+			}
+
 			// get and deserialize
-			record, err := lazy.Get()
+			var record R
+			var err error
+			if allocator != nil && q.windowFunc == nil && q.sampleSize == 0 {
+				record = q.reuseRecord(records, allocator)
+				err = lazy.GetInto(&record)
+			} else {
+				record, err = lazy.Get()
+			}
 			if err != nil {
 				return false, err
 			}
+			q.traceRow("fetch", keyBytes)
+
+			// the deserialization and the filter may be heavy
+			select {
+			case <-ctx.Done():
+				return false, fmt.Errorf("context done: %w", ctx.Err())
+			default:
+			}
 
 			// filter if filter available
 			if q.shouldFilter(query) {
-				if query.FilterFunc(record) {
-					records = append(records, record)
+				ok, err := q.filter(query, notInMatchers, keyBytes, record)
+				if err != nil {
+					return false, err
+				}
+				if ok {
+					q.traceKey("match", keyBytes)
+					if err = add(record); err != nil {
+						return false, err
+					}
 					count++
+				} else {
+					q.traceKey("reject filter", keyBytes)
 				}
 			} else {
-				records = append(records, record)
+				q.traceKey("match", keyBytes)
+				if err = add(record); err != nil {
+					return false, err
+				}
 				count++
 			}
 
 			next := true
 			// check if we need to iterate further
-			if !q.shouldSort() && q.shouldLimit() {
+			if !q.shouldSort() && q.windowFunc == nil && q.shouldLimit() {
 				next = count < q.offset+q.limit
 			}
 
+			if !next && prefetch {
+				pageEnd = append([]byte{}, keyBytes...)
+			}
+
 			return next, nil
 		}, optBatch...)
 		if err != nil {
 			return err
 		}
+
+		if pageEnd != nil {
+			q.prefetchNextPage(query, pageEnd)
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	if windowOpen {
+		if err := hold(windowAcc); err != nil {
+			return err
+		}
 	}
 
 	// sorting
 	if q.shouldSort() {
-		sort.Slice(records, func(i, j int) bool {
-			return q.orderLessFunc(records[i], records[j])
-		})
+		if err := q.checkBudget(start, rowsScanned); err != nil {
+			return err
+		}
+
+		if err := q.sort(ctx, records); err != nil {
+			return err
+		}
 	}
 
 	// offset
 	if !q.isOffsetApplied() {
 		if int(q.offset) >= len(records) {
-			records = make([]R, 0)
+			records = records[:0]
+		} else if allocator != nil {
+			// the skipped row objects are moved after the rows, so the spare
+			// capacity does not hold the objects of the returned rows
+			skipped := append([]R{}, records[:q.offset]...)
+			n := copy(records, records[q.offset:])
+			copy(records[n:], skipped)
+			records = records[:n]
 		} else {
 			records = records[q.offset:]
 		}
@@ -209,11 +772,118 @@ func (q Query[R]) Execute(ctx context.Context, r *[]R, optBatch ...Batch) error
 
 	*r = records
 
+	q.tracef("result %d rows", len(records))
+	return nil
+}
+
+// checkBudget returns the error once the query scanned more rows or ran longer
+// than it is allowed to.
+func (q Query[R]) checkBudget(start time.Time, rowsScanned uint64) error {
+	if q.maxScanRows > 0 && rowsScanned > q.maxScanRows {
+		return fmt.Errorf("%w: more than %d rows scanned", ErrQueryScanLimitExceeded, q.maxScanRows)
+	}
+
+	if q.deadline > 0 {
+		if elapsed := time.Since(start); elapsed > q.deadline {
+			return fmt.Errorf("%w: running for %s, deadline %s", ErrQueryDeadlineExceeded, elapsed, q.deadline)
+		}
+	}
+	return nil
+}
+
+// reuseRecord returns the row object that lays in the spare capacity of
+// records or a new one if there is none.
+func (q Query[R]) reuseRecord(records []R, allocator func() R) R {
+	if len(records) == cap(records) {
+		return allocator()
+	}
+
+	spare := records[:len(records)+1]
+	if rv := reflect.ValueOf(&spare[len(records)]).Elem(); rv.Kind() != reflect.Ptr || rv.IsNil() {
+		spare[len(records)] = allocator()
+	}
+	return spare[len(records)]
+}
+
+// filter applies the query filter and the NotIn conditions to the record. The
+// panic of the filter is returned as the error with the key of the record.
+func (q Query[R]) filter(query FilterAndIndex[R], notInMatchers []*_keyMatcher, keyBytes KeyBytes, record R) (ok bool, err error) {
+	defer q.table.recoverPanic(&err, query.Index, keyBytes, "filter")
+
+	if query.FilterFunc != nil && !query.FilterFunc(record) {
+		return false, nil
+	}
+
+	for i, notIn := range q.notIns {
+		if notInMatchers[i].exists(notInMatchers[i].target.lookupKey(notIn.keyFunc(NewKeyBuilder([]byte{}), record))) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// sortCheckInterval is the number of comparisons of the in-memory sort between
+// the checks of the context.
+const sortCheckInterval = 1024
+
+// _sortCanceled aborts the in-memory sort once the context is done.
+type _sortCanceled struct {
+	err error
+}
+
+// sort sorts the records with the query order. The panic of the order function
+// is returned as the error. The sort is aborted once the context is done, so
+// the canceled query does not hold the CPU until the sort finishes.
+func (q Query[R]) sort(ctx context.Context, records []R) (err error) {
+	defer q.table.recoverPanic(&err, nil, nil, "order")
+	defer func() {
+		if r := recover(); r != nil {
+			canceled, ok := r.(_sortCanceled)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("context done: %w", canceled.err)
+		}
+	}()
+
+	comparisons := 0
+	sort.Slice(records, func(i, j int) bool {
+		if comparisons++; comparisons%sortCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				panic(_sortCanceled{err: err})
+			}
+		}
+		return q.orderLessFunc(records[i], records[j])
+	})
 	return nil
 }
 
+func (q Query[R]) usesPrefix() bool {
+	if q.indexPrefix {
+		return true
+	}
+	for _, query := range q.queries {
+		if query.IndexPrefix {
+			return true
+		}
+	}
+	return false
+}
+
+func (q Query[R]) usesTimeRange() bool {
+	if q.indexTimeRange != nil {
+		return true
+	}
+	for _, query := range q.queries {
+		if query.IndexTimeRange != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (q Query[R]) shouldFilter(query FilterAndIndex[R]) bool {
-	return query.FilterFunc != nil
+	return query.FilterFunc != nil || len(q.notIns) > 0
 }
 
 func (q Query[R]) shouldSort() bool {
@@ -221,7 +891,7 @@ func (q Query[R]) shouldSort() bool {
 }
 
 func (q Query[R]) shouldApplyOffsetEarly() bool {
-	return q.orderLessFunc == nil && len(q.queries) == 1 && q.queries[0].FilterFunc == nil
+	return q.orderLessFunc == nil && q.windowFunc == nil && len(q.notIns) == 0 && q.payloadFilter == nil && len(q.queries) == 1 && q.queries[0].FilterFunc == nil
 }
 
 func (q Query[R]) shouldLimit() bool {
@@ -229,9 +899,9 @@ func (q Query[R]) shouldLimit() bool {
 }
 
 func (q Query[R]) isLimitApplied() bool {
-	return q.orderLessFunc == nil
+	return q.orderLessFunc == nil && q.windowFunc == nil
 }
 
 func (q Query[R]) isOffsetApplied() bool {
-	return q.orderLessFunc == nil && len(q.queries) == 1 && q.queries[0].FilterFunc == nil
+	return q.orderLessFunc == nil && q.windowFunc == nil && len(q.notIns) == 0 && q.payloadFilter == nil && len(q.queries) == 1 && q.queries[0].FilterFunc == nil
 }
@@ -0,0 +1,103 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// QueryOverloadError is returned when the query is not admitted, as the queries
+// already running or waiting use up Options.MaxConcurrentQueries and
+// Options.MaxQueuedQueries, or the query waited longer than
+// Options.QueryQueueTimeout. It matches ErrQueryOverloaded with errors.Is.
+type QueryOverloadError struct {
+	Running int
+	Queued  int
+	Waited  time.Duration
+}
+
+func (e *QueryOverloadError) Error() string {
+	if e.Waited > 0 {
+		return fmt.Sprintf("%s: waited %s with %d queries running and %d queued", ErrQueryOverloaded, e.Waited, e.Running, e.Queued)
+	}
+	return fmt.Sprintf("%s: %d queries running and %d queued", ErrQueryOverloaded, e.Running, e.Queued)
+}
+
+func (e *QueryOverloadError) Is(target error) bool {
+	return target == ErrQueryOverloaded
+}
+
+// _queryAdmission limits the number of the queries executed at the same time.
+// The queries wait for their turn in the order they arrived, the channel hands
+// the released slot to the query that waits the longest.
+type _queryAdmission struct {
+	slots     chan struct{}
+	queued    int32
+	maxQueued int
+	timeout   time.Duration
+}
+
+func newQueryAdmission(opts *Options) *_queryAdmission {
+	if opts.MaxConcurrentQueries <= 0 {
+		return nil
+	}
+
+	return &_queryAdmission{
+		slots:     make(chan struct{}, opts.MaxConcurrentQueries),
+		maxQueued: opts.MaxQueuedQueries,
+		timeout:   opts.QueryQueueTimeout,
+	}
+}
+
+// acquire waits for the query slot and returns the function that releases it.
+func (a *_queryAdmission) acquire(ctx context.Context) (func(), error) {
+	release := func() {
+		<-a.slots
+	}
+
+	select {
+	case a.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	queued := int(atomic.AddInt32(&a.queued, 1))
+	defer atomic.AddInt32(&a.queued, -1)
+
+	if a.maxQueued > 0 && queued > a.maxQueued {
+		return nil, &QueryOverloadError{Running: len(a.slots), Queued: queued - 1}
+	}
+
+	var timeout <-chan time.Time
+	if a.timeout > 0 {
+		timer := time.NewTimer(a.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	start := time.Now()
+	select {
+	case a.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("context done: %w", ctx.Err())
+	case <-timeout:
+		return nil, &QueryOverloadError{
+			Running: len(a.slots),
+			Queued:  int(atomic.LoadInt32(&a.queued)) - 1,
+			Waited:  time.Since(start),
+		}
+	}
+}
+
+// admit waits for the turn of the query on the database with the admission
+// control. The point reads are not admitted, so they are not delayed by the
+// queries.
+func (q Query[R]) admit(ctx context.Context) (func(), error) {
+	db, ok := q.table.db.(*_db)
+	if !ok || db.queryAdmission == nil {
+		return func() {}, nil
+	}
+	return db.queryAdmission.acquire(ctx)
+}
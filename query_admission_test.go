@@ -0,0 +1,98 @@
+package bond
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Query_MaxConcurrentQueries(t *testing.T) {
+	const dbName = "test_db_admission"
+
+	db, err := Open(dbName, &Options{
+		MaxConcurrentQueries: 1,
+		MaxQueuedQueries:     1,
+		QueryQueueTimeout:    time.Second,
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount", Balance: 5},
+	})
+	require.NoError(t, err)
+
+	var (
+		running = make(chan struct{})
+		unblock = make(chan struct{})
+		done    = make(chan error, 2)
+	)
+
+	go func() {
+		var trs []*TokenBalance
+		done <- tokenBalanceTable.Query().Filter(func(tb *TokenBalance) bool {
+			close(running)
+			<-unblock
+			return true
+		}).Execute(context.Background(), &trs)
+	}()
+	<-running
+
+	// the second query waits for its turn
+	go func() {
+		var trs []*TokenBalance
+		done <- tokenBalanceTable.Query().Execute(context.Background(), &trs)
+	}()
+
+	require.Eventually(t, func() bool {
+		admission := db.(*_db).queryAdmission
+		return atomic.LoadInt32(&admission.queued) == 1
+	}, time.Second, time.Millisecond)
+
+	// the third query is rejected, as the queue is full
+	var trs []*TokenBalance
+	err = tokenBalanceTable.Query().Execute(context.Background(), &trs)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrQueryOverloaded)
+
+	var overloadErr *QueryOverloadError
+	require.True(t, errors.As(err, &overloadErr))
+	assert.Equal(t, 1, overloadErr.Running)
+	assert.Equal(t, 1, overloadErr.Queued)
+
+	// the key only scans are limited too
+	_, err = tokenBalanceTable.Query().Keys(context.Background())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrQueryOverloaded)
+
+	// the point reads are not limited
+	tr, err := tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), tr.Balance)
+
+	close(unblock)
+	require.NoError(t, <-done)
+	require.NoError(t, <-done)
+
+	err = tokenBalanceTable.Query().Execute(context.Background(), &trs)
+	require.NoError(t, err)
+	assert.Len(t, trs, 1)
+}
@@ -0,0 +1,174 @@
+package bond
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// _queryCache is the LRU cache of query results. The entries are indexed by
+// the index prefixes the queries read from, so the writes that touch those
+// prefixes remove them.
+//
+// The generation is increased on every invalidation, the results are cached
+// only if the generation did not change while the query was executed.
+type _queryCache[T any] struct {
+	size int
+
+	generation uint64
+	entries    map[string]*list.Element
+	prefixes   map[string]map[string]struct{}
+	lru        *list.List
+
+	mutex sync.Mutex
+}
+
+type _queryCacheEntry[T any] struct {
+	key      string
+	prefixes []string
+	rows     []T
+}
+
+func newQueryCache[T any](size int) *_queryCache[T] {
+	return &_queryCache[T]{
+		size:     size,
+		entries:  make(map[string]*list.Element, size),
+		prefixes: make(map[string]map[string]struct{}),
+		lru:      list.New(),
+	}
+}
+
+func (c *_queryCache[T]) Generation() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.generation
+}
+
+func (c *_queryCache[T]) Get(key string) ([]T, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		return append([]T{}, elem.Value.(*_queryCacheEntry[T]).rows...), true
+	}
+	return nil, false
+}
+
+func (c *_queryCache[T]) Put(key string, prefixes [][]byte, rows []T, generation uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	entry := &_queryCacheEntry[T]{key: key, rows: append([]T{}, rows...)}
+	for _, prefix := range prefixes {
+		entry.prefixes = append(entry.prefixes, string(prefix))
+
+		keys, ok := c.prefixes[string(prefix)]
+		if !ok {
+			keys = make(map[string]struct{})
+			c.prefixes[string(prefix)] = keys
+		}
+		keys[key] = struct{}{}
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *_queryCache[T]) Invalidate(prefixes [][]byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	for _, prefix := range prefixes {
+		for key := range c.prefixes[string(prefix)] {
+			c.remove(c.entries[key])
+		}
+	}
+}
+
+func (c *_queryCache[T]) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generation++
+	c.entries = make(map[string]*list.Element, c.size)
+	c.prefixes = make(map[string]map[string]struct{})
+	c.lru.Init()
+}
+
+func (c *_queryCache[T]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
+
+func (c *_queryCache[T]) remove(elem *list.Element) {
+	entry := elem.Value.(*_queryCacheEntry[T])
+	for _, prefix := range entry.prefixes {
+		delete(c.prefixes[prefix], entry.key)
+		if len(c.prefixes[prefix]) == 0 {
+			delete(c.prefixes, prefix)
+		}
+	}
+
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+}
+
+// executeCached returns the cached query result or executes the query and
+// caches its result.
+func (q Query[R]) executeCached(ctx context.Context, r *[]R) error {
+	cache := q.table.queryCache
+	key, prefixes := q.cacheKey()
+
+	if rows, ok := cache.Get(key); ok {
+		q.tracef("cache hit %d rows", len(rows))
+		*r = rows
+		return nil
+	}
+
+	generation := cache.Generation()
+
+	err := q.execute(ctx, r, nil)
+	if err != nil {
+		return err
+	}
+
+	cache.Put(key, prefixes, *r, generation)
+	return nil
+}
+
+// cacheKey returns the key of the query result and the index prefixes
+// the query reads from.
+func (q Query[R]) cacheKey() (string, [][]byte) {
+	queries := q.queries
+	if len(queries) == 0 {
+		queries = []FilterAndIndex[R]{{Index: q.index, IndexSelector: q.indexSelector}}
+	}
+
+	var key strings.Builder
+	_, _ = fmt.Fprintf(&key, "%q|%d|%d|%t", q.cacheFingerprint, q.offset, q.limit, q.isAfter)
+
+	prefixes := make([][]byte, 0, len(queries))
+	for _, query := range queries {
+		selector := q.table.indexKey(query.IndexSelector, query.Index, make([]byte, 0, DataKeyBufferSize))
+		_, _ = fmt.Fprintf(&key, "|%x", selector)
+
+		prefixes = append(prefixes, q.table.keyPrefix(query.Index, query.IndexSelector, make([]byte, 0, DataKeyBufferSize)))
+	}
+
+	return key.String(), prefixes
+}
@@ -0,0 +1,113 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Query_Cached(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		QueryCacheSize: 8,
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	cache := tokenBalanceTable.(*_table[*TokenBalance]).queryCache
+	require.NotNil(t, cache)
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount1", Balance: 7},
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	query := tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount1"}).
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance > 6
+		}).
+		Cached("balance>6")
+
+	var tokenBalancesFromQuery []*TokenBalance
+	err = query.Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalances[1]}, tokenBalancesFromQuery)
+	assert.Equal(t, 1, cache.Len())
+
+	// served from the cache
+	var tokenBalancesFromCache []*TokenBalance
+	err = query.Execute(context.Background(), &tokenBalancesFromCache)
+	require.NoError(t, err)
+	assert.Same(t, tokenBalancesFromQuery[0], tokenBalancesFromCache[0])
+
+	// the write to the other index prefix keeps the cached result
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 3, AccountAddress: "0xtestAccount2", Balance: 9},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, cache.Len())
+
+	// the write to the same index prefix invalidates the cached result
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", Balance: 15},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, cache.Len())
+
+	err = query.Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", Balance: 15},
+		tokenBalances[1],
+	}, tokenBalancesFromQuery)
+	assert.Equal(t, 1, cache.Len())
+
+	// the write to the batch invalidates the cached result once committed
+	batch := db.Batch()
+	defer func() { _ = batch.Close() }()
+
+	err = tokenBalanceTable.Delete(context.Background(), []*TokenBalance{tokenBalances[1]}, batch)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cache.Len())
+
+	err = query.Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(tokenBalancesFromQuery))
+	assert.Equal(t, 1, cache.Len())
+
+	err = batch.Commit(Sync)
+	require.NoError(t, err)
+	assert.Equal(t, 0, cache.Len())
+
+	err = query.Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, 1, len(tokenBalancesFromQuery))
+}
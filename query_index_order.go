@@ -0,0 +1,109 @@
+package bond
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// IndexOrderError is returned by the query with OrderPreserveIndex that can
+// not return the rows in the index order. The Reason is the part of the query
+// that would reorder the rows. It matches ErrIndexOrderNotPreserved with
+// errors.Is.
+type IndexOrderError struct {
+	Reason string
+}
+
+func (e *IndexOrderError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrIndexOrderNotPreserved, e.Reason)
+}
+
+func (e *IndexOrderError) Is(target error) bool {
+	return target == ErrIndexOrderNotPreserved
+}
+
+// OrderPreserveIndex guarantees that the rows are returned strictly in the
+// order of the index keys, so the pages read with Offset and Limit, or with
+// After, are stable when combined with Filter. The filters of the query that
+// use the same index and selector are applied with the single scan, the row
+// is returned once if it matches any of them, as with Paginate.
+//
+// The query fails with IndexOrderError if it uses Order, Window, Sample or
+// WithBestIndex, or its filters use different indexes or selectors, as the
+// order of their rows is not the index order. The in-memory Order is not
+// stable for the rows it considers equal, so the pages of such query may
+// repeat or skip the rows.
+//
+// Example:
+//
+//	t.Query().
+//		With(AccountAddressIndex, &TokenBalance{AccountAddress: "0xab"}).
+//		Filter(func(tb *TokenBalance) bool {
+//			return tb.Balance > 25
+//		}).
+//		OrderPreserveIndex().
+//		Offset(100).
+//		Limit(50)
+func (q Query[R]) OrderPreserveIndex() Query[R] {
+	q.preserveIndexOrder = true
+	return q
+}
+
+// indexOrderQuery returns the query that scans the index once, with the
+// filters of the query merged.
+func (q Query[R]) indexOrderQuery() (Query[R], error) {
+	switch {
+	case q.orderLessFunc != nil:
+		return q, &IndexOrderError{Reason: "order sorts the rows in memory"}
+	case q.windowFunc != nil:
+		return q, &IndexOrderError{Reason: "window aggregates the rows"}
+	case q.sampleSize > 0:
+		return q, &IndexOrderError{Reason: "sample returns the rows in random order"}
+	case len(q.indexCandidates) > 0:
+		return q, &IndexOrderError{Reason: "best index may change between executions"}
+	}
+
+	if len(q.queries) < 2 {
+		return q, nil
+	}
+
+	first := q.queries[0]
+	firstKey, err := q.table.safeIndexKey(first.IndexSelector, first.Index, make([]byte, 0, DataKeyBufferSize))
+	if err != nil {
+		return q, err
+	}
+
+	filters := make([]FilterFunc[R], 0, len(q.queries))
+	for _, query := range q.queries {
+		key, err := q.table.safeIndexKey(query.IndexSelector, query.Index, make([]byte, 0, DataKeyBufferSize))
+		if err != nil {
+			return q, err
+		}
+
+		if query.Index.IndexID != first.Index.IndexID || query.IndexPrefix != first.IndexPrefix || query.IndexTimeRange != first.IndexTimeRange || !bytes.Equal(key, firstKey) {
+			return q, &IndexOrderError{Reason: "filters use multiple indexes or selectors"}
+		}
+
+		// the filter that matches every row makes the others irrelevant
+		if query.FilterFunc == nil {
+			filters = nil
+			break
+		}
+		filters = append(filters, query.FilterFunc)
+	}
+
+	merged := first
+	merged.FilterFunc = nil
+	if filters != nil {
+		merged.FilterFunc = func(r R) bool {
+			for _, filter := range filters {
+				if filter(r) {
+					return true
+				}
+			}
+			return false
+		}
+	}
+
+	q.queries = []FilterAndIndex[R]{merged}
+	return q, nil
+}
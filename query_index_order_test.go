@@ -0,0 +1,79 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Query_OrderPreserveIndex(t *testing.T) {
+	db, tokenBalanceTable, accountAddressIndex, accountAndContractAddressIndex := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i),
+			AccountAddress:  "0xtestAccount",
+			ContractAddress: "0xtestContract",
+			Balance:         uint64(i * 10 % 11),
+		})
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	query := tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance <= 2
+		}).
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance >= 8
+		})
+
+	// the filters are scanned one after another
+	var tokenBalancesRead []*TokenBalance
+	err = query.Execute(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalances[8], tokenBalances[9], tokenBalances[0], tokenBalances[1], tokenBalances[2]}, tokenBalancesRead)
+
+	// the filters are merged into the single scan in the index order
+	err = query.OrderPreserveIndex().Execute(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalances[0], tokenBalances[1], tokenBalances[2], tokenBalances[8], tokenBalances[9]}, tokenBalancesRead)
+
+	var pages []*TokenBalance
+	for offset := uint64(0); offset < 5; offset += 3 {
+		var page []*TokenBalance
+		err = query.OrderPreserveIndex().Offset(offset).Limit(3).Execute(context.Background(), &page)
+		require.NoError(t, err)
+		pages = append(pages, page...)
+	}
+	assert.Equal(t, tokenBalancesRead, pages)
+
+	err = query.OrderPreserveIndex().
+		Order(func(tb, tb2 *TokenBalance) bool {
+			return tb.Balance < tb2.Balance
+		}).
+		Execute(context.Background(), &tokenBalancesRead)
+	require.ErrorIs(t, err, ErrIndexOrderNotPreserved)
+
+	var indexOrderErr *IndexOrderError
+	require.ErrorAs(t, err, &indexOrderErr)
+	assert.Equal(t, "order sorts the rows in memory", indexOrderErr.Reason)
+
+	err = query.
+		With(accountAndContractAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount", ContractAddress: "0xtestContract"}).
+		Filter(func(tb *TokenBalance) bool {
+			return true
+		}).
+		OrderPreserveIndex().
+		Execute(context.Background(), &tokenBalancesRead)
+	require.ErrorIs(t, err, ErrIndexOrderNotPreserved)
+
+	err = tokenBalanceTable.Query().Sample(2).OrderPreserveIndex().Execute(context.Background(), &tokenBalancesRead)
+	require.ErrorIs(t, err, ErrIndexOrderNotPreserved)
+}
@@ -0,0 +1,195 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// JoinPageSize is the number of the left rows for which the right rows are
+// looked up at once.
+const JoinPageSize = 1000
+
+// JoinKeyFunc builds the primary key of the right table row for the left row.
+type JoinKeyFunc[A any] func(builder KeyBuilder, a A) []byte
+
+// JoinPair is the left row with the matching right row.
+type JoinPair[A any, B any] struct {
+	Left  A
+	Right B
+}
+
+// Join executes the query and matches its rows with the rows of the table by
+// the primary key built with keyFunc. The rows without the match are skipped.
+// The right rows are retrieved with the batched lookups for each page of the
+// query rows.
+func Join[A any, B any](ctx context.Context, query Query[A], table Table[B], keyFunc JoinKeyFunc[A], result *[]JoinPair[A, B], optBatch ...Batch) error {
+	return JoinProject(ctx, query, table, keyFunc, func(a A, b B) JoinPair[A, B] {
+		return JoinPair[A, B]{Left: a, Right: b}
+	}, result, optBatch...)
+}
+
+// JoinProject is Join with the matched rows projected into the result type.
+func JoinProject[A any, B any, P any](ctx context.Context, query Query[A], table Table[B], keyFunc JoinKeyFunc[A], projectFunc func(a A, b B) P, result *[]P, optBatch ...Batch) error {
+	right, ok := table.(*_table[B])
+	if !ok {
+		return fmt.Errorf("join: table %s is not supported", table.Name())
+	}
+
+	var batch Batch
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		batch = optBatch[0]
+	}
+
+	var left []A
+	err := query.Execute(ctx, &left, optBatch...)
+	if err != nil {
+		return err
+	}
+
+	projected := make([]P, 0, len(left))
+	keys := make([]PrimaryKey, 0, JoinPageSize)
+	for start := 0; start < len(left); start += JoinPageSize {
+		end := start + JoinPageSize
+		if end > len(left) {
+			end = len(left)
+		}
+		page := left[start:end]
+
+		keys = keys[:0]
+		for _, a := range page {
+			keys = append(keys, keyFunc(NewKeyBuilder([]byte{}), a))
+		}
+
+		rows, found, err := right.getByKeys(ctx, keys, true, batch)
+		if err != nil {
+			return err
+		}
+
+		for i, a := range page {
+			if found[i] {
+				projected = append(projected, projectFunc(a, rows[i]))
+			}
+		}
+	}
+
+	*result = projected
+	return nil
+}
+
+// JoinTarget is the table or the index the query rows are matched with.
+type JoinTarget struct {
+	tableID TableID
+	indexID IndexID
+}
+
+// JoinTable targets the rows of the table. The key function of the join builds
+// the primary key of the table row.
+func JoinTable(table TableInfo) JoinTarget {
+	return JoinTarget{tableID: table.ID(), indexID: PrimaryIndexID}
+}
+
+// JoinIndex targets the index entries of the table. The key function of the
+// join builds the index key of the entries.
+func JoinIndex(table TableInfo, idx IndexInfo) JoinTarget {
+	return JoinTarget{tableID: table.ID(), indexID: idx.ID()}
+}
+
+// lookupKey returns the row key for the table, or the prefix of the entries
+// with the index key for the index.
+func (j JoinTarget) lookupKey(key []byte) []byte {
+	if j.indexID == PrimaryIndexID {
+		return KeyEncode(Key{
+			TableID:    j.tableID,
+			IndexID:    PrimaryIndexID,
+			IndexKey:   []byte{},
+			IndexOrder: []byte{},
+			PrimaryKey: key,
+		})
+	}
+
+	return KeyEncode(Key{
+		TableID:  j.tableID,
+		IndexID:  j.indexID,
+		IndexKey: key,
+	})
+}
+
+type _notIn[R any] struct {
+	target  JoinTarget
+	keyFunc func(builder KeyBuilder, r R) []byte
+}
+
+// NotIn filters out the records whose key exists in the target table or index,
+// e.g. the balances without the account row:
+//
+//	t.Query().
+//		NotIn(JoinTable(AccountTable), func(builder KeyBuilder, b *Balance) []byte {
+//			return builder.AddStringField(b.AccountAddress).Bytes()
+//		})
+//
+// The target is read with the single iterator that seeks forward, so the check
+// is a streaming merge if the records are read in the order of the target keys.
+func (q Query[R]) NotIn(target JoinTarget, keyFunc func(builder KeyBuilder, r R) []byte) Query[R] {
+	notIns := make([]_notIn[R], 0, len(q.notIns)+1)
+	q.notIns = append(append(notIns, q.notIns...), _notIn[R]{target: target, keyFunc: keyFunc})
+	return q
+}
+
+func (q Query[R]) newNotInMatchers(optBatch ...Batch) []*_keyMatcher {
+	matchers := make([]*_keyMatcher, 0, len(q.notIns))
+	for _, notIn := range q.notIns {
+		matchers = append(matchers, newKeyMatcher(q.table.db, notIn.target, optBatch...))
+	}
+	return matchers
+}
+
+// _keyMatcher checks the existence of the keys in the target. The lookups
+// in the ascending key order reuse the iterator position instead of seeking.
+type _keyMatcher struct {
+	target  JoinTarget
+	iter    Iterator
+	exact   bool
+	lastKey []byte
+}
+
+func newKeyMatcher(db DB, target JoinTarget, optBatch ...Batch) *_keyMatcher {
+	target.tableID = storageIDOf(db, target.tableID)
+	return &_keyMatcher{
+		target: target,
+		iter: db.Iter(&IterOptions{
+			IterOptions: pebble.IterOptions{
+				LowerBound: []byte{byte(target.tableID), byte(target.indexID)},
+				UpperBound: []byte{byte(target.tableID), byte(target.indexID + 1)},
+			},
+		}, optBatch...),
+		exact: target.indexID == PrimaryIndexID,
+	}
+}
+
+func (m *_keyMatcher) exists(key []byte) bool {
+	// the iterator is at the first entry after the last key, so it is also
+	// the first entry after the key if it's between the two
+	if m.lastKey == nil || bytes.Compare(key, m.lastKey) < 0 ||
+		(m.iter.Valid() && bytes.Compare(m.iter.Key(), key) < 0) {
+		m.iter.SeekGE(key)
+	} else if !m.iter.Valid() {
+		return false
+	}
+	m.lastKey = append(m.lastKey[:0], key...)
+
+	if !m.iter.Valid() {
+		return false
+	}
+
+	if m.exact {
+		return bytes.Equal(m.iter.Key(), key)
+	}
+	return bytes.HasPrefix(m.iter.Key(), key)
+}
+
+func (m *_keyMatcher) close() {
+	_ = m.iter.Close()
+}
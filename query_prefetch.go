@@ -0,0 +1,146 @@
+package bond
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+)
+
+const (
+	// sequentialPrefetchMaxPageEnds is the number of the page ends remembered
+	// to detect the sequential pagination.
+	sequentialPrefetchMaxPageEnds = 1024
+
+	// sequentialPrefetchMaxInFlight is the number of the pages prefetched at
+	// the same time.
+	sequentialPrefetchMaxInFlight = 4
+)
+
+// _sequentialPrefetch detects the queries that continue after the last row of
+// the page returned by the previous query and reads the page that follows them
+// ahead, so its index entries and rows are in the block cache when the next
+// query asks for them.
+type _sequentialPrefetch struct {
+	pageEnds map[string]struct{}
+	inFlight int
+	closed   bool
+
+	wg    sync.WaitGroup
+	mutex sync.Mutex
+}
+
+func newSequentialPrefetch(db DB) *_sequentialPrefetch {
+	p := &_sequentialPrefetch{pageEnds: make(map[string]struct{})}
+	db.OnClose(func(DB) {
+		p.close()
+	})
+	return p
+}
+
+// pageRead records the end of the page that was read and returns true if the
+// page continued after the end of the previous one.
+func (p *_sequentialPrefetch) pageRead(after []byte, pageEnd []byte) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	_, sequential := p.pageEnds[string(after)]
+	if sequential {
+		delete(p.pageEnds, string(after))
+	}
+
+	if len(p.pageEnds) >= sequentialPrefetchMaxPageEnds {
+		p.pageEnds = make(map[string]struct{})
+	}
+	p.pageEnds[string(pageEnd)] = struct{}{}
+
+	return sequential
+}
+
+// start returns false if the prefetch can not be started now.
+func (p *_sequentialPrefetch) start() bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed || p.inFlight >= sequentialPrefetchMaxInFlight {
+		return false
+	}
+
+	p.inFlight++
+	p.wg.Add(1)
+	return true
+}
+
+func (p *_sequentialPrefetch) done() {
+	p.mutex.Lock()
+	p.inFlight--
+	p.mutex.Unlock()
+	p.wg.Done()
+}
+
+// close waits for the prefetches in flight, as the database is being closed.
+func (p *_sequentialPrefetch) close() {
+	p.mutex.Lock()
+	p.closed = true
+	p.mutex.Unlock()
+	p.wg.Wait()
+}
+
+// prefetchNextPage reads the page that follows the page of the query in the
+// background if the query continued the previous page.
+func (q Query[R]) prefetchNextPage(query FilterAndIndex[R], pageEnd []byte) {
+	p := q.table.sequentialPrefetch
+	if !q.isAfter || query.IndexPrefix || len(q.queries) > 1 {
+		p.pageRead(nil, pageEnd)
+		return
+	}
+
+	after, err := q.table.safeIndexKey(query.IndexSelector, query.Index, make([]byte, 0, DataKeyBufferSize))
+	if err != nil || !p.pageRead(after, pageEnd) || !p.start() {
+		return
+	}
+
+	go func() {
+		defer p.done()
+		q.table.prefetchRows(query.Index, pageEnd, int(q.limit))
+	}()
+}
+
+// prefetchRows reads the index entries that follow the key and their rows, the
+// rows are added to the row cache if the table has one.
+func (t *_table[T]) prefetchRows(idx *Index[T], key []byte, count int) {
+	prefix := key[:_KeyPrefixSplitIndex(key)]
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: key,
+			UpperBound: prefixUpperBound(prefix),
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	var keyBuffer [DataKeyBufferSize]byte
+	for iter.First(); iter.Valid() && count > 0; iter.Next() {
+		if bytes.Equal(iter.Key(), key) {
+			continue
+		}
+		count--
+
+		if idx.IndexID == PrimaryIndexID {
+			_ = iter.Value()
+			continue
+		}
+
+		dataKey := KeyBytes(iter.Key()).ToDataKeyBytes(keyBuffer[:0])
+		if t.cache != nil {
+			_, _ = t.get(dataKey, nil)
+			continue
+		}
+
+		_, closer, err := t.db.Get(dataKey, nil)
+		if err == nil {
+			_ = closer.Close()
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Query_SequentialPrefetch(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		CacheSize:          100,
+		SequentialPrefetch: true,
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < 40; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: "0xtestContract",
+			AccountAddress:  fmt.Sprintf("0xtestAccount%d", i%2),
+			Balance:         uint64(i),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	table := tokenBalanceTable.(*_table[*TokenBalance])
+	cached := func(tb *TokenBalance) bool {
+		_, ok := table.cache.Get(table.key(tb, make([]byte, 0, DataKeyBufferSize)))
+		return ok
+	}
+
+	query := tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount0"}).
+		Limit(5)
+
+	var page []*TokenBalance
+	err = query.Execute(context.Background(), &page)
+	require.NoError(t, err)
+	require.Len(t, page, 5)
+
+	// the first page after the previous one is not prefetched yet
+	err = query.After(page[len(page)-1]).Execute(context.Background(), &page)
+	require.NoError(t, err)
+	require.Len(t, page, 5)
+	assert.Equal(t, uint64(11), page[0].ID)
+
+	table.sequentialPrefetch.wg.Wait()
+
+	// the page that follows is read ahead
+	for _, tb := range tokenBalances[20:30] {
+		assert.Equal(t, tb.AccountAddress == "0xtestAccount0", cached(tb), "row %d", tb.ID)
+	}
+
+	err = query.After(page[len(page)-1]).Execute(context.Background(), &page)
+	require.NoError(t, err)
+	require.Len(t, page, 5)
+	assert.Equal(t, uint64(21), page[0].ID)
+
+	table.sequentialPrefetch.wg.Wait()
+
+	// the prefetch stays within the index key prefix
+	for _, tb := range tokenBalances[30:] {
+		assert.Equal(t, tb.AccountAddress == "0xtestAccount0", cached(tb), "row %d", tb.ID)
+	}
+}
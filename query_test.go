@@ -4,7 +4,9 @@ import (
 	"context"
 	"math"
 	"testing"
+	"time"
 
+	"github.com/go-bond/bond/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -210,6 +212,53 @@ func TestBond_Query_Context_Canceled(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestBond_Query_Context_Canceled_Order(t *testing.T) {
+	db, TokenBalanceTable, _, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 5000; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i),
+			AccountID:       1,
+			ContractAddress: "0xtestContract",
+			AccountAddress:  "0xtestAccount",
+			Balance:         uint64(i % 97),
+		})
+	}
+
+	err := TokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	comparisons := 0
+	var tokenBalancesFromQuery []*TokenBalance
+	err = TokenBalanceTable.Query().
+		Order(func(tb *TokenBalance, tb2 *TokenBalance) bool {
+			// the query is canceled once the sort starts
+			comparisons++
+			cancel()
+			return tb.Balance < tb2.Balance
+		}).
+		Execute(ctx, &tokenBalancesFromQuery)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrCallbackPanic)
+	assert.Less(t, comparisons, 2*sortCheckInterval)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	err = TokenBalanceTable.Query().
+		Filter(func(tb *TokenBalance) bool {
+			cancel()
+			return true
+		}).
+		Execute(ctx, &tokenBalancesFromQuery)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
 func TestBond_Query_Last_Row_As_Selector(t *testing.T) {
 	db, TokenBalanceTable, _, lastIndex := setupDatabaseForQuery()
 	defer tearDownDatabase(db)
@@ -1029,3 +1078,743 @@ func TestBond_Query_Indexes_Mix(t *testing.T) {
 
 	assert.Equal(t, tokenBalanceAccount1, tokenBalances[0])
 }
+
+func TestBond_Query_ExecuteReuse(t *testing.T) {
+	db, TokenBalanceTable, TokenBalanceAccountAddressIndex, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	tokenBalanceAccount1 := &TokenBalance{
+		ID:              1,
+		AccountID:       1,
+		ContractAddress: "0xtestContract",
+		AccountAddress:  "0xtestAccount",
+		Balance:         5,
+	}
+
+	tokenBalance2Account1 := &TokenBalance{
+		ID:              2,
+		AccountID:       1,
+		ContractAddress: "0xtestContract2",
+		AccountAddress:  "0xtestAccount",
+		Balance:         15,
+	}
+
+	tokenBalance1Account2 := &TokenBalance{
+		ID:              3,
+		AccountID:       2,
+		ContractAddress: "0xtestContract",
+		AccountAddress:  "0xtestAccount2",
+		Balance:         4,
+	}
+
+	err := TokenBalanceTable.Insert(
+		context.Background(),
+		[]*TokenBalance{
+			tokenBalanceAccount1,
+			tokenBalance2Account1,
+			tokenBalance1Account2,
+		},
+	)
+	require.NoError(t, err)
+
+	allocations := 0
+	allocator := func() *TokenBalance {
+		allocations++
+		return &TokenBalance{}
+	}
+
+	tokenBalances := make([]*TokenBalance, 0, 3)
+
+	err = TokenBalanceTable.Query().ExecuteReuse(context.Background(), &tokenBalances, allocator)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(tokenBalances))
+	assert.Equal(t, 3, allocations)
+	assert.Equal(t, tokenBalanceAccount1, tokenBalances[0])
+	assert.Equal(t, tokenBalance2Account1, tokenBalances[1])
+	assert.Equal(t, tokenBalance1Account2, tokenBalances[2])
+
+	firstRow := tokenBalances[0]
+
+	err = TokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount2"}).
+		ExecuteReuse(context.Background(), &tokenBalances, allocator)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(tokenBalances))
+	assert.Equal(t, 3, allocations)
+	assert.Equal(t, tokenBalance1Account2, tokenBalances[0])
+	assert.True(t, firstRow == tokenBalances[0])
+
+	err = TokenBalanceTable.Query().
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance > 4
+		}).
+		Offset(1).
+		ExecuteReuse(context.Background(), &tokenBalances, allocator)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(tokenBalances))
+	assert.Equal(t, 3, allocations)
+	assert.Equal(t, tokenBalance2Account1, tokenBalances[0])
+}
+
+func TestBond_Query_ExecuteReuse_Offset(t *testing.T) {
+	db, TokenBalanceTable, _, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 6; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i),
+			AccountID:       uint32(i),
+			ContractAddress: "0xtestContract",
+			AccountAddress:  "0xtestAccount",
+			Balance:         uint64(i),
+		})
+	}
+
+	err := TokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	allocator := func() *TokenBalance {
+		return &TokenBalance{}
+	}
+
+	query := TokenBalanceTable.Query().
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance > 0
+		}).
+		Offset(2)
+
+	// the rows skipped by the offset do not share the objects with the
+	// returned rows, so the next execution does not overwrite them
+	var result []*TokenBalance
+	for i := 0; i < 2; i++ {
+		err = query.ExecuteReuse(context.Background(), &result, allocator)
+		require.NoError(t, err)
+		assert.Equal(t, tokenBalances[2:], result)
+	}
+}
+
+func TestBond_Query_Keys(t *testing.T) {
+	db, TokenBalanceTable, TokenBalanceAccountAddressIndex, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 5; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i),
+			AccountID:       1,
+			ContractAddress: "0xtestContract",
+			AccountAddress:  "0xtestAccount",
+			Balance:         uint64(i * 10),
+		})
+	}
+
+	err := TokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	keyOf := func(id uint64) PrimaryKey {
+		return NewKeyBuilder([]byte{}).AddUint64Field(id).Bytes()
+	}
+
+	keys, err := TokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Offset(1).
+		Limit(2).
+		Keys(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []PrimaryKey{keyOf(2), keyOf(3)}, keys)
+
+	keys, err = TokenBalanceTable.Query().
+		After(tokenBalances[3]).
+		Keys(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []PrimaryKey{keyOf(5)}, keys)
+
+	keys, err = TokenBalanceTable.Query().
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance > 20
+		}).
+		Order(func(tb *TokenBalance, tb2 *TokenBalance) bool {
+			return tb.Balance > tb2.Balance
+		}).
+		Limit(2).
+		Keys(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []PrimaryKey{keyOf(5), keyOf(4)}, keys)
+
+	tokenBalancesFromKeys, err := TokenBalanceTable.GetByKeys(context.Background(), keys)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalances[4], tokenBalances[3]}, tokenBalancesFromKeys)
+}
+
+func TestBond_Query_IsPlainIndexScan(t *testing.T) {
+	db, TokenBalanceTable, TokenBalanceAccountAddressIndex, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	plain := []Query[*TokenBalance]{
+		TokenBalanceTable.Query(),
+		TokenBalanceTable.Query().
+			With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+			Offset(1).
+			Limit(2),
+		TokenBalanceTable.Query().After(&TokenBalance{ID: 1}),
+		TokenBalanceTable.Query().MemoryLimit(1024).Cached("fingerprint").OrderPreserveIndex(),
+	}
+	for _, q := range plain {
+		assert.True(t, q.isPlainIndexScan())
+	}
+
+	notPlain := []Query[*TokenBalance]{
+		TokenBalanceTable.Query().Filter(func(tb *TokenBalance) bool { return true }),
+		TokenBalanceTable.Query().Order(func(tb, tb2 *TokenBalance) bool { return tb.ID < tb2.ID }),
+		TokenBalanceTable.Query().WithPrefix(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0x"}),
+		TokenBalanceTable.Query().MaxScanRows(10),
+		TokenBalanceTable.Query().Sample(1),
+		TokenBalanceTable.Query().AsOf(time.Now()),
+		TokenBalanceTable.Query().Deadline(time.Second),
+		TokenBalanceTable.Query().Window(func(tb *TokenBalance) uint64 { return tb.ID },
+			func(_ uint64, acc *TokenBalance, _ *TokenBalance) *TokenBalance { return acc }),
+		TokenBalanceTable.Query().WithBestIndex(&TokenBalance{AccountAddress: "0x"}, TokenBalanceAccountAddressIndex),
+	}
+	for _, q := range notPlain {
+		assert.False(t, q.isPlainIndexScan())
+	}
+}
+
+func TestBond_Query_MemoryLimit(t *testing.T) {
+	db, TokenBalanceTable, _, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i),
+			AccountID:       1,
+			ContractAddress: "0xtestContract",
+			AccountAddress:  "0xtestAccount",
+			Balance:         uint64(i * 10),
+		})
+	}
+
+	err := TokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	rowSize := uint64(utils.SizeOf(tokenBalances[0]))
+
+	var tokenBalancesFromQuery []*TokenBalance
+	err = TokenBalanceTable.Query().
+		Order(func(tb *TokenBalance, tb2 *TokenBalance) bool {
+			return tb.Balance > tb2.Balance
+		}).
+		Limit(2).
+		MemoryLimit(rowSize*5).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.ErrorIs(t, err, ErrQueryMemoryLimitExceeded)
+
+	err = TokenBalanceTable.Query().
+		Order(func(tb *TokenBalance, tb2 *TokenBalance) bool {
+			return tb.Balance > tb2.Balance
+		}).
+		Limit(2).
+		MemoryLimit(rowSize*10).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalances[9], tokenBalances[8]}, tokenBalancesFromQuery)
+
+	// the offset applied early does not hold the skipped rows
+	err = TokenBalanceTable.Query().
+		Offset(7).
+		MemoryLimit(rowSize*3).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[7:], tokenBalancesFromQuery)
+}
+
+func TestBond_Query_MaxScanRows_Deadline(t *testing.T) {
+	db, TokenBalanceTable, _, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:             uint64(i),
+			AccountAddress: "0xtestAccount",
+			Balance:        uint64(i * 10),
+		})
+	}
+
+	err := TokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	// the filtered out rows count towards the limit
+	var tokenBalancesFromQuery []*TokenBalance
+	err = TokenBalanceTable.Query().
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance > 80
+		}).
+		MaxScanRows(5).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.ErrorIs(t, err, ErrQueryScanLimitExceeded)
+
+	err = TokenBalanceTable.Query().
+		Limit(5).
+		MaxScanRows(5).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[:5], tokenBalancesFromQuery)
+
+	_, err = TokenBalanceTable.Query().
+		MaxScanRows(5).
+		Keys(context.Background())
+	require.ErrorIs(t, err, ErrQueryScanLimitExceeded)
+
+	err = TokenBalanceTable.Query().
+		Filter(func(tb *TokenBalance) bool {
+			time.Sleep(2 * time.Millisecond)
+			return true
+		}).
+		Deadline(5*time.Millisecond).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.ErrorIs(t, err, ErrQueryDeadlineExceeded)
+
+	err = TokenBalanceTable.Query().
+		Deadline(time.Minute).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances, tokenBalancesFromQuery)
+}
+
+func TestBond_Query_Window(t *testing.T) {
+	db, TokenBalanceTable, TokenBalanceAccountAddressIndex, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 25; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:             uint64(i),
+			AccountAddress: "0xtestAccount",
+			Balance:        uint64(i),
+		})
+	}
+
+	err := TokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	// the sums of the balances in the windows of 10 IDs
+	windowFunc := func(tb *TokenBalance) uint64 {
+		return tb.ID / 10
+	}
+	aggregateFunc := func(window uint64, acc *TokenBalance, tb *TokenBalance) *TokenBalance {
+		acc.ID = window * 10
+		acc.AccountAddress = tb.AccountAddress
+		acc.Balance += tb.Balance
+		return acc
+	}
+
+	var windows []*TokenBalance
+	err = TokenBalanceTable.Query().
+		Window(windowFunc, aggregateFunc).
+		Execute(context.Background(), &windows)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{
+		{ID: 0, AccountAddress: "0xtestAccount", Balance: 45},
+		{ID: 10, AccountAddress: "0xtestAccount", Balance: 145},
+		{ID: 20, AccountAddress: "0xtestAccount", Balance: 135},
+	}, windows)
+
+	err = TokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Filter(func(tb *TokenBalance) bool {
+			return tb.ID%2 == 0
+		}).
+		Window(windowFunc, aggregateFunc).
+		Offset(1).
+		Limit(1).
+		ExecuteReuse(context.Background(), &windows, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{
+		{ID: 10, AccountAddress: "0xtestAccount", Balance: 70},
+	}, windows)
+
+	err = TokenBalanceTable.Query().
+		Window(windowFunc, aggregateFunc).
+		Order(func(tb, tb2 *TokenBalance) bool {
+			return tb.ID < tb2.ID
+		}).
+		Execute(context.Background(), &windows)
+	require.Error(t, err)
+}
+
+func TestBond_Query_WithPrefix(t *testing.T) {
+	db, TokenBalanceTable, TokenBalanceAccountAddressIndex, TokenBalanceAccountAndContractAddressIndex := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	TokenBalanceEscapedAccountAndContractAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   TokenBalanceAccountAndContractAddressIndex.IndexID + 1,
+		IndexName: "escaped_account_and_contract_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.
+				AddEscapedStringField(tb.AccountAddress).
+				AddEscapedStringField(tb.ContractAddress).
+				Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := TokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceEscapedAccountAndContractAddressIndex})
+	require.NoError(t, err)
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xab", ContractAddress: "0xc1", Balance: 1},
+		{ID: 2, AccountAddress: "0xabcd", ContractAddress: "0xc1", Balance: 2},
+		{ID: 3, AccountAddress: "0xabc", ContractAddress: "0xc2", Balance: 3},
+		{ID: 4, AccountAddress: "0xac", ContractAddress: "0xc1", Balance: 4},
+		{ID: 5, AccountAddress: "0xa", ContractAddress: "0xc1", Balance: 5},
+		{ID: 6, AccountAddress: "0xb", ContractAddress: "0xc1", Balance: 6},
+	}
+
+	err = TokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	ids := func(tbs []*TokenBalance) []uint64 {
+		var ids []uint64
+		for _, tb := range tbs {
+			ids = append(ids, tb.ID)
+		}
+		return ids
+	}
+
+	var result []*TokenBalance
+	err = TokenBalanceTable.Query().
+		WithPrefix(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xab"}).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 3, 2}, ids(result))
+
+	// the unset trailing field is not the part of the prefix
+	err = TokenBalanceTable.Query().
+		WithPrefix(TokenBalanceAccountAndContractAddressIndex, &TokenBalance{AccountAddress: "0xab"}).
+		Filter(func(tb *TokenBalance) bool {
+			return tb.ContractAddress == "0xc1"
+		}).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, ids(result))
+
+	err = TokenBalanceTable.Query().
+		WithPrefix(TokenBalanceEscapedAccountAndContractAddressIndex, &TokenBalance{AccountAddress: "0xab"}).
+		Limit(2).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 3}, ids(result))
+
+	// the set trailing field makes the first field exact
+	err = TokenBalanceTable.Query().
+		WithPrefix(TokenBalanceEscapedAccountAndContractAddressIndex, &TokenBalance{AccountAddress: "0xabc", ContractAddress: "0xc"}).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3}, ids(result))
+
+	keys, err := TokenBalanceTable.Query().
+		WithPrefix(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xa"}).
+		Keys(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, keys, 5)
+}
+
+func TestBond_Query_WithSubtree(t *testing.T) {
+	db, TokenBalanceTable, _, lastIndex := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	// the contract address is the path of the contract in the registry
+	TokenBalanceAccountAndContractPathIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   lastIndex.IndexID + 1,
+		IndexName: "account_address_contract_path_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.
+				AddEscapedStringField(tb.AccountAddress).
+				AddPathField(tb.ContractAddress).
+				Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := TokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAndContractPathIndex})
+	require.NoError(t, err)
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xa", ContractAddress: "erc20", Balance: 1},
+		{ID: 2, AccountAddress: "0xa", ContractAddress: "erc20/stable", Balance: 2},
+		{ID: 3, AccountAddress: "0xa", ContractAddress: "erc20/stable/usdc", Balance: 3},
+		{ID: 4, AccountAddress: "0xa", ContractAddress: "erc20-wrapped", Balance: 4},
+		{ID: 5, AccountAddress: "0xa", ContractAddress: "erc721", Balance: 5},
+		{ID: 6, AccountAddress: "0xb", ContractAddress: "erc20/stable", Balance: 6},
+	}
+
+	err = TokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	ids := func(tbs []*TokenBalance) []uint64 {
+		var ids []uint64
+		for _, tb := range tbs {
+			ids = append(ids, tb.ID)
+		}
+		return ids
+	}
+
+	var result []*TokenBalance
+	err = TokenBalanceTable.Query().
+		WithSubtree(TokenBalanceAccountAndContractPathIndex, &TokenBalance{AccountAddress: "0xa", ContractAddress: "erc20"}).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint64{1, 2, 3}, ids(result))
+
+	err = TokenBalanceTable.Query().
+		WithSubtree(TokenBalanceAccountAndContractPathIndex, &TokenBalance{AccountAddress: "0xa", ContractAddress: "erc20/stable"}).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint64{2, 3}, ids(result))
+
+	// the empty path is not the part of the prefix, as with WithPrefix
+	err = TokenBalanceTable.Query().
+		WithSubtree(TokenBalanceAccountAndContractPathIndex, &TokenBalance{AccountAddress: "0xb"}).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint64{6}, ids(result))
+}
+
+func TestBond_Query_NotIn(t *testing.T) {
+	db, TokenBalanceTable, TokenBalanceAccountAddressIndex, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	BlockedTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(2),
+		TableName: "blocked",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	BlockedAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := BlockedTable.AddIndex([]*Index[*TokenBalance]{BlockedAccountAddressIndex})
+	require.NoError(t, err)
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xa1", ContractAddress: "0xc1", Balance: 1},
+		{ID: 2, AccountAddress: "0xa2", ContractAddress: "0xc1", Balance: 2},
+		{ID: 3, AccountAddress: "0xa3", ContractAddress: "0xc1", Balance: 3},
+		{ID: 4, AccountAddress: "0xa1", ContractAddress: "0xc2", Balance: 4},
+		{ID: 5, AccountAddress: "0xa4", ContractAddress: "0xc2", Balance: 5},
+	}
+
+	err = TokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	err = BlockedTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 2, AccountAddress: "0xa3"},
+		{ID: 5, AccountAddress: "0xa1"},
+	})
+	require.NoError(t, err)
+
+	ids := func(tbs []*TokenBalance) []uint64 {
+		var ids []uint64
+		for _, tb := range tbs {
+			ids = append(ids, tb.ID)
+		}
+		return ids
+	}
+
+	byID := func(builder KeyBuilder, tb *TokenBalance) []byte {
+		return builder.AddUint64Field(tb.ID).Bytes()
+	}
+
+	byAccountAddress := func(builder KeyBuilder, tb *TokenBalance) []byte {
+		return builder.AddStringField(tb.AccountAddress).Bytes()
+	}
+
+	var result []*TokenBalance
+	err = TokenBalanceTable.Query().
+		NotIn(JoinTable(BlockedTable), byID).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 3, 4}, ids(result))
+
+	err = TokenBalanceTable.Query().
+		NotIn(JoinIndex(BlockedTable, BlockedAccountAddressIndex), byAccountAddress).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2, 5}, ids(result))
+
+	err = TokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xa2"}).
+		NotIn(JoinIndex(BlockedTable, BlockedAccountAddressIndex), byAccountAddress).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{2}, ids(result))
+
+	err = TokenBalanceTable.Query().
+		NotIn(JoinIndex(BlockedTable, BlockedAccountAddressIndex), byAccountAddress).
+		NotIn(JoinTable(BlockedTable), byID).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+
+	err = TokenBalanceTable.Query().
+		NotIn(JoinTable(BlockedTable), byID).
+		Offset(1).
+		Limit(1).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3}, ids(result))
+
+	keys, err := TokenBalanceTable.Query().
+		NotIn(JoinTable(BlockedTable), byID).
+		Keys(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, keys, 3)
+}
+
+func TestBond_Join(t *testing.T) {
+	db, TokenBalanceTable, TokenBalanceAccountAddressIndex, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	AccountTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(2),
+		TableName: "account",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+	})
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xa1", ContractAddress: "0xc1", Balance: 1},
+		{ID: 2, AccountAddress: "0xa2", ContractAddress: "0xc1", Balance: 2},
+		{ID: 3, AccountAddress: "0xa3", ContractAddress: "0xc1", Balance: 3},
+		{ID: 4, AccountAddress: "0xa1", ContractAddress: "0xc2", Balance: 4},
+	}
+
+	err := TokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	err = AccountTable.Insert(context.Background(), []*TokenBalance{
+		{AccountAddress: "0xa1", AccountID: 10},
+		{AccountAddress: "0xa3", AccountID: 30},
+	})
+	require.NoError(t, err)
+
+	byAccountAddress := func(builder KeyBuilder, tb *TokenBalance) []byte {
+		return builder.AddStringField(tb.AccountAddress).Bytes()
+	}
+
+	var pairs []JoinPair[*TokenBalance, *TokenBalance]
+	err = Join(context.Background(), TokenBalanceTable.Query(), AccountTable, byAccountAddress, &pairs)
+	require.NoError(t, err)
+	require.Len(t, pairs, 3)
+	assert.Equal(t, uint64(1), pairs[0].Left.ID)
+	assert.Equal(t, uint32(10), pairs[0].Right.AccountID)
+	assert.Equal(t, uint64(3), pairs[1].Left.ID)
+	assert.Equal(t, uint32(30), pairs[1].Right.AccountID)
+	assert.Equal(t, uint64(4), pairs[2].Left.ID)
+	assert.Equal(t, uint32(10), pairs[2].Right.AccountID)
+
+	type accountBalance struct {
+		AccountID uint32
+		Balance   uint64
+	}
+
+	var projected []accountBalance
+	err = JoinProject(context.Background(),
+		TokenBalanceTable.Query().With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xa1"}),
+		AccountTable, byAccountAddress,
+		func(tb *TokenBalance, account *TokenBalance) accountBalance {
+			return accountBalance{AccountID: account.AccountID, Balance: tb.Balance}
+		}, &projected)
+	require.NoError(t, err)
+	assert.Equal(t, []accountBalance{{AccountID: 10, Balance: 1}, {AccountID: 10, Balance: 4}}, projected)
+}
+
+func TestBond_Query_Sample(t *testing.T) {
+	db, TokenBalanceTable, _, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 100; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i),
+			AccountAddress:  "0xtestAccount",
+			ContractAddress: "0xtestContract",
+			Balance:         uint64(i),
+		})
+	}
+
+	err := TokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	var result []*TokenBalance
+	err = TokenBalanceTable.Query().
+		Sample(10).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	require.Len(t, result, 10)
+
+	seen := map[uint64]bool{}
+	for _, tb := range result {
+		assert.False(t, seen[tb.ID])
+		seen[tb.ID] = true
+	}
+
+	err = TokenBalanceTable.Query().
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance%2 == 0
+		}).
+		Sample(20).
+		Order(func(tb *TokenBalance, tb2 *TokenBalance) bool {
+			return tb.ID < tb2.ID
+		}).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	require.Len(t, result, 20)
+	for i, tb := range result {
+		assert.Equal(t, uint64(0), tb.Balance%2)
+		if i > 0 {
+			assert.Less(t, result[i-1].ID, tb.ID)
+		}
+	}
+
+	// the sample larger than the table returns all rows
+	err = TokenBalanceTable.Query().
+		Sample(1000).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Len(t, result, 100)
+
+	// every row is sampled with the similar probability
+	hits := make([]int, 101)
+	for i := 0; i < 200; i++ {
+		err = TokenBalanceTable.Query().
+			Sample(10).
+			Execute(context.Background(), &result)
+		require.NoError(t, err)
+		for _, tb := range result {
+			hits[tb.ID]++
+		}
+	}
+	assert.Greater(t, hits[1]+hits[2]+hits[3]+hits[4]+hits[5], 20)
+	assert.Greater(t, hits[96]+hits[97]+hits[98]+hits[99]+hits[100], 20)
+
+	err = TokenBalanceTable.Query().
+		Sample(10).
+		Limit(5).
+		Execute(context.Background(), &result)
+	require.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/go-bond/bond/utils"
+)
+
+// TimeRange is the time window [From, To). The zero From or To leaves the
+// window open on that side.
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// WithTimeRange selects the index entries whose index keys start with the
+// time field, added with KeyBuilder.AddTimeField, within the window from
+// inclusive to exclusive. The zero from or to leaves the window open, e.g. all
+// the transfers of the last hour:
+//
+//	t.Query().
+//		WithTimeRange(TransferCreatedAtIndex, time.Now().Add(-time.Hour), time.Time{})
+//
+// The entries are ordered by the length of their index keys first, as with
+// WithPrefix, so the entries are in the time order if the other fields of the
+// index key are of the fixed size.
+func (q Query[R]) WithTimeRange(idx *Index[R], from time.Time, to time.Time) Query[R] {
+	q.index = idx
+	q.indexSelector = utils.MakeNew[R]()
+	q.indexPrefix = false
+	q.indexTimeRange = &TimeRange{From: from, To: to}
+	return q
+}
+
+// scanIndexTimeRangeForEach iterates over the index entries whose leading time
+// field is within the time range. The index keys are encoded after their
+// length, so the entries of every index key length are sought separately.
+func (t *_table[T]) scanIndexTimeRangeForEach(ctx context.Context, idx *Index[T], timeRange TimeRange, f func(keyBytes KeyBytes, t Lazy[T]) (bool, error), optBatch ...Batch) error {
+	if idx.IndexID == PrimaryIndexID {
+		return t.newError(idx, nil, fmt.Errorf("time range can not be used with primary index"))
+	}
+
+	if err := t.checkIndexRegistered(idx); err != nil {
+		return err
+	}
+
+	schema := keySchema(func(builder KeyBuilder) []byte {
+		return idx.IndexKeyFunction(builder, utils.MakeNew[T]())
+	})
+	if len(schema) == 0 || schema[0].Type != KeyFieldTypeTime {
+		return t.newError(idx, nil, fmt.Errorf("time range requires index key starting with time field"))
+	}
+
+	// the bounds are the encoded time fields the index keys are compared with
+	var lower, upper []byte
+	if !timeRange.From.IsZero() {
+		lower = appendInt64([]byte{schema[0].ID}, timeRange.From.UnixNano())
+	}
+	if !timeRange.To.IsZero() {
+		upper = appendInt64([]byte{schema[0].ID}, timeRange.To.UnixNano())
+	}
+
+	var batch Batch
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		batch = optBatch[0]
+	}
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{byte(t.storageID()), byte(idx.IndexID)},
+			UpperBound: []byte{byte(t.storageID()), byte(idx.IndexID + 1)},
+		},
+	}, batch)
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	seekKey := func(length int, bound []byte) []byte {
+		key := make([]byte, 6, 6+len(bound))
+		key[0], key[1] = byte(t.storageID()), byte(idx.IndexID)
+		binary.BigEndian.PutUint32(key[2:6], uint32(length))
+		return append(key, bound...)
+	}
+
+	var keyBuffer [DataKeyBufferSize]byte
+	getValue := func() (T, error) {
+		return t.get(KeyBytes(iter.Key()).ToDataKeyBytes(keyBuffer[:0]), batch)
+	}
+	getValueInto := func(record *T) error {
+		tr, err := getValue()
+		if err != nil {
+			return err
+		}
+		*record = tr
+		return nil
+	}
+
+	for valid := iter.First(); valid; {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		indexKey := KeyBytes(iter.Key()).IndexKey()
+		if lower != nil && bytes.Compare(indexKey, lower) < 0 {
+			// the entries of this length start before the window
+			valid = iter.SeekGE(seekKey(len(indexKey), lower))
+			continue
+		} else if upper != nil && bytes.Compare(indexKey, upper) >= 0 {
+			// the rest of the entries of this length are after the window
+			valid = iter.SeekGE(seekKey(len(indexKey)+1, nil))
+			continue
+		}
+
+		cont, err := f(iter.Key(), t.decodeLazy(ctx, Lazy[T]{GetFunc: getValue, GetIntoFunc: getValueInto, indexValue: iter.Value()}))
+		if err != nil || !cont {
+			return err
+		}
+
+		valid = iter.Next()
+	}
+
+	return nil
+}
@@ -0,0 +1,131 @@
+package bond
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Query_WithTimeRange(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	// the ID is the unix time of the balance
+	createdAt := func(tb *TokenBalance) time.Time {
+		return time.Unix(int64(tb.ID), 0)
+	}
+
+	TokenBalanceCreatedAtIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "created_at_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddTimeField(createdAt(tb)).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	TokenBalanceCreatedAtAndAccountIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 2,
+		IndexName: "created_at_account_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddTimeField(createdAt(tb)).AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	TokenBalanceAccountIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 3,
+		IndexName: "account_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{
+		TokenBalanceCreatedAtIndex,
+		TokenBalanceCreatedAtAndAccountIndex,
+		TokenBalanceAccountIndex,
+	})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 10; i++ {
+		accountAddress := "0xa1"
+		if i%2 == 0 {
+			accountAddress = "0xa1a2"
+		}
+		tokenBalances = append(tokenBalances, &TokenBalance{ID: uint64(i * 100), AccountAddress: accountAddress, Balance: uint64(i)})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	ids := func(trs []*TokenBalance) []uint64 {
+		var result []uint64
+		for _, tr := range trs {
+			result = append(result, tr.ID)
+		}
+		return result
+	}
+
+	var tokenBalancesFromQuery []*TokenBalance
+	err = tokenBalanceTable.Query().
+		WithTimeRange(TokenBalanceCreatedAtIndex, time.Unix(300, 0), time.Unix(600, 0)).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{300, 400, 500}, ids(tokenBalancesFromQuery))
+
+	// open-ended ranges
+	err = tokenBalanceTable.Query().
+		WithTimeRange(TokenBalanceCreatedAtIndex, time.Unix(850, 0), time.Time{}).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{900, 1000}, ids(tokenBalancesFromQuery))
+
+	err = tokenBalanceTable.Query().
+		WithTimeRange(TokenBalanceCreatedAtIndex, time.Time{}, time.Unix(200, 1)).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{100, 200}, ids(tokenBalancesFromQuery))
+
+	// with filter and limit
+	err = tokenBalanceTable.Query().
+		WithTimeRange(TokenBalanceCreatedAtIndex, time.Unix(200, 0), time.Time{}).
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance%2 == 1
+		}).
+		Limit(2).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{300, 500}, ids(tokenBalancesFromQuery))
+
+	// the entries are ordered by the length of their index keys first
+	err = tokenBalanceTable.Query().
+		WithTimeRange(TokenBalanceCreatedAtAndAccountIndex, time.Unix(300, 0), time.Unix(700, 0)).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{300, 500, 400, 600}, ids(tokenBalancesFromQuery))
+
+	// the index key needs to start with the time field
+	err = tokenBalanceTable.Query().
+		WithTimeRange(TokenBalanceAccountIndex, time.Unix(300, 0), time.Unix(700, 0)).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.Error(t, err)
+
+	assert.Contains(t, tokenBalanceTable.Query().
+		WithTimeRange(TokenBalanceCreatedAtIndex, time.Unix(300, 0), time.Time{}).
+		Explain(), "time range scan created_at_idx")
+}
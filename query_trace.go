@@ -0,0 +1,60 @@
+package bond
+
+import (
+	"fmt"
+	"io"
+)
+
+// Trace writes the steps of the query execution to the writer, one per line:
+// the plan, every index key visited, every row fetched, the rows skipped and
+// the decisions of the filters, e.g. to find out why the query misses the
+// rows. The query is not traced unless the writer is set, the tracing slows
+// it down, so it's meant for debugging.
+//
+// Example:
+//
+//	var trace strings.Builder
+//	err := t.Query().
+//		With(AccountAddressIndex, &TokenBalance{AccountAddress: "0xab"}).
+//		Filter(func(tb *TokenBalance) bool {
+//			return tb.Balance > 25
+//		}).
+//		Trace(&trace).
+//		Execute(ctx, &trs)
+//	...
+//	fmt.Println(trace.String())
+//
+// The output looks like:
+//
+//	plan scan account_address_idx with filter
+//	visit table=0x01 index=0x01 key=01"0xab" order= pk=010000000000000001
+//	fetch table=0x01 index=0x00 key= order= pk=010000000000000001
+//	reject filter table=0x01 index=0x01 key=01"0xab" order= pk=010000000000000001
+//	result 0 rows
+func (q Query[R]) Trace(w io.Writer) Query[R] {
+	q.trace = w
+	return q
+}
+
+func (q Query[R]) tracef(format string, args ...any) {
+	if q.trace == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(q.trace, format+"\n", args...)
+}
+
+// traceKey traces the event of the index entry.
+func (q Query[R]) traceKey(event string, key KeyBytes) {
+	if q.trace == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(q.trace, "%s %s\n", event, FormatKey(key))
+}
+
+// traceRow traces the event of the row the index entry points to.
+func (q Query[R]) traceRow(event string, key KeyBytes) {
+	if q.trace == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(q.trace, "%s %s\n", event, FormatKey(key.ToDataKeyBytes()))
+}
@@ -0,0 +1,57 @@
+package bond
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Query_Trace(t *testing.T) {
+	db, tokenBalanceTable, tokenBalanceAccountAddressIndex, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount", Balance: 15},
+		{ID: 3, AccountID: 2, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount2", Balance: 7},
+	})
+	require.NoError(t, err)
+
+	var (
+		trace strings.Builder
+		trs   []*TokenBalance
+	)
+	err = tokenBalanceTable.Query().
+		With(tokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance > 10
+		}).
+		Trace(&trace).
+		Execute(context.Background(), &trs)
+	require.NoError(t, err)
+	require.Len(t, trs, 1)
+
+	lines := strings.Split(strings.TrimSpace(trace.String()), "\n")
+	require.Len(t, lines, 8)
+	assert.Equal(t, "plan scan account_address_idx with filter", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "visit table=0x01 index=0x01 "))
+	assert.True(t, strings.HasPrefix(lines[2], "fetch table=0x01 index=0x00 "))
+	assert.True(t, strings.HasPrefix(lines[3], "reject filter table=0x01 index=0x01 "))
+	assert.True(t, strings.HasPrefix(lines[4], "visit "))
+	assert.True(t, strings.HasPrefix(lines[5], "fetch "))
+	assert.True(t, strings.HasPrefix(lines[6], "match "))
+	assert.Equal(t, "result 1 rows", lines[7])
+
+	// the keys only query traces the visited index entries
+	trace.Reset()
+	keys, err := tokenBalanceTable.Query().
+		With(tokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Trace(&trace).
+		Keys(context.Background())
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, 2, strings.Count(trace.String(), "visit "))
+}
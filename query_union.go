@@ -0,0 +1,113 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// UnionQuery is the union of the queries on the same table and index. It's
+// built with Union.
+type UnionQuery[R any] struct {
+	queries []Query[R]
+	limit   uint64
+}
+
+// Union merges the rows of the queries in the order of their index, the rows
+// returned by more than one query are returned once. It serves the access
+// patterns that select several distinct index prefixes, such as
+// "status IN (x, y, z)", with one ordered result.
+//
+// The queries must be on the same table and index and must not be ordered
+// with Order, as their rows would not be in the index order. The offset and the
+// limit of the queries apply to each query before the rows are merged.
+//
+// Example:
+//
+//	var orders []*Order
+//	err := bond.Union(
+//		OrderTable.Query().With(OrderStatusIndex, &Order{Status: StatusNew}),
+//		OrderTable.Query().With(OrderStatusIndex, &Order{Status: StatusPaid}),
+//	).Limit(100).Execute(ctx, &orders)
+func Union[R any](queries ...Query[R]) UnionQuery[R] {
+	return UnionQuery[R]{queries: queries}
+}
+
+// Limit sets the maximal number of the merged rows.
+func (u UnionQuery[R]) Limit(limit uint64) UnionQuery[R] {
+	u.limit = limit
+	return u
+}
+
+// Execute executes the queries and merges their rows into r.
+func (u UnionQuery[R]) Execute(ctx context.Context, r *[]R, optBatch ...Batch) error {
+	if len(u.queries) == 0 {
+		*r = (*r)[:0]
+		return nil
+	}
+
+	first := u.queries[0]
+	for _, q := range u.queries {
+		if q.table != first.table || q.index.IndexID != first.index.IndexID {
+			return fmt.Errorf("union: queries need to be on the same table and index")
+		}
+		if q.orderLessFunc != nil || len(q.indexCandidates) > 0 || q.windowFunc != nil {
+			return fmt.Errorf("union: queries can not be ordered, windowed or choose the index")
+		}
+	}
+
+	var (
+		results = make([][]R, len(u.queries))
+		keys    = make([][][]byte, len(u.queries))
+	)
+	for i, q := range u.queries {
+		err := q.Execute(ctx, &results[i], optBatch...)
+		if err != nil {
+			return err
+		}
+
+		keys[i] = make([][]byte, 0, len(results[i]))
+		for _, record := range results[i] {
+			keys[i] = append(keys[i], q.table.indexKey(record, q.index, make([]byte, 0, DataKeyBufferSize)))
+		}
+	}
+
+	var (
+		merged []R
+		pos    = make([]int, len(u.queries))
+		seen   = make(map[string]struct{})
+	)
+	for !u.shouldLimit() || uint64(len(merged)) < u.limit {
+		next := -1
+		for i := range u.queries {
+			if pos[i] == len(results[i]) {
+				continue
+			}
+			if next == -1 || bytes.Compare(keys[i][pos[i]], keys[next][pos[next]]) < 0 {
+				next = i
+			}
+		}
+		if next == -1 {
+			break
+		}
+
+		key := keys[next][pos[next]]
+		record := results[next][pos[next]]
+		pos[next]++
+
+		primaryKey := string(KeyBytes(key).PrimaryKey())
+		if _, ok := seen[primaryKey]; ok {
+			continue
+		}
+		seen[primaryKey] = struct{}{}
+
+		merged = append(merged, record)
+	}
+
+	*r = merged
+	return nil
+}
+
+func (u UnionQuery[R]) shouldLimit() bool {
+	return u.limit != 0
+}
@@ -0,0 +1,50 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Union(t *testing.T) {
+	db, tokenBalanceTable, accountAddressIndex, accountAndContractAddressIndex := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount3", ContractAddress: "0xtestContract", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount1", ContractAddress: "0xtestContract", Balance: 15},
+		{ID: 3, AccountAddress: "0xtestAccount2", ContractAddress: "0xtestContract", Balance: 7},
+		{ID: 4, AccountAddress: "0xtestAccount1", ContractAddress: "0xtestContract", Balance: 3},
+		{ID: 5, AccountAddress: "0xtestAccount3", ContractAddress: "0xtestContract", Balance: 20},
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	var tokenBalancesRead []*TokenBalance
+	err = Union(
+		tokenBalanceTable.Query().With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount3"}),
+		tokenBalanceTable.Query().With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount1"}),
+		tokenBalanceTable.Query().With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount1"}).
+			Filter(func(tb *TokenBalance) bool {
+				return tb.Balance > 10
+			}),
+	).Execute(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalances[1], tokenBalances[3], tokenBalances[0], tokenBalances[4]}, tokenBalancesRead)
+
+	err = Union(
+		tokenBalanceTable.Query().With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount3"}),
+		tokenBalanceTable.Query().With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount2"}),
+	).Limit(2).Execute(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalances[2], tokenBalances[0]}, tokenBalancesRead)
+
+	err = Union(
+		tokenBalanceTable.Query().With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount3"}),
+		tokenBalanceTable.Query().With(accountAndContractAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount2"}),
+	).Execute(context.Background(), &tokenBalancesRead)
+	require.Error(t, err)
+}
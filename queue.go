@@ -0,0 +1,285 @@
+package bond
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultQueueVisibilityTimeout is the time the dequeued message is hidden from
+// the other consumers of the group until it is acknowledged.
+const DefaultQueueVisibilityTimeout = 30 * time.Second
+
+type QueueOptions struct {
+	DB DB
+
+	MessageTableID   TableID
+	MessageTableName string
+
+	// ConsumerTableID is the table that keeps the offsets and the in-flight
+	// messages of the consumer groups.
+	ConsumerTableID   TableID
+	ConsumerTableName string
+
+	VisibilityTimeout time.Duration
+}
+
+// QueueMessage is the message of the queue. The offsets of the messages
+// increase in the order the messages are enqueued.
+type QueueMessage[T any] struct {
+	Offset  uint64
+	Payload T
+}
+
+// _queueConsumerEntry is the consumer group cursor if the Offset is 0, or the
+// in-flight message of the group otherwise. The cursor of the empty group is
+// the next offset of the queue.
+type _queueConsumerEntry struct {
+	Group    string
+	Offset   uint64
+	Next     uint64
+	Deadline int64
+}
+
+// Queue is the durable queue of the messages consumed by the consumer groups.
+// Every group receives every message. The dequeued message is delivered again
+// if it is not acknowledged within the visibility timeout.
+type Queue[T any] struct {
+	db DB
+
+	messages  Table[*QueueMessage[T]]
+	consumers Table[*_queueConsumerEntry]
+
+	visibilityTimeout time.Duration
+	nextOffset        uint64
+
+	mutex sync.Mutex
+}
+
+func NewQueue[T any](opt QueueOptions) (*Queue[T], error) {
+	messages, err := RegisterTable[*QueueMessage[T]](TableOptions[*QueueMessage[T]]{
+		DB:        opt.DB,
+		TableID:   opt.MessageTableID,
+		TableName: opt.MessageTableName,
+		TablePrimaryKeyFunc: func(builder KeyBuilder, m *QueueMessage[T]) []byte {
+			return builder.AddUint64Field(m.Offset).Bytes()
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	consumers, err := RegisterTable[*_queueConsumerEntry](TableOptions[*_queueConsumerEntry]{
+		DB:        opt.DB,
+		TableID:   opt.ConsumerTableID,
+		TableName: opt.ConsumerTableName,
+		TablePrimaryKeyFunc: func(builder KeyBuilder, e *_queueConsumerEntry) []byte {
+			return builder.AddEscapedStringField(e.Group).AddUint64Field(e.Offset).Bytes()
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Queue[T]{
+		db:                opt.DB,
+		messages:          messages,
+		consumers:         consumers,
+		visibilityTimeout: opt.VisibilityTimeout,
+	}
+	if q.visibilityTimeout == 0 {
+		q.visibilityTimeout = DefaultQueueVisibilityTimeout
+	}
+
+	head, err := q.cursor("", nil)
+	if err != nil {
+		return nil, err
+	}
+	q.nextOffset = head.Next
+
+	return q, nil
+}
+
+// Enqueue adds the messages to the queue and returns their offsets.
+func (q *Queue[T]) Enqueue(ctx context.Context, payloads []T) ([]uint64, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	offsets := make([]uint64, 0, len(payloads))
+	messages := make([]*QueueMessage[T], 0, len(payloads))
+	for i, payload := range payloads {
+		offset := q.nextOffset + uint64(i)
+		offsets = append(offsets, offset)
+		messages = append(messages, &QueueMessage[T]{Offset: offset, Payload: payload})
+	}
+
+	batch := q.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	err := q.messages.Insert(ctx, messages, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	head := &_queueConsumerEntry{Next: q.nextOffset + uint64(len(payloads))}
+	err = q.consumers.Upsert(ctx, []*_queueConsumerEntry{head}, TableUpsertOnConflictReplace[*_queueConsumerEntry], batch)
+	if err != nil {
+		return nil, err
+	}
+
+	err = batch.Commit(Sync)
+	if err != nil {
+		return nil, err
+	}
+
+	q.nextOffset = head.Next
+	return offsets, nil
+}
+
+// Dequeue returns the next message of the consumer group. The message whose
+// visibility timeout expired without the acknowledgement is returned before
+// the new messages. It returns ErrQueueEmpty if there is no message to return.
+func (q *Queue[T]) Dequeue(ctx context.Context, group string) (*QueueMessage[T], error) {
+	if group == "" {
+		return nil, fmt.Errorf("consumer group name can not be empty")
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	batch := q.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	now := clockOf(q.db).Now()
+	deadline := now.Add(q.visibilityTimeout).UnixNano()
+
+	// redeliver the message with expired visibility timeout
+	var expired *_queueConsumerEntry
+	err := q.consumers.ScanIndexForEach(ctx, q.consumers.PrimaryIndex(), &_queueConsumerEntry{Group: group, Offset: 1},
+		func(_ KeyBytes, lazy Lazy[*_queueConsumerEntry]) (bool, error) {
+			e, err := lazy.Get()
+			if err != nil || e.Group != group {
+				return false, err
+			}
+
+			if e.Deadline <= now.UnixNano() {
+				expired = e
+				return false, nil
+			}
+			return true, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var message *QueueMessage[T]
+	if expired != nil {
+		message, err = q.messages.Get(&QueueMessage[T]{Offset: expired.Offset})
+		if errors.Is(err, ErrNotFound) {
+			// the message was truncated
+			err = q.consumers.Delete(ctx, []*_queueConsumerEntry{expired}, batch)
+			if err != nil {
+				return nil, err
+			}
+			message = nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if message == nil {
+		cursor, err := q.cursor(group, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		// the next message that is not truncated
+		var messages []*QueueMessage[T]
+		err = q.messages.Query().
+			With(q.messages.PrimaryIndex(), &QueueMessage[T]{Offset: cursor.Next}).
+			Limit(1).
+			Execute(ctx, &messages)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(messages) == 0 {
+			return nil, ErrQueueEmpty
+		}
+		message = messages[0]
+
+		cursor.Next = message.Offset + 1
+		err = q.consumers.Upsert(ctx, []*_queueConsumerEntry{cursor}, TableUpsertOnConflictReplace[*_queueConsumerEntry], batch)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	lease := &_queueConsumerEntry{Group: group, Offset: message.Offset, Deadline: deadline}
+	err = q.consumers.Upsert(ctx, []*_queueConsumerEntry{lease}, TableUpsertOnConflictReplace[*_queueConsumerEntry], batch)
+	if err != nil {
+		return nil, err
+	}
+
+	err = batch.Commit(Sync)
+	if err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
+
+// Ack acknowledges the message dequeued by the consumer group, so it is not
+// delivered to the group again.
+func (q *Queue[T]) Ack(ctx context.Context, group string, offset uint64) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	lease := &_queueConsumerEntry{Group: group, Offset: offset}
+	if group == "" || offset == 0 || !q.consumers.Exist(lease) {
+		return fmt.Errorf("message %d is not in flight in group %s: %w", offset, group, ErrNotFound)
+	}
+
+	return q.consumers.Delete(ctx, []*_queueConsumerEntry{lease})
+}
+
+// Truncate removes the messages with the offsets lower than given offset.
+func (q *Queue[T]) Truncate(ctx context.Context, offset uint64) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	start := KeyEncode(Key{
+		TableID:    storageIDOf(q.db, q.messages.ID()),
+		IndexID:    PrimaryIndexID,
+		IndexKey:   []byte{},
+		IndexOrder: []byte{},
+		PrimaryKey: NewKeyBuilder([]byte{}).AddUint64Field(0).Bytes(),
+	})
+	end := KeyEncode(Key{
+		TableID:    storageIDOf(q.db, q.messages.ID()),
+		IndexID:    PrimaryIndexID,
+		IndexKey:   []byte{},
+		IndexOrder: []byte{},
+		PrimaryKey: NewKeyBuilder([]byte{}).AddUint64Field(offset).Bytes(),
+	})
+
+	return q.db.DeleteRange(start, end, Sync)
+}
+
+// cursor returns the cursor of the group. The cursor of the group that did not
+// dequeue any message yet starts at the first offset.
+func (q *Queue[T]) cursor(group string, batch Batch) (*_queueConsumerEntry, error) {
+	cursor, err := q.consumers.Get(&_queueConsumerEntry{Group: group}, batch)
+	if errors.Is(err, ErrNotFound) {
+		return &_queueConsumerEntry{Group: group, Next: 1}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
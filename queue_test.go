@@ -0,0 +1,92 @@
+package bond
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Queue(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		JobQueueMessageTableID  = TableID(1)
+		JobQueueConsumerTableID = TableID(2)
+	)
+
+	queueOptions := QueueOptions{
+		DB:                db,
+		MessageTableID:    JobQueueMessageTableID,
+		MessageTableName:  "job_queue_message",
+		ConsumerTableID:   JobQueueConsumerTableID,
+		ConsumerTableName: "job_queue_consumer",
+		VisibilityTimeout: 50 * time.Millisecond,
+	}
+
+	queue, err := NewQueue[*TokenBalance](queueOptions)
+	require.NoError(t, err)
+
+	_, err = queue.Dequeue(context.Background(), "workers")
+	require.ErrorIs(t, err, ErrQueueEmpty)
+
+	offsets, err := queue.Enqueue(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 7},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 2}, offsets)
+
+	message, err := queue.Dequeue(context.Background(), "workers")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), message.Offset)
+	assert.Equal(t, uint64(1), message.Payload.ID)
+
+	message, err = queue.Dequeue(context.Background(), "workers")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), message.Offset)
+
+	err = queue.Ack(context.Background(), "workers", 2)
+	require.NoError(t, err)
+
+	// every group receives every message
+	message, err = queue.Dequeue(context.Background(), "auditors")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), message.Offset)
+
+	_, err = queue.Dequeue(context.Background(), "workers")
+	require.ErrorIs(t, err, ErrQueueEmpty)
+
+	// the message not acknowledged in time is delivered again
+	time.Sleep(60 * time.Millisecond)
+
+	message, err = queue.Dequeue(context.Background(), "workers")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), message.Offset)
+
+	err = queue.Ack(context.Background(), "workers", 1)
+	require.NoError(t, err)
+
+	err = queue.Ack(context.Background(), "workers", 1)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// the offsets continue after the messages are truncated
+	err = queue.Truncate(context.Background(), 3)
+	require.NoError(t, err)
+
+	queue, err = NewQueue[*TokenBalance](queueOptions)
+	require.NoError(t, err)
+
+	offsets, err = queue.Enqueue(context.Background(), []*TokenBalance{
+		{ID: 3, AccountAddress: "0xtestAccount", Balance: 9},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3}, offsets)
+
+	message, err = queue.Dequeue(context.Background(), "workers")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), message.Offset)
+}
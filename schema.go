@@ -0,0 +1,257 @@
+package bond
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// SchemaFingerprinter describes the schema of the registered tables.
+type SchemaFingerprinter interface {
+	SchemaFingerprint() Schema
+}
+
+// Schema is the schema of the tables registered by the running binary. The
+// Fingerprint is the hash of the tables, so the equal schemas are compared
+// by their fingerprints. The schema is JSON encoded, so it can be stored by
+// the deployment pipeline and compared with DiffSchemas.
+type Schema struct {
+	Fingerprint string        `json:"fingerprint"`
+	Tables      []SchemaTable `json:"tables"`
+}
+
+// SchemaTable is the table of the schema. The KeyFingerprint describes the
+// fields of the primary key.
+type SchemaTable struct {
+	ID             TableID       `json:"id"`
+	Name           string        `json:"name"`
+	KeyFingerprint string        `json:"keyFingerprint"`
+	Serializer     string        `json:"serializer"`
+	Indexes        []SchemaIndex `json:"indexes"`
+}
+
+// SchemaIndex is the index of the schema table. The KeyFingerprint describes
+// the fields of the index key and the index order.
+type SchemaIndex struct {
+	ID             IndexID `json:"id"`
+	Name           string  `json:"name"`
+	KeyFingerprint string  `json:"keyFingerprint"`
+}
+
+// SchemaChangeKind is the kind of the change between two schemas.
+type SchemaChangeKind string
+
+const (
+	SchemaChangeTableAdded      SchemaChangeKind = "table_added"
+	SchemaChangeTableRemoved    SchemaChangeKind = "table_removed"
+	SchemaChangeTableRenamed    SchemaChangeKind = "table_renamed"
+	SchemaChangeTableKey        SchemaChangeKind = "table_key"
+	SchemaChangeTableSerializer SchemaChangeKind = "table_serializer"
+	SchemaChangeIndexAdded      SchemaChangeKind = "index_added"
+	SchemaChangeIndexRemoved    SchemaChangeKind = "index_removed"
+	SchemaChangeIndexRenamed    SchemaChangeKind = "index_renamed"
+	SchemaChangeIndexKey        SchemaChangeKind = "index_key"
+)
+
+// SchemaChange is the change of the table or the index between two schemas.
+// Old and New are the changed values, e.g. the key fingerprints.
+type SchemaChange struct {
+	Kind      SchemaChangeKind `json:"kind"`
+	TableID   TableID          `json:"tableId"`
+	TableName string           `json:"tableName"`
+	IndexID   IndexID          `json:"indexId,omitempty"`
+	IndexName string           `json:"indexName,omitempty"`
+	Old       string           `json:"old,omitempty"`
+	New       string           `json:"new,omitempty"`
+}
+
+// NeedsBackfill returns true if the change adds the index entries to the
+// existing table, which needs the index to be built with AddIndex reindexing.
+func (c SchemaChange) NeedsBackfill() bool {
+	return c.Kind == SchemaChangeIndexAdded || c.Kind == SchemaChangeIndexKey
+}
+
+func (c SchemaChange) String() string {
+	name := fmt.Sprintf("table 0x%02x %s", c.TableID, c.TableName)
+	if c.IndexName != "" {
+		name += fmt.Sprintf(" index 0x%02x %s", c.IndexID, c.IndexName)
+	}
+
+	msg := fmt.Sprintf("%s: %s", name, c.Kind)
+	if c.Old != "" || c.New != "" {
+		msg += fmt.Sprintf(" %q -> %q", c.Old, c.New)
+	}
+	return msg
+}
+
+// SchemaFingerprint returns the schema of the tables and indexes registered
+// on the database.
+//
+// Example:
+//
+//	// in the deployment pipeline, with the schema of the running release
+//	changes := bond.DiffSchemas(released, db.SchemaFingerprint())
+//	for _, change := range changes {
+//		if change.NeedsBackfill() {
+//			log.Fatalf("index needs backfill: %s", change)
+//		}
+//	}
+func (db *_db) SchemaFingerprint() Schema {
+	db.catalog.mutex.Lock()
+	defer db.catalog.mutex.Unlock()
+
+	schema := Schema{Tables: []SchemaTable{}}
+	for id, name := range db.catalog.tables {
+		table := SchemaTable{
+			ID:         id,
+			Name:       name,
+			Serializer: db.catalog.serializers[id],
+			Indexes:    []SchemaIndex{},
+		}
+
+		persisted, ok := db.catalog.persisted[id]
+		if ok {
+			table.KeyFingerprint = persisted.Fingerprint
+		}
+
+		for indexID, indexName := range db.catalog.indexes[id] {
+			index := SchemaIndex{ID: indexID, Name: indexName}
+			if ok {
+				for _, persistedIndex := range persisted.Indexes {
+					if persistedIndex.ID == indexID {
+						index.KeyFingerprint = persistedIndex.Fingerprint
+					}
+				}
+			}
+			table.Indexes = append(table.Indexes, index)
+		}
+
+		sort.Slice(table.Indexes, func(i, j int) bool {
+			return table.Indexes[i].ID < table.Indexes[j].ID
+		})
+		schema.Tables = append(schema.Tables, table)
+	}
+
+	sort.Slice(schema.Tables, func(i, j int) bool {
+		return schema.Tables[i].ID < schema.Tables[j].ID
+	})
+
+	data, _ := json.Marshal(schema.Tables)
+	hash := sha256.Sum256(data)
+	schema.Fingerprint = hex.EncodeToString(hash[:])
+
+	return schema
+}
+
+// DiffSchemas returns the changes of the tables and indexes from the old to
+// the new schema, ordered by the table and the index IDs. The indexes added
+// to or changed in the tables of the old schema are reported with
+// NeedsBackfill, the indexes of the added tables are not reported.
+func DiffSchemas(old, new Schema) []SchemaChange {
+	if old.Fingerprint != "" && old.Fingerprint == new.Fingerprint {
+		return nil
+	}
+
+	oldTables := make(map[TableID]SchemaTable, len(old.Tables))
+	for _, table := range old.Tables {
+		oldTables[table.ID] = table
+	}
+
+	newTables := make(map[TableID]SchemaTable, len(new.Tables))
+	for _, table := range new.Tables {
+		newTables[table.ID] = table
+	}
+
+	var changes []SchemaChange
+	for _, table := range new.Tables {
+		oldTable, ok := oldTables[table.ID]
+		if !ok {
+			changes = append(changes, SchemaChange{Kind: SchemaChangeTableAdded, TableID: table.ID, TableName: table.Name})
+			continue
+		}
+		changes = append(changes, diffSchemaTables(oldTable, table)...)
+	}
+
+	for _, table := range old.Tables {
+		if _, ok := newTables[table.ID]; !ok {
+			changes = append(changes, SchemaChange{Kind: SchemaChangeTableRemoved, TableID: table.ID, TableName: table.Name})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].TableID != changes[j].TableID {
+			return changes[i].TableID < changes[j].TableID
+		}
+		return changes[i].IndexID < changes[j].IndexID
+	})
+	return changes
+}
+
+// diffSchemaTables returns the changes of the table with the same ID.
+func diffSchemaTables(old, new SchemaTable) []SchemaChange {
+	var changes []SchemaChange
+	change := func(kind SchemaChangeKind, oldValue, newValue string) {
+		changes = append(changes, SchemaChange{Kind: kind, TableID: new.ID, TableName: new.Name, Old: oldValue, New: newValue})
+	}
+
+	if old.Name != new.Name {
+		change(SchemaChangeTableRenamed, old.Name, new.Name)
+	}
+	if fingerprintsDiffer(old.KeyFingerprint, new.KeyFingerprint) {
+		change(SchemaChangeTableKey, old.KeyFingerprint, new.KeyFingerprint)
+	}
+	if old.Serializer != new.Serializer {
+		change(SchemaChangeTableSerializer, old.Serializer, new.Serializer)
+	}
+
+	oldIndexes := make(map[IndexID]SchemaIndex, len(old.Indexes))
+	for _, index := range old.Indexes {
+		oldIndexes[index.ID] = index
+	}
+
+	newIndexes := make(map[IndexID]SchemaIndex, len(new.Indexes))
+	for _, index := range new.Indexes {
+		newIndexes[index.ID] = index
+	}
+
+	indexChange := func(kind SchemaChangeKind, index SchemaIndex, oldValue, newValue string) {
+		changes = append(changes, SchemaChange{
+			Kind: kind, TableID: new.ID, TableName: new.Name,
+			IndexID: index.ID, IndexName: index.Name, Old: oldValue, New: newValue,
+		})
+	}
+
+	for _, index := range new.Indexes {
+		oldIndex, ok := oldIndexes[index.ID]
+		switch {
+		case !ok:
+			indexChange(SchemaChangeIndexAdded, index, "", index.KeyFingerprint)
+		case oldIndex.Name != index.Name:
+			indexChange(SchemaChangeIndexRenamed, index, oldIndex.Name, index.Name)
+		case fingerprintsDiffer(oldIndex.KeyFingerprint, index.KeyFingerprint):
+			indexChange(SchemaChangeIndexKey, index, oldIndex.KeyFingerprint, index.KeyFingerprint)
+		}
+	}
+
+	for _, index := range old.Indexes {
+		if _, ok := newIndexes[index.ID]; !ok {
+			indexChange(SchemaChangeIndexRemoved, index, index.KeyFingerprint, "")
+		}
+	}
+	return changes
+}
+
+// serializerName describes the serializer of the table by its type.
+func serializerName[T any](opt TableOptions[T]) string {
+	var serializer any = opt.Serializer
+	if opt.Serializer == nil {
+		serializer = opt.DB.Serializer()
+	}
+
+	if wrapper, ok := serializer.(*SerializerAnyWrapper[*T]); ok {
+		serializer = wrapper.Serializer
+	}
+	return fmt.Sprintf("%T", serializer)
+}
@@ -0,0 +1,83 @@
+package bond
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_SchemaFingerprint_DiffSchemas(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	released := db.SchemaFingerprint()
+	require.Len(t, released.Tables, 1)
+	assert.Equal(t, "token_balance", released.Tables[0].Name)
+	assert.Equal(t, "uint64", released.Tables[0].KeyFingerprint)
+	assert.Equal(t, "*serializers.JsonSerializer", released.Tables[0].Serializer)
+	assert.Equal(t, released.Fingerprint, db.SchemaFingerprint().Fingerprint)
+
+	// the schema is stored by the pipeline as JSON
+	data, err := json.Marshal(released)
+	require.NoError(t, err)
+
+	var stored Schema
+	require.NoError(t, json.Unmarshal(data, &stored))
+	assert.Empty(t, DiffSchemas(stored, db.SchemaFingerprint()))
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{
+		NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 1,
+			IndexName: "account_address_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.AccountAddress).Bytes()
+			},
+		}),
+	})
+	require.NoError(t, err)
+
+	_ = NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(2),
+		TableName: "token_balance_archive",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	next := db.SchemaFingerprint()
+	assert.NotEqual(t, released.Fingerprint, next.Fingerprint)
+
+	changes := DiffSchemas(stored, next)
+	require.Len(t, changes, 2)
+	assert.Equal(t, SchemaChangeIndexAdded, changes[0].Kind)
+	assert.Equal(t, "account_address_idx", changes[0].IndexName)
+	assert.True(t, changes[0].NeedsBackfill())
+	assert.Equal(t, SchemaChangeTableAdded, changes[1].Kind)
+	assert.False(t, changes[1].NeedsBackfill())
+
+	// the serializer change of the table
+	next.Tables[0].Serializer = "*serializers.MsgpackSerializer"
+	next.Fingerprint = ""
+
+	changes = DiffSchemas(stored, next)
+	require.Len(t, changes, 3)
+	assert.Equal(t, SchemaChangeTableSerializer, changes[0].Kind)
+	assert.Equal(t, "*serializers.JsonSerializer", changes[0].Old)
+
+	changes = DiffSchemas(next, stored)
+	require.Len(t, changes, 3)
+	assert.Equal(t, SchemaChangeIndexRemoved, changes[1].Kind)
+	assert.Equal(t, SchemaChangeTableRemoved, changes[2].Kind)
+}
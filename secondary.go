@@ -0,0 +1,517 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// DefaultSecondaryCatchUpInterval is the interval the secondary catches up
+// with the primary at if Options.SecondaryCatchUpInterval is not set.
+const DefaultSecondaryCatchUpInterval = 10 * time.Second
+
+// secondaryCheckpointsDir is the directory of the checkpoints read by the
+// secondaries, within the directory of the primary.
+const secondaryCheckpointsDir = "secondary"
+
+// _openDBs are the databases opened in this process by their directories, so
+// the secondaries can find their primaries.
+var _openDBs = struct {
+	dbs   map[string]*_db
+	mutex sync.Mutex
+}{dbs: make(map[string]*_db)}
+
+func registerOpenDB(db *_db) {
+	dirname, err := filepath.Abs(db.dirname)
+	if err != nil {
+		return
+	}
+
+	_openDBs.mutex.Lock()
+	defer _openDBs.mutex.Unlock()
+	_openDBs.dbs[dirname] = db
+}
+
+func unregisterOpenDB(db *_db) {
+	dirname, err := filepath.Abs(db.dirname)
+	if err != nil {
+		return
+	}
+
+	_openDBs.mutex.Lock()
+	defer _openDBs.mutex.Unlock()
+	if _openDBs.dbs[dirname] == db {
+		delete(_openDBs.dbs, dirname)
+	}
+}
+
+// _secondaryInstance is the read-only pebble instance opened on the checkpoint
+// of the primary. It's closed and removed once it's retired and the last
+// iterator, batch and snapshot created on it is closed.
+type _secondaryInstance struct {
+	pebble  *pebble.DB
+	backend Backend
+	dirname string
+	fs      vfs.FS
+
+	// seq is the sequence number of the last batch committed by the primary
+	// before the checkpoint
+	seq uint64
+
+	// refs is the number of the readers of the instance, plus one while it's
+	// the current instance of the secondary
+	refs int64
+}
+
+func (i *_secondaryInstance) acquire() {
+	atomic.AddInt64(&i.refs, 1)
+}
+
+// release releases the reference to the instance, and closes and removes the
+// instance if it was the last one.
+func (i *_secondaryInstance) release() error {
+	if atomic.AddInt64(&i.refs, -1) != 0 {
+		return nil
+	}
+
+	err := i.pebble.Close()
+	if rmErr := i.fs.RemoveAll(i.dirname); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// _secondary is the read-only view of the primary that is replaced with the
+// fresh one on every catch-up.
+type _secondary struct {
+	primary *_db
+	options pebble.Options
+
+	current *_secondaryInstance
+
+	// caughtUp is closed when the current checkpoint is replaced
+	caughtUp chan struct{}
+
+	// onCatchUp is called once the current checkpoint is replaced
+	onCatchUp func() error
+
+	mutex sync.RWMutex
+}
+
+// OpenSecondary opens the read-only secondary of the database opened with
+// Open from the same directory in this process, e.g. for the analytics
+// queries. The secondary reads the checkpoint of the primary, hard linked
+// into the secondary directory within its directory, with its own pebble
+// cache, so its reads do not evict
+// the primary's cache. It catches up with the primary every
+// Options.SecondaryCatchUpInterval, by reading the new checkpoint. The writes
+// to the secondary fail. The secondary uses the serializer of the primary
+// unless Options.Serializer is set.
+//
+// The iterators, the batches and the snapshots of the secondary read the
+// checkpoint they were created on until they are closed. The checkpoint is
+// removed once it's replaced and its last reader is closed.
+//
+// Example:
+//
+//	db, err := bond.Open(dir, opts)
+//	...
+//	analyticsDB, err := bond.OpenSecondary(dir, &bond.Options{
+//		SecondaryCatchUpInterval: time.Minute,
+//	})
+//	tokenBalanceTable := bond.NewTable[*TokenBalance](bond.TableOptions[*TokenBalance]{
+//		DB: analyticsDB,
+//		...
+//	})
+func OpenSecondary(dirname string, opts *Options) (DB, error) {
+	absDirname, err := filepath.Abs(dirname)
+	if err != nil {
+		return nil, err
+	}
+
+	_openDBs.mutex.Lock()
+	primary, ok := _openDBs.dbs[absDirname]
+	_openDBs.mutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("open secondary: database %s is not open in this process", dirname)
+	}
+
+	if primary.pebble == nil {
+		return nil, fmt.Errorf("open secondary: %w", ErrNotSupported)
+	}
+
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	if opts.PebbleOptions == nil {
+		opts.PebbleOptions = DefaultPebbleOptions()
+	}
+
+	health := &_health{}
+
+	pebbleOptions := *opts.PebbleOptions
+	pebbleOptions.Comparer = DefaultKeyComparer()
+	pebbleOptions.Merger = newMerger(opts.PebbleOptions.Merger)
+	pebbleOptions.EventListener = pebble.TeeEventListener(opts.PebbleOptions.EventListener, health.eventListener())
+	pebbleOptions.ReadOnly = true
+
+	s := &_secondary{primary: primary, options: pebbleOptions, caughtUp: make(chan struct{})}
+	s.current, err = s.open()
+	if err != nil {
+		return nil, err
+	}
+
+	db := newDB(s.current.backend, s.current.pebble, dirname, opts, &pebbleOptions, health)
+	db.secondary = s
+	if opts.Serializer == nil {
+		db.serializer = primary.serializer
+	}
+	db.catalog = newCatalog(db, opts.CatalogDriftFunc, opts.OnCatalogRename)
+	s.onCatchUp = db.catalog.refreshStorages
+
+	if db.Version() != BOND_DB_DATA_VERSION {
+		_ = s.close()
+		return nil, fmt.Errorf("bond db version is %d but expecting %d", db.Version(), BOND_DB_DATA_VERSION)
+	}
+
+	if err := db.catalog.load(); err != nil {
+		_ = s.close()
+		return nil, err
+	}
+
+	interval := opts.SecondaryCatchUpInterval
+	if interval <= 0 {
+		interval = DefaultSecondaryCatchUpInterval
+	}
+
+	db.background = newBackground(opts)
+	db.background.Schedule("secondary catch-up", interval, s.catchUp)
+
+	return db, nil
+}
+
+// acquire returns the current checkpoint, which is kept open until it's
+// released.
+func (s *_secondary) acquire() (*_secondaryInstance, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.current == nil {
+		return nil, fmt.Errorf("secondary: database closed")
+	}
+	s.current.acquire()
+	return s.current, nil
+}
+
+// waitFor returns the current checkpoint and the channel closed when it's
+// replaced.
+func (s *_secondary) waitFor() (*_secondaryInstance, <-chan struct{}) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.current, s.caughtUp
+}
+
+// open opens the new checkpoint of the primary.
+func (s *_secondary) open() (*_secondaryInstance, error) {
+	checkpointsDirname := filepath.Join(s.primary.dirname, secondaryCheckpointsDir)
+	err := s.primary.fs.MkdirAll(checkpointsDirname, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("secondary: %w", err)
+	}
+
+	instance := &_secondaryInstance{
+		dirname: filepath.Join(checkpointsDirname, fmt.Sprintf("%d", time.Now().UnixNano())),
+		fs:      s.primary.fs,
+		seq:     atomic.LoadUint64(&s.primary.commitSeq),
+		refs:    1,
+	}
+
+	err = s.primary.pebble.Checkpoint(instance.dirname, pebble.WithFlushedWAL())
+	if err != nil {
+		return nil, fmt.Errorf("secondary: failed to checkpoint: %w", err)
+	}
+
+	instance.pebble, err = pebble.Open(instance.dirname, &s.options)
+	if err != nil {
+		_ = s.primary.fs.RemoveAll(instance.dirname)
+		return nil, fmt.Errorf("secondary: failed to open checkpoint: %w", err)
+	}
+	instance.backend = NewPebbleBackend(instance.pebble)
+	return instance, nil
+}
+
+// removeSecondaryCheckpoints removes the checkpoints left by the secondaries
+// of the primary that was not closed, e.g. crashed. The secondaries are only
+// opened in the process of the primary, so none of them is read.
+func removeSecondaryCheckpoints(db *_db) error {
+	return db.fs.RemoveAll(filepath.Join(db.dirname, secondaryCheckpointsDir))
+}
+
+// catchUp replaces the current checkpoint with the new one. The replaced
+// checkpoint is closed once its last reader is closed.
+func (s *_secondary) catchUp(ctx context.Context) error {
+	instance, err := s.open()
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	retired := s.current
+	s.current = instance
+	s.mutex.Unlock()
+
+	// the tables replaced on the primary read the keys they were replaced
+	// with before the waiters of the catch-up read them
+	if s.onCatchUp != nil {
+		err = s.onCatchUp()
+	}
+
+	s.mutex.Lock()
+	close(s.caughtUp)
+	s.caughtUp = make(chan struct{})
+	s.mutex.Unlock()
+
+	if retired != nil {
+		if releaseErr := retired.release(); err == nil {
+			err = releaseErr
+		}
+	}
+	return err
+}
+
+func (s *_secondary) close() error {
+	s.mutex.Lock()
+	retired := s.current
+	s.current = nil
+	close(s.caughtUp)
+	s.caughtUp = make(chan struct{})
+	s.mutex.Unlock()
+
+	if retired == nil {
+		return nil
+	}
+	return retired.release()
+}
+
+// _secondaryBackend is the backend of the secondary. Every read, iterator,
+// batch and snapshot holds the checkpoint it was created on until it's
+// closed.
+type _secondaryBackend struct {
+	secondary *_secondary
+}
+
+func (b *_secondaryBackend) Get(key []byte) (data []byte, closer io.Closer, err error) {
+	instance, err := b.secondary.acquire()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	data, closer, err = instance.backend.Get(key)
+	if err != nil {
+		_ = instance.release()
+		return nil, nil, err
+	}
+	return data, &_secondaryCloser{Closer: closer, instance: instance}, nil
+}
+
+func (b *_secondaryBackend) Iter(opt *IterOptions) Iterator {
+	instance, err := b.secondary.acquire()
+	if err != nil {
+		return &_closedIterator{err: err}
+	}
+	return &_secondaryIterator{Iterator: instance.backend.Iter(opt), instance: instance}
+}
+
+func (b *_secondaryBackend) Set(key []byte, value []byte, opt WriteOptions) error {
+	instance, err := b.secondary.acquire()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = instance.release() }()
+	return instance.backend.Set(key, value, opt)
+}
+
+func (b *_secondaryBackend) Delete(key []byte, opt WriteOptions) error {
+	instance, err := b.secondary.acquire()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = instance.release() }()
+	return instance.backend.Delete(key, opt)
+}
+
+func (b *_secondaryBackend) DeleteRange(start []byte, end []byte, opt WriteOptions) error {
+	instance, err := b.secondary.acquire()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = instance.release() }()
+	return instance.backend.DeleteRange(start, end, opt)
+}
+
+func (b *_secondaryBackend) Batch() BackendBatch {
+	instance, err := b.secondary.acquire()
+	if err != nil {
+		return &_closedBatch{err: err}
+	}
+	return &_secondaryBatch{BackendBatch: instance.backend.Batch(), instance: instance}
+}
+
+func (b *_secondaryBackend) Snapshot() BackendSnapshot {
+	instance, err := b.secondary.acquire()
+	if err != nil {
+		return &_closedBatch{err: err}
+	}
+	return &_secondarySnapshot{BackendSnapshot: instance.backend.Snapshot(), instance: instance}
+}
+
+func (b *_secondaryBackend) Close() error {
+	return b.secondary.close()
+}
+
+type _secondaryCloser struct {
+	io.Closer
+	instance *_secondaryInstance
+}
+
+func (c *_secondaryCloser) Close() error {
+	err := c.Closer.Close()
+	if relErr := c.instance.release(); err == nil {
+		err = relErr
+	}
+	return err
+}
+
+type _secondaryIterator struct {
+	Iterator
+	instance *_secondaryInstance
+}
+
+func (it *_secondaryIterator) Close() error {
+	err := it.Iterator.Close()
+	if relErr := it.instance.release(); err == nil {
+		err = relErr
+	}
+	return err
+}
+
+type _secondaryBatch struct {
+	BackendBatch
+	instance *_secondaryInstance
+	closed   bool
+}
+
+func (b *_secondaryBatch) Close() error {
+	err := b.BackendBatch.Close()
+	if b.closed {
+		return err
+	}
+	b.closed = true
+	if relErr := b.instance.release(); err == nil {
+		err = relErr
+	}
+	return err
+}
+
+type _secondarySnapshot struct {
+	BackendSnapshot
+	instance *_secondaryInstance
+}
+
+func (s *_secondarySnapshot) Close() error {
+	err := s.BackendSnapshot.Close()
+	if relErr := s.instance.release(); err == nil {
+		err = relErr
+	}
+	return err
+}
+
+// _closedIterator is the iterator of the closed secondary, it's not valid and
+// returns the error.
+type _closedIterator struct {
+	err error
+}
+
+func (it *_closedIterator) First() bool                  { return false }
+func (it *_closedIterator) Last() bool                   { return false }
+func (it *_closedIterator) Prev() bool                   { return false }
+func (it *_closedIterator) Next() bool                   { return false }
+func (it *_closedIterator) Valid() bool                  { return false }
+func (it *_closedIterator) Error() error                 { return it.err }
+func (it *_closedIterator) SeekGE(key []byte) bool       { return false }
+func (it *_closedIterator) SeekPrefixGE(key []byte) bool { return false }
+func (it *_closedIterator) SeekLT(key []byte) bool       { return false }
+func (it *_closedIterator) Key() []byte                  { return nil }
+func (it *_closedIterator) Value() []byte                { return nil }
+func (it *_closedIterator) Close() error                 { return it.err }
+
+// _closedBatch is the batch and the snapshot of the closed secondary, its
+// reads and writes return the error.
+type _closedBatch struct {
+	err error
+}
+
+func (b *_closedBatch) Get(key []byte) (data []byte, closer io.Closer, err error) {
+	return nil, nil, b.err
+}
+
+func (b *_closedBatch) Iter(opt *IterOptions) Iterator {
+	return &_closedIterator{err: b.err}
+}
+
+func (b *_closedBatch) Set(key []byte, value []byte, opt WriteOptions) error {
+	return b.err
+}
+
+func (b *_closedBatch) Delete(key []byte, opt WriteOptions) error {
+	return b.err
+}
+
+func (b *_closedBatch) DeleteRange(start []byte, end []byte, opt WriteOptions) error {
+	return b.err
+}
+
+func (b *_closedBatch) Merge(key []byte, value []byte, opt WriteOptions) error {
+	return b.err
+}
+
+func (b *_closedBatch) Apply(batch BackendBatch, opt WriteOptions) error {
+	return b.err
+}
+
+func (b *_closedBatch) Mutations() []WriteMutation {
+	return nil
+}
+
+func (b *_closedBatch) Count() uint32 {
+	return 0
+}
+
+func (b *_closedBatch) Len() int {
+	return 0
+}
+
+func (b *_closedBatch) Empty() bool {
+	return true
+}
+
+func (b *_closedBatch) Reset() {}
+
+func (b *_closedBatch) Commit(opt WriteOptions) error {
+	return b.err
+}
+
+func (b *_closedBatch) Close() error {
+	return nil
+}
+
+func (b *_closedBatch) SeqNum() uint64 {
+	return 0
+}
@@ -0,0 +1,202 @@
+package bond
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_OpenSecondary(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	newTokenBalanceTable := func(db DB) Table[*TokenBalance] {
+		return NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   TokenBalanceTableID,
+			TableName: "token_balance",
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+		})
+	}
+
+	tokenBalanceTable := newTokenBalanceTable(db)
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+	})
+	require.NoError(t, err)
+
+	secondaryDB, err := OpenSecondary(dbName, nil)
+	require.NoError(t, err)
+
+	secondaryTokenBalanceTable := newTokenBalanceTable(secondaryDB)
+
+	tb, err := secondaryTokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), tb.Balance)
+
+	err = secondaryTokenBalanceTable.Insert(context.Background(), []*TokenBalance{{ID: 3}})
+	require.Error(t, err)
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 7},
+	})
+	require.NoError(t, err)
+
+	assert.False(t, secondaryTokenBalanceTable.Exist(&TokenBalance{ID: 2}))
+
+	err = secondaryDB.(*_db).secondary.catchUp(context.Background())
+	require.NoError(t, err)
+
+	assert.True(t, secondaryTokenBalanceTable.Exist(&TokenBalance{ID: 2}))
+
+	err = secondaryDB.Close()
+	require.NoError(t, err)
+
+	checkpoints, err := filepath.Glob(filepath.Join(dbName, secondaryCheckpointsDir, "*"))
+	require.NoError(t, err)
+	assert.Empty(t, checkpoints)
+
+	// the reads of the closed secondary fail
+	var tokenBalances []*TokenBalance
+	err = secondaryTokenBalanceTable.Scan(context.Background(), &tokenBalances)
+	require.Error(t, err)
+}
+
+func TestBond_OpenSecondary_StaleCheckpoints(t *testing.T) {
+	db := setupDatabase()
+	require.NoError(t, db.Close())
+
+	// the checkpoint left by the crashed process is removed on open
+	stale := filepath.Join(dbName, secondaryCheckpointsDir, "1")
+	require.NoError(t, os.MkdirAll(stale, 0755))
+
+	db = setupDatabase()
+	defer tearDownDatabase(db)
+
+	_, err := os.Stat(stale)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBond_OpenSecondary_NotOpen(t *testing.T) {
+	_, err := OpenSecondary(dbName, nil)
+	require.Error(t, err)
+
+	_ = os.RemoveAll(dbName)
+}
+
+func TestBond_OpenSecondary_WaitForCommit(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	newTokenBalanceTable := func(db DB) Table[*TokenBalance] {
+		return NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   TableID(1),
+			TableName: "token_balance",
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+		})
+	}
+
+	tokenBalanceTable := newTokenBalanceTable(db)
+
+	secondaryDB, err := OpenSecondary(dbName, &Options{SecondaryCatchUpInterval: 50 * time.Millisecond})
+	require.NoError(t, err)
+	defer func() { _ = secondaryDB.Close() }()
+
+	slowSecondaryDB, err := OpenSecondary(dbName, &Options{SecondaryCatchUpInterval: time.Hour})
+	require.NoError(t, err)
+	defer func() { _ = slowSecondaryDB.Close() }()
+
+	var info CommittedBatchInfo
+	ctx := ContextWithWriteOptions(context.Background(), WriteOptions{Sync: true, Committed: &info})
+	err = tokenBalanceTable.Insert(ctx, []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+	})
+	require.NoError(t, err)
+
+	// the primary reads its own commits
+	require.NoError(t, db.WaitForCommit(context.Background(), info.Seq))
+
+	waitCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = secondaryDB.WaitForCommit(waitCtx, info.Seq)
+	require.NoError(t, err)
+	assert.True(t, newTokenBalanceTable(secondaryDB).Exist(&TokenBalance{ID: 1}))
+
+	// the secondary does not catch up before the deadline
+	slowCtx, slowCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer slowCancel()
+
+	err = slowSecondaryDB.WaitForCommit(slowCtx, info.Seq)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestBond_OpenSecondary_IteratorOutlivesCatchUp(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 7},
+	})
+	require.NoError(t, err)
+
+	secondaryDB, err := OpenSecondary(dbName, nil)
+	require.NoError(t, err)
+
+	iter := secondaryDB.Iter(&IterOptions{})
+	require.True(t, iter.First())
+
+	// the iterator keeps its checkpoint open over the catch-ups
+	for i := 0; i < 2; i++ {
+		err = secondaryDB.(*_db).secondary.catchUp(context.Background())
+		require.NoError(t, err)
+	}
+
+	checkpoints, err := filepath.Glob(filepath.Join(dbName, secondaryCheckpointsDir, "*"))
+	require.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+
+	count := 0
+	for ; iter.Valid(); iter.Next() {
+		count++
+	}
+	require.NoError(t, iter.Error())
+	assert.Greater(t, count, 0)
+	require.NoError(t, iter.Close())
+
+	checkpoints, err = filepath.Glob(filepath.Join(dbName, secondaryCheckpointsDir, "*"))
+	require.NoError(t, err)
+	assert.Len(t, checkpoints, 1)
+
+	err = secondaryDB.Close()
+	require.NoError(t, err)
+
+	checkpoints, err = filepath.Glob(filepath.Join(dbName, secondaryCheckpointsDir, "*"))
+	require.NoError(t, err)
+	assert.Empty(t, checkpoints)
+}
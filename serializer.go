@@ -1,5 +1,15 @@
 package bond
 
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"reflect"
+
+	"github.com/go-bond/bond/serializers"
+	"github.com/tinylib/msgp/msgp"
+)
+
 type Serializer[T any] interface {
 	Serialize(t T) ([]byte, error)
 	Deserialize(b []byte, t T) error
@@ -20,3 +30,87 @@ func (s *SerializerAnyWrapper[T]) Serialize(t T) ([]byte, error) {
 func (s *SerializerAnyWrapper[T]) Deserialize(b []byte, t T) error {
 	return s.Serializer.Deserialize(b, t)
 }
+
+// MsgpackGenType is the pointer to the type with the msgp generated encoder
+// and decoder.
+type MsgpackGenType[V any] interface {
+	*V
+	msgp.Encodable
+	msgp.Decodable
+}
+
+// RegisterMsgpackGenType registers the type generated by msgp with
+// serializers.MsgpackGenSerializer. The type parameters are checked at compile
+// time, so the types without the generated code are not accepted. The rows of
+// the registered types are decoded without reflection, and the strict
+// serializer refuses the types that were not registered. The types are
+// generated with:
+//
+//	//go:generate msgp
+//
+//	type TokenBalance struct {
+//		...
+//	}
+//
+//	func init() {
+//		bond.RegisterMsgpackGenType[TokenBalance]()
+//	}
+func RegisterMsgpackGenType[V any, P MsgpackGenType[V]]() {
+	serializers.RegisterMsgpackGenType(reflect.TypeOf(P(nil)), func() msgp.Decodable {
+		return P(new(V))
+	})
+}
+
+// ChecksumSerializer appends CRC-32 checksum to the serialized data and
+// verifies it before deserialization, so that the corrupted rows are
+// detected instead of being decoded.
+type ChecksumSerializer[T any] struct {
+	Serializer Serializer[T]
+}
+
+func (s *ChecksumSerializer[T]) Serialize(t T) ([]byte, error) {
+	data, err := s.Serializer.Serialize(t)
+	if err != nil {
+		return nil, err
+	}
+
+	var checksum [checksumSize]byte
+	binary.BigEndian.PutUint32(checksum[:], crc32.Checksum(data, checksumTable))
+	return append(data, checksum[:]...), nil
+}
+
+func (s *ChecksumSerializer[T]) serializeFor(t T) ([]byte, func(batch Batch) error, error) {
+	bs, ok := s.Serializer.(interface {
+		serializeFor(t T) ([]byte, func(batch Batch) error, error)
+	})
+	if !ok {
+		data, err := s.Serialize(t)
+		return data, nil, err
+	}
+
+	data, write, err := bs.serializeFor(t)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var checksum [checksumSize]byte
+	binary.BigEndian.PutUint32(checksum[:], crc32.Checksum(data, checksumTable))
+	return append(data, checksum[:]...), write, nil
+}
+
+func (s *ChecksumSerializer[T]) Deserialize(b []byte, t T) error {
+	if len(b) < checksumSize {
+		return fmt.Errorf("%w: data too short", ErrChecksumMismatch)
+	}
+
+	data, checksum := b[:len(b)-checksumSize], binary.BigEndian.Uint32(b[len(b)-checksumSize:])
+	if crc32.Checksum(data, checksumTable) != checksum {
+		return ErrChecksumMismatch
+	}
+
+	return s.Serializer.Deserialize(data, t)
+}
+
+const checksumSize = 4
+
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
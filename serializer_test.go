@@ -2,6 +2,7 @@ package bond
 
 import (
 	"bytes"
+	"context"
 	"sync"
 	"testing"
 
@@ -81,3 +82,113 @@ func TestMsgpackGenSerializer_SerializerWithClosable(t *testing.T) {
 
 	assert.Equal(t, tb, tb2)
 }
+
+func TestChecksumSerializer(t *testing.T) {
+	serializer := &ChecksumSerializer[*TokenBalance]{
+		Serializer: &SerializerAnyWrapper[*TokenBalance]{Serializer: &serializers.CBORSerializer{}},
+	}
+
+	tokenBalance := &TokenBalance{ID: 1, AccountAddress: "0xtestAccount", Balance: 5}
+
+	data, err := serializer.Serialize(tokenBalance)
+	require.NoError(t, err)
+
+	var tokenBalanceDeserialized TokenBalance
+	err = serializer.Deserialize(data, &tokenBalanceDeserialized)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalance, &tokenBalanceDeserialized)
+
+	data[0] ^= 0xFF
+	err = serializer.Deserialize(data, &tokenBalanceDeserialized)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+
+	err = serializer.Deserialize([]byte{0x01}, &tokenBalanceDeserialized)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestBondTable_Checksum(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		Checksum: true,
+	})
+
+	tokenBalance := &TokenBalance{ID: 1, AccountAddress: "0xtestAccount", Balance: 5}
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	tokenBalanceFromDB, err := tokenBalanceTable.Get(tokenBalance)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalance, tokenBalanceFromDB)
+
+	// corrupt the row
+	key := tokenBalanceTable.(*_table[*TokenBalance]).key(tokenBalance, make([]byte, 0, DataKeyBufferSize))
+
+	data, closer, err := db.Get(key)
+	require.NoError(t, err)
+	corrupted := append([]byte{}, data...)
+	_ = closer.Close()
+
+	corrupted[0] ^= 0xFF
+	err = db.Set(key, corrupted, Sync)
+	require.NoError(t, err)
+
+	_, err = tokenBalanceTable.Get(tokenBalance)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+
+	var tokenBalances []*TokenBalance
+	err = tokenBalanceTable.Query().Execute(context.Background(), &tokenBalances)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+}
+
+func TestRegisterMsgpackGenType(t *testing.T) {
+	RegisterMsgpackGenType[TokenBalance]()
+
+	s := serializers.MsgpackGenSerializer{Strict: true}
+
+	tb := &TokenBalance{
+		ID:              5,
+		AccountID:       3,
+		ContractAddress: "abc",
+		AccountAddress:  "xyz",
+		TokenID:         12,
+		Balance:         7,
+	}
+
+	buff, err := s.Serialize(tb)
+	require.NoError(t, err)
+
+	var tb2 *TokenBalance
+	err = s.Deserialize(buff, &tb2)
+	require.NoError(t, err)
+	assert.Equal(t, tb, tb2)
+
+	var tb3 TokenBalance
+	err = s.Deserialize(buff, &tb3)
+	require.NoError(t, err)
+	assert.Equal(t, tb, &tb3)
+
+	// the strict serializer refuses the types that were not registered
+	type unregistered struct {
+		ID uint64
+	}
+
+	_, err = s.Serialize(&unregistered{ID: 1})
+	require.Error(t, err)
+
+	var u *unregistered
+	err = s.Deserialize(buff, &u)
+	require.Error(t, err)
+}
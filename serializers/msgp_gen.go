@@ -4,16 +4,43 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
+	"sync"
 
 	"github.com/go-bond/bond/utils"
 	"github.com/tinylib/msgp/msgp"
 )
 
+// msgpackGenTypes maps the registered pointer types to their constructors.
+var msgpackGenTypes sync.Map
+
+// RegisterMsgpackGenType registers the pointer type generated by msgp, so
+// MsgpackGenSerializer decodes into it without looking up the decodable value
+// with reflection. The newFunc creates the zero value of the type.
+func RegisterMsgpackGenType(typ reflect.Type, newFunc func() msgp.Decodable) {
+	msgpackGenTypes.Store(typ, newFunc)
+}
+
+func msgpackGenType(typ reflect.Type) (func() msgp.Decodable, bool) {
+	newFunc, ok := msgpackGenTypes.Load(typ)
+	if !ok {
+		return nil, false
+	}
+	return newFunc.(func() msgp.Decodable), true
+}
+
 type MsgpackGenSerializer struct {
 	Buffer utils.SyncPool[bytes.Buffer]
+
+	// Strict fails the serialization of the types that were not registered
+	// with RegisterMsgpackGenType instead of using reflection.
+	Strict bool
 }
 
 func (m *MsgpackGenSerializer) Serialize(i interface{}) ([]byte, error) {
+	if err := m.checkRegistered(i); err != nil {
+		return nil, err
+	}
+
 	e, ok := i.(msgp.Encodable)
 	if !ok {
 		if typ := reflect.TypeOf(i); typ.Kind() == reflect.Ptr {
@@ -38,6 +65,10 @@ func (m *MsgpackGenSerializer) Serialize(i interface{}) ([]byte, error) {
 }
 
 func (m *MsgpackGenSerializer) SerializerWithCloseable(i interface{}) ([]byte, func(), error) {
+	if err := m.checkRegistered(i); err != nil {
+		return nil, nil, err
+	}
+
 	e, ok := i.(msgp.Encodable)
 	if !ok {
 		if typ := reflect.TypeOf(i); typ.Kind() == reflect.Ptr {
@@ -66,6 +97,25 @@ func (m *MsgpackGenSerializer) SerializerWithCloseable(i interface{}) ([]byte, f
 }
 
 func (m *MsgpackGenSerializer) Deserialize(b []byte, i interface{}) error {
+	// the registered types are decoded directly, or into the new value
+	// the pointer to them is set to
+	typ := reflect.TypeOf(i)
+	if _, ok := msgpackGenType(typ); ok {
+		return msgp.Decode(bytes.NewBuffer(b), i.(msgp.Decodable))
+	}
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		if newFunc, ok := msgpackGenType(typ.Elem()); ok {
+			d := newFunc()
+			err := msgp.Decode(bytes.NewBuffer(b), d)
+			reflect.ValueOf(i).Elem().Set(reflect.ValueOf(d))
+			return err
+		}
+	}
+
+	if m.Strict {
+		return fmt.Errorf("type %s is not registered with RegisterMsgpackGenType", typ)
+	}
+
 	newEntry := utils.MakeNewAny(i)
 	root := utils.FindRootInterface(reflect.ValueOf(newEntry))
 	d, ok := root.(msgp.Decodable)
@@ -78,6 +128,25 @@ func (m *MsgpackGenSerializer) Deserialize(b []byte, i interface{}) error {
 	return err
 }
 
+// checkRegistered returns the error for the types that were not registered
+// if the serializer is strict.
+func (m *MsgpackGenSerializer) checkRegistered(i interface{}) error {
+	if !m.Strict {
+		return nil
+	}
+
+	typ := reflect.TypeOf(i)
+	if _, ok := msgpackGenType(typ); ok {
+		return nil
+	}
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		if _, ok := msgpackGenType(typ.Elem()); ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("type %s is not registered with RegisterMsgpackGenType", typ)
+}
+
 func (m *MsgpackGenSerializer) getBuffer() bytes.Buffer {
 	if m.Buffer != nil {
 		return m.Buffer.Get()
@@ -0,0 +1,166 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SlowQueryLogTableName is the name of the table the slow queries are logged
+// into if Options.SlowQueryLogTableID is set.
+const SlowQueryLogTableName = "bond_slow_query_log"
+
+// SlowQuery is the query that took longer than Options.SlowQueryThreshold.
+type SlowQuery struct {
+	ID           uint64        `json:"id"`
+	Time         time.Time     `json:"time"`
+	Table        string        `json:"table"`
+	Plan         string        `json:"plan"`
+	Selectors    []string      `json:"selectors"`
+	RowsScanned  uint64        `json:"rowsScanned"`
+	RowsReturned uint64        `json:"rowsReturned"`
+	Duration     time.Duration `json:"duration"`
+	Err          string        `json:"err,omitempty"`
+}
+
+// SlowQueryLogger gives access to the logged slow queries.
+type SlowQueryLogger interface {
+	// SlowQueryLog returns the table the slow queries are logged into, so it
+	// can be queried or passed to the inspect CLI. It's nil if the log is not
+	// enabled with Options.SlowQueryLogTableID.
+	SlowQueryLog() Table[*SlowQuery]
+}
+
+func (db *_db) SlowQueryLog() Table[*SlowQuery] {
+	if db.slowQueryLog == nil {
+		return nil
+	}
+	return db.slowQueryLog
+}
+
+func newSlowQueryLog(db *_db, tableID TableID) (*_table[*SlowQuery], error) {
+	table, err := RegisterTable[*SlowQuery](TableOptions[*SlowQuery]{
+		DB:        db,
+		TableID:   tableID,
+		TableName: SlowQueryLogTableName,
+		TablePrimaryKeyFunc: func(builder KeyBuilder, sq *SlowQuery) []byte {
+			return builder.AddUint64Field(sq.ID).Bytes()
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return table.(*_table[*SlowQuery]), nil
+}
+
+// logSlowQuery reports the query if it took longer than the threshold.
+func (q Query[R]) logSlowQuery(start time.Time, rowsScanned uint64, rowsReturned int, queryErr error) {
+	db, ok := q.table.db.(*_db)
+	if !ok || db.slowQueryThreshold <= 0 {
+		return
+	}
+
+	duration := time.Since(start)
+	if duration < db.slowQueryThreshold {
+		return
+	}
+
+	id, _ := sequenceId.Next()
+	slowQuery := &SlowQuery{
+		ID:           uint64(start.UnixNano())<<8 | id&0xFF,
+		Time:         start,
+		Table:        q.table.name,
+		Plan:         q.plan(),
+		Selectors:    q.selectors(),
+		RowsScanned:  rowsScanned,
+		RowsReturned: uint64(rowsReturned),
+		Duration:     duration,
+	}
+	if queryErr != nil {
+		slowQuery.Err = queryErr.Error()
+	}
+
+	if db.onSlowQuery != nil {
+		db.onSlowQuery(*slowQuery)
+	}
+
+	if db.slowQueryLog != nil {
+		_ = db.slowQueryLog.Upsert(context.Background(), []*SlowQuery{slowQuery}, TableUpsertOnConflictReplace[*SlowQuery])
+	}
+}
+
+// Explain describes how the query is executed, e.g. which index is scanned,
+// and why it was chosen by WithBestIndex.
+func (q Query[R]) Explain() string {
+	if len(q.indexCandidates) > 0 {
+		q = q.chooseIndex()
+	}
+
+	if len(q.queries) == 0 {
+		q.queries = []FilterAndIndex[R]{{Index: q.index, IndexSelector: q.indexSelector, IndexPrefix: q.indexPrefix, IndexTimeRange: q.indexTimeRange}}
+	}
+	return q.plan()
+}
+
+// plan describes how the query is executed.
+func (q Query[R]) plan() string {
+	var steps []string
+	for _, query := range q.queries {
+		scan := "scan"
+		if query.IndexPrefix {
+			scan = "prefix scan"
+		} else if query.IndexTimeRange != nil {
+			scan = "time range scan"
+		}
+
+		step := fmt.Sprintf("%s %s", scan, query.Index.IndexName)
+		if query.FilterFunc != nil {
+			step += " with filter"
+		}
+		steps = append(steps, step)
+	}
+
+	if q.indexChoice != "" {
+		steps = append(steps, fmt.Sprintf("index choice: %s", q.indexChoice))
+	}
+	if len(q.notIns) > 0 {
+		steps = append(steps, fmt.Sprintf("not in %d targets", len(q.notIns)))
+	}
+	if q.isAfter {
+		steps = append(steps, "after")
+	}
+	if q.windowFunc != nil {
+		steps = append(steps, "window")
+	}
+	if q.sampleSize > 0 {
+		steps = append(steps, fmt.Sprintf("sample %d", q.sampleSize))
+	}
+	if q.orderLessFunc != nil {
+		steps = append(steps, "order")
+	}
+	if q.offset > 0 {
+		steps = append(steps, fmt.Sprintf("offset %d", q.offset))
+	}
+	if q.limit > 0 {
+		steps = append(steps, fmt.Sprintf("limit %d", q.limit))
+	}
+	if !q.asOf.IsZero() {
+		steps = append(steps, fmt.Sprintf("as of %s", q.asOf.Format(time.RFC3339Nano)))
+	}
+	return strings.Join(steps, ", ")
+}
+
+// selectors returns the index keys the queries start the scans at.
+func (q Query[R]) selectors() []string {
+	selectors := make([]string, 0, len(q.queries))
+	for _, query := range q.queries {
+		key, err := q.table.safeIndexKey(query.IndexSelector, query.Index, []byte{})
+		if err != nil {
+			selectors = append(selectors, err.Error())
+			continue
+		}
+		selectors = append(selectors, FormatKey(key))
+	}
+	return selectors
+}
@@ -0,0 +1,89 @@
+package bond
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_SlowQueryLog(t *testing.T) {
+	var (
+		mutex       sync.Mutex
+		slowQueries []SlowQuery
+	)
+
+	db, err := Open(dbName, &Options{
+		SlowQueryThreshold: time.Nanosecond,
+		OnSlowQuery: func(query SlowQuery) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			slowQueries = append(slowQueries, query)
+		},
+		SlowQueryLogTableID: TableID(0xF0),
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	AccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+	require.NoError(t, tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{AccountAddressIndex}))
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xa1", Balance: 1},
+		{ID: 2, AccountAddress: "0xa1", Balance: 2},
+		{ID: 3, AccountAddress: "0xa2", Balance: 3},
+	})
+	require.NoError(t, err)
+
+	var result []*TokenBalance
+	err = tokenBalanceTable.Query().
+		With(AccountAddressIndex, &TokenBalance{AccountAddress: "0xa1"}).
+		Filter(func(tb *TokenBalance) bool {
+			return tb.Balance > 1
+		}).
+		Limit(1).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+
+	mutex.Lock()
+	require.Len(t, slowQueries, 1)
+	slowQuery := slowQueries[0]
+	mutex.Unlock()
+
+	assert.Equal(t, "token_balance", slowQuery.Table)
+	assert.Equal(t, "scan account_address_idx with filter, limit 1", slowQuery.Plan)
+	assert.Len(t, slowQuery.Selectors, 1)
+	assert.Equal(t, uint64(2), slowQuery.RowsScanned)
+	assert.Equal(t, uint64(1), slowQuery.RowsReturned)
+	assert.Greater(t, slowQuery.Duration, time.Duration(0))
+
+	var logged []*SlowQuery
+	err = db.SlowQueryLog().Scan(context.Background(), &logged)
+	require.NoError(t, err)
+	require.NotEmpty(t, logged)
+	assert.Equal(t, slowQuery.Plan, logged[0].Plan)
+	assert.Equal(t, slowQuery.RowsScanned, logged[0].RowsScanned)
+}
@@ -0,0 +1,160 @@
+package bond
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Snapshotter retains the point-in-time views of the database that can be read
+// with Query.AsOf.
+type Snapshotter interface {
+	// RetainSnapshot takes the snapshot of the database and returns the time
+	// it was taken at. The snapshots are released after Options.SnapshotRetention.
+	RetainSnapshot() time.Time
+}
+
+type _retainedSnapshot struct {
+	time     time.Time
+	snapshot BackendSnapshot
+	refs     int
+	released bool
+}
+
+type _snapshots struct {
+	mutex     sync.Mutex
+	retention time.Duration
+	list      []*_retainedSnapshot
+}
+
+func (db *_db) RetainSnapshot() time.Time {
+	s := &db.snapshots
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := db.clock.Now()
+	if s.retention > 0 {
+		kept := s.list[:0]
+		for _, snapshot := range s.list {
+			if now.Sub(snapshot.time) <= s.retention {
+				kept = append(kept, snapshot)
+				continue
+			}
+
+			snapshot.released = true
+			if snapshot.refs == 0 {
+				_ = snapshot.snapshot.Close()
+			}
+		}
+		s.list = kept
+	}
+
+	s.list = append(s.list, &_retainedSnapshot{time: now, snapshot: db.backend().Snapshot()})
+	return now
+}
+
+// acquireSnapshot returns the latest snapshot taken at or before the time. The
+// snapshot is not closed until it's released.
+func (db *_db) acquireSnapshot(ts time.Time) *_retainedSnapshot {
+	s := &db.snapshots
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for i := len(s.list) - 1; i >= 0; i-- {
+		if !s.list[i].time.After(ts) {
+			s.list[i].refs++
+			return s.list[i]
+		}
+	}
+	return nil
+}
+
+func (db *_db) releaseSnapshot(snapshot *_retainedSnapshot) {
+	s := &db.snapshots
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot.refs--
+	if snapshot.released && snapshot.refs == 0 {
+		_ = snapshot.snapshot.Close()
+	}
+}
+
+func (db *_db) closeSnapshots() {
+	s := &db.snapshots
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, snapshot := range s.list {
+		_ = snapshot.snapshot.Close()
+	}
+	s.list = nil
+}
+
+// _snapshotBatch is the read-only batch that reads from the snapshot, so that
+// the table reads can be directed to it.
+type _snapshotBatch struct {
+	snapshot BackendSnapshot
+}
+
+func (b *_snapshotBatch) ID() uint64 {
+	return 0
+}
+
+func (b *_snapshotBatch) Len() int {
+	return 0
+}
+
+func (b *_snapshotBatch) Empty() bool {
+	return true
+}
+
+func (b *_snapshotBatch) Reset() {
+}
+
+func (b *_snapshotBatch) Get(key []byte, _ ...Batch) (data []byte, closer io.Closer, err error) {
+	return b.snapshot.Get(key)
+}
+
+func (b *_snapshotBatch) Set(_ []byte, _ []byte, _ WriteOptions, _ ...Batch) error {
+	return ErrSnapshotReadOnly
+}
+
+func (b *_snapshotBatch) Delete(_ []byte, _ WriteOptions, _ ...Batch) error {
+	return ErrSnapshotReadOnly
+}
+
+func (b *_snapshotBatch) DeleteRange(_ []byte, _ []byte, _ WriteOptions, _ ...Batch) error {
+	return ErrSnapshotReadOnly
+}
+
+func (b *_snapshotBatch) Iter(opt *IterOptions, _ ...Batch) Iterator {
+	return b.snapshot.Iter(opt)
+}
+
+func (b *_snapshotBatch) Apply(_ Batch, _ WriteOptions) error {
+	return ErrSnapshotReadOnly
+}
+
+func (b *_snapshotBatch) Commit(_ WriteOptions) error {
+	return ErrSnapshotReadOnly
+}
+
+func (b *_snapshotBatch) OnCommit(_ func(b Batch) error) {
+}
+
+func (b *_snapshotBatch) OnCommitted(_ func(b Batch)) {
+}
+
+func (b *_snapshotBatch) OnError(_ func(b Batch, err error)) {
+}
+
+func (b *_snapshotBatch) OnClose(_ func(b Batch)) {
+}
+
+func (b *_snapshotBatch) AfterCommit(_ func(info CommittedBatchInfo)) {
+}
+
+func (b *_snapshotBatch) Close() error {
+	return nil
+}
@@ -0,0 +1,300 @@
+package bond
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// snapshotStreamMagic starts the snapshot stream, the last byte is the format
+// version.
+var snapshotStreamMagic = []byte{'B', 'O', 'N', 'D', 'S', 'N', 'P', 2}
+
+// snapshotManifestName is the name of the last entry of the snapshot stream,
+// which lists the files of the snapshot. The name can not be the name of the
+// checkpoint file, as these are not hidden.
+const snapshotManifestName = ".manifest"
+
+// _snapshotFile is the file listed by the snapshot manifest.
+type _snapshotFile struct {
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	Checksum uint32 `json:"checksum"`
+}
+
+// SnapshotStreamer writes the snapshot of the whole database to the stream.
+type SnapshotStreamer interface {
+	SnapshotStream(ctx context.Context, w io.Writer) error
+}
+
+// SnapshotStream writes the consistent snapshot of the whole database to the
+// stream, which seeds the new replica with BootstrapFromSnapshot. The snapshot
+// is the pebble checkpoint, the sstables are hard linked where the file
+// system allows it and transferred as they are, so the replica does not
+// rebuild them key by key as with Export. The checkpoint is taken next to the
+// database directory and removed once it's written. The stream ends with the
+// manifest of the files with their sizes and checksums, so the stream cut
+// short is detected by the replica.
+//
+// Example:
+//
+//	// on the primary
+//	err := db.SnapshotStream(ctx, conn)
+//
+//	// on the new replica
+//	err := bond.BootstrapFromSnapshot(ctx, conn, dir)
+//	db, err := bond.Open(dir, opts)
+func (db *_db) SnapshotStream(ctx context.Context, w io.Writer) error {
+	fs := db.fs
+	dirname, err := filepath.Abs(db.dirname)
+	if err != nil {
+		return err
+	}
+	checkpointDir := fmt.Sprintf("%s.snapshot-%d", dirname, time.Now().UnixNano())
+
+	pdb, release, err := db.pebbleDB()
+	if err != nil {
+		return fmt.Errorf("snapshot stream: %w", err)
+	}
+
+	err = pdb.Checkpoint(checkpointDir, pebble.WithFlushedWAL())
+	release()
+	if err != nil {
+		return fmt.Errorf("snapshot stream: failed to checkpoint: %w", err)
+	}
+	defer func() {
+		_ = fs.RemoveAll(checkpointDir)
+	}()
+
+	files, err := fs.List(checkpointDir)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	_, err = bw.Write(snapshotStreamMagic)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(bw)
+	manifest := make([]_snapshotFile, 0, len(files))
+	for _, name := range files {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		file, ok, err := writeSnapshotFile(tw, fs, checkpointDir, name)
+		if err != nil {
+			return err
+		}
+		if ok {
+			manifest = append(manifest, file)
+		}
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{
+		Name: snapshotManifestName,
+		Mode: 0644,
+		Size: int64(len(data)),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tw.Write(data)
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeSnapshotFile writes the file of the checkpoint to the tar stream. It
+// returns the file as listed by the manifest, false for the directories.
+func writeSnapshotFile(tw *tar.Writer, fs vfs.FS, dir string, name string) (_snapshotFile, bool, error) {
+	path := fs.PathJoin(dir, name)
+
+	info, err := fs.Stat(path)
+	if err != nil {
+		return _snapshotFile{}, false, err
+	}
+	if info.IsDir() {
+		return _snapshotFile{}, false, nil
+	}
+
+	f, err := fs.Open(path)
+	if err != nil {
+		return _snapshotFile{}, false, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	})
+	if err != nil {
+		return _snapshotFile{}, false, err
+	}
+
+	checksum := crc32.New(checksumTable)
+	size, err := io.Copy(io.MultiWriter(tw, checksum), f)
+	if err != nil {
+		return _snapshotFile{}, false, err
+	}
+	return _snapshotFile{Name: name, Size: size, Checksum: checksum.Sum32()}, true, nil
+}
+
+// BootstrapFromSnapshot writes the database read from the stream written by
+// SnapshotStream to the directory, which is then opened with Open. The
+// directory must not exist or be empty. The files are written to the
+// temporary directory next to it, which is renamed to the directory once the
+// files match the manifest that ends the stream, so the stream that can not be
+// read completely leaves no directory behind.
+func BootstrapFromSnapshot(ctx context.Context, r io.Reader, dir string) (err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("bootstrap from snapshot: directory %s is not empty", dir)
+	}
+
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(snapshotStreamMagic))
+	_, err = io.ReadFull(br, magic)
+	if err != nil || !bytes.Equal(magic, snapshotStreamMagic) {
+		return fmt.Errorf("bootstrap from snapshot: not the snapshot stream")
+	}
+
+	tmpDir := fmt.Sprintf("%s.bootstrap-%d", filepath.Clean(dir), time.Now().UnixNano())
+	err = os.MkdirAll(tmpDir, 0755)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.RemoveAll(tmpDir)
+		}
+	}()
+
+	received := make(map[string]_snapshotFile)
+	var manifest []_snapshotFile
+
+	tr := tar.NewReader(br)
+	for manifest == nil {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		var header *tar.Header
+		header, err = tr.Next()
+		if err == io.EOF {
+			return fmt.Errorf("bootstrap from snapshot: stream ended before the manifest")
+		} else if err != nil {
+			return fmt.Errorf("bootstrap from snapshot: %w", err)
+		}
+
+		if header.Name == snapshotManifestName {
+			manifest = []_snapshotFile{}
+			err = json.NewDecoder(tr).Decode(&manifest)
+			if err != nil {
+				return fmt.Errorf("bootstrap from snapshot: invalid manifest: %w", err)
+			}
+			continue
+		}
+
+		// the checkpoint files are flat, the other names are not trusted
+		if header.Name != filepath.Base(header.Name) || strings.HasPrefix(header.Name, ".") {
+			return fmt.Errorf("bootstrap from snapshot: invalid file name %q", header.Name)
+		}
+
+		var file _snapshotFile
+		file, err = readSnapshotFile(tr, filepath.Join(tmpDir, header.Name))
+		if err != nil {
+			return err
+		}
+		received[header.Name] = file
+	}
+
+	err = checkSnapshotManifest(manifest, received)
+	if err != nil {
+		return err
+	}
+
+	// the empty directory is replaced
+	err = os.Remove(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(tmpDir, dir)
+}
+
+// checkSnapshotManifest returns the error if the received files do not match
+// the files listed by the manifest.
+func checkSnapshotManifest(manifest []_snapshotFile, received map[string]_snapshotFile) error {
+	if len(manifest) != len(received) {
+		return fmt.Errorf("bootstrap from snapshot: %d files received, the manifest lists %d", len(received), len(manifest))
+	}
+
+	for _, file := range manifest {
+		if got, ok := received[file.Name]; !ok {
+			return fmt.Errorf("bootstrap from snapshot: file %s is missing", file.Name)
+		} else if got != file {
+			return fmt.Errorf("bootstrap from snapshot: file %s does not match the manifest", file.Name)
+		}
+	}
+	return nil
+}
+
+// readSnapshotFile writes the file read from the tar stream and syncs it. It
+// returns the file with the size and the checksum of the bytes written.
+func readSnapshotFile(r io.Reader, path string) (_snapshotFile, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return _snapshotFile{}, err
+	}
+
+	checksum := crc32.New(checksumTable)
+	size, err := io.Copy(io.MultiWriter(f, checksum), r)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	closeErr := f.Close()
+	if err != nil {
+		return _snapshotFile{}, err
+	} else if closeErr != nil {
+		return _snapshotFile{}, closeErr
+	}
+	return _snapshotFile{Name: filepath.Base(path), Size: size, Checksum: checksum.Sum32()}, nil
+}
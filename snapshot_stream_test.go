@@ -0,0 +1,108 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_SnapshotStream(t *testing.T) {
+	const replicaDBName = "test_db_replica"
+
+	db, tokenBalanceTable, accountAddressIndex, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+	defer func() {
+		_ = os.RemoveAll(replicaDBName)
+	}()
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", ContractAddress: "0xtestContract", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount2", ContractAddress: "0xtestContract", Balance: 15},
+		{ID: 3, AccountAddress: "0xtestAccount1", ContractAddress: "0xtestContract", Balance: 7},
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	var stream bytes.Buffer
+	err = db.SnapshotStream(context.Background(), &stream)
+	require.NoError(t, err)
+
+	// the checkpoint is removed
+	matches, err := os.ReadDir(".")
+	require.NoError(t, err)
+	for _, entry := range matches {
+		assert.NotContains(t, entry.Name(), dbName+".snapshot-")
+	}
+
+	err = BootstrapFromSnapshot(context.Background(), bytes.NewReader(stream.Bytes()), replicaDBName)
+	require.NoError(t, err)
+
+	replica, err := Open(replicaDBName, &Options{})
+	require.NoError(t, err)
+	defer func() {
+		_ = replica.Close()
+	}()
+
+	replicaTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        replica,
+		TableID:   tokenBalanceTable.ID(),
+		TableName: tokenBalanceTable.Name(),
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+	require.NoError(t, replicaTable.AddIndex([]*Index[*TokenBalance]{accountAddressIndex}))
+
+	var tokenBalancesRead []*TokenBalance
+	err = replicaTable.Scan(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances, tokenBalancesRead)
+
+	err = replicaTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount1"}).
+		Execute(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalances[0], tokenBalances[2]}, tokenBalancesRead)
+
+	// the directory is not empty
+	err = BootstrapFromSnapshot(context.Background(), bytes.NewReader(stream.Bytes()), replicaDBName)
+	require.Error(t, err)
+
+	err = BootstrapFromSnapshot(context.Background(), bytes.NewReader([]byte("not a snapshot")), replicaDBName+"_2")
+	require.Error(t, err)
+	_, err = os.Stat(replicaDBName + "_2")
+	assert.True(t, os.IsNotExist(err))
+
+	assertNotBootstrapped := func(stream []byte) {
+		err := BootstrapFromSnapshot(context.Background(), bytes.NewReader(stream), replicaDBName+"_2")
+		require.Error(t, err)
+
+		_, err = os.Stat(replicaDBName + "_2")
+		assert.True(t, os.IsNotExist(err))
+
+		entries, err := os.ReadDir(".")
+		require.NoError(t, err)
+		for _, entry := range entries {
+			assert.NotContains(t, entry.Name(), replicaDBName+"_2.bootstrap-")
+		}
+	}
+
+	// the stream cut at the file boundary, before the manifest
+	manifestHeader := bytes.Index(stream.Bytes(), []byte(snapshotManifestName))
+	require.Greater(t, manifestHeader, 0)
+	assertNotBootstrapped(stream.Bytes()[:manifestHeader])
+
+	// the stream cut before the first file
+	firstFile := len(snapshotStreamMagic)
+	assertNotBootstrapped(stream.Bytes()[:firstFile])
+
+	// the file corrupted in transit
+	corrupted := append([]byte{}, stream.Bytes()...)
+	corrupted[firstFile+512] ^= 0xff
+	assertNotBootstrapped(corrupted)
+}
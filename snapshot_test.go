@@ -0,0 +1,82 @@
+package bond
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Query_AsOf(t *testing.T) {
+	db, TokenBalanceTable, TokenBalanceAccountAddressIndex, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	start := time.Now()
+
+	tokenBalance := &TokenBalance{ID: 1, AccountAddress: "0xa1", ContractAddress: "0xc1", Balance: 5}
+	err := TokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	first := db.RetainSnapshot()
+
+	err = TokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xa1", ContractAddress: "0xc1", Balance: 10},
+	})
+	require.NoError(t, err)
+
+	err = TokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 2, AccountAddress: "0xa1", ContractAddress: "0xc2", Balance: 7},
+	})
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+	second := db.RetainSnapshot()
+
+	err = TokenBalanceTable.Delete(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	var result []*TokenBalance
+	err = TokenBalanceTable.Query().AsOf(first).Execute(context.Background(), &result)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalance}, result)
+
+	// the latest snapshot before the time is used
+	err = TokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xa1"}).
+		AsOf(second.Add(time.Hour)).
+		Execute(context.Background(), &result)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+	assert.Equal(t, uint64(10), result[0].Balance)
+	assert.Equal(t, uint64(7), result[1].Balance)
+
+	err = TokenBalanceTable.Query().Execute(context.Background(), &result)
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+
+	keys, err := TokenBalanceTable.Query().AsOf(first).Keys(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+
+	err = TokenBalanceTable.Query().AsOf(start).Execute(context.Background(), &result)
+	require.ErrorIs(t, err, ErrSnapshotNotFound)
+}
+
+func TestBond_RetainSnapshot_Retention(t *testing.T) {
+	db, err := Open(dbName, &Options{SnapshotRetention: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	first := db.RetainSnapshot()
+	time.Sleep(20 * time.Millisecond)
+	_ = db.RetainSnapshot()
+
+	snapshot := db.(*_db).acquireSnapshot(first)
+	assert.Nil(t, snapshot)
+}
@@ -0,0 +1,354 @@
+package bond
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// SystemTableRow is the row of TablesTable, the table of the catalog.
+type SystemTableRow struct {
+	ID   TableID
+	Name string
+
+	// Registered is true if the table is registered by this process.
+	Registered bool
+	// Persisted is true if the table is in the persisted catalog.
+	Persisted bool
+
+	Indexes int
+}
+
+// SystemIndexRow is the row of IndexesTable, the table of the catalog indexes.
+type SystemIndexRow struct {
+	TableID   TableID
+	TableName string
+	ID        IndexID
+	Name      string
+
+	Registered bool
+	Persisted  bool
+}
+
+// SystemStatsRow is the row of StatsTable, the statistics of the table index.
+// The primary index of the table is the index with PrimaryIndexID.
+type SystemStatsRow struct {
+	TableID   TableID
+	TableName string
+	IndexID   IndexID
+	IndexName string
+
+	// DiskUsageBytes is the estimated size of the index on disk.
+	DiskUsageBytes uint64
+
+	// Entries is the number of the index entries as persisted by the index
+	// statistics, HasEntries is false if the index does not maintain them.
+	Entries    uint64
+	HasEntries bool
+}
+
+const (
+	systemTablesTableID  = TableID(1)
+	systemIndexesTableID = TableID(2)
+	systemStatsTableID   = TableID(3)
+)
+
+// _systemTables holds the system tables of the database. The tables are
+// stored in the in-memory database that is refreshed every time the table
+// is asked for, so the queries see the consistent snapshot of the metadata.
+type _systemTables struct {
+	db DB
+
+	tables  Table[*SystemTableRow]
+	indexes Table[*SystemIndexRow]
+	stats   Table[*SystemStatsRow]
+
+	mutex sync.Mutex
+}
+
+// TablesTable returns the system table of the tables registered on the
+// database or persisted in its catalog. It's queried with the Query API as
+// any other table and passed to the inspect tool along with the tables of
+// the application.
+//
+// Example:
+//
+//	tables, err := bond.TablesTable(ctx, db)
+//	...
+//	var unregistered []*bond.SystemTableRow
+//	err = tables.Query().Filter(func(t *bond.SystemTableRow) bool {
+//		return !t.Registered
+//	}).Execute(ctx, &unregistered)
+func TablesTable(ctx context.Context, db DB) (Table[*SystemTableRow], error) {
+	system, err := refreshSystemTables(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	return system.tables, nil
+}
+
+// IndexesTable returns the system table of the indexes registered on the
+// database or persisted in its catalog, ordered by the table and the index.
+func IndexesTable(ctx context.Context, db DB) (Table[*SystemIndexRow], error) {
+	system, err := refreshSystemTables(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	return system.indexes, nil
+}
+
+// StatsTable returns the system table of the statistics of the table indexes,
+// the primary index included.
+func StatsTable(ctx context.Context, db DB) (Table[*SystemStatsRow], error) {
+	system, err := refreshSystemTables(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	return system.stats, nil
+}
+
+// refreshSystemTables opens the system tables of the database on the first
+// call and writes the current metadata to them.
+func refreshSystemTables(ctx context.Context, db DB) (*_systemTables, error) {
+	d, ok := db.(*_db)
+	if !ok {
+		return nil, fmt.Errorf("system tables: unsupported database")
+	}
+
+	d.systemTablesMutex.Lock()
+	system := d.systemTables
+	if system == nil {
+		var err error
+		system, err = newSystemTables()
+		if err != nil {
+			d.systemTablesMutex.Unlock()
+			return nil, err
+		}
+
+		d.systemTables = system
+		d.OnClose(func(DB) {
+			_ = system.db.Close()
+		})
+	}
+	d.systemTablesMutex.Unlock()
+
+	tables, indexes, stats, err := d.systemRows()
+	if err != nil {
+		return nil, err
+	}
+
+	err = system.refresh(ctx, tables, indexes, stats)
+	if err != nil {
+		return nil, err
+	}
+	return system, nil
+}
+
+func newSystemTables() (*_systemTables, error) {
+	pebbleOptions := DefaultPebbleOptions()
+	pebbleOptions.FS = vfs.NewMem()
+	pebbleOptions.MemTableSize = 1 << 20
+
+	db, err := Open("system", &Options{PebbleOptions: pebbleOptions})
+	if err != nil {
+		return nil, err
+	}
+
+	system := &_systemTables{db: db}
+
+	system.tables, err = RegisterTable[*SystemTableRow](TableOptions[*SystemTableRow]{
+		DB:        db,
+		TableID:   systemTablesTableID,
+		TableName: "bond_tables",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, row *SystemTableRow) []byte {
+			return builder.AddByteField(byte(row.ID)).Bytes()
+		},
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	system.indexes, err = RegisterTable[*SystemIndexRow](TableOptions[*SystemIndexRow]{
+		DB:        db,
+		TableID:   systemIndexesTableID,
+		TableName: "bond_indexes",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, row *SystemIndexRow) []byte {
+			return builder.AddByteField(byte(row.TableID)).AddByteField(byte(row.ID)).Bytes()
+		},
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	system.stats, err = RegisterTable[*SystemStatsRow](TableOptions[*SystemStatsRow]{
+		DB:        db,
+		TableID:   systemStatsTableID,
+		TableName: "bond_stats",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, row *SystemStatsRow) []byte {
+			return builder.AddByteField(byte(row.TableID)).AddByteField(byte(row.IndexID)).Bytes()
+		},
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return system, nil
+}
+
+// refresh replaces the rows of the system tables with one batch.
+func (s *_systemTables) refresh(ctx context.Context, tables []*SystemTableRow, indexes []*SystemIndexRow, stats []*SystemStatsRow) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	batch := s.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	err := replaceSystemRows(ctx, s.tables, tables, batch)
+	if err != nil {
+		return err
+	}
+
+	err = replaceSystemRows(ctx, s.indexes, indexes, batch)
+	if err != nil {
+		return err
+	}
+
+	err = replaceSystemRows(ctx, s.stats, stats, batch)
+	if err != nil {
+		return err
+	}
+
+	return batch.Commit(Sync)
+}
+
+func replaceSystemRows[T any](ctx context.Context, table Table[T], rows []T, batch Batch) error {
+	var existing []T
+	err := table.Scan(ctx, &existing, batch)
+	if err != nil {
+		return err
+	}
+
+	err = table.Delete(ctx, existing, batch)
+	if err != nil {
+		return err
+	}
+	return table.Insert(ctx, rows, batch)
+}
+
+// systemRows returns the rows of the system tables from the catalog.
+func (db *_db) systemRows() ([]*SystemTableRow, []*SystemIndexRow, []*SystemStatsRow, error) {
+	db.catalog.mutex.Lock()
+
+	tables := make(map[TableID]*SystemTableRow)
+	indexes := make(map[[2]uint8]*SystemIndexRow)
+
+	for id, name := range db.catalog.tables {
+		tables[id] = &SystemTableRow{ID: id, Name: name, Registered: true}
+		for indexID, indexName := range db.catalog.indexes[id] {
+			indexes[[2]uint8{uint8(id), uint8(indexID)}] = &SystemIndexRow{
+				TableID: id, TableName: name, ID: indexID, Name: indexName, Registered: true,
+			}
+		}
+	}
+
+	for id, persisted := range db.catalog.persisted {
+		table, ok := tables[id]
+		if !ok {
+			table = &SystemTableRow{ID: id, Name: persisted.Name}
+			tables[id] = table
+		}
+		table.Persisted = true
+
+		for _, persistedIndex := range persisted.Indexes {
+			index, ok := indexes[[2]uint8{uint8(id), uint8(persistedIndex.ID)}]
+			if !ok {
+				index = &SystemIndexRow{TableID: id, TableName: table.Name, ID: persistedIndex.ID, Name: persistedIndex.Name}
+				indexes[[2]uint8{uint8(id), uint8(persistedIndex.ID)}] = index
+			}
+			index.Persisted = true
+		}
+	}
+
+	db.catalog.mutex.Unlock()
+
+	var (
+		tableRows []*SystemTableRow
+		indexRows []*SystemIndexRow
+		statsRows []*SystemStatsRow
+	)
+
+	for _, index := range indexes {
+		tables[index.TableID].Indexes++
+		indexRows = append(indexRows, index)
+	}
+	for _, table := range tables {
+		tableRows = append(tableRows, table)
+	}
+
+	sort.Slice(tableRows, func(i, j int) bool {
+		return tableRows[i].ID < tableRows[j].ID
+	})
+	sort.Slice(indexRows, func(i, j int) bool {
+		if indexRows[i].TableID != indexRows[j].TableID {
+			return indexRows[i].TableID < indexRows[j].TableID
+		}
+		return indexRows[i].ID < indexRows[j].ID
+	})
+
+	for _, table := range tableRows {
+		stats := []*SystemStatsRow{{TableID: table.ID, TableName: table.Name, IndexID: PrimaryIndexID, IndexName: PrimaryIndexName}}
+		for _, index := range indexRows {
+			if index.TableID == table.ID && index.ID != PrimaryIndexID {
+				stats = append(stats, &SystemStatsRow{TableID: table.ID, TableName: table.Name, IndexID: index.ID, IndexName: index.Name})
+			}
+		}
+
+		for _, stat := range stats {
+			err := db.indexStatsRow(stat)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		statsRows = append(statsRows, stats...)
+	}
+	return tableRows, indexRows, statsRows, nil
+}
+
+// indexStatsRow sets the disk usage and the persisted entries of the index.
+func (db *_db) indexStatsRow(row *SystemStatsRow) error {
+	storageID := db.catalog.storageID(row.TableID)
+	prefix := []byte{byte(storageID), byte(row.IndexID)}
+
+	upperBound := prefixUpperBound(prefix)
+	if upperBound == nil {
+		upperBound = []byte{0xFF, 0xFF, 0xFF}
+	}
+
+	size, err := db.estimateDiskUsage(prefix, upperBound)
+	if err != nil {
+		return err
+	}
+	row.DiskUsageBytes = size
+
+	data, closer, err := db.Get([]byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_INDEX_STATS_INDEX_ID, byte(storageID), byte(row.IndexID)})
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer func() { _ = closer.Close() }()
+
+	if len(data) >= 8 {
+		row.Entries, row.HasEntries = uint64(maxInt64(int64(binary.BigEndian.Uint64(data)), 0)), true
+	}
+	return nil
+}
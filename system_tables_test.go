@@ -0,0 +1,105 @@
+package bond
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_SystemTables(t *testing.T) {
+	defer func(interval time.Duration) {
+		IndexStatsPersistInterval = interval
+	}(IndexStatsPersistInterval)
+	IndexStatsPersistInterval = 0
+
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{
+		NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 1,
+			IndexName: "account_address_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.AccountAddress).Bytes()
+			},
+			IndexOrderFunc:  IndexOrderDefault[*TokenBalance],
+			IndexStatistics: true,
+		}),
+	})
+	require.NoError(t, err)
+
+	tables, err := TablesTable(context.Background(), db)
+	require.NoError(t, err)
+
+	var tableRows []*SystemTableRow
+	err = tables.Query().Execute(context.Background(), &tableRows)
+	require.NoError(t, err)
+	assert.Equal(t, []*SystemTableRow{
+		{ID: TableID(1), Name: "token_balance", Registered: true, Persisted: true, Indexes: 1},
+	}, tableRows)
+
+	_ = NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(2),
+		TableName: "token_balance_archive",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount2", Balance: 7},
+	})
+	require.NoError(t, err)
+
+	// the tables are refreshed on every call
+	tables, err = TablesTable(context.Background(), db)
+	require.NoError(t, err)
+
+	err = tables.Query().Execute(context.Background(), &tableRows)
+	require.NoError(t, err)
+	require.Len(t, tableRows, 2)
+	assert.Equal(t, "token_balance_archive", tableRows[1].Name)
+
+	indexes, err := IndexesTable(context.Background(), db)
+	require.NoError(t, err)
+
+	indexRow, err := indexes.Get(&SystemIndexRow{TableID: TableID(1), ID: PrimaryIndexID + 1})
+	require.NoError(t, err)
+	assert.Equal(t, &SystemIndexRow{
+		TableID: TableID(1), TableName: "token_balance", ID: PrimaryIndexID + 1,
+		Name: "account_address_idx", Registered: true, Persisted: true,
+	}, indexRow)
+
+	stats, err := StatsTable(context.Background(), db)
+	require.NoError(t, err)
+
+	var statsRows []*SystemStatsRow
+	err = stats.Query().Execute(context.Background(), &statsRows)
+	require.NoError(t, err)
+	require.Len(t, statsRows, 3)
+
+	assert.Equal(t, PrimaryIndexName, statsRows[0].IndexName)
+	assert.False(t, statsRows[0].HasEntries)
+	assert.Equal(t, "account_address_idx", statsRows[1].IndexName)
+	assert.True(t, statsRows[1].HasEntries)
+	assert.Equal(t, uint64(2), statsRows[1].Entries)
+	assert.Equal(t, TableID(2), statsRows[2].TableID)
+
+	// the system tables are the tables for the inspect tool
+	var tableInfo TableInfo = tables
+	assert.Equal(t, "bond_tables", tableInfo.Name())
+}
@@ -3,15 +3,17 @@ package bond
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"runtime"
 	"sort"
 	"sync"
+	"time"
 
 	"github.com/cockroachdb/pebble"
 	"github.com/go-bond/bond/utils"
-	"golang.org/x/exp/maps"
 )
 
 const PrimaryKeyBufferSize = 10240
@@ -38,6 +40,8 @@ type TableInfo interface {
 
 type TableGetter[T any] interface {
 	Get(tr T, optBatch ...Batch) (T, error)
+	GetOr(ctx context.Context, tr T, def T, optBatch ...Batch) (T, error)
+	GetByKeys(ctx context.Context, keys []PrimaryKey, optBatch ...Batch) ([]T, error)
 }
 
 type TableExistChecker[T any] interface {
@@ -46,6 +50,7 @@ type TableExistChecker[T any] interface {
 
 type TableQuerier[T any] interface {
 	Query() Query[T]
+	TablePaginator[T]
 }
 
 type TableScanner[T any] interface {
@@ -53,6 +58,8 @@ type TableScanner[T any] interface {
 	ScanIndex(ctx context.Context, i *Index[T], s T, tr *[]T, optBatch ...Batch) error
 	ScanForEach(ctx context.Context, f func(keyBytes KeyBytes, l Lazy[T]) (bool, error), optBatch ...Batch) error
 	ScanIndexForEach(ctx context.Context, idx *Index[T], s T, f func(keyBytes KeyBytes, t Lazy[T]) (bool, error), optBatch ...Batch) error
+	RawScan(ctx context.Context, f func(key, value []byte) bool, optBatch ...Batch) error
+	RawScanIndex(ctx context.Context, idx *Index[T], f func(key, value []byte) bool, optBatch ...Batch) error
 }
 
 type TableIterationer[T any] interface {
@@ -71,7 +78,10 @@ type TableReader[T any] interface {
 	TableQuerier[T]
 
 	TableScanner[T]
+	TableShardScanner[T]
+	TableColumnScanner[T]
 	TableIterationer[T]
+	TableDebugger[T]
 }
 
 type TableInserter[T any] interface {
@@ -84,6 +94,7 @@ type TableUpdater[T any] interface {
 
 type TableUpserter[T any] interface {
 	Upsert(ctx context.Context, trs []T, onConflict func(old, new T) T, optBatch ...Batch) error
+	UpsertReturning(ctx context.Context, trs []T, optBatch ...Batch) ([]T, error)
 }
 
 type TableDeleter[T any] interface {
@@ -92,11 +103,24 @@ type TableDeleter[T any] interface {
 
 type TableWriter[T any] interface {
 	AddIndex(idxs []*Index[T], reIndex ...bool) error
+	TableReferenceReindexer[T]
+	TableDictionaryCollector
+	TableOverflowCollector
+	TablePartitioner[T]
+	TableVersioner[T]
+	TableFilterIndexer[T]
 
 	TableInserter[T]
 	TableUpdater[T]
 	TableUpserter[T]
 	TableDeleter[T]
+	TableRangeDeleter[T]
+	TableRewriter[T]
+	TableArchiver
+	TableRetentionEnforcer
+	TableLoader[T]
+	TableAggregator[T]
+	TableReplacer[T]
 }
 
 type Table[T any] interface {
@@ -113,38 +137,328 @@ type TableOptions[T any] struct {
 	Serializer          Serializer[*T]
 
 	Filter Filter
+
+	// Checksum enables CRC-32 checksums of the rows. The checksum is verified
+	// on every read and the read fails with ErrChecksumMismatch if the row is
+	// corrupted. The checksums can not be enabled for the table with existing
+	// rows, as these would fail the verification.
+	Checksum bool
+
+	// ScanPrefetchSize enables batched row lookups during secondary index
+	// scans. The index hits are collected in groups of this size and the
+	// rows of the group are fetched concurrently once the scan callback reads
+	// them, in steps growing up to the group size. Zero or one disables
+	// prefetching.
+	ScanPrefetchSize int
+
+	// ScanPrefetchMaxConcurrency is the maximal number of concurrent row
+	// lookups performed by a single prefetching scan or a multi-row get.
+	// Zero defaults to GOMAXPROCS.
+	ScanPrefetchMaxConcurrency int
+
+	// CacheSize enables the LRU cache of deserialized rows keyed by the
+	// primary key. The cache is used by the reads performed outside of the
+	// batch and is invalidated on writes once they are committed. The cached
+	// rows are shared between the readers and must not be modified.
+	// Zero disables the cache.
+	CacheSize int
+
+	// QueryCacheSize enables the LRU cache of query results for the queries
+	// marked with Query.Cached. The cached results are invalidated by the
+	// writes of rows that belong to the index prefixes the query reads from.
+	// Zero disables the cache.
+	QueryCacheSize int
+
+	// FieldCodec encodes the sensitive fields of the rows before they are
+	// written. The rows read with Query, scans and GetByKeys are decoded if
+	// the context carries the key set with ContextWithFieldCodecKey, the
+	// other reads return the encoded rows.
+	FieldCodec FieldCodec[T]
+
+	// RateLimit is the number of rows per second the table accepts with
+	// Insert, Update, Upsert and UnsafeUpdate. The writes exceeding it fail
+	// with ErrRateLimited. RateLimitBurst is the number of rows that can be
+	// written at once, one second worth of rows if not set. Zero disables
+	// the limit.
+	RateLimit      float64
+	RateLimitBurst int
+
+	// MaxSizeBytes is the approximate size limit of the table rows. The size
+	// is estimated from the disk usage of the table every second and the
+	// bytes written in between. The writes exceeding it fail with
+	// ErrTableSizeExceeded. Zero disables the limit.
+	MaxSizeBytes uint64
+
+	// DeltaCodec enables the delta encoding of the updates. Update and Upsert
+	// store the delta against the previous version of the row instead of the
+	// whole row, the deltas are applied on read and folded by the compaction
+	// into the row they follow. It reduces the write amplification of the rows
+	// that change a small field many times. DeltaMaxChain is the number of
+	// deltas stored before the whole row is written again, DefaultDeltaMaxChain
+	// if not set. The deltas must not be enabled for the table with existing
+	// rows that end with the delta record magic.
+	DeltaCodec    DeltaCodec[T]
+	DeltaMaxChain int
+
+	// ColumnGroups are the groups of the hot fields stored apart from the
+	// rows, so the scans that only need them with ScanColumns read a fraction
+	// of the bytes. The groups are maintained on every write, the rows keep
+	// all the fields, so the point reads are not affected. The groups must
+	// be set when the table is created, the rows written before are not in
+	// the groups.
+	ColumnGroups []ColumnGroup[T]
+
+	// DictionaryFields are the string fields stored as the references to the
+	// values in the table dictionary, which shrinks the rows with the values
+	// repeated by many rows. The values are decoded on read. The values no
+	// longer used are removed with CollectDictionary.
+	DictionaryFields []DictionaryField[T]
+
+	// OverflowThreshold enables the overflow of the huge rows. The rows whose
+	// serialized size is above the threshold are stored in the OverflowStore
+	// and the references to them in their place, so the occasional huge rows
+	// do not inflate the blocks and the compactions of the table. The rows are
+	// read back on every read. The values are split into chunks of
+	// OverflowChunkSize stored in the database if OverflowStore is not set,
+	// the chunks no longer used are removed with CollectOverflow. The overflow
+	// must not be enabled for the table with existing rows that end with the
+	// overflow reference magic.
+	OverflowThreshold int
+	OverflowStore     OverflowStore
+
+	// SequentialPrefetch enables the prefetch of the pages of the sequential
+	// pagination. Once the query with After continues after the last row of
+	// the page returned by the previous query with the limit, the page that
+	// follows is read in the background, so the index entries and the rows
+	// are in the block cache and the row cache when it's asked for.
+	SequentialPrefetch bool
+
+	// PartitionFunc enables the partitioning of the table. The partition of
+	// the row is the first field of its primary key, so the rows of every
+	// partition are stored under their own prefix, the partition is dropped
+	// with DropPartition and the partitions are scanned in parallel with
+	// ScanPartitions. The partition must be computed from the fields the
+	// selectors of Get and the point reads carry, e.g. the time of the event
+	// with PartitionByTime.
+	PartitionFunc func(tr T) PartitionID
+
+	// IDGenerator assigns the IDs to the rows inserted with the zero ID, e.g.
+	// NewULIDGenerator, NewKSUIDGenerator or NewSnowflakeGenerator. Insert
+	// sets the IDs of the rows in the given slice.
+	IDGenerator IDGenerator[T]
+
+	// Versioned enables the row versions. Every write of the row gives it the
+	// new version, which is read with GetWithVersion and checked by
+	// UpdateIfVersion. The rows written before the table was versioned have
+	// the version 0.
+	Versioned bool
+
+	// DefaultsFunc sets the default values of the row, e.g. the values
+	// derived from the other fields. ValidateFunc returns the error if the
+	// row breaks the invariants of the table, the write of the rows fails
+	// with the error wrapped. They are called for the rows written with
+	// Insert, Update, Upsert and UnsafeUpdate before they are serialized, the
+	// defaults are set in the rows of the given slice. The rows that Upsert
+	// merges with the existing ones are validated after the merge.
+	DefaultsFunc func(tr *T)
+	ValidateFunc func(tr T) error
+
+	// ArchiveAfter enables the archival of the rows. The rows whose time,
+	// returned by ArchiveTimeFunc, is older than ArchiveAfter are moved by
+	// Archive to the ArchiveSink. If ArchiveStubs is set the stubs of the
+	// archived rows are kept, so Get reads the archived rows from the sink.
+	ArchiveAfter    time.Duration
+	ArchiveTimeFunc func(tr T) time.Time
+	ArchiveSink     ArchiveSink
+	ArchiveStubs    bool
+
+	// Retention trims the oldest rows of the table in the background, e.g.
+	// of the log-like tables that must not grow unbounded.
+	Retention *Retention[T]
 }
 
 type _table[T any] struct {
 	id   TableID
 	name string
 
+	// storage is the ID the keys of the table are stored under
+	storage *_tableStorage
+
 	db DB
 
 	primaryKeyFunc TablePrimaryKeyFunc[T]
 
 	primaryIndex     *Index[T]
 	secondaryIndexes map[IndexID]*Index[T]
+	indexBuilds      map[IndexID]*_indexBuild[T]
 
 	serializer Serializer[*T]
+	fieldCodec FieldCodec[T]
+
+	deltaCodec    DeltaCodec[T]
+	deltaMaxChain int
+
+	columnGroups map[ColumnGroupID]*_columnGroup[T]
+
+	dictionary       *_dictionary
+	dictionaryFields []DictionaryField[T]
+	overflowChunks   *_overflowChunks
+
+	sequentialPrefetch *_sequentialPrefetch
+
+	partitioned bool
+
+	idGenerator IDGenerator[T]
+
+	versions *_versionSequence
+
+	defaultsFunc func(tr *T)
+	validateFunc func(tr T) error
+
+	archive *_archivePolicy[T]
+
+	retention *Retention[T]
+
+	quota *_tableQuota
 
 	filter Filter
 
+	scanPrefetchSize           int
+	scanPrefetchMaxConcurrency int
+
+	writeConcurrency int
+
+	faultInjector FaultInjector
+
+	clock Clock
+
+	cache      *_rowCache[T]
+	queryCache *_queryCache[T]
+
+	catalog *_catalog
+
+	writeHooks []_writeHook[T]
+
+	loads _loads[T]
+
+	// writeMutex is held for reading by the writes and for writing by the
+	// index backfill and the conditional updates.
+	writeMutex sync.RWMutex
+
 	mutex sync.RWMutex
 }
 
+// NewTable creates the table. It panics if the table can not be registered
+// on the database, use RegisterTable to handle the error instead.
 func NewTable[T any](opt TableOptions[T]) Table[T] {
+	table, err := RegisterTable(opt)
+	if err != nil {
+		panic(err)
+	}
+	return table
+}
+
+// RegisterTable creates the table and registers it on the database. It returns
+// an error if the table ID is reserved for bond or already used by another table.
+func RegisterTable[T any](opt TableOptions[T]) (Table[T], error) {
+	if opt.PartitionFunc != nil {
+		opt.TablePrimaryKeyFunc = partitionedPrimaryKeyFunc(opt.PartitionFunc, opt.TablePrimaryKeyFunc)
+	}
+
+	table, err := newTable(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	if db, ok := opt.DB.(*_db); ok {
+		fingerprint, _ := keyFingerprint(func(builder KeyBuilder) []byte {
+			return table.primaryKeyFunc(builder, utils.MakeNew[T]())
+		})
+
+		err := db.catalog.registerTable(opt.TableID, opt.TableName, fingerprint, serializerName(opt))
+		if err != nil {
+			return nil, err
+		}
+
+		table.catalog = db.catalog
+		db.onCommitIntentReplayed(opt.TableID, table.reloadReplayed)
+	}
+
+	if table.retention != nil {
+		table.startRetention()
+	}
+
+	return table, nil
+}
+
+func newTable[T any](opt TableOptions[T]) (*_table[T], error) {
 	var serializer Serializer[*T] = &SerializerAnyWrapper[*T]{Serializer: opt.DB.Serializer()}
 	if opt.Serializer != nil {
 		serializer = opt.Serializer
 	}
 
-	// TODO: check if id == 0, and if so, return error that its reserved for bond
+	storage := newTableStorage(opt.TableID)
+	if db, ok := opt.DB.(*_db); ok {
+		storage = db.catalog.storage(opt.TableID)
+	}
+
+	var dictionary *_dictionary
+	if len(opt.DictionaryFields) > 0 {
+		var err error
+		dictionary, err = newDictionary(opt.DB, storage.ID(), len(opt.DictionaryFields))
+		if err != nil {
+			return nil, err
+		}
+
+		serializer = &_dictionarySerializer[T]{Serializer: serializer, Fields: opt.DictionaryFields, dictionary: dictionary}
+	}
+
+	if opt.FieldCodec != nil {
+		serializer = &FieldCodecSerializer[T]{Serializer: serializer, Codec: opt.FieldCodec}
+	}
+
+	if opt.Checksum {
+		serializer = &ChecksumSerializer[*T]{Serializer: serializer}
+	}
+
+	// the column group rows are not written as deltas
+	columnGroups, err := newColumnGroups(opt.ColumnGroups, serializer)
+	if err != nil {
+		return nil, err
+	}
+
+	// the column group rows are not overflowed, the deltas are appended to
+	// the overflow references
+	var overflowChunks *_overflowChunks
+	if opt.OverflowThreshold > 0 {
+		overflowStore := opt.OverflowStore
+		if overflowStore == nil {
+			overflowChunks = newOverflowChunks(opt.DB, storage)
+			overflowStore = overflowChunks
+		}
+
+		serializer = &_overflowSerializer[T]{Serializer: serializer, Threshold: opt.OverflowThreshold, Store: overflowStore, table: opt.TableName}
+	}
+
+	if opt.DeltaCodec != nil {
+		serializer = &_deltaSerializer[T]{Serializer: serializer, Codec: opt.DeltaCodec}
+	}
+
+	deltaMaxChain := opt.DeltaMaxChain
+	if deltaMaxChain <= 0 {
+		deltaMaxChain = DefaultDeltaMaxChain
+	}
+
+	scanPrefetchMaxConcurrency := opt.ScanPrefetchMaxConcurrency
+	if scanPrefetchMaxConcurrency <= 0 {
+		scanPrefetchMaxConcurrency = runtime.GOMAXPROCS(0)
+	}
 
 	table := &_table[T]{
 		db:             opt.DB,
 		id:             opt.TableID,
 		name:           opt.TableName,
+		storage:        storage,
 		primaryKeyFunc: opt.TablePrimaryKeyFunc,
 		primaryIndex: NewIndex(IndexOptions[T]{
 			IndexID:        PrimaryIndexID,
@@ -152,13 +466,80 @@ func NewTable[T any](opt TableOptions[T]) Table[T] {
 			IndexKeyFunc:   primaryIndexKey[T],
 			IndexOrderFunc: IndexOrderDefault[T],
 		}),
-		secondaryIndexes: make(map[IndexID]*Index[T]),
-		serializer:       serializer,
-		filter:           opt.Filter,
-		mutex:            sync.RWMutex{},
+		secondaryIndexes:           make(map[IndexID]*Index[T]),
+		indexBuilds:                make(map[IndexID]*_indexBuild[T]),
+		serializer:                 serializer,
+		fieldCodec:                 opt.FieldCodec,
+		deltaCodec:                 opt.DeltaCodec,
+		deltaMaxChain:              deltaMaxChain,
+		columnGroups:               columnGroups,
+		partitioned:                opt.PartitionFunc != nil,
+		idGenerator:                opt.IDGenerator,
+		defaultsFunc:               opt.DefaultsFunc,
+		validateFunc:               opt.ValidateFunc,
+		dictionary:                 dictionary,
+		dictionaryFields:           opt.DictionaryFields,
+		overflowChunks:             overflowChunks,
+		quota:                      newTableQuota(opt),
+		filter:                     opt.Filter,
+		scanPrefetchSize:           opt.ScanPrefetchSize,
+		scanPrefetchMaxConcurrency: scanPrefetchMaxConcurrency,
+		mutex:                      sync.RWMutex{},
 	}
 
-	return table
+	if db, ok := opt.DB.(*_db); ok {
+		table.writeConcurrency = db.writeConcurrency
+		table.faultInjector = db.faultInjector
+	}
+	table.clock = clockOf(opt.DB)
+
+	if opt.CacheSize > 0 {
+		table.cache = newRowCache[T](opt.CacheSize)
+	}
+
+	if opt.QueryCacheSize > 0 {
+		table.queryCache = newQueryCache[T](opt.QueryCacheSize)
+	}
+
+	if opt.SequentialPrefetch {
+		table.sequentialPrefetch = newSequentialPrefetch(opt.DB)
+	}
+
+	if opt.ArchiveAfter > 0 {
+		if opt.ArchiveTimeFunc == nil || opt.ArchiveSink == nil {
+			return nil, fmt.Errorf("table %s: archival needs the ArchiveTimeFunc and the ArchiveSink", opt.TableName)
+		}
+
+		table.archive = &_archivePolicy[T]{
+			after:    opt.ArchiveAfter,
+			timeFunc: opt.ArchiveTimeFunc,
+			sink:     opt.ArchiveSink,
+			stubs:    opt.ArchiveStubs,
+		}
+	}
+
+	if opt.Retention != nil {
+		table.retention, err = newRetention(opt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(columnGroups) > 0 {
+		table.writeHooks = append(table.writeHooks, table.updateColumnGroups)
+	}
+
+	if opt.Versioned {
+		versions, err := newVersionSequence(opt.DB, storage.ID())
+		if err != nil {
+			return nil, err
+		}
+
+		table.versions = versions
+		table.writeHooks = append(table.writeHooks, table.updateVersions)
+	}
+
+	return table, nil
 }
 
 func (t *_table[T]) ID() TableID {
@@ -209,94 +590,41 @@ func (t *_table[T]) Serializer() Serializer[*T] {
 	return t.serializer
 }
 
-func (t *_table[T]) AddIndex(idxs []*Index[T], reIndex ...bool) error {
-	t.mutex.Lock()
-	for _, idx := range idxs {
-		t.secondaryIndexes[idx.IndexID] = idx
-	}
-	t.mutex.Unlock()
-
-	if len(reIndex) > 0 && reIndex[0] {
-		return t.reindex(idxs)
-	}
-	return nil
+func (t *_table[T]) database() DB {
+	return t.db
 }
 
-func (t *_table[T]) reindex(idxs []*Index[T]) error {
-	idxsMap := make(map[IndexID]*Index[T])
-	for _, idx := range idxs {
-		idxsMap[idx.IndexID] = idx
-		err := t.db.DeleteRange(
-			[]byte{byte(t.id), byte(idx.IndexID)},
-			[]byte{byte(t.id), byte(idx.IndexID + 1)}, Sync)
-		if err != nil {
-			return fmt.Errorf("failed to delete index: %w", err)
-		}
+func (t *_table[T]) AddIndex(idxs []*Index[T], reIndex ...bool) error {
+	if len(reIndex) > 0 && reIndex[0] {
+		return t.buildIndexes(idxs)
 	}
 
-	var prefixBuffer [DataKeyBufferSize]byte
-	prefix := t.keyPrefix(t.primaryIndex, utils.MakeNew[T](), prefixBuffer[:0])
-
-	iter := t.db.Iter(&IterOptions{
-		IterOptions: pebble.IterOptions{
-			LowerBound: prefix,
-		},
-	})
-
-	batch := t.db.Batch()
-	defer func() {
-		_ = batch.Close()
-	}()
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
 
-	counter := 0
-	indexKeysBuffer := make([]byte, 0, (PrimaryKeyBufferSize+IndexKeyBufferSize)*len(idxs))
-	indexKeys := make([][]byte, 0, len(t.secondaryIndexes))
+	if err := t.checkIndexes(idxs); err != nil {
+		return err
+	}
 
-	for iter.SeekPrefixGE(prefix); iter.Valid(); iter.Next() {
-		var tr T
+	if err := t.registerIndexes(idxs); err != nil {
+		return err
+	}
 
-		err := t.serializer.Deserialize(iter.Value(), &tr)
+	for _, idx := range idxs {
+		writeHooks, err := t.initIndex(idx)
 		if err != nil {
-			return fmt.Errorf("failed to deserialize during reindexing: %w", err)
-		}
-
-		indexKeys = t.indexKeys(tr, idxsMap, indexKeysBuffer[:0], indexKeys[:0])
-
-		for _, indexKey := range indexKeys {
-			err = batch.Set(indexKey, []byte{}, Sync)
-			if err != nil {
-				return fmt.Errorf("failed to set index key during reindexing: %w", err)
-			}
-		}
-
-		counter++
-		if counter >= ReindexBatchSize {
-			counter = 0
-
-			err = batch.Commit(Sync)
-			if err != nil {
-				return fmt.Errorf("failed to commit reindex batch: %w", err)
-			}
-
-			batch = t.db.Batch()
+			return err
 		}
-	}
 
-	err := batch.Commit(Sync)
-	if err != nil {
-		return fmt.Errorf("failed to commit reindex batch: %w", err)
+		t.secondaryIndexes[idx.IndexID] = idx
+		t.writeHooks = append(t.writeHooks, writeHooks...)
 	}
-
-	_ = iter.Close()
-
 	return nil
 }
 
 func (t *_table[T]) Insert(ctx context.Context, trs []T, optBatch ...Batch) error {
-	t.mutex.RLock()
-	indexes := make(map[IndexID]*Index[T])
-	maps.Copy(indexes, t.secondaryIndexes)
-	t.mutex.RUnlock()
+	indexes, writeHooks, endWrite := t.beginWrite()
+	defer endWrite()
 
 	var (
 		keyBatch      Batch
@@ -321,41 +649,106 @@ func (t *_table[T]) Insert(ctx context.Context, trs []T, optBatch ...Batch) erro
 	var (
 		keyBuffer       [DataKeyBufferSize]byte
 		indexKeysBuffer = make([]byte, 0, (PrimaryKeyBufferSize+IndexKeyBufferSize)*len(indexes))
-		indexKeys       = make([][]byte, 0, len(t.secondaryIndexes))
+		indexKeys       = make([][]byte, 0, len(indexes))
 	)
 
-	for _, tr := range trs {
+	var invalidation _cacheInvalidation
+	var written int
+	var changes []_rowChange[T]
+
+	err := t.assignIDs(trs)
+	if err != nil {
+		return err
+	}
+
+	err = t.defaultAndValidate(trs)
+	if err != nil {
+		return err
+	}
+
+	// check if exist before anything is written to the batch
+	err = t.checkKeysNotExist(trs, keyBatch)
+	if err != nil {
+		return err
+	}
+
+	// serialize and compute keys concurrently, by chunks of the rows, as the
+	// batch keeps the copies of the data
+	var preparedRows []_preparedRow
+	concurrent := t.writeConcurrency > 1 && len(trs) > 1
+
+	for i, tr := range trs {
 		select {
 		case <-ctx.Done():
 			return fmt.Errorf("context done: %w", ctx.Err())
 		default:
 		}
 
-		// insert key
-		key := t.key(tr, keyBuffer[:0])
+		if concurrent && i%prepareRowsChunkSize == 0 {
+			end := i + prepareRowsChunkSize
+			if end > len(trs) {
+				end = len(trs)
+			}
+			preparedRows = t.prepareRows(ctx, trs[i:end], indexes)
+		}
 
-		// check if exist
-		if t.exist(key, keyBatch) {
-			return fmt.Errorf("record: %x already exist", key[_KeyPrefixSplitIndex(key):])
+		var (
+			key  []byte
+			data []byte
+			err  error
+		)
+		if concurrent {
+			preparedRow := preparedRows[i%prepareRowsChunkSize]
+			if preparedRow.err != nil {
+				return preparedRow.err
+			}
+			key, data, indexKeys = preparedRow.key, preparedRow.data, preparedRow.indexKeys
+
+			if preparedRow.write != nil {
+				err = preparedRow.write(keyBatch)
+				if err != nil {
+					return err
+				}
+			}
+		} else {
+			key = t.key(tr, keyBuffer[:0])
 		}
 
-		// serialize
-		data, err := t.serializer.Serialize(&tr)
-		if err != nil {
-			return err
+		t.collectInvalidation(&invalidation, key, indexes, tr)
+		if len(writeHooks) > 0 {
+			changes = append(changes, _rowChange[T]{new: tr, hasNew: true})
+		}
+
+		if !concurrent {
+			// serialize
+			data, err = t.serialize(&tr, keyBatch)
+			if err != nil {
+				return err
+			}
+
+			// index keys
+			indexKeys = t.indexKeys(tr, indexes, indexKeysBuffer[:0], indexKeys[:0])
 		}
 
 		err = keyBatch.Set(key, data, Sync)
 		if err != nil {
 			return err
 		}
+		written += len(key) + len(data)
 
-		// index keys
-		indexKeys = t.indexKeys(tr, indexes, indexKeysBuffer[:0], indexKeys[:0])
+		err = t.injectFault(FaultPointPostSerialize, key)
+		if err != nil {
+			return err
+		}
 
 		// update indexes
 		for _, indexKey := range indexKeys {
-			err = indexKeyBatch.Set(indexKey, []byte{}, Sync)
+			err = t.injectFault(FaultPointIndexUpdate, indexKey)
+			if err != nil {
+				return err
+			}
+
+			err = indexKeyBatch.Set(indexKey, t.indexEntryValue(indexKey, indexes, tr), Sync)
 			if err != nil {
 				return err
 			}
@@ -366,26 +759,82 @@ func (t *_table[T]) Insert(ctx context.Context, trs []T, optBatch ...Batch) erro
 		}
 	}
 
-	err := keyBatch.Apply(indexKeyBatch, Sync)
+	err = t.runWriteHooks(ctx, writeHooks, keyBatch, changes)
+	if err != nil {
+		return err
+	}
+
+	reservation, err := t.reserveQuota(ctx, keyBatch, externalBatch, len(trs), written)
+	if err != nil {
+		return err
+	}
+	defer reservation.release()
+
+	// abort before the writes are applied
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	err = keyBatch.Apply(indexKeyBatch, Sync)
 	if err != nil {
 		return err
 	}
 
 	if !externalBatch {
-		err = keyBatch.Commit(Sync)
+		err = keyBatch.Commit(ContextRetrieveWriteOptions(ctx))
 		if err != nil {
 			return err
 		}
 	}
 
+	reservation.keep()
+
+	t.invalidateCache(invalidation, keyBatch, externalBatch)
+
+	return nil
+}
+
+// checkKeysNotExist checks that the keys of the rows do not exist and do not
+// repeat within the rows.
+func (t *_table[T]) checkKeysNotExist(trs []T, batch Batch) error {
+	var keyBuffer [DataKeyBufferSize]byte
+
+	keys := make(map[string]struct{}, len(trs))
+	for _, tr := range trs {
+		key, err := t.safeKey(tr, keyBuffer[:0])
+		if err != nil {
+			return err
+		}
+
+		if _, ok := keys[string(key)]; ok {
+			return t.newError(nil, key, fmt.Errorf("%w within inserted rows", ErrKeyExists))
+		}
+
+		if t.exist(key, batch) {
+			return t.newError(nil, key, ErrKeyExists)
+		}
+
+		keys[string(key)] = struct{}{}
+	}
+
 	return nil
 }
 
 func (t *_table[T]) Update(ctx context.Context, trs []T, optBatch ...Batch) error {
-	t.mutex.RLock()
-	indexes := make(map[IndexID]*Index[T])
-	maps.Copy(indexes, t.secondaryIndexes)
-	t.mutex.RUnlock()
+	indexes, writeHooks, endWrite := t.beginWrite()
+	defer endWrite()
+
+	return t.update(ctx, trs, indexes, writeHooks, optBatch...)
+}
+
+// update writes the new versions of the rows with the indexes and the write
+// hooks of the write.
+func (t *_table[T]) update(ctx context.Context, trs []T, indexes map[IndexID]*Index[T], writeHooks []_writeHook[T], optBatch ...Batch) error {
+	if err := t.defaultAndValidate(trs); err != nil {
+		return err
+	}
 
 	var (
 		keyBatch      Batch
@@ -410,6 +859,10 @@ func (t *_table[T]) Update(ctx context.Context, trs []T, optBatch ...Batch) erro
 		indexKeyBuffer = make([]byte, DataKeyBufferSize*len(indexes)*2)
 	)
 
+	var invalidation _cacheInvalidation
+	var written int
+	var changes []_rowChange[T]
+
 	for _, tr := range trs {
 		select {
 		case <-ctx.Done():
@@ -423,25 +876,31 @@ func (t *_table[T]) Update(ctx context.Context, trs []T, optBatch ...Batch) erro
 		// old record
 		oldTrData, closer, err := keyBatch.Get(key)
 		if err != nil {
-			return err
+			return t.newError(nil, key, fmt.Errorf("failed to get record: %w", notFound(err)))
 		}
 
 		var oldTr T
-		err = t.serializer.Deserialize(oldTrData, &oldTr)
+		err = t.deserialize(oldTrData, &oldTr, keyBatch)
 		if err != nil {
-			return err
+			return t.newError(nil, key, fmt.Errorf("failed to deserialize record: %w", err))
 		}
 
+		chain := deltaChainLength(oldTrData)
 		_ = closer.Close()
 
-		// serialize
-		data, err := t.serializer.Serialize(&tr)
+		t.collectInvalidation(&invalidation, key, indexes, tr, oldTr)
+		if len(writeHooks) > 0 {
+			changes = append(changes, _rowChange[T]{old: oldTr, new: tr, hasOld: true, hasNew: true})
+		}
+
+		// update entry
+		n, err := t.setRow(keyBatch, key, chain, oldTr, tr)
 		if err != nil {
 			return err
 		}
+		written += n
 
-		// update entry
-		err = keyBatch.Set(key, data, Sync)
+		err = t.injectFault(FaultPointPostSerialize, key)
 		if err != nil {
 			return err
 		}
@@ -451,7 +910,12 @@ func (t *_table[T]) Update(ctx context.Context, trs []T, optBatch ...Batch) erro
 
 		// update indexes
 		for _, indexKey := range toAddIndexKeys {
-			err = indexKeyBatch.Set(indexKey, []byte{}, Sync)
+			err = t.injectFault(FaultPointIndexUpdate, indexKey)
+			if err != nil {
+				return err
+			}
+
+			err = indexKeyBatch.Set(indexKey, t.indexEntryValue(indexKey, indexes, tr), Sync)
 			if err != nil {
 				return err
 			}
@@ -465,26 +929,46 @@ func (t *_table[T]) Update(ctx context.Context, trs []T, optBatch ...Batch) erro
 		}
 	}
 
-	err := keyBatch.Apply(indexKeyBatch, Sync)
+	err := t.runWriteHooks(ctx, writeHooks, keyBatch, changes)
+	if err != nil {
+		return err
+	}
+
+	reservation, err := t.reserveQuota(ctx, keyBatch, externalBatch, len(trs), written)
+	if err != nil {
+		return err
+	}
+	defer reservation.release()
+
+	// abort before the writes are applied
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	err = keyBatch.Apply(indexKeyBatch, Sync)
 	if err != nil {
 		return err
 	}
 
 	if !externalBatch {
-		err = keyBatch.Commit(Sync)
+		err = keyBatch.Commit(ContextRetrieveWriteOptions(ctx))
 		if err != nil {
 			return err
 		}
 	}
 
+	reservation.keep()
+
+	t.invalidateCache(invalidation, keyBatch, externalBatch)
+
 	return nil
 }
 
 func (t *_table[T]) Delete(ctx context.Context, trs []T, optBatch ...Batch) error {
-	t.mutex.RLock()
-	indexes := make(map[IndexID]*Index[T])
-	maps.Copy(indexes, t.secondaryIndexes)
-	t.mutex.RUnlock()
+	indexes, writeHooks, endWrite := t.beginWrite()
+	defer endWrite()
 
 	var (
 		keyBatch      Batch
@@ -510,15 +994,45 @@ func (t *_table[T]) Delete(ctx context.Context, trs []T, optBatch ...Batch) erro
 		indexKeys      = make([][]byte, len(indexes))
 	)
 
-	for _, tr := range trs {
+	var invalidation _cacheInvalidation
+	var changes []_rowChange[T]
+
+	// compute keys concurrently, by chunks of the rows, as the batch keeps
+	// the copies of the keys
+	var preparedRows []_preparedRow
+	concurrent := t.writeConcurrency > 1 && len(trs) > 1
+
+	for i, tr := range trs {
 		select {
 		case <-ctx.Done():
 			return fmt.Errorf("context done: %w", ctx.Err())
 		default:
 		}
 
-		var key = t.key(tr, keyBuffer[:0])
-		indexKeys = t.indexKeys(tr, indexes, indexKeyBuffer[:0], indexKeys[:0])
+		if concurrent && i%prepareRowsChunkSize == 0 {
+			end := i + prepareRowsChunkSize
+			if end > len(trs) {
+				end = len(trs)
+			}
+			preparedRows = t.prepareRowKeys(ctx, trs[i:end], indexes)
+		}
+
+		var key []byte
+		if concurrent {
+			preparedRow := preparedRows[i%prepareRowsChunkSize]
+			if preparedRow.err != nil {
+				return preparedRow.err
+			}
+			key, indexKeys = preparedRow.key, preparedRow.indexKeys
+		} else {
+			key = t.key(tr, keyBuffer[:0])
+			indexKeys = t.indexKeys(tr, indexes, indexKeyBuffer[:0], indexKeys[:0])
+		}
+
+		t.collectInvalidation(&invalidation, key, indexes, tr)
+		if len(writeHooks) > 0 {
+			changes = append(changes, _rowChange[T]{old: tr, hasOld: true})
+		}
 
 		err := keyBatch.Delete(key, Sync)
 		if err != nil {
@@ -526,6 +1040,11 @@ func (t *_table[T]) Delete(ctx context.Context, trs []T, optBatch ...Batch) erro
 		}
 
 		for _, indexKey := range indexKeys {
+			err = t.injectFault(FaultPointIndexUpdate, indexKey)
+			if err != nil {
+				return err
+			}
+
 			err = keyBatch.Delete(indexKey, Sync)
 			if err != nil {
 				return err
@@ -533,26 +1052,56 @@ func (t *_table[T]) Delete(ctx context.Context, trs []T, optBatch ...Batch) erro
 		}
 	}
 
-	err := keyBatch.Apply(indexKeyBatch, Sync)
+	err := t.runWriteHooks(ctx, writeHooks, keyBatch, changes)
+	if err != nil {
+		return err
+	}
+
+	// abort before the writes are applied
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	err = keyBatch.Apply(indexKeyBatch, Sync)
 	if err != nil {
 		return err
 	}
 
 	if !externalBatch {
-		err = keyBatch.Commit(Sync)
+		err = keyBatch.Commit(ContextRetrieveWriteOptions(ctx))
 		if err != nil {
 			return err
 		}
 	}
 
+	t.invalidateCache(invalidation, keyBatch, externalBatch)
+
 	return nil
 }
 
 func (t *_table[T]) Upsert(ctx context.Context, trs []T, onConflict func(old, new T) T, optBatch ...Batch) error {
-	t.mutex.RLock()
-	indexes := make(map[IndexID]*Index[T])
-	maps.Copy(indexes, t.secondaryIndexes)
-	t.mutex.RUnlock()
+	_, err := t.upsert(ctx, trs, onConflict, false, optBatch...)
+	return err
+}
+
+// UpsertReturning replaces the rows and inserts the new ones, like Upsert with
+// TableUpsertOnConflictReplace. It returns the replaced versions of the rows,
+// read within the same batch as they are replaced, with the zero value for
+// the rows that were new. The returned rows are decoded with the field codec
+// key from the context.
+func (t *_table[T]) UpsertReturning(ctx context.Context, trs []T, optBatch ...Batch) ([]T, error) {
+	return t.upsert(ctx, trs, TableUpsertOnConflictReplace[T], true, optBatch...)
+}
+
+// upsert writes the rows, the old versions of the rows are returned if
+// returnOld is set.
+func (t *_table[T]) upsert(ctx context.Context, trs []T, onConflict func(old, new T) T, returnOld bool, optBatch ...Batch) ([]T, error) {
+	t.applyDefaults(trs)
+
+	indexes, writeHooks, endWrite := t.beginWrite()
+	defer endWrite()
 
 	var (
 		keyBatch      Batch
@@ -581,10 +1130,19 @@ func (t *_table[T]) Upsert(ctx context.Context, trs []T, onConflict func(old, ne
 		indexKeys = make([][]byte, 0, len(indexes))
 	)
 
-	for _, tr := range trs {
+	var invalidation _cacheInvalidation
+	var written int
+	var changes []_rowChange[T]
+
+	var oldTrs []T
+	if returnOld {
+		oldTrs = make([]T, len(trs))
+	}
+
+	for i, tr := range trs {
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("context done: %w", ctx.Err())
+			return nil, fmt.Errorf("context done: %w", ctx.Err())
 		default:
 		}
 
@@ -596,16 +1154,18 @@ func (t *_table[T]) Upsert(ctx context.Context, trs []T, onConflict func(old, ne
 			oldTr     T
 			oldTrData []byte
 			closer    io.Closer
+			chain     int
 			err       error
 		)
 		if t.exist(key, keyBatch) {
 			oldTrData, closer, err = keyBatch.Get(key)
 			if err == nil {
-				err = t.serializer.Deserialize(oldTrData, &oldTr)
+				err = t.deserialize(oldTrData, &oldTr, keyBatch)
 				if err != nil {
-					return err
+					return nil, t.newError(nil, key, fmt.Errorf("failed to deserialize record: %w", err))
 				}
 
+				chain = deltaChainLength(oldTrData)
 				_ = closer.Close()
 			}
 		}
@@ -613,19 +1173,51 @@ func (t *_table[T]) Upsert(ctx context.Context, trs []T, onConflict func(old, ne
 		// handle upsert
 		isUpdate := oldTrData != nil && len(oldTrData) > 0
 		if isUpdate {
+			if returnOld {
+				oldTrs[i], err = t.decodeFields(ctx, oldTr)
+				if err != nil {
+					return nil, err
+				}
+			}
+
 			tr = onConflict(oldTr, tr)
+			t.collectInvalidation(&invalidation, key, indexes, tr, oldTr)
+		} else {
+			t.collectInvalidation(&invalidation, key, indexes, tr)
 		}
 
-		// serialize
-		data, err := t.serializer.Serialize(&tr)
+		err = t.validateRow(tr)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		if len(writeHooks) > 0 {
+			changes = append(changes, _rowChange[T]{old: oldTr, new: tr, hasOld: isUpdate, hasNew: true})
 		}
 
 		// update entry
-		err = keyBatch.Set(key, data, Sync)
+		if isUpdate {
+			n, err := t.setRow(keyBatch, key, chain, oldTr, tr)
+			if err != nil {
+				return nil, err
+			}
+			written += n
+		} else {
+			data, err := t.serialize(&tr, keyBatch)
+			if err != nil {
+				return nil, err
+			}
+
+			err = keyBatch.Set(key, data, Sync)
+			if err != nil {
+				return nil, err
+			}
+			written += len(key) + len(data)
+		}
+
+		err = t.injectFault(FaultPointPostSerialize, key)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		// indexKeys to add and remove
@@ -642,16 +1234,21 @@ func (t *_table[T]) Upsert(ctx context.Context, trs []T, onConflict func(old, ne
 
 		// update indexes
 		for _, indexKey := range toAddIndexKeys {
-			err = indexKeyBatch.Set(indexKey, []byte{}, Sync)
+			err = t.injectFault(FaultPointIndexUpdate, indexKey)
 			if err != nil {
-				return err
+				return nil, err
+			}
+
+			err = indexKeyBatch.Set(indexKey, t.indexEntryValue(indexKey, indexes, tr), Sync)
+			if err != nil {
+				return nil, err
 			}
 		}
 
 		for _, indexKey := range toRemoveIndexKeys {
 			err = indexKeyBatch.Delete(indexKey, Sync)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
 
@@ -660,19 +1257,41 @@ func (t *_table[T]) Upsert(ctx context.Context, trs []T, onConflict func(old, ne
 		}
 	}
 
-	err := keyBatch.Apply(indexKeyBatch, Sync)
+	err := t.runWriteHooks(ctx, writeHooks, keyBatch, changes)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	reservation, err := t.reserveQuota(ctx, keyBatch, externalBatch, len(trs), written)
+	if err != nil {
+		return nil, err
+	}
+	defer reservation.release()
+
+	// abort before the writes are applied
+	select {
+	case <-ctx.Done():
+		return nil, fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	err = keyBatch.Apply(indexKeyBatch, Sync)
+	if err != nil {
+		return nil, err
 	}
 
 	if !externalBatch {
-		err = keyBatch.Commit(Sync)
+		err = keyBatch.Commit(ContextRetrieveWriteOptions(ctx))
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	reservation.keep()
+
+	t.invalidateCache(invalidation, keyBatch, externalBatch)
+
+	return oldTrs, nil
 }
 
 func (t *_table[T]) Exist(tr T, optBatch ...Batch) bool {
@@ -703,6 +1322,8 @@ func (t *_table[T]) exist(key []byte, batch Batch) bool {
 	return true
 }
 
+// Get retrieves the row of the selector. The error matches ErrNotFound with
+// errors.Is if the row does not exist.
 func (t *_table[T]) Get(tr T, optBatch ...Batch) (T, error) {
 	var batch Batch
 	if len(optBatch) > 0 && optBatch[0] != nil {
@@ -716,24 +1337,154 @@ func (t *_table[T]) Get(tr T, optBatch ...Batch) (T, error) {
 
 	bCtx := ContextWithBatch(context.Background(), batch)
 	if t.filter != nil && !t.filter.MayContain(bCtx, key) {
-		return utils.MakeNew[T](), fmt.Errorf("not found")
+		return utils.MakeNew[T](), t.newError(nil, key, ErrNotFound)
 	}
 
 	return t.get(key, batch)
 }
 
+// GetOr retrieves the row of the selector, or returns the default row if it
+// does not exist. The other errors are returned as they are.
+//
+// Example:
+//
+//	tb, err := tokenBalanceTable.GetOr(ctx, &TokenBalance{ID: id}, &TokenBalance{ID: id})
+func (t *_table[T]) GetOr(ctx context.Context, tr T, def T, optBatch ...Batch) (T, error) {
+	select {
+	case <-ctx.Done():
+		return utils.MakeNew[T](), fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	row, err := t.Get(tr, optBatch...)
+	if errors.Is(err, ErrNotFound) {
+		return def, nil
+	}
+	return row, err
+}
+
+// GetByKeys retrieves the rows with given primary keys. The rows are returned in
+// the order of the keys. The lookups are done concurrently unless the batch is
+// provided.
+func (t *_table[T]) GetByKeys(ctx context.Context, keys []PrimaryKey, optBatch ...Batch) ([]T, error) {
+	var batch Batch
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		batch = optBatch[0]
+	}
+
+	trs, _, err := t.getByKeys(ctx, keys, false, batch)
+	return trs, err
+}
+
+// getByKeys retrieves the rows with given primary keys. If skipMissing is set,
+// the missing rows are reported in the found slice instead of the error.
+func (t *_table[T]) getByKeys(ctx context.Context, keys []PrimaryKey, skipMissing bool, batch Batch) ([]T, []bool, error) {
+	select {
+	case <-ctx.Done():
+		return nil, nil, fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	useCache := t.cache != nil && batch == nil
+
+	var generation uint64
+	if useCache {
+		generation = t.cache.Generation()
+	}
+
+	trs := make([]T, len(keys))
+	found := make([]bool, len(keys))
+	entries := make([]*_prefetchEntry, 0, len(keys))
+	entryIndexes := make([]int, 0, len(keys))
+	for i, key := range keys {
+		dataKey := KeyEncode(Key{
+			TableID:    t.storageID(),
+			IndexID:    PrimaryIndexID,
+			IndexKey:   []byte{},
+			IndexOrder: []byte{},
+			PrimaryKey: key,
+		})
+
+		if useCache {
+			if tr, ok := t.cache.Get(dataKey); ok {
+				trs[i] = tr
+				found[i] = true
+				continue
+			}
+		}
+
+		entries = append(entries, &_prefetchEntry{dataKey: dataKey})
+		entryIndexes = append(entryIndexes, i)
+	}
+
+	t.prefetch(entries, batch)
+
+	for i, entry := range entries {
+		if entry.err != nil {
+			if skipMissing && errors.Is(entry.err, ErrNotFound) {
+				continue
+			}
+			return nil, nil, entry.err
+		}
+
+		var tr T
+		err := t.deserialize(entry.value, &tr, batch)
+		if err != nil {
+			return nil, nil, t.newError(nil, entry.dataKey, fmt.Errorf("failed to deserialize: %w", err))
+		}
+
+		if useCache {
+			t.cache.Put(entry.dataKey, tr, generation)
+		}
+
+		trs[entryIndexes[i]] = tr
+		found[entryIndexes[i]] = true
+	}
+
+	for i := range trs {
+		if !found[i] {
+			continue
+		}
+
+		tr, err := t.decodeFields(ctx, trs[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		trs[i] = tr
+	}
+
+	return trs, found, nil
+}
+
 func (t *_table[T]) get(key []byte, batch Batch) (T, error) {
+	useCache := t.cache != nil && batch == nil
+
+	var generation uint64
+	if useCache {
+		if tr, ok := t.cache.Get(key); ok {
+			return tr, nil
+		}
+		generation = t.cache.Generation()
+	}
+
 	data, closer, err := t.db.Get(key, batch)
+	if err != nil && t.archive != nil && t.archive.stubs && errors.Is(err, ErrNotFound) {
+		return t.getArchived(key, batch)
+	}
 	if err != nil {
-		return utils.MakeNew[T](), fmt.Errorf("get failed: %w", err)
+		return utils.MakeNew[T](), t.newError(nil, key, notFound(err))
 	}
 
 	defer func() { _ = closer.Close() }()
 
 	var tr T
-	err = t.serializer.Deserialize(data, &tr)
+	err = t.deserialize(data, &tr, batch)
 	if err != nil {
-		return utils.MakeNew[T](), fmt.Errorf("get failed to deserialize: %w", err)
+		return utils.MakeNew[T](), t.newError(nil, key, fmt.Errorf("failed to deserialize: %w", err))
+	}
+
+	if useCache {
+		t.cache.Put(key, tr, generation)
 	}
 
 	return tr, nil
@@ -744,8 +1495,8 @@ func (t *_table[T]) Iter(opt *IterOptions, optBatch ...Batch) Iterator {
 		opt = &IterOptions{}
 	}
 
-	lower := KeyEncode(Key{TableID: t.id}, nil)
-	upper := KeyEncode(Key{TableID: t.id + 1}, nil)
+	lower := KeyEncode(Key{TableID: t.storageID()}, nil)
+	upper := KeyEncode(Key{TableID: t.storageID() + 1}, nil)
 	opt.LowerBound = lower
 	opt.UpperBound = upper
 
@@ -781,9 +1532,24 @@ func (t *_table[T]) ScanForEach(ctx context.Context, f func(keyBytes KeyBytes, l
 }
 
 func (t *_table[T]) ScanIndexForEach(ctx context.Context, idx *Index[T], s T, f func(keyBytes KeyBytes, t Lazy[T]) (bool, error), optBatch ...Batch) error {
+	return t.scanIndexForEach(ctx, idx, s, func(keyBytes KeyBytes, lazy Lazy[T]) (bool, error) {
+		return f(keyBytes, t.decodeLazy(ctx, lazy))
+	}, false, optBatch...)
+}
+
+// scanIndexForEach iterates over index, the keysOnly disables row prefetching
+// for scans that are not going to read the rows.
+func (t *_table[T]) scanIndexForEach(ctx context.Context, idx *Index[T], s T, f func(keyBytes KeyBytes, t Lazy[T]) (bool, error), keysOnly bool, optBatch ...Batch) error {
+	if err := t.checkIndexRegistered(idx); err != nil {
+		return err
+	}
+
 	var prefixBuffer [DataKeyBufferSize]byte
 
-	selector := t.indexKey(s, idx, prefixBuffer[:0])
+	selector, err := t.safeIndexKey(s, idx, prefixBuffer[:0])
+	if err != nil {
+		return err
+	}
 
 	var iter Iterator
 	var batch Batch
@@ -802,59 +1568,77 @@ func (t *_table[T]) ScanIndexForEach(ctx context.Context, idx *Index[T], s T, f
 		})
 	}
 
-	var getValue func() (T, error)
+	if !keysOnly && idx.IndexID != PrimaryIndexID && t.scanPrefetchSize > 1 {
+		return t.scanIndexForEachPrefetch(ctx, iter, selector, f, batch)
+	}
+
+	var getValueInto func(record *T) error
 	var keyBuffer [DataKeyBufferSize]byte
 	if idx.IndexID == PrimaryIndexID {
-		getValue = func() (T, error) {
-			var record T
-			if err := t.serializer.Deserialize(iter.Value(), &record); err == nil {
-				return record, nil
-			} else {
-				return utils.MakeNew[T](), err
+		getValueInto = func(record *T) error {
+			if err := t.deserialize(iter.Value(), record, batch); err != nil {
+				return t.newError(idx, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
 			}
+			return nil
 		}
 	} else {
-		getValue = func() (T, error) {
+		getValueInto = func(record *T) error {
 			tableKey := KeyBytes(iter.Key()).ToDataKeyBytes(keyBuffer[:0])
 
 			valueData, closer, err := t.db.Get(tableKey, batch)
 			if err != nil {
-				return utils.MakeNew[T](), err
+				return t.newError(idx, iter.Key(), fmt.Errorf("failed to get row %s: %w",
+					FormatKey(tableKey), notFound(err)))
 			}
 
 			defer func() { _ = closer.Close() }()
 
-			var record T
-			if err = t.serializer.Deserialize(valueData, &record); err == nil {
-				return record, nil
-			} else {
-				return utils.MakeNew[T](), err
+			if err := t.deserialize(valueData, record, batch); err != nil {
+				return t.newError(nil, tableKey, fmt.Errorf("failed to deserialize: %w", err))
 			}
+			return nil
+		}
+	}
+
+	getValue := func() (T, error) {
+		var record T
+		if err := getValueInto(&record); err != nil {
+			return utils.MakeNew[T](), err
+		}
+		return record, nil
+	}
+
+	if idx.IndexID != PrimaryIndexID && t.cache != nil && batch == nil {
+		getValue = func() (T, error) {
+			return t.get(KeyBytes(iter.Key()).ToDataKeyBytes(keyBuffer[:0]), nil)
 		}
 	}
 
 	for iter.SeekPrefixGE(selector); iter.Valid(); iter.Next() {
 		select {
 		case <-ctx.Done():
+			_ = iter.Close()
 			return fmt.Errorf("context done: %w", ctx.Err())
 		default:
 		}
 
-		if cont, err := f(iter.Key(), Lazy[T]{getValue}); !cont || err != nil {
-			break
-		} else {
-			if err != nil {
-				_ = iter.Close()
-				return err
-			}
+		lazy := Lazy[T]{GetFunc: getValue, GetIntoFunc: getValueInto}
+		if idx.IndexID != PrimaryIndexID {
+			lazy.indexValue = iter.Value()
+		}
 
-			if !cont {
-				break
-			}
+		cont, err := f(iter.Key(), lazy)
+		if err != nil {
+			_ = iter.Close()
+			return err
+		}
+
+		if !cont {
+			break
 		}
 	}
 
-	err := iter.Close()
+	err = iter.Close()
 	if err != nil {
 		return err
 	}
@@ -866,7 +1650,7 @@ func (t *_table[T]) key(tr T, buff []byte) []byte {
 	var primaryKey = t.primaryKeyFunc(NewKeyBuilder(buff[:0]), tr)
 
 	return KeyEncode(Key{
-		TableID:    t.id,
+		TableID:    t.storageID(),
 		IndexID:    PrimaryIndexID,
 		IndexKey:   []byte{},
 		IndexOrder: []byte{},
@@ -878,7 +1662,7 @@ func (t *_table[T]) keyPrefix(idx *Index[T], s T, buff []byte) []byte {
 	indexKey := idx.IndexKeyFunction(NewKeyBuilder(buff[:0]), s)
 
 	return KeyEncode(Key{
-		TableID:    t.id,
+		TableID:    t.storageID(),
 		IndexID:    idx.IndexID,
 		IndexKey:   indexKey,
 		IndexOrder: []byte{},
@@ -894,7 +1678,7 @@ func (t *_table[T]) indexKey(tr T, idx *Index[T], buff []byte) []byte {
 	).Bytes()
 
 	return KeyEncode(Key{
-		TableID:    t.id,
+		TableID:    t.storageID(),
 		IndexID:    idx.IndexID,
 		IndexKey:   indexKeyPart,
 		IndexOrder: orderKeyPart,
@@ -915,6 +1699,20 @@ func (t *_table[T]) indexKeys(tr T, idxs map[IndexID]*Index[T], buff []byte, ind
 	return indexKeys
 }
 
+// safeIndexKey is indexKey that returns the panic of the index functions as
+// the error.
+func (t *_table[T]) safeIndexKey(tr T, idx *Index[T], buff []byte) (key []byte, err error) {
+	defer t.recoverPanic(&err, idx, nil, "index key")
+	return t.indexKey(tr, idx, buff), nil
+}
+
+// safeIndexKeys is indexKeys that returns the panic of the index functions as
+// the error with the key of the row.
+func (t *_table[T]) safeIndexKeys(tr T, key []byte, idxs map[IndexID]*Index[T], buff []byte, indexKeysBuff [][]byte) (indexKeys [][]byte, err error) {
+	defer t.recoverPanic(&err, nil, key, "index key")
+	return t.indexKeys(tr, idxs, buff, indexKeysBuff), nil
+}
+
 func (t *_table[T]) indexKeysDiff(newTr T, oldTr T, idxs map[IndexID]*Index[T], buff []byte) (toAdd [][]byte, toRemove [][]byte) {
 	newTrKeys := t.indexKeys(newTr, idxs, buff[:0], [][]byte{})
 	if len(newTrKeys) != 0 {
@@ -932,7 +1730,9 @@ func (t *_table[T]) indexKeysDiff(newTr T, oldTr T, idxs map[IndexID]*Index[T],
 			}
 		}
 
-		if !found {
+		// the entries with the payloads are written again, as the payload
+		// may have changed
+		if !found || hasIndexPayload(newKey, idxs) {
 			toAdd = append(toAdd, newKey)
 		}
 	}
@@ -0,0 +1,286 @@
+package bond
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// AggregateKeyFunc returns the key of the aggregate the row contributes to.
+type AggregateKeyFunc[T any] func(builder KeyBuilder, tr T) []byte
+
+// AggregateValueFunc returns the contribution of the row to the aggregate.
+type AggregateValueFunc[T any] func(tr T) int64
+
+// TableAggregator maintains the aggregates of the table rows.
+type TableAggregator[T any] interface {
+	MaintainSum(name string, keyFunc AggregateKeyFunc[T], valueFunc AggregateValueFunc[T]) *Sum[T]
+}
+
+// Sum is the sum of the values of the rows with the same aggregate key,
+// maintained by the table with the merge operator. The writes merge the
+// difference they make into the sum in the same batch, so the sums stay
+// current without reading them on every write.
+type Sum[T any] struct {
+	table *_table[T]
+	name  string
+
+	keyFunc   AggregateKeyFunc[T]
+	valueFunc AggregateValueFunc[T]
+}
+
+// MaintainSum maintains the sums of the values of the rows by their aggregate
+// keys. The name identifies the sums within the table, so it must not change
+// between the runs. The sums of the rows that exist before it's maintained
+// are added with Backfill.
+//
+// Example:
+//
+//	balanceByAccount := tokenBalanceTable.MaintainSum("balance_by_account",
+//		func(builder bond.KeyBuilder, tb *TokenBalance) []byte {
+//			return builder.AddStringField(tb.AccountAddress).Bytes()
+//		},
+//		func(tb *TokenBalance) int64 {
+//			return int64(tb.Balance)
+//		})
+//
+//	balance, err := balanceByAccount.Get(&TokenBalance{AccountAddress: "0xtestAccount"})
+func (t *_table[T]) MaintainSum(name string, keyFunc AggregateKeyFunc[T], valueFunc AggregateValueFunc[T]) *Sum[T] {
+	s := &Sum[T]{
+		table:     t,
+		name:      name,
+		keyFunc:   keyFunc,
+		valueFunc: valueFunc,
+	}
+
+	t.addWriteHook(s.apply)
+	return s
+}
+
+// Name returns the name of the sum.
+func (s *Sum[T]) Name() string {
+	return s.name
+}
+
+// Get returns the sum of the aggregate key of the selector, zero if no row
+// contributed to it.
+func (s *Sum[T]) Get(selector T, optBatch ...Batch) (int64, error) {
+	var batch Batch
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		batch = optBatch[0]
+	}
+
+	key := s.key(s.keyFunc(NewKeyBuilder([]byte{}), selector))
+
+	data, closer, err := s.table.db.Get(key, batch)
+	if errors.Is(err, ErrNotFound) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer func() { _ = closer.Close() }()
+
+	return decodeSum(data)
+}
+
+// Scan iterates over the sums in the order of their aggregate keys. The
+// iteration stops if the callback returns false or the error.
+func (s *Sum[T]) Scan(ctx context.Context, f func(key []byte, sum int64) (bool, error), optBatch ...Batch) error {
+	prefix := s.key(nil)
+	opt := &IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: prefixUpperBound(prefix),
+		},
+	}
+
+	var iter Iterator
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		iter = optBatch[0].Iter(opt)
+	} else {
+		iter = s.table.db.Iter(opt)
+	}
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			_ = iter.Close()
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		sum, err := decodeSum(iter.Value())
+		if err != nil {
+			_ = iter.Close()
+			return err
+		}
+
+		cont, err := f(iter.Key()[len(prefix):], sum)
+		if err != nil {
+			_ = iter.Close()
+			return err
+		}
+		if !cont {
+			break
+		}
+	}
+	return iter.Close()
+}
+
+// Backfill rebuilds the sums from the rows of the table. The table should not
+// be written to until the backfill finishes.
+func (s *Sum[T]) Backfill(ctx context.Context) error {
+	prefix := s.key(nil)
+	err := s.table.db.DeleteRange(prefix, prefixUpperBound(prefix), Sync)
+	if err != nil {
+		return fmt.Errorf("failed to clear sum %s: %w", s.name, err)
+	}
+
+	batch := s.table.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	changes := make([]_rowChange[T], 0, ReindexBatchSize)
+	flush := func() error {
+		err := s.apply(ctx, batch, changes)
+		if err != nil {
+			return err
+		}
+
+		err = batch.Commit(Sync)
+		if err != nil {
+			return fmt.Errorf("failed to commit sum backfill batch: %w", err)
+		}
+
+		batch.Reset()
+		changes = changes[:0]
+		return nil
+	}
+
+	err = s.table.ScanForEach(ctx, func(_ KeyBytes, lazy Lazy[T]) (bool, error) {
+		tr, err := lazy.Get()
+		if err != nil {
+			return false, err
+		}
+
+		changes = append(changes, _rowChange[T]{new: tr, hasNew: true})
+		if len(changes) >= ReindexBatchSize {
+			return true, flush()
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+// apply is the write hook that merges the contributions of the changed rows
+// into the sums.
+func (s *Sum[T]) apply(_ context.Context, batch Batch, changes []_rowChange[T]) error {
+	merger, ok := batch.(interface {
+		Merge(key, value []byte, opt WriteOptions) error
+	})
+	if !ok {
+		return fmt.Errorf("sum %s: batch does not support merge", s.name)
+	}
+
+	var keyBuffer [DataKeyBufferSize]byte
+	merge := func(tr T, sign int64) error {
+		value := s.valueFunc(tr) * sign
+		if value == 0 {
+			return nil
+		}
+		return merger.Merge(s.key(s.keyFunc(NewKeyBuilder(keyBuffer[:0]), tr)), encodeSum(value), Sync)
+	}
+
+	for _, change := range changes {
+		if change.hasOld {
+			if err := merge(change.old, -1); err != nil {
+				return err
+			}
+		}
+		if change.hasNew {
+			if err := merge(change.new, 1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// key returns the key of the sum of the aggregate key.
+func (s *Sum[T]) key(aggregateKey []byte) []byte {
+	key := make([]byte, 0, 3+len(s.name)+2+len(aggregateKey))
+	key = append(key, BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_AGGREGATE_INDEX_ID, byte(s.table.storageID()))
+	key = appendEscaped(key, []byte(s.name))
+	return append(key, aggregateKey...)
+}
+
+func encodeSum(sum int64) []byte {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], uint64(sum))
+	return data[:]
+}
+
+func decodeSum(data []byte) (int64, error) {
+	if len(data) != 8 {
+		return 0, fmt.Errorf("invalid sum length %d", len(data))
+	}
+	return int64(binary.BigEndian.Uint64(data)), nil
+}
+
+// DefaultMerger returns the merge operator the pebble database of bond is
+// opened with.
+func DefaultMerger() *pebble.Merger {
+	return newMerger(nil)
+}
+
+// newMerger returns the merge operator that sums the values of the aggregate
+// keys and merges the other keys with the base merger. It keeps the name of the
+// base merger, so the databases created with it can still be opened.
+func newMerger(base *pebble.Merger) *pebble.Merger {
+	if base == nil {
+		base = pebble.DefaultMerger
+	}
+
+	return &pebble.Merger{
+		Name: base.Name,
+		Merge: func(key, value []byte) (pebble.ValueMerger, error) {
+			if len(key) < 2 || key[0] != BOND_DB_DATA_TABLE_ID || key[1] != BOND_DB_DATA_AGGREGATE_INDEX_ID {
+				return base.Merge(key, value)
+			}
+
+			m := &_sumMerger{}
+			return m, m.MergeNewer(value)
+		},
+	}
+}
+
+// _sumMerger adds up the sum operands.
+type _sumMerger struct {
+	sum int64
+}
+
+func (m *_sumMerger) MergeNewer(value []byte) error {
+	sum, err := decodeSum(value)
+	if err != nil {
+		return err
+	}
+	m.sum += sum
+	return nil
+}
+
+func (m *_sumMerger) MergeOlder(value []byte) error {
+	return m.MergeNewer(value)
+}
+
+func (m *_sumMerger) Finish(_ bool) ([]byte, io.Closer, error) {
+	return encodeSum(m.sum), nil, nil
+}
@@ -0,0 +1,94 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_MaintainSum(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", Balance: 5},
+	})
+	require.NoError(t, err)
+
+	balanceByAccount := tokenBalanceTable.MaintainSum("balance_by_account",
+		func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		func(tb *TokenBalance) int64 {
+			return int64(tb.Balance)
+		})
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 2, AccountAddress: "0xtestAccount1", Balance: 15},
+		{ID: 3, AccountAddress: "0xtestAccount2", Balance: 7},
+	})
+	require.NoError(t, err)
+
+	balance, err := balanceByAccount.Get(&TokenBalance{AccountAddress: "0xtestAccount1"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(15), balance)
+
+	// the rows written before are added by the backfill
+	err = balanceByAccount.Backfill(context.Background())
+	require.NoError(t, err)
+
+	balance, err = balanceByAccount.Get(&TokenBalance{AccountAddress: "0xtestAccount1"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), balance)
+
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 2, AccountAddress: "0xtestAccount2", Balance: 10},
+	})
+	require.NoError(t, err)
+
+	batch := db.Batch()
+	err = tokenBalanceTable.Delete(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", Balance: 5},
+	}, batch)
+	require.NoError(t, err)
+
+	// the batch sees its own changes
+	balance, err = balanceByAccount.Get(&TokenBalance{AccountAddress: "0xtestAccount1"}, batch)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), balance)
+
+	require.NoError(t, batch.Commit(Sync))
+	require.NoError(t, batch.Close())
+
+	sums := map[string]int64{}
+	err = balanceByAccount.Scan(context.Background(), func(key []byte, sum int64) (bool, error) {
+		sums[string(key[1:])] = sum
+		return true, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"0xtestAccount1": 0, "0xtestAccount2": 17}, sums)
+
+	balance, err = balanceByAccount.Get(&TokenBalance{AccountAddress: "0xtestAccount3"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), balance)
+
+	// the sums are folded by the compaction
+	pdb := db.(*_db).pebble
+	require.NoError(t, pdb.Flush())
+	require.NoError(t, pdb.Compact([]byte{0x00}, []byte{0x01}, false))
+
+	balance, err = balanceByAccount.Get(&TokenBalance{AccountAddress: "0xtestAccount2"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(17), balance)
+}
@@ -0,0 +1,236 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/go-bond/bond/utils"
+)
+
+// ArchiveBatchSize is the number of the rows Archive reads with one batch.
+const ArchiveBatchSize = 1000
+
+// ArchivedRow is the row moved to the archive sink. The key is the key of the
+// row in the table and the data is the row serialized with the table
+// serializer.
+type ArchivedRow struct {
+	Key  []byte
+	Data []byte
+}
+
+// ArchiveSink is the cold storage of the archived rows, e.g. the parquet
+// files on S3. Archive has to store the rows durably before it returns, as
+// the rows are deleted from the table once it does. Get returns the data of
+// the archived row, or ErrNotFound if the sink does not have it.
+type ArchiveSink interface {
+	Archive(ctx context.Context, table string, rows []ArchivedRow) error
+	Get(ctx context.Context, table string, key []byte) ([]byte, error)
+}
+
+// TableArchiver moves the rows of the table to the archive sink.
+type TableArchiver interface {
+	Archive(ctx context.Context) (int, error)
+}
+
+// _archivePolicy is the archival policy set with TableOptions.ArchiveAfter.
+type _archivePolicy[T any] struct {
+	after    time.Duration
+	timeFunc func(tr T) time.Time
+	sink     ArchiveSink
+	stubs    bool
+}
+
+// Archive moves the rows older than TableOptions.ArchiveAfter to the archive
+// sink and returns the number of the archived rows. The rows are read in the
+// batches of ArchiveBatchSize rows, the old rows of every batch are passed to
+// the sink and deleted from the table with their index entries, with the
+// writes of the table on hold. The stubs of the rows are left if
+// TableOptions.ArchiveStubs is set, so the archived rows are still returned
+// by Get. The stubs are not removed by Delete.
+//
+// Example:
+//
+//	// run daily
+//	archived, err := eventTable.Archive(ctx)
+func (t *_table[T]) Archive(ctx context.Context) (int, error) {
+	if t.archive == nil {
+		return 0, fmt.Errorf("table %s: archival is not enabled", t.name)
+	}
+
+	threshold := t.clock.Now().Add(-t.archive.after)
+
+	var (
+		cursor   []byte
+		archived int
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return archived, fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		next, rows, err := t.archiveBatch(ctx, cursor, threshold)
+		archived += rows
+		if err != nil {
+			return archived, err
+		}
+		if next == nil {
+			return archived, nil
+		}
+		cursor = next
+	}
+}
+
+// archiveBatch archives the old rows of the ArchiveBatchSize rows after the
+// cursor. It returns the key of the last row read, or nil if all the rows are
+// read, and the number of the archived rows.
+func (t *_table[T]) archiveBatch(ctx context.Context, cursor []byte, threshold time.Time) ([]byte, int, error) {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	indexes, writeHooks := t.writeIndexes()
+
+	lowerBound := []byte{byte(t.storageID()), byte(PrimaryIndexID)}
+	if cursor != nil {
+		lowerBound = append(cursor[:len(cursor):len(cursor)], 0x00)
+	}
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: lowerBound,
+			UpperBound: []byte{byte(t.storageID()), byte(PrimaryIndexID + 1)},
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	var (
+		rows     int
+		last     []byte
+		archived []ArchivedRow
+		trs      []T
+	)
+
+	for iter.First(); iter.Valid() && rows < ArchiveBatchSize; iter.Next() {
+		rows++
+		last = append(last[:0], iter.Key()...)
+
+		var tr T
+		err := t.serializer.Deserialize(iter.Value(), &tr)
+		if err != nil {
+			return nil, 0, t.newError(nil, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
+		}
+
+		if !t.archive.timeFunc(tr).Before(threshold) {
+			continue
+		}
+
+		archived = append(archived, ArchivedRow{
+			Key:  append([]byte{}, iter.Key()...),
+			Data: append([]byte{}, iter.Value()...),
+		})
+		trs = append(trs, tr)
+	}
+
+	var next []byte
+	if rows == ArchiveBatchSize {
+		next = last
+	}
+
+	if len(archived) == 0 {
+		return next, 0, nil
+	}
+
+	err := t.archive.sink.Archive(ctx, t.name, archived)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	batch := t.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	var (
+		invalidation   _cacheInvalidation
+		changes        []_rowChange[T]
+		indexKeyBuffer = make([]byte, DataKeyBufferSize*len(indexes))
+		indexKeys      = make([][]byte, len(indexes))
+	)
+
+	for i, row := range archived {
+		tr := trs[i]
+
+		err = batch.Delete(row.Key, Sync)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		indexKeys = t.indexKeys(tr, indexes, indexKeyBuffer[:0], indexKeys[:0])
+		for _, indexKey := range indexKeys {
+			err = batch.Delete(indexKey, Sync)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+
+		if t.archive.stubs {
+			err = batch.Set(t.archiveStubKey(row.Key), []byte{}, Sync)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+
+		t.collectInvalidation(&invalidation, row.Key, indexes, tr)
+		if len(writeHooks) > 0 {
+			changes = append(changes, _rowChange[T]{old: tr, hasOld: true})
+		}
+	}
+
+	err = t.runWriteHooks(ctx, writeHooks, batch, changes)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = batch.Commit(ContextRetrieveWriteOptions(ctx))
+	if err != nil {
+		return nil, 0, err
+	}
+
+	t.invalidateCache(invalidation, batch, false)
+
+	return next, len(archived), nil
+}
+
+// getArchived reads the row that is not in the table from the archive sink,
+// if the row has the archive stub.
+func (t *_table[T]) getArchived(key []byte, batch Batch) (T, error) {
+	_, closer, err := t.db.Get(t.archiveStubKey(key), batch)
+	if err != nil {
+		return utils.MakeNew[T](), t.newError(nil, key, notFound(err))
+	}
+	_ = closer.Close()
+
+	data, err := t.archive.sink.Get(context.Background(), t.name, key)
+	if err != nil {
+		return utils.MakeNew[T](), t.newError(nil, key, fmt.Errorf("failed to read archived row: %w", err))
+	}
+
+	var tr T
+	err = t.serializer.Deserialize(data, &tr)
+	if err != nil {
+		return utils.MakeNew[T](), t.newError(nil, key, fmt.Errorf("failed to deserialize: %w", err))
+	}
+	return tr, nil
+}
+
+// archiveStubKey returns the key of the archive stub of the row.
+func (t *_table[T]) archiveStubKey(key []byte) []byte {
+	stubKey := make([]byte, 0, len(key)+2)
+	stubKey = append(stubKey, BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_ARCHIVE_STUB_INDEX_ID)
+	return append(stubKey, key...)
+}
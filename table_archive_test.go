@@ -0,0 +1,121 @@
+package bond
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type _memoryArchiveSink struct {
+	rows  map[string][]byte
+	mutex sync.Mutex
+}
+
+func (s *_memoryArchiveSink) Archive(_ context.Context, table string, rows []ArchivedRow) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, row := range rows {
+		s.rows[table+string(row.Key)] = row.Data
+	}
+	return nil
+}
+
+func (s *_memoryArchiveSink) Get(_ context.Context, table string, key []byte) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, ok := s.rows[table+string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+func TestBond_Table_Archive(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	sink := &_memoryArchiveSink{rows: make(map[string][]byte)}
+	now := time.Now()
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		ArchiveAfter: 24 * time.Hour,
+		ArchiveTimeFunc: func(tb *TokenBalance) time.Time {
+			// the balance is the age of the row in hours, less half an hour
+			return now.Add(-time.Duration(tb.Balance)*time.Hour + 30*time.Minute)
+		},
+		ArchiveSink:  sink,
+		ArchiveStubs: true,
+	})
+
+	accountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{accountAddressIndex})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < ArchiveBatchSize+10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: "0xtestContract",
+			AccountAddress:  "0xtestAccount",
+			Balance:         uint64(i % 48),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	archived, err := tokenBalanceTable.Archive(context.Background())
+	require.NoError(t, err)
+
+	var old int
+	for _, tb := range tokenBalances {
+		if tb.Balance > 24 {
+			old++
+		}
+	}
+	assert.Equal(t, old, archived)
+	assert.Len(t, sink.rows, old)
+
+	var tokenBalancesRead []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Len(t, tokenBalancesRead, len(tokenBalances)-old)
+
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Execute(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Len(t, tokenBalancesRead, len(tokenBalances)-old)
+
+	// the archived row is read from the sink
+	tokenBalance, err := tokenBalanceTable.Get(&TokenBalance{ID: 48})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[47], tokenBalance)
+
+	_, err = tokenBalanceTable.Get(&TokenBalance{ID: 100000})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	archived, err = tokenBalanceTable.Archive(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, archived)
+}
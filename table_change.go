@@ -0,0 +1,108 @@
+package bond
+
+import (
+	"bytes"
+	"sync"
+)
+
+// TableChangeNotifier notifies about the rows changed by the committed writes.
+type TableChangeNotifier interface {
+	OnTableChange(table TableID, f func(keys [][]byte))
+}
+
+// _tableChanges are the table change listeners by the table.
+type _tableChanges struct {
+	listeners map[TableID][]func(keys [][]byte)
+	mutex     sync.RWMutex
+}
+
+// OnTableChange registers the listener called with the primary keys of the
+// rows of the table inserted, updated or deleted by every committed write,
+// e.g. to invalidate the cached rows precisely without reading the changed
+// values. The keys are listed once per write, in the order they were first
+// changed. The keys are nil if the write deleted the range of the table, e.g.
+// with DeleteRange, as the deleted rows are not known, so all the cached rows
+// of the table need to be invalidated.
+//
+// The listener is called on the goroutine that committed the write, after the
+// commit, so it needs to be fast and must not write to the database.
+//
+// Example:
+//
+//	db.OnTableChange(TokenBalanceTableID, func(keys [][]byte) {
+//		if keys == nil {
+//			cache.Purge()
+//			return
+//		}
+//		for _, key := range keys {
+//			cache.Remove(string(key))
+//		}
+//	})
+func (db *_db) OnTableChange(table TableID, f func(keys [][]byte)) {
+	db.tableChanges.mutex.Lock()
+	defer db.tableChanges.mutex.Unlock()
+
+	if db.tableChanges.listeners == nil {
+		db.tableChanges.listeners = make(map[TableID][]func(keys [][]byte))
+	}
+	db.tableChanges.listeners[table] = append(db.tableChanges.listeners[table], f)
+}
+
+func (tc *_tableChanges) observed() bool {
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+	return len(tc.listeners) > 0
+}
+
+// notify calls the listeners of the tables changed by the mutations.
+func (tc *_tableChanges) notify(mutations []WriteMutation, storageID func(table TableID) TableID) {
+	if len(mutations) == 0 {
+		return
+	}
+
+	tc.mutex.RLock()
+	defer tc.mutex.RUnlock()
+
+	for table, listeners := range tc.listeners {
+		keys, changed := tableChangedKeys(table, storageID(table), mutations)
+		if !changed {
+			continue
+		}
+
+		for _, listener := range listeners {
+			listener(keys)
+		}
+	}
+}
+
+// tableChangedKeys returns the distinct primary keys of the table rows changed
+// by the mutations, or nil keys if the range of the table was deleted. The keys
+// of the table are stored under the storage ID.
+func tableChangedKeys(table TableID, storageID TableID, mutations []WriteMutation) ([][]byte, bool) {
+	tableStart := []byte{byte(storageID)}
+	tableEnd := prefixUpperBound(tableStart)
+
+	var keys [][]byte
+	seen := make(map[string]struct{})
+	for _, mutation := range mutations {
+		if mutation.Kind == WriteChangeDeleteRange {
+			// the range overlaps the table keys
+			if (tableEnd == nil || bytes.Compare(mutation.Key, tableEnd) < 0) && bytes.Compare(mutation.Value, tableStart) > 0 {
+				return nil, true
+			}
+			continue
+		}
+
+		if mutation.TableID != table || len(mutation.Key) < 10 || KeyBytes(mutation.Key).IndexID() != PrimaryIndexID {
+			continue
+		}
+
+		primaryKey := []byte(KeyBytes(mutation.Key).PrimaryKey())
+		if _, ok := seen[string(primaryKey)]; ok {
+			continue
+		}
+		seen[string(primaryKey)] = struct{}{}
+		keys = append(keys, primaryKey)
+	}
+	return keys, len(keys) > 0
+}
@@ -0,0 +1,117 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_OnTableChange(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	newTokenBalanceTable := func(id TableID, name string) Table[*TokenBalance] {
+		return NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   id,
+			TableName: name,
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+		})
+	}
+
+	tokenBalanceTable := newTokenBalanceTable(TableID(1), "token_balance")
+	otherTable := newTokenBalanceTable(TableID(2), "other_token_balance")
+
+	var changes [][][]byte
+	db.OnTableChange(tokenBalanceTable.ID(), func(keys [][]byte) {
+		changes = append(changes, keys)
+	})
+
+	primaryKey := func(id uint64) []byte {
+		return NewKeyBuilder([]byte{}).AddUint64Field(id).Bytes()
+	}
+
+	ctx := context.Background()
+
+	tb1 := &TokenBalance{ID: 1, AccountAddress: "0xa1", Balance: 5}
+	tb2 := &TokenBalance{ID: 2, AccountAddress: "0xa2", Balance: 7}
+	err := tokenBalanceTable.Insert(ctx, []*TokenBalance{tb1, tb2})
+	require.NoError(t, err)
+
+	require.Len(t, changes, 1)
+	assert.Equal(t, [][]byte{primaryKey(1), primaryKey(2)}, changes[0])
+
+	// the writes to the other tables are not reported
+	err = otherTable.Insert(ctx, []*TokenBalance{tb1})
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+
+	// the keys changed more than once within the batch are reported once
+	batch := db.Batch()
+	tb1.Balance = 10
+	err = tokenBalanceTable.Update(ctx, []*TokenBalance{tb1}, batch)
+	require.NoError(t, err)
+	tb1.Balance = 15
+	err = tokenBalanceTable.Update(ctx, []*TokenBalance{tb1}, batch)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+
+	err = batch.Commit(Sync)
+	require.NoError(t, err)
+	_ = batch.Close()
+
+	require.Len(t, changes, 2)
+	assert.Equal(t, [][]byte{primaryKey(1)}, changes[1])
+
+	err = tokenBalanceTable.Delete(ctx, []*TokenBalance{tb2})
+	require.NoError(t, err)
+
+	require.Len(t, changes, 3)
+	assert.Equal(t, [][]byte{primaryKey(2)}, changes[2])
+
+	// the deleted range of the table is reported with nil keys
+	err = db.DeleteRange([]byte{byte(tokenBalanceTable.ID())}, []byte{byte(tokenBalanceTable.ID() + 1)}, Sync)
+	require.NoError(t, err)
+
+	require.Len(t, changes, 4)
+	assert.Nil(t, changes[3])
+}
+
+func TestBond_OnTableChange_DeltaUpdate(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		DeltaCodec:    tokenBalanceDeltaCodec{},
+		DeltaMaxChain: 10,
+	})
+
+	var changes [][][]byte
+	db.OnTableChange(tokenBalanceTable.ID(), func(keys [][]byte) {
+		changes = append(changes, keys)
+	})
+
+	ctx := context.Background()
+
+	tb := &TokenBalance{ID: 1, AccountAddress: "0xa1", Balance: 5}
+	err := tokenBalanceTable.Insert(ctx, []*TokenBalance{tb})
+	require.NoError(t, err)
+
+	// the delta merged into the row is reported as the change of the row
+	tb.Balance = 10
+	err = tokenBalanceTable.Update(ctx, []*TokenBalance{tb})
+	require.NoError(t, err)
+
+	require.Len(t, changes, 2)
+	assert.Equal(t, [][]byte{NewKeyBuilder([]byte{}).AddUint64Field(1).Bytes()}, changes[1])
+}
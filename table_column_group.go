@@ -0,0 +1,170 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// ColumnGroupID is the ID of the column group within the table.
+type ColumnGroupID uint8
+
+// ColumnGroup is the set of the hot fields of the table rows that are stored
+// apart from the rows, so the scans that only need these fields read a
+// fraction of the bytes of the rows.
+type ColumnGroup[T any] struct {
+	ID   ColumnGroupID
+	Name string
+
+	// Columns returns the row with only the fields of the group set.
+	Columns func(tr T) T
+
+	// Serializer serializes the group rows, the table serializer if nil.
+	Serializer Serializer[*T]
+}
+
+// TableColumnScanner scans the column groups of the table.
+type TableColumnScanner[T any] interface {
+	ScanColumns(ctx context.Context, group ColumnGroupID, f func(primaryKey PrimaryKey, tr T) (bool, error), optBatch ...Batch) error
+}
+
+// ScanColumns iterates over the column group rows in the primary key order.
+// The rows only have the fields of the group set, the full rows are read with
+// Get. The iteration stops if the callback returns false or the error.
+//
+// Example:
+//
+//	err := tokenBalanceTable.ScanColumns(ctx, BalanceColumnGroupID, func(pk bond.PrimaryKey, tb *TokenBalance) (bool, error) {
+//		total += tb.Balance
+//		return true, nil
+//	})
+func (t *_table[T]) ScanColumns(ctx context.Context, group ColumnGroupID, f func(primaryKey PrimaryKey, tr T) (bool, error), optBatch ...Batch) error {
+	columnGroup, ok := t.columnGroups[group]
+	if !ok {
+		return fmt.Errorf("table %s has no column group %d", t.name, group)
+	}
+
+	prefix := t.columnGroupKey(group, nil)
+	opt := &IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: prefixUpperBound(prefix),
+		},
+	}
+
+	var iter Iterator
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		iter = optBatch[0].Iter(opt)
+	} else {
+		iter = t.db.Iter(opt)
+	}
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			_ = iter.Close()
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		var tr T
+		err := columnGroup.serializer.Deserialize(iter.Value(), &tr)
+		if err != nil {
+			_ = iter.Close()
+			return fmt.Errorf("column group %s: failed to deserialize: %w", columnGroup.Name, err)
+		}
+
+		cont, err := f(PrimaryKey(iter.Key()[len(prefix):]), tr)
+		if err != nil {
+			_ = iter.Close()
+			return err
+		}
+		if !cont {
+			break
+		}
+	}
+	return iter.Close()
+}
+
+// updateColumnGroups is the write hook that writes the column group rows of
+// the written rows.
+func (t *_table[T]) updateColumnGroups(_ context.Context, batch Batch, changes []_rowChange[T]) error {
+	var keyBuffer [DataKeyBufferSize]byte
+	for _, change := range changes {
+		tr := change.new
+		if !change.hasNew {
+			tr = change.old
+		}
+
+		primaryKey := t.primaryKeyFunc(NewKeyBuilder(keyBuffer[:0]), tr)
+		for id, columnGroup := range t.columnGroups {
+			key := t.columnGroupKey(id, primaryKey)
+			if !change.hasNew {
+				err := batch.Delete(key, Sync)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+
+			columns := columnGroup.Columns(tr)
+			data, err := columnGroup.serializer.Serialize(&columns)
+			if err != nil {
+				return fmt.Errorf("column group %s: failed to serialize: %w", columnGroup.Name, err)
+			}
+
+			err = batch.Set(key, data, Sync)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// columnGroupKey returns the key of the column group row.
+func (t *_table[T]) columnGroupKey(group ColumnGroupID, primaryKey []byte) []byte {
+	key := make([]byte, 0, 4+len(primaryKey))
+	key = append(key, BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_COLUMN_GROUP_INDEX_ID, byte(t.storageID()), byte(group))
+	return append(key, primaryKey...)
+}
+
+// _columnGroup is the column group with its serializer resolved.
+type _columnGroup[T any] struct {
+	ColumnGroup[T]
+	serializer Serializer[*T]
+}
+
+func newColumnGroups[T any](groups []ColumnGroup[T], serializer Serializer[*T]) (map[ColumnGroupID]*_columnGroup[T], error) {
+	columnGroups := make(map[ColumnGroupID]*_columnGroup[T], len(groups))
+	for _, group := range groups {
+		if group.Columns == nil {
+			return nil, fmt.Errorf("column group %s has no columns function", group.Name)
+		}
+
+		if _, ok := columnGroups[group.ID]; ok {
+			return nil, fmt.Errorf("column group ID %d is used by more than one group", group.ID)
+		}
+
+		columnGroup := &_columnGroup[T]{ColumnGroup: group, serializer: group.Serializer}
+		if columnGroup.serializer == nil {
+			columnGroup.serializer = serializer
+		}
+		columnGroups[group.ID] = columnGroup
+	}
+	return columnGroups, nil
+}
+
+// prefixUpperBound returns the smallest key greater than all the keys with the
+// prefix.
+func prefixUpperBound(prefix []byte) []byte {
+	upperBound := append([]byte{}, prefix...)
+	for i := len(upperBound) - 1; i >= 0; i-- {
+		upperBound[i]++
+		if upperBound[i] != 0 {
+			return upperBound[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_ColumnGroups(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const BalanceColumnGroupID = ColumnGroupID(1)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		ColumnGroups: []ColumnGroup[*TokenBalance]{
+			{
+				ID:   BalanceColumnGroupID,
+				Name: "balance",
+				Columns: func(tb *TokenBalance) *TokenBalance {
+					return &TokenBalance{Balance: tb.Balance}
+				},
+			},
+		},
+	})
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount1", Balance: 5},
+		{ID: 2, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount2", Balance: 7},
+		{ID: 3, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount3", Balance: 11},
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 2, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount2", Balance: 17},
+	})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Delete(context.Background(), tokenBalances[:1])
+	require.NoError(t, err)
+
+	var (
+		primaryKeys []PrimaryKey
+		columns     []*TokenBalance
+	)
+	err = tokenBalanceTable.ScanColumns(context.Background(), BalanceColumnGroupID, func(pk PrimaryKey, tb *TokenBalance) (bool, error) {
+		primaryKeys = append(primaryKeys, append(PrimaryKey{}, pk...))
+		columns = append(columns, tb)
+		return true, nil
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []*TokenBalance{{Balance: 17}, {Balance: 11}}, columns)
+	require.Len(t, primaryKeys, 2)
+
+	// the point reads return the full rows
+	tr, err := tokenBalanceTable.Get(&TokenBalance{ID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "0xtestAccount2", tr.AccountAddress)
+	assert.Equal(t, uint64(17), tr.Balance)
+
+	trs, err := tokenBalanceTable.GetByKeys(context.Background(), primaryKeys)
+	require.NoError(t, err)
+	require.Len(t, trs, 2)
+	assert.Equal(t, uint64(3), trs[1].ID)
+
+	err = tokenBalanceTable.ScanColumns(context.Background(), BalanceColumnGroupID+1, func(PrimaryKey, *TokenBalance) (bool, error) {
+		return true, nil
+	})
+	assert.Error(t, err)
+
+	_, err = RegisterTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(2),
+		TableName: "token_balance_2",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		ColumnGroups: []ColumnGroup[*TokenBalance]{{ID: BalanceColumnGroupID, Name: "balance"}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,99 @@
+package bond
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// TableDebugger describes the raw keys of the table in the human readable form.
+type TableDebugger[T any] interface {
+	DebugRow(ctx context.Context, rawKey []byte) (string, error)
+}
+
+// _debugRow is the JSON document returned by DebugRow.
+type _debugRow struct {
+	Table      string          `json:"table"`
+	TableID    TableID         `json:"tableId"`
+	Index      string          `json:"index"`
+	IndexID    IndexID         `json:"indexId"`
+	IndexKey   []_debugField   `json:"indexKey"`
+	IndexOrder []_debugField   `json:"indexOrder"`
+	PrimaryKey []_debugField   `json:"primaryKey"`
+	Value      json.RawMessage `json:"value"`
+}
+
+type _debugField struct {
+	ID    byte   `json:"id"`
+	Type  string `json:"type"`
+	Value any    `json:"value"`
+}
+
+// DebugRow resolves the raw key of the table, either the row key or the
+// secondary index entry key, to the breakdown of its fields and the row it
+// points to, as the indented JSON. The value is null if the row does not
+// exist, e.g. for the dangling index entry.
+//
+// Example:
+//
+//	out, err := tokenBalanceTable.DebugRow(ctx, rawKey)
+//	...
+//	fmt.Println(out)
+func (t *_table[T]) DebugRow(ctx context.Context, rawKey []byte) (string, error) {
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	if len(rawKey) == 0 || KeyBytes(rawKey).TableID() != t.storageID() {
+		return "", t.newError(nil, rawKey, fmt.Errorf("key is not within the table range"))
+	}
+
+	decodedKey, err := (&KeyDecoder[T]{table: t}).Decode(rawKey)
+	if err != nil {
+		return "", t.newError(nil, rawKey, err)
+	}
+
+	row := _debugRow{
+		Table:      t.name,
+		TableID:    decodedKey.TableID,
+		Index:      decodedKey.IndexName,
+		IndexID:    decodedKey.IndexID,
+		IndexKey:   debugFields(decodedKey.IndexKey),
+		IndexOrder: debugFields(decodedKey.IndexOrder),
+		PrimaryKey: debugFields(decodedKey.PrimaryKey),
+		Value:      json.RawMessage("null"),
+	}
+
+	if !keyIsPrefix(rawKey) {
+		tr, err := t.get(KeyBytes(rawKey).ToDataKeyBytes(), nil)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return "", err
+		} else if err == nil {
+			row.Value, err = json.Marshal(tr)
+			if err != nil {
+				return "", t.newError(nil, rawKey, fmt.Errorf("failed to marshal row: %w", err))
+			}
+		}
+	}
+
+	data, err := json.MarshalIndent(row, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func debugFields(fields []KeyField) []_debugField {
+	debugFields := make([]_debugField, 0, len(fields))
+	for _, field := range fields {
+		debugFields = append(debugFields, _debugField{
+			ID:    field.ID,
+			Type:  field.Type.String(),
+			Value: field.Value(),
+		})
+	}
+	return debugFields
+}
@@ -0,0 +1,83 @@
+package bond
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBondTable_DebugRow(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: func(o IndexOrder, tb *TokenBalance) IndexOrder {
+			return o.OrderUint64(tb.Balance, IndexOrderTypeDESC)
+		},
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	tokenBalance := &TokenBalance{ID: 1, AccountAddress: "0xtestAccount", Balance: 15}
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	var keys [][]byte
+	iter := tokenBalanceTable.Iter(nil)
+	for iter.First(); iter.Valid(); iter.Next() {
+		keys = append(keys, append([]byte{}, iter.Key()...))
+	}
+	require.NoError(t, iter.Close())
+	require.Equal(t, 2, len(keys))
+
+	var rows []map[string]any
+	for _, key := range keys {
+		out, err := tokenBalanceTable.DebugRow(context.Background(), key)
+		require.NoError(t, err)
+
+		var row map[string]any
+		require.NoError(t, json.Unmarshal([]byte(out), &row))
+		rows = append(rows, row)
+	}
+
+	assert.Equal(t, "token_balance", rows[0]["table"])
+	assert.Equal(t, PrimaryIndexName, rows[0]["index"])
+	assert.Equal(t, []any{map[string]any{"id": float64(1), "type": "uint64", "value": float64(1)}}, rows[0]["primaryKey"])
+	assert.Equal(t, "0xtestAccount", rows[0]["value"].(map[string]any)["accountAddress"])
+
+	assert.Equal(t, "account_address_idx", rows[1]["index"])
+	assert.Equal(t, []any{map[string]any{"id": float64(1), "type": "string", "value": "0xtestAccount"}}, rows[1]["indexKey"])
+	assert.Equal(t, rows[0]["value"], rows[1]["value"])
+
+	err = tokenBalanceTable.Delete(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	out, err := tokenBalanceTable.DebugRow(context.Background(), keys[1])
+	require.NoError(t, err)
+	assert.Contains(t, out, `"value": null`)
+
+	_, err = tokenBalanceTable.DebugRow(context.Background(), []byte{BOND_DB_DATA_TABLE_ID, 0x01})
+	require.Error(t, err)
+}
@@ -0,0 +1,151 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// DeleteRangeBatchSize is the number of the rows removed with one batch by
+// Table.DeleteRange.
+const DeleteRangeBatchSize = 10000
+
+// TableRangeDeleter deletes the rows of the primary key range.
+type TableRangeDeleter[T any] interface {
+	DeleteRange(ctx context.Context, fromSelector T, toSelector T) error
+}
+
+// DeleteRange deletes the rows with the primary keys from the key of the
+// fromSelector, inclusive, to the key of the toSelector, exclusive. The rows
+// are removed with the range deletes. The rows of the tables with the
+// secondary indexes, the caches or the index features that track the rows are
+// read to remove their entries too, in the batches of DeleteRangeBatchSize
+// rows. Every batch is committed on its own, with the writes of the table on
+// hold, so the other writes proceed between the batches and the rows written
+// meanwhile to the part of the range that was already deleted are kept.
+//
+// Example:
+//
+//	// delete the token balances with the IDs from 100 to 199
+//	err := tokenBalanceTable.DeleteRange(ctx, &TokenBalance{ID: 100}, &TokenBalance{ID: 200})
+func (t *_table[T]) DeleteRange(ctx context.Context, fromSelector T, toSelector T) error {
+	var fromBuffer, toBuffer [DataKeyBufferSize]byte
+	from := t.key(fromSelector, fromBuffer[:0])
+	to := t.key(toSelector, toBuffer[:0])
+
+	if bytes.Compare(from, to) >= 0 {
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		next, err := t.deleteRangeBatch(ctx, from, to)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			return nil
+		}
+		from = next
+	}
+}
+
+// deleteRangeBatch deletes up to DeleteRangeBatchSize rows from the start of
+// the range. It returns the start of the rest of the range, or nil if the
+// whole range is deleted.
+func (t *_table[T]) deleteRangeBatch(ctx context.Context, from, to []byte) ([]byte, error) {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	indexes, writeHooks := t.writeIndexes()
+
+	batch := t.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	var (
+		end  = to
+		more bool
+	)
+
+	var invalidation _cacheInvalidation
+	if len(indexes) > 0 || len(writeHooks) > 0 || t.cache != nil || t.queryCache != nil {
+		var (
+			changes        []_rowChange[T]
+			indexKeyBuffer = make([]byte, DataKeyBufferSize*len(indexes))
+			indexKeys      = make([][]byte, len(indexes))
+			rows           int
+		)
+
+		iter := t.db.Iter(&IterOptions{
+			IterOptions: pebble.IterOptions{
+				LowerBound: from,
+				UpperBound: to,
+			},
+		})
+
+		for iter.First(); iter.Valid(); iter.Next() {
+			if rows == DeleteRangeBatchSize {
+				end, more = append([]byte{}, iter.Key()...), true
+				break
+			}
+
+			var tr T
+			err := t.serializer.Deserialize(iter.Value(), &tr)
+			if err != nil {
+				_ = iter.Close()
+				return nil, t.newError(nil, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
+			}
+
+			t.collectInvalidation(&invalidation, iter.Key(), indexes, tr)
+			if len(writeHooks) > 0 {
+				changes = append(changes, _rowChange[T]{old: tr, hasOld: true})
+			}
+
+			indexKeys = t.indexKeys(tr, indexes, indexKeyBuffer[:0], indexKeys[:0])
+			for _, indexKey := range indexKeys {
+				err = batch.Delete(indexKey, Sync)
+				if err != nil {
+					_ = iter.Close()
+					return nil, err
+				}
+			}
+			rows++
+		}
+
+		err := iter.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		err = t.runWriteHooks(ctx, writeHooks, batch, changes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := batch.DeleteRange(from, end, Sync)
+	if err != nil {
+		return nil, err
+	}
+
+	err = batch.Commit(ContextRetrieveWriteOptions(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	t.invalidateCache(invalidation, batch, false)
+
+	if !more {
+		return nil, nil
+	}
+	return end, nil
+}
@@ -0,0 +1,89 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_DeleteRange(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		CacheSize: 100,
+	})
+
+	accountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{accountAddressIndex})
+	require.NoError(t, err)
+
+	const rows = DeleteRangeBatchSize*2 + 500
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < rows; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: "0xtestContract",
+			AccountAddress:  fmt.Sprintf("0xtestAccount%d", i%2),
+			Balance:         uint64(i),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	// cache the row that is deleted
+	_, err = tokenBalanceTable.Get(&TokenBalance{ID: 150})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.DeleteRange(context.Background(), &TokenBalance{ID: 101}, &TokenBalance{ID: rows - 99})
+	require.NoError(t, err)
+
+	var left []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &left)
+	require.NoError(t, err)
+	require.Len(t, left, 200)
+	assert.Equal(t, uint64(100), left[99].ID)
+	assert.Equal(t, uint64(rows-99), left[100].ID)
+
+	_, err = tokenBalanceTable.Get(&TokenBalance{ID: 150})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// the index entries of the deleted rows are removed
+	var accountRows []*TokenBalance
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount0"}).
+		Execute(context.Background(), &accountRows)
+	require.NoError(t, err)
+	assert.Len(t, accountRows, 100)
+
+	accountRows = nil
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount1"}).
+		Execute(context.Background(), &accountRows)
+	require.NoError(t, err)
+	assert.Len(t, accountRows, 100)
+
+	// the empty range deletes nothing
+	err = tokenBalanceTable.DeleteRange(context.Background(), &TokenBalance{ID: 10}, &TokenBalance{ID: 10})
+	require.NoError(t, err)
+	assert.True(t, tokenBalanceTable.Exist(&TokenBalance{ID: 10}))
+}
@@ -0,0 +1,138 @@
+package bond
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// DefaultDeltaMaxChain is the number of deltas stored on top of the row before
+// Update writes the whole row again.
+const DefaultDeltaMaxChain = 32
+
+// DeltaCodec encodes the updates of the rows as the deltas against their
+// previous versions.
+type DeltaCodec[T any] interface {
+	// Diff returns the delta that turns the old row into the new one. It
+	// returns false if the change can not be expressed as the delta, then the
+	// whole row is written.
+	Diff(old, new T) ([]byte, bool)
+	// Apply returns the row with the delta applied.
+	Apply(tr T, delta []byte) (T, error)
+}
+
+// deltaMagic marks the end of every delta record, so the records appended to
+// the row by the merge operator are told apart from the row itself.
+var deltaMagic = [8]byte{0xbd, 0x0d, 'd', 'e', 'l', 't', 'a', 0x01}
+
+const deltaTrailerSize = 4 + len(deltaMagic)
+
+// _deltaSerializer deserializes the rows with the delta records appended to
+// them. The rows are serialized as is.
+type _deltaSerializer[T any] struct {
+	Serializer Serializer[*T]
+	Codec      DeltaCodec[T]
+}
+
+func (s *_deltaSerializer[T]) Serialize(tr *T) ([]byte, error) {
+	return s.Serializer.Serialize(tr)
+}
+
+func (s *_deltaSerializer[T]) serializeFor(tr *T) ([]byte, func(batch Batch) error, error) {
+	return serializeFor(s.Serializer, tr)
+}
+
+func (s *_deltaSerializer[T]) Deserialize(b []byte, tr *T) error {
+	return s.deserializeFrom(nil, b, tr)
+}
+
+// deserializeFrom deserializes the row read from the batch or the snapshot.
+func (s *_deltaSerializer[T]) deserializeFrom(batch Batch, b []byte, tr *T) error {
+	base, deltas, err := splitDeltas(b)
+	if err != nil {
+		return err
+	}
+
+	err = deserializeFrom(s.Serializer, batch, base, tr)
+	if err != nil {
+		return err
+	}
+
+	for _, delta := range deltas {
+		*tr, err = s.Codec.Apply(*tr, delta)
+		if err != nil {
+			return fmt.Errorf("failed to apply delta: %w", err)
+		}
+	}
+	return nil
+}
+
+// encodeDelta returns the delta record: the delta, its length and the magic.
+func encodeDelta(delta []byte) []byte {
+	record := make([]byte, len(delta)+deltaTrailerSize)
+	copy(record, delta)
+	binary.BigEndian.PutUint32(record[len(delta):], uint32(len(delta)))
+	copy(record[len(delta)+4:], deltaMagic[:])
+	return record
+}
+
+// splitDeltas returns the row and its deltas in the order they were written.
+func splitDeltas(b []byte) ([]byte, [][]byte, error) {
+	var deltas [][]byte
+	for len(b) >= deltaTrailerSize && bytes.Equal(b[len(b)-len(deltaMagic):], deltaMagic[:]) {
+		length := int(binary.BigEndian.Uint32(b[len(b)-deltaTrailerSize:]))
+		if length > len(b)-deltaTrailerSize {
+			return nil, nil, fmt.Errorf("invalid delta record length %d", length)
+		}
+
+		end := len(b) - deltaTrailerSize
+		deltas = append(deltas, b[end-length:end])
+		b = b[:end-length]
+	}
+
+	for i, j := 0, len(deltas)-1; i < j; i, j = i+1, j-1 {
+		deltas[i], deltas[j] = deltas[j], deltas[i]
+	}
+	return b, deltas, nil
+}
+
+// deltaChainLength returns the number of the deltas stored on top of the row.
+func deltaChainLength(b []byte) int {
+	_, deltas, err := splitDeltas(b)
+	if err != nil {
+		return 0
+	}
+	return len(deltas)
+}
+
+// setRow writes the updated row. With the delta codec set, the delta against
+// the old row is merged into the stored value instead, until the chain of the
+// deltas reaches its limit and the whole row is written again.
+// The chain is the number of the deltas stored on top of the old row.
+func (t *_table[T]) setRow(batch Batch, key []byte, chain int, oldTr T, tr T) (int, error) {
+	if t.deltaCodec != nil && chain < t.deltaMaxChain {
+		if merger, ok := batch.(interface {
+			Merge(key, value []byte, opt WriteOptions) error
+		}); ok {
+			if delta, ok := t.deltaCodec.Diff(oldTr, tr); ok {
+				record := encodeDelta(delta)
+				err := merger.Merge(key, record, Sync)
+				if err != nil {
+					return 0, err
+				}
+				return len(key) + len(record), nil
+			}
+		}
+	}
+
+	data, err := t.serialize(&tr, batch)
+	if err != nil {
+		return 0, err
+	}
+
+	err = batch.Set(key, data, Sync)
+	if err != nil {
+		return 0, err
+	}
+	return len(key) + len(data), nil
+}
@@ -0,0 +1,124 @@
+package bond
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type tokenBalanceDeltaCodec struct{}
+
+func (c tokenBalanceDeltaCodec) Diff(old, new *TokenBalance) ([]byte, bool) {
+	if old.ID != new.ID || old.AccountID != new.AccountID || old.ContractAddress != new.ContractAddress ||
+		old.AccountAddress != new.AccountAddress || old.TokenID != new.TokenID {
+		return nil, false
+	}
+	delta := make([]byte, 8)
+	binary.BigEndian.PutUint64(delta, new.Balance)
+	return delta, true
+}
+
+func (c tokenBalanceDeltaCodec) Apply(tr *TokenBalance, delta []byte) (*TokenBalance, error) {
+	tr.Balance = binary.BigEndian.Uint64(delta)
+	return tr, nil
+}
+
+func TestBond_Table_DeltaCodec(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		DeltaCodec:    tokenBalanceDeltaCodec{},
+		DeltaMaxChain: 10,
+	})
+
+	TokenBalanceBalanceIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "balance_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.Balance).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceBalanceIndex})
+	require.NoError(t, err)
+
+	tokenBalance := &TokenBalance{
+		ID:              1,
+		AccountID:       1,
+		ContractAddress: "0xtestContract",
+		AccountAddress:  "0xtestAccount",
+		Balance:         0,
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	key := tokenBalanceTable.(*_table[*TokenBalance]).key(tokenBalance, make([]byte, 0, DataKeyBufferSize))
+	chain := func() int {
+		data, closer, err := db.Get(key)
+		require.NoError(t, err)
+		defer func() { _ = closer.Close() }()
+		return deltaChainLength(data)
+	}
+
+	for i := 1; i <= 25; i++ {
+		update := *tokenBalance
+		update.Balance = uint64(i)
+
+		if i%2 == 0 {
+			err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{&update})
+		} else {
+			err = tokenBalanceTable.Upsert(context.Background(), []*TokenBalance{&update}, TableUpsertOnConflictReplace[*TokenBalance])
+		}
+		require.NoError(t, err)
+
+		assert.LessOrEqual(t, chain(), 10)
+	}
+
+	// the whole row was written after every 10 deltas
+	assert.Equal(t, 3, chain())
+
+	read := func() *TokenBalance {
+		tr, err := tokenBalanceTable.Get(&TokenBalance{ID: 1})
+		require.NoError(t, err)
+		return tr
+	}
+
+	assert.Equal(t, uint64(25), read().Balance)
+
+	var byBalance []*TokenBalance
+	err = tokenBalanceTable.Query().
+		With(TokenBalanceBalanceIndex, &TokenBalance{Balance: 25}).
+		Execute(context.Background(), &byBalance)
+	require.NoError(t, err)
+	require.Len(t, byBalance, 1)
+	assert.Equal(t, uint64(25), byBalance[0].Balance)
+
+	// the deltas are folded into the row by the compaction
+	pdb := db.(*_db).pebble
+	require.NoError(t, pdb.Flush())
+	require.NoError(t, pdb.Compact([]byte{0x01}, []byte{0x02}, false))
+
+	assert.Equal(t, uint64(25), read().Balance)
+
+	// the change the codec can not express writes the whole row
+	update := *tokenBalance
+	update.Balance = 26
+	update.AccountAddress = "0xtestAccount2"
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{&update})
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, chain())
+	assert.Equal(t, &update, read())
+}
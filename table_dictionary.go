@@ -0,0 +1,358 @@
+package bond
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// DictionaryField is the string field of the rows that is stored as the
+// reference to the value in the table dictionary, e.g. the contract address
+// repeated by millions of rows.
+type DictionaryField[T any] struct {
+	Name string
+
+	// Get returns the value of the field.
+	Get func(tr T) string
+	// Set returns the copy of the row with the field set. The row must not
+	// be changed in place, as it's shared with the callers.
+	Set func(tr T, value string) T
+}
+
+// TableDictionaryCollector removes the unused values from the table dictionary.
+type TableDictionaryCollector interface {
+	CollectDictionary(ctx context.Context) error
+}
+
+// dictionaryReferenceMarker is the first byte of the stored references, it's
+// followed by the varint ID of the value. The values that start with the
+// marker are stored as they are, escaped with the marker and the zero byte,
+// which no ID starts with. The values of the rows written before the
+// dictionary was enabled must not start with the marker.
+const dictionaryReferenceMarker = 0x00
+
+// _dictionary is the dictionary of the field values of the table. Every value
+// has its ID, the IDs are never reused, so the rows written with the value
+// that was collected in the meantime get the new ID.
+type _dictionary struct {
+	db      DB
+	tableID TableID
+
+	fields []*_dictionaryField
+	epoch  uint64
+
+	mutex sync.Mutex
+}
+
+type _dictionaryField struct {
+	ids      map[string]uint32
+	values   map[uint32]string
+	lastUsed map[uint32]uint64
+	nextID   uint32
+
+	// stored are the IDs of the values that are committed, the other values
+	// are written with every row that refers to them until one is committed
+	stored map[uint32]struct{}
+}
+
+func newDictionary(db DB, tableID TableID, fields int) (*_dictionary, error) {
+	dictionary := &_dictionary{db: db, tableID: tableID}
+	for i := 0; i < fields; i++ {
+		dictionary.fields = append(dictionary.fields, &_dictionaryField{
+			ids:      make(map[string]uint32),
+			values:   make(map[uint32]string),
+			lastUsed: make(map[uint32]uint64),
+			nextID:   1,
+			stored:   make(map[uint32]struct{}),
+		})
+	}
+
+	prefix := dictionary.key(0, 0)[:3]
+	iter := db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: prefixUpperBound(prefix),
+		},
+	})
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		key := iter.Key()
+		if len(key) != 8 || int(key[3]) >= fields {
+			continue
+		}
+
+		field := dictionary.fields[key[3]]
+		id := binary.BigEndian.Uint32(key[4:])
+		value := string(iter.Value())
+
+		field.values[id] = value
+		field.stored[id] = struct{}{}
+		if _, ok := field.ids[value]; !ok {
+			field.ids[value] = id
+		}
+		if id >= field.nextID {
+			field.nextID = id + 1
+		}
+	}
+	return dictionary, iter.Close()
+}
+
+// intern returns the reference to the value and the function that writes the
+// value to the batch of the row, nil if the value is committed, so the value
+// is committed, or dropped, along with the row.
+func (d *_dictionary) intern(fieldIndex int, value string) (string, func(batch Batch) error) {
+	if value[0] == dictionaryReferenceMarker {
+		return string([]byte{dictionaryReferenceMarker, 0x00}) + value, nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	field := d.fields[fieldIndex]
+	id, ok := field.ids[value]
+	if !ok {
+		id = field.nextID
+		field.nextID++
+		field.ids[value] = id
+		field.values[id] = value
+	}
+	field.lastUsed[id] = d.epoch
+
+	var reference [1 + binary.MaxVarintLen32]byte
+	reference[0] = dictionaryReferenceMarker
+	n := binary.PutUvarint(reference[1:], uint64(id))
+
+	if _, ok := field.stored[id]; ok {
+		return string(reference[:1+n]), nil
+	}
+	return string(reference[:1+n]), func(batch Batch) error {
+		return d.write(batch, fieldIndex, id, value)
+	}
+}
+
+// write writes the value to the batch, unless it was committed in the
+// meantime. The value collected in the meantime is referenced again.
+func (d *_dictionary) write(batch Batch, fieldIndex int, id uint32, value string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	field := d.fields[fieldIndex]
+	field.lastUsed[id] = d.epoch
+	if _, ok := field.values[id]; !ok {
+		field.values[id] = value
+		if _, ok := field.ids[value]; !ok {
+			field.ids[value] = id
+		}
+	}
+
+	if _, ok := field.stored[id]; ok {
+		return nil
+	}
+
+	err := batch.Set(d.key(fieldIndex, id), []byte(value), Sync)
+	if err != nil {
+		return fmt.Errorf("failed to write dictionary value: %w", err)
+	}
+
+	batch.AfterCommit(func(CommittedBatchInfo) {
+		d.mutex.Lock()
+		defer d.mutex.Unlock()
+
+		if _, ok := field.values[id]; ok {
+			field.stored[id] = struct{}{}
+		}
+	})
+	return nil
+}
+
+// lookup returns the value of the reference. The escaped values are returned
+// unescaped, and the values that are not references, e.g. written before the
+// dictionary was enabled, are returned as they are.
+func (d *_dictionary) lookup(fieldIndex int, reference string) (string, error) {
+	if len(reference) == 0 || reference[0] != dictionaryReferenceMarker {
+		return reference, nil
+	}
+	if len(reference) > 1 && reference[1] == 0x00 {
+		return reference[2:], nil
+	}
+
+	id, n := binary.Uvarint([]byte(reference[1:]))
+	if n <= 0 {
+		return "", fmt.Errorf("invalid dictionary reference %x", reference)
+	}
+
+	d.mutex.Lock()
+	value, ok := d.fields[fieldIndex].values[uint32(id)]
+	d.mutex.Unlock()
+
+	if !ok {
+		return "", fmt.Errorf("dictionary value %d not found", id)
+	}
+	return value, nil
+}
+
+// key returns the key of the dictionary value.
+func (d *_dictionary) key(fieldIndex int, id uint32) []byte {
+	key := []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_DICTIONARY_INDEX_ID, byte(d.tableID), byte(fieldIndex), 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(key[4:], id)
+	return key
+}
+
+// _dictionarySerializer stores the dictionary fields of the rows as the
+// references to the dictionary values.
+type _dictionarySerializer[T any] struct {
+	Serializer Serializer[*T]
+	Fields     []DictionaryField[T]
+
+	dictionary *_dictionary
+}
+
+// Serialize serializes the row and commits the new values it refers to with
+// their own batch, e.g. for the column groups.
+func (s *_dictionarySerializer[T]) Serialize(tr *T) ([]byte, error) {
+	data, write, err := s.serializeFor(tr)
+	if err != nil || write == nil {
+		return data, err
+	}
+
+	batch := s.dictionary.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	err = write(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	err = batch.Commit(Sync)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// serializeFor returns the function that writes the new dictionary values of
+// the row to the batch of the row.
+func (s *_dictionarySerializer[T]) serializeFor(tr *T) ([]byte, func(batch Batch) error, error) {
+	var writes []func(batch Batch) error
+
+	row := *tr
+	for i, field := range s.Fields {
+		value := field.Get(row)
+		if value == "" {
+			continue
+		}
+
+		reference, write := s.dictionary.intern(i, value)
+		if write != nil {
+			writes = append(writes, write)
+		}
+		row = field.Set(row, reference)
+	}
+
+	data, write, err := serializeFor(s.Serializer, &row)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, joinWrites(append(writes, write)...), nil
+}
+
+func (s *_dictionarySerializer[T]) Deserialize(b []byte, tr *T) error {
+	err := s.Serializer.Deserialize(b, tr)
+	if err != nil {
+		return err
+	}
+
+	for i, field := range s.Fields {
+		reference := field.Get(*tr)
+		if len(reference) == 0 || reference[0] != dictionaryReferenceMarker {
+			continue
+		}
+
+		value, err := s.dictionary.lookup(i, reference)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		*tr = field.Set(*tr, value)
+	}
+	return nil
+}
+
+// CollectDictionary removes the dictionary values no row refers to. The values
+// referenced since the previous collection are kept, so the writes that are in
+// flight during the collection keep their values, the unused values are
+// removed by the collection that follows. The values are removed with the
+// point deletes, which the compaction drops with the values.
+func (t *_table[T]) CollectDictionary(ctx context.Context) error {
+	if t.dictionary == nil {
+		return fmt.Errorf("table %s has no dictionary fields", t.name)
+	}
+
+	d := t.dictionary
+
+	d.mutex.Lock()
+	d.epoch++
+	epoch := d.epoch
+	d.mutex.Unlock()
+
+	used := make([]map[string]struct{}, len(t.dictionaryFields))
+	for i := range used {
+		used[i] = make(map[string]struct{})
+	}
+
+	err := t.ScanForEach(ctx, func(_ KeyBytes, l Lazy[T]) (bool, error) {
+		tr, err := l.Get()
+		if err != nil {
+			return false, err
+		}
+
+		for i, field := range t.dictionaryFields {
+			used[i][field.Get(tr)] = struct{}{}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	batch := t.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	d.mutex.Lock()
+	for i, field := range d.fields {
+		for id, value := range field.values {
+			if _, ok := used[i][value]; ok || field.lastUsed[id]+1 >= epoch {
+				continue
+			}
+
+			err = batch.Delete(d.key(i, id), Sync)
+			if err != nil {
+				d.mutex.Unlock()
+				return err
+			}
+
+			delete(field.values, id)
+			delete(field.lastUsed, id)
+			delete(field.stored, id)
+			if field.ids[value] == id {
+				delete(field.ids, value)
+			}
+		}
+
+		// the other IDs of the values are referenced with the values
+		for id, value := range field.values {
+			if _, ok := field.ids[value]; !ok {
+				field.ids[value] = id
+			}
+		}
+	}
+	d.mutex.Unlock()
+
+	return batch.Commit(Sync)
+}
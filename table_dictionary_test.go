@@ -0,0 +1,164 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_DictionaryFields(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	newTokenBalanceTable := func() Table[*TokenBalance] {
+		return NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   TableID(1),
+			TableName: "token_balance",
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+			DictionaryFields: []DictionaryField[*TokenBalance]{
+				{
+					Name: "contract_address",
+					Get: func(tb *TokenBalance) string {
+						return tb.ContractAddress
+					},
+					Set: func(tb *TokenBalance, value string) *TokenBalance {
+						c := *tb
+						c.ContractAddress = value
+						return &c
+					},
+				},
+			},
+		})
+	}
+
+	tokenBalanceTable := newTokenBalanceTable()
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < 10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: fmt.Sprintf("0xtestContract%d", i%2),
+			AccountAddress:  "0xtestAccount",
+			Balance:         uint64(i),
+		})
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	// the rows are not changed by the serialization
+	assert.Equal(t, "0xtestContract0", tokenBalances[0].ContractAddress)
+
+	dictionaryEntries := func() int {
+		prefix := []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_DICTIONARY_INDEX_ID, 1}
+		iter := db.Iter(&IterOptions{
+			IterOptions: pebble.IterOptions{
+				LowerBound: prefix,
+				UpperBound: prefixUpperBound(prefix),
+			},
+		})
+		defer func() { _ = iter.Close() }()
+
+		var count int
+		for iter.First(); iter.Valid(); iter.Next() {
+			count++
+		}
+		return count
+	}
+
+	assert.Equal(t, 2, dictionaryEntries())
+
+	var stored int
+	err = tokenBalanceTable.RawScan(context.Background(), func(_, value []byte) bool {
+		assert.NotContains(t, string(value), "0xtestContract")
+		stored++
+		return true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 10, stored)
+
+	// the dictionary is loaded with the table
+	tokenBalanceTable = newTokenBalanceTable()
+
+	var trs []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &trs)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances, trs)
+
+	// the value no longer used is removed by the second collection
+	for _, tb := range tokenBalances {
+		tb.ContractAddress = "0xtestContract0"
+	}
+	err = tokenBalanceTable.Update(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.CollectDictionary(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, dictionaryEntries())
+
+	err = tokenBalanceTable.CollectDictionary(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, dictionaryEntries())
+
+	// the collected value gets the new ID
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 1, ContractAddress: "0xtestContract1", AccountAddress: "0xtestAccount"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, dictionaryEntries())
+
+	tr, err := tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "0xtestContract1", tr.ContractAddress)
+
+	tr, err = tokenBalanceTable.Get(&TokenBalance{ID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "0xtestContract0", tr.ContractAddress)
+
+	// the values of the writes that are not committed are not stored
+	dryRunCtx := ContextWithWriteOptions(context.Background(), WriteOptions{DryRun: true})
+	err = tokenBalanceTable.Insert(dryRunCtx, []*TokenBalance{
+		{ID: 11, ContractAddress: "0xtestContract2", AccountAddress: "0xtestAccount"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, dictionaryEntries())
+
+	batch := db.Batch()
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 11, ContractAddress: "0xtestContract2", AccountAddress: "0xtestAccount"},
+	}, batch)
+	require.NoError(t, err)
+	require.NoError(t, batch.Close())
+	assert.Equal(t, 2, dictionaryEntries())
+
+	// the value is stored with the row once it's committed
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 11, ContractAddress: "0xtestContract2", AccountAddress: "0xtestAccount"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, dictionaryEntries())
+
+	// the values that start with the reference marker are stored escaped
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 12, ContractAddress: "\x00\x01raw", AccountAddress: "0xtestAccount"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, dictionaryEntries())
+
+	tokenBalanceTable = newTokenBalanceTable()
+
+	tr, err = tokenBalanceTable.Get(&TokenBalance{ID: 11})
+	require.NoError(t, err)
+	assert.Equal(t, "0xtestContract2", tr.ContractAddress)
+
+	tr, err = tokenBalanceTable.Get(&TokenBalance{ID: 12})
+	require.NoError(t, err)
+	assert.Equal(t, "\x00\x01raw", tr.ContractAddress)
+}
@@ -0,0 +1,100 @@
+package bond
+
+import (
+	"fmt"
+)
+
+// TableFilterIndexer registers the filter indexes of the table.
+type TableFilterIndexer[T any] interface {
+	RegisterFilterIndex(name string, filter FilterFunc[T]) (*Index[T], error)
+}
+
+// RegisterFilterIndex registers the named filter that is maintained as the
+// keys only index of the rows the filter accepts. The existing rows are
+// indexed before it returns. The filter index is used by the queries with
+// Query.FilterIndex, so the queries with the stable predicate scan only the
+// rows that match it.
+//
+// The ID of the filter index is assigned from the top of the index ID space
+// and it's persisted in the catalog, so the same name gets the same ID after
+// the restart. The filter must not change between the restarts, as the index
+// is not rebuilt.
+//
+// Example:
+//
+//	overdueIndex, err := InvoiceTable.RegisterFilterIndex("overdue", func(i *Invoice) bool {
+//		return !i.Paid && i.DueDate < today
+//	})
+func (t *_table[T]) RegisterFilterIndex(name string, filter FilterFunc[T]) (*Index[T], error) {
+	id, err := t.filterIndexID(name)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := NewIndex[T](IndexOptions[T]{
+		IndexID:   id,
+		IndexName: name,
+		IndexKeyFunc: func(builder KeyBuilder, _ T) []byte {
+			return builder.Bytes()
+		},
+		IndexFilterFunc: IndexFilterFunction[T](filter),
+	})
+
+	err = t.AddIndex([]*Index[T]{idx}, true)
+	if err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// filterIndexID returns the ID of the filter index. It's the ID persisted in
+// the catalog for the index name or the highest free index ID.
+func (t *_table[T]) filterIndexID(name string) (IndexID, error) {
+	used := make(map[IndexID]bool)
+
+	t.mutex.RLock()
+	for id, idx := range t.secondaryIndexes {
+		if idx.IndexName == name {
+			t.mutex.RUnlock()
+			return 0, t.newError(idx, nil, fmt.Errorf("filter index %s is already registered", name))
+		}
+		used[id] = true
+	}
+	for id := range t.indexBuilds {
+		used[id] = true
+	}
+	t.mutex.RUnlock()
+
+	if t.catalog != nil {
+		t.catalog.mutex.Lock()
+		if table, ok := t.catalog.persisted[t.id]; ok {
+			for _, index := range table.Indexes {
+				if index.Name == name {
+					t.catalog.mutex.Unlock()
+					return index.ID, nil
+				}
+				used[index.ID] = true
+			}
+		}
+		t.catalog.mutex.Unlock()
+	}
+
+	for id := IndexID(0xFF); id > PrimaryIndexID; id-- {
+		if !used[id] {
+			return id, nil
+		}
+	}
+	return 0, t.newError(nil, nil, fmt.Errorf("no index id left for filter index %s: %w", name, ErrIndexIDCollision))
+}
+
+// FilterIndex restricts the query to the rows accepted by the filter index
+// registered with RegisterFilterIndex. The query that has no index selected
+// scans the filter index, otherwise the filter of the index is applied to the
+// rows of the selected index.
+func (q Query[R]) FilterIndex(idx *Index[R]) Query[R] {
+	if q.index.IndexID == PrimaryIndexID && len(q.queries) == 0 && !q.isAfter && !q.indexPrefix && len(q.indexCandidates) == 0 {
+		q.index = idx
+		return q
+	}
+	return q.Filter(FilterFunc[R](idx.IndexFilterFunction))
+}
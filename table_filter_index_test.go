@@ -0,0 +1,70 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_RegisterFilterIndex(t *testing.T) {
+	db, tokenBalanceTable, accountAddressIndex, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", ContractAddress: "0xtestContract", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount1", ContractAddress: "0xtestContract", Balance: 15},
+		{ID: 3, AccountAddress: "0xtestAccount2", ContractAddress: "0xtestContract", Balance: 70},
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	richIndex, err := tokenBalanceTable.RegisterFilterIndex("rich", func(tb *TokenBalance) bool {
+		return tb.Balance > 10
+	})
+	require.NoError(t, err)
+	assert.Equal(t, IndexID(0xFF), richIndex.IndexID)
+
+	_, err = tokenBalanceTable.RegisterFilterIndex("rich", func(tb *TokenBalance) bool {
+		return tb.Balance > 10
+	})
+	require.Error(t, err)
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 4, AccountAddress: "0xtestAccount2", ContractAddress: "0xtestContract", Balance: 1},
+		{ID: 5, AccountAddress: "0xtestAccount1", ContractAddress: "0xtestContract", Balance: 20},
+	})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 2, AccountAddress: "0xtestAccount1", ContractAddress: "0xtestContract", Balance: 0},
+	})
+	require.NoError(t, err)
+
+	var rich []*TokenBalance
+	err = tokenBalanceTable.Query().FilterIndex(richIndex).Execute(context.Background(), &rich)
+	require.NoError(t, err)
+	require.Len(t, rich, 2)
+	assert.Equal(t, uint64(3), rich[0].ID)
+	assert.Equal(t, uint64(5), rich[1].ID)
+
+	// the index entries are the rows accepted by the filter
+	var entries int
+	err = tokenBalanceTable.RawScanIndex(context.Background(), richIndex, func(key, value []byte) bool {
+		entries++
+		return true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, entries)
+
+	// the filter of the index is applied to the rows of the selected index
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount1"}).
+		FilterIndex(richIndex).
+		Execute(context.Background(), &rich)
+	require.NoError(t, err)
+	require.Len(t, rich, 1)
+	assert.Equal(t, uint64(5), rich[0].ID)
+}
@@ -0,0 +1,217 @@
+package bond
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// IDGenerator assigns the IDs to the rows inserted without them.
+type IDGenerator[T any] interface {
+	// AssignID returns the row with the generated ID if its ID is zero,
+	// otherwise it returns the row as it is.
+	AssignID(tr T) (T, error)
+}
+
+// _idGenerator assigns the IDs produced by next to the rows with the zero ID.
+type _idGenerator[T any, ID comparable] struct {
+	get  func(tr T) ID
+	set  func(tr T, id ID) T
+	next func() (ID, error)
+}
+
+func (g *_idGenerator[T, ID]) AssignID(tr T) (T, error) {
+	var zero ID
+	if g.get(tr) != zero {
+		return tr, nil
+	}
+
+	id, err := g.next()
+	if err != nil {
+		return tr, err
+	}
+	return g.set(tr, id), nil
+}
+
+// assignIDs sets the IDs of the rows without them. The rows of the slice are
+// replaced, so the caller sees the IDs the rows were inserted with.
+func (t *_table[T]) assignIDs(trs []T) error {
+	if t.idGenerator == nil {
+		return nil
+	}
+
+	for i, tr := range trs {
+		tr, err := t.idGenerator.AssignID(tr)
+		if err != nil {
+			return fmt.Errorf("failed to generate id: %w", err)
+		}
+		trs[i] = tr
+	}
+	return nil
+}
+
+// ulidEncoding is the Crockford's base32 alphabet of the ULIDs.
+const ulidEncoding = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULIDGenerator returns the generator of the ULIDs, the 26 characters long
+// strings made of the millisecond timestamp and 80 random bits. The IDs
+// generated within the same millisecond are monotonic, so the IDs sort in
+// the order they were generated.
+func NewULIDGenerator[T any](get func(tr T) string, set func(tr T, id string) T) IDGenerator[T] {
+	var (
+		mutex      sync.Mutex
+		lastMillis uint64
+		lastRandom [10]byte
+	)
+
+	return &_idGenerator[T, string]{
+		get: get,
+		set: set,
+		next: func() (string, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			millis := uint64(time.Now().UnixMilli())
+			if millis <= lastMillis {
+				// increase the random part of the last ID
+				i := len(lastRandom) - 1
+				for ; i >= 0; i-- {
+					lastRandom[i]++
+					if lastRandom[i] != 0 {
+						break
+					}
+				}
+				if i < 0 {
+					return "", fmt.Errorf("ulid random part overflow")
+				}
+				millis = lastMillis
+			} else {
+				_, err := rand.Read(lastRandom[:])
+				if err != nil {
+					return "", err
+				}
+				lastMillis = millis
+			}
+
+			hi := millis<<16 | uint64(lastRandom[0])<<8 | uint64(lastRandom[1])
+			var lo uint64
+			for _, b := range lastRandom[2:] {
+				lo = lo<<8 | uint64(b)
+			}
+
+			var id [26]byte
+			for i := range id {
+				shift := uint(25-i) * 5
+				var value uint64
+				switch {
+				case shift >= 64:
+					value = hi >> (shift - 64)
+				case shift == 0:
+					value = lo
+				default:
+					value = lo>>shift | hi<<(64-shift)
+				}
+				id[i] = ulidEncoding[value&0x1F]
+			}
+			return string(id[:]), nil
+		},
+	}
+}
+
+const (
+	// ksuidEpoch is the start of the KSUID timestamps.
+	ksuidEpoch = 1400000000
+
+	ksuidEncoding = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	ksuidLength   = 27
+)
+
+// NewKSUIDGenerator returns the generator of the KSUIDs, the 27 characters
+// long strings made of the second timestamp and 128 random bits. The IDs sort
+// by the second they were generated in.
+func NewKSUIDGenerator[T any](get func(tr T) string, set func(tr T, id string) T) IDGenerator[T] {
+	return &_idGenerator[T, string]{
+		get: get,
+		set: set,
+		next: func() (string, error) {
+			var data [20]byte
+			_, err := rand.Read(data[4:])
+			if err != nil {
+				return "", err
+			}
+
+			timestamp := uint32(time.Now().Unix() - ksuidEpoch)
+			data[0], data[1], data[2], data[3] = byte(timestamp>>24), byte(timestamp>>16), byte(timestamp>>8), byte(timestamp)
+
+			var (
+				value = new(big.Int).SetBytes(data[:])
+				base  = big.NewInt(int64(len(ksuidEncoding)))
+				mod   = new(big.Int)
+				id    [ksuidLength]byte
+			)
+			for i := len(id) - 1; i >= 0; i-- {
+				value.DivMod(value, base, mod)
+				id[i] = ksuidEncoding[mod.Int64()]
+			}
+			return string(id[:]), nil
+		},
+	}
+}
+
+const (
+	// snowflakeEpoch is the start of the snowflake timestamps, 2020-01-01.
+	snowflakeEpoch = 1577836800000
+
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+
+	// SnowflakeMaxNodeID is the largest node ID of the snowflake generator.
+	SnowflakeMaxNodeID = 1<<snowflakeNodeBits - 1
+)
+
+// NewSnowflakeGenerator returns the generator of the snowflake IDs, the
+// uint64 made of the millisecond timestamp, the node ID and the sequence
+// number. The IDs are unique across the nodes with different IDs and they
+// increase on the node. The node ID must not exceed SnowflakeMaxNodeID.
+func NewSnowflakeGenerator[T any](nodeID uint16, get func(tr T) uint64, set func(tr T, id uint64) T) IDGenerator[T] {
+	var (
+		mutex      sync.Mutex
+		lastMillis int64
+		sequence   uint64
+	)
+
+	return &_idGenerator[T, uint64]{
+		get: get,
+		set: set,
+		next: func() (uint64, error) {
+			if nodeID > SnowflakeMaxNodeID {
+				return 0, fmt.Errorf("snowflake node id %d exceeds %d", nodeID, SnowflakeMaxNodeID)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			millis := time.Now().UnixMilli() - snowflakeEpoch
+			if millis <= lastMillis {
+				// the clock did not move or moved back
+				millis = lastMillis
+				sequence = (sequence + 1) & (1<<snowflakeSequenceBits - 1)
+				if sequence == 0 {
+					// the sequence is used up, wait for the next millisecond
+					for millis <= lastMillis {
+						time.Sleep(100 * time.Microsecond)
+						millis = time.Now().UnixMilli() - snowflakeEpoch
+					}
+				}
+			} else {
+				sequence = 0
+			}
+			lastMillis = millis
+
+			return uint64(millis)<<(snowflakeNodeBits+snowflakeSequenceBits) |
+				uint64(nodeID)<<snowflakeSequenceBits | sequence, nil
+		},
+	}
+}
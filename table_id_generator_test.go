@@ -0,0 +1,117 @@
+package bond
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type idGeneratorEvent struct {
+	ID   string
+	Name string
+}
+
+func TestBond_Table_IDGenerator_Snowflake(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		IDGenerator: NewSnowflakeGenerator[*TokenBalance](1,
+			func(tb *TokenBalance) uint64 { return tb.ID },
+			func(tb *TokenBalance, id uint64) *TokenBalance { tb.ID = id; return tb },
+		),
+	})
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < 5000; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{AccountAddress: "0xtestAccount", Balance: uint64(i)})
+	}
+	tokenBalances = append(tokenBalances, &TokenBalance{ID: 7, AccountAddress: "0xtestAccount", Balance: 5000})
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	// the IDs increase in the order of the rows
+	for i := 1; i < 5000; i++ {
+		require.Greater(t, tokenBalances[i].ID, tokenBalances[i-1].ID)
+	}
+	assert.Equal(t, uint64(1), tokenBalances[0].ID>>snowflakeSequenceBits&SnowflakeMaxNodeID)
+
+	// the set ID is kept
+	assert.Equal(t, uint64(7), tokenBalances[5000].ID)
+
+	var stored []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &stored)
+	require.NoError(t, err)
+	assert.Len(t, stored, 5001)
+}
+
+func TestBond_Table_IDGenerator_ULID_KSUID(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	generators := map[string]IDGenerator[idGeneratorEvent]{
+		"ulid":  NewULIDGenerator[idGeneratorEvent](idGeneratorEventID, setIDGeneratorEventID),
+		"ksuid": NewKSUIDGenerator[idGeneratorEvent](idGeneratorEventID, setIDGeneratorEventID),
+	}
+	lengths := map[string]int{"ulid": 26, "ksuid": ksuidLength}
+
+	tableID := TableID(1)
+	for name, generator := range generators {
+		t.Run(name, func(t *testing.T) {
+			eventTable := NewTable[idGeneratorEvent](TableOptions[idGeneratorEvent]{
+				DB:        db,
+				TableID:   tableID,
+				TableName: "event_" + name,
+				TablePrimaryKeyFunc: func(builder KeyBuilder, e idGeneratorEvent) []byte {
+					return builder.AddStringField(e.ID).Bytes()
+				},
+				IDGenerator: generator,
+			})
+			tableID++
+
+			events := make([]idGeneratorEvent, 100)
+			for i := range events {
+				events[i].Name = "event"
+			}
+
+			err := eventTable.Insert(context.Background(), events)
+			require.NoError(t, err)
+
+			ids := make(map[string]bool)
+			for _, event := range events {
+				require.Len(t, event.ID, lengths[name])
+				ids[event.ID] = true
+			}
+			assert.Len(t, ids, len(events))
+
+			if name == "ulid" {
+				assert.True(t, sort.SliceIsSorted(events, func(i, j int) bool {
+					return events[i].ID < events[j].ID
+				}))
+			}
+
+			event, err := eventTable.Get(events[0])
+			require.NoError(t, err)
+			assert.Equal(t, events[0], event)
+		})
+	}
+}
+
+func idGeneratorEventID(e idGeneratorEvent) string {
+	return e.ID
+}
+
+func setIDGeneratorEventID(e idGeneratorEvent, id string) idGeneratorEvent {
+	e.ID = id
+	return e
+}
@@ -0,0 +1,470 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+	"golang.org/x/exp/maps"
+)
+
+// _indexBuild is the index that is backfilled. The writes maintain its entries
+// from the moment the build starts, but the queries can not use it until the
+// backfill is done and the index is swapped into the secondary indexes.
+type _indexBuild[T any] struct {
+	index *Index[T]
+
+	// writeHooks are the hooks of the index features, they see only the
+	// changes of the rows that were already backfilled, as the backfill
+	// accounts for the rest. The indexHooks see all the changes once the
+	// index is built.
+	writeHooks []_writeHook[T]
+	indexHooks []_writeHook[T]
+
+	// cursor is the key of the last backfilled row, done is set once all the
+	// rows are backfilled. They are changed with the write mutex held for
+	// writing, so the writes, which hold it for reading, see them unchanged.
+	cursor []byte
+	done   bool
+
+	// rows is the number of the backfilled rows.
+	rows uint64
+}
+
+// _indexBuildCheckpoint is the persisted progress of the index build. It's
+// written with every backfilled batch, so the build interrupted by the crash
+// or the restart resumes after the last backfilled row once the index is
+// added again.
+type _indexBuildCheckpoint struct {
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+	Cursor      []byte `json:"cursor"`
+	Rows        uint64 `json:"rows"`
+}
+
+// builtChanges returns the changes of the rows the backfill already went past.
+func (b *_indexBuild[T]) builtChanges(t *_table[T], changes []_rowChange[T]) []_rowChange[T] {
+	if b.done {
+		return changes
+	}
+	if b.cursor == nil {
+		return nil
+	}
+
+	var (
+		built     []_rowChange[T]
+		keyBuffer [DataKeyBufferSize]byte
+	)
+	for _, change := range changes {
+		tr := change.new
+		if !change.hasNew {
+			tr = change.old
+		}
+
+		if bytes.Compare(t.key(tr, keyBuffer[:0]), b.cursor) <= 0 {
+			built = append(built, change)
+		}
+	}
+	return built
+}
+
+func (b *_indexBuild[T]) hook(t *_table[T], hook _writeHook[T]) _writeHook[T] {
+	return func(ctx context.Context, batch Batch, changes []_rowChange[T]) error {
+		changes = b.builtChanges(t, changes)
+		if len(changes) == 0 {
+			return nil
+		}
+		return hook(ctx, batch, changes)
+	}
+}
+
+// beginWrite returns the indexes and the write hooks of the write, the indexes
+// that are built included, and the function that ends the write. The backfill
+// of the indexes waits for the writes in progress, so it does not overwrite
+// the entries of the rows they change with the stale ones.
+func (t *_table[T]) beginWrite() (map[IndexID]*Index[T], []_writeHook[T], func()) {
+	t.writeMutex.RLock()
+
+	indexes, writeHooks := t.writeIndexes()
+	return indexes, writeHooks, t.writeMutex.RUnlock
+}
+
+// writeIndexes returns the indexes and the write hooks of the write.
+func (t *_table[T]) writeIndexes() (map[IndexID]*Index[T], []_writeHook[T]) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	indexes := make(map[IndexID]*Index[T], len(t.secondaryIndexes)+len(t.indexBuilds))
+	maps.Copy(indexes, t.secondaryIndexes)
+	writeHooks := t.writeHooks
+	for id, build := range t.indexBuilds {
+		indexes[id] = build.index
+		writeHooks = append(writeHooks[:len(writeHooks):len(writeHooks)], build.writeHooks...)
+	}
+	return indexes, writeHooks
+}
+
+// initIndex prepares the index to be added to the table and returns the write
+// hooks of its features.
+func (t *_table[T]) initIndex(idx *Index[T]) ([]_writeHook[T], error) {
+	idx.db, idx.tableID, idx.storage = t.db, t.id, t.storage
+
+	var writeHooks []_writeHook[T]
+	if idx.IndexApproxDistinctFunction != nil {
+		writeHooks = append(writeHooks, idx.updateSketches)
+	}
+
+	if idx.IndexStatistics {
+		idx.stats = &_indexStats{persisted: time.Now()}
+		if err := idx.loadStats(); err != nil {
+			return nil, err
+		}
+		writeHooks = append(writeHooks, idx.updateStats)
+	}
+
+	if idx.IndexReferenceFunction != nil {
+		writeHooks = append(writeHooks, t.updateIndexReferences(idx))
+	}
+	return writeHooks, nil
+}
+
+// buildIndexes adds the indexes and backfills them while the table is read
+// and written. The indexes are maintained by the writes from the start of the
+// build, the backfill goes through the rows in batches and the indexes are
+// swapped into the secondary indexes, so the queries can use them, once all
+// the rows are backfilled. The writes with the external batches must be
+// committed before the build starts. The progress is checkpointed with every
+// batch, the build that did not finish resumes from the checkpoint.
+func (t *_table[T]) buildIndexes(idxs []*Index[T]) error {
+	builds, err := t.startIndexBuilds(idxs)
+	if err == nil {
+		err = t.backfillIndexes(builds)
+	}
+
+	t.finishIndexBuilds(builds, err)
+	return err
+}
+
+// finishIndexBuilds swaps the built indexes into the secondary indexes, or
+// drops them if the build failed with the error.
+func (t *_table[T]) finishIndexBuilds(builds []*_indexBuild[T], err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.clock.Now()
+	for _, build := range builds {
+		delete(t.indexBuilds, build.index.IndexID)
+		if err == nil {
+			t.secondaryIndexes[build.index.IndexID] = build.index
+			t.writeHooks = append(t.writeHooks, build.indexHooks...)
+		}
+
+		build.index.buildStatus.update(func(status *IndexBuildStatus) {
+			status.Building, status.FinishedAt, status.Err = false, now, err
+		})
+	}
+}
+
+// startIndexBuilds registers the indexes as built and removes their previous
+// entries. No writes are in progress meanwhile, so the entries they make from
+// now on are kept.
+func (t *_table[T]) startIndexBuilds(idxs []*Index[T]) ([]*_indexBuild[T], error) {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	t.mutex.Lock()
+	if err := t.checkIndexes(idxs); err != nil {
+		t.mutex.Unlock()
+		return nil, err
+	}
+
+	if err := t.registerIndexes(idxs); err != nil {
+		t.mutex.Unlock()
+		return nil, err
+	}
+
+	var builds []*_indexBuild[T]
+	for _, idx := range idxs {
+		writeHooks, err := t.initIndex(idx)
+		if err != nil {
+			t.mutex.Unlock()
+			return builds, err
+		}
+
+		now := t.clock.Now()
+		idx.buildStatus.update(func(status *IndexBuildStatus) {
+			*status = IndexBuildStatus{Building: true, StartedAt: now, UpdatedAt: now}
+		})
+
+		build := &_indexBuild[T]{index: idx, indexHooks: writeHooks}
+		for _, hook := range writeHooks {
+			build.writeHooks = append(build.writeHooks, build.hook(t, hook))
+		}
+		builds = append(builds, build)
+
+		// the index that is rebuilt can not be queried until it's done
+		delete(t.secondaryIndexes, idx.IndexID)
+		t.indexBuilds[idx.IndexID] = build
+	}
+	t.mutex.Unlock()
+
+	checkpoint, ok, err := t.loadIndexBuildCheckpoint(idxs)
+	if err != nil {
+		return builds, err
+	}
+
+	if ok {
+		for _, build := range builds {
+			build.cursor, build.rows = checkpoint.Cursor, checkpoint.Rows
+			build.index.buildStatus.update(func(status *IndexBuildStatus) {
+				status.Rows = checkpoint.Rows
+			})
+		}
+		return builds, nil
+	}
+
+	for _, idx := range idxs {
+		err = t.db.Delete(indexBuildCheckpointKey(t.storageID(), idx.IndexID), Sync)
+		if err != nil {
+			return builds, fmt.Errorf("failed to delete index build checkpoint: %w", err)
+		}
+
+		if idx.stats != nil {
+			idx.resetStats()
+		}
+		err = t.db.DeleteRange(
+			[]byte{byte(t.storageID()), byte(idx.IndexID)},
+			[]byte{byte(t.storageID()), byte(idx.IndexID + 1)}, Sync)
+		if err != nil {
+			return builds, fmt.Errorf("failed to delete index: %w", err)
+		}
+
+		if idx.IndexReferenceFunction != nil {
+			referencePrefix := []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_INDEX_REFERENCE_INDEX_ID, byte(t.storageID()), byte(idx.IndexID)}
+			err = t.db.DeleteRange(referencePrefix, append(referencePrefix[:3:3], byte(idx.IndexID+1)), Sync)
+			if err != nil {
+				return builds, fmt.Errorf("failed to delete index references: %w", err)
+			}
+		}
+	}
+	return builds, nil
+}
+
+// backfillIndexes writes the entries of the existing rows to the indexes. Every
+// batch of the rows is read and indexed with the writes on hold.
+func (t *_table[T]) backfillIndexes(builds []*_indexBuild[T]) error {
+	progress := IndexBuildProgress{TableID: t.id, TableName: t.name, Rows: builds[0].rows}
+	for _, build := range builds {
+		progress.IndexIDs = append(progress.IndexIDs, build.index.IndexID)
+	}
+
+	for {
+		rows, done, err := t.backfillIndexBatch(builds)
+		if err != nil {
+			return err
+		}
+
+		progress.Rows += uint64(rows)
+		progress.Done = done
+		t.notifyIndexBuildProgress(progress)
+
+		if done {
+			return nil
+		}
+	}
+}
+
+// backfillIndexBatch indexes up to ReindexBatchSize rows after the build cursor.
+func (t *_table[T]) backfillIndexBatch(builds []*_indexBuild[T]) (int, bool, error) {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	idxs := make([]*Index[T], 0, len(builds))
+	idxsMap := make(map[IndexID]*Index[T], len(builds))
+	for _, build := range builds {
+		idxs = append(idxs, build.index)
+		idxsMap[build.index.IndexID] = build.index
+	}
+
+	lowerBound := []byte{byte(t.storageID()), byte(PrimaryIndexID)}
+	if cursor := builds[0].cursor; cursor != nil {
+		lowerBound = append(cursor[:len(cursor):len(cursor)], 0x00)
+	}
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: lowerBound,
+			UpperBound: []byte{byte(t.storageID()), byte(PrimaryIndexID + 1)},
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	batch := t.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	var (
+		rows            int
+		cursor          []byte
+		indexKeysBuffer = make([]byte, 0, (PrimaryKeyBufferSize+IndexKeyBufferSize)*len(idxs))
+		indexKeys       = make([][]byte, 0, len(idxs))
+		statsDeltas     = make(map[IndexID]*_indexStatsDelta, len(idxs))
+	)
+
+	for _, idx := range idxs {
+		if idx.stats != nil {
+			statsDeltas[idx.IndexID] = &_indexStatsDelta{}
+		}
+	}
+
+	for iter.First(); iter.Valid() && rows < ReindexBatchSize; iter.Next() {
+		var tr T
+
+		err := t.serializer.Deserialize(iter.Value(), &tr)
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to deserialize %s during reindexing: %w", FormatKey(iter.Key()), err)
+		}
+
+		indexKeys, err = t.safeIndexKeys(tr, iter.Key(), idxsMap, indexKeysBuffer[:0], indexKeys[:0])
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to build index keys during reindexing: %w", err)
+		}
+
+		for _, idx := range idxs {
+			if idx.IndexApproxDistinctFunction != nil {
+				err = idx.updateSketch(batch, tr)
+				if err != nil {
+					return 0, false, fmt.Errorf("failed to update index sketch during reindexing: %w", err)
+				}
+			}
+			if idx.stats != nil {
+				idx.addStats(statsDeltas[idx.IndexID], tr, 1)
+			}
+			if idx.IndexReferenceFunction != nil {
+				err = t.setIndexReference(batch, idx, tr, false)
+				if err != nil {
+					return 0, false, fmt.Errorf("failed to set index reference during reindexing: %w", err)
+				}
+			}
+		}
+
+		for _, indexKey := range indexKeys {
+			err = batch.Set(indexKey, t.indexEntryValue(indexKey, idxsMap, tr), Sync)
+			if err != nil {
+				return 0, false, fmt.Errorf("failed to set index key during reindexing: %w", err)
+			}
+		}
+
+		cursor = append(cursor[:0], iter.Key()...)
+		rows++
+	}
+
+	if cursor == nil {
+		cursor = builds[0].cursor
+	}
+
+	done := !iter.Valid()
+	for _, build := range builds {
+		idx := build.index
+		if idx.stats != nil {
+			delta := statsDeltas[idx.IndexID]
+			idx.stats.mutex.Lock()
+			err := idx.persistStats(batch, delta)
+			idx.stats.mutex.Unlock()
+			if err != nil {
+				return 0, false, fmt.Errorf("failed to persist index statistics during reindexing: %w", err)
+			}
+
+			batch.AfterCommit(func(CommittedBatchInfo) {
+				idx.applyStats(delta)
+			})
+		}
+
+		err := t.setIndexBuildCheckpoint(batch, idx, cursor, build.rows+uint64(rows), done)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	err := batch.Commit(Sync)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to commit reindex batch: %w", err)
+	}
+
+	now := t.clock.Now()
+	for _, build := range builds {
+		build.cursor, build.done = cursor, done
+		build.rows += uint64(rows)
+		build.index.buildStatus.update(func(status *IndexBuildStatus) {
+			status.Rows, status.UpdatedAt = build.rows, now
+		})
+	}
+	return rows, done, nil
+}
+
+// setIndexBuildCheckpoint writes the progress of the index build to the batch,
+// the checkpoint of the finished build is removed.
+func (t *_table[T]) setIndexBuildCheckpoint(batch Batch, idx *Index[T], cursor []byte, rows uint64, done bool) error {
+	key := indexBuildCheckpointKey(t.storageID(), idx.IndexID)
+	if done {
+		return batch.Delete(key, Sync)
+	}
+
+	data, err := json.Marshal(_indexBuildCheckpoint{
+		Name:        idx.IndexName,
+		Fingerprint: indexFingerprint(idx),
+		Cursor:      cursor,
+		Rows:        rows,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = batch.Set(key, data, Sync)
+	if err != nil {
+		return fmt.Errorf("failed to write index build checkpoint: %w", err)
+	}
+	return nil
+}
+
+// loadIndexBuildCheckpoint returns the checkpoint the build of the indexes
+// resumes from. The build resumes if all the indexes were built together and
+// they did not change since, otherwise it starts over. The rows written in the
+// meantime by the process that did not add the indexes are not indexed, so
+// the indexes must be added before the table is written to.
+func (t *_table[T]) loadIndexBuildCheckpoint(idxs []*Index[T]) (_indexBuildCheckpoint, bool, error) {
+	var checkpoint _indexBuildCheckpoint
+	for i, idx := range idxs {
+		data, closer, err := t.db.Get(indexBuildCheckpointKey(t.storageID(), idx.IndexID))
+		if errors.Is(err, ErrNotFound) {
+			return _indexBuildCheckpoint{}, false, nil
+		} else if err != nil {
+			return _indexBuildCheckpoint{}, false, err
+		}
+
+		var indexCheckpoint _indexBuildCheckpoint
+		err = json.Unmarshal(data, &indexCheckpoint)
+		_ = closer.Close()
+		if err != nil {
+			return _indexBuildCheckpoint{}, false, fmt.Errorf("failed to read index build checkpoint: %w", err)
+		}
+
+		if indexCheckpoint.Name != idx.IndexName || indexCheckpoint.Fingerprint != indexFingerprint(idx) ||
+			(i > 0 && !bytes.Equal(indexCheckpoint.Cursor, checkpoint.Cursor)) {
+			return _indexBuildCheckpoint{}, false, nil
+		}
+		checkpoint = indexCheckpoint
+	}
+	return checkpoint, len(idxs) > 0, nil
+}
+
+func indexBuildCheckpointKey(tableID TableID, indexID IndexID) []byte {
+	return []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_INDEX_BUILD_INDEX_ID, byte(tableID), byte(indexID)}
+}
@@ -0,0 +1,313 @@
+package bond
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_AddIndex_ConcurrentWrites(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	const rows = ReindexBatchSize*2 + 500
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < rows; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: "0xtestContract",
+			AccountAddress:  fmt.Sprintf("0xtestAccount%d", i%10),
+			Balance:         uint64(i),
+		})
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	accountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc:  IndexOrderDefault[*TokenBalance],
+		IndexStatistics: true,
+	})
+
+	var (
+		wg       sync.WaitGroup
+		stop     = make(chan struct{})
+		writeErr error
+	)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		// move the rows from the start and the end of the table to the other
+		// account, delete some of them and insert the new ones
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+
+			id := uint64(i%100) + 1
+			if i%2 == 1 {
+				id = uint64(rows - i%100)
+			}
+
+			tb := &TokenBalance{
+				ID:              id,
+				ContractAddress: "0xtestContract",
+				AccountAddress:  fmt.Sprintf("0xtestAccountMoved%d", i%3),
+				Balance:         uint64(i),
+			}
+
+			var err error
+			switch i % 5 {
+			case 0:
+				// the index entries are removed by the stored row
+				var stored *TokenBalance
+				stored, err = tokenBalanceTable.Get(tb)
+				if err == nil {
+					err = tokenBalanceTable.Delete(context.Background(), []*TokenBalance{stored})
+				} else if errors.Is(err, ErrNotFound) {
+					err = nil
+				}
+			case 1:
+				tb.ID = uint64(rows + i + 1)
+				err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tb})
+			default:
+				err = tokenBalanceTable.Upsert(context.Background(), []*TokenBalance{tb}, TableUpsertOnConflictReplace[*TokenBalance])
+			}
+			if err != nil {
+				writeErr = err
+				return
+			}
+		}
+	}()
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{accountAddressIndex}, true)
+	close(stop)
+	wg.Wait()
+	require.NoError(t, err)
+	require.NoError(t, writeErr)
+
+	var all []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &all)
+	require.NoError(t, err)
+
+	expected := make(map[string]int)
+	for _, tb := range all {
+		expected[tb.AccountAddress]++
+	}
+
+	// every row is in the index once, under its current account
+	var indexed int
+	for account, count := range expected {
+		var accountRows []*TokenBalance
+		err = tokenBalanceTable.Query().
+			With(accountAddressIndex, &TokenBalance{AccountAddress: account}).
+			Execute(context.Background(), &accountRows)
+		require.NoError(t, err)
+		require.Len(t, accountRows, count, account)
+
+		for _, tb := range accountRows {
+			require.Equal(t, account, tb.AccountAddress)
+		}
+		indexed += len(accountRows)
+	}
+	assert.Equal(t, len(all), indexed)
+
+	stats, err := accountAddressIndex.Statistics()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(len(all)), stats.Entries)
+}
+
+func TestBond_Table_AddIndex_NotQueryableDuringBuild(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount", Balance: 5},
+	})
+	require.NoError(t, err)
+
+	accountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	table := tokenBalanceTable.(*_table[*TokenBalance])
+	builds, err := table.startIndexBuilds([]*Index[*TokenBalance]{accountAddressIndex})
+	require.NoError(t, err)
+
+	// the writes maintain the index that is built
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 2, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount", Balance: 7},
+	})
+	require.NoError(t, err)
+
+	var trs []*TokenBalance
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Execute(context.Background(), &trs)
+	require.ErrorIs(t, err, ErrIndexNotRegistered)
+	require.ErrorIs(t, err, ErrIndexBuilding)
+
+	_, err = accountAddressIndex.DistinctPrefixes(context.Background(), 1, 0, nil)
+	require.ErrorIs(t, err, ErrIndexBuilding)
+
+	status := accountAddressIndex.BuildStatus()
+	assert.True(t, status.Building)
+	assert.Zero(t, status.Rows)
+	assert.False(t, status.StartedAt.IsZero())
+
+	err = table.backfillIndexes(builds)
+	require.NoError(t, err)
+
+	status = accountAddressIndex.BuildStatus()
+	assert.True(t, status.Building)
+	assert.Equal(t, uint64(2), status.Rows)
+
+	table.finishIndexBuilds(builds, nil)
+
+	status = accountAddressIndex.BuildStatus()
+	assert.False(t, status.Building)
+	assert.False(t, status.FinishedAt.IsZero())
+	assert.NoError(t, status.Err)
+
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Execute(context.Background(), &trs)
+	require.NoError(t, err)
+	assert.Len(t, trs, 2)
+}
+
+func TestBond_Table_AddIndex_ResumeFromCheckpoint(t *testing.T) {
+	const dbName = "test_db_index_build_resume"
+	defer func() {
+		_ = os.RemoveAll(dbName)
+	}()
+
+	var progress []IndexBuildProgress
+	openTable := func() (DB, Table[*TokenBalance]) {
+		db, err := Open(dbName, &Options{
+			OnIndexBuildProgress: func(p IndexBuildProgress) {
+				progress = append(progress, p)
+			},
+		})
+		require.NoError(t, err)
+
+		return db, NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   TableID(1),
+			TableName: "token_balance",
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+		})
+	}
+
+	newAccountAddressIndex := func() *Index[*TokenBalance] {
+		return NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 1,
+			IndexName: "account_address_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.AccountAddress).Bytes()
+			},
+			IndexOrderFunc:  IndexOrderDefault[*TokenBalance],
+			IndexStatistics: true,
+		})
+	}
+
+	db, tokenBalanceTable := openTable()
+
+	const rows = ReindexBatchSize*2 + 500
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < rows; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			ContractAddress: "0xtestContract",
+			AccountAddress:  fmt.Sprintf("0xtestAccount%d", i%2),
+			Balance:         uint64(i),
+		})
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	// the build is interrupted after the first batch
+	table := tokenBalanceTable.(*_table[*TokenBalance])
+	builds, err := table.startIndexBuilds([]*Index[*TokenBalance]{newAccountAddressIndex()})
+	require.NoError(t, err)
+
+	backfilled, done, err := table.backfillIndexBatch(builds)
+	require.NoError(t, err)
+	require.False(t, done)
+	require.Equal(t, ReindexBatchSize, backfilled)
+
+	require.NoError(t, db.Close())
+
+	// the build resumes after the restart
+	db, tokenBalanceTable = openTable()
+	defer func() {
+		_ = db.Close()
+	}()
+
+	accountAddressIndex := newAccountAddressIndex()
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{accountAddressIndex}, true)
+	require.NoError(t, err)
+
+	require.Len(t, progress, 2)
+	assert.Equal(t, uint64(ReindexBatchSize*2), progress[0].Rows)
+	assert.Equal(t, uint64(rows), progress[1].Rows)
+	assert.True(t, progress[1].Done)
+
+	var trs []*TokenBalance
+	err = tokenBalanceTable.Query().
+		With(accountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount1"}).
+		Execute(context.Background(), &trs)
+	require.NoError(t, err)
+	assert.Len(t, trs, rows/2)
+
+	stats, err := accountAddressIndex.Statistics()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(rows), stats.Entries)
+
+	// the checkpoint of the finished build is removed
+	_, _, err = db.Get(indexBuildCheckpointKey(TableID(1), accountAddressIndex.IndexID))
+	assert.ErrorIs(t, err, ErrNotFound)
+}
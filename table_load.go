@@ -0,0 +1,109 @@
+package bond
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-bond/bond/utils"
+)
+
+// TableLoader reads the rows through from the source of truth.
+type TableLoader[T any] interface {
+	GetOrLoad(ctx context.Context, tr T, loader func(ctx context.Context) (T, error)) (T, error)
+}
+
+type _load[T any] struct {
+	done chan struct{}
+	row  T
+	err  error
+}
+
+// _loads are the loads in flight by the primary key.
+type _loads[T any] struct {
+	mutex    sync.Mutex
+	inFlight map[string]*_load[T]
+}
+
+// GetOrLoad retrieves the row of the selector, or loads it with the loader
+// and inserts it if it does not exist, e.g. to use the table as the cache of
+// the slower store. The concurrent calls for the same missing row wait for
+// the single loader call and get its result, including its error or the
+// cancellation of its context. The loaded row is expected to have the primary
+// key of the selector. The panic of the loader is returned as the error.
+//
+// Example:
+//
+//	tb, err := tokenBalanceTable.GetOrLoad(ctx, &TokenBalance{ID: id},
+//		func(ctx context.Context) (*TokenBalance, error) {
+//			return fetchTokenBalance(ctx, id)
+//		})
+func (t *_table[T]) GetOrLoad(ctx context.Context, tr T, loader func(ctx context.Context) (T, error)) (T, error) {
+	select {
+	case <-ctx.Done():
+		return utils.MakeNew[T](), fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	row, err := t.Get(tr)
+	if !errors.Is(err, ErrNotFound) {
+		return row, err
+	}
+
+	var keyBuffer [DataKeyBufferSize]byte
+	key := string(t.key(tr, keyBuffer[:0]))
+
+	t.loads.mutex.Lock()
+	if load, ok := t.loads.inFlight[key]; ok {
+		t.loads.mutex.Unlock()
+
+		select {
+		case <-load.done:
+			return load.row, load.err
+		case <-ctx.Done():
+			return utils.MakeNew[T](), fmt.Errorf("context done: %w", ctx.Err())
+		}
+	}
+
+	if t.loads.inFlight == nil {
+		t.loads.inFlight = make(map[string]*_load[T])
+	}
+	load := &_load[T]{done: make(chan struct{})}
+	t.loads.inFlight[key] = load
+	t.loads.mutex.Unlock()
+
+	load.row, load.err = t.load(ctx, tr, []byte(key), loader)
+
+	t.loads.mutex.Lock()
+	delete(t.loads.inFlight, key)
+	t.loads.mutex.Unlock()
+	close(load.done)
+
+	return load.row, load.err
+}
+
+// load calls the loader and inserts the row, unless it was written since it
+// was not found.
+func (t *_table[T]) load(ctx context.Context, tr T, key []byte, loader func(ctx context.Context) (T, error)) (row T, err error) {
+	row, err = t.Get(tr)
+	if !errors.Is(err, ErrNotFound) {
+		return row, err
+	}
+
+	row, err = func() (row T, err error) {
+		defer t.recoverPanic(&err, nil, key, "loader")
+		return loader(ctx)
+	}()
+	if err != nil {
+		return utils.MakeNew[T](), err
+	}
+
+	err = t.Insert(ctx, []T{row})
+	if errors.Is(err, ErrKeyExists) {
+		return t.Get(tr)
+	} else if err != nil {
+		return utils.MakeNew[T](), err
+	}
+	return row, nil
+}
@@ -0,0 +1,74 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_GetOrLoad(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	tokenBalance := &TokenBalance{ID: 1, AccountAddress: "0xtestAccount", Balance: 5}
+
+	var loads int32
+	loader := func(ctx context.Context) (*TokenBalance, error) {
+		atomic.AddInt32(&loads, 1)
+		time.Sleep(50 * time.Millisecond)
+		return tokenBalance, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			tb, err := tokenBalanceTable.GetOrLoad(context.Background(), &TokenBalance{ID: 1}, loader)
+			assert.NoError(t, err)
+			assert.Equal(t, tokenBalance, tb)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	tb, err := tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalance, tb)
+
+	// the row is read through
+	tb, err = tokenBalanceTable.GetOrLoad(context.Background(), &TokenBalance{ID: 1}, loader)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalance, tb)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// the error of the loader is not cached
+	errLoad := fmt.Errorf("load failed")
+	_, err = tokenBalanceTable.GetOrLoad(context.Background(), &TokenBalance{ID: 2}, func(ctx context.Context) (*TokenBalance, error) {
+		return nil, errLoad
+	})
+	require.ErrorIs(t, err, errLoad)
+	assert.False(t, tokenBalanceTable.Exist(&TokenBalance{ID: 2}))
+
+	_, err = tokenBalanceTable.GetOrLoad(context.Background(), &TokenBalance{ID: 2}, func(ctx context.Context) (*TokenBalance, error) {
+		panic("loader panic")
+	})
+	require.ErrorIs(t, err, ErrCallbackPanic)
+}
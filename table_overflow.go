@@ -0,0 +1,495 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// OverflowChunkSize is the size of the chunks the overflow values are split
+// into when they are stored in the database.
+const OverflowChunkSize = 64 << 10
+
+// OverflowStore stores the serialized rows above the overflow threshold of the
+// table, e.g. in the blob storage. The values are never changed once they are
+// put, so the store owns their lifecycle, e.g. keeps them under the hash of
+// their content and expires the ones no longer referenced.
+type OverflowStore interface {
+	// Put stores the value and returns the reference it's read with.
+	Put(table string, value []byte) ([]byte, error)
+	// Get returns the value of the reference.
+	Get(table string, reference []byte) ([]byte, error)
+}
+
+// TableOverflowCollector removes the overflow values no longer referenced by
+// the rows of the table.
+type TableOverflowCollector interface {
+	CollectOverflow(ctx context.Context) error
+}
+
+// overflowMagic marks the end of the stored overflow references, so they are
+// told apart from the rows stored as is.
+var overflowMagic = [8]byte{0xbd, 0x0d, 'o', 'f', 'l', 'o', 'w', 0x01}
+
+const overflowTrailerSize = 4 + len(overflowMagic)
+
+// _overflowSerializer stores the serialized rows above the threshold in the
+// overflow store and the references to them in their place, so the huge rows
+// do not inflate the blocks and the compactions of the table.
+type _overflowSerializer[T any] struct {
+	Serializer Serializer[*T]
+	Threshold  int
+	Store      OverflowStore
+
+	table string
+}
+
+func (s *_overflowSerializer[T]) Serialize(tr *T) ([]byte, error) {
+	data, err := s.Serializer.Serialize(tr)
+	if err != nil || len(data) <= s.Threshold {
+		return data, err
+	}
+
+	reference, err := s.Store.Put(s.table, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store overflow value: %w", err)
+	}
+	return encodeOverflowReference(reference), nil
+}
+
+// serializeFor returns the function that writes the overflow chunks of the row
+// to the batch of the row, so they are committed, or dropped, along with it.
+func (s *_overflowSerializer[T]) serializeFor(tr *T) ([]byte, func(batch Batch) error, error) {
+	data, write, err := serializeFor(s.Serializer, tr)
+	if err != nil || len(data) <= s.Threshold {
+		return data, write, err
+	}
+
+	chunks, ok := s.Store.(*_overflowChunks)
+	if !ok {
+		reference, err := s.Store.Put(s.table, data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to store overflow value: %w", err)
+		}
+		return encodeOverflowReference(reference), write, nil
+	}
+
+	reference, chunksWrite := chunks.put(data)
+	return encodeOverflowReference(reference), joinWrites(write, chunksWrite), nil
+}
+
+func (s *_overflowSerializer[T]) Deserialize(b []byte, tr *T) error {
+	return s.deserializeFrom(nil, b, tr)
+}
+
+// deserializeFrom reads the overflow chunks of the row from the batch or the
+// snapshot the row is read from, so the rows of the snapshot read the chunks
+// removed by CollectOverflow since it was taken.
+func (s *_overflowSerializer[T]) deserializeFrom(batch Batch, b []byte, tr *T) error {
+	reference, ok, err := splitOverflowReference(b)
+	if err != nil {
+		return err
+	} else if !ok {
+		return deserializeFrom(s.Serializer, batch, b, tr)
+	}
+
+	var data []byte
+	if chunks, ok := s.Store.(*_overflowChunks); ok {
+		data, err = chunks.get(reference, batch)
+	} else {
+		data, err = s.Store.Get(s.table, reference)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read overflow value: %w", err)
+	}
+	return s.Serializer.Deserialize(data, tr)
+}
+
+// _batchDeserializer is the serializer that reads the data the rows refer to
+// from the batch or the snapshot the rows are read from.
+type _batchDeserializer[T any] interface {
+	deserializeFrom(batch Batch, b []byte, tr *T) error
+}
+
+// deserializeFrom deserializes the row read from the batch or the snapshot,
+// nil for the database.
+func deserializeFrom[T any](serializer Serializer[*T], batch Batch, b []byte, tr *T) error {
+	if s, ok := serializer.(_batchDeserializer[T]); ok && batch != nil {
+		return s.deserializeFrom(batch, b, tr)
+	}
+	return serializer.Deserialize(b, tr)
+}
+
+// deserialize deserializes the row read from the batch, nil for the database.
+func (t *_table[T]) deserialize(data []byte, tr *T, batch Batch) error {
+	return deserializeFrom(t.serializer, batch, data, tr)
+}
+
+// _batchSerializer is the serializer that stores the data the rows refer to
+// along with the rows. The returned function writes the data to the batch the
+// row is written to, nil if there is nothing to write, so the rows prepared
+// concurrently write it once they are added to the batch.
+type _batchSerializer[T any] interface {
+	serializeFor(tr *T) ([]byte, func(batch Batch) error, error)
+}
+
+// serializeFor serializes the row and returns the function that writes the
+// data the row refers to, if any.
+func serializeFor[T any](serializer Serializer[*T], tr *T) ([]byte, func(batch Batch) error, error) {
+	if s, ok := serializer.(_batchSerializer[T]); ok {
+		return s.serializeFor(tr)
+	}
+
+	data, err := serializer.Serialize(tr)
+	return data, nil, err
+}
+
+// joinWrites returns the function that runs the writes, nil if there are none.
+func joinWrites(writes ...func(batch Batch) error) func(batch Batch) error {
+	var joined []func(batch Batch) error
+	for _, write := range writes {
+		if write != nil {
+			joined = append(joined, write)
+		}
+	}
+
+	switch len(joined) {
+	case 0:
+		return nil
+	case 1:
+		return joined[0]
+	}
+
+	return func(batch Batch) error {
+		for _, write := range joined {
+			err := write(batch)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// serialize serializes the row written to the batch.
+func (t *_table[T]) serialize(tr *T, batch Batch) ([]byte, error) {
+	data, write, err := serializeFor(t.serializer, tr)
+	if err != nil || write == nil {
+		return data, err
+	}
+
+	err = write(batch)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// encodeOverflowReference returns the stored reference: the reference, its
+// length and the magic.
+func encodeOverflowReference(reference []byte) []byte {
+	record := make([]byte, len(reference)+overflowTrailerSize)
+	copy(record, reference)
+	binary.BigEndian.PutUint32(record[len(reference):], uint32(len(reference)))
+	copy(record[len(reference)+4:], overflowMagic[:])
+	return record
+}
+
+// splitOverflowReference returns the reference stored in place of the row, or
+// false if the row is stored as is.
+func splitOverflowReference(b []byte) ([]byte, bool, error) {
+	if len(b) < overflowTrailerSize || !bytes.Equal(b[len(b)-len(overflowMagic):], overflowMagic[:]) {
+		return nil, false, nil
+	}
+
+	length := int(binary.BigEndian.Uint32(b[len(b)-overflowTrailerSize:]))
+	if length != len(b)-overflowTrailerSize {
+		return nil, false, fmt.Errorf("invalid overflow reference length %d", length)
+	}
+	return b[:length], true, nil
+}
+
+// _overflowChunks is the overflow store of the table in the database. The
+// values are split into the chunks stored under the SHA-256 hash of the value,
+// which is the reference, so the rows with the same value share the chunks.
+type _overflowChunks struct {
+	db      DB
+	storage *_tableStorage
+
+	// written are the epochs the hashes were last written to a batch at, the
+	// chunks written since the previous collection are kept by the collection
+	written map[[sha256.Size]byte]uint64
+	// stored are the epochs the chunks of the hashes were last committed at,
+	// the chunks stored since the previous collection are not written again
+	stored map[[sha256.Size]byte]uint64
+	epoch  uint64
+
+	// mutex serializes the writes of the chunks with their removal
+	mutex sync.Mutex
+}
+
+func newOverflowChunks(db DB, storage *_tableStorage) *_overflowChunks {
+	return &_overflowChunks{
+		db:      db,
+		storage: storage,
+		written: make(map[[sha256.Size]byte]uint64),
+		stored:  make(map[[sha256.Size]byte]uint64),
+	}
+}
+
+func (c *_overflowChunks) Put(_ string, value []byte) ([]byte, error) {
+	reference, write := c.put(value)
+
+	batch := c.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	err := write(batch)
+	if err != nil {
+		return nil, err
+	}
+
+	err = batch.Commit(Sync)
+	if err != nil {
+		return nil, err
+	}
+	return reference, nil
+}
+
+// put returns the reference of the value and the function that writes its
+// chunks to the batch.
+func (c *_overflowChunks) put(value []byte) ([]byte, func(batch Batch) error) {
+	hash := sha256.Sum256(value)
+
+	return hash[:], func(batch Batch) error {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		c.written[hash] = c.epoch
+
+		// the chunks stored since the previous collection are not removed yet
+		if epoch, ok := c.stored[hash]; ok && epoch+1 >= c.epoch {
+			return nil
+		}
+
+		for i := 0; i*OverflowChunkSize < len(value); i++ {
+			end := (i + 1) * OverflowChunkSize
+			if end > len(value) {
+				end = len(value)
+			}
+
+			err := batch.Set(c.key(hash[:], uint32(i)), value[i*OverflowChunkSize:end], Sync)
+			if err != nil {
+				return err
+			}
+		}
+
+		batch.AfterCommit(func(CommittedBatchInfo) {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+
+			c.stored[hash] = c.epoch
+		})
+		return nil
+	}
+}
+
+func (c *_overflowChunks) Get(_ string, reference []byte) ([]byte, error) {
+	return c.get(reference, nil)
+}
+
+// get reads the value from the batch or the snapshot, nil for the database.
+func (c *_overflowChunks) get(reference []byte, batch Batch) ([]byte, error) {
+	if len(reference) != sha256.Size {
+		return nil, fmt.Errorf("invalid overflow reference %x", reference)
+	}
+
+	prefix := c.key(reference, 0)[:3+sha256.Size]
+	iter := c.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: prefixUpperBound(prefix),
+		},
+	}, batch)
+
+	var value []byte
+	for iter.First(); iter.Valid(); iter.Next() {
+		value = append(value, iter.Value()...)
+	}
+
+	err := iter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if value == nil {
+		return nil, fmt.Errorf("overflow value %x not found", reference)
+	} else if sha256.Sum256(value) != *(*[sha256.Size]byte)(reference) {
+		return nil, fmt.Errorf("overflow value %x is corrupted", reference)
+	}
+	return value, nil
+}
+
+// replaceFrom takes over the written chunks of the staging table, whose chunks
+// the table reads once their storage IDs are swapped. The chunks the staging
+// table reads from then on were removed.
+func (c *_overflowChunks) replaceFrom(staging *_overflowChunks) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if staging == nil {
+		c.written = make(map[[sha256.Size]byte]uint64)
+		c.stored = make(map[[sha256.Size]byte]uint64)
+		return
+	}
+
+	staging.mutex.Lock()
+	defer staging.mutex.Unlock()
+
+	c.written, c.stored, c.epoch = staging.written, staging.stored, staging.epoch
+	staging.written = make(map[[sha256.Size]byte]uint64)
+	staging.stored = make(map[[sha256.Size]byte]uint64)
+}
+
+// key returns the key of the chunk of the value.
+func (c *_overflowChunks) key(hash []byte, chunk uint32) []byte {
+	key := make([]byte, 3+sha256.Size+4)
+	key[0], key[1], key[2] = BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_OVERFLOW_INDEX_ID, byte(c.storage.ID())
+	copy(key[3:], hash)
+	binary.BigEndian.PutUint32(key[3+sha256.Size:], chunk)
+	return key
+}
+
+// CollectOverflow removes the overflow chunks no row refers to. The chunks
+// written since the previous collection are kept, so the writes that are in
+// flight during the collection keep their chunks, the unused chunks are
+// removed by the collection that follows. The rows of the retained snapshots,
+// read with Query.AsOf, read the chunks from the snapshots, so the collection
+// does not remove the chunks they refer to. It's only supported for the tables
+// that store the overflow values in the database, the OverflowStore owns the
+// lifecycle of its values.
+func (t *_table[T]) CollectOverflow(ctx context.Context) error {
+	if t.overflowChunks == nil {
+		return fmt.Errorf("table %s does not store overflow values in the database", t.name)
+	}
+
+	c := t.overflowChunks
+
+	c.mutex.Lock()
+	c.epoch++
+	epoch := c.epoch
+	c.mutex.Unlock()
+
+	used, err := t.overflowReferences(ctx)
+	if err != nil {
+		return err
+	}
+
+	prefix := []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_OVERFLOW_INDEX_ID, byte(t.storageID())}
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: prefixUpperBound(prefix),
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	batch := t.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		key := iter.Key()
+		if len(key) != len(prefix)+sha256.Size+4 {
+			continue
+		}
+
+		var hash [sha256.Size]byte
+		copy(hash[:], key[len(prefix):])
+		if _, ok := used[hash]; ok {
+			continue
+		} else if written, ok := c.written[hash]; ok && written+1 >= epoch {
+			continue
+		}
+
+		err = batch.Delete(key, Sync)
+		if err != nil {
+			return err
+		}
+		delete(c.written, hash)
+		delete(c.stored, hash)
+	}
+
+	for hash, written := range c.written {
+		if written+1 < epoch {
+			delete(c.written, hash)
+		}
+	}
+	for hash, stored := range c.stored {
+		if stored+1 < epoch {
+			delete(c.stored, hash)
+		}
+	}
+
+	// the chunks are removed before the mutex is released, so the values
+	// written meanwhile are not removed with them
+	return batch.Commit(Sync)
+}
+
+// overflowReferences returns the hashes of the overflow values the rows of the
+// table refer to.
+func (t *_table[T]) overflowReferences(ctx context.Context) (map[[sha256.Size]byte]struct{}, error) {
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{byte(t.storageID()), byte(PrimaryIndexID)},
+			UpperBound: []byte{byte(t.storageID()), byte(PrimaryIndexID + 1)},
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	used := make(map[[sha256.Size]byte]struct{})
+	for iter.First(); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		value := iter.Value()
+		if t.deltaCodec != nil {
+			base, _, err := splitDeltas(value)
+			if err != nil {
+				return nil, t.newError(nil, iter.Key(), err)
+			}
+			value = base
+		}
+
+		reference, ok, err := splitOverflowReference(value)
+		if err != nil {
+			return nil, t.newError(nil, iter.Key(), err)
+		} else if ok && len(reference) == sha256.Size {
+			used[*(*[sha256.Size]byte)(reference)] = struct{}{}
+		}
+	}
+	return used, nil
+}
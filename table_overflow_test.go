@@ -0,0 +1,237 @@
+package bond
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_Overflow(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		Checksum:          true,
+		OverflowThreshold: 1024,
+	})
+
+	overflowChunks := func() int {
+		prefix := []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_OVERFLOW_INDEX_ID, 1}
+		iter := db.Iter(&IterOptions{
+			IterOptions: pebble.IterOptions{
+				LowerBound: prefix,
+				UpperBound: prefixUpperBound(prefix),
+			},
+		})
+		defer func() { _ = iter.Close() }()
+
+		var count int
+		for iter.First(); iter.Valid(); iter.Next() {
+			count++
+		}
+		return count
+	}
+
+	huge := strings.Repeat("0xtestAccount", OverflowChunkSize/4)
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountAddress: huge, Balance: 7},
+		{ID: 3, AccountAddress: huge, Balance: 9},
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	assert.Equal(t, 8, overflowChunks())
+
+	err = tokenBalanceTable.RawScan(context.Background(), func(_, value []byte) bool {
+		assert.Less(t, len(value), 1024)
+		return true
+	})
+	require.NoError(t, err)
+
+	var trs []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &trs)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances, trs)
+
+	tr, err := tokenBalanceTable.Get(&TokenBalance{ID: 2})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[1], tr)
+
+	// the chunks no longer used are removed by the second collection
+	err = tokenBalanceTable.Delete(context.Background(), []*TokenBalance{tokenBalances[1]})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.CollectOverflow(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 8, overflowChunks())
+
+	err = tokenBalanceTable.CollectOverflow(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 4, overflowChunks())
+
+	// the chunks written before the previous collection are removed at once
+	tokenBalances[2].AccountAddress = "0xtestAccount"
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{tokenBalances[2]})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.CollectOverflow(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, overflowChunks())
+
+	// the collected value is written again
+	tokenBalances[2].AccountAddress = huge
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{tokenBalances[2]})
+	require.NoError(t, err)
+	assert.Equal(t, 4, overflowChunks())
+
+	tr, err = tokenBalanceTable.Get(&TokenBalance{ID: 3})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[2], tr)
+
+	// the rows of the retained snapshot read the chunks collected since
+	snapshotTime := db.RetainSnapshot()
+	snapshotRow := *tokenBalances[2]
+
+	tokenBalances[2].AccountAddress = "0xtestAccount"
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{tokenBalances[2]})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		err = tokenBalanceTable.CollectOverflow(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 0, overflowChunks())
+
+	err = tokenBalanceTable.Query().
+		Filter(func(tb *TokenBalance) bool {
+			return tb.ID == 3
+		}).
+		AsOf(snapshotTime).
+		Execute(context.Background(), &trs)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{&snapshotRow}, trs)
+}
+
+func TestBond_Table_Overflow_NotCommitted(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		OverflowThreshold: 1024,
+	})
+
+	overflowChunks := func() int {
+		prefix := []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_OVERFLOW_INDEX_ID, 1}
+		iter := db.Iter(&IterOptions{
+			IterOptions: pebble.IterOptions{
+				LowerBound: prefix,
+				UpperBound: prefixUpperBound(prefix),
+			},
+		})
+		defer func() { _ = iter.Close() }()
+
+		var count int
+		for iter.First(); iter.Valid(); iter.Next() {
+			count++
+		}
+		return count
+	}
+
+	huge := strings.Repeat("0xtestAccount", OverflowChunkSize/4)
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: huge, Balance: 5},
+	}
+
+	// the dry run does not store the chunks
+	dryRunCtx := ContextWithWriteOptions(context.Background(), WriteOptions{DryRun: true, Report: &WriteReport{}})
+	err := tokenBalanceTable.Insert(dryRunCtx, tokenBalances)
+	require.NoError(t, err)
+	assert.Equal(t, 0, overflowChunks())
+
+	// the chunks are written with the row to the batch
+	batch := db.Batch()
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances, batch)
+	require.NoError(t, err)
+	assert.Equal(t, 0, overflowChunks())
+	require.NoError(t, batch.Close())
+	assert.Equal(t, 0, overflowChunks())
+
+	// the chunks not committed before are written again
+	batch = db.Batch()
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances, batch)
+	require.NoError(t, err)
+	require.NoError(t, batch.Commit(Sync))
+	require.NoError(t, batch.Close())
+	assert.Equal(t, 4, overflowChunks())
+
+	tr, err := tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[0], tr)
+}
+
+type _testOverflowStore struct {
+	values map[string][]byte
+}
+
+func (s *_testOverflowStore) Put(table string, value []byte) ([]byte, error) {
+	reference := []byte(table + "/" + string(rune('a'+len(s.values))))
+	s.values[string(reference)] = value
+	return reference, nil
+}
+
+func (s *_testOverflowStore) Get(_ string, reference []byte) ([]byte, error) {
+	return s.values[string(reference)], nil
+}
+
+func TestBond_Table_Overflow_Store(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	store := &_testOverflowStore{values: make(map[string][]byte)}
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		OverflowThreshold: 1024,
+		OverflowStore:     store,
+	})
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountAddress: strings.Repeat("0xtestAccount", 100), Balance: 7},
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+	assert.Len(t, store.values, 1)
+
+	var trs []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &trs)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances, trs)
+
+	// the values of the store are not collected by the table
+	err = tokenBalanceTable.CollectOverflow(context.Background())
+	require.Error(t, err)
+}
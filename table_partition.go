@@ -0,0 +1,313 @@
+package bond
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// PartitionID is the ID of the table partition.
+type PartitionID uint64
+
+// TablePartitioner manages the partitions of the table.
+type TablePartitioner[T any] interface {
+	Partitions(ctx context.Context, optBatch ...Batch) ([]PartitionID, error)
+	DropPartition(ctx context.Context, partition PartitionID, optBatch ...Batch) error
+	ScanPartitions(ctx context.Context, partitions []PartitionID, f func(partition PartitionID, tr T) (bool, error)) error
+}
+
+// PartitionByTime returns the partition function that puts the rows of every
+// interval to its own partition.
+func PartitionByTime[T any](interval time.Duration, timeFunc func(tr T) time.Time) func(tr T) PartitionID {
+	return func(tr T) PartitionID {
+		return PartitionID(timeFunc(tr).UnixNano() / int64(interval))
+	}
+}
+
+// PartitionByHash returns the partition function that spreads the rows over
+// the partitions by the hash of the key.
+func PartitionByHash[T any](partitions uint64, keyFunc func(tr T) []byte) func(tr T) PartitionID {
+	return func(tr T) PartitionID {
+		hash := fnv.New64a()
+		_, _ = hash.Write(keyFunc(tr))
+		return PartitionID(hash.Sum64() % partitions)
+	}
+}
+
+// partitionedPrimaryKeyFunc returns the primary key function that starts the
+// primary keys with the partition, so the rows of the partition are stored
+// under their own prefix.
+func partitionedPrimaryKeyFunc[T any](partitionFunc func(tr T) PartitionID, primaryKeyFunc TablePrimaryKeyFunc[T]) TablePrimaryKeyFunc[T] {
+	return func(builder KeyBuilder, tr T) []byte {
+		return primaryKeyFunc(builder.AddUint64Field(uint64(partitionFunc(tr))), tr)
+	}
+}
+
+// partitionPrefix returns the prefix of the primary keys of the partition rows.
+func (t *_table[T]) partitionPrefix(partition PartitionID) []byte {
+	return KeyEncode(Key{
+		TableID:    t.storageID(),
+		IndexID:    PrimaryIndexID,
+		IndexKey:   []byte{},
+		IndexOrder: []byte{},
+		PrimaryKey: NewKeyBuilder([]byte{}).AddUint64Field(uint64(partition)).Bytes(),
+	})
+}
+
+func (t *_table[T]) checkPartitioned() error {
+	if !t.partitioned {
+		return fmt.Errorf("table %s is not partitioned", t.name)
+	}
+	return nil
+}
+
+// Partitions returns the partitions with rows in the ascending order. The
+// partitions are found with one seek per partition.
+func (t *_table[T]) Partitions(ctx context.Context, optBatch ...Batch) ([]PartitionID, error) {
+	if err := t.checkPartitioned(); err != nil {
+		return nil, err
+	}
+
+	prefix := t.partitionPrefix(0)[:10]
+	opt := &IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: prefixUpperBound(prefix),
+		},
+	}
+
+	var iter Iterator
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		iter = optBatch[0].Iter(opt)
+	} else {
+		iter = t.db.Iter(opt)
+	}
+
+	var partitions []PartitionID
+	for iter.First(); iter.Valid(); {
+		select {
+		case <-ctx.Done():
+			_ = iter.Close()
+			return nil, fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		primaryKey := KeyBytes(iter.Key()).PrimaryKey()
+		if len(primaryKey) < 9 {
+			_ = iter.Close()
+			return nil, t.newError(nil, iter.Key(), fmt.Errorf("row key has no partition"))
+		}
+
+		partition := PartitionID(binary.BigEndian.Uint64(primaryKey[1:9]))
+		partitions = append(partitions, partition)
+
+		next := prefixUpperBound(t.partitionPrefix(partition))
+		if next == nil || !iter.SeekGE(next) {
+			break
+		}
+	}
+	return partitions, iter.Close()
+}
+
+// DropPartition deletes the rows of the partition. The rows are deleted with
+// the range delete, which takes the same time regardless of the number of the
+// rows. The rows of the tables with the secondary indexes, the caches or the
+// index features that track the rows are read to delete their entries too.
+//
+// Example:
+//
+//	// drop the events older than 30 days
+//	partitions, err := eventTable.Partitions(ctx)
+//	...
+//	for _, partition := range partitions {
+//		if partition < bond.PartitionID(time.Now().Add(-30*24*time.Hour).UnixNano()/int64(24*time.Hour)) {
+//			err = eventTable.DropPartition(ctx, partition)
+//			...
+//		}
+//	}
+func (t *_table[T]) DropPartition(ctx context.Context, partition PartitionID, optBatch ...Batch) error {
+	if err := t.checkPartitioned(); err != nil {
+		return err
+	}
+
+	indexes, writeHooks, endWrite := t.beginWrite()
+	defer endWrite()
+
+	var (
+		batch         Batch
+		externalBatch = len(optBatch) > 0 && optBatch[0] != nil
+	)
+	if externalBatch {
+		batch = optBatch[0]
+	} else {
+		batch = t.db.Batch()
+		defer func() {
+			_ = batch.Close()
+		}()
+	}
+
+	prefix := t.partitionPrefix(partition)
+
+	var invalidation _cacheInvalidation
+	if len(indexes) > 0 || len(writeHooks) > 0 || t.cache != nil || t.queryCache != nil {
+		var (
+			changes        []_rowChange[T]
+			indexKeyBuffer = make([]byte, DataKeyBufferSize*len(indexes))
+			indexKeys      = make([][]byte, len(indexes))
+		)
+
+		iter := t.db.Iter(&IterOptions{
+			IterOptions: pebble.IterOptions{
+				LowerBound: prefix,
+				UpperBound: prefixUpperBound(prefix),
+			},
+		}, batch)
+
+		for iter.First(); iter.Valid(); iter.Next() {
+			select {
+			case <-ctx.Done():
+				_ = iter.Close()
+				return fmt.Errorf("context done: %w", ctx.Err())
+			default:
+			}
+
+			var tr T
+			err := t.deserialize(iter.Value(), &tr, batch)
+			if err != nil {
+				_ = iter.Close()
+				return t.newError(nil, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
+			}
+
+			t.collectInvalidation(&invalidation, iter.Key(), indexes, tr)
+			if len(writeHooks) > 0 {
+				changes = append(changes, _rowChange[T]{old: tr, hasOld: true})
+			}
+
+			indexKeys = t.indexKeys(tr, indexes, indexKeyBuffer[:0], indexKeys[:0])
+			for _, indexKey := range indexKeys {
+				err = batch.Delete(indexKey, Sync)
+				if err != nil {
+					_ = iter.Close()
+					return err
+				}
+			}
+		}
+
+		err := iter.Close()
+		if err != nil {
+			return err
+		}
+
+		err = t.runWriteHooks(ctx, writeHooks, batch, changes)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := t.db.DeleteRange(prefix, prefixUpperBound(prefix), Sync, batch)
+	if err != nil {
+		return err
+	}
+
+	if !externalBatch {
+		err = batch.Commit(ContextRetrieveWriteOptions(ctx))
+		if err != nil {
+			return err
+		}
+	}
+
+	t.invalidateCache(invalidation, batch, externalBatch)
+	return nil
+}
+
+// ScanPartitions scans the partitions in parallel. The callback is called
+// concurrently for the rows of the different partitions and in the primary
+// key order within the partition. It stops the scan of the partition if it
+// returns false and the scan of all the partitions if it returns the error.
+func (t *_table[T]) ScanPartitions(ctx context.Context, partitions []PartitionID, f func(partition PartitionID, tr T) (bool, error)) error {
+	if err := t.checkPartitioned(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errMutex sync.Mutex
+		firstErr error
+		workers  = make(chan struct{}, runtime.GOMAXPROCS(0))
+	)
+
+	for _, partition := range partitions {
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(partition PartitionID) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+
+			err := t.scanPartition(ctx, partition, f)
+			if err != nil {
+				errMutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMutex.Unlock()
+				cancel()
+			}
+		}(partition)
+	}
+	wg.Wait()
+
+	return firstErr
+}
+
+func (t *_table[T]) scanPartition(ctx context.Context, partition PartitionID, f func(partition PartitionID, tr T) (bool, error)) error {
+	prefix := t.partitionPrefix(partition)
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: prefix,
+			UpperBound: prefixUpperBound(prefix),
+		},
+	})
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			_ = iter.Close()
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		var tr T
+		err := t.serializer.Deserialize(iter.Value(), &tr)
+		if err != nil {
+			_ = iter.Close()
+			return t.newError(nil, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
+		}
+
+		tr, err = t.decodeFields(ctx, tr)
+		if err != nil {
+			_ = iter.Close()
+			return err
+		}
+
+		cont, err := f(partition, tr)
+		if err != nil {
+			_ = iter.Close()
+			return err
+		}
+		if !cont {
+			break
+		}
+	}
+	return iter.Close()
+}
@@ -0,0 +1,101 @@
+package bond
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_Partitions(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	day := 24 * time.Hour
+	start := time.Date(2022, 11, 1, 0, 0, 0, 0, time.UTC)
+
+	// the balance is the unix time of the row
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		PartitionFunc: PartitionByTime(day, func(tb *TokenBalance) time.Time {
+			return time.Unix(int64(tb.Balance), 0)
+		}),
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < 30; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(30 - i),
+			ContractAddress: "0xtestContract",
+			AccountAddress:  "0xtestAccount",
+			Balance:         uint64(start.Add(time.Duration(i%3) * day).Unix()),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	first := PartitionID(start.UnixNano() / int64(day))
+
+	partitions, err := tokenBalanceTable.Partitions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []PartitionID{first, first + 1, first + 2}, partitions)
+
+	var (
+		mutex  sync.Mutex
+		counts = map[PartitionID]int{}
+	)
+	err = tokenBalanceTable.ScanPartitions(context.Background(), partitions, func(partition PartitionID, tb *TokenBalance) (bool, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		assert.Equal(t, partition, PartitionID(int64(tb.Balance)*int64(time.Second)/int64(day)))
+		counts[partition]++
+		return true, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[PartitionID]int{first: 10, first + 1: 10, first + 2: 10}, counts)
+
+	// the point reads find the rows in their partitions
+	tr, err := tokenBalanceTable.Get(tokenBalances[4])
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[4], tr)
+
+	err = tokenBalanceTable.DropPartition(context.Background(), first)
+	require.NoError(t, err)
+
+	partitions, err = tokenBalanceTable.Partitions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []PartitionID{first + 1, first + 2}, partitions)
+
+	// the index entries of the dropped rows are deleted too
+	var byAccount []*TokenBalance
+	err = tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Execute(context.Background(), &byAccount)
+	require.NoError(t, err)
+	assert.Len(t, byAccount, 20)
+
+	_, err = tokenBalanceTable.Get(tokenBalances[0])
+	assert.ErrorIs(t, err, ErrNotFound)
+}
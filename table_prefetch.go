@@ -0,0 +1,170 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type _prefetchEntry struct {
+	dataKey []byte
+	value   []byte
+	err     error
+}
+
+type _prefetchRow[T any] struct {
+	indexKey   []byte
+	indexValue []byte
+	dataKey    []byte
+	fetched    bool
+	record     T
+	err        error
+}
+
+// scanIndexForEachPrefetch iterates over the secondary index in groups of the
+// scan prefetch size. The rows are not fetched until the first Lazy.Get of the
+// group. Each fetch reads the rows from the requested one onward, starting with
+// a single row and doubling with every fetch up to the end of the group, so that
+// the scans that stop early or skip the index hits do not read unused rows.
+func (t *_table[T]) scanIndexForEachPrefetch(ctx context.Context, iter Iterator, selector []byte, f func(keyBytes KeyBytes, t Lazy[T]) (bool, error), batch Batch) error {
+	group := make([]*_prefetchRow[T], 0, t.scanPrefetchSize)
+	window := 1
+
+	fetch := func(pos int) {
+		end := pos + window
+		if end > len(group) {
+			end = len(group)
+		}
+
+		t.fetchRows(group[pos:end], batch)
+
+		if window *= 2; window > t.scanPrefetchSize {
+			window = t.scanPrefetchSize
+		}
+	}
+
+	flush := func() (bool, error) {
+		for pos, row := range group {
+			select {
+			case <-ctx.Done():
+				return false, fmt.Errorf("context done: %w", ctx.Err())
+			default:
+			}
+
+			pos, row := pos, row
+			cont, err := f(row.indexKey, Lazy[T]{
+				GetFunc: func() (T, error) {
+					if !row.fetched {
+						fetch(pos)
+					}
+					return row.record, row.err
+				},
+				indexValue: row.indexValue,
+			})
+			if err != nil || !cont {
+				return false, err
+			}
+		}
+
+		group = group[:0]
+		return true, nil
+	}
+
+	for iter.SeekPrefixGE(selector); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			_ = iter.Close()
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		indexKey := append([]byte{}, iter.Key()...)
+		group = append(group, &_prefetchRow[T]{
+			indexKey:   indexKey,
+			indexValue: append([]byte{}, iter.Value()...),
+			dataKey:    KeyBytes(indexKey).ToDataKeyBytes(),
+		})
+
+		if len(group) < t.scanPrefetchSize {
+			continue
+		}
+
+		cont, err := flush()
+		if err != nil {
+			_ = iter.Close()
+			return err
+		}
+
+		if !cont {
+			return iter.Close()
+		}
+	}
+
+	if len(group) > 0 {
+		if _, err := flush(); err != nil {
+			_ = iter.Close()
+			return err
+		}
+	}
+
+	return iter.Close()
+}
+
+// fetchRows fetches the rows that were not fetched yet. The rows are read
+// through the row cache if the table has one.
+func (t *_table[T]) fetchRows(rows []*_prefetchRow[T], batch Batch) {
+	t.forEachConcurrently(len(rows), batch, func(i int) {
+		row := rows[i]
+		if row.fetched {
+			return
+		}
+
+		row.record, row.err = t.get(row.dataKey, batch)
+		row.fetched = true
+	})
+}
+
+// prefetch fetches the rows of given entries.
+func (t *_table[T]) prefetch(entries []*_prefetchEntry, batch Batch) {
+	t.forEachConcurrently(len(entries), batch, func(i int) {
+		entry := entries[i]
+
+		data, closer, err := t.db.Get(entry.dataKey, batch)
+		if err != nil {
+			entry.err = t.newError(nil, entry.dataKey, notFound(err))
+			return
+		}
+
+		entry.value = append([]byte{}, data...)
+		_ = closer.Close()
+	})
+}
+
+// forEachConcurrently calls f for every index below n. The calls are done
+// concurrently unless the batch is provided, as the batch does not support
+// concurrent reads.
+func (t *_table[T]) forEachConcurrently(n int, batch Batch, f func(i int)) {
+	concurrency := t.scanPrefetchMaxConcurrency
+	if batch != nil || concurrency <= 1 || n <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	if concurrency > n {
+		concurrency = n
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(concurrency)
+	for worker := 0; worker < concurrency; worker++ {
+		go func(worker int) {
+			defer wg.Done()
+			for i := worker; i < n; i += concurrency {
+				f(i)
+			}
+		}(worker)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,167 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_ScanPrefetch(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		ScanPrefetchSize: 4,
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i),
+			AccountID:       1,
+			ContractAddress: fmt.Sprintf("0xtestContract%d", i),
+			AccountAddress:  "0xtestAccount",
+			Balance:         uint64(i * 10),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	var tokenBalancesFromQuery []*TokenBalance
+	err = tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances, tokenBalancesFromQuery)
+
+	err = tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Offset(3).
+		Limit(5).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[3:8], tokenBalancesFromQuery)
+
+	batch := db.Batch()
+	defer func() { _ = batch.Close() }()
+
+	err = tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Limit(6).
+		Execute(context.Background(), &tokenBalancesFromQuery, batch)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[:6], tokenBalancesFromQuery)
+}
+
+// _countingSerializer counts the deserialized rows.
+type _countingSerializer struct {
+	Serializer[**TokenBalance]
+	deserialized int64
+}
+
+func (s *_countingSerializer) Deserialize(b []byte, tr **TokenBalance) error {
+	atomic.AddInt64(&s.deserialized, 1)
+	return s.Serializer.Deserialize(b, tr)
+}
+
+func TestBond_Table_ScanPrefetch_Lazy(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	serializer := &_countingSerializer{
+		Serializer: &SerializerAnyWrapper[**TokenBalance]{Serializer: db.Serializer()},
+	}
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		Serializer:                 serializer,
+		ScanPrefetchSize:           8,
+		ScanPrefetchMaxConcurrency: 2,
+		CacheSize:                  100,
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err := tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:             uint64(i),
+			AccountAddress: "0xtestAccount",
+			Balance:        uint64(i * 10),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	atomic.StoreInt64(&serializer.deserialized, 0)
+
+	// the query stopped after the first row reads only that row
+	var tokenBalancesFromQuery []*TokenBalance
+	err = tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Limit(1).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[:1], tokenBalancesFromQuery)
+	assert.Equal(t, int64(1), atomic.LoadInt64(&serializer.deserialized))
+
+	// the rows are read through the row cache
+	tokenBalancesFromQuery = nil
+	err = tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances, tokenBalancesFromQuery)
+	assert.Equal(t, int64(10), atomic.LoadInt64(&serializer.deserialized))
+
+	tokenBalancesFromQuery = nil
+	err = tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount"}).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances, tokenBalancesFromQuery)
+	assert.Equal(t, int64(10), atomic.LoadInt64(&serializer.deserialized))
+}
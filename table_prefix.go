@@ -0,0 +1,138 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+	"github.com/go-bond/bond/utils"
+)
+
+// scanIndexPrefixForEach iterates over the index entries whose index keys start
+// with the index key prefix of the selector. The index keys are encoded after
+// their length, so the entries of every index key length are sought separately.
+func (t *_table[T]) scanIndexPrefixForEach(ctx context.Context, idx *Index[T], s T, f func(keyBytes KeyBytes, t Lazy[T]) (bool, error), optBatch ...Batch) error {
+	if idx.IndexID == PrimaryIndexID {
+		return t.newError(idx, nil, fmt.Errorf("prefix can not be used with primary index"))
+	}
+
+	if err := t.checkIndexRegistered(idx); err != nil {
+		return err
+	}
+
+	prefix, err := t.indexKeyPrefix(idx, s)
+	if err != nil {
+		return err
+	}
+
+	var batch Batch
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		batch = optBatch[0]
+	}
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{byte(t.storageID()), byte(idx.IndexID)},
+			UpperBound: []byte{byte(t.storageID()), byte(idx.IndexID + 1)},
+		},
+	}, batch)
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	seekKey := func(length int) []byte {
+		key := make([]byte, 6, 6+len(prefix))
+		key[0], key[1] = byte(t.storageID()), byte(idx.IndexID)
+		binary.BigEndian.PutUint32(key[2:6], uint32(length))
+		return append(key, prefix...)
+	}
+
+	var keyBuffer [DataKeyBufferSize]byte
+	getValue := func() (T, error) {
+		return t.get(KeyBytes(iter.Key()).ToDataKeyBytes(keyBuffer[:0]), batch)
+	}
+	getValueInto := func(record *T) error {
+		tr, err := getValue()
+		if err != nil {
+			return err
+		}
+		*record = tr
+		return nil
+	}
+
+	for valid := iter.SeekGE(seekKey(len(prefix))); valid; {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		indexKey := KeyBytes(iter.Key()).IndexKey()
+		if bytes.HasPrefix(indexKey, prefix) {
+			cont, err := f(iter.Key(), t.decodeLazy(ctx, Lazy[T]{GetFunc: getValue, GetIntoFunc: getValueInto, indexValue: iter.Value()}))
+			if err != nil || !cont {
+				return err
+			}
+
+			valid = iter.Next()
+			continue
+		}
+
+		// the entries of this length either start after the prefix or are
+		// all before it
+		if bytes.Compare(indexKey[:len(prefix)], prefix) < 0 {
+			valid = iter.SeekGE(seekKey(len(indexKey)))
+		} else {
+			valid = iter.SeekGE(seekKey(len(indexKey) + 1))
+		}
+	}
+
+	return nil
+}
+
+// indexKeyPrefix returns the index key of the selector without its trailing
+// fields that are not set. The terminator of the last escaped field is
+// removed, so the field matches the values it is the prefix of, and the
+// terminator of the last path field, so the field matches its subtree.
+func (t *_table[T]) indexKeyPrefix(idx *Index[T], s T) (prefix []byte, err error) {
+	defer t.recoverPanic(&err, idx, nil, "index key")
+
+	schema := keySchema(func(builder KeyBuilder) []byte {
+		return idx.IndexKeyFunction(builder, s)
+	})
+
+	key := idx.IndexKeyFunction(NewKeyBuilder([]byte{}), s)
+	zeroKey := idx.IndexKeyFunction(NewKeyBuilder([]byte{}), utils.MakeNew[T]())
+
+	fields, err := decodeKeyFields(key, schema)
+	if err != nil {
+		return nil, t.newError(idx, nil, fmt.Errorf("failed to decode selector index key: %w", err))
+	}
+
+	zeroFields, err := decodeKeyFields(zeroKey, schema)
+	if err != nil || len(zeroFields) != len(fields) {
+		return key, nil
+	}
+
+	end := len(fields)
+	for end > 0 && bytes.Equal(fields[end-1].Data, zeroFields[end-1].Data) {
+		end--
+	}
+
+	size := 0
+	for _, field := range fields[:end] {
+		size += 1 + len(field.Data)
+	}
+	prefix = key[:size]
+
+	if end > 0 {
+		last := fields[end-1]
+		if last.Type == KeyFieldTypeEscapedString || last.Type == KeyFieldTypeEscapedBytes || last.Type == KeyFieldTypePath {
+			prefix = prefix[:len(prefix)-2]
+		}
+	}
+
+	return prefix, nil
+}
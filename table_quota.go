@@ -0,0 +1,188 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tableSizeRefreshInterval is how often the table size is estimated from
+// the disk usage. The bytes written in between are added to the estimate.
+const tableSizeRefreshInterval = time.Second
+
+// _tableQuota enforces TableOptions.RateLimit and TableOptions.MaxSizeBytes.
+type _tableQuota struct {
+	mutex sync.Mutex
+
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	maxSize     uint64
+	size        uint64
+	sizeRefresh time.Time
+}
+
+func newTableQuota[T any](opt TableOptions[T]) *_tableQuota {
+	if opt.RateLimit <= 0 && opt.MaxSizeBytes == 0 {
+		return nil
+	}
+
+	burst := float64(opt.RateLimitBurst)
+	if burst <= 0 {
+		burst = opt.RateLimit
+	}
+
+	return &_tableQuota{
+		rate:    opt.RateLimit,
+		burst:   burst,
+		tokens:  burst,
+		last:    time.Now(),
+		maxSize: opt.MaxSizeBytes,
+	}
+}
+
+// checkQuota takes the rows from the rate limit and adds the written bytes to
+// the table size. Nothing is taken if the write exceeds any of the limits.
+func (t *_table[T]) checkQuota(rows int, written int) error {
+	q := t.quota
+	if q == nil {
+		return nil
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	now := time.Now()
+	if q.rate > 0 {
+		q.tokens += now.Sub(q.last).Seconds() * q.rate
+		if q.tokens > q.burst {
+			q.tokens = q.burst
+		}
+		q.last = now
+
+		if float64(rows) > q.tokens {
+			return t.newError(nil, nil, fmt.Errorf("%w: %d rows exceed %.2f rows per second",
+				ErrRateLimited, rows, q.rate))
+		}
+	}
+
+	if q.maxSize > 0 {
+		if now.Sub(q.sizeRefresh) >= tableSizeRefreshInterval {
+			if db, ok := t.db.(*_db); ok {
+				size, err := db.estimateDiskUsage([]byte{byte(t.storageID())}, []byte{byte(t.storageID()), 0xFF, 0xFF})
+				if err != nil {
+					return err
+				}
+				q.size, q.sizeRefresh = size, now
+			}
+		}
+
+		if q.size+uint64(written) > q.maxSize {
+			return t.newError(nil, nil, fmt.Errorf("%w: %d bytes exceed %d bytes",
+				ErrTableSizeExceeded, q.size+uint64(written), q.maxSize))
+		}
+		q.size += uint64(written)
+	}
+
+	if q.rate > 0 {
+		q.tokens -= float64(rows)
+	}
+	return nil
+}
+
+// refundQuota gives back the rows and the bytes taken by checkQuota for the
+// write that was not committed.
+func (t *_table[T]) refundQuota(rows int, written int) {
+	q := t.quota
+	if q == nil {
+		return
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.rate > 0 {
+		q.tokens += float64(rows)
+		if q.tokens > q.burst {
+			q.tokens = q.burst
+		}
+	}
+
+	if q.maxSize > 0 {
+		if uint64(written) > q.size {
+			q.size = 0
+		} else {
+			q.size -= uint64(written)
+		}
+	}
+}
+
+// _quotaReservation is the quota taken by the write before it's committed.
+type _quotaReservation struct {
+	refund func()
+	kept   bool
+}
+
+// keep keeps the quota of the write that was committed, or written to the
+// external batch, which gives the quota back if it's closed uncommitted.
+func (r *_quotaReservation) keep() {
+	r.kept = true
+}
+
+// release gives back the quota of the write that failed before keep.
+func (r *_quotaReservation) release() {
+	if !r.kept {
+		r.refund()
+	}
+}
+
+// reserveQuota takes the quota of the write with checkQuota. The quota is given
+// back if the write fails, or if the external batch the write is made to is
+// closed without being committed, e.g. committed with DryRun. The writes
+// committed with DryRun take no quota.
+func (t *_table[T]) reserveQuota(ctx context.Context, batch Batch, externalBatch bool, rows int, written int) (*_quotaReservation, error) {
+	if t.quota == nil || (!externalBatch && ContextRetrieveWriteOptions(ctx).DryRun) {
+		return &_quotaReservation{refund: func() {}}, nil
+	}
+
+	err := t.checkQuota(rows, written)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	refund := func() {
+		once.Do(func() {
+			t.refundQuota(rows, written)
+		})
+	}
+
+	if externalBatch {
+		var committed int32
+		batch.OnCommitted(func(_ Batch) {
+			atomic.StoreInt32(&committed, 1)
+		})
+		batch.OnClose(func(_ Batch) {
+			if atomic.LoadInt32(&committed) == 0 {
+				refund()
+			}
+		})
+	}
+	return &_quotaReservation{refund: refund}, nil
+}
+
+// resetSize makes the next write estimate the table size, e.g. once the
+// contents of the table were replaced.
+func (q *_tableQuota) resetSize() {
+	if q == nil {
+		return
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.size, q.sizeRefresh = 0, time.Time{}
+}
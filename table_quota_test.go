@@ -0,0 +1,146 @@
+package bond
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_RateLimit(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		RateLimit:      20,
+		RateLimitBurst: 5,
+	})
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 5; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{ID: uint64(i)})
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{{ID: 6}})
+	require.ErrorIs(t, err, ErrRateLimited)
+	assert.False(t, tokenBalanceTable.Exist(&TokenBalance{ID: 6}))
+
+	time.Sleep(100 * time.Millisecond)
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{{ID: 6}})
+	require.NoError(t, err)
+
+	// the deletes are not limited
+	err = tokenBalanceTable.Delete(context.Background(), tokenBalances)
+	require.NoError(t, err)
+}
+
+func TestBond_Table_MaxSizeBytes(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		MaxSizeBytes: 1024,
+	})
+
+	var err error
+	inserted := 0
+	for i := 1; i <= 100; i++ {
+		err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+			{ID: uint64(i), AccountAddress: "0xtestAccount", ContractAddress: "0xtestContract"},
+		})
+		if err != nil {
+			break
+		}
+		inserted++
+	}
+	require.ErrorIs(t, err, ErrTableSizeExceeded)
+	assert.Greater(t, inserted, 0)
+	assert.Less(t, inserted, 100)
+}
+
+// _cancelSerializer cancels the write once it serializes the row with the ID.
+type _cancelSerializer struct {
+	Serializer[**TokenBalance]
+	id     uint64
+	cancel context.CancelFunc
+}
+
+func (s *_cancelSerializer) Serialize(tr **TokenBalance) ([]byte, error) {
+	if (*tr).ID == s.id {
+		s.cancel()
+	}
+	return s.Serializer.Serialize(tr)
+}
+
+func TestBond_Table_RateLimit_NotCommitted(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		Serializer: &_cancelSerializer{
+			Serializer: &SerializerAnyWrapper[**TokenBalance]{Serializer: db.Serializer()},
+			id:         5,
+			cancel:     cancel,
+		},
+		RateLimit:      0.001,
+		RateLimitBurst: 5,
+	})
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 5; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{ID: uint64(i)})
+	}
+
+	// the insert canceled after the quota is taken gives it back
+	err := tokenBalanceTable.Insert(ctx, tokenBalances)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.False(t, tokenBalanceTable.Exist(&TokenBalance{ID: 1}))
+
+	// the dry run takes no quota
+	dryRunCtx := ContextWithWriteOptions(context.Background(), WriteOptions{DryRun: true, Report: &WriteReport{}})
+	err = tokenBalanceTable.Insert(dryRunCtx, tokenBalances)
+	require.NoError(t, err)
+
+	// the external batch closed without being committed gives the quota back
+	batch := db.Batch()
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances, batch)
+	require.NoError(t, err)
+	require.NoError(t, batch.Close())
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	// the quota is taken by the committed insert
+	tokenBalances[0].Balance = 10
+	batch = db.Batch()
+	err = tokenBalanceTable.Update(context.Background(), tokenBalances[:1], batch)
+	require.ErrorIs(t, err, ErrRateLimited)
+	require.NoError(t, batch.Close())
+}
@@ -0,0 +1,60 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// RawScan iterates over the raw keys and values of the table rows, without
+// deserializing them. The values are serialized with the table serializer and
+// carry the checksums if they are enabled. The key and the value are only
+// valid until the callback returns, the iteration stops if it returns false.
+func (t *_table[T]) RawScan(ctx context.Context, f func(key, value []byte) bool, optBatch ...Batch) error {
+	return t.RawScanIndex(ctx, t.primaryIndex, f, optBatch...)
+}
+
+// RawScanIndex iterates over the raw keys of the index entries, the values of
+// the secondary index entries are empty. The key and the value are only valid
+// until the callback returns, the iteration stops if it returns false.
+func (t *_table[T]) RawScanIndex(ctx context.Context, idx *Index[T], f func(key, value []byte) bool, optBatch ...Batch) error {
+	if err := t.checkIndexRegistered(idx); err != nil {
+		return err
+	}
+
+	opt := &IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{byte(t.storageID()), byte(idx.IndexID)},
+			UpperBound: []byte{byte(t.storageID()), byte(idx.IndexID + 1)},
+		},
+	}
+	if idx.IndexID == math.MaxUint8 {
+		opt.UpperBound = nil
+		if t.storageID() < math.MaxUint8 {
+			opt.UpperBound = []byte{byte(t.storageID() + 1)}
+		}
+	}
+
+	var iter Iterator
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		iter = optBatch[0].Iter(opt)
+	} else {
+		iter = t.db.Iter(opt)
+	}
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			_ = iter.Close()
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		if !f(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+	return iter.Close()
+}
@@ -0,0 +1,62 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_RawScan(t *testing.T) {
+	db, tokenBalanceTable, accountAddressIndex, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	tokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", ContractAddress: "0xtestContract", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount2", ContractAddress: "0xtestContract", Balance: 7},
+		{ID: 3, AccountAddress: "0xtestAccount3", ContractAddress: "0xtestContract", Balance: 9},
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	var rows []*TokenBalance
+	err = tokenBalanceTable.RawScan(context.Background(), func(key, value []byte) bool {
+		assert.Equal(t, PrimaryIndexID, KeyBytes(key).IndexID())
+
+		var tb *TokenBalance
+		require.NoError(t, tokenBalanceTable.Serializer().Deserialize(value, &tb))
+		rows = append(rows, tb)
+		return true
+	})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances, rows)
+
+	var keys [][]byte
+	err = tokenBalanceTable.RawScanIndex(context.Background(), accountAddressIndex, func(key, value []byte) bool {
+		keys = append(keys, append([]byte{}, key...))
+		assert.Empty(t, value)
+		return len(keys) < 2
+	})
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+
+	for i, key := range keys {
+		assert.Equal(t, accountAddressIndex.IndexID, KeyBytes(key).IndexID())
+		assert.Equal(t, PrimaryKey(NewKeyBuilder([]byte{}).AddUint64Field(tokenBalances[i].ID).Bytes()), KeyBytes(key).PrimaryKey())
+	}
+
+	unregisteredIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   accountAddressIndex.IndexID + 10,
+		IndexName: "unregistered_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.ContractAddress).Bytes()
+		},
+	})
+
+	err = tokenBalanceTable.RawScanIndex(context.Background(), unregisteredIndex, func(key, value []byte) bool {
+		return true
+	})
+	assert.ErrorIs(t, err, ErrIndexNotRegistered)
+}
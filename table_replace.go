@@ -0,0 +1,280 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// TableReplacer replaces the contents of the table.
+type TableReplacer[T any] interface {
+	ReplaceFrom(ctx context.Context, staging Table[T]) error
+}
+
+// ReplaceFrom atomically replaces the rows, the index entries and the bond data
+// of the table, e.g. the column groups and the maintained sums, with the ones
+// of the staging table, which is left empty. The readers see either the old
+// or the new contents of the table, so the table can be rebuilt offline into
+// the staging table and promoted at once, the blue/green way. The staging
+// table needs to have the same indexes, and is expected to be created with
+// the same options apart from its ID and name. The tables with the
+// dictionary fields can not be replaced, as their dictionaries are held in
+// memory. The version sequence and the index statistics of the staging table
+// are taken over with the rows, and the versioned table can only be replaced
+// from the versioned staging table.
+//
+// The contents are not copied, the tables swap the IDs their keys are stored
+// under in the catalog, and the old contents of the table are deleted with
+// the range deletions, so the size of the tables does not matter.
+//
+// Example:
+//
+//	err := stagingTable.Insert(ctx, rebuiltTokenBalances)
+//	...
+//	err = tokenBalanceTable.ReplaceFrom(ctx, stagingTable)
+func (t *_table[T]) ReplaceFrom(ctx context.Context, staging Table[T]) error {
+	s, ok := staging.(*_table[T])
+	if !ok {
+		return fmt.Errorf("staging table %s is not bond table", staging.Name())
+	}
+	if s.id == t.id {
+		return fmt.Errorf("table %s can not be replaced from itself", t.name)
+	}
+	if s.db != t.db {
+		return fmt.Errorf("staging table %s belongs to the other database", s.name)
+	}
+	if t.catalog == nil {
+		return fmt.Errorf("table %s: replace needs the database opened with bond.Open", t.name)
+	}
+	if t.dictionary != nil || s.dictionary != nil {
+		return fmt.Errorf("table %s: dictionary fields can not be used with replace", t.name)
+	}
+	if (t.versions == nil) != (s.versions == nil) {
+		return fmt.Errorf("staging table %s is not versioned as table %s", s.name, t.name)
+	}
+
+	// the tables are locked in the order of their IDs, so the tables replaced
+	// from each other at the same time do not wait for each other
+	first, second := t, s
+	if s.id < t.id {
+		first, second = s, t
+	}
+	first.writeMutex.Lock()
+	defer first.writeMutex.Unlock()
+	second.writeMutex.Lock()
+	defer second.writeMutex.Unlock()
+
+	err := t.checkReplaceIndexes(s)
+	if err != nil {
+		return err
+	}
+
+	batch := t.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	tableStorageID, stagingStorageID := t.storageID(), s.storageID()
+
+	swap, err := t.catalog.swapStorages(batch, t.id, s.id)
+	if err != nil {
+		return err
+	}
+
+	// the old contents of the table are stored under the ID of the staging
+	// table once it's swapped, which is left empty
+	for _, prefix := range tableDataPrefixes(tableStorageID) {
+		err = t.deletePrefix(batch, prefix)
+		if err != nil {
+			return err
+		}
+	}
+
+	replaced, err := t.replaceStateFrom(batch, s)
+	if err != nil {
+		return err
+	}
+
+	if t.filter != nil {
+		err = t.addFilterKeys(ContextWithBatch(ctx, batch), stagingStorageID)
+		if err != nil {
+			return err
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	err = batch.Commit(ContextRetrieveWriteOptions(ctx))
+	if err != nil {
+		return err
+	}
+
+	// the tables read the swapped storages once the swap is committed
+	swap()
+
+	for _, replace := range replaced {
+		replace()
+	}
+
+	t.clearCaches()
+	s.clearCaches()
+	t.quota.resetSize()
+	s.quota.resetSize()
+	return nil
+}
+
+// addFilterKeys adds the keys of the rows stored under the storage ID to the
+// filter of the table.
+func (t *_table[T]) addFilterKeys(ctx context.Context, storageID TableID) error {
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{byte(storageID), byte(PrimaryIndexID)},
+			UpperBound: []byte{byte(storageID), byte(PrimaryIndexID + 1)},
+		},
+	})
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			_ = iter.Close()
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		t.filter.Add(ctx, iter.Key())
+	}
+	return iter.Close()
+}
+
+// replaceStateFrom writes the version sequence and the index statistics of the
+// staging table, which are held in memory, to the batch, so they match the
+// swapped rows. The returned functions take them over once it's committed.
+func (t *_table[T]) replaceStateFrom(batch Batch, s *_table[T]) ([]func(), error) {
+	var replaced []func()
+	if t.versions != nil {
+		replace, err := t.versions.replaceFrom(batch, s.versions)
+		if err != nil {
+			return nil, err
+		}
+		replaced = append(replaced, replace)
+	}
+
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for id, idx := range t.secondaryIndexes {
+		if idx.stats == nil {
+			continue
+		}
+
+		replace, err := idx.replaceStats(batch, s.secondaryIndexes[id])
+		if err != nil {
+			return nil, err
+		}
+		replaced = append(replaced, replace)
+	}
+
+	if t.overflowChunks != nil {
+		replaced = append(replaced, func() {
+			t.overflowChunks.replaceFrom(s.overflowChunks)
+		})
+	}
+	return replaced, nil
+}
+
+// checkReplaceIndexes checks that the staging table has the same secondary
+// indexes as the table.
+func (t *_table[T]) checkReplaceIndexes(s *_table[T]) error {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if len(t.indexBuilds) > 0 || len(s.indexBuilds) > 0 {
+		return fmt.Errorf("table %s: replace can not be used during index build", t.name)
+	}
+
+	if len(t.secondaryIndexes) != len(s.secondaryIndexes) {
+		return fmt.Errorf("staging table %s does not have the indexes of table %s", s.name, t.name)
+	}
+	for id, idx := range t.secondaryIndexes {
+		stagingIdx, ok := s.secondaryIndexes[id]
+		if !ok || stagingIdx.IndexName != idx.IndexName {
+			return fmt.Errorf("staging table %s does not have the index %s of table %s", s.name, idx.IndexName, t.name)
+		}
+	}
+	return nil
+}
+
+// deletePrefix deletes the keys with the prefix. The prefixes that have no
+// successor are deleted key by key.
+func (t *_table[T]) deletePrefix(batch Batch, prefix []byte) error {
+	if end := prefixUpperBound(prefix); end != nil {
+		return batch.DeleteRange(prefix, end, Sync)
+	}
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: prefix,
+		},
+	})
+	for iter.First(); iter.Valid(); iter.Next() {
+		err := batch.Delete(iter.Key(), Sync)
+		if err != nil {
+			_ = iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
+}
+
+// _tableStorage is the ID the keys of the table are stored under. It's the
+// table ID unless the table was replaced with ReplaceFrom, which swaps the
+// storage IDs of the table and the staging table. The tables registered with
+// the same ID on the database share it.
+type _tableStorage struct {
+	id uint32
+}
+
+func newTableStorage(id TableID) *_tableStorage {
+	return &_tableStorage{id: uint32(id)}
+}
+
+func (s *_tableStorage) ID() TableID {
+	return TableID(atomic.LoadUint32(&s.id))
+}
+
+func (s *_tableStorage) set(id TableID) {
+	atomic.StoreUint32(&s.id, uint32(id))
+}
+
+func (t *_table[T]) storageID() TableID {
+	return t.storage.ID()
+}
+
+// storageIDOf returns the ID the keys of the table are stored under in the
+// database.
+func storageIDOf(db DB, id TableID) TableID {
+	if bdb, ok := db.(*_db); ok && bdb.catalog != nil {
+		return bdb.catalog.storageID(id)
+	}
+	return id
+}
+
+// clearCaches removes all the rows and the query results from the caches.
+func (t *_table[T]) clearCaches() {
+	if t.cache != nil {
+		t.cache.Clear()
+	}
+	if t.queryCache != nil {
+		t.queryCache.Clear()
+	}
+}
@@ -0,0 +1,299 @@
+package bond
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_ReplaceFrom(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	newTable := func(id TableID, name string) Table[*TokenBalance] {
+		table := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   id,
+			TableName: name,
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+			CacheSize: 10,
+		})
+
+		accountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 1,
+			IndexName: "account_address_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.AccountAddress).Bytes()
+			},
+			IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+		})
+		require.NoError(t, table.AddIndex([]*Index[*TokenBalance]{accountAddressIndex}))
+		return table
+	}
+
+	tokenBalanceTable := newTable(TableID(1), "token_balance")
+	stagingTable := newTable(TableID(2), "token_balance_staging")
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount2", Balance: 15},
+	})
+	require.NoError(t, err)
+
+	// the row is cached
+	_, err = tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+
+	stagingTokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", Balance: 50},
+		{ID: 3, AccountAddress: "0xtestAccount1", Balance: 7},
+	}
+	err = stagingTable.Insert(context.Background(), stagingTokenBalances)
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.ReplaceFrom(context.Background(), stagingTable)
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	assert.Equal(t, stagingTokenBalances, tokenBalances)
+
+	tb, err := tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(50), tb.Balance)
+
+	err = tokenBalanceTable.Query().
+		With(tokenBalanceTable.SecondaryIndexes()[0], &TokenBalance{AccountAddress: "0xtestAccount1"}).
+		Execute(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	assert.Equal(t, stagingTokenBalances, tokenBalances)
+
+	err = tokenBalanceTable.Query().
+		With(tokenBalanceTable.SecondaryIndexes()[0], &TokenBalance{AccountAddress: "0xtestAccount2"}).
+		Execute(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	assert.Empty(t, tokenBalances)
+
+	// the staging table is left empty
+	err = stagingTable.Scan(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	assert.Empty(t, tokenBalances)
+
+	otherTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(3),
+		TableName: "token_balance_other",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	err = tokenBalanceTable.ReplaceFrom(context.Background(), otherTable)
+	require.Error(t, err)
+
+	// the tables replaced from each other at the same time do not deadlock
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for _, tables := range [][2]Table[*TokenBalance]{
+		{tokenBalanceTable, stagingTable},
+		{stagingTable, tokenBalanceTable},
+	} {
+		go func(table, staging Table[*TokenBalance]) {
+			defer wg.Done()
+			assert.NoError(t, table.ReplaceFrom(context.Background(), staging))
+		}(tables[0], tables[1])
+	}
+	wg.Wait()
+}
+
+func TestBond_Table_ReplaceFrom_VersionsAndStatistics(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	newTable := func(id TableID, name string) (Table[*TokenBalance], *Index[*TokenBalance]) {
+		table := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   id,
+			TableName: name,
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+			Versioned: true,
+		})
+
+		accountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   PrimaryIndexID + 1,
+			IndexName: "account_address_idx",
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.AccountAddress).Bytes()
+			},
+			IndexOrderFunc:  IndexOrderDefault[*TokenBalance],
+			IndexStatistics: true,
+		})
+		require.NoError(t, table.AddIndex([]*Index[*TokenBalance]{accountAddressIndex}))
+		return table, accountAddressIndex
+	}
+
+	tokenBalanceTable, accountAddressIndex := newTable(TableID(1), "token_balance")
+	stagingTable, stagingAccountAddressIndex := newTable(TableID(2), "token_balance_staging")
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", Balance: 5},
+	})
+	require.NoError(t, err)
+
+	stagingTokenBalances := []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount1", Balance: 50},
+		{ID: 2, AccountAddress: "0xtestAccount2", Balance: 7},
+		{ID: 3, AccountAddress: "0xtestAccount2", Balance: 9},
+	}
+	err = stagingTable.Insert(context.Background(), stagingTokenBalances)
+	require.NoError(t, err)
+
+	var versions []uint64
+	for _, tb := range stagingTokenBalances {
+		_, version, err := stagingTable.GetWithVersion(tb)
+		require.NoError(t, err)
+		versions = append(versions, version)
+	}
+
+	err = tokenBalanceTable.ReplaceFrom(context.Background(), stagingTable)
+	require.NoError(t, err)
+
+	// the rows keep their versions and the new versions do not repeat them
+	for i, tb := range stagingTokenBalances {
+		_, version, err := tokenBalanceTable.GetWithVersion(tb)
+		require.NoError(t, err)
+		assert.Equal(t, versions[i], version)
+	}
+
+	newVersion, err := tokenBalanceTable.UpdateIfVersion(context.Background(),
+		&TokenBalance{ID: 1, AccountAddress: "0xtestAccount1", Balance: 51}, versions[0])
+	require.NoError(t, err)
+	for _, version := range versions {
+		assert.Greater(t, newVersion, version)
+	}
+
+	// the statistics are taken over with the index entries
+	stats, err := accountAddressIndex.Statistics()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.Entries)
+
+	stats, err = stagingAccountAddressIndex.Statistics()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), stats.Entries)
+
+	// the table opened again reads the taken over state
+	reopenedTable, reopenedAccountAddressIndex := newTable(TableID(1), "token_balance")
+
+	stats, err = reopenedAccountAddressIndex.Statistics()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.Entries)
+
+	_, version, err := reopenedTable.GetWithVersion(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	reopenedVersion, err := reopenedTable.UpdateIfVersion(context.Background(),
+		&TokenBalance{ID: 1, AccountAddress: "0xtestAccount1", Balance: 52}, version)
+	require.NoError(t, err)
+	assert.Greater(t, reopenedVersion, newVersion)
+
+	// the versioned table is not replaced from the table without versions
+	otherTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(3),
+		TableName: "token_balance_other",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+	require.NoError(t, otherTable.AddIndex([]*Index[*TokenBalance]{NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})}))
+
+	err = tokenBalanceTable.ReplaceFrom(context.Background(), otherTable)
+	require.Error(t, err)
+}
+
+func TestBond_Table_ReplaceFrom_Reopen(t *testing.T) {
+	db := setupDatabase()
+
+	newTable := func(db DB, id TableID, name string) Table[*TokenBalance] {
+		return NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   id,
+			TableName: name,
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+		})
+	}
+
+	tokenBalanceTable := newTable(db, TableID(1), "token_balance")
+	stagingTable := newTable(db, TableID(2), "token_balance_staging")
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{{ID: 1, Balance: 5}})
+	require.NoError(t, err)
+
+	err = stagingTable.Insert(context.Background(), []*TokenBalance{{ID: 2, Balance: 7}})
+	require.NoError(t, err)
+
+	var changed [][]byte
+	db.OnTableChange(TableID(1), func(keys [][]byte) {
+		changed = append(changed, keys...)
+	})
+
+	err = tokenBalanceTable.ReplaceFrom(context.Background(), stagingTable)
+	require.NoError(t, err)
+
+	// the writes to the replaced table are reported under its ID
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{{ID: 3, Balance: 9}})
+	require.NoError(t, err)
+	require.Len(t, changed, 1)
+
+	require.NoError(t, db.Close())
+
+	db, err = Open(dbName, &Options{})
+	require.NoError(t, err)
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable = newTable(db, TableID(1), "token_balance")
+	stagingTable = newTable(db, TableID(2), "token_balance_staging")
+
+	var tokenBalances []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{{ID: 2, Balance: 7}, {ID: 3, Balance: 9}}, tokenBalances)
+
+	var stagingTokenBalances []*TokenBalance
+	err = stagingTable.Scan(context.Background(), &stagingTokenBalances)
+	require.NoError(t, err)
+	assert.Empty(t, stagingTokenBalances)
+
+	// the table is replaced again from the staging table stored under its
+	// original ID
+	err = stagingTable.Insert(context.Background(), []*TokenBalance{{ID: 4, Balance: 11}})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.ReplaceFrom(context.Background(), stagingTable)
+	require.NoError(t, err)
+
+	tokenBalances = nil
+	err = tokenBalanceTable.Scan(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{{ID: 4, Balance: 11}}, tokenBalances)
+
+	err = stagingTable.Scan(context.Background(), &stagingTokenBalances)
+	require.NoError(t, err)
+	assert.Empty(t, stagingTokenBalances)
+}
@@ -0,0 +1,241 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// DefaultRetentionInterval is the time between the runs of the retention
+// janitor.
+const DefaultRetentionInterval = time.Minute
+
+// RetentionBatchSize is the number of the rows the retention deletes with one
+// batch.
+const RetentionBatchSize = 1000
+
+// Retention is the retention policy of the table set with
+// TableOptions.Retention. The oldest rows are trimmed by the janitor that runs
+// with the background tasks of the database every Interval, until the table
+// meets all the limits.
+type Retention[T any] struct {
+	// MaxAge is the age of the oldest row kept, by the time returned by
+	// TimeFunc.
+	MaxAge   time.Duration
+	TimeFunc func(tr T) time.Time
+
+	// MaxRows is the number of the rows kept.
+	MaxRows uint64
+
+	// MaxBytes is the estimated size of the table on the disk. The deleted
+	// rows free the space once they are compacted, so the table may exceed
+	// the limit for a while.
+	MaxBytes uint64
+
+	// Index orders the rows from the oldest, e.g. by the time or the
+	// sequence, the primary index if not set. It has to be added to the
+	// table before the janitor runs.
+	Index *Index[T]
+
+	// Interval is the time between the runs of the janitor, the
+	// DefaultRetentionInterval if not set.
+	Interval time.Duration
+
+	// OnError is called with the error of the janitor run.
+	OnError func(err error)
+}
+
+// TableRetentionEnforcer trims the table by its retention policy.
+type TableRetentionEnforcer interface {
+	EnforceRetention(ctx context.Context) (int, error)
+}
+
+func newRetention[T any](opt TableOptions[T]) (*Retention[T], error) {
+	r := *opt.Retention
+	if r.MaxAge <= 0 && r.MaxRows == 0 && r.MaxBytes == 0 {
+		return nil, fmt.Errorf("table %s: retention needs the MaxAge, the MaxRows or the MaxBytes", opt.TableName)
+	}
+	if r.MaxAge > 0 && r.TimeFunc == nil {
+		return nil, fmt.Errorf("table %s: retention with the MaxAge needs the TimeFunc", opt.TableName)
+	}
+	if r.Interval <= 0 {
+		r.Interval = DefaultRetentionInterval
+	}
+	return &r, nil
+}
+
+// startRetention schedules the janitor with the background tasks of the
+// database.
+func (t *_table[T]) startRetention() {
+	r := t.retention
+	t.db.Background().Schedule("retention "+t.name, r.Interval, func(ctx context.Context) error {
+		_, err := t.EnforceRetention(ctx)
+		if err != nil && r.OnError != nil {
+			r.OnError(err)
+		}
+		return err
+	})
+}
+
+// EnforceRetention deletes the oldest rows that break the retention policy of
+// the table and returns the number of the deleted rows. It's called by the
+// janitor, so it only needs to be called to trim the table at once.
+func (t *_table[T]) EnforceRetention(ctx context.Context) (int, error) {
+	if t.retention == nil {
+		return 0, fmt.Errorf("table %s: retention is not enabled", t.name)
+	}
+
+	idx := t.retention.Index
+	if idx == nil {
+		idx = t.primaryIndex
+	}
+
+	trim, err := t.retentionExcess(idx)
+	if err != nil {
+		return 0, err
+	}
+
+	var threshold time.Time
+	if t.retention.MaxAge > 0 {
+		threshold = t.clock.Now().Add(-t.retention.MaxAge)
+	}
+
+	deleted := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return deleted, fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		var remaining uint64
+		if trim > uint64(deleted) {
+			remaining = trim - uint64(deleted)
+		}
+
+		trs, err := t.retentionBatch(idx, remaining, threshold)
+		if err != nil {
+			return deleted, err
+		}
+		if len(trs) == 0 {
+			return deleted, nil
+		}
+
+		err = t.Delete(ctx, trs)
+		if err != nil {
+			return deleted, err
+		}
+
+		deleted += len(trs)
+		if len(trs) < RetentionBatchSize {
+			return deleted, nil
+		}
+	}
+}
+
+// retentionExcess returns the number of the rows over the MaxRows and the
+// MaxBytes limits.
+func (t *_table[T]) retentionExcess(idx *Index[T]) (uint64, error) {
+	r := t.retention
+	if r.MaxRows == 0 && r.MaxBytes == 0 {
+		return 0, nil
+	}
+
+	rows, err := t.retentionRows(idx)
+	if err != nil {
+		return 0, err
+	}
+
+	var excess uint64
+	if r.MaxRows > 0 && rows > r.MaxRows {
+		excess = rows - r.MaxRows
+	}
+
+	if db, ok := t.db.(*_db); ok && r.MaxBytes > 0 && rows > 0 {
+		size, err := db.estimateDiskUsage([]byte{byte(t.storageID())}, []byte{byte(t.storageID()), 0xFF, 0xFF})
+		if err != nil {
+			return 0, err
+		}
+
+		// the rows are assumed to be of the same size
+		if size > r.MaxBytes {
+			bytesExcess := uint64(float64(rows) * float64(size-r.MaxBytes) / float64(size))
+			if bytesExcess > excess {
+				excess = bytesExcess
+			}
+		}
+	}
+	return excess, nil
+}
+
+// retentionRows counts the entries of the retention index.
+func (t *_table[T]) retentionRows(idx *Index[T]) (uint64, error) {
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{byte(t.storageID()), byte(idx.IndexID)},
+			UpperBound: []byte{byte(t.storageID()), byte(idx.IndexID + 1)},
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	var rows uint64
+	for iter.First(); iter.Valid(); iter.Next() {
+		rows++
+	}
+	return rows, iter.Error()
+}
+
+// retentionBatch returns up to RetentionBatchSize oldest rows to delete. The
+// first trim rows are deleted regardless of their age, the rows that follow
+// are deleted while they are older than the threshold.
+func (t *_table[T]) retentionBatch(idx *Index[T], trim uint64, threshold time.Time) ([]T, error) {
+	if err := t.checkIndexRegistered(idx); err != nil {
+		return nil, err
+	}
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{byte(t.storageID()), byte(idx.IndexID)},
+			UpperBound: []byte{byte(t.storageID()), byte(idx.IndexID + 1)},
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	var (
+		trs       []T
+		keyBuffer [DataKeyBufferSize]byte
+	)
+	for iter.First(); iter.Valid() && len(trs) < RetentionBatchSize; iter.Next() {
+		var (
+			tr  T
+			err error
+		)
+		if idx.IndexID == PrimaryIndexID {
+			err = t.serializer.Deserialize(iter.Value(), &tr)
+			if err != nil {
+				return nil, t.newError(idx, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
+			}
+		} else {
+			tr, err = t.get(KeyBytes(iter.Key()).ToDataKeyBytes(keyBuffer[:0]), nil)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if uint64(len(trs)) >= trim && (threshold.IsZero() || !t.retention.TimeFunc(tr).Before(threshold)) {
+			break
+		}
+		trs = append(trs, tr)
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return trs, nil
+}
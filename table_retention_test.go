@@ -0,0 +1,144 @@
+package bond
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_Retention_MaxRows(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	// the balance is the sequence of the row
+	sequenceIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "sequence_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.Balance).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		Retention: &Retention[*TokenBalance]{
+			MaxRows:  5,
+			Index:    sequenceIndex,
+			Interval: time.Hour,
+		},
+	})
+	require.NoError(t, tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{sequenceIndex}))
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{ID: uint64(i), AccountAddress: "0xtestAccount", Balance: uint64(11 - i)})
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	deleted, err := tokenBalanceTable.EnforceRetention(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 5, deleted)
+
+	var tokenBalancesRead []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[:5], tokenBalancesRead)
+
+	deleted, err = tokenBalanceTable.EnforceRetention(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+}
+
+func TestBond_Table_Retention_MaxAge(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	now := time.Now()
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		Retention: &Retention[*TokenBalance]{
+			// the balance is the age of the row in hours
+			MaxAge: 4*time.Hour + 30*time.Minute,
+			TimeFunc: func(tb *TokenBalance) time.Time {
+				return now.Add(-time.Duration(tb.Balance) * time.Hour)
+			},
+			Interval: time.Hour,
+		},
+	})
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{ID: uint64(i), AccountAddress: "0xtestAccount", Balance: uint64(10 - i)})
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	deleted, err := tokenBalanceTable.EnforceRetention(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 5, deleted)
+
+	var tokenBalancesRead []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[5:], tokenBalancesRead)
+}
+
+func TestBond_Table_Retention_Janitor(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		Retention: &Retention[*TokenBalance]{
+			MaxRows:  2,
+			Interval: 10 * time.Millisecond,
+		},
+	})
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 1},
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 2},
+		{ID: 3, AccountAddress: "0xtestAccount", Balance: 3},
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return !tokenBalanceTable.Exist(&TokenBalance{ID: 1})
+	}, time.Second, 10*time.Millisecond)
+	assert.True(t, tokenBalanceTable.Exist(&TokenBalance{ID: 2}))
+	assert.True(t, tokenBalanceTable.Exist(&TokenBalance{ID: 3}))
+
+	_, err = RegisterTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(2),
+		TableName: "token_balance_2",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		Retention: &Retention[*TokenBalance]{},
+	})
+	require.Error(t, err)
+}
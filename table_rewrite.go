@@ -0,0 +1,208 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// DefaultRewriteBatchSize is the number of the rows rewritten with one batch
+// by Table.Rewrite.
+const DefaultRewriteBatchSize = 1000
+
+// TableRewriter rewrites the rows of the table.
+type TableRewriter[T any] interface {
+	Rewrite(ctx context.Context, opt RewriteOptions[T]) error
+}
+
+// RewriteOptions are the options of Rewrite.
+type RewriteOptions[T any] struct {
+	// BatchSize is the number of the rows rewritten with one batch, the
+	// DefaultRewriteBatchSize if not set.
+	BatchSize int
+
+	// Transform changes the row before it's written. It must not change the
+	// primary key of the row. The row is written as read if it's not set.
+	Transform func(tr T) (T, error)
+
+	// Serializer is the serializer the rows were written with, the serializer
+	// of the table if not set.
+	Serializer Serializer[*T]
+}
+
+// Rewrite reads every row of the table, transforms it with the Transform and
+// writes it with the serializer and the indexes the table has now. The index
+// entries of the rows are written again, so the indexes that missed them are
+// repaired. It's the supported way of switching the serializer of the table,
+// e.g. from JSON to msgpack, with the previous serializer set in the options.
+//
+// Every batch is committed on its own, with the writes of the table on hold.
+// The rows written meanwhile to the part of the table that was already
+// rewritten are written with the table serializer, so Rewrite can run while
+// the table is in use, as long as the rows it's yet to rewrite are readable
+// with the serializer of the options.
+//
+// Example:
+//
+//	tokenBalanceTable := bond.NewTable[*TokenBalance](bond.TableOptions[*TokenBalance]{
+//		...
+//		Serializer: &bond.SerializerAnyWrapper[**TokenBalance]{Serializer: &serializers.MsgpackSerializer{}},
+//	})
+//
+//	err := tokenBalanceTable.Rewrite(ctx, bond.RewriteOptions[*TokenBalance]{
+//		Serializer: &bond.SerializerAnyWrapper[**TokenBalance]{Serializer: &serializers.JsonSerializer{}},
+//	})
+func (t *_table[T]) Rewrite(ctx context.Context, opt RewriteOptions[T]) error {
+	if opt.BatchSize <= 0 {
+		opt.BatchSize = DefaultRewriteBatchSize
+	}
+	if opt.Serializer == nil {
+		opt.Serializer = t.serializer
+	}
+
+	var cursor []byte
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		next, err := t.rewriteBatch(ctx, cursor, opt)
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// rewriteBatch rewrites up to BatchSize rows after the cursor. It returns the
+// key of the last rewritten row, or nil if all the rows are rewritten.
+func (t *_table[T]) rewriteBatch(ctx context.Context, cursor []byte, opt RewriteOptions[T]) ([]byte, error) {
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	indexes, writeHooks := t.writeIndexes()
+
+	lowerBound := []byte{byte(t.storageID()), byte(PrimaryIndexID)}
+	if cursor != nil {
+		lowerBound = append(cursor[:len(cursor):len(cursor)], 0x00)
+	}
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: lowerBound,
+			UpperBound: []byte{byte(t.storageID()), byte(PrimaryIndexID + 1)},
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	batch := t.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	var (
+		keyBuffer      [DataKeyBufferSize]byte
+		indexKeyBuffer = make([]byte, DataKeyBufferSize*len(indexes)*2)
+		indexKeys      = make([][]byte, len(indexes))
+	)
+
+	var (
+		invalidation _cacheInvalidation
+		changes      []_rowChange[T]
+		rows         int
+		last         []byte
+	)
+
+	for iter.First(); iter.Valid() && rows < opt.BatchSize; iter.Next() {
+		var oldTr, tr T
+		err := opt.Serializer.Deserialize(iter.Value(), &oldTr)
+		if err != nil {
+			return nil, t.newError(nil, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
+		}
+
+		// the transform gets its own copy, so the pointer rows are not
+		// changed in place
+		err = opt.Serializer.Deserialize(iter.Value(), &tr)
+		if err != nil {
+			return nil, t.newError(nil, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
+		}
+
+		if opt.Transform != nil {
+			tr, err = opt.Transform(tr)
+			if err != nil {
+				return nil, t.newError(nil, iter.Key(), err)
+			}
+		}
+
+		key := t.key(tr, keyBuffer[:0])
+		if !bytes.Equal(key, iter.Key()) {
+			return nil, t.newError(nil, iter.Key(), fmt.Errorf("rewrite can not change the primary key"))
+		}
+
+		data, err := t.serialize(&tr, batch)
+		if err != nil {
+			return nil, err
+		}
+
+		err = batch.Set(key, data, Sync)
+		if err != nil {
+			return nil, err
+		}
+
+		// all the index entries are written, the ones that are up-to-date
+		// are overwritten
+		indexKeys = t.indexKeys(tr, indexes, indexKeyBuffer[:0], indexKeys[:0])
+		for _, indexKey := range indexKeys {
+			err = batch.Set(indexKey, t.indexEntryValue(indexKey, indexes, tr), Sync)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		_, toRemoveIndexKeys := t.indexKeysDiff(tr, oldTr, indexes, indexKeyBuffer[:0])
+		for _, indexKey := range toRemoveIndexKeys {
+			err = batch.Delete(indexKey, Sync)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		t.collectInvalidation(&invalidation, key, indexes, tr, oldTr)
+		if len(writeHooks) > 0 {
+			changes = append(changes, _rowChange[T]{old: oldTr, new: tr, hasOld: true, hasNew: true})
+		}
+
+		last = append(last[:0], key...)
+		rows++
+	}
+
+	if rows == 0 {
+		return nil, nil
+	}
+
+	err := t.runWriteHooks(ctx, writeHooks, batch, changes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = batch.Commit(ContextRetrieveWriteOptions(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	t.invalidateCache(invalidation, batch, false)
+
+	if rows < opt.BatchSize {
+		return nil, nil
+	}
+	return last, nil
+}
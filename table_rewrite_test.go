@@ -0,0 +1,99 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-bond/bond/serializers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_Rewrite(t *testing.T) {
+	db := setupDatabase()
+	defer func() {
+		tearDownDatabase(db)
+	}()
+
+	tableOptions := TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	}
+
+	tokenBalanceTable := NewTable[*TokenBalance](tableOptions)
+
+	var tokenBalances []*TokenBalance
+	for i := 0; i < 25; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i + 1),
+			AccountID:       uint32(i % 5),
+			ContractAddress: "0xtestContract",
+			AccountAddress:  "0xtestAccount",
+			Balance:         uint64(i),
+		})
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Close())
+	db = setupDatabase()
+
+	// the table is switched to CBOR and gets the index without reindexing
+	tableOptions.DB = db
+	tableOptions.Serializer = &SerializerAnyWrapper[**TokenBalance]{Serializer: &serializers.CBORSerializer{}}
+	tokenBalanceTable = NewTable[*TokenBalance](tableOptions)
+
+	accountIDIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_id_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint32Field(tb.AccountID).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{accountIDIndex})
+	require.NoError(t, err)
+
+	_, err = tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.Error(t, err)
+
+	err = tokenBalanceTable.Rewrite(context.Background(), RewriteOptions[*TokenBalance]{
+		BatchSize: 10,
+		Transform: func(tb *TokenBalance) (*TokenBalance, error) {
+			tb.Balance *= 2
+			return tb, nil
+		},
+		Serializer: &SerializerAnyWrapper[**TokenBalance]{Serializer: &serializers.JsonSerializer{}},
+	})
+	require.NoError(t, err)
+
+	var tokenBalancesRead []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	require.Len(t, tokenBalancesRead, 25)
+	for i, tb := range tokenBalancesRead {
+		assert.Equal(t, uint64(i*2), tb.Balance)
+	}
+
+	// the missing index entries are repaired
+	err = tokenBalanceTable.Query().
+		With(accountIDIndex, &TokenBalance{AccountID: 3}).
+		Execute(context.Background(), &tokenBalancesRead)
+	require.NoError(t, err)
+	assert.Len(t, tokenBalancesRead, 5)
+
+	// the primary key can not be changed
+	err = tokenBalanceTable.Rewrite(context.Background(), RewriteOptions[*TokenBalance]{
+		Transform: func(tb *TokenBalance) (*TokenBalance, error) {
+			tb.ID += 100
+			return tb, nil
+		},
+	})
+	require.Error(t, err)
+}
@@ -0,0 +1,181 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// ScanShardSampleSize is the number of the primary keys ScanShards keeps to
+// choose the shard boundaries from.
+const ScanShardSampleSize = 4096
+
+// ScanShard is the range of the primary keys of the table, the Start key is
+// included and the End key is not. The shards are JSON encodable, so they can
+// be handed to the workers with the job.
+type ScanShard struct {
+	Start []byte `json:"start"`
+	End   []byte `json:"end"`
+}
+
+// TableShardScanner divides the table into the shards scanned independently.
+type TableShardScanner[T any] interface {
+	ScanShards(ctx context.Context, n int, optBatch ...Batch) ([]ScanShard, error)
+	ScanRange(ctx context.Context, shard ScanShard, cursor []byte, f func(cursor []byte, tr T) (bool, error), optBatch ...Batch) error
+}
+
+// ScanShards divides the table into at most n disjoint shards of about the
+// same number of rows, which together cover the whole table. The keys of the
+// table are read once to choose the boundaries, so the shards should be
+// computed once per job and stored with it. The rows inserted later are
+// scanned by the shard their keys fall into. Fewer shards are returned if the
+// table has fewer rows.
+//
+// Example:
+//
+//	shards, err := t.ScanShards(ctx, len(workers))
+//	for i, shard := range shards {
+//		workers[i].Run(shard)
+//	}
+func (t *_table[T]) ScanShards(ctx context.Context, n int, optBatch ...Batch) ([]ScanShard, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("scan shards: invalid number of shards %d", n)
+	}
+
+	lowerBound := []byte{byte(t.storageID()), byte(PrimaryIndexID)}
+	upperBound := []byte{byte(t.storageID()), byte(PrimaryIndexID + 1)}
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: lowerBound,
+			UpperBound: upperBound,
+		},
+	}, optBatch...)
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	// every stride-th key is sampled, the stride doubles once the sample is
+	// full and every other sampled key is dropped
+	var (
+		sample [][]byte
+		stride = uint64(1)
+		rows   uint64
+	)
+	for iter.First(); iter.Valid(); iter.Next() {
+		if rows%stride == 0 {
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("context done: %w", ctx.Err())
+			default:
+			}
+
+			sample = append(sample, append([]byte{}, iter.Key()...))
+			if len(sample) == ScanShardSampleSize*2 {
+				for i := 0; i < ScanShardSampleSize; i++ {
+					sample[i] = sample[i*2]
+				}
+				sample = sample[:ScanShardSampleSize]
+				stride *= 2
+			}
+		}
+		rows++
+	}
+
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+
+	if n > len(sample) {
+		n = len(sample)
+	}
+	if n <= 1 {
+		return []ScanShard{{Start: lowerBound, End: upperBound}}, nil
+	}
+
+	shards := make([]ScanShard, 0, n)
+	start := lowerBound
+	for i := 1; i < n; i++ {
+		end := sample[i*len(sample)/n]
+		shards = append(shards, ScanShard{Start: start, End: end})
+		start = end
+	}
+	return append(shards, ScanShard{Start: start, End: upperBound}), nil
+}
+
+// ScanRange calls f with the rows of the shard in the primary key order,
+// starting after the cursor, or at the start of the shard if the cursor is
+// nil. The cursor passed to f with every row is the checkpoint of the
+// worker, the scan continues from it when passed back to ScanRange. The scan
+// stops when f returns false or the error.
+//
+// Example:
+//
+//	err := t.ScanRange(ctx, shard, job.Cursor, func(cursor []byte, tr *Transfer) (bool, error) {
+//		if err := process(tr); err != nil {
+//			return false, err
+//		}
+//		job.Cursor = cursor
+//		return true, nil
+//	})
+func (t *_table[T]) ScanRange(ctx context.Context, shard ScanShard, cursor []byte, f func(cursor []byte, tr T) (bool, error), optBatch ...Batch) error {
+	tablePrefix := []byte{byte(t.storageID()), byte(PrimaryIndexID)}
+	if !bytes.HasPrefix(shard.Start, tablePrefix) || bytes.Compare(shard.End, shard.Start) < 0 ||
+		bytes.Compare(shard.End, []byte{byte(t.storageID()), byte(PrimaryIndexID + 1)}) > 0 {
+		return t.newError(nil, nil, fmt.Errorf("scan range: shard is not the range of the table"))
+	}
+
+	lowerBound := shard.Start
+	if cursor != nil {
+		if bytes.Compare(cursor, shard.Start) < 0 || bytes.Compare(cursor, shard.End) >= 0 {
+			return t.newError(nil, cursor, fmt.Errorf("scan range: cursor is out of the shard"))
+		}
+		lowerBound = append(cursor[:len(cursor):len(cursor)], 0x00)
+	}
+
+	var batch Batch
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		batch = optBatch[0]
+	}
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: lowerBound,
+			UpperBound: shard.End,
+		},
+	}, batch)
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		var tr T
+		err := t.deserialize(iter.Value(), &tr, batch)
+		if err != nil {
+			return t.newError(nil, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
+		}
+
+		tr, err = t.decodeFields(ctx, tr)
+		if err != nil {
+			return err
+		}
+
+		cont, err := f(append([]byte{}, iter.Key()...), tr)
+		if err != nil {
+			return err
+		}
+		if !cont {
+			return nil
+		}
+	}
+
+	return iter.Error()
+}
@@ -0,0 +1,105 @@
+package bond
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBondTable_ScanShards(t *testing.T) {
+	db, tokenBalanceTable, _, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 1000; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i),
+			AccountAddress:  "0xtestAccount",
+			ContractAddress: "0xtestContract",
+			Balance:         uint64(i),
+		})
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	shards, err := tokenBalanceTable.ScanShards(context.Background(), 4)
+	require.NoError(t, err)
+	require.Len(t, shards, 4)
+
+	// the shards survive the round trip through the job store
+	data, err := json.Marshal(shards)
+	require.NoError(t, err)
+
+	var storedShards []ScanShard
+	require.NoError(t, json.Unmarshal(data, &storedShards))
+	assert.Equal(t, shards, storedShards)
+
+	var scanned []*TokenBalance
+	for _, shard := range storedShards {
+		var shardRows int
+		err = tokenBalanceTable.ScanRange(context.Background(), shard, nil, func(_ []byte, tb *TokenBalance) (bool, error) {
+			scanned = append(scanned, tb)
+			shardRows++
+			return true, nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 250, shardRows)
+	}
+	assert.Equal(t, tokenBalances, scanned)
+
+	// the worker resumes from the checkpoint
+	var (
+		checkpoint []byte
+		resumed    []*TokenBalance
+	)
+	err = tokenBalanceTable.ScanRange(context.Background(), shards[1], nil, func(cursor []byte, tb *TokenBalance) (bool, error) {
+		checkpoint = cursor
+		return tb.ID < 260, nil
+	})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.ScanRange(context.Background(), shards[1], checkpoint, func(cursor []byte, tb *TokenBalance) (bool, error) {
+		resumed = append(resumed, tb)
+		return true, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[260:500], resumed)
+
+	err = tokenBalanceTable.ScanRange(context.Background(), shards[0], checkpoint, func(_ []byte, _ *TokenBalance) (bool, error) {
+		return true, nil
+	})
+	require.Error(t, err)
+}
+
+func TestBondTable_ScanShards_Small(t *testing.T) {
+	db, tokenBalanceTable, _, _ := setupDatabaseForQuery()
+	defer tearDownDatabase(db)
+
+	shards, err := tokenBalanceTable.ScanShards(context.Background(), 4)
+	require.NoError(t, err)
+	assert.Len(t, shards, 1)
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 1},
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 2},
+	})
+	require.NoError(t, err)
+
+	shards, err = tokenBalanceTable.ScanShards(context.Background(), 4)
+	require.NoError(t, err)
+	assert.Len(t, shards, 2)
+
+	var ids []uint64
+	for _, shard := range shards {
+		err = tokenBalanceTable.ScanRange(context.Background(), shard, nil, func(_ []byte, tb *TokenBalance) (bool, error) {
+			ids = append(ids, tb.ID)
+			return true, nil
+		})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []uint64{1, 2}, ids)
+}
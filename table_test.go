@@ -28,6 +28,93 @@ func TestBond_NewTable(t *testing.T) {
 	assert.Equal(t, TokenBalanceTableID, tokenBalanceTable.ID())
 }
 
+func TestBond_RegisterTable_IDCollision(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID TableID = 0xC0
+	)
+
+	primaryKeyFunc := func(builder KeyBuilder, tb *TokenBalance) []byte {
+		return builder.AddUint64Field(tb.ID).Bytes()
+	}
+
+	tokenBalanceTable, err := RegisterTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:                  db,
+		TableID:             TokenBalanceTableID,
+		TableName:           "token_balance",
+		TablePrimaryKeyFunc: primaryKeyFunc,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, tokenBalanceTable)
+
+	// the same table can be registered again
+	_, err = RegisterTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:                  db,
+		TableID:             TokenBalanceTableID,
+		TableName:           "token_balance",
+		TablePrimaryKeyFunc: primaryKeyFunc,
+	})
+	require.NoError(t, err)
+
+	_, err = RegisterTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:                  db,
+		TableID:             TokenBalanceTableID,
+		TableName:           "token_balance_copy",
+		TablePrimaryKeyFunc: primaryKeyFunc,
+	})
+	require.Error(t, err)
+
+	_, err = RegisterTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:                  db,
+		TableID:             BOND_DB_DATA_TABLE_ID,
+		TableName:           "token_balance_reserved",
+		TablePrimaryKeyFunc: primaryKeyFunc,
+	})
+	require.Error(t, err)
+
+	assert.Panics(t, func() {
+		_ = NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:                  db,
+			TableID:             TokenBalanceTableID,
+			TableName:           "token_balance_copy",
+			TablePrimaryKeyFunc: primaryKeyFunc,
+		})
+	})
+
+	newIndex := func(id IndexID, name string) *Index[*TokenBalance] {
+		return NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+			IndexID:   id,
+			IndexName: name,
+			IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.AccountAddress).Bytes()
+			},
+			IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+		})
+	}
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{newIndex(PrimaryIndexID+1, "account_address_idx")})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{newIndex(PrimaryIndexID+1, "account_address_idx")})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{newIndex(PrimaryIndexID+1, "contract_address_idx")})
+	require.Error(t, err)
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{
+		newIndex(PrimaryIndexID+2, "contract_address_idx"),
+		newIndex(PrimaryIndexID+2, "account_id_idx"),
+	})
+	require.Error(t, err)
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{newIndex(PrimaryIndexID, "account_id_idx")})
+	require.Error(t, err)
+
+	assert.Equal(t, 1, len(tokenBalanceTable.SecondaryIndexes()))
+}
+
 func TestBondTable_Interfaces(t *testing.T) {
 	db := setupDatabase()
 	defer tearDownDatabase(db)
@@ -218,6 +305,67 @@ func TestBondTable_Insert_Context_Canceled(t *testing.T) {
 	require.Error(t, err)
 }
 
+type cancelingSerializer[T any] struct {
+	Serializer[T]
+
+	cancel      context.CancelFunc
+	cancelAfter int
+	calls       int
+}
+
+func (s *cancelingSerializer[T]) Serialize(t T) ([]byte, error) {
+	s.calls++
+	if s.calls == s.cancelAfter {
+		s.cancel()
+	}
+	return s.Serializer.Serialize(t)
+}
+
+func TestBondTable_Insert_Context_Canceled_During_Write(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		Serializer: &cancelingSerializer[**TokenBalance]{
+			Serializer:  &SerializerAnyWrapper[**TokenBalance]{Serializer: db.Serializer()},
+			cancel:      cancel,
+			cancelAfter: 3,
+		},
+	})
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 3; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i),
+			AccountID:       1,
+			ContractAddress: "0xtestContract",
+			AccountAddress:  "0xtestAccount",
+			Balance:         uint64(i * 10),
+		})
+	}
+
+	// canceled while serializing the last row
+	err := tokenBalanceTable.Insert(ctx, tokenBalances)
+	require.ErrorIs(t, err, context.Canceled)
+
+	for _, tokenBalance := range tokenBalances {
+		assert.False(t, tokenBalanceTable.Exist(tokenBalance))
+	}
+}
+
 func TestBondTable_Insert_When_Exist(t *testing.T) {
 	db := setupDatabase()
 	defer tearDownDatabase(db)
@@ -247,7 +395,7 @@ func TestBondTable_Insert_When_Exist(t *testing.T) {
 	require.NoError(t, err)
 
 	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalanceAccount1})
-	require.Error(t, err)
+	require.ErrorIs(t, err, ErrKeyExists)
 
 	it := tokenBalanceTable.Iter(nil)
 
@@ -261,6 +409,52 @@ func TestBondTable_Insert_When_Exist(t *testing.T) {
 	}
 }
 
+func TestBondTable_Insert_Duplicated_Rows(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	tokenBalanceAccount1 := &TokenBalance{
+		ID:              1,
+		AccountID:       1,
+		ContractAddress: "0xtestContract",
+		AccountAddress:  "0xtestAccount",
+		Balance:         5,
+	}
+
+	tokenBalanceAccount2 := &TokenBalance{
+		ID:              2,
+		AccountID:       1,
+		ContractAddress: "0xtestContract",
+		AccountAddress:  "0xtestAccount",
+		Balance:         7,
+	}
+
+	batch := db.Batch()
+	defer func() { _ = batch.Close() }()
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		tokenBalanceAccount1, tokenBalanceAccount2, tokenBalanceAccount1,
+	}, batch)
+	require.ErrorIs(t, err, ErrKeyExists)
+
+	// nothing is written to the batch
+	assert.False(t, tokenBalanceTable.Exist(tokenBalanceAccount1, batch))
+	assert.False(t, tokenBalanceTable.Exist(tokenBalanceAccount2, batch))
+}
+
 func TestBondTable_Update(t *testing.T) {
 	db := setupDatabase()
 	defer tearDownDatabase(db)
@@ -1114,3 +1308,51 @@ func TestBond_Batch(t *testing.T) {
 		assert.Equal(t, tokenBalance, &tokenBalanceAccountFromDB)
 	}
 }
+
+func TestBondTable_GetByKeys(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	tokenBalanceAccount1 := &TokenBalance{
+		ID:              1,
+		AccountID:       1,
+		ContractAddress: "0xtestContract",
+		AccountAddress:  "0xtestAccount",
+		Balance:         5,
+	}
+
+	tokenBalanceAccount2 := &TokenBalance{
+		ID:              2,
+		AccountID:       2,
+		ContractAddress: "0xtestContract",
+		AccountAddress:  "0xtestAccount2",
+		Balance:         7,
+	}
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalanceAccount1, tokenBalanceAccount2})
+	require.NoError(t, err)
+
+	keyOf := func(id uint64) PrimaryKey {
+		return NewKeyBuilder([]byte{}).AddUint64Field(id).Bytes()
+	}
+
+	tokenBalances, err := tokenBalanceTable.GetByKeys(context.Background(), []PrimaryKey{keyOf(2), keyOf(1)})
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalanceAccount2, tokenBalanceAccount1}, tokenBalances)
+
+	_, err = tokenBalanceTable.GetByKeys(context.Background(), []PrimaryKey{keyOf(1), keyOf(3)})
+	require.Error(t, err)
+}
@@ -3,8 +3,6 @@ package bond
 import (
 	"context"
 	"fmt"
-
-	"golang.org/x/exp/maps"
 )
 
 // TableUnsafeUpdater provides access to UnsafeUpdate method that allows
@@ -21,10 +19,12 @@ func (t *_table[T]) UnsafeUpdate(ctx context.Context, trs []T, oldTrs []T, optBa
 		return fmt.Errorf("params need to be of equal size")
 	}
 
-	t.mutex.RLock()
-	indexes := make(map[IndexID]*Index[T])
-	maps.Copy(indexes, t.secondaryIndexes)
-	t.mutex.RUnlock()
+	if err := t.defaultAndValidate(trs); err != nil {
+		return err
+	}
+
+	indexes, writeHooks, endWrite := t.beginWrite()
+	defer endWrite()
 
 	var batch Batch
 	var externalBatch = len(optBatch) > 0 && optBatch[0] != nil
@@ -34,11 +34,21 @@ func (t *_table[T]) UnsafeUpdate(ctx context.Context, trs []T, oldTrs []T, optBa
 		batch = t.db.Batch()
 	}
 
+	defer func() {
+		if !externalBatch {
+			_ = batch.Close()
+		}
+	}()
+
 	var (
 		keyBuffer      [DataKeyBufferSize]byte
 		indexKeyBuffer = make([]byte, DataKeyBufferSize*len(indexes)*2)
 	)
 
+	var invalidation _cacheInvalidation
+	var written int
+	var changes []_rowChange[T]
+
 	for i := 0; i < len(trs); i++ {
 		tr := trs[i]
 		oldTr := oldTrs[i]
@@ -51,9 +61,13 @@ func (t *_table[T]) UnsafeUpdate(ctx context.Context, trs []T, oldTrs []T, optBa
 
 		// update key
 		key := t.key(tr, keyBuffer[:0])
+		t.collectInvalidation(&invalidation, key, indexes, tr, oldTr)
+		if len(writeHooks) > 0 {
+			changes = append(changes, _rowChange[T]{old: oldTr, new: tr, hasOld: true, hasNew: true})
+		}
 
 		// serialize
-		data, err := t.serializer.Serialize(&tr)
+		data, err := t.serialize(&tr, batch)
 		if err != nil {
 			return err
 		}
@@ -61,18 +75,17 @@ func (t *_table[T]) UnsafeUpdate(ctx context.Context, trs []T, oldTrs []T, optBa
 		// update entry
 		err = batch.Set(key, data, Sync)
 		if err != nil {
-			_ = batch.Close()
 			return err
 		}
+		written += len(key) + len(data)
 
 		// indexKeys to add and remove
 		toAddIndexKeys, toRemoveIndexKeys := t.indexKeysDiff(tr, oldTr, indexes, indexKeyBuffer[:0])
 
 		// update indexes
 		for _, indexKey := range toAddIndexKeys {
-			err = batch.Set(indexKey, []byte{}, Sync)
+			err = batch.Set(indexKey, t.indexEntryValue(indexKey, indexes, tr), Sync)
 			if err != nil {
-				_ = batch.Close()
 				return err
 			}
 		}
@@ -80,19 +93,39 @@ func (t *_table[T]) UnsafeUpdate(ctx context.Context, trs []T, oldTrs []T, optBa
 		for _, indexKey := range toRemoveIndexKeys {
 			err = batch.Delete(indexKey, Sync)
 			if err != nil {
-				_ = batch.Close()
 				return err
 			}
 		}
 	}
 
+	err := t.runWriteHooks(ctx, writeHooks, batch, changes)
+	if err != nil {
+		return err
+	}
+
+	reservation, err := t.reserveQuota(ctx, batch, externalBatch, len(trs), written)
+	if err != nil {
+		return err
+	}
+	defer reservation.release()
+
+	// abort before the writes are committed
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
 	if !externalBatch {
-		err := batch.Commit(Sync)
+		err = batch.Commit(ContextRetrieveWriteOptions(ctx))
 		if err != nil {
-			_ = batch.Close()
 			return err
 		}
 	}
 
+	reservation.keep()
+
+	t.invalidateCache(invalidation, batch, externalBatch)
+
 	return nil
 }
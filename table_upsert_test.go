@@ -0,0 +1,63 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_UpsertReturning(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	tokenBalance1 := &TokenBalance{ID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount", Balance: 5}
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance1})
+	require.NoError(t, err)
+
+	tokenBalance1Updated := &TokenBalance{ID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount", Balance: 7}
+	tokenBalance2 := &TokenBalance{ID: 2, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount2", Balance: 15}
+
+	oldTokenBalances, err := tokenBalanceTable.UpsertReturning(context.Background(), []*TokenBalance{tokenBalance1Updated, tokenBalance2})
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalance1, nil}, oldTokenBalances)
+
+	var tokenBalances []*TokenBalance
+	err = tokenBalanceTable.Scan(context.Background(), &tokenBalances)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalance1Updated, tokenBalance2}, tokenBalances)
+
+	// the rows upserted twice within the batch return the version written by
+	// the batch
+	batch := db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	tokenBalance3 := &TokenBalance{ID: 3, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount3", Balance: 1}
+	oldTokenBalances, err = tokenBalanceTable.UpsertReturning(context.Background(), []*TokenBalance{tokenBalance3}, batch)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{nil}, oldTokenBalances)
+
+	tokenBalance3Updated := &TokenBalance{ID: 3, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount3", Balance: 2}
+	oldTokenBalances, err = tokenBalanceTable.UpsertReturning(context.Background(), []*TokenBalance{tokenBalance3Updated}, batch)
+	require.NoError(t, err)
+	assert.Equal(t, []*TokenBalance{tokenBalance3}, oldTokenBalances)
+
+	require.NoError(t, batch.Commit(Sync))
+
+	tr, err := tokenBalanceTable.Get(&TokenBalance{ID: 3})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalance3Updated, tr)
+}
@@ -7,6 +7,8 @@ type _tableAnyScanner struct {
 	scanIndex        func(ctx context.Context, i *Index[any], s any, tr *[]any, optBatch ...Batch) error
 	scanForEach      func(ctx context.Context, f func(keyBytes KeyBytes, l Lazy[any]) (bool, error), optBatch ...Batch) error
 	scanIndexForEach func(ctx context.Context, idx *Index[any], s any, f func(keyBytes KeyBytes, t Lazy[any]) (bool, error), optBatch ...Batch) error
+	rawScan          func(ctx context.Context, f func(key, value []byte) bool, optBatch ...Batch) error
+	rawScanIndex     func(ctx context.Context, idx *Index[any], f func(key, value []byte) bool, optBatch ...Batch) error
 }
 
 func (a *_tableAnyScanner) Scan(ctx context.Context, tr *[]any, optBatch ...Batch) error {
@@ -25,6 +27,14 @@ func (a *_tableAnyScanner) ScanIndexForEach(ctx context.Context, idx *Index[any]
 	return a.scanIndexForEach(ctx, idx, s, f, optBatch...)
 }
 
+func (a *_tableAnyScanner) RawScan(ctx context.Context, f func(key, value []byte) bool, optBatch ...Batch) error {
+	return a.rawScan(ctx, f, optBatch...)
+}
+
+func (a *_tableAnyScanner) RawScanIndex(ctx context.Context, idx *Index[any], f func(key, value []byte) bool, optBatch ...Batch) error {
+	return a.rawScanIndex(ctx, idx, f, optBatch...)
+}
+
 func TableAnyScanner[T any](scanner TableScanner[T]) TableScanner[any] {
 	return &_tableAnyScanner{
 		scan: func(ctx context.Context, tr *[]any, optBatch ...Batch) error {
@@ -112,5 +122,19 @@ func TableAnyScanner[T any](scanner TableScanner[T]) TableScanner[any] {
 				})
 			})
 		},
+		rawScan: func(ctx context.Context, f func(key, value []byte) bool, optBatch ...Batch) error {
+			return scanner.RawScan(ctx, f, optBatch...)
+		},
+		rawScanIndex: func(ctx context.Context, i *Index[any], f func(key, value []byte) bool, optBatch ...Batch) error {
+			iAny := NewIndex[T](IndexOptions[T]{
+				IndexID:   i.IndexID,
+				IndexName: i.IndexName,
+				IndexKeyFunc: func(keyBuilder KeyBuilder, t T) []byte {
+					return i.IndexKeyFunction(keyBuilder, t)
+				},
+			})
+
+			return scanner.RawScanIndex(ctx, iAny, f, optBatch...)
+		},
 	}
 }
@@ -0,0 +1,40 @@
+package bond
+
+import "fmt"
+
+// applyDefaults sets the default values of the rows in place.
+func (t *_table[T]) applyDefaults(trs []T) {
+	if t.defaultsFunc == nil {
+		return
+	}
+
+	for i := range trs {
+		t.defaultsFunc(&trs[i])
+	}
+}
+
+// validateRow returns the error if the row is rejected by the validation.
+func (t *_table[T]) validateRow(tr T) error {
+	if t.validateFunc == nil {
+		return nil
+	}
+
+	if err := t.validateFunc(tr); err != nil {
+		var keyBuffer [DataKeyBufferSize]byte
+		return t.newError(nil, t.key(tr, keyBuffer[:0]), fmt.Errorf("invalid row: %w", err))
+	}
+	return nil
+}
+
+// defaultAndValidate sets the default values of the rows and validates them
+// before they are written.
+func (t *_table[T]) defaultAndValidate(trs []T) error {
+	t.applyDefaults(trs)
+
+	for _, tr := range trs {
+		if err := t.validateRow(tr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
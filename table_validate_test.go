@@ -0,0 +1,90 @@
+package bond
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_DefaultsAndValidation(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	errEmptyAccountAddress := errors.New("empty account address")
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		DefaultsFunc: func(tb **TokenBalance) {
+			if (*tb).ContractAddress == "" {
+				(*tb).ContractAddress = "0xdefaultContract"
+			}
+			(*tb).AccountAddress = strings.ToLower((*tb).AccountAddress)
+		},
+		ValidateFunc: func(tb *TokenBalance) error {
+			if tb.AccountAddress == "" {
+				return errEmptyAccountAddress
+			}
+			if tb.Balance > 1000 {
+				return errors.New("balance out of bounds")
+			}
+			return nil
+		},
+	})
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xTestAccount", Balance: 5},
+	})
+	require.NoError(t, err)
+
+	tr, err := tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, &TokenBalance{ID: 1, ContractAddress: "0xdefaultContract", AccountAddress: "0xtestaccount", Balance: 5}, tr)
+
+	// the invalid rows are not written
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 2, AccountAddress: "0xtestAccount2", Balance: 5},
+		{ID: 3, Balance: 7},
+	})
+	require.ErrorIs(t, err, errEmptyAccountAddress)
+
+	var tableErr *TableError
+	require.True(t, errors.As(err, &tableErr))
+
+	exist := tokenBalanceTable.Exist(&TokenBalance{ID: 2})
+	assert.False(t, exist)
+
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5000},
+	})
+	require.Error(t, err)
+
+	// the merged row of the upsert is validated
+	err = tokenBalanceTable.Upsert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 999},
+	}, func(old, new *TokenBalance) *TokenBalance {
+		return &TokenBalance{ID: old.ID, ContractAddress: old.ContractAddress, AccountAddress: old.AccountAddress, Balance: old.Balance + new.Balance}
+	})
+	require.Error(t, err)
+
+	tr, err = tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), tr.Balance)
+
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 10},
+	})
+	require.NoError(t, err)
+
+	tr, err = tokenBalanceTable.Get(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, &TokenBalance{ID: 1, ContractAddress: "0xdefaultContract", AccountAddress: "0xtestaccount", Balance: 10}, tr)
+}
@@ -0,0 +1,245 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// versionSequenceBlock is the number of the row versions reserved at once.
+const versionSequenceBlock = 1000
+
+// TableVersioner reads and writes the rows with their versions, which change
+// with every write of the row, e.g. to implement the If-Match semantics of
+// the HTTP APIs with the versions as the ETags.
+type TableVersioner[T any] interface {
+	GetWithVersion(tr T, optBatch ...Batch) (T, uint64, error)
+	UpdateIfVersion(ctx context.Context, tr T, version uint64) (uint64, error)
+}
+
+// _versionSequence hands out the row versions of the table. The versions are
+// reserved in blocks, so they never repeat, even for the row that is deleted
+// and inserted again, but the versions of the block that was not used up
+// before the restart are skipped.
+type _versionSequence struct {
+	db  DB
+	key []byte
+
+	next  uint64
+	limit uint64
+
+	mutex sync.Mutex
+}
+
+func newVersionSequence(db DB, tableID TableID) (*_versionSequence, error) {
+	s := &_versionSequence{db: db, key: []byte{BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_ROW_VERSION_INDEX_ID, byte(tableID)}}
+
+	data, closer, err := db.Get(s.key)
+	if errors.Is(err, ErrNotFound) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer func() { _ = closer.Close() }()
+
+	if len(data) != 8 {
+		return nil, fmt.Errorf("invalid version sequence of table 0x%02x", tableID)
+	}
+
+	s.next = binary.BigEndian.Uint64(data)
+	s.limit = s.next
+	return s, nil
+}
+
+// nextVersion returns the next version, the versions start with 1.
+func (s *_versionSequence) nextVersion() (uint64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.next == s.limit {
+		var data [8]byte
+		binary.BigEndian.PutUint64(data[:], s.limit+versionSequenceBlock)
+
+		err := s.db.Set(s.key, data[:], Sync)
+		if err != nil {
+			return 0, fmt.Errorf("failed to reserve row versions: %w", err)
+		}
+		s.limit += versionSequenceBlock
+	}
+
+	s.next++
+	return s.next, nil
+}
+
+func (t *_table[T]) checkVersioned() error {
+	if t.versions == nil {
+		return fmt.Errorf("table %s is not versioned", t.name)
+	}
+	return nil
+}
+
+// versionKey returns the key of the version of the row.
+func (t *_table[T]) versionKey(tr T, buffer []byte) []byte {
+	buffer = append(buffer, BOND_DB_DATA_TABLE_ID, BOND_DB_DATA_ROW_VERSION_INDEX_ID, byte(t.storageID()))
+	return t.primaryKeyFunc(NewKeyBuilder(buffer), tr)
+}
+
+// updateVersions is the write hook that sets the new versions of the written
+// rows and removes the versions of the deleted ones.
+func (t *_table[T]) updateVersions(_ context.Context, batch Batch, changes []_rowChange[T]) error {
+	var keyBuffer [DataKeyBufferSize]byte
+	for _, change := range changes {
+		if !change.hasNew {
+			err := batch.Delete(t.versionKey(change.old, keyBuffer[:0]), Sync)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		version, err := t.versions.nextVersion()
+		if err != nil {
+			return err
+		}
+
+		var data [8]byte
+		binary.BigEndian.PutUint64(data[:], version)
+
+		err = batch.Set(t.versionKey(change.new, keyBuffer[:0]), data[:], Sync)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetWithVersion returns the row with its version. The row and the version
+// are read from the same view of the database.
+func (t *_table[T]) GetWithVersion(tr T, optBatch ...Batch) (T, uint64, error) {
+	var zero T
+	if err := t.checkVersioned(); err != nil {
+		return zero, 0, err
+	}
+
+	var (
+		keyBuffer        [DataKeyBufferSize]byte
+		versionKeyBuffer [DataKeyBufferSize]byte
+	)
+	key := t.key(tr, keyBuffer[:0])
+	versionKey := t.versionKey(tr, versionKeyBuffer[:0])
+
+	var batch Batch
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		batch = optBatch[0]
+	}
+
+	// the iterator reads the consistent view of the database, so the version
+	// belongs to the row that is read
+	iter := t.db.Iter(&IterOptions{}, batch)
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	if !iter.SeekGE(key) || !bytes.Equal(iter.Key(), key) {
+		return zero, 0, t.newError(nil, key, ErrNotFound)
+	}
+
+	var row T
+	err := t.deserialize(iter.Value(), &row, batch)
+	if err != nil {
+		return zero, 0, t.newError(nil, key, fmt.Errorf("failed to deserialize: %w", err))
+	}
+
+	if !iter.SeekGE(versionKey) || !bytes.Equal(iter.Key(), versionKey) || len(iter.Value()) != 8 {
+		// the row written before the table was versioned
+		return row, 0, nil
+	}
+	return row, binary.BigEndian.Uint64(iter.Value()), nil
+}
+
+// UpdateIfVersion updates the row if its version did not change since it was
+// read with GetWithVersion and returns its new version. The row of the other
+// version is not updated and ErrVersionMismatch is returned. The writes of
+// the table wait for the update, so the version does not change between the
+// check and the update, except for the writes with the batches that are not
+// committed yet.
+//
+// Example:
+//
+//	// If-Match: "42"
+//	version, err := tokenBalanceTable.UpdateIfVersion(ctx, tokenBalance, 42)
+//	if errors.Is(err, bond.ErrVersionMismatch) {
+//		// 412 Precondition Failed
+//	}
+func (t *_table[T]) UpdateIfVersion(ctx context.Context, tr T, version uint64) (uint64, error) {
+	if err := t.checkVersioned(); err != nil {
+		return 0, err
+	}
+
+	t.writeMutex.Lock()
+	defer t.writeMutex.Unlock()
+
+	_, current, err := t.GetWithVersion(tr)
+	if err != nil {
+		return 0, err
+	}
+
+	if current != version {
+		var keyBuffer [DataKeyBufferSize]byte
+		return 0, t.newError(nil, t.key(tr, keyBuffer[:0]),
+			fmt.Errorf("%w: version is %d, expected %d", ErrVersionMismatch, current, version))
+	}
+
+	indexes, writeHooks := t.writeIndexes()
+	err = t.update(ctx, []T{tr}, indexes, writeHooks)
+	if err != nil {
+		return 0, err
+	}
+
+	_, current, err = t.GetWithVersion(tr)
+	return current, err
+}
+
+// replaceFrom takes over the sequence of the staging table, whose rows are
+// read by the table once their storage IDs are swapped. Both sequences
+// continue from the highest version either of them reserved, so the versions
+// do not repeat, and the sequences swap their keys with the rows. The
+// returned function takes them over once the batch is committed.
+func (s *_versionSequence) replaceFrom(batch Batch, staging *_versionSequence) (func(), error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	staging.mutex.Lock()
+	defer staging.mutex.Unlock()
+
+	next, limit := s.next, s.limit
+	if staging.next > next {
+		next = staging.next
+	}
+	if staging.limit > limit {
+		limit = staging.limit
+	}
+
+	// the keys are written after the old contents of the table are deleted
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], limit)
+	for _, key := range [][]byte{s.key, staging.key} {
+		err := batch.Set(key, data[:], Sync)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		staging.mutex.Lock()
+		defer staging.mutex.Unlock()
+
+		s.key, staging.key = staging.key, s.key
+		s.next, s.limit = next, limit
+		staging.next, staging.limit = next, limit
+	}, nil
+}
@@ -0,0 +1,112 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_Table_Versioned(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+		Versioned: true,
+	})
+
+	tokenBalance := &TokenBalance{ID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount", Balance: 5}
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	tr, version, err := tokenBalanceTable.GetWithVersion(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalance, tr)
+	assert.Equal(t, uint64(1), version)
+
+	// the update with the current version succeeds
+	tokenBalanceUpdated := &TokenBalance{ID: 1, ContractAddress: "0xtestContract", AccountAddress: "0xtestAccount", Balance: 7}
+	newVersion, err := tokenBalanceTable.UpdateIfVersion(context.Background(), tokenBalanceUpdated, version)
+	require.NoError(t, err)
+	assert.Greater(t, newVersion, version)
+
+	// the update with the stale version fails
+	_, err = tokenBalanceTable.UpdateIfVersion(context.Background(), &TokenBalance{ID: 1, Balance: 9}, version)
+	require.ErrorIs(t, err, ErrVersionMismatch)
+
+	tr, version, err = tokenBalanceTable.GetWithVersion(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalanceUpdated, tr)
+	assert.Equal(t, newVersion, version)
+
+	// the plain writes change the version too
+	err = tokenBalanceTable.Upsert(context.Background(), []*TokenBalance{tokenBalance}, TableUpsertOnConflictReplace[*TokenBalance])
+	require.NoError(t, err)
+
+	_, err = tokenBalanceTable.UpdateIfVersion(context.Background(), tokenBalanceUpdated, version)
+	require.ErrorIs(t, err, ErrVersionMismatch)
+
+	// the row inserted again after the delete does not reuse the versions
+	_, lastVersion, err := tokenBalanceTable.GetWithVersion(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Delete(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	_, _, err = tokenBalanceTable.GetWithVersion(&TokenBalance{ID: 1})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	_, err = tokenBalanceTable.UpdateIfVersion(context.Background(), tokenBalance, lastVersion)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tokenBalance})
+	require.NoError(t, err)
+
+	_, version, err = tokenBalanceTable.GetWithVersion(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+	assert.Greater(t, version, lastVersion)
+}
+
+func TestBond_Table_Versioned_AfterRestart(t *testing.T) {
+	db := setupDatabase()
+
+	newTokenBalanceTable := func(db DB) Table[*TokenBalance] {
+		return NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   TableID(1),
+			TableName: "token_balance",
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddUint64Field(tb.ID).Bytes()
+			},
+			Versioned: true,
+		})
+	}
+
+	tokenBalanceTable := newTokenBalanceTable(db)
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{{ID: 1, Balance: 5}})
+	require.NoError(t, err)
+
+	_, version, err := tokenBalanceTable.GetWithVersion(&TokenBalance{ID: 1})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Close())
+
+	db = setupDatabase()
+	defer tearDownDatabase(db)
+
+	tokenBalanceTable = newTokenBalanceTable(db)
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{{ID: 2, Balance: 7}})
+	require.NoError(t, err)
+
+	_, newVersion, err := tokenBalanceTable.GetWithVersion(&TokenBalance{ID: 2})
+	require.NoError(t, err)
+	assert.Greater(t, newVersion, version)
+}
@@ -0,0 +1,157 @@
+package bond
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// prepareRowsChunkSize is the number of the rows the writes that do not need
+// all the rows prepared at once prepare at a time.
+const prepareRowsChunkSize = 4096
+
+type _preparedRow struct {
+	key       []byte
+	data      []byte
+	indexKeys [][]byte
+	err       error
+
+	// write writes the data the row refers to, e.g. the overflow chunks, to
+	// the batch of the row
+	write func(batch Batch) error
+}
+
+// prepareRows serializes the rows and computes their keys and index keys
+// using the pool of workers. The prepared rows keep the order of trs, the
+// row that failed to be prepared carries the error.
+func (t *_table[T]) prepareRows(ctx context.Context, trs []T, indexes map[IndexID]*Index[T]) []_preparedRow {
+	return t.prepareRowsConcurrently(ctx, trs, indexes, true)
+}
+
+// prepareRowKeys computes the keys and the index keys of the rows using the
+// pool of workers, as prepareRows does, without serializing the rows.
+func (t *_table[T]) prepareRowKeys(ctx context.Context, trs []T, indexes map[IndexID]*Index[T]) []_preparedRow {
+	return t.prepareRowsConcurrently(ctx, trs, indexes, false)
+}
+
+func (t *_table[T]) prepareRowsConcurrently(ctx context.Context, trs []T, indexes map[IndexID]*Index[T], serialize bool) []_preparedRow {
+	rows := make([]_preparedRow, len(trs))
+
+	workers := t.writeConcurrency
+	if workers > len(trs) {
+		workers = len(trs)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for worker := 0; worker < workers; worker++ {
+		go func(worker int) {
+			defer wg.Done()
+
+			// the keys are built in the buffers of the worker, the prepared
+			// rows hold the copies of the bytes they need
+			var (
+				keyBuffer       [DataKeyBufferSize]byte
+				indexKeysBuffer = make([]byte, 0, (PrimaryKeyBufferSize+IndexKeyBufferSize)*len(indexes))
+				indexKeys       = make([][]byte, 0, len(indexes))
+			)
+
+			for i := worker; i < len(trs); i += workers {
+				if err := ctx.Err(); err != nil {
+					rows[i].err = fmt.Errorf("context done: %w", err)
+					return
+				}
+
+				rows[i] = t.prepareRow(trs[i], indexes, serialize, keyBuffer[:0], indexKeysBuffer[:0], indexKeys[:0])
+				if rows[i].err != nil {
+					return
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+
+	return rows
+}
+
+// prepareRow serializes the row and computes its keys in the given buffers.
+// The panics of the key functions and the serializer are returned as the error
+// of the row, as the worker goroutines can not be recovered by the caller.
+func (t *_table[T]) prepareRow(tr T, indexes map[IndexID]*Index[T], serialize bool, keyBuffer []byte, indexKeysBuffer []byte, indexKeys [][]byte) (row _preparedRow) {
+	key, err := t.safeKey(tr, keyBuffer)
+	if err != nil {
+		return _preparedRow{err: err}
+	}
+
+	indexKeys, err = t.safeIndexKeys(tr, key, indexes, indexKeysBuffer, indexKeys)
+	if err != nil {
+		return _preparedRow{err: err}
+	}
+
+	if serialize {
+		row.data, row.write, err = t.safeSerialize(tr, key)
+		if err != nil {
+			return _preparedRow{err: err}
+		}
+	}
+
+	row.key = make([]byte, len(key))
+	copy(row.key, key)
+
+	var size int
+	for _, indexKey := range indexKeys {
+		size += len(indexKey)
+	}
+
+	buff := make([]byte, 0, size)
+	row.indexKeys = make([][]byte, 0, len(indexKeys))
+	for _, indexKey := range indexKeys {
+		buff = append(buff, indexKey...)
+		row.indexKeys = append(row.indexKeys, buff[len(buff)-len(indexKey):len(buff):len(buff)])
+	}
+	return row
+}
+
+// safeKey is key that returns the panic of the primary key function as the
+// error.
+func (t *_table[T]) safeKey(tr T, buff []byte) (key []byte, err error) {
+	defer t.recoverPanic(&err, nil, nil, "primary key")
+	return t.key(tr, buff), nil
+}
+
+// safeSerialize serializes the row and returns the panic of the serializer as
+// the error with the key of the row.
+func (t *_table[T]) safeSerialize(tr T, key []byte) (data []byte, write func(batch Batch) error, err error) {
+	defer t.recoverPanic(&err, nil, key, "serializer")
+	return serializeFor(t.serializer, &tr)
+}
+
+// _rowChange is the row written by the table write. The old is the version
+// replaced or deleted by the write, the new is the version written.
+type _rowChange[T any] struct {
+	old    T
+	new    T
+	hasOld bool
+	hasNew bool
+}
+
+// _writeHook is called with the rows changed by the write before the write is
+// applied. The changes it makes to the batch are applied with the write.
+type _writeHook[T any] func(ctx context.Context, batch Batch, changes []_rowChange[T]) error
+
+func (t *_table[T]) addWriteHook(hook _writeHook[T]) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.writeHooks = append(t.writeHooks, hook)
+}
+
+func (t *_table[T]) runWriteHooks(ctx context.Context, hooks []_writeHook[T], batch Batch, changes []_rowChange[T]) error {
+	for _, hook := range hooks {
+		err := hook(ctx, batch, changes)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package bond
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBondTable_Insert_WriteConcurrency(t *testing.T) {
+	db, err := Open(dbName, &Options{WriteConcurrency: 4})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 100; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i),
+			AccountID:       uint32(i % 3),
+			ContractAddress: fmt.Sprintf("0xtestContract%d", i),
+			AccountAddress:  fmt.Sprintf("0xtestAccount%d", i%3),
+			Balance:         uint64(i * 10),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	var tokenBalancesFromQuery []*TokenBalance
+	err = tokenBalanceTable.Query().Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances, tokenBalancesFromQuery)
+
+	err = tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount1"}).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, 34, len(tokenBalancesFromQuery))
+
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 101, AccountAddress: "0xtestAccount1"},
+		{ID: 50, AccountAddress: "0xtestAccount1"},
+	})
+	require.Error(t, err)
+
+	assert.False(t, tokenBalanceTable.Exist(&TokenBalance{ID: 101}))
+}
+
+func TestBondTable_Delete_WriteConcurrency(t *testing.T) {
+	db, err := Open(dbName, &Options{WriteConcurrency: 4})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 100; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:              uint64(i),
+			AccountID:       uint32(i % 3),
+			ContractAddress: fmt.Sprintf("0xtestContract%d", i),
+			AccountAddress:  fmt.Sprintf("0xtestAccount%d", i%3),
+			Balance:         uint64(i * 10),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Delete(context.Background(), tokenBalances[:90])
+	require.NoError(t, err)
+
+	var tokenBalancesFromQuery []*TokenBalance
+	err = tokenBalanceTable.Query().Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[90:], tokenBalancesFromQuery)
+
+	err = tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount1"}).
+		Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, 4, len(tokenBalancesFromQuery))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = tokenBalanceTable.Delete(ctx, tokenBalances[90:])
+	require.ErrorIs(t, err, context.Canceled)
+	assert.True(t, tokenBalanceTable.Exist(&TokenBalance{ID: 100}))
+}
+
+func TestBondTable_Insert_WriteConcurrency_Panic(t *testing.T) {
+	db, err := Open(dbName, &Options{WriteConcurrency: 4})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	const (
+		TokenBalanceTableID = TableID(1)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_first_letter_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddByteField(tb.AccountAddress[0]).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= 100; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:             uint64(i),
+			AccountAddress: fmt.Sprintf("0xtestAccount%d", i%3),
+		})
+	}
+
+	// the prepared rows hold the keys of their size, not the key buffers
+	table := tokenBalanceTable.(*_table[*TokenBalance])
+	rows := table.prepareRows(context.Background(), tokenBalances, table.secondaryIndexes)
+	for _, row := range rows {
+		require.NoError(t, row.err)
+		assert.Equal(t, len(row.key), cap(row.key))
+		require.Len(t, row.indexKeys, 1)
+		assert.Equal(t, len(row.indexKeys[0]), cap(row.indexKeys[0]))
+	}
+
+	// the panic of the index key function in the worker is returned as the
+	// error instead of crashing the process
+	tokenBalances[50].AccountAddress = ""
+
+	var tableErr *TableError
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.ErrorIs(t, err, ErrCallbackPanic)
+	require.True(t, errors.As(err, &tableErr))
+	assert.Equal(t, table.key(tokenBalances[50], make([]byte, 0, DataKeyBufferSize)), tableErr.Key)
+	assert.False(t, tokenBalanceTable.Exist(&TokenBalance{ID: 1}))
+}
+
+func TestBondTable_Delete_WriteConcurrency_Chunks(t *testing.T) {
+	db, err := Open(dbName, &Options{WriteConcurrency: 4})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	TokenBalanceAccountAddressIndex := NewIndex[*TokenBalance](IndexOptions[*TokenBalance]{
+		IndexID:   PrimaryIndexID + 1,
+		IndexName: "account_address_idx",
+		IndexKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		IndexOrderFunc: IndexOrderDefault[*TokenBalance],
+	})
+
+	err = tokenBalanceTable.AddIndex([]*Index[*TokenBalance]{TokenBalanceAccountAddressIndex})
+	require.NoError(t, err)
+
+	// the keys of the rows are prepared by more than one chunk
+	var tokenBalances []*TokenBalance
+	for i := 1; i <= prepareRowsChunkSize*2+10; i++ {
+		tokenBalances = append(tokenBalances, &TokenBalance{
+			ID:             uint64(i),
+			AccountAddress: fmt.Sprintf("0xtestAccount%d", i%3),
+		})
+	}
+
+	err = tokenBalanceTable.Insert(context.Background(), tokenBalances)
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Delete(context.Background(), tokenBalances[5:])
+	require.NoError(t, err)
+
+	var tokenBalancesFromQuery []*TokenBalance
+	err = tokenBalanceTable.Query().Execute(context.Background(), &tokenBalancesFromQuery)
+	require.NoError(t, err)
+	assert.Equal(t, tokenBalances[:5], tokenBalancesFromQuery)
+
+	keys, err := tokenBalanceTable.Query().
+		With(TokenBalanceAccountAddressIndex, &TokenBalance{AccountAddress: "0xtestAccount1"}).
+		Keys(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, len(keys))
+}
@@ -0,0 +1,336 @@
+package bond
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/cockroachdb/pebble"
+)
+
+type TimeSeriesTableOptions[T any] struct {
+	DB DB
+
+	TableID   TableID
+	TableName string
+
+	// SeriesKeyFunc builds the key of the series the row belongs to. The rows
+	// are keyed by the series key followed by the timestamp.
+	SeriesKeyFunc func(builder KeyBuilder, tr T) []byte
+	TimestampFunc func(tr T) time.Time
+
+	// Retention is the age of the rows removed by ApplyRetention. The rows are
+	// kept forever if it is not set.
+	Retention time.Duration
+
+	Serializer Serializer[*T]
+}
+
+// TimeSeriesTable is the table of the rows keyed by the series and the
+// timestamp. The rows of the series are stored in the timestamp order, so
+// the time ranges of the series are read with a single seek.
+type TimeSeriesTable[T any] struct {
+	Table[T]
+
+	table *_table[T]
+
+	seriesKeyFunc func(builder KeyBuilder, tr T) []byte
+	timestampFunc func(tr T) time.Time
+
+	retention time.Duration
+}
+
+func NewTimeSeriesTable[T any](opt TimeSeriesTableOptions[T]) *TimeSeriesTable[T] {
+	table := NewTable[T](TableOptions[T]{
+		DB:        opt.DB,
+		TableID:   opt.TableID,
+		TableName: opt.TableName,
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tr T) []byte {
+			seriesKey := opt.SeriesKeyFunc(builder, tr)
+			return NewKeyBuilder(seriesKey).AddInt64Field(opt.TimestampFunc(tr).UnixNano()).Bytes()
+		},
+		Serializer: opt.Serializer,
+	})
+
+	return &TimeSeriesTable[T]{
+		Table:         table,
+		table:         table.(*_table[T]),
+		seriesKeyFunc: opt.SeriesKeyFunc,
+		timestampFunc: opt.TimestampFunc,
+		retention:     opt.Retention,
+	}
+}
+
+// Append writes the rows without reading the existing ones. The row appended
+// with the series and the timestamp of the existing row replaces it, but its
+// old version is not removed from the indexes and the rollups, so the rows are
+// expected to be new. The existing rows are replaced with Update or Upsert.
+func (ts *TimeSeriesTable[T]) Append(ctx context.Context, trs []T, optBatch ...Batch) error {
+	t := ts.table
+
+	indexes, writeHooks, endWrite := t.beginWrite()
+	defer endWrite()
+
+	var (
+		batch         Batch
+		externalBatch = len(optBatch) > 0 && optBatch[0] != nil
+	)
+	if externalBatch {
+		batch = optBatch[0]
+	} else {
+		batch = t.db.Batch()
+	}
+
+	defer func() {
+		if !externalBatch {
+			_ = batch.Close()
+		}
+	}()
+
+	var (
+		keyBuffer       [DataKeyBufferSize]byte
+		indexKeysBuffer = make([]byte, 0, (PrimaryKeyBufferSize+IndexKeyBufferSize)*len(indexes))
+		indexKeys       = make([][]byte, 0, len(indexes))
+	)
+
+	var invalidation _cacheInvalidation
+	var written int
+	var changes []_rowChange[T]
+
+	for _, tr := range trs {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		key := t.key(tr, keyBuffer[:0])
+		t.collectInvalidation(&invalidation, key, indexes, tr)
+		if len(writeHooks) > 0 {
+			changes = append(changes, _rowChange[T]{new: tr, hasNew: true})
+		}
+
+		data, err := t.serialize(&tr, batch)
+		if err != nil {
+			return err
+		}
+
+		err = batch.Set(key, data, Sync)
+		if err != nil {
+			return err
+		}
+		written += len(key) + len(data)
+
+		indexKeys = t.indexKeys(tr, indexes, indexKeysBuffer[:0], indexKeys[:0])
+		for _, indexKey := range indexKeys {
+			err = batch.Set(indexKey, t.indexEntryValue(indexKey, indexes, tr), Sync)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	err := t.runWriteHooks(ctx, writeHooks, batch, changes)
+	if err != nil {
+		return err
+	}
+
+	reservation, err := t.reserveQuota(ctx, batch, externalBatch, len(trs), written)
+	if err != nil {
+		return err
+	}
+	defer reservation.release()
+
+	// abort before the writes are committed
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("context done: %w", ctx.Err())
+	default:
+	}
+
+	if !externalBatch {
+		err = batch.Commit(ContextRetrieveWriteOptions(ctx))
+		if err != nil {
+			return err
+		}
+	}
+
+	reservation.keep()
+
+	t.invalidateCache(invalidation, batch, externalBatch)
+
+	return nil
+}
+
+// Range reads the rows of the series the selector belongs to with the
+// timestamps from the start up to, but not including, the end.
+func (ts *TimeSeriesTable[T]) Range(ctx context.Context, series T, start time.Time, end time.Time, trs *[]T, optBatch ...Batch) error {
+	var batch Batch
+	if len(optBatch) > 0 && optBatch[0] != nil {
+		batch = optBatch[0]
+	}
+
+	iter := ts.table.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: ts.key(series, start.UnixNano()),
+			UpperBound: ts.key(series, end.UnixNano()),
+		},
+	}, batch)
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	records := (*trs)[:0]
+	for iter.First(); iter.Valid(); iter.Next() {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		var tr T
+		err := ts.table.serializer.Deserialize(iter.Value(), &tr)
+		if err != nil {
+			return ts.table.newError(nil, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
+		}
+
+		records = append(records, tr)
+	}
+
+	*trs = records
+	return nil
+}
+
+// ApplyRetention removes the rows older than the retention from all the
+// series. The removed rows stay in the rollups.
+func (ts *TimeSeriesTable[T]) ApplyRetention(ctx context.Context) error {
+	if ts.retention == 0 {
+		return nil
+	}
+
+	t := ts.table
+	cutoff := t.clock.Now().Add(-ts.retention).UnixNano()
+
+	indexes, _, endWrite := t.beginWrite()
+	defer endWrite()
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: []byte{byte(t.storageID()), byte(PrimaryIndexID)},
+			UpperBound: []byte{byte(t.storageID()), byte(PrimaryIndexID + 1)},
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	batch := t.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	var invalidation _cacheInvalidation
+	for valid := iter.First(); valid; {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("context done: %w", ctx.Err())
+		default:
+		}
+
+		// the first row of the series
+		var series T
+		err := t.serializer.Deserialize(iter.Value(), &series)
+		if err != nil {
+			return t.newError(nil, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
+		}
+
+		seriesStart, seriesCutoff, seriesEnd := ts.key(series, math.MinInt64), ts.key(series, cutoff), ts.key(series, math.MaxInt64)
+		if len(indexes) > 0 || t.cache != nil || t.queryCache != nil {
+			// the index keys and the cached rows are removed row by row
+			err = ts.deleteRows(seriesStart, seriesCutoff, indexes, batch, &invalidation)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = batch.DeleteRange(seriesStart, seriesCutoff, Sync)
+		if err != nil {
+			return err
+		}
+
+		// the next series
+		valid = iter.SeekGE(seriesEnd)
+		if valid && bytes.Equal(iter.Key(), seriesEnd) {
+			valid = iter.Next()
+		}
+	}
+
+	err := batch.Commit(Sync)
+	if err != nil {
+		return err
+	}
+
+	t.invalidateCache(invalidation, batch, false)
+
+	return nil
+}
+
+func (ts *TimeSeriesTable[T]) deleteRows(start []byte, end []byte, indexes map[IndexID]*Index[T], batch Batch, invalidation *_cacheInvalidation) error {
+	t := ts.table
+
+	iter := t.db.Iter(&IterOptions{
+		IterOptions: pebble.IterOptions{
+			LowerBound: start,
+			UpperBound: end,
+		},
+	})
+	defer func() {
+		_ = iter.Close()
+	}()
+
+	indexKeysBuffer := make([]byte, 0, (PrimaryKeyBufferSize+IndexKeyBufferSize)*len(indexes))
+	indexKeys := make([][]byte, 0, len(indexes))
+	for iter.First(); iter.Valid(); iter.Next() {
+		var tr T
+		err := t.serializer.Deserialize(iter.Value(), &tr)
+		if err != nil {
+			return t.newError(nil, iter.Key(), fmt.Errorf("failed to deserialize: %w", err))
+		}
+
+		t.collectInvalidation(invalidation, iter.Key(), indexes, tr)
+
+		indexKeys = t.indexKeys(tr, indexes, indexKeysBuffer[:0], indexKeys[:0])
+		for _, indexKey := range indexKeys {
+			err = batch.Delete(indexKey, Sync)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// key builds the row key of the series at the timestamp.
+func (ts *TimeSeriesTable[T]) key(series T, timestamp int64) []byte {
+	seriesKey := ts.seriesKeyFunc(NewKeyBuilder(make([]byte, 0, PrimaryKeyBufferSize)), series)
+	primaryKey := NewKeyBuilder(seriesKey).AddInt64Field(timestamp).Bytes()
+
+	return KeyEncode(Key{
+		TableID:    ts.table.storageID(),
+		IndexID:    PrimaryIndexID,
+		IndexKey:   []byte{},
+		IndexOrder: []byte{},
+		PrimaryKey: primaryKey,
+	})
+}
+
+// NewTimeSeriesRollup creates the view of the time series table that
+// downsamples its rows to the intervals. The mapFunc maps the row to its
+// contribution to the rollup row of the interval that starts at the bucket.
+func NewTimeSeriesRollup[T any, V any](ts *TimeSeriesTable[T], rollup Table[V], interval time.Duration, mapFunc func(tr T, bucket time.Time) (V, bool), reduceFunc ViewReduceFunc[V]) *View[T, V] {
+	return NewView[T, V](ts.table, rollup, func(tr T) (V, bool) {
+		return mapFunc(tr, ts.timestampFunc(tr).Truncate(interval))
+	}, reduceFunc)
+}
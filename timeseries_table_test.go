@@ -0,0 +1,99 @@
+package bond
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_TimeSeriesTable(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		BalanceHistoryTableID       = TableID(1)
+		BalanceHistoryHourlyTableID = TableID(2)
+	)
+
+	// the balance history of the accounts, the ID is the unix time of the balance
+	balanceHistory := NewTimeSeriesTable[*TokenBalance](TimeSeriesTableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   BalanceHistoryTableID,
+		TableName: "balance_history",
+		SeriesKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddStringField(tb.AccountAddress).Bytes()
+		},
+		TimestampFunc: func(tb *TokenBalance) time.Time {
+			return time.Unix(int64(tb.ID), 0)
+		},
+		Retention: 24 * time.Hour,
+	})
+
+	// the sums of the balances per hour
+	hourly := NewTimeSeriesRollup[*TokenBalance, *TokenBalance](balanceHistory,
+		NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:        db,
+			TableID:   BalanceHistoryHourlyTableID,
+			TableName: "balance_history_hourly",
+			TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+				return builder.AddStringField(tb.AccountAddress).AddUint64Field(tb.ID).Bytes()
+			},
+		}),
+		time.Hour,
+		func(tb *TokenBalance, bucket time.Time) (*TokenBalance, bool) {
+			return &TokenBalance{AccountAddress: tb.AccountAddress, ID: uint64(bucket.Unix()), Balance: tb.Balance}, true
+		},
+		func(acc *TokenBalance, tb *TokenBalance, remove bool) (*TokenBalance, bool) {
+			if remove {
+				acc.Balance -= tb.Balance
+			} else {
+				acc.Balance += tb.Balance
+			}
+			return acc, true
+		},
+	)
+
+	hour := time.Now().Truncate(time.Hour)
+	old := hour.Add(-48 * time.Hour)
+
+	err := balanceHistory.Append(context.Background(), []*TokenBalance{
+		{ID: uint64(old.Unix()), AccountAddress: "0xtestAccount", Balance: 1},
+		{ID: uint64(hour.Unix()), AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: uint64(hour.Add(time.Minute).Unix()), AccountAddress: "0xtestAccount", Balance: 7},
+		{ID: uint64(hour.Add(time.Hour).Unix()), AccountAddress: "0xtestAccount", Balance: 9},
+		{ID: uint64(old.Unix()), AccountAddress: "0xtestAccount2", Balance: 2},
+		{ID: uint64(hour.Unix()), AccountAddress: "0xtestAccount2", Balance: 3},
+	})
+	require.NoError(t, err)
+
+	var balances []*TokenBalance
+	err = balanceHistory.Range(context.Background(), &TokenBalance{AccountAddress: "0xtestAccount"},
+		hour, hour.Add(time.Hour), &balances)
+	require.NoError(t, err)
+	require.Len(t, balances, 2)
+	assert.Equal(t, uint64(5), balances[0].Balance)
+	assert.Equal(t, uint64(7), balances[1].Balance)
+
+	hourlyBalance, err := hourly.Get(&TokenBalance{AccountAddress: "0xtestAccount", ID: uint64(hour.Unix())})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(12), hourlyBalance.Balance)
+
+	err = balanceHistory.ApplyRetention(context.Background())
+	require.NoError(t, err)
+
+	balances = nil
+	err = balanceHistory.Scan(context.Background(), &balances)
+	require.NoError(t, err)
+	require.Len(t, balances, 4)
+	for _, balance := range balances {
+		assert.GreaterOrEqual(t, balance.ID, uint64(hour.Unix()))
+	}
+
+	// the rollups are kept
+	hourlyBalance, err = hourly.Get(&TokenBalance{AccountAddress: "0xtestAccount2", ID: uint64(old.Unix())})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), hourlyBalance.Balance)
+}
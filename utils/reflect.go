@@ -41,3 +41,68 @@ func FindRootInterface(v reflect.Value) any {
 		return v.Interface()
 	}
 }
+
+// ResetPointer sets the value pointed by v to its zero value, so that the
+// object can be reused. It does nothing if v is not a non-nil pointer.
+func ResetPointer(v any) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv.Elem().Set(reflect.Zero(rv.Elem().Type()))
+	}
+}
+
+// SizeOf estimates the number of bytes of memory used by v, including the
+// memory referenced through its pointers, strings, slices, maps and interfaces.
+// The value is expected to be acyclic, as it is the case for deserialized rows.
+func SizeOf(v any) int {
+	if v == nil {
+		return 0
+	}
+
+	rv := reflect.ValueOf(v)
+	return int(rv.Type().Size()) + sizeOfReferenced(rv)
+}
+
+func sizeOfReferenced(rv reflect.Value) int {
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return 0
+		}
+		return int(rv.Type().Elem().Size()) + sizeOfReferenced(rv.Elem())
+	case reflect.Interface:
+		if rv.IsNil() {
+			return 0
+		}
+		return int(rv.Elem().Type().Size()) + sizeOfReferenced(rv.Elem())
+	case reflect.String:
+		return rv.Len()
+	case reflect.Slice:
+		size := rv.Cap() * int(rv.Type().Elem().Size())
+		for i := 0; i < rv.Len(); i++ {
+			size += sizeOfReferenced(rv.Index(i))
+		}
+		return size
+	case reflect.Array:
+		size := 0
+		for i := 0; i < rv.Len(); i++ {
+			size += sizeOfReferenced(rv.Index(i))
+		}
+		return size
+	case reflect.Map:
+		size := rv.Len() * int(rv.Type().Key().Size()+rv.Type().Elem().Size())
+		iter := rv.MapRange()
+		for iter.Next() {
+			size += sizeOfReferenced(iter.Key()) + sizeOfReferenced(iter.Value())
+		}
+		return size
+	case reflect.Struct:
+		size := 0
+		for i := 0; i < rv.NumField(); i++ {
+			size += sizeOfReferenced(rv.Field(i))
+		}
+		return size
+	default:
+		return 0
+	}
+}
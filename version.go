@@ -3,8 +3,6 @@ package bond
 import (
 	"fmt"
 	"strconv"
-
-	"github.com/cockroachdb/pebble"
 )
 
 const (
@@ -13,10 +11,12 @@ const (
 )
 
 func (db *_db) Version() int {
-	value, _, err := db.pebble.Get(bondDataVersionKey())
+	value, closer, err := db.backend().Get(bondDataVersionKey())
 	if err != nil {
 		return 0
 	}
+	defer func() { _ = closer.Close() }()
+
 	ver, _ := strconv.ParseInt(string(value), 10, 32)
 	return int(ver)
 }
@@ -26,7 +26,7 @@ func (db *_db) initVersion() error {
 		return nil
 	}
 	ver := fmt.Sprintf("%d", BOND_DB_DATA_VERSION)
-	return db.pebble.Set(bondDataVersionKey(), []byte(ver), pebble.Sync)
+	return db.backend().Set(bondDataVersionKey(), []byte(ver), Sync)
 }
 
 func bondDataVersionKey() []byte {
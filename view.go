@@ -0,0 +1,163 @@
+package bond
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ViewMapFunc maps the source row to its contribution to the view row. The
+// source row does not contribute to the view if it returns false.
+type ViewMapFunc[T any, V any] func(tr T) (V, bool)
+
+// ViewReduceFunc merges the contribution into the view row, or takes it away
+// from the view row if the remove is set. The view row is deleted if it
+// returns false. The contribution that has no view row to be merged into
+// becomes the view row.
+type ViewReduceFunc[V any] func(acc V, v V, remove bool) (V, bool)
+
+// View is the table maintained from the source table. The rows written to the
+// source table are mapped to the contributions and reduced into the view rows
+// that have the same primary key, in the same batch as the source write.
+type View[T any, V any] struct {
+	TableReader[V]
+
+	source *_table[T]
+	view   Table[V]
+
+	mapFunc    ViewMapFunc[T, V]
+	reduceFunc ViewReduceFunc[V]
+}
+
+// NewView creates the view of the source table stored in the view table. The
+// view table must not be written to directly. The rows that exist in the source
+// table before the view is created are added to the view with Backfill.
+func NewView[T any, V any](source Table[T], view Table[V], mapFunc ViewMapFunc[T, V], reduceFunc ViewReduceFunc[V]) *View[T, V] {
+	sourceTable, ok := source.(*_table[T])
+	if !ok {
+		panic(fmt.Errorf("view source table %s is not bond table", source.Name()))
+	}
+
+	v := &View[T, V]{
+		TableReader: view,
+		source:      sourceTable,
+		view:        view,
+		mapFunc:     mapFunc,
+		reduceFunc:  reduceFunc,
+	}
+
+	sourceTable.addWriteHook(v.apply)
+	return v
+}
+
+// Backfill rebuilds the view from the rows of the source table. The source
+// table should not be written to until the backfill finishes.
+func (v *View[T, V]) Backfill(ctx context.Context) error {
+	err := v.clear()
+	if err != nil {
+		return fmt.Errorf("failed to clear view %s: %w", v.view.Name(), err)
+	}
+
+	batch := v.source.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	changes := make([]_rowChange[T], 0, ReindexBatchSize)
+	flush := func() error {
+		err := v.apply(ctx, batch, changes)
+		if err != nil {
+			return err
+		}
+
+		err = batch.Commit(Sync)
+		if err != nil {
+			return fmt.Errorf("failed to commit view backfill batch: %w", err)
+		}
+
+		batch.Reset()
+		changes = changes[:0]
+		return nil
+	}
+
+	err = v.source.ScanForEach(ctx, func(_ KeyBytes, lazy Lazy[T]) (bool, error) {
+		tr, err := lazy.Get()
+		if err != nil {
+			return false, err
+		}
+
+		changes = append(changes, _rowChange[T]{new: tr, hasNew: true})
+		if len(changes) >= ReindexBatchSize {
+			return true, flush()
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+// clear deletes the rows of the view table.
+func (v *View[T, V]) clear() error {
+	viewID := storageIDOf(v.source.db, v.view.ID())
+
+	batch := v.source.db.Batch()
+	defer func() {
+		_ = batch.Close()
+	}()
+
+	err := v.source.deletePrefix(batch, []byte{byte(viewID)})
+	if err != nil {
+		return err
+	}
+	return batch.Commit(Sync)
+}
+
+func (v *View[T, V]) apply(ctx context.Context, batch Batch, changes []_rowChange[T]) error {
+	for _, change := range changes {
+		if change.hasOld {
+			if contribution, ok := v.mapFunc(change.old); ok {
+				err := v.reduce(ctx, batch, contribution, true)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if change.hasNew {
+			if contribution, ok := v.mapFunc(change.new); ok {
+				err := v.reduce(ctx, batch, contribution, false)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (v *View[T, V]) reduce(ctx context.Context, batch Batch, contribution V, remove bool) error {
+	acc, err := v.view.Get(contribution, batch)
+	if errors.Is(err, ErrNotFound) {
+		if remove {
+			return nil
+		}
+		return v.view.Insert(ctx, []V{contribution}, batch)
+	} else if err != nil {
+		return fmt.Errorf("failed to get view %s row: %w", v.view.Name(), err)
+	}
+
+	acc, keep := v.reduceFunc(acc, contribution, remove)
+	if !keep {
+		// the reduce may modify the row in place, so the stored version is
+		// read again to delete its index keys
+		stored, err := v.view.Get(contribution, batch)
+		if err != nil {
+			return fmt.Errorf("failed to get view %s row: %w", v.view.Name(), err)
+		}
+		return v.view.Delete(ctx, []V{stored}, batch)
+	}
+	return v.view.Update(ctx, []V{acc}, batch)
+}
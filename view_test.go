@@ -0,0 +1,149 @@
+package bond
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_View(t *testing.T) {
+	db := setupDatabase()
+	defer tearDownDatabase(db)
+
+	const (
+		TokenBalanceTableID           = TableID(1)
+		AccountBalanceTableID         = TableID(2)
+		AccountBalanceBackfillTableID = TableID(0xFF)
+	)
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TokenBalanceTableID,
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	accountBalanceKeyFunc := func(builder KeyBuilder, tb *TokenBalance) []byte {
+		return builder.AddStringField(tb.AccountAddress).Bytes()
+	}
+
+	accountBalanceMap := func(tb *TokenBalance) (*TokenBalance, bool) {
+		if tb.Balance == 0 {
+			return nil, false
+		}
+		return &TokenBalance{AccountAddress: tb.AccountAddress, Balance: tb.Balance}, true
+	}
+
+	accountBalanceReduce := func(acc *TokenBalance, tb *TokenBalance, remove bool) (*TokenBalance, bool) {
+		if remove {
+			acc.Balance -= tb.Balance
+		} else {
+			acc.Balance += tb.Balance
+		}
+		return acc, acc.Balance != 0
+	}
+
+	accountBalanceView := NewView[*TokenBalance, *TokenBalance](tokenBalanceTable,
+		NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:                  db,
+			TableID:             AccountBalanceTableID,
+			TableName:           "account_balance",
+			TablePrimaryKeyFunc: accountBalanceKeyFunc,
+		}),
+		accountBalanceMap, accountBalanceReduce,
+	)
+
+	err := tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 7},
+		{ID: 3, AccountAddress: "0xtestAccount2", Balance: 3},
+	})
+	require.NoError(t, err)
+
+	accountBalance, err := accountBalanceView.Get(&TokenBalance{AccountAddress: "0xtestAccount"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(12), accountBalance.Balance)
+
+	err = tokenBalanceTable.Update(context.Background(), []*TokenBalance{
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 10},
+	})
+	require.NoError(t, err)
+
+	err = tokenBalanceTable.Upsert(context.Background(), []*TokenBalance{
+		{ID: 3, AccountAddress: "0xtestAccount", Balance: 1},
+	}, TableUpsertOnConflictReplace[*TokenBalance])
+	require.NoError(t, err)
+
+	var accountBalances []*TokenBalance
+	err = accountBalanceView.Scan(context.Background(), &accountBalances)
+	require.NoError(t, err)
+	require.Len(t, accountBalances, 1)
+	assert.Equal(t, uint64(16), accountBalances[0].Balance)
+
+	// the view is written in the same batch as the source table
+	batch := db.Batch()
+	err = tokenBalanceTable.Delete(context.Background(), []*TokenBalance{
+		{ID: 1, AccountAddress: "0xtestAccount", Balance: 5},
+	}, batch)
+	require.NoError(t, err)
+
+	accountBalance, err = accountBalanceView.Get(&TokenBalance{AccountAddress: "0xtestAccount"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(16), accountBalance.Balance)
+
+	err = batch.Commit(Sync)
+	require.NoError(t, err)
+	_ = batch.Close()
+
+	accountBalance, err = accountBalanceView.Get(&TokenBalance{AccountAddress: "0xtestAccount"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(11), accountBalance.Balance)
+
+	err = tokenBalanceTable.Delete(context.Background(), []*TokenBalance{
+		{ID: 2, AccountAddress: "0xtestAccount", Balance: 10},
+		{ID: 3, AccountAddress: "0xtestAccount", Balance: 1},
+	})
+	require.NoError(t, err)
+
+	_, err = accountBalanceView.Get(&TokenBalance{AccountAddress: "0xtestAccount"})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// backfill
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{
+		{ID: 4, AccountAddress: "0xtestAccount", Balance: 2},
+		{ID: 5, AccountAddress: "0xtestAccount", Balance: 4},
+	})
+	require.NoError(t, err)
+
+	backfillView := NewView[*TokenBalance, *TokenBalance](tokenBalanceTable,
+		NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+			DB:                  db,
+			TableID:             AccountBalanceBackfillTableID,
+			TableName:           "account_balance_backfill",
+			TablePrimaryKeyFunc: accountBalanceKeyFunc,
+		}),
+		accountBalanceMap, accountBalanceReduce,
+	)
+
+	_, err = backfillView.Get(&TokenBalance{AccountAddress: "0xtestAccount"})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	err = backfillView.Backfill(context.Background())
+	require.NoError(t, err)
+
+	accountBalance, err = backfillView.Get(&TokenBalance{AccountAddress: "0xtestAccount"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), accountBalance.Balance)
+
+	// the view is cleared before it's backfilled again
+	err = backfillView.Backfill(context.Background())
+	require.NoError(t, err)
+
+	accountBalance, err = backfillView.Get(&TokenBalance{AccountAddress: "0xtestAccount"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), accountBalance.Balance)
+}
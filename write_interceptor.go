@@ -0,0 +1,158 @@
+package bond
+
+import (
+	"sync"
+
+	"github.com/cockroachdb/pebble"
+)
+
+// DefaultWriteInterceptorQueueSize is the number of the writes queued for the
+// asynchronous WriteInterceptor before the writes wait for it.
+const DefaultWriteInterceptorQueueSize = 1024
+
+// WriteMutation is the single mutation committed to the database. The Value is
+// the value that is set, the end key of the deleted range, or the operand that
+// is merged, e.g. the delta of the row. The TableID is
+// the table of the key, the BOND_DB_DATA_TABLE_ID for the bond data such as
+// the catalog and the index statistics.
+type WriteMutation struct {
+	TableID TableID
+	Kind    WriteChangeKind
+	Key     []byte
+	Value   []byte
+
+	// Seq is the sequence number of the commit of the mutation, see
+	// CommittedBatchInfo.Seq.
+	Seq uint64
+}
+
+// WriteInterceptor receives the mutations of the committed write. The
+// mutations are copied, so the interceptor can keep them.
+type WriteInterceptor func(mutations []WriteMutation)
+
+// _writeInterceptor passes the committed mutations to the interceptor, from
+// the background goroutine if it's asynchronous.
+type _writeInterceptor struct {
+	interceptor WriteInterceptor
+
+	queue chan []WriteMutation
+	done  chan struct{}
+
+	closeOnce sync.Once
+}
+
+func newWriteInterceptor(opts *Options) *_writeInterceptor {
+	if opts.WriteInterceptor == nil {
+		return nil
+	}
+
+	wi := &_writeInterceptor{interceptor: opts.WriteInterceptor}
+	if opts.WriteInterceptorAsync {
+		wi.queue = make(chan []WriteMutation, DefaultWriteInterceptorQueueSize)
+		wi.done = make(chan struct{})
+		go wi.run()
+	}
+	return wi
+}
+
+func (wi *_writeInterceptor) run() {
+	defer close(wi.done)
+	for mutations := range wi.queue {
+		wi.interceptor(mutations)
+	}
+}
+
+// intercept passes the mutations to the interceptor. The asynchronous
+// interceptor gets them once the previously queued ones are passed.
+func (wi *_writeInterceptor) intercept(mutations []WriteMutation) {
+	if wi == nil || len(mutations) == 0 {
+		return
+	}
+
+	if wi.queue != nil {
+		wi.queue <- mutations
+		return
+	}
+	wi.interceptor(mutations)
+}
+
+// observesMutations returns true if the committed mutations are passed to
+// the write interceptor or the table change listeners.
+func (db *_db) observesMutations() bool {
+	return db.writeInterceptor != nil || db.tableChanges.observed()
+}
+
+// notifyMutations passes the committed mutations to the write interceptor and
+// the table change listeners.
+func (db *_db) notifyMutations(mutations []WriteMutation) {
+	db.mapMutationTables(mutations)
+	db.writeInterceptor.intercept(mutations)
+	db.tableChanges.notify(mutations, db.catalog.storageID)
+}
+
+// mapMutationTables sets the tables of the mutations of the keys stored
+// under the IDs of the other tables, see ReplaceFrom.
+func (db *_db) mapMutationTables(mutations []WriteMutation) {
+	if db.catalog == nil || !db.catalog.replaced() {
+		return
+	}
+
+	for i := range mutations {
+		if mutations[i].TableID != BOND_DB_DATA_TABLE_ID {
+			mutations[i].TableID = db.catalog.tableOfStorage(mutations[i].TableID)
+		}
+	}
+}
+
+// close waits for the queued mutations to be passed to the interceptor.
+func (wi *_writeInterceptor) close() {
+	if wi == nil || wi.queue == nil {
+		return
+	}
+
+	wi.closeOnce.Do(func() {
+		close(wi.queue)
+		<-wi.done
+	})
+}
+
+// batchMutations returns the copies of the mutations of the batch.
+func batchMutations(batch *pebble.Batch) []WriteMutation {
+	mutations := make([]WriteMutation, 0, batch.Count())
+
+	reader := batch.Reader()
+	for {
+		kind, key, value, ok := reader.Next()
+		if !ok {
+			return mutations
+		}
+
+		switch kind {
+		case pebble.InternalKeyKindSet:
+			mutations = append(mutations, newWriteMutation(WriteChangeSet, key, value))
+		case pebble.InternalKeyKindDelete, pebble.InternalKeyKindSingleDelete:
+			mutations = append(mutations, newWriteMutation(WriteChangeDelete, key, nil))
+		case pebble.InternalKeyKindRangeDelete:
+			mutations = append(mutations, newWriteMutation(WriteChangeDeleteRange, key, value))
+		case pebble.InternalKeyKindMerge:
+			mutations = append(mutations, newWriteMutation(WriteChangeMerge, key, value))
+		}
+	}
+}
+
+func newWriteMutation(kind WriteChangeKind, key []byte, value []byte) WriteMutation {
+	var tableID TableID
+	if len(key) > 0 {
+		tableID = TableID(key[0])
+	}
+
+	mutation := WriteMutation{
+		TableID: tableID,
+		Kind:    kind,
+		Key:     append([]byte{}, key...),
+	}
+	if value != nil {
+		mutation.Value = append([]byte{}, value...)
+	}
+	return mutation
+}
@@ -0,0 +1,123 @@
+package bond
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBond_WriteInterceptor(t *testing.T) {
+	var (
+		mutex     sync.Mutex
+		mutations []WriteMutation
+	)
+
+	db, err := Open(dbName, &Options{
+		WriteInterceptor: func(m []WriteMutation) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			mutations = append(mutations, m...)
+		},
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dbName)
+	}()
+
+	tokenBalanceTable := NewTable[*TokenBalance](TableOptions[*TokenBalance]{
+		DB:        db,
+		TableID:   TableID(1),
+		TableName: "token_balance",
+		TablePrimaryKeyFunc: func(builder KeyBuilder, tb *TokenBalance) []byte {
+			return builder.AddUint64Field(tb.ID).Bytes()
+		},
+	})
+
+	tableMutations := func() []WriteMutation {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		var result []WriteMutation
+		for _, mutation := range mutations {
+			if mutation.TableID == tokenBalanceTable.ID() {
+				result = append(result, mutation)
+			}
+		}
+		mutations = nil
+		return result
+	}
+
+	tb := &TokenBalance{ID: 1, AccountAddress: "0xa1", Balance: 5}
+	err = tokenBalanceTable.Insert(context.Background(), []*TokenBalance{tb})
+	require.NoError(t, err)
+
+	data, err := tokenBalanceTable.Serializer().Serialize(&tb)
+	require.NoError(t, err)
+
+	inserted := tableMutations()
+	require.Len(t, inserted, 1)
+	assert.Equal(t, WriteChangeSet, inserted[0].Kind)
+	assert.Equal(t, PrimaryIndexID, KeyBytes(inserted[0].Key).IndexID())
+	assert.Equal(t, data, inserted[0].Value)
+
+	err = tokenBalanceTable.Delete(context.Background(), []*TokenBalance{tb})
+	require.NoError(t, err)
+
+	deleted := tableMutations()
+	require.Len(t, deleted, 1)
+	assert.Equal(t, WriteChangeDelete, deleted[0].Kind)
+	assert.Equal(t, inserted[0].Key, deleted[0].Key)
+
+	// dry run writes are not committed
+	err = tokenBalanceTable.Insert(ContextWithWriteOptions(context.Background(), WriteOptions{DryRun: true, Report: &WriteReport{}}), []*TokenBalance{tb})
+	require.NoError(t, err)
+	assert.Len(t, tableMutations(), 0)
+}
+
+func TestBond_WriteInterceptor_Async(t *testing.T) {
+	var mutations []WriteMutation
+
+	db, err := Open(dbName, &Options{
+		WriteInterceptor: func(m []WriteMutation) {
+			mutations = append(mutations, m...)
+		},
+		WriteInterceptorAsync: true,
+	})
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(dbName)
+	}()
+
+	for i := 0; i < 100; i++ {
+		err = db.Set([]byte{0x01, byte(i)}, []byte{byte(i)}, Sync)
+		require.NoError(t, err)
+	}
+
+	err = db.DeleteRange([]byte{0x01, 0x00}, []byte{0x01, 0x10}, Sync)
+	require.NoError(t, err)
+
+	// close waits for the queued mutations
+	require.NoError(t, db.Close())
+
+	require.Len(t, mutations, 101)
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, WriteChangeSet, mutations[i].Kind)
+		assert.Equal(t, TableID(1), mutations[i].TableID)
+		assert.Equal(t, []byte{0x01, byte(i)}, mutations[i].Key)
+		if i > 0 {
+			assert.Equal(t, mutations[i-1].Seq+1, mutations[i].Seq)
+		}
+	}
+	assert.Equal(t, WriteMutation{
+		TableID: TableID(1),
+		Kind:    WriteChangeDeleteRange,
+		Key:     []byte{0x01, 0x00},
+		Value:   []byte{0x01, 0x10},
+		Seq:     mutations[99].Seq + 1,
+	}, mutations[100])
+}